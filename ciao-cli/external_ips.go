@@ -74,9 +74,12 @@ func getExternalIPRef(address string) (string, error) {
 
 var externalIPCommand = &command{
 	SubCommands: map[string]subCommand{
-		"map":   new(externalIPMapCommand),
-		"list":  new(externalIPListCommand),
-		"unmap": new(externalIPUnMapCommand),
+		"map":           new(externalIPMapCommand),
+		"list":          new(externalIPListCommand),
+		"unmap":         new(externalIPUnMapCommand),
+		"forward":       new(externalIPForwardCommand),
+		"list-forwards": new(externalIPListForwardsCommand),
+		"unforward":     new(externalIPUnForwardCommand),
 	},
 }
 
@@ -284,6 +287,216 @@ func (cmd *externalIPUnMapCommand) run(args []string) error {
 	return nil
 }
 
+// Port forwards are tenant resources, so we always address them through
+// the tenant, even for admin users.
+func getCiaoPortForwardsURL() string {
+	return buildCiaoURL("%s/external-ips/port-forwards", *tenantID)
+}
+
+type externalIPForwardCommand struct {
+	Flag         flag.FlagSet
+	instanceID   string
+	poolName     string
+	address      string
+	protocol     string
+	port         int
+	instancePort int
+}
+
+func (cmd *externalIPForwardCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] external-ip forward [flags]
+
+Forward a port of an external IP to a port of an instance.  The external IP is
+either one whose ports are already forwarded, given with -address, or a new one
+taken from a pool.  All the ports of an external IP are forwarded to instances
+on the same subnet.
+
+The forward flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *externalIPForwardCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.instanceID, "instance", "", "ID of the instance to forward the port to")
+	cmd.Flag.StringVar(&cmd.poolName, "pool", "", "Name of the pool of a new external IP")
+	cmd.Flag.StringVar(&cmd.address, "address", "", "External IP whose ports are already forwarded")
+	cmd.Flag.StringVar(&cmd.protocol, "protocol", "tcp", "Protocol of the port, tcp or udp")
+	cmd.Flag.IntVar(&cmd.port, "port", 0, "Port of the external IP")
+	cmd.Flag.IntVar(&cmd.instancePort, "instance-port", 0, "Port of the instance, defaults to -port")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *externalIPForwardCommand) run(args []string) error {
+	if cmd.instanceID == "" {
+		errorf("Missing required -instance parameter")
+		cmd.usage()
+	}
+
+	if cmd.port == 0 {
+		errorf("Missing required -port parameter")
+		cmd.usage()
+	}
+
+	if cmd.address != "" && cmd.poolName != "" {
+		errorf("Only one of -address and -pool may be given")
+		cmd.usage()
+	}
+
+	req := types.PortForwardRequest{
+		ExternalIP:   cmd.address,
+		Protocol:     cmd.protocol,
+		ExternalPort: cmd.port,
+		InstanceID:   cmd.instanceID,
+		InternalPort: cmd.instancePort,
+	}
+
+	if cmd.poolName != "" {
+		req.PoolName = &cmd.poolName
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+
+	resp, err := sendCiaoRequest("POST", getCiaoPortForwardsURL(), nil, body, api.ExternalIPsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		fatalf("Port forward failed: %s", resp.Status)
+	}
+
+	var pf types.PortForward
+	err = unmarshalHTTPResponse(resp, &pf)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	fmt.Printf("Forwarded %s %s:%d to %s:%d: %s\n", pf.Protocol, pf.ExternalIP,
+		pf.ExternalPort, pf.InternalIP, pf.InternalPort, pf.ID)
+
+	return nil
+}
+
+type externalIPListForwardsCommand struct {
+	Flag     flag.FlagSet
+	template string
+}
+
+func (cmd *externalIPListForwardsCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] external-ip list-forwards [flags]
+
+List the forwarded ports of external IPs.
+
+The list-forwards flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s",
+		tfortools.GenerateUsageDecorated("f", types.ListPortForwardsResponse{}.PortForwards, nil))
+	os.Exit(2)
+}
+
+func (cmd *externalIPListForwardsCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *externalIPListForwardsCommand) run(args []string) error {
+	var pfs types.ListPortForwardsResponse
+
+	resp, err := sendCiaoRequest("GET", getCiaoPortForwardsURL(), nil, nil, api.ExternalIPsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Port forward list failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &pfs)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "external-ip-list-forwards", cmd.template,
+			&pfs.PortForwards, nil)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 1, 1, ' ', 0)
+	fmt.Fprintf(w, "#\tID\tProtocol\tExternalIP\tPort\tInternalIP\tPort\tInstanceID\n")
+
+	for i, pf := range pfs.PortForwards {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\t%d\t%s\n", i+1, pf.ID, pf.Protocol,
+			pf.ExternalIP, pf.ExternalPort, pf.InternalIP, pf.InternalPort, pf.InstanceID)
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+type externalIPUnForwardCommand struct {
+	Flag flag.FlagSet
+	id   string
+}
+
+func (cmd *externalIPUnForwardCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] external-ip unforward [flags]
+
+Stop forwarding a port of an external IP.  The external IP is returned to its
+pool once none of its ports are forwarded.
+
+The unforward flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *externalIPUnForwardCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.id, "id", "", "Port forward UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *externalIPUnForwardCommand) run(args []string) error {
+	if cmd.id == "" {
+		errorf("Missing required -id parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoPortForwardsURL(), cmd.id)
+
+	resp, err := sendCiaoRequest("DELETE", url, nil, nil, api.ExternalIPsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Port forward deletion failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Deleted port forward: %s\n", cmd.id)
+
+	return nil
+}
+
 var poolCommand = &command{
 	SubCommands: map[string]subCommand{
 		"create": new(poolCreateCommand),
@@ -17,11 +17,15 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"regexp"
@@ -43,12 +47,15 @@ const (
 
 var instanceCommand = &command{
 	SubCommands: map[string]subCommand{
-		"add":     new(instanceAddCommand),
-		"delete":  new(instanceDeleteCommand),
-		"list":    new(instanceListCommand),
-		"show":    new(instanceShowCommand),
-		"restart": new(instanceRestartCommand),
-		"stop":    new(instanceStopCommand),
+		"add":      new(instanceAddCommand),
+		"delete":   new(instanceDeleteCommand),
+		"list":     new(instanceListCommand),
+		"show":     new(instanceShowCommand),
+		"restart":  new(instanceRestartCommand),
+		"stop":     new(instanceStopCommand),
+		"resize":   new(instanceResizeCommand),
+		"snapshot": new(instanceSnapshotCommand),
+		"console":  new(instanceConsoleCommand),
 	},
 }
 
@@ -377,13 +384,16 @@ func (v *volumeFlagSlice) Set(value string) error {
 }
 
 type instanceAddCommand struct {
-	Flag      flag.FlagSet
-	workload  string
-	instances int
-	label     string
-	volumes   volumeFlagSlice
-	name      string
-	template  string
+	Flag           flag.FlagSet
+	workload       string
+	instances      int
+	label          string
+	volumes        volumeFlagSlice
+	name           string
+	template       string
+	serverGroup    string
+	securityGroups string
+	networks       string
 }
 
 func (cmd *instanceAddCommand) usage(...string) {
@@ -407,6 +417,9 @@ func (cmd *instanceAddCommand) parseArgs(args []string) []string {
 	cmd.Flag.Var(&cmd.volumes, "volume", "volume descriptor argument list")
 	cmd.Flag.StringVar(&cmd.name, "name", "", "Name for this instance. When multiple instances are requested this is used as a prefix")
 	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.StringVar(&cmd.serverGroup, "server-group", "", "Server group UUID the instances are to be members of")
+	cmd.Flag.StringVar(&cmd.securityGroups, "security-groups", "", "Comma separated names or UUIDs of the security groups the instances are to be members of, default if empty")
+	cmd.Flag.StringVar(&cmd.networks, "networks", "", "Comma separated names or UUIDs of the networks the instances are to be attached to, the first one being the primary network")
 	cmd.Flag.Usage = func() { cmd.usage() }
 	cmd.Flag.Parse(args)
 	return cmd.Flag.Args()
@@ -429,9 +442,9 @@ func (cmd *instanceAddCommand) validateAddCommandArgs() {
 	}
 
 	if cmd.name != "" {
-		r := regexp.MustCompile("^[a-z0-9-]{1,64}?$")
+		r := regexp.MustCompile("^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$")
 		if !r.MatchString(cmd.name) {
-			errorf("Requested name must be between 1 and 64 lowercase letters, numbers and hyphens")
+			errorf("Requested name must be between 1 and 63 lowercase letters, numbers and hyphens, and must not start or end with a hyphen")
 		}
 	}
 
@@ -472,6 +485,24 @@ func populateCreateServerRequest(cmd *instanceAddCommand, server *compute.Create
 	server.Server.MinInstances = 1
 	server.Server.Name = cmd.name
 
+	if cmd.serverGroup != "" {
+		server.SchedulerHints = &compute.SchedulerHints{Group: cmd.serverGroup}
+	}
+
+	if cmd.securityGroups != "" {
+		for _, group := range strings.Split(cmd.securityGroups, ",") {
+			server.Server.SecurityGroups = append(server.Server.SecurityGroups,
+				compute.SecurityGroup{Name: group})
+		}
+	}
+
+	if cmd.networks != "" {
+		for _, network := range strings.Split(cmd.networks, ",") {
+			server.Server.Networks = append(server.Server.Networks,
+				compute.Network{UUID: network})
+		}
+	}
+
 	for _, volume := range cmd.volumes {
 		bd := compute.BlockDeviceMappingV2{
 			DeviceName:          "", //unsupported
@@ -675,6 +706,218 @@ func (cmd *instanceStopCommand) run([]string) error {
 	return err
 }
 
+type instanceResizeCommand struct {
+	Flag     flag.FlagSet
+	instance string
+	workload string
+}
+
+func (cmd *instanceResizeCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] instance resize [flags]
+
+Resize a stopped Ciao instance to the VCPUs and memory of a workload.  The
+new resources are used when the instance is next restarted.
+
+The resize flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *instanceResizeCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.instance, "instance", "", "Instance UUID")
+	cmd.Flag.StringVar(&cmd.workload, "workload", "", "UUID of the workload whose resources to use")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *instanceResizeCommand) run([]string) error {
+	if *tenantID == "" {
+		errorf("Missing required -tenant-id parameter")
+		cmd.usage()
+	}
+
+	if cmd.instance == "" {
+		errorf("Missing required -instance parameter")
+		cmd.usage()
+	}
+
+	if cmd.workload == "" {
+		errorf("Missing required -workload parameter")
+		cmd.usage()
+	}
+
+	var req compute.ResizeServerRequest
+	req.Resize.FlavorRef = cmd.workload
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	url := buildComputeURL("%s/servers/%s/action", *tenantID, cmd.instance)
+
+	resp, err := sendHTTPRequest("POST", url, nil, bytes.NewReader(b))
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusAccepted {
+		fatalf("Instance resize failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Instance %s resized\n", cmd.instance)
+	return nil
+}
+
+type instanceSnapshotCommand struct {
+	Flag     flag.FlagSet
+	instance string
+	name     string
+}
+
+func (cmd *instanceSnapshotCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] instance snapshot [flags]
+
+Create a new image from the boot volume of a stopped Ciao instance.
+
+The snapshot flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *instanceSnapshotCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.instance, "instance", "", "Instance UUID")
+	cmd.Flag.StringVar(&cmd.name, "name", "", "Image Name")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *instanceSnapshotCommand) run([]string) error {
+	if *tenantID == "" {
+		errorf("Missing required -tenant-id parameter")
+		cmd.usage()
+	}
+
+	if cmd.instance == "" {
+		errorf("Missing required -instance parameter")
+		cmd.usage()
+	}
+
+	var req compute.CreateImageRequest
+	req.CreateImage.Name = cmd.name
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	url := buildComputeURL("%s/servers/%s/action", *tenantID, cmd.instance)
+
+	resp, err := sendHTTPRequest("POST", url, nil, bytes.NewReader(b))
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusAccepted {
+		fatalf("Instance snapshot failed: %s", resp.Status)
+	}
+
+	var image compute.CreateImageResponse
+	err = unmarshalHTTPResponse(resp, &image)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	fmt.Printf("Instance %s snapshotted to image %s\n", cmd.instance, image.ImageID)
+	return nil
+}
+
+type instanceConsoleCommand struct {
+	Flag     flag.FlagSet
+	instance string
+}
+
+func (cmd *instanceConsoleCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] instance console [flags]
+
+Attach to the serial console of a Ciao instance.  Input is sent to the
+console a line at a time.  Use Ctrl-D or Ctrl-C to detach.
+
+The console flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *instanceConsoleCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.instance, "instance", "", "Instance UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *instanceConsoleCommand) run([]string) error {
+	if *tenantID == "" {
+		errorf("Missing required -tenant-id parameter")
+		cmd.usage()
+	}
+
+	if cmd.instance == "" {
+		errorf("Missing required -instance parameter")
+		cmd.usage()
+	}
+
+	url := buildComputeURL("%s/servers/%s/console", *tenantID, cmd.instance)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		fatalf(err.Error())
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	infof("Sending %s %s\n", req.Method, url)
+
+	conn, err := tls.Dial("tcp", req.URL.Host, clientTLSConfig())
+	if err != nil {
+		fatalf(err.Error())
+	}
+	defer conn.Close()
+
+	err = req.Write(conn)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := ioutil.ReadAll(resp.Body)
+		fatalf("Instance console failed: %s: %s", resp.Status, body)
+	}
+
+	fmt.Fprintf(os.Stderr, "Connected to console of instance %s\n", cmd.instance)
+
+	go func() {
+		_, _ = io.Copy(conn, os.Stdin)
+		_ = conn.CloseWrite()
+	}()
+
+	_, err = io.Copy(os.Stdout, r)
+	return err
+}
+
 func startStopInstance(instance string, stop bool) error {
 	if *tenantID == "" {
 		return errors.New("Missing required -tenant-id parameter")
@@ -904,6 +1147,9 @@ func dumpInstance(server *compute.ServerDetails) {
 	fmt.Printf("\tUUID: %s\n", server.ID)
 	fmt.Printf("\tStatus: %s\n", server.Status)
 	fmt.Printf("\tPrivate IP: %s\n", server.PrivateAddresses[0].Addr)
+	if server.PrivateAddresses[0].IPv6Addr != "" {
+		fmt.Printf("\tPrivate IPv6: %s\n", server.PrivateAddresses[0].IPv6Addr)
+	}
 	fmt.Printf("\tMAC Address: %s\n", server.PrivateAddresses[0].MacAddr)
 	fmt.Printf("\tCN UUID: %s\n", server.NodeID)
 	fmt.Printf("\tTenant UUID: %s\n", server.TenantID)
@@ -0,0 +1,437 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ciao-project/ciao/ciao-controller/api"
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/intel/tfortools"
+)
+
+var loadBalancerCommand = &command{
+	SubCommands: map[string]subCommand{
+		"create":        new(loadBalancerCreateCommand),
+		"list":          new(loadBalancerListCommand),
+		"show":          new(loadBalancerShowCommand),
+		"delete":        new(loadBalancerDeleteCommand),
+		"add-member":    new(loadBalancerAddMemberCommand),
+		"delete-member": new(loadBalancerDeleteMemberCommand),
+	},
+}
+
+// Load balancers are tenant resources, so we always address them through
+// the tenant, even for admin users.
+func getCiaoLoadBalancersURL() string {
+	return buildCiaoURL("%s/load-balancers", *tenantID)
+}
+
+type loadBalancerCreateCommand struct {
+	Flag       flag.FlagSet
+	name       string
+	pool       string
+	port       int
+	memberPort int
+	interval   int
+	timeout    int
+	members    string
+}
+
+func (cmd *loadBalancerCreateCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] load-balancer create [flags]
+
+Creates a new TCP load balancer.  The load balancer is given an external IP
+from a pool and proxies the connections to its port to one of its healthy
+members, in turn.  Members are instances of a single subnet and are healthy
+as long as they accept connections on the member port.
+
+The create flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *loadBalancerCreateCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.name, "name", "", "Name of the load balancer")
+	cmd.Flag.StringVar(&cmd.pool, "pool", "", "Name of the pool of the external IP, any pool if empty")
+	cmd.Flag.IntVar(&cmd.port, "port", 0, "Port the load balancer listens on")
+	cmd.Flag.IntVar(&cmd.memberPort, "member-port", 0, "Port of the members, port if 0")
+	cmd.Flag.IntVar(&cmd.interval, "health-interval", 0, "Seconds between two health checks of the members")
+	cmd.Flag.IntVar(&cmd.timeout, "health-timeout", 0, "Seconds after which a member not accepting connections is unhealthy")
+	cmd.Flag.StringVar(&cmd.members, "members", "", "Comma separated list of member instance UUIDs")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *loadBalancerCreateCommand) run(args []string) error {
+	var lb types.LoadBalancer
+
+	if cmd.name == "" {
+		errorf("Missing required -name parameter")
+		cmd.usage()
+	}
+
+	if cmd.port == 0 {
+		errorf("Missing required -port parameter")
+		cmd.usage()
+	}
+
+	req := types.LoadBalancerRequest{
+		Name:                cmd.name,
+		PoolName:            cmd.pool,
+		Protocol:            "tcp",
+		Port:                cmd.port,
+		MemberPort:          cmd.memberPort,
+		HealthCheckInterval: cmd.interval,
+		HealthCheckTimeout:  cmd.timeout,
+	}
+
+	if cmd.members != "" {
+		req.Members = strings.Split(cmd.members, ",")
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+
+	resp, err := sendCiaoRequest("POST", getCiaoLoadBalancersURL(), nil, body, api.LoadBalancersV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		fatalf("Load balancer creation failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &lb)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	fmt.Printf("Created new load balancer: %s on %s:%d\n", lb.ID, lb.ExternalIP, lb.Port)
+
+	return nil
+}
+
+type loadBalancerListCommand struct {
+	Flag     flag.FlagSet
+	template string
+}
+
+func (cmd *loadBalancerListCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] load-balancer list [flags]
+
+List all load balancers of the tenant.
+
+The list flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s",
+		tfortools.GenerateUsageDecorated("f", types.ListLoadBalancersResponse{}.LoadBalancers, nil))
+	os.Exit(2)
+}
+
+func (cmd *loadBalancerListCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *loadBalancerListCommand) run(args []string) error {
+	var lbs types.ListLoadBalancersResponse
+
+	resp, err := sendCiaoRequest("GET", getCiaoLoadBalancersURL(), nil, nil, api.LoadBalancersV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Load balancer list failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &lbs)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "load-balancer-list", cmd.template,
+			&lbs.LoadBalancers, nil)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 1, 1, ' ', 0)
+	fmt.Fprintf(w, "#\tUUID\tName\tExternal IP\tPort\tMembers\n")
+
+	for i, lb := range lbs.LoadBalancers {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%d\n", i+1, lb.ID, lb.Name,
+			lb.ExternalIP, lb.Port, len(lb.Members))
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+type loadBalancerShowCommand struct {
+	Flag     flag.FlagSet
+	lb       string
+	template string
+}
+
+func (cmd *loadBalancerShowCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] load-balancer show [flags]
+
+Show load balancer details.
+
+The show flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s", tfortools.GenerateUsageDecorated("f", types.LoadBalancer{}, nil))
+	os.Exit(2)
+}
+
+func (cmd *loadBalancerShowCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.lb, "lb", "", "Load balancer UUID")
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *loadBalancerShowCommand) run(args []string) error {
+	var lb types.LoadBalancer
+
+	if cmd.lb == "" {
+		errorf("Missing required -lb parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoLoadBalancersURL(), cmd.lb)
+
+	resp, err := sendCiaoRequest("GET", url, nil, nil, api.LoadBalancersV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Load balancer show failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &lb)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "load-balancer-show", cmd.template,
+			&lb, nil)
+	}
+
+	fmt.Printf("\tUUID: %s\n", lb.ID)
+	fmt.Printf("\tName: %s\n", lb.Name)
+	fmt.Printf("\tExternal IP: %s\n", lb.ExternalIP)
+	fmt.Printf("\tPool: %s\n", lb.PoolName)
+	fmt.Printf("\tProtocol: %s\n", lb.Protocol)
+	fmt.Printf("\tPort: %d\n", lb.Port)
+	fmt.Printf("\tMember Port: %d\n", lb.MemberPort)
+	fmt.Printf("\tHealth Check Interval: %ds\n", lb.HealthCheckInterval)
+	fmt.Printf("\tHealth Check Timeout: %ds\n", lb.HealthCheckTimeout)
+	fmt.Printf("\tMembers: %s\n", strings.Join(lb.Members, ", "))
+
+	return nil
+}
+
+type loadBalancerDeleteCommand struct {
+	Flag flag.FlagSet
+	lb   string
+}
+
+func (cmd *loadBalancerDeleteCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] load-balancer delete [flags]
+
+Deletes a load balancer and returns its external IP to its pool.
+
+The delete flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *loadBalancerDeleteCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.lb, "lb", "", "Load balancer UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *loadBalancerDeleteCommand) run(args []string) error {
+	if cmd.lb == "" {
+		errorf("Missing required -lb parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoLoadBalancersURL(), cmd.lb)
+
+	resp, err := sendCiaoRequest("DELETE", url, nil, nil, api.LoadBalancersV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Load balancer deletion failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Deleted load balancer: %s\n", cmd.lb)
+
+	return nil
+}
+
+type loadBalancerAddMemberCommand struct {
+	Flag     flag.FlagSet
+	lb       string
+	instance string
+}
+
+func (cmd *loadBalancerAddMemberCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] load-balancer add-member [flags]
+
+Adds an instance to the members of a load balancer.  The instance must be on
+the same subnet as the other members.
+
+The add-member flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *loadBalancerAddMemberCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.lb, "lb", "", "Load balancer UUID")
+	cmd.Flag.StringVar(&cmd.instance, "instance", "", "Instance UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *loadBalancerAddMemberCommand) run(args []string) error {
+	if cmd.lb == "" {
+		errorf("Missing required -lb parameter")
+		cmd.usage()
+	}
+
+	if cmd.instance == "" {
+		errorf("Missing required -instance parameter")
+		cmd.usage()
+	}
+
+	req := types.LoadBalancerMemberRequest{
+		InstanceID: cmd.instance,
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+
+	url := fmt.Sprintf("%s/%s/members", getCiaoLoadBalancersURL(), cmd.lb)
+
+	resp, err := sendCiaoRequest("POST", url, nil, body, api.LoadBalancersV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Load balancer member addition failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Added instance %s to load balancer %s\n", cmd.instance, cmd.lb)
+
+	return nil
+}
+
+type loadBalancerDeleteMemberCommand struct {
+	Flag     flag.FlagSet
+	lb       string
+	instance string
+}
+
+func (cmd *loadBalancerDeleteMemberCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] load-balancer delete-member [flags]
+
+Removes an instance from the members of a load balancer.
+
+The delete-member flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *loadBalancerDeleteMemberCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.lb, "lb", "", "Load balancer UUID")
+	cmd.Flag.StringVar(&cmd.instance, "instance", "", "Instance UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *loadBalancerDeleteMemberCommand) run(args []string) error {
+	if cmd.lb == "" {
+		errorf("Missing required -lb parameter")
+		cmd.usage()
+	}
+
+	if cmd.instance == "" {
+		errorf("Missing required -instance parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s/members/%s", getCiaoLoadBalancersURL(), cmd.lb, cmd.instance)
+
+	resp, err := sendCiaoRequest("DELETE", url, nil, nil, api.LoadBalancersV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Load balancer member removal failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Removed instance %s from load balancer %s\n", cmd.instance, cmd.lb)
+
+	return nil
+}
@@ -84,17 +84,21 @@ type subCommand interface {
 }
 
 var commands = map[string]subCommand{
-	"instance":    instanceCommand,
-	"workload":    workloadCommand,
-	"tenant":      tenantCommand,
-	"event":       eventCommand,
-	"node":        nodeCommand,
-	"trace":       traceCommand,
-	"image":       imageCommand,
-	"volume":      volumeCommand,
-	"pool":        poolCommand,
-	"external-ip": externalIPCommand,
-	"quotas":      quotasCommand,
+	"instance":       instanceCommand,
+	"workload":       workloadCommand,
+	"tenant":         tenantCommand,
+	"event":          eventCommand,
+	"node":           nodeCommand,
+	"trace":          traceCommand,
+	"image":          imageCommand,
+	"volume":         volumeCommand,
+	"pool":           poolCommand,
+	"external-ip":    externalIPCommand,
+	"quotas":         quotasCommand,
+	"server-group":   serverGroupCommand,
+	"security-group": securityGroupCommand,
+	"network":        networkCommand,
+	"load-balancer":  loadBalancerCommand,
 }
 
 var scopedToken string
@@ -173,6 +177,21 @@ func buildImageURL(format string, args ...interface{}) string {
 	return fmt.Sprintf(prefix+format, args...)
 }
 
+func clientTLSConfig() *tls.Config {
+	tlsConfig := &tls.Config{}
+
+	if caCertPool != nil {
+		tlsConfig.RootCAs = caCertPool
+	}
+
+	if clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*clientCert}
+		tlsConfig.BuildNameToCertificate()
+	}
+
+	return tlsConfig
+}
+
 func sendHTTPRequestToken(method string, url string, values []queryValue, token string, body io.Reader, content string) (*http.Response, error) {
 	req, err := http.NewRequest(method, os.ExpandEnv(url), body)
 	if err != nil {
@@ -201,19 +220,8 @@ func sendHTTPRequestToken(method string, url string, values []queryValue, token
 		req.Header.Set("Accept", "application/json")
 	}
 
-	tlsConfig := &tls.Config{}
-
-	if caCertPool != nil {
-		tlsConfig.RootCAs = caCertPool
-	}
-
-	if clientCert != nil {
-		tlsConfig.Certificates = []tls.Certificate{*clientCert}
-		tlsConfig.BuildNameToCertificate()
-	}
-
 	transport := &http.Transport{
-		TLSClientConfig: tlsConfig,
+		TLSClientConfig: clientTLSConfig(),
 	}
 
 	client := &http.Client{Transport: transport}
@@ -0,0 +1,289 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/ciao-project/ciao/ciao-controller/api"
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/intel/tfortools"
+)
+
+var networkCommand = &command{
+	SubCommands: map[string]subCommand{
+		"create": new(networkCreateCommand),
+		"list":   new(networkListCommand),
+		"show":   new(networkShowCommand),
+		"delete": new(networkDeleteCommand),
+	},
+}
+
+// Like security groups, networks are tenant resources and are always
+// addressed through the tenant.
+func getCiaoNetworksURL() string {
+	return buildCiaoURL("%s/networks", *tenantID)
+}
+
+type networkCreateCommand struct {
+	Flag flag.FlagSet
+	name string
+	cidr string
+}
+
+func (cmd *networkCreateCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] network create [flags]
+
+Creates a new tenant network.  The subnet of the network must be a /24 subnet
+of 172.16.0.0/12 that is not already used by the tenant.
+
+Instances are attached to networks with the -networks flag of instance add.
+
+The create flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *networkCreateCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.name, "name", "", "Name of the network")
+	cmd.Flag.StringVar(&cmd.cidr, "cidr", "", "Subnet of the network, e.g. 172.18.5.0/24")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *networkCreateCommand) run(args []string) error {
+	var network types.TenantNetwork
+
+	if cmd.name == "" {
+		errorf("Missing required -name parameter")
+		cmd.usage()
+	}
+
+	if cmd.cidr == "" {
+		errorf("Missing required -cidr parameter")
+		cmd.usage()
+	}
+
+	req := types.TenantNetworkRequest{
+		Name: cmd.name,
+		CIDR: cmd.cidr,
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+
+	resp, err := sendCiaoRequest("POST", getCiaoNetworksURL(), nil, body, api.NetworksV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		fatalf("Network creation failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &network)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	fmt.Printf("Created new network: %s\n", network.ID)
+
+	return nil
+}
+
+type networkListCommand struct {
+	Flag     flag.FlagSet
+	template string
+}
+
+func (cmd *networkListCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] network list [flags]
+
+List all networks of the tenant.
+
+The list flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s",
+		tfortools.GenerateUsageDecorated("f", types.ListTenantNetworksResponse{}.Networks, nil))
+	os.Exit(2)
+}
+
+func (cmd *networkListCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *networkListCommand) run(args []string) error {
+	var networks types.ListTenantNetworksResponse
+
+	resp, err := sendCiaoRequest("GET", getCiaoNetworksURL(), nil, nil, api.NetworksV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Network list failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &networks)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "network-list", cmd.template,
+			&networks.Networks, nil)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 1, 1, ' ', 0)
+	fmt.Fprintf(w, "#\tUUID\tName\tCIDR\n")
+
+	for i, network := range networks.Networks {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", i+1, network.ID, network.Name, network.CIDR)
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+type networkShowCommand struct {
+	Flag     flag.FlagSet
+	network  string
+	template string
+}
+
+func (cmd *networkShowCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] network show [flags]
+
+Show network details.
+
+The show flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s", tfortools.GenerateUsageDecorated("f", types.TenantNetwork{}, nil))
+	os.Exit(2)
+}
+
+func (cmd *networkShowCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.network, "network", "", "Network UUID")
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *networkShowCommand) run(args []string) error {
+	var network types.TenantNetwork
+
+	if cmd.network == "" {
+		errorf("Missing required -network parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoNetworksURL(), cmd.network)
+
+	resp, err := sendCiaoRequest("GET", url, nil, nil, api.NetworksV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Network show failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &network)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "network-show", cmd.template,
+			&network, nil)
+	}
+
+	fmt.Printf("\tUUID: %s\n", network.ID)
+	fmt.Printf("\tName: %s\n", network.Name)
+	fmt.Printf("\tCIDR: %s\n", network.CIDR)
+
+	return nil
+}
+
+type networkDeleteCommand struct {
+	Flag    flag.FlagSet
+	network string
+}
+
+func (cmd *networkDeleteCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] network delete [flags]
+
+Deletes a network.  No instance may be attached to the network.
+
+The delete flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *networkDeleteCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.network, "network", "", "Network UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *networkDeleteCommand) run(args []string) error {
+	if cmd.network == "" {
+		errorf("Missing required -network parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoNetworksURL(), cmd.network)
+
+	resp, err := sendCiaoRequest("DELETE", url, nil, nil, api.NetworksV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Network deletion failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Deleted network: %s\n", cmd.network)
+
+	return nil
+}
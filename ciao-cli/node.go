@@ -22,8 +22,11 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"text/template"
 
 	"github.com/ciao-project/ciao/ciao-controller/api"
@@ -38,6 +41,8 @@ var nodeCommand = &command{
 		"show":     new(nodeShowCommand),
 		"evacuate": new(nodeEvacuateCommand),
 		"restore":  new(nodeRestoreCommand),
+		"label":    new(nodeLabelCommand),
+		"network":  new(nodeNetworkCommand),
 	},
 }
 
@@ -134,6 +139,11 @@ func dumpNode(node *types.CiaoNode) {
 	fmt.Printf("\t\tTotal Start Failures: %d\n", node.StartFailures)
 	fmt.Printf("\t\tTotal Delete Failures: %d\n", node.DeleteFailures)
 	fmt.Printf("\t\tTotal Attach Failures: %d\n", node.AttachVolumeFailures)
+
+	if len(node.Labels) > 0 {
+		labels := labelFlagMap(node.Labels)
+		fmt.Printf("\tLabels: %s\n", labels.String())
+	}
 }
 
 func dumpNodes(headerText string, url string, t *template.Template) {
@@ -463,3 +473,248 @@ func (cmd *nodeRestoreCommand) parseArgs(args []string) []string {
 func (cmd *nodeRestoreCommand) run(args []string) error {
 	return nodeChangeStatus(cmd.nodeID, types.NodeStatusReady)
 }
+
+type nodeLabelCommand struct {
+	Flag   flag.FlagSet
+	nodeID string
+	labels labelFlagMap
+}
+
+// labelFlagMap implements the flag.Value interface for repeated
+// -label key=value flags.
+type labelFlagMap map[string]string
+
+func (m *labelFlagMap) String() string {
+	var labels []string
+	for key, value := range *m {
+		labels = append(labels, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
+
+func (m *labelFlagMap) Set(value string) error {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return fmt.Errorf("Invalid label %q, expected key=value", value)
+	}
+
+	if *m == nil {
+		*m = make(labelFlagMap)
+	}
+	(*m)[kv[0]] = kv[1]
+
+	return nil
+}
+
+func (cmd *nodeLabelCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] node label
+
+Set the labels of a node, replacing any labels it already has.  Workloads
+with a node selector are only scheduled on nodes carrying all of the
+selector's labels.  Omitting -label removes all labels from the node.
+
+The label flags are:
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *nodeLabelCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.nodeID, "node-id", "", "Node ID")
+	cmd.Flag.Var(&cmd.labels, "label", "Label in key=value form, may be repeated")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *nodeLabelCommand) run(args []string) error {
+	if !checkPrivilege() {
+		fatalf("The labelling of nodes is restricted to admin users")
+	}
+
+	if cmd.nodeID == "" {
+		errorf("Missing required -node-id parameter")
+		cmd.usage()
+	}
+
+	labels := map[string]string(cmd.labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	nodeStatus := types.CiaoNodeStatus{Labels: labels}
+	b, err := json.Marshal(&nodeStatus)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	url, err := getCiaoResource("node", api.NodeV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	url = fmt.Sprintf("%s/%s", url, cmd.nodeID)
+
+	ver := api.NodeV1
+	resp, err := sendCiaoRequest("PUT", url, nil, bytes.NewReader(b), ver)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Node labelling failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Labelled node: %s\n", cmd.nodeID)
+
+	return nil
+}
+
+type nodeNetworkCommand struct {
+	Flag     flag.FlagSet
+	nodeID   string
+	repair   bool
+	last     bool
+	template string
+}
+
+func (cmd *nodeNetworkCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] node network [-node-id] <node-id>
+
+Inspect the network topology of a compute node, network node or CNCI.  The
+node compares the bridges, tunnels and VNICs it expects to the ones actually
+present and, for a CNCI, checks its dnsmasq processes.  With -repair the node
+fixes the problems it can before reporting.
+
+The network flags are:
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, `
+The template passed to the -f option operates on the following structure:
+
+%s`, tfortools.GenerateUsageUndecorated(types.NodeNetwork{}))
+	fmt.Fprintln(os.Stderr, tfortools.TemplateFunctionHelp(nil))
+	os.Exit(2)
+}
+
+func (cmd *nodeNetworkCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.nodeID, "node-id", "", "Node ID")
+	cmd.Flag.BoolVar(&cmd.repair, "repair", false, "Repair the problems found")
+	cmd.Flag.BoolVar(&cmd.last, "last", false, "Show the last report without inspecting the node")
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *nodeNetworkCommand) run(args []string) error {
+	if !checkPrivilege() {
+		fatalf("The inspection of node networks is restricted to admin users")
+	}
+
+	if cmd.nodeID == "" && len(args) > 0 {
+		cmd.nodeID = args[0]
+	}
+
+	if cmd.nodeID == "" {
+		errorf("Missing required -node-id parameter")
+		cmd.usage()
+	}
+
+	if cmd.last && cmd.repair {
+		errorf("-last and -repair cannot be combined")
+		cmd.usage()
+	}
+
+	url, err := getCiaoResource("node", api.NodeV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	url = fmt.Sprintf("%s/%s/network", url, cmd.nodeID)
+
+	method := "POST"
+	var body io.Reader
+	if cmd.last {
+		method = "GET"
+	} else {
+		b, err := json.Marshal(&types.NodeNetworkInspectRequest{Repair: cmd.repair})
+		if err != nil {
+			fatalf(err.Error())
+		}
+		body = bytes.NewReader(b)
+	}
+
+	ver := api.NodeV1
+	resp, err := sendCiaoRequest(method, url, nil, body, ver)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Node network inspection failed: %s", resp.Status)
+	}
+
+	var n types.NodeNetwork
+	err = unmarshalHTTPResponse(resp, &n)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "node-network", cmd.template,
+			&n, nil)
+	}
+
+	dumpNodeNetwork(&n)
+
+	return nil
+}
+
+func dumpNodeNetworkLinks(links []types.NodeNetworkLink, state bool) {
+	for _, l := range links {
+		fmt.Printf("\t%s\n", l.Alias)
+		fmt.Printf("\t\tName: %s\n", l.Name)
+		if l.Master != "" {
+			fmt.Printf("\t\tBridge: %s\n", l.Master)
+		}
+		if state {
+			fmt.Printf("\t\tUp: %t\n", l.Up)
+		}
+	}
+}
+
+func dumpNodeNetwork(n *types.NodeNetwork) {
+	fmt.Printf("Node ID: %s\n", n.NodeID)
+	fmt.Printf("Updated: %v\n", n.Timestamp)
+	fmt.Printf("Repair: %t\n", n.Repair)
+	if n.Error != "" {
+		fmt.Printf("Error: %s\n", n.Error)
+	}
+
+	fmt.Printf("Expected:\n")
+	dumpNodeNetworkLinks(n.Expected, false)
+
+	fmt.Printf("Actual:\n")
+	dumpNodeNetworkLinks(n.Actual, true)
+
+	if len(n.Dnsmasq) > 0 {
+		fmt.Printf("Dnsmasq:\n")
+		for _, d := range n.Dnsmasq {
+			fmt.Printf("\t%s: pid %d running %t\n", d.Bridge, d.PID, d.Running)
+		}
+	}
+
+	fmt.Printf("Problems:\n")
+	if len(n.Problems) == 0 {
+		fmt.Printf("\tNone\n")
+	}
+	for _, p := range n.Problems {
+		fmt.Printf("\t%s: %s", p.Alias, p.Problem)
+		if p.Repaired {
+			fmt.Printf(" (repaired)")
+		}
+		fmt.Printf("\n")
+	}
+}
@@ -0,0 +1,454 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ciao-project/ciao/ciao-controller/api"
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/intel/tfortools"
+)
+
+var securityGroupCommand = &command{
+	SubCommands: map[string]subCommand{
+		"create":      new(securityGroupCreateCommand),
+		"list":        new(securityGroupListCommand),
+		"show":        new(securityGroupShowCommand),
+		"delete":      new(securityGroupDeleteCommand),
+		"add-rule":    new(securityGroupAddRuleCommand),
+		"delete-rule": new(securityGroupDeleteRuleCommand),
+	},
+}
+
+// Security groups are tenant resources, so we always address them through
+// the tenant, even for admin users.
+func getCiaoSecurityGroupsURL() string {
+	return buildCiaoURL("%s/security-groups", *tenantID)
+}
+
+type securityGroupCreateCommand struct {
+	Flag        flag.FlagSet
+	name        string
+	description string
+}
+
+func (cmd *securityGroupCreateCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] security-group create [flags]
+
+Creates a new security group.  A new group only allows egress traffic, rules
+allowing ingress traffic are added with security-group add-rule.
+
+Security groups are enforced by the CNCI of the tenant, so they only filter
+traffic routed through it, not traffic between instances of the same subnet.
+
+The create flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *securityGroupCreateCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.name, "name", "", "Name of the security group")
+	cmd.Flag.StringVar(&cmd.description, "description", "", "Description of the security group")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *securityGroupCreateCommand) run(args []string) error {
+	var group types.SecurityGroup
+
+	if cmd.name == "" {
+		errorf("Missing required -name parameter")
+		cmd.usage()
+	}
+
+	req := types.SecurityGroupRequest{
+		Name:        cmd.name,
+		Description: cmd.description,
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+
+	resp, err := sendCiaoRequest("POST", getCiaoSecurityGroupsURL(), nil, body, api.SecurityGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		fatalf("Security group creation failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &group)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	fmt.Printf("Created new security group: %s\n", group.ID)
+
+	return nil
+}
+
+type securityGroupListCommand struct {
+	Flag     flag.FlagSet
+	template string
+}
+
+func (cmd *securityGroupListCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] security-group list [flags]
+
+List all security groups of the tenant.
+
+The list flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s",
+		tfortools.GenerateUsageDecorated("f", types.ListSecurityGroupsResponse{}.SecurityGroups, nil))
+	os.Exit(2)
+}
+
+func (cmd *securityGroupListCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *securityGroupListCommand) run(args []string) error {
+	var groups types.ListSecurityGroupsResponse
+
+	resp, err := sendCiaoRequest("GET", getCiaoSecurityGroupsURL(), nil, nil, api.SecurityGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Security group list failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &groups)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "security-group-list", cmd.template,
+			&groups.SecurityGroups, nil)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 1, 1, ' ', 0)
+	fmt.Fprintf(w, "#\tUUID\tName\tRules\tMembers\n")
+
+	for i, group := range groups.SecurityGroups {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\n", i+1, group.ID, group.Name,
+			len(group.Rules), len(group.Members))
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+type securityGroupShowCommand struct {
+	Flag     flag.FlagSet
+	group    string
+	template string
+}
+
+func (cmd *securityGroupShowCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] security-group show [flags]
+
+Show security group details.
+
+The show flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s", tfortools.GenerateUsageDecorated("f", types.SecurityGroup{}, nil))
+	os.Exit(2)
+}
+
+func (cmd *securityGroupShowCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.group, "group", "", "Security group UUID")
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func dumpSecurityGroupRule(rule types.SecurityGroupRule) {
+	protocol := rule.Protocol
+	if protocol == "" {
+		protocol = "any"
+	}
+
+	ports := "any"
+	if rule.PortRangeMin != 0 {
+		ports = fmt.Sprintf("%d-%d", rule.PortRangeMin, rule.PortRangeMax)
+	}
+
+	remote := rule.RemoteIPPrefix
+	if rule.RemoteGroupID != "" {
+		remote = "group " + rule.RemoteGroupID
+	} else if remote == "" {
+		remote = "any"
+	}
+
+	fmt.Printf("\t\t%s %s %s ports %s remote %s\n", rule.ID, rule.Direction,
+		protocol, ports, remote)
+}
+
+func (cmd *securityGroupShowCommand) run(args []string) error {
+	var group types.SecurityGroup
+
+	if cmd.group == "" {
+		errorf("Missing required -group parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoSecurityGroupsURL(), cmd.group)
+
+	resp, err := sendCiaoRequest("GET", url, nil, nil, api.SecurityGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Security group show failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &group)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "security-group-show", cmd.template,
+			&group, nil)
+	}
+
+	fmt.Printf("\tUUID: %s\n", group.ID)
+	fmt.Printf("\tName: %s\n", group.Name)
+	fmt.Printf("\tDescription: %s\n", group.Description)
+	fmt.Printf("\tMembers: %s\n", strings.Join(group.Members, ", "))
+	fmt.Printf("\tRules:\n")
+	for _, rule := range group.Rules {
+		dumpSecurityGroupRule(rule)
+	}
+
+	return nil
+}
+
+type securityGroupDeleteCommand struct {
+	Flag  flag.FlagSet
+	group string
+}
+
+func (cmd *securityGroupDeleteCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] security-group delete [flags]
+
+Deletes a security group.  The group must not have any members nor be the
+remote group of a rule of another group.
+
+The delete flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *securityGroupDeleteCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.group, "group", "", "Security group UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *securityGroupDeleteCommand) run(args []string) error {
+	if cmd.group == "" {
+		errorf("Missing required -group parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoSecurityGroupsURL(), cmd.group)
+
+	resp, err := sendCiaoRequest("DELETE", url, nil, nil, api.SecurityGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Security group deletion failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Deleted security group: %s\n", cmd.group)
+
+	return nil
+}
+
+type securityGroupAddRuleCommand struct {
+	Flag           flag.FlagSet
+	group          string
+	direction      string
+	protocol       string
+	portMin        int
+	portMax        int
+	remoteIPPrefix string
+	remoteGroup    string
+}
+
+func (cmd *securityGroupAddRuleCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] security-group add-rule [flags]
+
+Adds a rule allowing traffic to or from the members of a security group.
+Traffic is allowed from, or to, anywhere unless -remote-ip-prefix or
+-remote-group are given.
+
+The add-rule flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *securityGroupAddRuleCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.group, "group", "", "Security group UUID")
+	cmd.Flag.StringVar(&cmd.direction, "direction", string(payloads.Ingress), "Direction of the traffic: ingress or egress")
+	cmd.Flag.StringVar(&cmd.protocol, "protocol", "", "Protocol of the traffic: tcp, udp or icmp, any if empty")
+	cmd.Flag.IntVar(&cmd.portMin, "port-min", 0, "First port of the allowed tcp or udp port range")
+	cmd.Flag.IntVar(&cmd.portMax, "port-max", 0, "Last port of the allowed tcp or udp port range, port-min if 0")
+	cmd.Flag.StringVar(&cmd.remoteIPPrefix, "remote-ip-prefix", "", "CIDR of the remote addresses")
+	cmd.Flag.StringVar(&cmd.remoteGroup, "remote-group", "", "UUID of the security group of the remote instances")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *securityGroupAddRuleCommand) run(args []string) error {
+	var rule types.SecurityGroupRule
+
+	if cmd.group == "" {
+		errorf("Missing required -group parameter")
+		cmd.usage()
+	}
+
+	req := types.SecurityGroupRule{
+		Direction:      payloads.SecurityGroupDirection(cmd.direction),
+		Protocol:       cmd.protocol,
+		PortRangeMin:   cmd.portMin,
+		PortRangeMax:   cmd.portMax,
+		RemoteIPPrefix: cmd.remoteIPPrefix,
+		RemoteGroupID:  cmd.remoteGroup,
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+
+	url := fmt.Sprintf("%s/%s/rules", getCiaoSecurityGroupsURL(), cmd.group)
+
+	resp, err := sendCiaoRequest("POST", url, nil, body, api.SecurityGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		fatalf("Security group rule creation failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &rule)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	fmt.Printf("Created new security group rule: %s\n", rule.ID)
+
+	return nil
+}
+
+type securityGroupDeleteRuleCommand struct {
+	Flag  flag.FlagSet
+	group string
+	rule  string
+}
+
+func (cmd *securityGroupDeleteRuleCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] security-group delete-rule [flags]
+
+Deletes a rule of a security group.
+
+The delete-rule flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *securityGroupDeleteRuleCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.group, "group", "", "Security group UUID")
+	cmd.Flag.StringVar(&cmd.rule, "rule", "", "Security group rule UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *securityGroupDeleteRuleCommand) run(args []string) error {
+	if cmd.group == "" {
+		errorf("Missing required -group parameter")
+		cmd.usage()
+	}
+
+	if cmd.rule == "" {
+		errorf("Missing required -rule parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s/rules/%s", getCiaoSecurityGroupsURL(), cmd.group, cmd.rule)
+
+	resp, err := sendCiaoRequest("DELETE", url, nil, nil, api.SecurityGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Security group rule deletion failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Deleted security group rule: %s\n", cmd.rule)
+
+	return nil
+}
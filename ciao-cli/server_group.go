@@ -0,0 +1,287 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ciao-project/ciao/ciao-controller/api"
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/intel/tfortools"
+)
+
+var serverGroupCommand = &command{
+	SubCommands: map[string]subCommand{
+		"create": new(serverGroupCreateCommand),
+		"list":   new(serverGroupListCommand),
+		"show":   new(serverGroupShowCommand),
+		"delete": new(serverGroupDeleteCommand),
+	},
+}
+
+// Server groups are tenant resources, so we always address them through
+// the tenant, even for admin users.
+func getCiaoServerGroupsURL() string {
+	return buildCiaoURL("%s/server-groups", *tenantID)
+}
+
+type serverGroupCreateCommand struct {
+	Flag   flag.FlagSet
+	name   string
+	policy string
+}
+
+func (cmd *serverGroupCreateCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] server-group create [flags]
+
+Creates a new server group.  Instances launched into an affinity group are
+placed on the same node, instances launched into an anti-affinity group on
+different nodes.
+
+The create flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *serverGroupCreateCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.name, "name", "", "Name of the server group")
+	cmd.Flag.StringVar(&cmd.policy, "policy", "", "Placement policy: affinity or anti-affinity")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *serverGroupCreateCommand) run(args []string) error {
+	var group types.ServerGroup
+
+	if cmd.policy == "" {
+		errorf("Missing required -policy parameter")
+		cmd.usage()
+	}
+
+	req := types.ServerGroupRequest{
+		Name:   cmd.name,
+		Policy: payloads.ServerGroupPolicy(cmd.policy),
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+
+	resp, err := sendCiaoRequest("POST", getCiaoServerGroupsURL(), nil, body, api.ServerGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		fatalf("Server group creation failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &group)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	fmt.Printf("Created new server group: %s\n", group.ID)
+
+	return nil
+}
+
+type serverGroupListCommand struct {
+	Flag     flag.FlagSet
+	template string
+}
+
+func (cmd *serverGroupListCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] server-group list [flags]
+
+List all server groups of the tenant.
+
+The list flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s",
+		tfortools.GenerateUsageDecorated("f", types.ListServerGroupsResponse{}.ServerGroups, nil))
+	os.Exit(2)
+}
+
+func (cmd *serverGroupListCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *serverGroupListCommand) run(args []string) error {
+	var groups types.ListServerGroupsResponse
+
+	resp, err := sendCiaoRequest("GET", getCiaoServerGroupsURL(), nil, nil, api.ServerGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Server group list failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &groups)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "server-group-list", cmd.template,
+			&groups.ServerGroups, nil)
+	}
+
+	w := new(tabwriter.Writer)
+	w.Init(os.Stdout, 0, 1, 1, ' ', 0)
+	fmt.Fprintf(w, "#\tUUID\tName\tPolicy\tMembers\n")
+
+	for i, group := range groups.ServerGroups {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\n", i+1, group.ID, group.Name,
+			group.Policy, len(group.Members))
+	}
+
+	w.Flush()
+
+	return nil
+}
+
+type serverGroupShowCommand struct {
+	Flag     flag.FlagSet
+	group    string
+	template string
+}
+
+func (cmd *serverGroupShowCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] server-group show [flags]
+
+Show server group details.
+
+The show flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, "\n%s", tfortools.GenerateUsageDecorated("f", types.ServerGroup{}, nil))
+	os.Exit(2)
+}
+
+func (cmd *serverGroupShowCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.group, "group", "", "Server group UUID")
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *serverGroupShowCommand) run(args []string) error {
+	var group types.ServerGroup
+
+	if cmd.group == "" {
+		errorf("Missing required -group parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoServerGroupsURL(), cmd.group)
+
+	resp, err := sendCiaoRequest("GET", url, nil, nil, api.ServerGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Server group show failed: %s", resp.Status)
+	}
+
+	err = unmarshalHTTPResponse(resp, &group)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if cmd.template != "" {
+		return tfortools.OutputToTemplate(os.Stdout, "server-group-show", cmd.template,
+			&group, nil)
+	}
+
+	fmt.Printf("\tUUID: %s\n", group.ID)
+	fmt.Printf("\tName: %s\n", group.Name)
+	fmt.Printf("\tPolicy: %s\n", group.Policy)
+	fmt.Printf("\tMembers: %s\n", strings.Join(group.Members, ", "))
+
+	return nil
+}
+
+type serverGroupDeleteCommand struct {
+	Flag  flag.FlagSet
+	group string
+}
+
+func (cmd *serverGroupDeleteCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] server-group delete [flags]
+
+Deletes a server group.  The group must not have any members.
+
+The delete flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *serverGroupDeleteCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.group, "group", "", "Server group UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *serverGroupDeleteCommand) run(args []string) error {
+	if cmd.group == "" {
+		errorf("Missing required -group parameter")
+		cmd.usage()
+	}
+
+	url := fmt.Sprintf("%s/%s", getCiaoServerGroupsURL(), cmd.group)
+
+	resp, err := sendCiaoRequest("DELETE", url, nil, nil, api.ServerGroupsV1)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		fatalf("Server group deletion failed: %s", resp.Status)
+	}
+
+	fmt.Printf("Deleted server group: %s\n", cmd.group)
+
+	return nil
+}
@@ -33,12 +33,22 @@ import (
 
 var volumeCommand = &command{
 	SubCommands: map[string]subCommand{
-		"add":    new(volumeAddCommand),
-		"list":   new(volumeListCommand),
-		"show":   new(volumeShowCommand),
-		"delete": new(volumeDeleteCommand),
-		"attach": new(volumeAttachCommand),
-		"detach": new(volumeDetachCommand),
+		"add":      new(volumeAddCommand),
+		"list":     new(volumeListCommand),
+		"show":     new(volumeShowCommand),
+		"delete":   new(volumeDeleteCommand),
+		"attach":   new(volumeAttachCommand),
+		"detach":   new(volumeDetachCommand),
+		"snapshot": volumeSnapshotCommand,
+	},
+}
+
+var volumeSnapshotCommand = &snapshotCommand{
+	SubCommands: map[string]subCommand{
+		"create":  new(snapshotCreateCommand),
+		"list":    new(snapshotListCommand),
+		"delete":  new(snapshotDeleteCommand),
+		"restore": new(snapshotRestoreCommand),
 	},
 }
 
@@ -49,6 +59,7 @@ type volumeAddCommand struct {
 	name        string
 	sourceType  string
 	source      string
+	volumeType  string
 }
 
 func (cmd *volumeAddCommand) usage(...string) {
@@ -65,10 +76,11 @@ The add flags are:
 
 func (cmd *volumeAddCommand) parseArgs(args []string) []string {
 	cmd.Flag.StringVar(&cmd.name, "name", "", "Volume name")
-	cmd.Flag.StringVar(&cmd.sourceType, "source_type", "image", "The type of the source to clone from")
-	cmd.Flag.StringVar(&cmd.source, "source", "", "ID of image or volume to clone from")
+	cmd.Flag.StringVar(&cmd.sourceType, "source_type", "image", "The type of the source to clone from, image, volume or snapshot")
+	cmd.Flag.StringVar(&cmd.source, "source", "", "ID of image, volume or snapshot to clone from")
 	cmd.Flag.IntVar(&cmd.size, "size", 1, "Size of the volume in GB")
 	cmd.Flag.StringVar(&cmd.description, "description", "", "Volume description")
+	cmd.Flag.StringVar(&cmd.volumeType, "type", "", "Volume type, the default volume type if empty")
 	cmd.Flag.Usage = func() { cmd.usage() }
 	cmd.Flag.Parse(args)
 	return cmd.Flag.Args()
@@ -79,12 +91,15 @@ func (cmd *volumeAddCommand) run(args []string) error {
 		Description: cmd.description,
 		Name:        cmd.name,
 		Size:        cmd.size,
+		VolumeType:  cmd.volumeType,
 	}
 
 	if cmd.sourceType == "image" {
 		opts.ImageRef = cmd.source
 	} else if cmd.sourceType == "volume" {
 		opts.SourceVolID = cmd.source
+	} else if cmd.sourceType == "snapshot" {
+		opts.SnapshotID = cmd.source
 	} else {
 		fatalf("Unknown source type [%s]\n", cmd.sourceType)
 	}
@@ -456,4 +471,330 @@ func dumpVolume(v *block.VolumeDetail) {
 	fmt.Printf("\tTenantID         [%s]\n", v.OSVolTenantAttr)
 	fmt.Printf("\tStatus           [%s]\n", v.Status)
 	fmt.Printf("\tDescription      [%s]\n", v.Description)
+	fmt.Printf("\tType             [%s]\n", v.VolumeType)
+}
+
+// snapshotCommand dispatches the actions of the volume snapshot sub-command.
+type snapshotCommand struct {
+	SubCommands map[string]subCommand
+	action      subCommand
+}
+
+func (cmd *snapshotCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] volume snapshot sub-command [flags]
+
+Manage the snapshots of volumes
+`)
+	var t = template.Must(template.New("commandTemplate").Parse(commandTemplate))
+	_ = t.Execute(os.Stderr, cmd)
+	fmt.Fprintf(os.Stderr, `
+Use "ciao-cli volume snapshot sub-command -help" for more information about that item.
+`)
+	os.Exit(2)
+}
+
+func (cmd *snapshotCommand) parseArgs(args []string) []string {
+	if len(args) == 0 {
+		cmd.usage()
+	}
+
+	cmd.action = cmd.SubCommands[args[0]]
+	if cmd.action == nil {
+		cmd.usage()
+	}
+
+	return cmd.action.parseArgs(args[1:])
+}
+
+func (cmd *snapshotCommand) run(args []string) error {
+	return cmd.action.run(args)
+}
+
+type snapshotCreateCommand struct {
+	Flag        flag.FlagSet
+	volume      string
+	name        string
+	description string
+	force       bool
+}
+
+func (cmd *snapshotCreateCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] volume snapshot create [flags]
+
+Create a snapshot of a volume
+
+The create flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *snapshotCreateCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.volume, "volume", "", "Volume UUID")
+	cmd.Flag.StringVar(&cmd.name, "name", "", "Snapshot name")
+	cmd.Flag.StringVar(&cmd.description, "description", "", "Snapshot description")
+	cmd.Flag.BoolVar(&cmd.force, "force", false, "Snapshot the volume even if it is attached to an instance")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *snapshotCreateCommand) run(args []string) error {
+	if cmd.volume == "" {
+		errorf("missing required -volume parameter")
+		cmd.usage()
+	}
+
+	createReq := block.SnapshotCreateRequest{
+		Snapshot: block.RequestedSnapshot{
+			VolumeID:    cmd.volume,
+			Force:       cmd.force,
+			Name:        cmd.name,
+			Description: cmd.description,
+		},
+	}
+
+	b, err := json.Marshal(createReq)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+	url := buildBlockURL("%s/snapshots", *tenantID)
+	resp, err := sendHTTPRequest("POST", url, nil, body)
+	if err != nil {
+		fatalf(err.Error())
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusAccepted {
+		fatalf("Snapshot creation failed: %s", resp.Status)
+	}
+
+	var snap block.SnapshotResponse
+
+	err = unmarshalHTTPResponse(resp, &snap)
+	if err != nil {
+		fatalf(err.Error())
+	}
+	fmt.Printf("Created new snapshot: %s\n", snap.Snapshot.ID)
+
+	return err
+}
+
+type snapshotListCommand struct {
+	Flag     flag.FlagSet
+	volume   string
+	template string
+}
+
+func (cmd *snapshotListCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] volume snapshot list [flags]
+
+List the snapshots of volumes
+
+The list flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	fmt.Fprintf(os.Stderr, `
+The template passed to the -f option operates on a
+
+%s`, tfortools.GenerateUsageUndecorated([]block.Snapshot{}))
+	fmt.Fprintln(os.Stderr, tfortools.TemplateFunctionHelp(nil))
+	os.Exit(2)
+}
+
+func (cmd *snapshotListCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.volume, "volume", "", "Only list the snapshots of this volume")
+	cmd.Flag.StringVar(&cmd.template, "f", "", "Template used to format output")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+type snapshotsByName []block.Snapshot
+
+func (ss snapshotsByName) Len() int      { return len(ss) }
+func (ss snapshotsByName) Swap(i, j int) { ss[i], ss[j] = ss[j], ss[i] }
+func (ss snapshotsByName) Less(i, j int) bool {
+	return ss[i].Name < ss[j].Name
+}
+
+func (cmd *snapshotListCommand) run(args []string) error {
+	var t *template.Template
+	var err error
+	if cmd.template != "" {
+		t, err = tfortools.CreateTemplate("snapshot-list", cmd.template, nil)
+		if err != nil {
+			fatalf(err.Error())
+		}
+	}
+
+	url := buildBlockURL("%s/snapshots/detail", *tenantID)
+	resp, err := sendHTTPRequest("GET", url, nil, nil)
+	if err != nil {
+		fatalf(err.Error())
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Snapshot list failed: %s", resp.Status)
+	}
+
+	var snaps block.ListSnapshots
+
+	err = unmarshalHTTPResponse(resp, &snaps)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	var sortedSnapshots []block.Snapshot
+	for _, s := range snaps.Snapshots {
+		if cmd.volume == "" || s.VolumeID == cmd.volume {
+			sortedSnapshots = append(sortedSnapshots, s)
+		}
+	}
+	sort.Sort(snapshotsByName(sortedSnapshots))
+
+	if t != nil {
+		if err = t.Execute(os.Stdout, &sortedSnapshots); err != nil {
+			fatalf(err.Error())
+		}
+		return nil
+	}
+
+	for i, s := range sortedSnapshots {
+		fmt.Printf("Snapshot #%d\n", i+1)
+		dumpSnapshot(&s)
+		fmt.Printf("\n")
+	}
+
+	return err
+}
+
+type snapshotDeleteCommand struct {
+	Flag     flag.FlagSet
+	snapshot string
+}
+
+func (cmd *snapshotDeleteCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] volume snapshot delete [flags]
+
+Deletes a snapshot
+
+The delete flags are:
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *snapshotDeleteCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.snapshot, "snapshot", "", "Snapshot UUID")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *snapshotDeleteCommand) run(args []string) error {
+	if cmd.snapshot == "" {
+		errorf("missing required -snapshot parameter")
+		cmd.usage()
+	}
+
+	url := buildBlockURL("%s/snapshots/%s", *tenantID, cmd.snapshot)
+	resp, err := sendHTTPRequest("DELETE", url, nil, nil)
+	if err != nil {
+		fatalf(err.Error())
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusAccepted {
+		fatalf("Snapshot delete failed: %s", resp.Status)
+	}
+
+	return err
+}
+
+type snapshotRestoreCommand struct {
+	Flag        flag.FlagSet
+	snapshot    string
+	size        int
+	name        string
+	description string
+}
+
+func (cmd *snapshotRestoreCommand) usage(...string) {
+	fmt.Fprintf(os.Stderr, `usage: ciao-cli [options] volume snapshot restore [flags]
+
+Restore a snapshot to a new volume
+
+The restore flags are:
+
+`)
+	cmd.Flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func (cmd *snapshotRestoreCommand) parseArgs(args []string) []string {
+	cmd.Flag.StringVar(&cmd.snapshot, "snapshot", "", "Snapshot UUID")
+	cmd.Flag.IntVar(&cmd.size, "size", 0, "Size of the volume in GB, defaults to the size of the snapshot")
+	cmd.Flag.StringVar(&cmd.name, "name", "", "Volume name")
+	cmd.Flag.StringVar(&cmd.description, "description", "", "Volume description")
+	cmd.Flag.Usage = func() { cmd.usage() }
+	cmd.Flag.Parse(args)
+	return cmd.Flag.Args()
+}
+
+func (cmd *snapshotRestoreCommand) run(args []string) error {
+	if cmd.snapshot == "" {
+		errorf("missing required -snapshot parameter")
+		cmd.usage()
+	}
+
+	createReq := block.VolumeCreateRequest{
+		Volume: block.RequestedVolume{
+			SnapshotID:  cmd.snapshot,
+			Size:        cmd.size,
+			Name:        cmd.name,
+			Description: cmd.description,
+		},
+	}
+
+	b, err := json.Marshal(createReq)
+	if err != nil {
+		fatalf(err.Error())
+	}
+
+	body := bytes.NewReader(b)
+	url := buildBlockURL("%s/volumes", *tenantID)
+	resp, err := sendHTTPRequest("POST", url, nil, body)
+	if err != nil {
+		fatalf(err.Error())
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusAccepted {
+		fatalf("Snapshot restore failed: %s", resp.Status)
+	}
+
+	var vol block.VolumeResponse
+
+	err = unmarshalHTTPResponse(resp, &vol)
+	if err != nil {
+		fatalf(err.Error())
+	}
+	fmt.Printf("Restored snapshot %s to new volume: %s\n", cmd.snapshot, vol.Volume.ID)
+
+	return err
+}
+
+func dumpSnapshot(s *block.Snapshot) {
+	fmt.Printf("\tName             [%s]\n", s.Name)
+	fmt.Printf("\tSize             [%d GB]\n", s.Size)
+	fmt.Printf("\tUUID             [%s]\n", s.ID)
+	fmt.Printf("\tVolume           [%s]\n", s.VolumeID)
+	fmt.Printf("\tStatus           [%s]\n", s.Status)
+	fmt.Printf("\tDescription      [%s]\n", s.Description)
 }
@@ -172,20 +172,23 @@ type disk struct {
 }
 
 type defaultResources struct {
-	VCPUs int `yaml:"vcpus"`
-	MemMB int `yaml:"mem_mb"`
+	VCPUs       int `yaml:"vcpus"`
+	MemMB       int `yaml:"mem_mb"`
+	IngressKbps int `yaml:"network_ingress_kbps,omitempty"`
+	EgressKbps  int `yaml:"network_egress_kbps,omitempty"`
 }
 
 // we currently only use the first disk due to lack of support
 // in types.Workload for multiple storage resources.
 type workloadOptions struct {
-	Description     string           `yaml:"description"`
-	VMType          string           `yaml:"vm_type"`
-	FWType          string           `yaml:"fw_type,omitempty"`
-	ImageName       string           `yaml:"image_name,omitempty"`
-	Defaults        defaultResources `yaml:"defaults"`
-	CloudConfigFile string           `yaml:"cloud_init,omitempty"`
-	Disks           []disk           `yaml:"disks,omitempty"`
+	Description     string            `yaml:"description"`
+	VMType          string            `yaml:"vm_type"`
+	FWType          string            `yaml:"fw_type,omitempty"`
+	ImageName       string            `yaml:"image_name,omitempty"`
+	Defaults        defaultResources  `yaml:"defaults"`
+	CloudConfigFile string            `yaml:"cloud_init,omitempty"`
+	Disks           []disk            `yaml:"disks,omitempty"`
+	NodeSelector    map[string]string `yaml:"node_selector,omitempty"`
 }
 
 func optToReqStorage(opt workloadOptions) ([]types.StorageResource, error) {
@@ -256,6 +259,7 @@ func optToReq(opt workloadOptions, req *types.Workload) error {
 	req.FWType = opt.FWType
 	req.ImageName = opt.ImageName
 	req.Config = config
+	req.NodeSelector = opt.NodeSelector
 	req.Storage, err = optToReqStorage(opt)
 
 	if err != nil {
@@ -277,6 +281,23 @@ func optToReq(opt workloadOptions, req *types.Workload) error {
 	}
 	req.Defaults = append(req.Defaults, r)
 
+	// bandwidth limits are optional
+	if defaults.IngressKbps != 0 {
+		r = payloads.RequestedResource{
+			Type:  payloads.NetworkIngressKbps,
+			Value: defaults.IngressKbps,
+		}
+		req.Defaults = append(req.Defaults, r)
+	}
+
+	if defaults.EgressKbps != 0 {
+		r = payloads.RequestedResource{
+			Type:  payloads.NetworkEgressKbps,
+			Value: defaults.EgressKbps,
+		}
+		req.Defaults = append(req.Defaults, r)
+	}
+
 	return nil
 }
 
@@ -287,11 +308,16 @@ func outputWorkload(w types.Workload) {
 	opt.VMType = string(w.VMType)
 	opt.FWType = w.FWType
 	opt.ImageName = w.ImageName
+	opt.NodeSelector = w.NodeSelector
 	for _, d := range w.Defaults {
 		if d.Type == payloads.VCPUs {
 			opt.Defaults.VCPUs = d.Value
 		} else if d.Type == payloads.MemMB {
 			opt.Defaults.MemMB = d.Value
+		} else if d.Type == payloads.NetworkIngressKbps {
+			opt.Defaults.IngressKbps = d.Value
+		} else if d.Type == payloads.NetworkEgressKbps {
+			opt.Defaults.EgressKbps = d.Value
 		}
 	}
 
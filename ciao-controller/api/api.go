@@ -44,6 +44,18 @@ const (
 
 	// NodeV1 is the content-type string for v1 of our node resource
 	NodeV1 = "x.ciao.node.v1"
+
+	// ServerGroupsV1 is the content-type string for v1 of our server-groups resource
+	ServerGroupsV1 = "x.ciao.server-groups.v1"
+
+	// SecurityGroupsV1 is the content-type string for v1 of our security-groups resource
+	SecurityGroupsV1 = "x.ciao.security-groups.v1"
+
+	// NetworksV1 is the content-type string for v1 of our networks resource
+	NetworksV1 = "x.ciao.networks.v1"
+
+	// LoadBalancersV1 is the content-type string for v1 of our load-balancers resource
+	LoadBalancersV1 = "x.ciao.load-balancers.v1"
 )
 
 // HTTPErrorData represents the HTTP response body for
@@ -74,7 +86,15 @@ func errorResponse(err error) Response {
 		types.ErrTenantNotFound,
 		types.ErrAddressNotFound,
 		types.ErrInstanceNotFound,
-		types.ErrWorkloadNotFound:
+		types.ErrWorkloadNotFound,
+		types.ErrServerGroupNotFound,
+		types.ErrSecurityGroupNotFound,
+		types.ErrSecurityGroupRuleNotFound,
+		types.ErrTenantNetworkNotFound,
+		types.ErrLoadBalancerNotFound,
+		types.ErrPortForwardNotFound,
+		types.ErrNodeNotFound,
+		types.ErrNodeNetworkNotFound:
 		return Response{http.StatusNotFound, nil}
 
 	case types.ErrQuota,
@@ -82,14 +102,29 @@ func errorResponse(err error) Response {
 		types.ErrDuplicateSubnet,
 		types.ErrDuplicateIP,
 		types.ErrInvalidIP,
+		types.ErrSubnetTooSmall,
+		types.ErrSubnetTooLarge,
 		types.ErrPoolNotEmpty,
 		types.ErrInvalidPoolAddress,
 		types.ErrBadRequest,
 		types.ErrPoolEmpty,
 		types.ErrDuplicatePoolName,
-		types.ErrWorkloadInUse:
+		types.ErrWorkloadInUse,
+		types.ErrServerGroupInUse,
+		types.ErrSecurityGroupInUse,
+		types.ErrDuplicateSecurityGroupName,
+		types.ErrTenantNetworkInUse,
+		types.ErrDuplicateTenantNetworkName,
+		types.ErrTenantSubnetInUse,
+		types.ErrDuplicateLoadBalancerName,
+		types.ErrLoadBalancerMemberSubnet,
+		types.ErrDuplicatePortForward,
+		types.ErrPortForwardSubnet:
 		return Response{http.StatusForbidden, nil}
 
+	case types.ErrNodeNetworkTimeout:
+		return Response{http.StatusGatewayTimeout, nil}
+
 	default:
 		return Response{http.StatusInternalServerError, nil}
 	}
@@ -229,6 +264,58 @@ func listResources(c *Context, w http.ResponseWriter, r *http.Request) (Response
 		links = append(links, link)
 	}
 
+	// for the "server-groups" resource
+
+	if ok {
+		link = types.APILink{
+			Rel:        "server-groups",
+			Version:    ServerGroupsV1,
+			MinVersion: ServerGroupsV1,
+		}
+
+		link.Href = fmt.Sprintf("%s/%s/server-groups", c.URL, tenantID)
+		links = append(links, link)
+	}
+
+	// for the "security-groups" resource
+
+	if ok {
+		link = types.APILink{
+			Rel:        "security-groups",
+			Version:    SecurityGroupsV1,
+			MinVersion: SecurityGroupsV1,
+		}
+
+		link.Href = fmt.Sprintf("%s/%s/security-groups", c.URL, tenantID)
+		links = append(links, link)
+	}
+
+	// for the "networks" resource
+
+	if ok {
+		link = types.APILink{
+			Rel:        "networks",
+			Version:    NetworksV1,
+			MinVersion: NetworksV1,
+		}
+
+		link.Href = fmt.Sprintf("%s/%s/networks", c.URL, tenantID)
+		links = append(links, link)
+	}
+
+	// for the "load-balancers" resource
+
+	if ok {
+		link = types.APILink{
+			Rel:        "load-balancers",
+			Version:    LoadBalancersV1,
+			MinVersion: LoadBalancersV1,
+		}
+
+		link.Href = fmt.Sprintf("%s/%s/load-balancers", c.URL, tenantID)
+		links = append(links, link)
+	}
+
 	return Response{http.StatusOK, links}, nil
 }
 
@@ -465,6 +552,71 @@ func unmapExternalIP(c *Context, w http.ResponseWriter, r *http.Request) (Respon
 	return errorResponse(types.ErrAddressNotFound), types.ErrAddressNotFound
 }
 
+func createPortForward(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.PortForwardRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	pf, err := c.CreatePortForward(tenantID, req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusCreated, pf}, nil
+}
+
+func listPortForwards(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	pfs, err := c.ListPortForwards(tenantID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := types.ListPortForwardsResponse{
+		PortForwards: pfs,
+	}
+
+	return Response{http.StatusOK, resp}, nil
+}
+
+func showPortForward(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["forward_id"]
+
+	pf, err := c.ShowPortForward(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusOK, pf}, nil
+}
+
+func deletePortForward(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["forward_id"]
+
+	err := c.DeletePortForward(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
 func addWorkload(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
 	var req types.Workload
 
@@ -622,13 +774,26 @@ func changeNodeStatus(c *Context, w http.ResponseWriter, r *http.Request) (Respo
 		return errorResponse(err), err
 	}
 
+	if status.Status != types.NodeStatusReady &&
+		status.Status != types.NodeStatusMaintenance &&
+		(status.Status != "" || status.Labels == nil) {
+		err = fmt.Errorf("Cannot transition node %s to %s",
+			ID, status.Status)
+		return errorResponse(err), err
+	}
+
+	// labels may be changed along with the status
+	if status.Labels != nil {
+		err = c.LabelNode(ID, status.Labels)
+		if err != nil {
+			return errorResponse(err), err
+		}
+	}
+
 	if status.Status == types.NodeStatusReady {
 		err = c.RestoreNode(ID)
 	} else if status.Status == types.NodeStatusMaintenance {
 		err = c.EvacuateNode(ID)
-	} else {
-		err = fmt.Errorf("Cannot transition node %s to %s",
-			ID, status.Status)
 	}
 
 	if err != nil {
@@ -638,6 +803,41 @@ func changeNodeStatus(c *Context, w http.ResponseWriter, r *http.Request) (Respo
 	return Response{http.StatusNoContent, nil}, nil
 }
 
+func showNodeNetwork(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	ID := vars["node_id"]
+
+	n, err := c.ShowNodeNetwork(ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusOK, n}, nil
+}
+
+func inspectNodeNetwork(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	ID := vars["node_id"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.NodeNetworkInspectRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	n, err := c.InspectNodeNetwork(ID, req.Repair)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusOK, n}, nil
+}
+
 func listTenants(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
 	var resp types.TenantsListResponse
 
@@ -726,6 +926,342 @@ func deleteTenant(c *Context, w http.ResponseWriter, r *http.Request) (Response,
 	return Response{http.StatusNoContent, nil}, nil
 }
 
+func createServerGroup(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.ServerGroupRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	group, err := c.CreateServerGroup(tenantID, req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusCreated, group}, nil
+}
+
+func listServerGroups(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	groups, err := c.ListServerGroups(tenantID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := types.ListServerGroupsResponse{
+		ServerGroups: groups,
+	}
+
+	return Response{http.StatusOK, resp}, nil
+}
+
+func showServerGroup(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["group_id"]
+
+	group, err := c.ShowServerGroup(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusOK, group}, nil
+}
+
+func deleteServerGroup(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["group_id"]
+
+	err := c.DeleteServerGroup(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
+func createSecurityGroup(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.SecurityGroupRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	group, err := c.CreateSecurityGroup(tenantID, req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusCreated, group}, nil
+}
+
+func listSecurityGroups(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	groups, err := c.ListSecurityGroups(tenantID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := types.ListSecurityGroupsResponse{
+		SecurityGroups: groups,
+	}
+
+	return Response{http.StatusOK, resp}, nil
+}
+
+func showSecurityGroup(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["group_id"]
+
+	group, err := c.ShowSecurityGroup(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusOK, group}, nil
+}
+
+func deleteSecurityGroup(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["group_id"]
+
+	err := c.DeleteSecurityGroup(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
+func createSecurityGroupRule(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["group_id"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.SecurityGroupRule
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	rule, err := c.CreateSecurityGroupRule(tenantID, ID, req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusCreated, rule}, nil
+}
+
+func deleteSecurityGroupRule(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["group_id"]
+	ruleID := vars["rule_id"]
+
+	err := c.DeleteSecurityGroupRule(tenantID, ID, ruleID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
+func createTenantNetwork(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.TenantNetworkRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	network, err := c.CreateTenantNetwork(tenantID, req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusCreated, network}, nil
+}
+
+func listTenantNetworks(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	networks, err := c.ListTenantNetworks(tenantID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := types.ListTenantNetworksResponse{
+		Networks: networks,
+	}
+
+	return Response{http.StatusOK, resp}, nil
+}
+
+func showTenantNetwork(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["network_id"]
+
+	network, err := c.ShowTenantNetwork(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusOK, network}, nil
+}
+
+func deleteTenantNetwork(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["network_id"]
+
+	err := c.DeleteTenantNetwork(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
+func createLoadBalancer(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.LoadBalancerRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	lb, err := c.CreateLoadBalancer(tenantID, req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusCreated, lb}, nil
+}
+
+func listLoadBalancers(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+
+	lbs, err := c.ListLoadBalancers(tenantID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := types.ListLoadBalancersResponse{
+		LoadBalancers: lbs,
+	}
+
+	return Response{http.StatusOK, resp}, nil
+}
+
+func showLoadBalancer(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["lb_id"]
+
+	lb, err := c.ShowLoadBalancer(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusOK, lb}, nil
+}
+
+func deleteLoadBalancer(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["lb_id"]
+
+	err := c.DeleteLoadBalancer(tenantID, ID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
+func addLoadBalancerMember(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["lb_id"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	var req types.LoadBalancerMemberRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	err = c.AddLoadBalancerMember(tenantID, ID, req.InstanceID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
+func deleteLoadBalancerMember(c *Context, w http.ResponseWriter, r *http.Request) (Response, error) {
+	vars := mux.Vars(r)
+	tenantID := vars["tenant"]
+	ID := vars["lb_id"]
+	instanceID := vars["instance_id"]
+
+	err := c.DeleteLoadBalancerMember(tenantID, ID, instanceID)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return Response{http.StatusNoContent, nil}, nil
+}
+
 // Service is an interface which must be implemented by the ciao API context.
 type Service interface {
 	AddPool(name string, subnet *string, ips []string) (types.Pool, error)
@@ -737,6 +1273,10 @@ type Service interface {
 	ListMappedAddresses(tenantID *string) []types.MappedIP
 	MapAddress(tenantID string, poolName *string, instanceID string) error
 	UnMapAddress(ID string) error
+	CreatePortForward(tenantID string, req types.PortForwardRequest) (types.PortForward, error)
+	ListPortForwards(tenantID string) ([]types.PortForward, error)
+	ShowPortForward(tenantID string, forwardID string) (types.PortForward, error)
+	DeletePortForward(tenantID string, forwardID string) error
 	CreateWorkload(req types.Workload) (types.Workload, error)
 	DeleteWorkload(tenantID string, workloadID string) error
 	ShowWorkload(tenantID string, workloadID string) (types.Workload, error)
@@ -745,11 +1285,34 @@ type Service interface {
 	UpdateQuotas(tenantID string, qds []types.QuotaDetails) error
 	EvacuateNode(nodeID string) error
 	RestoreNode(nodeID string) error
+	LabelNode(nodeID string, labels map[string]string) error
+	InspectNodeNetwork(nodeID string, repair bool) (types.NodeNetwork, error)
+	ShowNodeNetwork(nodeID string) (types.NodeNetwork, error)
 	ListTenants() ([]types.TenantSummary, error)
 	ShowTenant(ID string) (types.TenantConfig, error)
 	PatchTenant(ID string, patch []byte) error
 	CreateTenant(ID string, config types.TenantConfig) (types.TenantSummary, error)
 	DeleteTenant(ID string) error
+	CreateServerGroup(tenantID string, req types.ServerGroupRequest) (types.ServerGroup, error)
+	ListServerGroups(tenantID string) ([]types.ServerGroup, error)
+	ShowServerGroup(tenantID string, groupID string) (types.ServerGroup, error)
+	DeleteServerGroup(tenantID string, groupID string) error
+	CreateSecurityGroup(tenantID string, req types.SecurityGroupRequest) (types.SecurityGroup, error)
+	ListSecurityGroups(tenantID string) ([]types.SecurityGroup, error)
+	ShowSecurityGroup(tenantID string, groupID string) (types.SecurityGroup, error)
+	DeleteSecurityGroup(tenantID string, groupID string) error
+	CreateSecurityGroupRule(tenantID string, groupID string, rule types.SecurityGroupRule) (types.SecurityGroupRule, error)
+	DeleteSecurityGroupRule(tenantID string, groupID string, ruleID string) error
+	CreateTenantNetwork(tenantID string, req types.TenantNetworkRequest) (types.TenantNetwork, error)
+	ListTenantNetworks(tenantID string) ([]types.TenantNetwork, error)
+	ShowTenantNetwork(tenantID string, networkID string) (types.TenantNetwork, error)
+	DeleteTenantNetwork(tenantID string, networkID string) error
+	CreateLoadBalancer(tenantID string, req types.LoadBalancerRequest) (types.LoadBalancer, error)
+	ListLoadBalancers(tenantID string) ([]types.LoadBalancer, error)
+	ShowLoadBalancer(tenantID string, lbID string) (types.LoadBalancer, error)
+	DeleteLoadBalancer(tenantID string, lbID string) error
+	AddLoadBalancerMember(tenantID string, lbID string, instanceID string) error
+	DeleteLoadBalancerMember(tenantID string, lbID string, instanceID string) error
 }
 
 // Context is used to provide the services and current URL to the handlers.
@@ -845,6 +1408,23 @@ func Routes(config Config, r *mux.Router) *mux.Router {
 	route.Methods("DELETE")
 	route.HeadersRegexp("Content-Type", matchContent)
 
+	// port forwards of external IPs
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/external-ips/port-forwards", Handler{context, createPortForward, false})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/external-ips/port-forwards", Handler{context, listPortForwards, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/external-ips/port-forwards/{forward_id:"+uuid.UUIDRegex+"}", Handler{context, showPortForward, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/external-ips/port-forwards/{forward_id:"+uuid.UUIDRegex+"}", Handler{context, deletePortForward, false})
+	route.Methods("DELETE")
+	route.HeadersRegexp("Content-Type", matchContent)
+
 	// workloads
 	matchContent = fmt.Sprintf("application/(%s|json)", WorkloadsV1)
 
@@ -927,5 +1507,106 @@ func Routes(config Config, r *mux.Router) *mux.Router {
 	route.Methods("PUT")
 	route.HeadersRegexp("Content-Type", matchContent)
 
+	// network inspection and repair
+	route = r.Handle("/node/{node_id:"+uuid.UUIDRegex+"}/network", Handler{context, showNodeNetwork, true})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/node/{node_id:"+uuid.UUIDRegex+"}/network", Handler{context, inspectNodeNetwork, true})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	// server groups
+	matchContent = fmt.Sprintf("application/(%s|json)", ServerGroupsV1)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/server-groups", Handler{context, createServerGroup, false})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/server-groups", Handler{context, listServerGroups, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/server-groups/{group_id:"+uuid.UUIDRegex+"}", Handler{context, showServerGroup, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/server-groups/{group_id:"+uuid.UUIDRegex+"}", Handler{context, deleteServerGroup, false})
+	route.Methods("DELETE")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	// security groups
+	matchContent = fmt.Sprintf("application/(%s|json)", SecurityGroupsV1)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/security-groups", Handler{context, createSecurityGroup, false})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/security-groups", Handler{context, listSecurityGroups, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/security-groups/{group_id:"+uuid.UUIDRegex+"}", Handler{context, showSecurityGroup, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/security-groups/{group_id:"+uuid.UUIDRegex+"}", Handler{context, deleteSecurityGroup, false})
+	route.Methods("DELETE")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/security-groups/{group_id:"+uuid.UUIDRegex+"}/rules", Handler{context, createSecurityGroupRule, false})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/security-groups/{group_id:"+uuid.UUIDRegex+"}/rules/{rule_id:"+uuid.UUIDRegex+"}", Handler{context, deleteSecurityGroupRule, false})
+	route.Methods("DELETE")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	// tenant networks
+	matchContent = fmt.Sprintf("application/(%s|json)", NetworksV1)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/networks", Handler{context, createTenantNetwork, false})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/networks", Handler{context, listTenantNetworks, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/networks/{network_id:"+uuid.UUIDRegex+"}", Handler{context, showTenantNetwork, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/networks/{network_id:"+uuid.UUIDRegex+"}", Handler{context, deleteTenantNetwork, false})
+	route.Methods("DELETE")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	// load balancers
+	matchContent = fmt.Sprintf("application/(%s|json)", LoadBalancersV1)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/load-balancers", Handler{context, createLoadBalancer, false})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/load-balancers", Handler{context, listLoadBalancers, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/load-balancers/{lb_id:"+uuid.UUIDRegex+"}", Handler{context, showLoadBalancer, false})
+	route.Methods("GET")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/load-balancers/{lb_id:"+uuid.UUIDRegex+"}", Handler{context, deleteLoadBalancer, false})
+	route.Methods("DELETE")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/load-balancers/{lb_id:"+uuid.UUIDRegex+"}/members", Handler{context, addLoadBalancerMember, false})
+	route.Methods("POST")
+	route.HeadersRegexp("Content-Type", matchContent)
+
+	route = r.Handle("/{tenant:"+uuid.UUIDRegex+"}/load-balancers/{lb_id:"+uuid.UUIDRegex+"}/members/{instance_id:"+uuid.UUIDRegex+"}", Handler{context, deleteLoadBalancerMember, false})
+	route.Methods("DELETE")
+	route.HeadersRegexp("Content-Type", matchContent)
+
 	return r
 }
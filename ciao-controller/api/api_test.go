@@ -124,6 +124,38 @@ var tests = []test{
 		http.StatusNoContent,
 		"null",
 	},
+	{
+		"POST",
+		"/19df9b86-eda3-489d-b75f-d38710e210cb/external-ips/port-forwards",
+		`{"pool_name":"apool","protocol":"tcp","external_port":2222,"instance_id":"ba58f471-0735-4773-9550-188e2d012941","internal_port":22}`,
+		fmt.Sprintf("application/%s", ExternalIPsV1),
+		http.StatusCreated,
+		`{"id":"3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b","tenant_id":"19df9b86-eda3-489d-b75f-d38710e210cb","external_ip":"192.168.0.1","pool_id":"f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e","pool_name":"apool","protocol":"tcp","external_port":2222,"instance_id":"ba58f471-0735-4773-9550-188e2d012941","internal_ip":"172.16.0.2","internal_port":22,"links":[{"rel":"self","href":"/19df9b86-eda3-489d-b75f-d38710e210cb/external-ips/port-forwards/3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b"}]}`,
+	},
+	{
+		"GET",
+		"/19df9b86-eda3-489d-b75f-d38710e210cb/external-ips/port-forwards",
+		"",
+		fmt.Sprintf("application/%s", ExternalIPsV1),
+		http.StatusOK,
+		`{"port_forwards":[{"id":"3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b","tenant_id":"19df9b86-eda3-489d-b75f-d38710e210cb","external_ip":"192.168.0.1","pool_id":"f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e","pool_name":"apool","protocol":"tcp","external_port":2222,"instance_id":"ba58f471-0735-4773-9550-188e2d012941","internal_ip":"172.16.0.2","internal_port":22,"links":[{"rel":"self","href":"/19df9b86-eda3-489d-b75f-d38710e210cb/external-ips/port-forwards/3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b"}]}]}`,
+	},
+	{
+		"GET",
+		"/19df9b86-eda3-489d-b75f-d38710e210cb/external-ips/port-forwards/3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b",
+		"",
+		fmt.Sprintf("application/%s", ExternalIPsV1),
+		http.StatusOK,
+		`{"id":"3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b","tenant_id":"19df9b86-eda3-489d-b75f-d38710e210cb","external_ip":"192.168.0.1","pool_id":"f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e","pool_name":"apool","protocol":"tcp","external_port":2222,"instance_id":"ba58f471-0735-4773-9550-188e2d012941","internal_ip":"172.16.0.2","internal_port":22,"links":[{"rel":"self","href":"/19df9b86-eda3-489d-b75f-d38710e210cb/external-ips/port-forwards/3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b"}]}`,
+	},
+	{
+		"DELETE",
+		"/19df9b86-eda3-489d-b75f-d38710e210cb/external-ips/port-forwards/3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b",
+		"",
+		fmt.Sprintf("application/%s", ExternalIPsV1),
+		http.StatusNoContent,
+		"null",
+	},
 	{
 		"POST",
 		"/workloads",
@@ -204,6 +236,206 @@ var tests = []test{
 		http.StatusNoContent,
 		"null",
 	},
+	{
+		"POST",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/server-groups",
+		`{"name":"ha-pair","policy":"anti-affinity"}`,
+		fmt.Sprintf("application/%s", ServerGroupsV1),
+		http.StatusCreated,
+		`{"id":"4cb19522-1e18-439a-883a-f9b2a3a95f5e","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"ha-pair","policy":"anti-affinity","members":[],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/server-groups/4cb19522-1e18-439a-883a-f9b2a3a95f5e"}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/server-groups",
+		"",
+		fmt.Sprintf("application/%s", ServerGroupsV1),
+		http.StatusOK,
+		`{"server_groups":[{"id":"4cb19522-1e18-439a-883a-f9b2a3a95f5e","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"ha-pair","policy":"anti-affinity","members":["ba58f471-0735-4773-9550-188e2d012941"],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/server-groups/4cb19522-1e18-439a-883a-f9b2a3a95f5e"}]}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/server-groups/4cb19522-1e18-439a-883a-f9b2a3a95f5e",
+		"",
+		fmt.Sprintf("application/%s", ServerGroupsV1),
+		http.StatusOK,
+		`{"id":"4cb19522-1e18-439a-883a-f9b2a3a95f5e","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"ha-pair","policy":"anti-affinity","members":["ba58f471-0735-4773-9550-188e2d012941"],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/server-groups/4cb19522-1e18-439a-883a-f9b2a3a95f5e"}]}`,
+	},
+	{
+		"DELETE",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/server-groups/4cb19522-1e18-439a-883a-f9b2a3a95f5e",
+		"",
+		fmt.Sprintf("application/%s", ServerGroupsV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"PUT",
+		"/node/0e6c8b3b-e5c1-4e27-9a3e-c8e7b8d5c6a2",
+		`{"labels":{"ssd":"true","rack":"r12"}}`,
+		fmt.Sprintf("application/%s", NodeV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"PUT",
+		"/node/0e6c8b3b-e5c1-4e27-9a3e-c8e7b8d5c6a2",
+		`{"status":"MAINTENANCE","labels":{"ssd":"true"}}`,
+		fmt.Sprintf("application/%s", NodeV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"PUT",
+		"/node/0e6c8b3b-e5c1-4e27-9a3e-c8e7b8d5c6a2",
+		`{"status":"READY","labels":{"ssd":"false"}}`,
+		fmt.Sprintf("application/%s", NodeV1),
+		http.StatusForbidden,
+		"{\"error\":{\"code\":403,\"name\":\"Forbidden\",\"message\":\"Invalid Request\"}}\n",
+	},
+	{
+		"POST",
+		"/node/0e6c8b3b-e5c1-4e27-9a3e-c8e7b8d5c6a2/network",
+		`{"repair":true}`,
+		fmt.Sprintf("application/%s", NodeV1),
+		http.StatusOK,
+		`{"node_id":"0e6c8b3b-e5c1-4e27-9a3e-c8e7b8d5c6a2","updated":"0001-01-01T00:00:00Z","repair":true,"expected":[{"alias":"vnic_t_s_c_10.0.0.1##172.16.0.2","name":"svn_1","master":"br_t_s_c_10.0.0.1"}],"actual":[{"alias":"vnic_t_s_c_10.0.0.1##172.16.0.2","name":"svn_1","up":true}],"dnsmasq":[],"problems":[{"alias":"vnic_t_s_c_10.0.0.1##172.16.0.2","problem":"VNIC has no bridge br_t_s_c_10.0.0.1","repaired":false}]}`,
+	},
+	{
+		"GET",
+		"/node/0e6c8b3b-e5c1-4e27-9a3e-c8e7b8d5c6a2/network",
+		"",
+		fmt.Sprintf("application/%s", NodeV1),
+		http.StatusOK,
+		`{"node_id":"0e6c8b3b-e5c1-4e27-9a3e-c8e7b8d5c6a2","updated":"0001-01-01T00:00:00Z","repair":false,"expected":[{"alias":"vnic_t_s_c_10.0.0.1##172.16.0.2","name":"svn_1","master":"br_t_s_c_10.0.0.1"}],"actual":[{"alias":"vnic_t_s_c_10.0.0.1##172.16.0.2","name":"svn_1","up":true}],"dnsmasq":[],"problems":[{"alias":"vnic_t_s_c_10.0.0.1##172.16.0.2","problem":"VNIC has no bridge br_t_s_c_10.0.0.1","repaired":false}]}`,
+	},
+	{
+		"POST",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups",
+		`{"name":"web","description":"Web servers"}`,
+		fmt.Sprintf("application/%s", SecurityGroupsV1),
+		http.StatusCreated,
+		`{"id":"cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"web","description":"Web servers","rules":[{"id":"e2a4a7c8-5f9e-4a4a-9d0c-77b1d8e2f3a1","direction":"ingress","protocol":"tcp","port_range_min":80,"port_range_max":80,"remote_ip_prefix":"0.0.0.0/0"}],"members":[],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups/cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b"}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups",
+		"",
+		fmt.Sprintf("application/%s", SecurityGroupsV1),
+		http.StatusOK,
+		`{"security_groups":[{"id":"cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"web","description":"Web servers","rules":[{"id":"e2a4a7c8-5f9e-4a4a-9d0c-77b1d8e2f3a1","direction":"ingress","protocol":"tcp","port_range_min":80,"port_range_max":80,"remote_ip_prefix":"0.0.0.0/0"}],"members":["ba58f471-0735-4773-9550-188e2d012941"],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups/cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b"}]}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups/cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b",
+		"",
+		fmt.Sprintf("application/%s", SecurityGroupsV1),
+		http.StatusOK,
+		`{"id":"cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"web","description":"Web servers","rules":[{"id":"e2a4a7c8-5f9e-4a4a-9d0c-77b1d8e2f3a1","direction":"ingress","protocol":"tcp","port_range_min":80,"port_range_max":80,"remote_ip_prefix":"0.0.0.0/0"}],"members":["ba58f471-0735-4773-9550-188e2d012941"],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups/cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b"}]}`,
+	},
+	{
+		"DELETE",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups/cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b",
+		"",
+		fmt.Sprintf("application/%s", SecurityGroupsV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"POST",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups/cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b/rules",
+		`{"direction":"ingress","protocol":"tcp","port_range_min":80,"remote_ip_prefix":"0.0.0.0/0"}`,
+		fmt.Sprintf("application/%s", SecurityGroupsV1),
+		http.StatusCreated,
+		`{"id":"e2a4a7c8-5f9e-4a4a-9d0c-77b1d8e2f3a1","direction":"ingress","protocol":"tcp","port_range_min":80,"port_range_max":80,"remote_ip_prefix":"0.0.0.0/0"}`,
+	},
+	{
+		"DELETE",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/security-groups/cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b/rules/e2a4a7c8-5f9e-4a4a-9d0c-77b1d8e2f3a1",
+		"",
+		fmt.Sprintf("application/%s", SecurityGroupsV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"POST",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/networks",
+		`{"name":"backend","cidr":"172.18.5.0/24"}`,
+		fmt.Sprintf("application/%s", NetworksV1),
+		http.StatusCreated,
+		`{"id":"5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"backend","cidr":"172.18.5.0/24","links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/networks/5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f"}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/networks",
+		"",
+		fmt.Sprintf("application/%s", NetworksV1),
+		http.StatusOK,
+		`{"networks":[{"id":"5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"backend","cidr":"172.18.5.0/24","links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/networks/5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f"}]}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/networks/5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f",
+		"",
+		fmt.Sprintf("application/%s", NetworksV1),
+		http.StatusOK,
+		`{"id":"5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"backend","cidr":"172.18.5.0/24","links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/networks/5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f"}]}`,
+	},
+	{
+		"DELETE",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/networks/5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f",
+		"",
+		fmt.Sprintf("application/%s", NetworksV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"POST",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers",
+		`{"name":"web","pool_name":"testpool","protocol":"tcp","port":80,"member_port":8080,"members":["ba58f471-0735-4773-9550-188e2d012941"]}`,
+		fmt.Sprintf("application/%s", LoadBalancersV1),
+		http.StatusCreated,
+		`{"id":"6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"web","external_ip":"192.168.0.1","pool_id":"f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e","pool_name":"testpool","protocol":"tcp","port":80,"member_port":8080,"health_check_interval":5,"health_check_timeout":2,"members":["ba58f471-0735-4773-9550-188e2d012941"],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers/6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b"}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers",
+		"",
+		fmt.Sprintf("application/%s", LoadBalancersV1),
+		http.StatusOK,
+		`{"load_balancers":[{"id":"6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"web","external_ip":"192.168.0.1","pool_id":"f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e","pool_name":"testpool","protocol":"tcp","port":80,"member_port":8080,"health_check_interval":5,"health_check_timeout":2,"members":["ba58f471-0735-4773-9550-188e2d012941"],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers/6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b"}]}]}`,
+	},
+	{
+		"GET",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers/6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b",
+		"",
+		fmt.Sprintf("application/%s", LoadBalancersV1),
+		http.StatusOK,
+		`{"id":"6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b","tenant_id":"093ae09b-f653-464e-9ae6-5ae28bd03a22","name":"web","external_ip":"192.168.0.1","pool_id":"f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e","pool_name":"testpool","protocol":"tcp","port":80,"member_port":8080,"health_check_interval":5,"health_check_timeout":2,"members":["ba58f471-0735-4773-9550-188e2d012941"],"links":[{"rel":"self","href":"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers/6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b"}]}`,
+	},
+	{
+		"DELETE",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers/6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b",
+		"",
+		fmt.Sprintf("application/%s", LoadBalancersV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"POST",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers/6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b/members",
+		`{"instance_id":"ba58f471-0735-4773-9550-188e2d012941"}`,
+		fmt.Sprintf("application/%s", LoadBalancersV1),
+		http.StatusNoContent,
+		"null",
+	},
+	{
+		"DELETE",
+		"/093ae09b-f653-464e-9ae6-5ae28bd03a22/load-balancers/6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b/members/ba58f471-0735-4773-9550-188e2d012941",
+		"",
+		fmt.Sprintf("application/%s", LoadBalancersV1),
+		http.StatusNoContent,
+		"null",
+	},
 }
 
 type testCiaoService struct{}
@@ -310,6 +542,42 @@ func (ts testCiaoService) UnMapAddress(string) error {
 	return nil
 }
 
+func testPortForward(tenantID string) types.PortForward {
+	pf := types.PortForward{
+		ID:           "3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b",
+		TenantID:     tenantID,
+		ExternalIP:   "192.168.0.1",
+		PoolID:       "f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e",
+		PoolName:     "apool",
+		Protocol:     "tcp",
+		ExternalPort: 2222,
+		InstanceID:   "ba58f471-0735-4773-9550-188e2d012941",
+		InternalIP:   "172.16.0.2",
+		InternalPort: 22,
+	}
+
+	ref := fmt.Sprintf("/%s/external-ips/port-forwards/%s", tenantID, pf.ID)
+	pf.Links = []types.Link{{Rel: "self", Href: ref}}
+
+	return pf
+}
+
+func (ts testCiaoService) CreatePortForward(tenantID string, req types.PortForwardRequest) (types.PortForward, error) {
+	return testPortForward(tenantID), nil
+}
+
+func (ts testCiaoService) ListPortForwards(tenantID string) ([]types.PortForward, error) {
+	return []types.PortForward{testPortForward(tenantID)}, nil
+}
+
+func (ts testCiaoService) ShowPortForward(tenantID string, forwardID string) (types.PortForward, error) {
+	return testPortForward(tenantID), nil
+}
+
+func (ts testCiaoService) DeletePortForward(tenantID string, forwardID string) error {
+	return nil
+}
+
 func (ts testCiaoService) CreateWorkload(req types.Workload) (types.Workload, error) {
 	req.ID = "ba58f471-0735-4773-9550-188e2d012941"
 	return req, nil
@@ -359,6 +627,49 @@ func (ts testCiaoService) RestoreNode(nodeID string) error {
 	return nil
 }
 
+func (ts testCiaoService) LabelNode(nodeID string, labels map[string]string) error {
+	if labels["ssd"] != "true" {
+		return types.ErrBadRequest
+	}
+	return nil
+}
+
+func testNodeNetwork(nodeID string, repair bool) types.NodeNetwork {
+	return types.NodeNetwork{
+		NodeID: nodeID,
+		Repair: repair,
+		Expected: []types.NodeNetworkLink{
+			{
+				Alias:  "vnic_t_s_c_10.0.0.1##172.16.0.2",
+				Name:   "svn_1",
+				Master: "br_t_s_c_10.0.0.1",
+			},
+		},
+		Actual: []types.NodeNetworkLink{
+			{
+				Alias: "vnic_t_s_c_10.0.0.1##172.16.0.2",
+				Name:  "svn_1",
+				Up:    true,
+			},
+		},
+		Dnsmasq: []types.NodeNetworkDnsmasq{},
+		Problems: []types.NodeNetworkProblem{
+			{
+				Alias:   "vnic_t_s_c_10.0.0.1##172.16.0.2",
+				Problem: "VNIC has no bridge br_t_s_c_10.0.0.1",
+			},
+		},
+	}
+}
+
+func (ts testCiaoService) InspectNodeNetwork(nodeID string, repair bool) (types.NodeNetwork, error) {
+	return testNodeNetwork(nodeID, repair), nil
+}
+
+func (ts testCiaoService) ShowNodeNetwork(nodeID string) (types.NodeNetwork, error) {
+	return testNodeNetwork(nodeID, false), nil
+}
+
 func (ts testCiaoService) UpdateQuotas(tenantID string, qds []types.QuotaDetails) error {
 	return nil
 }
@@ -414,6 +725,168 @@ func (ts testCiaoService) DeleteTenant(string) error {
 	return nil
 }
 
+func testServerGroup(tenantID string, members []string) types.ServerGroup {
+	group := types.ServerGroup{
+		ID:       "4cb19522-1e18-439a-883a-f9b2a3a95f5e",
+		TenantID: tenantID,
+		Name:     "ha-pair",
+		Policy:   payloads.AntiAffinity,
+		Members:  members,
+	}
+
+	ref := fmt.Sprintf("/%s/server-groups/%s", tenantID, group.ID)
+	group.Links = []types.Link{{Rel: "self", Href: ref}}
+
+	return group
+}
+
+func (ts testCiaoService) CreateServerGroup(tenantID string, req types.ServerGroupRequest) (types.ServerGroup, error) {
+	return testServerGroup(tenantID, []string{}), nil
+}
+
+func (ts testCiaoService) ListServerGroups(tenantID string) ([]types.ServerGroup, error) {
+	members := []string{"ba58f471-0735-4773-9550-188e2d012941"}
+	return []types.ServerGroup{testServerGroup(tenantID, members)}, nil
+}
+
+func (ts testCiaoService) ShowServerGroup(tenantID string, groupID string) (types.ServerGroup, error) {
+	members := []string{"ba58f471-0735-4773-9550-188e2d012941"}
+	return testServerGroup(tenantID, members), nil
+}
+
+func (ts testCiaoService) DeleteServerGroup(tenantID string, groupID string) error {
+	return nil
+}
+
+func testSecurityGroupRule() types.SecurityGroupRule {
+	return types.SecurityGroupRule{
+		ID:             "e2a4a7c8-5f9e-4a4a-9d0c-77b1d8e2f3a1",
+		Direction:      payloads.Ingress,
+		Protocol:       "tcp",
+		PortRangeMin:   80,
+		PortRangeMax:   80,
+		RemoteIPPrefix: "0.0.0.0/0",
+	}
+}
+
+func testSecurityGroup(tenantID string, members []string) types.SecurityGroup {
+	group := types.SecurityGroup{
+		ID:          "cd1ba3bc-1a6f-4c4b-a1c1-3b2d4f5e6a7b",
+		TenantID:    tenantID,
+		Name:        "web",
+		Description: "Web servers",
+		Rules:       []types.SecurityGroupRule{testSecurityGroupRule()},
+		Members:     members,
+	}
+
+	ref := fmt.Sprintf("/%s/security-groups/%s", tenantID, group.ID)
+	group.Links = []types.Link{{Rel: "self", Href: ref}}
+
+	return group
+}
+
+func (ts testCiaoService) CreateSecurityGroup(tenantID string, req types.SecurityGroupRequest) (types.SecurityGroup, error) {
+	return testSecurityGroup(tenantID, []string{}), nil
+}
+
+func (ts testCiaoService) ListSecurityGroups(tenantID string) ([]types.SecurityGroup, error) {
+	members := []string{"ba58f471-0735-4773-9550-188e2d012941"}
+	return []types.SecurityGroup{testSecurityGroup(tenantID, members)}, nil
+}
+
+func (ts testCiaoService) ShowSecurityGroup(tenantID string, groupID string) (types.SecurityGroup, error) {
+	members := []string{"ba58f471-0735-4773-9550-188e2d012941"}
+	return testSecurityGroup(tenantID, members), nil
+}
+
+func (ts testCiaoService) DeleteSecurityGroup(tenantID string, groupID string) error {
+	return nil
+}
+
+func (ts testCiaoService) CreateSecurityGroupRule(tenantID string, groupID string, rule types.SecurityGroupRule) (types.SecurityGroupRule, error) {
+	return testSecurityGroupRule(), nil
+}
+
+func (ts testCiaoService) DeleteSecurityGroupRule(tenantID string, groupID string, ruleID string) error {
+	return nil
+}
+
+func testTenantNetwork(tenantID string) types.TenantNetwork {
+	network := types.TenantNetwork{
+		ID:       "5f0e8d6c-2b1a-4c3d-8e9f-0a1b2c3d4e5f",
+		TenantID: tenantID,
+		Name:     "backend",
+		CIDR:     "172.18.5.0/24",
+	}
+
+	ref := fmt.Sprintf("/%s/networks/%s", tenantID, network.ID)
+	network.Links = []types.Link{{Rel: "self", Href: ref}}
+
+	return network
+}
+
+func (ts testCiaoService) CreateTenantNetwork(tenantID string, req types.TenantNetworkRequest) (types.TenantNetwork, error) {
+	return testTenantNetwork(tenantID), nil
+}
+
+func (ts testCiaoService) ListTenantNetworks(tenantID string) ([]types.TenantNetwork, error) {
+	return []types.TenantNetwork{testTenantNetwork(tenantID)}, nil
+}
+
+func (ts testCiaoService) ShowTenantNetwork(tenantID string, networkID string) (types.TenantNetwork, error) {
+	return testTenantNetwork(tenantID), nil
+}
+
+func (ts testCiaoService) DeleteTenantNetwork(tenantID string, networkID string) error {
+	return nil
+}
+
+func testLoadBalancer(tenantID string) types.LoadBalancer {
+	lb := types.LoadBalancer{
+		ID:                  "6a1f3c2e-8d4b-4e7a-9c5f-1b2d3e4f5a6b",
+		TenantID:            tenantID,
+		Name:                "web",
+		ExternalIP:          "192.168.0.1",
+		PoolID:              "f384ffd8-e7bd-40c2-8552-2efbe7e3ad6e",
+		PoolName:            "testpool",
+		Protocol:            "tcp",
+		Port:                80,
+		MemberPort:          8080,
+		HealthCheckInterval: 5,
+		HealthCheckTimeout:  2,
+		Members:             []string{"ba58f471-0735-4773-9550-188e2d012941"},
+	}
+
+	ref := fmt.Sprintf("/%s/load-balancers/%s", tenantID, lb.ID)
+	lb.Links = []types.Link{{Rel: "self", Href: ref}}
+
+	return lb
+}
+
+func (ts testCiaoService) CreateLoadBalancer(tenantID string, req types.LoadBalancerRequest) (types.LoadBalancer, error) {
+	return testLoadBalancer(tenantID), nil
+}
+
+func (ts testCiaoService) ListLoadBalancers(tenantID string) ([]types.LoadBalancer, error) {
+	return []types.LoadBalancer{testLoadBalancer(tenantID)}, nil
+}
+
+func (ts testCiaoService) ShowLoadBalancer(tenantID string, lbID string) (types.LoadBalancer, error) {
+	return testLoadBalancer(tenantID), nil
+}
+
+func (ts testCiaoService) DeleteLoadBalancer(tenantID string, lbID string) error {
+	return nil
+}
+
+func (ts testCiaoService) AddLoadBalancerMember(tenantID string, lbID string, instanceID string) error {
+	return nil
+}
+
+func (ts testCiaoService) DeleteLoadBalancerMember(tenantID string, lbID string, instanceID string) error {
+	return nil
+}
+
 func TestResponse(t *testing.T) {
 	var ts testCiaoService
 
@@ -37,13 +37,23 @@ type controllerClient interface {
 	DeleteInstance(instanceID string, nodeID string) error
 	StopInstance(instanceID string, nodeID string) error
 	RestartInstance(i *types.Instance, w *types.Workload, t *types.Tenant) error
+	MigrateInstance(i *types.Instance, w *types.Workload, t *types.Tenant) error
 	RemoveInstance(instanceID string)
 	EvacuateNode(nodeID string) error
 	RestoreNode(nodeID string) error
+	LabelNode(nodeID string, labels map[string]string) error
+	InspectNetwork(nodeID string, repair bool) error
 	Disconnect()
 	mapExternalIP(t types.Tenant, m types.MappedIP) error
 	unMapExternalIP(t types.Tenant, m types.MappedIP) error
-	attachVolume(volID string, instanceID string, nodeID string) error
+	setSecurityGroups(cmd payloads.SecurityGroupsCmd) error
+	setLoadBalancers(cmd payloads.LoadBalancersCmd) error
+	setPortForwards(cmd payloads.PortForwardsCmd) error
+	setTenantDNS(cmd payloads.TenantDNSCmd) error
+	setTenantRoutes(cmd payloads.TenantRoutesCmd) error
+	attachVolume(volID string, instanceID string, nodeID string, volumeType string) error
+	detachVolume(volID string, instanceID string, nodeID string) error
+	resizeVolume(volID string, instanceID string, nodeID string, size int) error
 	ssntpClient() *ssntp.Client
 }
 
@@ -110,7 +120,7 @@ func (client *ssntpClient) releaseResources(instanceID string) error {
 	}
 
 	resources := []payloads.RequestedResource{{Type: payloads.Instance, Value: 1}}
-	resources = append(resources, wl.Defaults...)
+	resources = append(resources, instanceResources(i, &wl)...)
 	client.ctl.qs.Release(i.TenantID, resources...)
 	return nil
 }
@@ -133,6 +143,18 @@ func (client *ssntpClient) RemoveInstance(instanceID string) {
 		glog.Warningf("Error deleting instance from datastore: %v", err)
 	}
 
+	if !i.CNCI {
+		client.ctl.updateSecurityGroups(i.TenantID)
+
+		if i.Name != "" {
+			client.ctl.updateTenantDNS(i.TenantID)
+		}
+
+		if len(client.ctl.ds.GetLoadBalancers(i.TenantID)) > 0 {
+			client.ctl.updateLoadBalancers(i.TenantID)
+		}
+	}
+
 	if i.CNCI {
 		tenant, err := client.ctl.ds.GetTenant(i.TenantID)
 		if err != nil {
@@ -144,6 +166,8 @@ func (client *ssntpClient) RemoveInstance(instanceID string) {
 		if err != nil {
 			glog.Warningf("Error removing CNCI: %v", err)
 		}
+
+		client.ctl.updateTenantRoutes(i.TenantID)
 	}
 
 	// notify anyone is listening for a state change
@@ -223,6 +247,12 @@ func (client *ssntpClient) concentratorInstanceAdded(payload []byte) {
 	}
 
 	tenant.CNCIctrl.CNCIAdded(newCNCI.InstanceUUID)
+
+	// the new CNCI serves the names of all the instances of the tenant
+	client.ctl.updateTenantDNS(i.TenantID)
+
+	// and its subnet is routed by the routers of the tenant
+	client.ctl.updateTenantRoutes(i.TenantID)
 }
 
 func (client *ssntpClient) traceReport(payload []byte) {
@@ -262,6 +292,18 @@ func (client *ssntpClient) nodeDisconnected(payload []byte) {
 	client.ctl.ds.DeleteNode(nodeDisconnected.Disconnected.NodeUUID)
 }
 
+func (client *ssntpClient) networkReport(payload []byte) {
+	var event payloads.NetworkReport
+	err := yaml.Unmarshal(payload, &event)
+	if err != nil {
+		glog.Warningf("Error unmarshalling NetworkReport: %v", err)
+		return
+	}
+
+	glog.Infof("Node %s reported %d network problems", event.Report.NodeUUID, len(event.Report.Problems))
+	client.ctl.networkReported(event.Report)
+}
+
 func (client *ssntpClient) unassignEvent(payload []byte) {
 	var event payloads.EventPublicIPUnassigned
 	err := yaml.Unmarshal(payload, &event)
@@ -338,6 +380,9 @@ func (client *ssntpClient) EventNotify(event ssntp.Event, frame *ssntp.Frame) {
 	case ssntp.PublicIPUnassigned:
 		client.unassignEvent(payload)
 
+	case ssntp.NetworkReport:
+		client.networkReport(payload)
+
 	}
 }
 
@@ -420,6 +465,19 @@ func (client *ssntpClient) attachVolumeFailure(payload []byte) {
 	}
 }
 
+func (client *ssntpClient) detachVolumeFailure(payload []byte) {
+	var failure payloads.ErrorDetachVolumeFailure
+	err := yaml.Unmarshal(payload, &failure)
+	if err != nil {
+		glog.Warningf("Error unmarshalling DetachVolumeFailure: %v", err)
+		return
+	}
+	err = client.ctl.ds.DetachVolumeFailure(failure.InstanceUUID, failure.VolumeUUID, failure.Reason)
+	if err != nil {
+		glog.Warningf("Error handling DetachVolumeFailure in datastore: %v", err)
+	}
+}
+
 func (client *ssntpClient) assignError(payload []byte) {
 	var failure payloads.ErrorPublicIPFailure
 	err := yaml.Unmarshal(payload, &failure)
@@ -452,6 +510,27 @@ func (client *ssntpClient) unassignError(payload []byte) {
 	client.ctl.ds.LogError(failure.TenantUUID, msg)
 }
 
+func (client *ssntpClient) migrateFailure(payload []byte) {
+	var failure payloads.ErrorMigrateFailure
+	err := yaml.Unmarshal(payload, &failure)
+	if err != nil {
+		glog.Warningf("Error unmarshalling MigrateFailure: %v", err)
+		return
+	}
+
+	i, err := client.ctl.ds.GetInstance(failure.InstanceUUID)
+	if err != nil {
+		glog.Warningf("Error getting instance: %v", err)
+		return
+	}
+
+	// The instance is still running on its original node so there's
+	// nothing to clean up.  All we can do is log.
+	msg := fmt.Sprintf("Failed to migrate %s from %s: %s", failure.InstanceUUID,
+		failure.NodeUUID, failure.Reason.String())
+	client.ctl.ds.LogError(i.TenantID, msg)
+}
+
 func (client *ssntpClient) ErrorNotify(err ssntp.Error, frame *ssntp.Frame) {
 	payload := frame.Payload
 
@@ -471,12 +550,18 @@ func (client *ssntpClient) ErrorNotify(err ssntp.Error, frame *ssntp.Frame) {
 	case ssntp.AttachVolumeFailure:
 		client.attachVolumeFailure(payload)
 
+	case ssntp.DetachVolumeFailure:
+		client.detachVolumeFailure(payload)
+
 	case ssntp.AssignPublicIPFailure:
 		client.assignError(payload)
 
 	case ssntp.UnassignPublicIPFailure:
 		client.unassignError(payload)
 
+	case ssntp.MigrateFailure:
+		client.migrateFailure(payload)
+
 	}
 }
 
@@ -559,13 +644,31 @@ func (client *ssntpClient) StopInstance(instanceID string, nodeID string) error
 
 func (client *ssntpClient) RestartInstance(i *types.Instance, w *types.Workload,
 	t *types.Tenant) error {
-	var cnci *types.Instance
-
 	err := client.ctl.ds.InstanceRestarting(i.ID)
 	if err != nil {
 		return errors.Wrapf(err, "Unable to update instance state before restarting")
 	}
 
+	return client.sendRestart(i, w, t, "")
+}
+
+// MigrateInstance asks the scheduler to find a new home for a running
+// instance.  The instance continues to run on its current node until the
+// node chosen by the scheduler has received it.
+func (client *ssntpClient) MigrateInstance(i *types.Instance, w *types.Workload,
+	t *types.Tenant) error {
+	if i.NodeID == "" {
+		return types.ErrInstanceNotAssigned
+	}
+
+	return client.sendRestart(i, w, t, i.NodeID)
+}
+
+func (client *ssntpClient) sendRestart(i *types.Instance, w *types.Workload,
+	t *types.Tenant, migrateFrom string) error {
+	var cnci *types.Instance
+	var err error
+
 	if !i.CNCI {
 		// get the CNCI for this instance
 		cnci, err = t.CNCIctrl.GetInstanceCNCI(i.ID)
@@ -592,13 +695,14 @@ func (client *ssntpClient) RestartInstance(i *types.Instance, w *types.Workload,
 		FWType:              payloads.Firmware(w.FWType),
 		VMType:              w.VMType,
 		InstancePersistence: payloads.Host,
-		RequestedResources:  w.Defaults,
+		RequestedResources:  instanceResources(i, w),
 		Networking: payloads.NetworkResources{
 			VnicMAC:  i.MACAddress,
 			VnicUUID: i.VnicUUID,
 		},
-		Storage: make([]payloads.StorageResource, len(attachments)),
-		Restart: true,
+		Storage:     make([]payloads.StorageResource, len(attachments)),
+		Restart:     true,
+		MigrateFrom: migrateFrom,
 	}
 
 	if cnci != nil {
@@ -608,10 +712,39 @@ func (client *ssntpClient) RestartInstance(i *types.Instance, w *types.Workload,
 		restartCmd.Networking.PrivateIP = i.IPAddress
 	}
 
+	if !i.CNCI {
+		restartCmd.RouterSubnets = tenantRouterSubnets(t)
+	}
+
+	for _, vnic := range i.Vnics {
+		vnicCNCI, err := t.CNCIctrl.GetSubnetCNCI(vnic.Subnet)
+		if err != nil {
+			return err
+		}
+
+		restartCmd.ExtraNetworking = append(restartCmd.ExtraNetworking,
+			payloads.NetworkResources{
+				VnicMAC:          vnic.MACAddress,
+				VnicUUID:         vnic.VnicUUID,
+				ConcentratorUUID: vnicCNCI.ID,
+				ConcentratorIP:   vnicCNCI.IPAddress,
+				Subnet:           vnic.Subnet,
+				PrivateIP:        vnic.IPAddress,
+			})
+	}
+
 	if w.VMType == payloads.Docker {
 		restartCmd.DockerImage = w.ImageName
 	}
 
+	if len(w.NodeSelector) > 0 {
+		restartCmd.NodeSelector = w.NodeSelector
+	}
+
+	if group, ok := client.ctl.ds.GetInstanceServerGroup(i.ID); ok {
+		restartCmd.ServerGroup = client.ctl.serverGroupHint(group, i.ID)
+	}
+
 	for k := range attachments {
 		vol := &restartCmd.Storage[k]
 		vol.ID = attachments[k].BlockID
@@ -642,7 +775,11 @@ func (client *ssntpClient) RestartInstance(i *types.Instance, w *types.Workload,
 	_, _ = buf.Write(b)
 	_, _ = buf.WriteString("\n...\n")
 
-	glog.Info("RESTART instance: ", i.ID)
+	if migrateFrom != "" {
+		glog.Info("MIGRATE instance: ", i.ID, " from node ", migrateFrom)
+	} else {
+		glog.Info("RESTART instance: ", i.ID)
+	}
 	glog.V(1).Info(buf.String())
 
 	_, err = client.ssntp.SendCommand(ssntp.START, buf.Bytes())
@@ -694,12 +831,55 @@ func (client *ssntpClient) RestoreNode(nodeID string) error {
 	return err
 }
 
-func (client *ssntpClient) attachVolume(volID string, instanceID string, nodeID string) error {
+func (client *ssntpClient) LabelNode(nodeID string, labels map[string]string) error {
+	payload := payloads.LabelNode{
+		LabelNode: payloads.LabelNodeCmd{
+			WorkloadAgentUUID: nodeID,
+			Labels:            labels,
+		},
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Info("Label node: ", nodeID)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.LabelNode, y)
+
+	return err
+}
+
+func (client *ssntpClient) InspectNetwork(nodeID string, repair bool) error {
+	payload := payloads.NetworkInspect{
+		Inspect: payloads.NetworkInspectCmd{
+			NodeUUID: nodeID,
+			Repair:   repair,
+		},
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Info("Inspect network of node: ", nodeID)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.NetworkInspect, y)
+
+	return err
+}
+
+func (client *ssntpClient) attachVolume(volID string, instanceID string, nodeID string, volumeType string) error {
 	payload := payloads.AttachVolume{
 		Attach: payloads.VolumeCmd{
 			InstanceUUID:      instanceID,
 			VolumeUUID:        volID,
 			WorkloadAgentUUID: nodeID,
+			VolumeType:        volumeType,
 		},
 	}
 
@@ -716,6 +896,51 @@ func (client *ssntpClient) attachVolume(volID string, instanceID string, nodeID
 	return err
 }
 
+func (client *ssntpClient) detachVolume(volID string, instanceID string, nodeID string) error {
+	payload := payloads.DetachVolume{
+		Detach: payloads.VolumeCmd{
+			InstanceUUID:      instanceID,
+			VolumeUUID:        volID,
+			WorkloadAgentUUID: nodeID,
+		},
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("DetachVolume %s from %s\n", volID, instanceID)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.DetachVolume, y)
+
+	return err
+}
+
+func (client *ssntpClient) resizeVolume(volID string, instanceID string, nodeID string, size int) error {
+	payload := payloads.ResizeVolume{
+		Resize: payloads.ResizeVolumeCmd{
+			InstanceUUID:      instanceID,
+			VolumeUUID:        volID,
+			WorkloadAgentUUID: nodeID,
+			SizeGiB:           size,
+		},
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("ResizeVolume %s of %s to %d GiB\n", volID, instanceID, size)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.ResizeVolume, y)
+
+	return err
+}
+
 func (client *ssntpClient) ssntpClient() *ssntp.Client {
 	return &client.ssntp
 }
@@ -783,3 +1008,122 @@ func (client *ssntpClient) unMapExternalIP(t types.Tenant, m types.MappedIP) err
 	_, err = client.ssntp.SendCommand(ssntp.ReleasePublicIP, y)
 	return err
 }
+
+func (client *ssntpClient) setSecurityGroups(cmd payloads.SecurityGroupsCmd) error {
+	generation, err := client.ctl.ds.NextGeneration()
+	if err != nil {
+		return err
+	}
+	cmd.Generation = generation
+
+	payload := payloads.CommandSecurityGroups{
+		SecurityGroups: cmd,
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if cmd.NodeUUID != "" {
+		glog.Infof("Set security groups of tenant %s on node %s\n", cmd.TenantUUID, cmd.NodeUUID)
+	} else {
+		glog.Infof("Set security groups of tenant %s on CNCI %s\n", cmd.TenantUUID, cmd.ConcentratorUUID)
+	}
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.SecurityGroups, y)
+	return err
+}
+
+func (client *ssntpClient) setLoadBalancers(cmd payloads.LoadBalancersCmd) error {
+	generation, err := client.ctl.ds.NextGeneration()
+	if err != nil {
+		return err
+	}
+	cmd.Generation = generation
+
+	payload := payloads.CommandLoadBalancers{
+		LoadBalancers: cmd,
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Set load balancers of tenant %s on CNCI %s\n", cmd.TenantUUID, cmd.ConcentratorUUID)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.LoadBalancers, y)
+	return err
+}
+
+func (client *ssntpClient) setPortForwards(cmd payloads.PortForwardsCmd) error {
+	generation, err := client.ctl.ds.NextGeneration()
+	if err != nil {
+		return err
+	}
+	cmd.Generation = generation
+
+	payload := payloads.CommandPortForwards{
+		PortForwards: cmd,
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Set port forwards of tenant %s on CNCI %s\n", cmd.TenantUUID, cmd.ConcentratorUUID)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.PortForwards, y)
+	return err
+}
+
+func (client *ssntpClient) setTenantDNS(cmd payloads.TenantDNSCmd) error {
+	generation, err := client.ctl.ds.NextGeneration()
+	if err != nil {
+		return err
+	}
+	cmd.Generation = generation
+
+	payload := payloads.CommandTenantDNS{
+		TenantDNS: cmd,
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Set DNS records of tenant %s on CNCI %s\n", cmd.TenantUUID, cmd.ConcentratorUUID)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.TenantDNS, y)
+	return err
+}
+
+func (client *ssntpClient) setTenantRoutes(cmd payloads.TenantRoutesCmd) error {
+	generation, err := client.ctl.ds.NextGeneration()
+	if err != nil {
+		return err
+	}
+	cmd.Generation = generation
+
+	payload := payloads.CommandTenantRoutes{
+		TenantRoutes: cmd,
+	}
+
+	y, err := yaml.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Set routes of tenant %s on node %s\n", cmd.TenantUUID, cmd.NodeUUID)
+	glog.V(1).Info(string(y))
+
+	_, err = client.ssntp.SendCommand(ssntp.TenantRoutes, y)
+	return err
+}
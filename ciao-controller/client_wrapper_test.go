@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
 	"github.com/ciao-project/ciao/ssntp"
 )
 
@@ -95,6 +96,11 @@ func (client *ssntpClientWrapper) RestartInstance(i *types.Instance, w *types.Wo
 	return client.realClient.RestartInstance(i, w, t)
 }
 
+func (client *ssntpClientWrapper) MigrateInstance(i *types.Instance, w *types.Workload,
+	t *types.Tenant) error {
+	return client.realClient.MigrateInstance(i, w, t)
+}
+
 func (client *ssntpClientWrapper) EvacuateNode(nodeID string) error {
 	return client.realClient.EvacuateNode(nodeID)
 }
@@ -103,6 +109,34 @@ func (client *ssntpClientWrapper) RestoreNode(nodeID string) error {
 	return client.realClient.RestoreNode(nodeID)
 }
 
+func (client *ssntpClientWrapper) LabelNode(nodeID string, labels map[string]string) error {
+	return client.realClient.LabelNode(nodeID, labels)
+}
+
+func (client *ssntpClientWrapper) InspectNetwork(nodeID string, repair bool) error {
+	return client.realClient.InspectNetwork(nodeID, repair)
+}
+
+func (client *ssntpClientWrapper) setSecurityGroups(cmd payloads.SecurityGroupsCmd) error {
+	return client.realClient.setSecurityGroups(cmd)
+}
+
+func (client *ssntpClientWrapper) setLoadBalancers(cmd payloads.LoadBalancersCmd) error {
+	return client.realClient.setLoadBalancers(cmd)
+}
+
+func (client *ssntpClientWrapper) setPortForwards(cmd payloads.PortForwardsCmd) error {
+	return client.realClient.setPortForwards(cmd)
+}
+
+func (client *ssntpClientWrapper) setTenantDNS(cmd payloads.TenantDNSCmd) error {
+	return client.realClient.setTenantDNS(cmd)
+}
+
+func (client *ssntpClientWrapper) setTenantRoutes(cmd payloads.TenantRoutesCmd) error {
+	return client.realClient.setTenantRoutes(cmd)
+}
+
 func (client *ssntpClientWrapper) mapExternalIP(t types.Tenant, m types.MappedIP) error {
 	return client.realClient.mapExternalIP(t, m)
 }
@@ -111,8 +145,16 @@ func (client *ssntpClientWrapper) unMapExternalIP(t types.Tenant, m types.Mapped
 	return client.realClient.unMapExternalIP(t, m)
 }
 
-func (client *ssntpClientWrapper) attachVolume(volID string, instanceID string, nodeID string) error {
-	return client.realClient.attachVolume(volID, instanceID, nodeID)
+func (client *ssntpClientWrapper) attachVolume(volID string, instanceID string, nodeID string, volumeType string) error {
+	return client.realClient.attachVolume(volID, instanceID, nodeID, volumeType)
+}
+
+func (client *ssntpClientWrapper) detachVolume(volID string, instanceID string, nodeID string) error {
+	return client.realClient.detachVolume(volID, instanceID, nodeID)
+}
+
+func (client *ssntpClientWrapper) resizeVolume(volID string, instanceID string, nodeID string, size int) error {
+	return client.realClient.resizeVolume(volID, instanceID, nodeID, size)
 }
 
 func (client *ssntpClientWrapper) ssntpClient() *ssntp.Client {
@@ -20,6 +20,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"net"
+	"sort"
 	"sync"
 	"time"
 
@@ -479,6 +480,41 @@ func (c *CNCIManager) GetSubnetCNCI(subnet string) (*types.Instance, error) {
 	return cnci.instance, nil
 }
 
+// RouterSubnets returns the subnets of the tenant that have an active CNCI.
+// These are the subnets the distributed routers of the tenant connect.
+func (c *CNCIManager) RouterSubnets() []payloads.RouterSubnet {
+	var subnets []payloads.RouterSubnet
+
+	c.cnciLock.RLock()
+	defer c.cnciLock.RUnlock()
+
+	for _, cnci := range c.subnets {
+		if cnci.instance == nil || cnci.instance.IPAddress == "" ||
+			!instanceActive(cnci.instance) {
+			continue
+		}
+
+		subnetKey, err := c.ctrl.ds.GetSubnetKey(c.tenant, cnci.instance.Subnet)
+		if err != nil {
+			glog.Warningf("Unable to get key of subnet %s: %v", cnci.instance.Subnet, err)
+			continue
+		}
+
+		subnets = append(subnets, payloads.RouterSubnet{
+			Subnet:           cnci.instance.Subnet,
+			ConcentratorUUID: cnci.instance.ID,
+			ConcentratorIP:   cnci.instance.IPAddress,
+			SubnetKey:        subnetKey,
+		})
+	}
+
+	sort.Slice(subnets, func(i, j int) bool {
+		return subnets[i].Subnet < subnets[j].Subnet
+	})
+
+	return subnets
+}
+
 func (c *CNCIManager) getInstanceCount(subnet string) (int, error) {
 	var count int
 
@@ -491,6 +527,12 @@ func (c *CNCIManager) getInstanceCount(subnet string) (int, error) {
 		if i.Subnet == subnet {
 			count++
 		}
+
+		for _, vnic := range i.Vnics {
+			if vnic.Subnet == subnet {
+				count++
+			}
+		}
 	}
 
 	return count, nil
@@ -21,6 +21,8 @@ import (
 	"time"
 
 	"github.com/ciao-project/ciao/ciao-controller/types"
+	imageDatastore "github.com/ciao-project/ciao/ciao-image/datastore"
+	"github.com/ciao-project/ciao/openstack/image"
 	"github.com/ciao-project/ciao/payloads"
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
@@ -55,6 +57,171 @@ func (c *controller) restartInstance(instanceID string) error {
 	return nil
 }
 
+// resizeInstance gives a stopped instance the VCPUs and memory of another
+// workload, taking the difference from or returning it to the quota of the
+// instance's tenant.  The resources are used when the instance is next
+// restarted.  The workload must be of the same type as the one of the
+// instance and define both resources.  The state lock of the instance is
+// held throughout so that the instance cannot be resized twice at once.
+func (c *controller) resizeInstance(instanceID string, workloadID string) error {
+	i, err := c.ds.GetInstance(instanceID)
+	if err != nil {
+		return err
+	}
+
+	i.StateLock.Lock()
+	defer i.StateLock.Unlock()
+
+	if i.State != payloads.Exited {
+		return types.ErrInstanceNotStopped
+	}
+
+	wl, err := c.ds.GetWorkload(i.TenantID, i.WorkloadID)
+	if err != nil {
+		return err
+	}
+
+	flavor, err := c.ds.GetWorkload(i.TenantID, workloadID)
+	if err != nil {
+		return err
+	}
+
+	if flavor.VMType != wl.VMType ||
+		resourceValue(flavor.Defaults, payloads.VCPUs) <= 0 ||
+		resourceValue(flavor.Defaults, payloads.MemMB) <= 0 {
+		return types.ErrInvalidFlavor
+	}
+
+	current := instanceResources(i, &wl)
+
+	var resized, consumed, released []payloads.RequestedResource
+	for _, t := range []payloads.Resource{payloads.VCPUs, payloads.MemMB} {
+		before := resourceValue(current, t)
+		after := resourceValue(flavor.Defaults, t)
+
+		resized = append(resized, payloads.RequestedResource{Type: t, Value: after, Mandatory: true})
+
+		if after > before {
+			consumed = append(consumed, payloads.RequestedResource{Type: t, Value: after - before})
+		} else if after < before {
+			released = append(released, payloads.RequestedResource{Type: t, Value: before - after})
+		}
+	}
+
+	if len(consumed) > 0 {
+		res := <-c.qs.Consume(i.TenantID, consumed...)
+		if !res.Allowed() {
+			c.qs.Release(i.TenantID, res.Resources()...)
+			return types.ErrQuota
+		}
+	}
+
+	err = c.ds.ResizeInstance(i.ID, resized)
+	if err != nil {
+		c.qs.Release(i.TenantID, consumed...)
+		return err
+	}
+
+	c.qs.Release(i.TenantID, released...)
+
+	return nil
+}
+
+func resourceValue(resources []payloads.RequestedResource, t payloads.Resource) int {
+	for _, r := range resources {
+		if r.Type == t {
+			return r.Value
+		}
+	}
+
+	return 0
+}
+
+// snapshotInstance copies the boot volume of a stopped instance into a new
+// private image of the instance's tenant and returns the ID of the image.
+// Instances started from an image boot from a clone of it, so this also
+// captures their root filesystem.  The copy is snapshotted the way the raw
+// datastore stores uploaded images, so new instances can be booted from it.
+// Boot volumes of a named volume type are stored by another block driver
+// than the images and cannot be snapshotted to images.
+func (c *controller) snapshotInstance(instanceID string, name string) (string, error) {
+	i, err := c.ds.GetInstance(instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	if i.State != payloads.Exited {
+		return "", types.ErrInstanceNotStopped
+	}
+
+	var bootID string
+	for _, a := range c.ds.GetStorageAttachments(i.ID) {
+		if a.Boot {
+			bootID = a.BlockID
+			break
+		}
+	}
+
+	if bootID == "" {
+		return "", types.ErrNoBootVolume
+	}
+
+	// the image service only serves images stored by the default block
+	// driver
+	bd, err := c.ds.GetBlockDevice(bootID)
+	if err != nil {
+		return "", err
+	}
+
+	if bd.VolumeType != "" {
+		return "", types.ErrBootVolumeType
+	}
+
+	res := <-c.qs.Consume(i.TenantID, payloads.RequestedResource{Type: payloads.Image, Value: 1})
+	if !res.Allowed() {
+		c.qs.Release(i.TenantID, res.Resources()...)
+		return "", types.ErrQuota
+	}
+
+	device, err := c.CopyBlockDevice(bootID)
+	if err != nil {
+		c.qs.Release(i.TenantID, res.Resources()...)
+		return "", errors.Wrap(err, "error copying boot volume")
+	}
+
+	err = c.CreateBlockDeviceSnapshot(device.ID, "ciao-image")
+	if err != nil {
+		c.DeleteBlockDevice(device.ID)
+		c.qs.Release(i.TenantID, res.Resources()...)
+		return "", errors.Wrap(err, "error snapshotting boot volume")
+	}
+
+	size, err := c.GetBlockDeviceSize(device.ID)
+	if err == nil {
+		err = c.is.ds.CreateImage(imageDatastore.Image{
+			ID:         device.ID,
+			State:      imageDatastore.Active,
+			TenantID:   i.TenantID,
+			Name:       name,
+			CreateTime: time.Now(),
+			Type:       imageDatastore.Raw,
+			Size:       size,
+			Visibility: image.Private,
+		})
+	}
+
+	if err != nil {
+		c.DeleteBlockDeviceSnapshot(device.ID, "ciao-image")
+		c.DeleteBlockDevice(device.ID)
+		c.qs.Release(i.TenantID, res.Resources()...)
+		return "", errors.Wrap(err, "error creating image")
+	}
+
+	glog.Infof("Instance %s snapshotted to image %s", i.ID, device.ID)
+
+	return device.ID, nil
+}
+
 func (c *controller) stopInstance(instanceID string) error {
 	// get node id.  If there is no node id we can't send a delete
 	i, err := c.ds.GetInstance(instanceID)
@@ -136,6 +303,12 @@ func (c *controller) deleteInstance(instanceID string) error {
 		}
 	}
 
+	for _, pf := range c.ds.GetPortForwards(i.TenantID) {
+		if pf.InstanceID == instanceID {
+			return types.ErrInstanceMapped
+		}
+	}
+
 	go c.client.DeleteInstance(instanceID, i.NodeID)
 	return nil
 }
@@ -222,7 +395,35 @@ func (c *controller) startWorkload(w types.WorkloadRequest) ([]*types.Instance,
 		return nil, err
 	}
 
+	var group *types.ServerGroup
+	if w.ServerGroup != "" {
+		g, err := c.ds.GetServerGroup(w.TenantID, w.ServerGroup)
+		if err != nil {
+			return nil, err
+		}
+		group = &g
+	}
+
+	var securityGroups []string
+	var networks []types.TenantNetwork
+	if !isCNCIWorkload(&wl) {
+		securityGroups, err = c.resolveSecurityGroups(w.TenantID, w.SecurityGroups)
+		if err != nil {
+			return nil, err
+		}
+
+		networks, err = c.resolveTenantNetworks(w.TenantID, w.Networks)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(networks) > 1 && wl.VMType == payloads.Docker {
+			return nil, errors.New("Containers can only be attached to one network")
+		}
+	}
+
 	var newInstances []*types.Instance
+	var started []*instance
 
 	for i := 0; i < w.Instances && e == nil; i++ {
 		startTime := time.Now()
@@ -234,12 +435,13 @@ func (c *controller) startWorkload(w types.WorkloadRequest) ([]*types.Instance,
 			}
 		}
 
-		instance, err := newInstance(c, w.TenantID, &wl, w.Volumes, name, w.Subnet)
+		instance, err := newInstance(c, w.TenantID, &wl, w.Volumes, name, w.Subnet, group, networks)
 		if err != nil {
 			e = errors.Wrap(err, "Error creating instance")
 			continue
 		}
 		instance.startTime = startTime
+		instance.securityGroups = securityGroups
 
 		ok, err := instance.Allowed()
 		if err != nil {
@@ -257,11 +459,7 @@ func (c *controller) startWorkload(w types.WorkloadRequest) ([]*types.Instance,
 			}
 
 			newInstances = append(newInstances, instance.Instance)
-			if w.TraceLabel == "" {
-				go c.client.StartWorkload(instance.newConfig.config)
-			} else {
-				go c.client.StartTracedWorkload(instance.newConfig.config, instance.startTime, w.TraceLabel)
-			}
+			started = append(started, instance)
 		} else {
 			instance.Clean()
 			// stop if we are over limits
@@ -270,6 +468,23 @@ func (c *controller) startWorkload(w types.WorkloadRequest) ([]*types.Instance,
 		}
 	}
 
+	// the compute nodes need the rules of the instances before they run
+	if len(newInstances) > 0 && len(securityGroups) > 0 {
+		c.updateSecurityGroups(w.TenantID)
+	}
+
+	for _, instance := range started {
+		if w.TraceLabel == "" {
+			go c.client.StartWorkload(instance.newConfig.config)
+		} else {
+			go c.client.StartTracedWorkload(instance.newConfig.config, instance.startTime, w.TraceLabel)
+		}
+	}
+
+	if len(newInstances) > 0 && w.Name != "" {
+		c.updateTenantDNS(w.TenantID)
+	}
+
 	return newInstances, e
 }
 
@@ -287,11 +502,15 @@ func (c *controller) deleteEphemeralStorage(instanceID string) error {
 		if err != nil {
 			return errors.Wrap(err, "Error getting block device from datastore")
 		}
+		driver, err := c.volumeDriver(bd.VolumeType)
+		if err != nil {
+			return errors.Wrap(err, "Error getting block driver of volume")
+		}
 		err = c.ds.DeleteBlockDevice(attachment.BlockID)
 		if err != nil {
 			return errors.Wrap(err, "Error deleting block device from datastore")
 		}
-		err = c.DeleteBlockDevice(attachment.BlockID)
+		err = driver.DeleteBlockDevice(attachment.BlockID)
 		if err != nil {
 			return errors.Wrap(err, "Error deleting block device")
 		}
@@ -15,11 +15,14 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"reflect"
@@ -1049,3 +1052,98 @@ func testTraceData(t *testing.T, httpExpectedStatus int, validToken bool) {
 func TestTraceData(t *testing.T) {
 	testTraceData(t, http.StatusOK, true)
 }
+
+func TestServerConsole(t *testing.T) {
+	tenant, err := ctl.ds.GetTenant(testutil.ComputeUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servers := testCreateServer(t, 1)
+
+	url := testutil.ComputeURL + "/v2.1/" + tenant.ID + "/servers/" +
+		servers.Servers[0].ID + "/console"
+	_ = testHTTPRequest(t, "GET", url, http.StatusBadRequest, nil, true)
+
+	console, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer console.Close()
+
+	i, err := ctl.ds.GetInstance(servers.Servers[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i.ConsoleType = payloads.SerialConsole
+	i.ConsoleIP = "127.0.0.1"
+	i.ConsolePort = console.Addr().(*net.TCPAddr).Port
+	i.ConsoleToken = "token"
+
+	// the console proxy of the launcher accepts the token of the
+	// instance before echoing the console stream.
+	go func() {
+		conn, err := console.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		auth, err := r.ReadString('\n')
+		if err != nil || auth != i.ID+" token\n" {
+			return
+		}
+
+		_, _ = io.WriteString(conn, consoleAuthOK)
+		_, _ = io.Copy(conn, r)
+	}()
+
+	clientCertFile := "/etc/pki/ciao/auth-admin.pem"
+	cert, err := tls.LoadX509KeyPair(clientCertFile, clientCertFile)
+	if err != nil {
+		t.Fatalf("Unable to load client certiticate: %s", err)
+	}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", consoleUpgrade)
+
+	conn, err := tls.Dial("tcp", req.URL.Host, &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	err = req.Write(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected: %d, got: %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+
+	_, err = conn.Write([]byte("ping\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if line != "ping\n" {
+		t.Fatalf("expected ping from console, got %q", line)
+	}
+}
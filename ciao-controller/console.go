@@ -0,0 +1,184 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/gorilla/mux"
+)
+
+// consoleUpgrade is the protocol a client asks to switch to, through the
+// Upgrade header, to be connected to the console of an instance.
+const consoleUpgrade = "tcp"
+
+const consoleDialTimeout = 5 * time.Second
+
+// consoleAuthOK is the reply of the console proxy of a launcher to a
+// successful authentication.
+const consoleAuthOK = "OK\n"
+
+// consoleHandler proxies the console of an instance to the client.  The
+// console is reached through the console proxy of the launcher running the
+// instance, which it authenticates to with the token the launcher reported
+// for the console.  The HTTP connection is hijacked once the console has
+// been reached and carries the raw console stream in both directions until
+// either end closes it.
+type consoleHandler struct {
+	*controller
+}
+
+func consoleError(w http.ResponseWriter, status int, message string) {
+	code := HTTPReturnErrorCode{
+		Error: HTTPErrorData{
+			Code:    status,
+			Name:    http.StatusText(status),
+			Message: message,
+		},
+	}
+
+	b, err := json.Marshal(code)
+	if err != nil {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	http.Error(w, string(b), status)
+}
+
+func (h consoleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	tenant := vars["tenant"]
+	server := vars["server"]
+
+	if !strings.EqualFold(r.Header.Get("Upgrade"), consoleUpgrade) {
+		consoleError(w, http.StatusBadRequest,
+			fmt.Sprintf("Expected Upgrade: %s header", consoleUpgrade))
+		return
+	}
+
+	i, err := h.ds.GetTenantInstance(tenant, server)
+	if err != nil {
+		consoleError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if i.ConsolePort == 0 || i.ConsoleToken == "" {
+		consoleError(w, http.StatusConflict, "Instance has no console")
+		return
+	}
+
+	addr := net.JoinHostPort(i.ConsoleIP, strconv.Itoa(i.ConsolePort))
+
+	backend, err := net.DialTimeout("tcp", addr, consoleDialTimeout)
+	if err != nil {
+		glog.Warningf("Unable to connect to console of %s at %s: %v", server, addr, err)
+		consoleError(w, http.StatusBadGateway, "Unable to connect to instance console")
+		return
+	}
+
+	err = consoleAuthenticate(backend, i.ID, i.ConsoleToken)
+	if err != nil {
+		backend.Close()
+		glog.Warningf("Unable to authenticate to console of %s at %s: %v", server, addr, err)
+		consoleError(w, http.StatusBadGateway, "Unable to connect to instance console")
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		backend.Close()
+		consoleError(w, http.StatusInternalServerError, "Connection cannot be upgraded")
+		return
+	}
+
+	conn, buf, err := hijacker.Hijack()
+	if err != nil {
+		backend.Close()
+		glog.Errorf("Unable to hijack console connection: %v", err)
+		return
+	}
+
+	fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\nConnection: Upgrade\r\nUpgrade: %s\r\n\r\n",
+		http.StatusSwitchingProtocols, http.StatusText(http.StatusSwitchingProtocols),
+		consoleUpgrade)
+	if err := buf.Flush(); err != nil {
+		backend.Close()
+		conn.Close()
+		return
+	}
+
+	glog.Infof("Proxying console of %s at %s to %s", server, addr, r.RemoteAddr)
+
+	proxyConsole(conn, buf, backend)
+
+	glog.Infof("Console of %s closed", server)
+}
+
+// consoleAuthenticate sends the UUID of an instance and its console token to
+// the console proxy of a launcher, and waits for it to accept them.
+func consoleAuthenticate(backend net.Conn, instanceID string, token string) error {
+	err := backend.SetDeadline(time.Now().Add(consoleDialTimeout))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(backend, "%s %s\n", instanceID, token)
+	if err != nil {
+		return err
+	}
+
+	reply := make([]byte, len(consoleAuthOK))
+	_, err = io.ReadFull(backend, reply)
+	if err != nil {
+		return err
+	}
+
+	if string(reply) != consoleAuthOK {
+		return fmt.Errorf("Unexpected reply %q", reply)
+	}
+
+	return backend.SetDeadline(time.Time{})
+}
+
+// proxyConsole copies data between the client and the console until one of
+// them closes its connection.  The client is read through r, which may hold
+// data it sent after its request.
+func proxyConsole(client net.Conn, r io.Reader, backend net.Conn) {
+	doneCh := make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(backend, r)
+		doneCh <- struct{}{}
+	}()
+
+	go func() {
+		_, _ = io.Copy(client, backend)
+		doneCh <- struct{}{}
+	}()
+
+	<-doneCh
+	client.Close()
+	backend.Close()
+	<-doneCh
+}
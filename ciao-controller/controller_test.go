@@ -34,6 +34,7 @@ import (
 	"github.com/ciao-project/ciao/ciao-controller/utils"
 	"github.com/ciao-project/ciao/ciao-storage"
 	"github.com/ciao-project/ciao/openstack/block"
+	"github.com/ciao-project/ciao/openstack/image"
 	"github.com/ciao-project/ciao/payloads"
 	"github.com/ciao-project/ciao/ssntp"
 	"github.com/ciao-project/ciao/ssntp/uuid"
@@ -259,7 +260,7 @@ func BenchmarkNewConfig(b *testing.B) {
 	b.ResetTimer()
 	noVolumes := []storage.BlockDevice{}
 	for n := 0; n < b.N; n++ {
-		_, err := newConfig(ctl, &wls[0], id.String(), tenant.ID, noVolumes, fmt.Sprintf("test-%d", n))
+		_, err := newConfig(ctl, &wls[0], id.String(), tenant.ID, noVolumes, fmt.Sprintf("test-%d", n), nil, nil)
 		if err != nil {
 			b.Error(err)
 		}
@@ -505,49 +506,728 @@ func TestRestartInstance(t *testing.T) {
 	}
 }
 
+func TestResizeInstance(t *testing.T) {
+	var reason payloads.StartFailureReason
+
+	client, instances := testStartWorkload(t, 1, false, reason)
+	defer client.Shutdown()
+
+	sendStatsCmd(client, t)
+
+	wl, err := ctl.ds.GetWorkload(instances[0].TenantID, instances[0].WorkloadID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flavor := wl
+	flavor.ID = uuid.Generate().String()
+	flavor.Defaults = []payloads.RequestedResource{
+		{Type: payloads.VCPUs, Value: 4},
+		{Type: payloads.MemMB, Value: 1024},
+	}
+	err = ctl.ds.AddWorkload(flavor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctl.resizeInstance(instances[0].ID, flavor.ID)
+	if err != types.ErrInstanceNotStopped {
+		t.Fatalf("expected %v resizing running instance, got %v",
+			types.ErrInstanceNotStopped, err)
+	}
+
+	serverCh := server.AddCmdChan(ssntp.DELETE)
+	clientCh := client.AddCmdChan(ssntp.DELETE)
+
+	err = ctl.stopInstance(instances[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = server.GetCmdChanResult(serverCh, ssntp.DELETE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetCmdChanResult(clientCh, ssntp.DELETE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sendStopEvent(client, instances[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noMem := flavor
+	noMem.ID = uuid.Generate().String()
+	noMem.Defaults = []payloads.RequestedResource{
+		{Type: payloads.VCPUs, Value: 4},
+	}
+	otherType := flavor
+	otherType.ID = uuid.Generate().String()
+	otherType.VMType = payloads.Docker
+	if wl.VMType == payloads.Docker {
+		otherType.VMType = payloads.QEMU
+	}
+
+	for _, invalid := range []types.Workload{noMem, otherType} {
+		err = ctl.ds.AddWorkload(invalid)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ctl.resizeInstance(instances[0].ID, invalid.ID)
+		if err != types.ErrInvalidFlavor {
+			t.Fatalf("expected %v resizing to %v, got %v",
+				types.ErrInvalidFlavor, invalid, err)
+		}
+	}
+
+	err = ctl.resizeInstance(instances[0].ID, flavor.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i, err := ctl.ds.GetInstance(instances[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resources := instanceResources(i, &wl)
+	if resourceValue(resources, payloads.VCPUs) != 4 ||
+		resourceValue(resources, payloads.MemMB) != 1024 {
+		t.Fatalf("instance not resized: %v", resources)
+	}
+}
+
+func TestSnapshotInstance(t *testing.T) {
+	var reason payloads.StartFailureReason
+
+	client, instances := testStartWorkload(t, 1, false, reason)
+	defer client.Shutdown()
+
+	sendStatsCmd(client, t)
+
+	tenantID := instances[0].TenantID
+
+	device, err := ctl.CreateBlockDevice("", "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := types.StorageResource{Bootable: true}
+	volume, err := addBlockDevice(ctl, tenantID, instances[0].ID, device, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ctl.ds.CreateStorageAttachment(instances[0].ID, volume)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ctl.snapshotInstance(instances[0].ID, "snapshot")
+	if err != types.ErrInstanceNotStopped {
+		t.Fatalf("expected %v snapshotting running instance, got %v",
+			types.ErrInstanceNotStopped, err)
+	}
+
+	serverCh := server.AddCmdChan(ssntp.DELETE)
+	clientCh := client.AddCmdChan(ssntp.DELETE)
+
+	err = ctl.stopInstance(instances[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = server.GetCmdChanResult(serverCh, ssntp.DELETE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetCmdChanResult(clientCh, ssntp.DELETE)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = sendStopEvent(client, instances[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imageID, err := ctl.snapshotInstance(instances[0].ID, "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := ctl.is.GetImage(tenantID, imageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *img.Name != "snapshot" || img.Status != image.Active {
+		t.Fatalf("unexpected image %s (%s)", *img.Name, img.Status)
+	}
+
+	_, err = ctl.is.DeleteImage(tenantID, imageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestEvacuateNode(t *testing.T) {
 	client, err := testutil.NewSsntpTestClientConnection("EvacuateNode", ssntp.AGENT, testutil.AgentUUID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer client.Shutdown()
+	defer client.Shutdown()
+
+	serverCh := server.AddCmdChan(ssntp.EVACUATE)
+
+	// ok to not send workload first?
+
+	err = ctl.EvacuateNode(client.UUID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := server.GetCmdChanResult(serverCh, ssntp.EVACUATE)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NodeUUID != client.UUID {
+		t.Fatal("Did not get node ID")
+	}
+}
+
+func TestRestoreNode(t *testing.T) {
+	client, err := testutil.NewSsntpTestClientConnection("RestoreNode", ssntp.AGENT, testutil.AgentUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Shutdown()
+
+	serverCh := server.AddCmdChan(ssntp.Restore)
+
+	err = ctl.RestoreNode(client.UUID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := server.GetCmdChanResult(serverCh, ssntp.Restore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NodeUUID != client.UUID {
+		t.Fatal("Did not get node ID")
+	}
+}
+
+func TestLabelNode(t *testing.T) {
+	client, err := testutil.NewSsntpTestClientConnection("LabelNode", ssntp.AGENT, testutil.AgentUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Shutdown()
+
+	serverCh := server.AddCmdChan(ssntp.LabelNode)
+
+	err = ctl.LabelNode(client.UUID, testutil.NodeLabels)
+	if err != nil {
+		t.Error(err)
+	}
+
+	result, err := server.GetCmdChanResult(serverCh, ssntp.LabelNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NodeUUID != client.UUID {
+		t.Fatal("Did not get node ID")
+	}
+}
+
+func TestInspectNodeNetwork(t *testing.T) {
+	client, err := testutil.NewSsntpTestClientConnection("InspectNodeNetwork", ssntp.AGENT, testutil.AgentUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Shutdown()
+
+	_, err = ctl.ShowNodeNetwork(client.UUID)
+	if err != types.ErrNodeNetworkNotFound {
+		t.Fatalf("Expected %v, got %v", types.ErrNodeNetworkNotFound, err)
+	}
+
+	_, err = ctl.InspectNodeNetwork(uuid.Generate().String(), false)
+	if err != types.ErrNodeNotFound {
+		t.Fatalf("Expected %v, got %v", types.ErrNodeNotFound, err)
+	}
+
+	ctl.ds.AddNode(client.UUID, payloads.ComputeNode)
+	defer func() { _ = ctl.ds.DeleteNode(client.UUID) }()
+
+	serverCh := server.AddCmdChan(ssntp.NetworkInspect)
+
+	n, err := ctl.InspectNodeNetwork(client.UUID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := server.GetCmdChanResult(serverCh, ssntp.NetworkInspect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.NodeUUID != client.UUID {
+		t.Fatal("Did not get node ID")
+	}
+
+	if n.NodeID != client.UUID || !n.Repair || len(n.Problems) != 1 || !n.Problems[0].Repaired {
+		t.Fatalf("Unexpected network report %+v", n)
+	}
+
+	last, err := ctl.ShowNodeNetwork(client.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(last, n) {
+		t.Fatalf("Unexpected last network report %+v", last)
+	}
+}
+
+func TestSecurityGroups(t *testing.T) {
+	var reason payloads.StartFailureReason
+
+	client, instances := testStartWorkload(t, 1, false, reason)
+	defer client.Shutdown()
+
+	tenantID := instances[0].TenantID
+
+	groups := ctl.ds.GetInstanceSecurityGroups(instances[0].ID)
+	if len(groups) != 1 || groups[0].Name != types.DefaultSecurityGroup {
+		t.Fatalf("Instance not in default security group: %v", groups)
+	}
+
+	group, err := ctl.CreateSecurityGroup(tenantID, types.SecurityGroupRequest{Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := types.SecurityGroupRule{
+		Direction:    payloads.Ingress,
+		Protocol:     "icmp",
+		PortRangeMin: 80,
+	}
+
+	_, err = ctl.CreateSecurityGroupRule(tenantID, group.ID, invalid)
+	if err != types.ErrBadRequest {
+		t.Fatalf("Invalid security group rule accepted: %v", err)
+	}
+
+	rule, err := ctl.CreateSecurityGroupRule(tenantID, group.ID, types.SecurityGroupRule{
+		Direction:     payloads.Ingress,
+		Protocol:      "tcp",
+		PortRangeMin:  80,
+		RemoteGroupID: groups[0].ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rule.PortRangeMax != 80 {
+		t.Fatalf("Expected port range max 80, got %d", rule.PortRangeMax)
+	}
+
+	group, err = ctl.ShowSecurityGroup(tenantID, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memberIPs := map[string][]string{groups[0].ID: {"172.16.0.2", "fd00:c1a0:0:1000::2", "172.16.0.3"}}
+	rules := instanceSecurityRules([]types.SecurityGroup{group}, memberIPs)
+	if len(rules) != 4 || rules[1].RemoteCIDR != "172.16.0.2/32" ||
+		rules[2].RemoteCIDR != "fd00:c1a0:0:1000::2/128" ||
+		rules[3].RemoteCIDR != "172.16.0.3/32" {
+		t.Fatalf("Remote group not expanded to its members: %v", rules)
+	}
+
+	err = ctl.DeleteSecurityGroup(tenantID, groups[0].ID)
+	if err != types.ErrSecurityGroupInUse {
+		t.Fatal("Deleted security group in use")
+	}
+
+	err = ctl.DeleteSecurityGroupRule(tenantID, group.ID, rule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctl.DeleteSecurityGroup(tenantID, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantSecurityRules(t *testing.T) {
+	web := types.SecurityGroup{
+		ID: "web",
+		Rules: []types.SecurityGroupRule{
+			{
+				Direction:     payloads.Ingress,
+				Protocol:      "tcp",
+				PortRangeMin:  80,
+				PortRangeMax:  80,
+				RemoteGroupID: "web",
+			},
+		},
+	}
+
+	instances := []*types.Instance{
+		{
+			ID:        "instance-a",
+			IPAddress: "172.16.0.2",
+			Subnet:    "172.16.0.0/24",
+			Vnics: []types.InstanceVnic{
+				{
+					Subnet:    "172.16.1.0/24",
+					IPAddress: "172.16.1.5",
+				},
+			},
+		},
+		{
+			ID:        "instance-b",
+			IPAddress: "172.16.1.2",
+			Subnet:    "172.16.1.0/24",
+		},
+	}
+
+	instanceGroups := map[string][]types.SecurityGroup{
+		"instance-a": {web},
+		"instance-b": {web},
+	}
+
+	subnets, all := tenantSecurityRules(instances, instanceGroups)
+	if len(all) != 3 {
+		t.Fatalf("Expected rules for 3 VNICs, got %d", len(all))
+	}
+
+	if len(subnets["172.16.0.0/24"]) != 1 || len(subnets["172.16.1.0/24"]) != 2 {
+		t.Fatalf("Unexpected rules per subnet: %v", subnets)
+	}
+
+	vnic := subnets["172.16.1.0/24"][0]
+	if vnic.InstanceUUID != "instance-a" || vnic.PrivateIP != "172.16.1.5" ||
+		vnic.PrivateIPv6 != tenantIPv6Addr("172.16.1.5", "172.16.1.0/24") {
+		t.Fatalf("Unexpected rules of extra VNIC: %+v", vnic)
+	}
+
+	remotes := make(map[string]bool)
+	for _, r := range vnic.Rules {
+		remotes[r.RemoteCIDR] = true
+	}
+
+	for _, ip := range []string{"172.16.0.2", "172.16.1.5", "172.16.1.2"} {
+		if !remotes[hostCIDR(ip)] {
+			t.Errorf("Member address %s missing from rules %v", ip, vnic.Rules)
+		}
+	}
+}
+
+func TestLoadBalancers(t *testing.T) {
+	var reason payloads.StartFailureReason
+
+	client, instances := testStartWorkload(t, 1, false, reason)
+	defer client.Shutdown()
+
+	tenantID := instances[0].TenantID
+
+	pool, err := ctl.AddPool("lbpool", nil, []string{"192.168.100.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := types.LoadBalancerRequest{
+		Name:     "web",
+		PoolName: pool.Name,
+		Protocol: "udp",
+		Port:     80,
+		Members:  []string{instances[0].ID},
+	}
+
+	_, err = ctl.CreateLoadBalancer(tenantID, req)
+	if err != types.ErrBadRequest {
+		t.Fatalf("Invalid load balancer accepted: %v", err)
+	}
+
+	req.Protocol = ""
+	lb, err := ctl.CreateLoadBalancer(tenantID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lb.ExternalIP != "192.168.100.1" || lb.Protocol != "tcp" || lb.MemberPort != 80 ||
+		lb.HealthCheckInterval != defaultHealthCheckInterval ||
+		lb.HealthCheckTimeout != defaultHealthCheckTimeout {
+		t.Fatalf("Unexpected load balancer %+v", lb)
+	}
+
+	err = ctl.AddLoadBalancerMember(tenantID, lb.ID, instances[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lb, err = ctl.ShowLoadBalancer(tenantID, lb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lb.Members) != 1 || lb.Members[0] != instances[0].ID {
+		t.Fatalf("Unexpected load balancer members %v", lb.Members)
+	}
+
+	err = ctl.DeleteLoadBalancerMember(tenantID, lb.ID, instances[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctl.DeleteLoadBalancer(tenantID, lb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ctl.ShowLoadBalancer(tenantID, lb.ID)
+	if err != types.ErrLoadBalancerNotFound {
+		t.Fatal("Load balancer not deleted")
+	}
+
+	err = ctl.DeletePool(pool.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPortForwards(t *testing.T) {
+	var reason payloads.StartFailureReason
+
+	client, instances := testStartWorkload(t, 2, false, reason)
+	defer client.Shutdown()
+
+	tenantID := instances[0].TenantID
+
+	pool, err := ctl.AddPool("pfpool", nil, []string{"192.168.101.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := types.PortForwardRequest{
+		PoolName:     &pool.Name,
+		Protocol:     "icmp",
+		ExternalPort: 2222,
+		InstanceID:   instances[0].ID,
+		InternalPort: 22,
+	}
+
+	_, err = ctl.CreatePortForward(tenantID, req)
+	if err != types.ErrBadRequest {
+		t.Fatalf("Invalid port forward accepted: %v", err)
+	}
+
+	req.Protocol = ""
+	ssh, err := ctl.CreatePortForward(tenantID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ssh.ExternalIP != "192.168.101.1" || ssh.Protocol != "tcp" ||
+		ssh.InternalIP != instances[0].IPAddress {
+		t.Fatalf("Unexpected port forward %+v", ssh)
+	}
+
+	req = types.PortForwardRequest{
+		ExternalIP:   ssh.ExternalIP,
+		ExternalPort: 80,
+		InstanceID:   instances[1].ID,
+	}
+
+	web, err := ctl.CreatePortForward(tenantID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if web.InternalPort != 80 || web.InternalIP != instances[1].IPAddress {
+		t.Fatalf("Unexpected port forward %+v", web)
+	}
+
+	err = ctl.deleteInstance(instances[0].ID)
+	if err != types.ErrInstanceMapped {
+		t.Fatalf("Deleted instance with forwarded ports: %v", err)
+	}
+
+	pfs, err := ctl.ListPortForwards(tenantID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pfs) != 2 {
+		t.Fatalf("Expected 2 port forwards, got %d", len(pfs))
+	}
+
+	for _, pf := range pfs {
+		err = ctl.DeletePortForward(tenantID, pf.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = ctl.ShowPortForward(tenantID, ssh.ID)
+	if err != types.ErrPortForwardNotFound {
+		t.Fatal("Port forward not deleted")
+	}
+
+	err = ctl.DeletePool(pool.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTenantNetworks(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	serverCh := server.AddCmdChan(ssntp.EVACUATE)
+	for _, cidr := range []string{"", "172.18.5.0/16", "10.0.0.0/24", "172.18.5.300/24"} {
+		req := types.TenantNetworkRequest{Name: "invalid", CIDR: cidr}
+		_, err = ctl.CreateTenantNetwork(tenant.ID, req)
+		if err != types.ErrBadRequest {
+			t.Fatalf("Invalid network CIDR %s accepted: %v", cidr, err)
+		}
+	}
 
-	// ok to not send workload first?
+	req := types.TenantNetworkRequest{Name: "backend", CIDR: "172.18.5.7/24"}
+	network, err := ctl.CreateTenantNetwork(tenant.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	err = ctl.EvacuateNode(client.UUID)
+	if network.CIDR != "172.18.5.0/24" {
+		t.Fatalf("Expected CIDR 172.18.5.0/24, got %s", network.CIDR)
+	}
+
+	_, err = ctl.CreateTenantNetwork(tenant.ID, req)
+	if err != types.ErrDuplicateTenantNetworkName {
+		t.Fatalf("Duplicate network name accepted: %v", err)
+	}
+
+	req = types.TenantNetworkRequest{Name: "frontend", CIDR: "172.18.5.0/24"}
+	_, err = ctl.CreateTenantNetwork(tenant.ID, req)
+	if err != types.ErrTenantSubnetInUse {
+		t.Fatalf("Network subnet in use accepted: %v", err)
+	}
+
+	networks, err := ctl.resolveTenantNetworks(tenant.ID, []string{"backend"})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	result, err := server.GetCmdChanResult(serverCh, ssntp.EVACUATE)
+	if len(networks) != 1 || networks[0].ID != network.ID {
+		t.Fatalf("Network not resolved by name: %v", networks)
+	}
+
+	_, err = ctl.resolveTenantNetworks(tenant.ID, []string{network.ID, "backend"})
+	if err == nil {
+		t.Fatal("Network requested twice accepted")
+	}
+
+	err = ctl.DeleteTenantNetwork(tenant.ID, network.ID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if result.NodeUUID != client.UUID {
-		t.Fatal("Did not get node ID")
+
+	_, err = ctl.ShowTenantNetwork(tenant.ID, network.ID)
+	if err != types.ErrTenantNetworkNotFound {
+		t.Fatalf("Deleted network still found: %v", err)
 	}
 }
 
-func TestRestoreNode(t *testing.T) {
-	client, err := testutil.NewSsntpTestClientConnection("RestoreNode", ssntp.AGENT, testutil.AgentUUID)
+func TestInstanceDNSRecord(t *testing.T) {
+	i := &types.Instance{
+		Name:      "web-0",
+		IPAddress: "172.16.0.2",
+		Subnet:    "172.16.0.0/24",
+		Vnics: []types.InstanceVnic{
+			{
+				Subnet:    "172.16.1.0/24",
+				IPAddress: "172.16.1.5",
+			},
+		},
+	}
+
+	tests := []struct {
+		subnet   string
+		ip       string
+		ipSubnet string
+	}{
+		{"172.16.0.0/24", "172.16.0.2", "172.16.0.0/24"},
+		{"172.16.1.0/24", "172.16.1.5", "172.16.1.0/24"},
+		{"172.16.2.0/24", "172.16.0.2", "172.16.0.0/24"},
+	}
+
+	for _, test := range tests {
+		record := instanceDNSRecord(i, test.subnet)
+		if record.Name != i.Name || record.IP != test.ip {
+			t.Errorf("Unexpected record on subnet %s: %+v", test.subnet, record)
+		}
+
+		if record.IPv6 == "" || record.IPv6 != tenantIPv6Addr(test.ip, test.ipSubnet) {
+			t.Errorf("Unexpected IPv6 address on subnet %s: %s", test.subnet, record.IPv6)
+		}
+	}
+}
+
+func TestTenantRouterNodes(t *testing.T) {
+	instances := []*types.Instance{
+		{NodeID: "node-b"},
+		{NodeID: "node-a"},
+		{NodeID: "node-b"},
+		{NodeID: "node-c", CNCI: true},
+		{NodeID: ""},
+	}
+
+	nodes := tenantRouterNodes(instances)
+	if !reflect.DeepEqual(nodes, []string{"node-a", "node-b"}) {
+		t.Fatalf("Unexpected router nodes %v", nodes)
+	}
+}
+
+func TestSetTenantRoutes(t *testing.T) {
+	client, err := testutil.NewSsntpTestClientConnection("SetTenantRoutes", ssntp.AGENT, testutil.AgentUUID)
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer client.Shutdown()
+	defer client.Ssntp.Close()
 
-	serverCh := server.AddCmdChan(ssntp.Restore)
+	serverCh := server.AddCmdChan(ssntp.TenantRoutes)
 
-	err = ctl.RestoreNode(client.UUID)
+	cmd := payloads.TenantRoutesCmd{
+		NodeUUID:   client.UUID,
+		TenantUUID: testutil.TenantUUID,
+		Subnets: []payloads.RouterSubnet{
+			{
+				Subnet:           testutil.TenantSubnet,
+				ConcentratorUUID: testutil.CNCIUUID,
+				ConcentratorIP:   testutil.CNCIIP,
+			},
+		},
+	}
+
+	err = ctl.client.setTenantRoutes(cmd)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	result, err := server.GetCmdChanResult(serverCh, ssntp.Restore)
+	result, err := server.GetCmdChanResult(serverCh, ssntp.TenantRoutes)
 	if err != nil {
 		t.Fatal(err)
 	}
+
 	if result.NodeUUID != client.UUID {
 		t.Fatal("Did not get node ID")
 	}
@@ -564,7 +1244,7 @@ func TestAttachVolume(t *testing.T) {
 
 	// ok to not send workload first?
 
-	err = ctl.client.attachVolume("volID", "instanceID", client.UUID)
+	err = ctl.client.attachVolume("volID", "instanceID", client.UUID, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -713,66 +1393,89 @@ func doDetachVolumeCommand(t *testing.T, fail bool) {
 	}
 
 	if data.State != types.InUse {
-		t.Fatalf("expected state %s, got %s\n", types.Detaching, data.State)
+		t.Fatalf("expected state %s, got %s\n", types.InUse, data.State)
 	}
 
-	if fail {
-		err := ctl.DetachVolume(tenantID, volume, "")
-		if err == nil {
-			t.Fatal("Expected error when detaching volume from active instance")
+	serverCh := server.AddCmdChan(ssntp.DetachVolume)
+	agentCh := client.AddCmdChan(ssntp.DetachVolume)
+	var serverErrorCh chan testutil.Result
+	var controllerCh chan struct{}
 
-		}
+	if fail == true {
+		serverErrorCh = server.AddErrorChan(ssntp.DetachVolumeFailure)
+		controllerCh = wrappedClient.addErrorChan(ssntp.DetachVolumeFailure)
+		client.DetachFail = true
+		client.DetachVolumeFailReason = payloads.DetachVolumeDetachFailure
 
-		data, err := ctl.ds.GetBlockDevice(volume)
-		if err != nil {
-			t.Fatal(err)
+		defer func() {
+			client.DetachFail = false
+			client.DetachVolumeFailReason = ""
+		}()
+	}
 
-		}
+	err = ctl.DetachVolume(tenantID, volume, "")
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		if data.State != types.InUse {
-			t.Fatalf("expected state %s, got %s\n", types.Detaching, data.State)
-		}
-	} else {
-		serverCh := server.AddCmdChan(ssntp.DELETE)
-		clientCh := client.AddCmdChan(ssntp.DELETE)
+	result, err := server.GetCmdChanResult(serverCh, ssntp.DetachVolume)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-		err := ctl.stopInstance(instanceID)
-		if err != nil {
-			t.Fatal(err)
-		}
+	if result.InstanceUUID != instanceID ||
+		result.NodeUUID != client.UUID ||
+		result.VolumeUUID != volume {
+		t.Fatalf("expected %s %s %s, got %s %s %s", instanceID, client.UUID, volume, result.InstanceUUID, result.NodeUUID, result.VolumeUUID)
+	}
 
-		result, err := server.GetCmdChanResult(serverCh, ssntp.DELETE)
-		if err != nil {
-			t.Fatal(err)
+	expected := types.Available
+
+	if fail == true {
+		_, err = client.GetCmdChanResult(agentCh, ssntp.DetachVolume)
+		if err == nil {
+			t.Fatal("Success when Failure expected")
 		}
 
-		_, err = client.GetCmdChanResult(clientCh, ssntp.DELETE)
+		_, err = server.GetErrorChanResult(serverErrorCh, ssntp.DetachVolumeFailure)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		if result.InstanceUUID != instanceID {
-			t.Fatal("Did not get correct Instance ID")
-		}
-
-		err = sendStopEvent(client, instanceID)
+		err = wrappedClient.getErrorChan(controllerCh, ssntp.DetachVolumeFailure)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		err = ctl.DetachVolume(tenantID, volume, "")
+		// the volume stays attached to the instance.
+		expected = types.InUse
+	} else {
+		_, err = client.GetCmdChanResult(agentCh, ssntp.DetachVolume)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		data, err := ctl.ds.GetBlockDevice(volume)
+		data, err = ctl.ds.GetBlockDevice(volume)
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		if data.State != types.Available {
+		if data.State != types.Detaching {
 			t.Fatalf("expected state %s, got %s\n", types.Detaching, data.State)
 		}
+
+		// the volume is available once the instance statistics
+		// no longer include it.
+		sendStatsCmd(client, t)
+	}
+
+	data, err = ctl.ds.GetBlockDevice(volume)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.State != expected {
+		t.Fatalf("expected state %s, got %s\n", expected, data.State)
 	}
 }
 
@@ -1336,7 +2039,7 @@ func TestStorageConfig(t *testing.T) {
 	id := uuid.Generate()
 
 	noVolumes := []storage.BlockDevice{}
-	_, err = newConfig(ctl, &wls[0], id.String(), tenant.ID, noVolumes, "test")
+	_, err = newConfig(ctl, &wls[0], id.String(), tenant.ID, noVolumes, "test", nil, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1382,6 +2085,71 @@ func TestCreateVolume(t *testing.T) {
 	}
 }
 
+func TestCreateVolumeType(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := block.RequestedVolume{
+		Size:       10,
+		VolumeType: testutil.VolumeType,
+	}
+
+	vol, err := ctl.CreateVolume(tenant.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if vol.VolumeType != testutil.VolumeType {
+		t.Fatalf("expected volume type %s, got %s", testutil.VolumeType, vol.VolumeType)
+	}
+
+	details, err := ctl.ShowVolumeDetails(tenant.ID, vol.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if details.VolumeType != testutil.VolumeType {
+		t.Fatalf("expected volume type %s, got %s", testutil.VolumeType, details.VolumeType)
+	}
+
+	// copies of a volume are of the type of the volume.
+	clone, err := ctl.CreateVolume(tenant.ID, block.RequestedVolume{SourceVolID: vol.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bd, err := ctl.ds.GetBlockDevice(clone.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bd.VolumeType != testutil.VolumeType {
+		t.Fatalf("expected volume type %s, got %s", testutil.VolumeType, bd.VolumeType)
+	}
+
+	invalid := []block.RequestedVolume{
+		{Size: 10, VolumeType: "unknown"},
+		{ImageRef: "test-image-id", VolumeType: testutil.VolumeType},
+		{SourceVolID: createTestVolume(tenant.ID, 10, t), VolumeType: testutil.VolumeType},
+	}
+
+	for _, req := range invalid {
+		_, err = ctl.CreateVolume(tenant.ID, req)
+		if err != block.ErrVolumeType {
+			t.Fatalf("expected ErrVolumeType for %+v, got %v", req, err)
+		}
+	}
+
+	for _, ID := range []string{clone.ID, vol.ID} {
+		err = ctl.DeleteVolume(tenant.ID, ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestCreateImageVolume(t *testing.T) {
 	tenant, err := addTestTenant()
 	if err != nil {
@@ -1511,6 +2279,195 @@ func TestListVolumesDetail(t *testing.T) {
 	}
 }
 
+func TestSnapshots(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volID := createTestVolume(tenant.ID, 20, t)
+
+	req := block.RequestedSnapshot{
+		VolumeID: volID,
+		Name:     "snap",
+	}
+
+	snap, err := ctl.CreateSnapshot(tenant.ID, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if snap.VolumeID != volID || snap.Size != 20 || snap.Status != block.Available ||
+		snap.Name != "snap" {
+		t.Fatalf("incorrect snapshot returned %v", snap)
+	}
+
+	// add second tenant to datastore to prevent CNCI launching.
+	tenant2, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ctl.CreateSnapshot(tenant2.ID, req)
+	if err != block.ErrVolumeOwner {
+		t.Fatalf("expected ErrVolumeOwner, got %v", err)
+	}
+
+	_, err = ctl.ShowSnapshot(tenant2.ID, snap.ID)
+	if err != block.ErrSnapshotOwner {
+		t.Fatalf("expected ErrSnapshotOwner, got %v", err)
+	}
+
+	snaps, err := ctl.ListSnapshots(tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 1 || snaps[0].ID != snap.ID {
+		t.Fatalf("incorrect snapshots listed %v", snaps)
+	}
+
+	// the volume cannot be deleted while it has snapshots
+	err = ctl.DeleteVolume(tenant.ID, volID)
+	if err != block.ErrVolumeHasSnapshots {
+		t.Fatalf("expected ErrVolumeHasSnapshots, got %v", err)
+	}
+
+	// restore the snapshot to a new volume
+	vol, err := ctl.CreateVolume(tenant.ID, block.RequestedVolume{SnapshotID: snap.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vol.Size != 20 || vol.SnapshotID != snap.ID {
+		t.Fatalf("incorrect volume restored %v", vol)
+	}
+
+	err = ctl.DeleteSnapshot(tenant.ID, snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ctl.ShowSnapshot(tenant.ID, snap.ID)
+	if err != block.ErrSnapshotNotFound {
+		t.Fatalf("expected ErrSnapshotNotFound, got %v", err)
+	}
+
+	err = ctl.DeleteVolume(tenant.ID, volID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSnapshotQuota(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volID := createTestVolume(tenant.ID, 20, t)
+
+	ctl.qs.Update(tenant.ID, []types.QuotaDetails{
+		{Name: "tenant-snapshot-storage-quota", Value: 10},
+	})
+
+	_, err = ctl.CreateSnapshot(tenant.ID, block.RequestedSnapshot{VolumeID: volID})
+	if err != block.ErrQuota {
+		t.Fatalf("expected ErrQuota, got %v", err)
+	}
+
+	if len(ctl.ds.GetVolumeSnapshots(volID)) != 0 {
+		t.Fatal("snapshot over quota was stored")
+	}
+}
+
+func TestExtendVolume(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	volID := createTestVolume(tenant.ID, 10, t)
+
+	err = ctl.ExtendVolume(tenant.ID, volID, 10)
+	if err != block.ErrVolumeSize {
+		t.Fatalf("expected ErrVolumeSize, got %v", err)
+	}
+
+	ctl.qs.Update(tenant.ID, []types.QuotaDetails{
+		{Name: "tenant-volume-size-limit", Value: 15},
+	})
+
+	err = ctl.ExtendVolume(tenant.ID, volID, 20)
+	if err != block.ErrQuota {
+		t.Fatalf("expected ErrQuota, got %v", err)
+	}
+
+	err = ctl.ExtendVolume(tenant.ID, volID, 15)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ctl.ds.GetBlockDevice(volID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if data.Size != 15 {
+		t.Fatalf("expected size 15, got %d", data.Size)
+	}
+
+	qds := ctl.qs.DumpQuotas(tenant.ID)
+	qd := findQuota(qds, "tenant-storage-quota")
+	if qd == nil || qd.Usage != 15 {
+		t.Fatalf("storage usage not updated %v", qd)
+	}
+
+	// a volume being extended cannot be extended again.
+	_, err = ctl.ds.UpdateBlockDeviceState(volID, types.Extending, types.Available)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ctl.ExtendVolume(tenant.ID, volID, 16)
+	if err != block.ErrVolumeNotAvailable {
+		t.Fatalf("expected ErrVolumeNotAvailable, got %v", err)
+	}
+}
+
+func TestExtendAttachedVolume(t *testing.T) {
+	client, tenant, volume, instance := doAttachVolumeCommand(t, false)
+	defer client.Ssntp.Close()
+
+	data, err := ctl.ds.GetBlockDevice(volume)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data.State = types.InUse
+	err = ctl.ds.UpdateBlockDevice(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCh := server.AddCmdChan(ssntp.ResizeVolume)
+
+	err = ctl.ExtendVolume(tenant, volume, data.Size+10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := server.GetCmdChanResult(serverCh, ssntp.ResizeVolume)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.InstanceUUID != instance ||
+		result.NodeUUID != client.UUID ||
+		result.VolumeUUID != volume {
+		t.Fatalf("expected %s %s %s, got %s %s %s", instance, client.UUID, volume,
+			result.InstanceUUID, result.NodeUUID, result.VolumeUUID)
+	}
+}
+
 func testAddPool(t *testing.T, name string, subnet *string, ips []string) {
 	pool, err := ctl.AddPool(name, subnet, ips)
 	if err != nil {
@@ -2079,6 +3036,10 @@ func TestMain(m *testing.M) {
 	ctl.BlockDriver = func() storage.BlockDriver {
 		return &storage.NoopDriver{}
 	}()
+	ctl.volumeTypes = storage.VolumeTypes{
+		"":                  ctl.BlockDriver,
+		testutil.VolumeType: &storage.NoopDriver{},
+	}
 
 	dir, err := ioutil.TempDir("", "controller_test")
 	if err != nil {
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -43,9 +44,11 @@ type config struct {
 
 type instance struct {
 	*types.Instance
-	newConfig config
-	ctl       *controller
-	startTime time.Time
+	newConfig      config
+	ctl            *controller
+	startTime      time.Time
+	serverGroup    string
+	securityGroups []string
 }
 
 type userData struct {
@@ -64,10 +67,15 @@ func isCNCIWorkload(workload *types.Workload) bool {
 }
 
 func newInstance(ctl *controller, tenantID string, workload *types.Workload,
-	volumes []storage.BlockDevice, name string, subnet string) (*instance, error) {
+	volumes []storage.BlockDevice, name string, subnet string,
+	group *types.ServerGroup, networks []types.TenantNetwork) (*instance, error) {
 	id := uuid.Generate()
 
 	if name != "" {
+		if !isCNCIWorkload(workload) && !validInstanceName(name) {
+			return nil, types.ErrInvalidInstanceName
+		}
+
 		existingID, err := ctl.ds.ResolveInstance(tenantID, name)
 		if err != nil {
 			return nil, errors.Wrap(err, "error trying to resolve name")
@@ -78,7 +86,7 @@ func newInstance(ctl *controller, tenantID string, workload *types.Workload,
 		}
 	}
 
-	config, err := newConfig(ctl, workload, id.String(), tenantID, volumes, name)
+	config, err := newConfig(ctl, workload, id.String(), tenantID, volumes, name, group, networks)
 	if err != nil {
 		return nil, err
 	}
@@ -102,12 +110,26 @@ func newInstance(ctl *controller, tenantID string, workload *types.Workload,
 		newInstance.Subnet = subnet
 	}
 
+	for k, extra := range config.sc.Start.ExtraNetworking {
+		newInstance.Vnics = append(newInstance.Vnics, types.InstanceVnic{
+			NetworkID:  networks[k+1].ID,
+			VnicUUID:   extra.VnicUUID,
+			MACAddress: extra.VnicMAC,
+			Subnet:     extra.Subnet,
+			IPAddress:  extra.PrivateIP,
+		})
+	}
+
 	i := &instance{
 		ctl:       ctl,
 		newConfig: config,
 		Instance:  &newInstance,
 	}
 
+	if group != nil {
+		i.serverGroup = group.ID
+	}
+
 	return i, nil
 }
 
@@ -119,6 +141,20 @@ func (i *instance) Add() error {
 		return errors.Wrapf(err, "Error creating instance in datastore")
 	}
 
+	if i.serverGroup != "" {
+		err = ds.AddServerGroupMember(i.serverGroup, i.Instance.ID)
+		if err != nil {
+			return errors.Wrap(err, "Error adding instance to server group")
+		}
+	}
+
+	for _, group := range i.securityGroups {
+		err = ds.AddSecurityGroupMember(group, i.Instance.ID)
+		if err != nil {
+			return errors.Wrap(err, "Error adding instance to security group")
+		}
+	}
+
 	for _, volume := range i.newConfig.sc.Start.Storage {
 		if volume.ID == "" && volume.Local {
 			// these are launcher auto-created ephemeral
@@ -145,6 +181,9 @@ func (i *instance) Clean() error {
 	}
 
 	i.ctl.ds.ReleaseTenantIP(i.TenantID, i.IPAddress)
+	for _, vnic := range i.Vnics {
+		i.ctl.ds.ReleaseTenantIP(i.TenantID, vnic.IPAddress)
+	}
 
 	wl, err := i.ctl.ds.GetWorkload(i.TenantID, i.WorkloadID)
 	if err != nil {
@@ -178,6 +217,29 @@ func (i *instance) Allowed() (bool, error) {
 	return res.Allowed(), nil
 }
 
+// instanceResources returns the resources of an instance, that is the
+// defaults of its workload overridden by those it has been resized to.
+func instanceResources(i *types.Instance, wl *types.Workload) []payloads.RequestedResource {
+	resources := make([]payloads.RequestedResource, len(wl.Defaults))
+	copy(resources, wl.Defaults)
+
+	for _, r := range i.Resources {
+		found := false
+		for k := range resources {
+			if resources[k].Type == r.Type {
+				resources[k].Value = r.Value
+				found = true
+			}
+		}
+
+		if !found {
+			resources = append(resources, r)
+		}
+	}
+
+	return resources
+}
+
 func transitionInstanceState(i *types.Instance, to string) error {
 	i.StateLock.Lock()
 	defer i.StateLock.Unlock()
@@ -215,6 +277,18 @@ func instanceActive(i *types.Instance) bool {
 }
 
 func addBlockDevice(c *controller, tenant string, instanceID string, device storage.BlockDevice, s types.StorageResource) (payloads.StorageResource, error) {
+	return addTypedBlockDevice(c, tenant, instanceID, device, "", s)
+}
+
+// addTypedBlockDevice records a volume of the given volume type created
+// for an instance, deleting it if it cannot be recorded.
+func addTypedBlockDevice(c *controller, tenant string, instanceID string, device storage.BlockDevice,
+	volumeType string, s types.StorageResource) (payloads.StorageResource, error) {
+	driver, err := c.volumeDriver(volumeType)
+	if err != nil {
+		return payloads.StorageResource{}, err
+	}
+
 	// don't you need to add support for indicating whether
 	// a block device is bootable.
 	data := types.BlockData{
@@ -224,6 +298,7 @@ func addBlockDevice(c *controller, tenant string, instanceID string, device stor
 		Name:        fmt.Sprintf("Storage for instance: %s", instanceID),
 		Description: s.Tag,
 		Internal:    s.Internal,
+		VolumeType:  volumeType,
 	}
 
 	if !data.Internal {
@@ -232,25 +307,30 @@ func addBlockDevice(c *controller, tenant string, instanceID string, device stor
 			payloads.RequestedResource{Type: payloads.SharedDiskGiB, Value: device.Size})
 
 		if !res.Allowed() {
-			c.DeleteBlockDevice(device.ID)
+			driver.DeleteBlockDevice(device.ID)
 			c.qs.Release(tenant, res.Resources()...)
 			return payloads.StorageResource{}, fmt.Errorf("Error creating volume: %s", res.Reason())
 		}
 	}
 
-	err := c.ds.AddBlockDevice(data)
+	err = c.ds.AddBlockDevice(data)
 	if err != nil {
-		c.DeleteBlockDevice(device.ID)
+		driver.DeleteBlockDevice(device.ID)
 		return payloads.StorageResource{}, err
 	}
 
-	return payloads.StorageResource{ID: data.ID, Bootable: s.Bootable, Ephemeral: s.Ephemeral}, nil
+	return payloads.StorageResource{ID: data.ID, Bootable: s.Bootable, Ephemeral: s.Ephemeral, VolumeType: volumeType}, nil
 }
 
 func getStorage(c *controller, s types.StorageResource, tenant string, instanceID string) (payloads.StorageResource, error) {
 	// storage already exists, use preexisting definition.
 	if s.ID != "" {
-		return payloads.StorageResource{ID: s.ID, Bootable: s.Bootable}, nil
+		bd, err := c.ds.GetBlockDevice(s.ID)
+		if err != nil {
+			return payloads.StorageResource{}, err
+		}
+
+		return payloads.StorageResource{ID: s.ID, Bootable: s.Bootable, VolumeType: bd.VolumeType}, nil
 	}
 
 	// new storage.
@@ -278,24 +358,39 @@ func getStorage(c *controller, s types.StorageResource, tenant string, instanceI
 	// assume always persistent for now.
 	// assume we have already checked quotas.
 	// ID of source is the image id.
+	// Images are stored by the default block driver.  Volumes are
+	// cloned by the block driver of their volume type.
 	var device storage.BlockDevice
 	var err error
+	var volumeType string
+	driver := c.BlockDriver
 	switch s.SourceType {
 	case types.ImageService:
-		device, err = c.CreateBlockDeviceFromSnapshot(s.SourceID, "ciao-image")
+		device, err = driver.CreateBlockDeviceFromSnapshot(s.SourceID, "ciao-image")
 		if err != nil {
 			glog.Errorf("Unable to get block device for image: %v", err)
 			return payloads.StorageResource{}, err
 		}
 
 	case types.VolumeService:
-		device, err = c.CopyBlockDevice(s.SourceID)
+		source, err := c.ds.GetBlockDevice(s.SourceID)
+		if err != nil {
+			return payloads.StorageResource{}, err
+		}
+
+		volumeType = source.VolumeType
+		driver, err = c.volumeDriver(volumeType)
+		if err != nil {
+			return payloads.StorageResource{}, err
+		}
+
+		device, err = driver.CopyBlockDevice(s.SourceID)
 		if err != nil {
 			return payloads.StorageResource{}, err
 		}
 
 	case types.Empty:
-		device, err = c.CreateBlockDevice("", "", s.Size)
+		device, err = driver.CreateBlockDevice("", "", s.Size)
 		if err != nil {
 			return payloads.StorageResource{}, err
 		}
@@ -305,15 +400,15 @@ func getStorage(c *controller, s types.StorageResource, tenant string, instanceI
 	}
 
 	if device.Size < s.Size {
-		device.Size, err = c.Resize(device.ID, s.Size)
+		device.Size, err = driver.Resize(device.ID, s.Size)
 	}
 
 	if err != nil {
-		c.DeleteBlockDevice(device.ID)
+		driver.DeleteBlockDevice(device.ID)
 		return payloads.StorageResource{}, errors.Wrap(err, "error resizing volume")
 	}
 
-	return addBlockDevice(c, tenant, instanceID, device, s)
+	return addTypedBlockDevice(c, tenant, instanceID, device, volumeType, s)
 }
 
 func controllerStorageResourceFromPayload(volume payloads.StorageResource) (s types.StorageResource) {
@@ -328,7 +423,11 @@ func controllerStorageResourceFromPayload(volume payloads.StorageResource) (s ty
 	return
 }
 
-func networkConfig(ctl *controller, tenant *types.Tenant, networking *payloads.NetworkResources, cnci bool) error {
+func networkConfig(ctl *controller, tenant *types.Tenant, networking *payloads.NetworkResources,
+	cnci bool, network *types.TenantNetwork) error {
+	var ipAddress net.IP
+	var err error
+
 	networking.VnicUUID = uuid.Generate().String()
 
 	if cnci {
@@ -341,7 +440,11 @@ func networkConfig(ctl *controller, tenant *types.Tenant, networking *payloads.N
 		return nil
 	}
 
-	ipAddress, err := ctl.ds.AllocateTenantIP(tenant.ID)
+	if network != nil {
+		ipAddress, err = ctl.ds.AllocateTenantNetworkIP(tenant.ID, network.ID)
+	} else {
+		ipAddress, err = ctl.ds.AllocateTenantIP(tenant.ID)
+	}
 	if err != nil {
 		fmt.Println("Unable to allocate IP address: ", err)
 		return err
@@ -358,6 +461,12 @@ func networkConfig(ctl *controller, tenant *types.Tenant, networking *payloads.N
 	}
 	networking.Subnet = ipnet.String()
 
+	subnetKey, err := ctl.ds.GetSubnetKey(tenant.ID, networking.Subnet)
+	if err != nil {
+		return err
+	}
+	networking.SubnetKey = strconv.Itoa(subnetKey)
+
 	cnciInstance, err := tenant.CNCIctrl.GetSubnetCNCI(networking.Subnet)
 	if err != nil {
 		return err
@@ -402,6 +511,12 @@ func storageConfig(ctl *controller, tenant *types.Tenant, instanceID string, vol
 			if err != nil {
 				return storage, err
 			}
+		} else if volume.ID != "" {
+			bd, err := ctl.ds.GetBlockDevice(volume.ID)
+			if err != nil {
+				return storage, err
+			}
+			instanceStorage.VolumeType = bd.VolumeType
 		} /* else {
 			// volume.ID != "": launcher will attach pre-existing volume
 			// volume.Local: launcher will create ephemeral volume
@@ -414,7 +529,8 @@ func storageConfig(ctl *controller, tenant *types.Tenant, instanceID string, vol
 }
 
 func newConfig(ctl *controller, wl *types.Workload, instanceID string, tenantID string,
-	volumes []storage.BlockDevice, name string) (config, error) {
+	volumes []storage.BlockDevice, name string, group *types.ServerGroup,
+	networks []types.TenantNetwork) (config, error) {
 	var metaData userData
 	var config config
 	var networking payloads.NetworkResources
@@ -431,11 +547,34 @@ func newConfig(ctl *controller, wl *types.Workload, instanceID string, tenantID
 		fmt.Println("unable to get tenant")
 	}
 
-	err = networkConfig(ctl, tenant, &networking, config.cnci)
+	// the first VNIC of an instance is attached to its first network
+	// and any other network gets an additional VNIC.
+	var network *types.TenantNetwork
+	if len(networks) > 0 && !config.cnci {
+		network = &networks[0]
+	}
+
+	err = networkConfig(ctl, tenant, &networking, config.cnci, network)
 	if err != nil {
 		return config, err
 	}
 
+	var extraNetworking []payloads.NetworkResources
+	for k := 1; k < len(networks) && !config.cnci; k++ {
+		var extra payloads.NetworkResources
+
+		err = networkConfig(ctl, tenant, &extra, false, &networks[k])
+		if err != nil {
+			ctl.ds.ReleaseTenantIP(tenantID, networking.PrivateIP)
+			for _, e := range extraNetworking {
+				ctl.ds.ReleaseTenantIP(tenantID, e.PrivateIP)
+			}
+			return config, err
+		}
+
+		extraNetworking = append(extraNetworking, extra)
+	}
+
 	metaData.Hostname = instanceID
 	if name != "" {
 		metaData.Hostname = name
@@ -468,13 +607,26 @@ func newConfig(ctl *controller, wl *types.Workload, instanceID string, tenantID
 		InstancePersistence: payloads.Host,
 		RequestedResources:  defaults,
 		Networking:          networking,
+		ExtraNetworking:     extraNetworking,
 		Storage:             storage,
 	}
 
+	if !config.cnci {
+		startCmd.RouterSubnets = tenantRouterSubnets(tenant)
+	}
+
 	if wl.VMType == payloads.Docker {
 		startCmd.DockerImage = wl.ImageName
 	}
 
+	if len(wl.NodeSelector) > 0 {
+		startCmd.NodeSelector = wl.NodeSelector
+	}
+
+	if group != nil {
+		startCmd.ServerGroup = ctl.serverGroupHint(*group, instanceID)
+	}
+
 	cmd := payloads.Start{
 		Start: startCmd,
 	}
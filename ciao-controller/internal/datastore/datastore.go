@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/ciao-controller/utils"
 	"github.com/ciao-project/ciao/payloads"
 	"github.com/ciao-project/ciao/ssntp"
 	"github.com/ciao-project/ciao/ssntp/uuid"
@@ -60,11 +61,12 @@ const (
 
 type tenant struct {
 	types.Tenant
-	network   map[int]map[int]bool
-	subnets   []int
-	instances map[string]*types.Instance
-	devices   map[string]types.BlockData
-	workloads []types.Workload
+	network    map[int]map[int]bool
+	subnets    []int
+	subnetKeys map[int]int
+	instances  map[string]*types.Instance
+	devices    map[string]types.BlockData
+	workloads  []types.Workload
 }
 
 type node struct {
@@ -104,6 +106,7 @@ type persistentStore interface {
 	addInstance(instance *types.Instance) (err error)
 	deleteInstance(instanceID string) (err error)
 	updateInstance(instance *types.Instance) (err error)
+	updateInstanceResources(instanceID string, resources []payloads.RequestedResource) (err error)
 
 	// interfaces related to statistics
 	addNodeStat(stat payloads.Stat) (err error)
@@ -122,6 +125,9 @@ type persistentStore interface {
 	addStorageAttachment(a types.StorageAttachment) error
 	getAllStorageAttachments() (map[string]types.StorageAttachment, error)
 	deleteStorageAttachment(ID string) error
+	addSnapshot(snap types.SnapshotData) error
+	deleteSnapshot(ID string) error
+	getSnapshots() (map[string]types.SnapshotData, error)
 
 	// external IP interfaces
 	addPool(pool types.Pool) error
@@ -136,6 +142,48 @@ type persistentStore interface {
 	// quotas
 	updateQuotas(tenantID string, qds []types.QuotaDetails) error
 	getQuotas(tenantID string) ([]types.QuotaDetails, error)
+
+	// server groups
+	addServerGroup(group types.ServerGroup) error
+	deleteServerGroup(ID string) error
+	getServerGroups() (map[string]types.ServerGroup, error)
+	addServerGroupMember(groupID string, instanceID string) error
+	deleteServerGroupMember(instanceID string) error
+
+	// security groups
+	addSecurityGroup(group types.SecurityGroup) error
+	deleteSecurityGroup(ID string) error
+	getSecurityGroups() (map[string]types.SecurityGroup, error)
+	addSecurityGroupRule(groupID string, rule types.SecurityGroupRule) error
+	deleteSecurityGroupRule(ID string) error
+	addSecurityGroupMember(groupID string, instanceID string) error
+	deleteSecurityGroupMembers(instanceID string) error
+
+	// tenant networks
+	addTenantNetwork(network types.TenantNetwork) error
+	deleteTenantNetwork(ID string) error
+	getTenantNetworks() (map[string]types.TenantNetwork, error)
+
+	// subnet keys
+	addSubnetKey(tenantID string, subnet int, key int) error
+	getSubnetKeys() (map[string]map[int]int, error)
+
+	// update generations
+	updateGeneration(generation int64) error
+	getGeneration() (int64, error)
+
+	// load balancers
+	addLoadBalancer(lb types.LoadBalancer) error
+	deleteLoadBalancer(ID string) error
+	getLoadBalancers() (map[string]types.LoadBalancer, error)
+	addLoadBalancerMember(lbID string, instanceID string) error
+	deleteLoadBalancerMember(lbID string, instanceID string) error
+	deleteLoadBalancerMembers(instanceID string) error
+
+	// port forwards
+	addPortForward(pf types.PortForward) error
+	deletePortForward(ID string) error
+	getPortForwards() (map[string]types.PortForward, error)
 }
 
 // Datastore provides context for the datastore package.
@@ -151,6 +199,14 @@ type Datastore struct {
 	tenants     map[string]*tenant
 	tenantsLock *sync.RWMutex
 
+	// tenant networks reserve subnets of their tenant, so they are
+	// protected by tenantsLock too.
+	tenantNetworks map[string]types.TenantNetwork
+
+	// subnetKeys holds the keys allocated to the subnets of all the
+	// tenants, it is protected by tenantsLock too.
+	subnetKeys map[int]bool
+
 	cnciWorkload types.Workload
 
 	nodes     map[string]*node
@@ -165,6 +221,10 @@ type Datastore struct {
 	blockDevices map[string]types.BlockData
 	bdLock       *sync.RWMutex
 
+	// snapshots belong to block devices, so they are protected by
+	// bdLock too.
+	snapshots map[string]types.SnapshotData
+
 	attachments     map[string]types.StorageAttachment
 	instanceVolumes map[attachment]string
 	attachLock      *sync.RWMutex
@@ -176,6 +236,27 @@ type Datastore struct {
 	externalIPs     map[string]bool
 	mappedIPs       map[string]types.MappedIP
 	poolsLock       *sync.RWMutex
+
+	// load balancers use external IPs of pools, so they are protected
+	// by poolsLock too.
+	loadBalancers   map[string]types.LoadBalancer
+	loadBalancerIPs map[string]string
+
+	// port forwards too.  portForwardIPs counts the forwarded ports of
+	// each external IP.
+	portForwards   map[string]types.PortForward
+	portForwardIPs map[string]int
+
+	serverGroups     map[string]types.ServerGroup
+	instanceGroups   map[string]string
+	serverGroupsLock *sync.RWMutex
+
+	securityGroups         map[string]types.SecurityGroup
+	instanceSecurityGroups map[string][]string
+	securityGroupsLock     *sync.RWMutex
+
+	generation     int64
+	generationLock *sync.Mutex
 }
 
 func (ds *Datastore) initExternalIPs() {
@@ -198,6 +279,163 @@ func (ds *Datastore) initExternalIPs() {
 	ds.mappedIPs = ds.db.getMappedIPs()
 }
 
+func (ds *Datastore) initLoadBalancers() error {
+	var err error
+
+	ds.loadBalancerIPs = make(map[string]string)
+
+	ds.loadBalancers, err = ds.db.getLoadBalancers()
+	if err != nil {
+		return err
+	}
+
+	for ID, lb := range ds.loadBalancers {
+		ds.loadBalancerIPs[lb.ExternalIP] = ID
+		lb.PoolName = ds.pools[lb.PoolID].Name
+		ds.loadBalancers[ID] = lb
+	}
+
+	return nil
+}
+
+func (ds *Datastore) initPortForwards() error {
+	var err error
+
+	ds.portForwardIPs = make(map[string]int)
+
+	ds.portForwards, err = ds.db.getPortForwards()
+	if err != nil {
+		return err
+	}
+
+	for ID, pf := range ds.portForwards {
+		ds.portForwardIPs[pf.ExternalIP]++
+		pf.PoolName = ds.pools[pf.PoolID].Name
+		ds.portForwards[ID] = pf
+	}
+
+	return nil
+}
+
+func (ds *Datastore) initServerGroups() error {
+	var err error
+
+	ds.serverGroupsLock = &sync.RWMutex{}
+	ds.instanceGroups = make(map[string]string)
+
+	ds.serverGroups, err = ds.db.getServerGroups()
+	if err != nil {
+		return err
+	}
+
+	for _, group := range ds.serverGroups {
+		for _, instanceID := range group.Members {
+			ds.instanceGroups[instanceID] = group.ID
+		}
+	}
+
+	return nil
+}
+
+func (ds *Datastore) initSecurityGroups() error {
+	var err error
+
+	ds.securityGroupsLock = &sync.RWMutex{}
+	ds.instanceSecurityGroups = make(map[string][]string)
+
+	ds.securityGroups, err = ds.db.getSecurityGroups()
+	if err != nil {
+		return err
+	}
+
+	for _, group := range ds.securityGroups {
+		for _, instanceID := range group.Members {
+			ds.instanceSecurityGroups[instanceID] = append(ds.instanceSecurityGroups[instanceID], group.ID)
+		}
+	}
+
+	return nil
+}
+
+// tenantSubnetInt returns the number by which the subnets of a tenant are
+// indexed, the second and third bytes of their 172.16.0.0/12 address.
+func tenantSubnetInt(cidr string) (int, error) {
+	ip, _, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid tenant subnet %s", cidr)
+	}
+
+	ipBytes := ip.To4()
+	if ipBytes == nil {
+		return 0, fmt.Errorf("invalid tenant subnet %s", cidr)
+	}
+
+	return int(binary.BigEndian.Uint16(ipBytes[1:3])), nil
+}
+
+// reserveTenantSubnet keeps a subnet from being used for the IP addresses
+// allocated by AllocateTenantIP.  Lock must be held by caller.
+func reserveTenantSubnet(t *tenant, subnetInt int) {
+	if _, ok := t.network[subnetInt]; !ok {
+		t.network[subnetInt] = make(map[int]bool)
+	}
+
+	for i, k := range t.subnets {
+		if k == subnetInt {
+			t.subnets = append(t.subnets[:i], t.subnets[i+1:]...)
+			break
+		}
+	}
+}
+
+func (ds *Datastore) initSubnetKeys() error {
+	ds.subnetKeys = make(map[int]bool)
+
+	keys, err := ds.db.getSubnetKeys()
+	if err != nil {
+		return err
+	}
+
+	for tenantID, subnetKeys := range keys {
+		t, ok := ds.tenants[tenantID]
+		if !ok {
+			continue
+		}
+
+		t.subnetKeys = subnetKeys
+		for _, key := range subnetKeys {
+			ds.subnetKeys[key] = true
+		}
+	}
+
+	return nil
+}
+
+func (ds *Datastore) initTenantNetworks() error {
+	var err error
+
+	ds.tenantNetworks, err = ds.db.getTenantNetworks()
+	if err != nil {
+		return err
+	}
+
+	for _, network := range ds.tenantNetworks {
+		t := ds.tenants[network.TenantID]
+		if t == nil {
+			continue
+		}
+
+		subnetInt, err := tenantSubnetInt(network.CIDR)
+		if err != nil {
+			return err
+		}
+
+		reserveTenantSubnet(t, subnetInt)
+	}
+
+	return nil
+}
+
 // Init initializes the private data for the Datastore object.
 // The sql tables are populated with initial data from csv
 // files if this is the first time the database has been
@@ -251,6 +489,16 @@ func (ds *Datastore) Init(config Config) error {
 		ds.tenants[tenants[i].ID] = tenants[i]
 	}
 
+	err = ds.initTenantNetworks()
+	if err != nil {
+		return errors.Wrap(err, "error getting tenant networks from database")
+	}
+
+	err = ds.initSubnetKeys()
+	if err != nil {
+		return errors.Wrap(err, "error getting subnet keys from database")
+	}
+
 	ds.nodesLock = &sync.RWMutex{}
 	ds.nodes = make(map[string]*node)
 
@@ -288,6 +536,11 @@ func (ds *Datastore) Init(config Config) error {
 
 	ds.bdLock = &sync.RWMutex{}
 
+	ds.snapshots, err = ds.db.getSnapshots()
+	if err != nil {
+		return errors.Wrap(err, "error getting snapshots from database")
+	}
+
 	ds.attachments, err = ds.db.getAllStorageAttachments()
 	if err != nil {
 		return errors.Wrap(err, "error getting storage attachments from database")
@@ -308,6 +561,32 @@ func (ds *Datastore) Init(config Config) error {
 
 	ds.initExternalIPs()
 
+	err = ds.initLoadBalancers()
+	if err != nil {
+		return errors.Wrap(err, "error getting load balancers from database")
+	}
+
+	err = ds.initPortForwards()
+	if err != nil {
+		return errors.Wrap(err, "error getting port forwards from database")
+	}
+
+	err = ds.initServerGroups()
+	if err != nil {
+		return errors.Wrap(err, "error getting server groups from database")
+	}
+
+	err = ds.initSecurityGroups()
+	if err != nil {
+		return errors.Wrap(err, "error getting security groups from database")
+	}
+
+	ds.generationLock = &sync.Mutex{}
+	ds.generation, err = ds.db.getGeneration()
+	if err != nil {
+		return errors.Wrap(err, "error getting generation from database")
+	}
+
 	return nil
 }
 
@@ -354,6 +633,10 @@ func (ds *Datastore) DeleteTenant(ID string) error {
 		return ErrNoTenant
 	}
 
+	for _, key := range ds.tenants[ID].subnetKeys {
+		delete(ds.subnetKeys, key)
+	}
+
 	delete(ds.tenants, ID)
 
 	return ds.db.deleteTenant(ID)
@@ -566,6 +849,37 @@ func (ds *Datastore) UpdateInstance(instance *types.Instance) error {
 	return ds.db.updateInstance(instance)
 }
 
+// ResizeInstance stores the resources an instance has been resized to.
+// They override the defaults of the instance's workload.
+func (ds *Datastore) ResizeInstance(instanceID string, resources []payloads.RequestedResource) error {
+	err := ds.db.updateInstanceResources(instanceID, resources)
+	if err != nil {
+		return errors.Wrap(err, "Error updating instance resources in database")
+	}
+
+	ds.instancesLock.Lock()
+	i, ok := ds.instances[instanceID]
+	if ok {
+		i.Resources = resources
+	}
+	ds.instancesLock.Unlock()
+
+	if !ok {
+		return types.ErrInstanceNotFound
+	}
+
+	ds.tenantsLock.Lock()
+	tenant := ds.tenants[i.TenantID]
+	if tenant != nil {
+		if ti, ok := tenant.instances[instanceID]; ok {
+			ti.Resources = resources
+		}
+	}
+	ds.tenantsLock.Unlock()
+
+	return nil
+}
+
 // GetAllTenants returns all the tenants from the datastore.
 func (ds *Datastore) GetAllTenants() ([]*types.Tenant, error) {
 	var tenants []*types.Tenant
@@ -606,13 +920,17 @@ func (ds *Datastore) ReleaseTenantIP(tenantID string, ip string) error {
 		i = int(subnetInt)
 
 		if len(network[i]) == 0 {
-			// delete the network map and the subnet
-			delete(ds.tenants[tenantID].network, i)
-
-			if len(subnets) > 1 {
-				ds.tenants[tenantID].subnets = append(subnets[:i], subnets[i+1:]...)
-			} else {
-				ds.tenants[tenantID].subnets = nil
+			// the subnets of tenant networks stay reserved until
+			// the networks are deleted.
+			if !ds.isTenantNetworkSubnet(tenantID, i) {
+				// delete the network map and the subnet
+				delete(ds.tenants[tenantID].network, i)
+
+				if len(subnets) > 1 {
+					ds.tenants[tenantID].subnets = append(subnets[:i], subnets[i+1:]...)
+				} else {
+					ds.tenants[tenantID].subnets = nil
+				}
 			}
 
 			removeSubnet = true
@@ -638,8 +956,9 @@ func getMaxHost(bits int) (int, error) {
 	}
 	ones, bits := ipNet.Mask.Size()
 
-	// deduct .0 and .1, and .255
-	maxHosts := (1 << uint32(bits-ones)) - 3
+	// deduct .0 and .1, .254 which is the address of the distributed
+	// router of the tenant, and .255
+	maxHosts := (1 << uint32(bits-ones)) - 4
 	if maxHosts <= 0 {
 		return -1, errors.Wrapf(err, "Invalid tenant config")
 	}
@@ -647,6 +966,71 @@ func getMaxHost(bits int) (int, error) {
 	return maxHosts, nil
 }
 
+// NextGeneration returns the generation of the next update replacing some
+// state of a tenant on its CNCIs or compute nodes.  The last generation is
+// stored in the database so that generations keep increasing across
+// restarts of the controller.
+func (ds *Datastore) NextGeneration() (int64, error) {
+	ds.generationLock.Lock()
+	defer ds.generationLock.Unlock()
+
+	err := ds.db.updateGeneration(ds.generation + 1)
+	if err != nil {
+		return 0, errors.Wrap(err, "error updating generation in database")
+	}
+	ds.generation++
+
+	return ds.generation, nil
+}
+
+// maxSubnetKey is the largest subnet key, the largest VXLAN network
+// identifier.
+const maxSubnetKey = 1<<24 - 1
+
+// GetSubnetKey returns the key of the tunnels of a subnet of a tenant,
+// allocating it on first use.  Tenants allocate their subnets from the
+// same range so keys are allocated across the cluster, they are what
+// keeps the traffic of the tenants apart.
+func (ds *Datastore) GetSubnetKey(tenantID string, subnet string) (int, error) {
+	subnetInt, err := tenantSubnetInt(subnet)
+	if err != nil {
+		return 0, err
+	}
+
+	ds.tenantsLock.Lock()
+	defer ds.tenantsLock.Unlock()
+
+	t, ok := ds.tenants[tenantID]
+	if !ok {
+		return 0, ErrNoTenant
+	}
+
+	if key, ok := t.subnetKeys[subnetInt]; ok {
+		return key, nil
+	}
+
+	for key := 1; key <= maxSubnetKey; key++ {
+		if ds.subnetKeys[key] {
+			continue
+		}
+
+		err = ds.db.addSubnetKey(tenantID, subnetInt, key)
+		if err != nil {
+			return 0, errors.Wrap(err, "error adding subnet key to database")
+		}
+
+		if t.subnetKeys == nil {
+			t.subnetKeys = make(map[int]int)
+		}
+		t.subnetKeys[subnetInt] = key
+		ds.subnetKeys[key] = true
+
+		return key, nil
+	}
+
+	return 0, errors.New("Out of subnet keys")
+}
+
 // AllocateTenantIP will find a free IP address within a tenant network.
 // For now we make each tenant have unique subnets even though it
 // isn't actually needed because of a docker issue.
@@ -721,7 +1105,7 @@ func (ds *Datastore) AllocateTenantIP(tenantID string) (net.IP, error) {
 			break
 		}
 
-		if rest == 255 {
+		if rest == 253 {
 			// this should never happen
 			glog.Warning("ran out of host numbers")
 
@@ -1037,6 +1421,47 @@ func (ds *Datastore) AttachVolumeFailure(instanceID string, volumeID string, rea
 	return errors.Wrap(ds.db.logEvent(e), "Error logging event")
 }
 
+// DetachVolumeFailure will clean up after a failure to detach a volume.
+// The volume state will be changed back to in use, and an error message
+// will be logged.
+func (ds *Datastore) DetachVolumeFailure(instanceID string, volumeID string, reason payloads.DetachVolumeFailureReason) error {
+	// update the block data to reflect correct state
+	data, err := ds.GetBlockDevice(volumeID)
+	if err != nil {
+		return errors.Wrapf(err, "error getting block device for volume (%v)", volumeID)
+	}
+
+	data.State = types.InUse
+	err = ds.UpdateBlockDevice(data)
+	if err != nil {
+		return errors.Wrapf(err, "error updating block device for volume (%v)", volumeID)
+	}
+
+	// get owner of this instance
+	i, err := ds.GetInstance(instanceID)
+	if err != nil {
+		return errors.Wrapf(err, "error getting instance (%v)", instanceID)
+	}
+
+	ds.nodesLock.Lock()
+	defer ds.nodesLock.Unlock()
+
+	n, ok := ds.nodes[i.NodeID]
+	if ok {
+		n.TotalFailures++
+	}
+
+	msg := fmt.Sprintf("Detach Volume Failure %s from %s: %s", volumeID, instanceID, reason.String())
+	e := types.LogEntry{
+		TenantID:  i.TenantID,
+		EventType: string(userError),
+		Message:   msg,
+		NodeID:    i.NodeID,
+	}
+
+	return errors.Wrap(ds.db.logEvent(e), "Error logging event")
+}
+
 func (ds *Datastore) deleteInstance(instanceID string) (string, error) {
 	if err := ds.db.deleteInstance(instanceID); err != nil {
 		glog.Warningf("error deleting instance (%v): %v", instanceID, err)
@@ -1079,10 +1504,40 @@ func (ds *Datastore) deleteInstance(instanceID string) (string, error) {
 				err = errors.Wrapf(err, "error releasing IP for instance (%v)", i.ID)
 			}
 		}
+
+		for _, vnic := range i.Vnics {
+			if tmpErr := ds.ReleaseTenantIP(i.TenantID, vnic.IPAddress); tmpErr != nil {
+				glog.Warningf("error releasing IP of VNIC %v for instance (%v): %v", vnic.VnicUUID, i.ID, tmpErr)
+				if err == nil {
+					err = errors.Wrapf(tmpErr, "error releasing IP for instance (%v)", i.ID)
+				}
+			}
+		}
 	}
 
 	ds.updateStorageAttachments(instanceID, nil)
 
+	if tmpErr := ds.removeServerGroupMember(instanceID); tmpErr != nil {
+		glog.Warningf("error removing instance (%v) from server group: %v", i.ID, tmpErr)
+		if err == nil {
+			err = tmpErr
+		}
+	}
+
+	if tmpErr := ds.removeSecurityGroupMember(instanceID); tmpErr != nil {
+		glog.Warningf("error removing instance (%v) from security groups: %v", i.ID, tmpErr)
+		if err == nil {
+			err = tmpErr
+		}
+	}
+
+	if tmpErr := ds.removeLoadBalancerMember(instanceID); tmpErr != nil {
+		glog.Warningf("error removing instance (%v) from load balancers: %v", i.ID, tmpErr)
+		if err == nil {
+			err = tmpErr
+		}
+	}
+
 	return i.TenantID, err
 }
 
@@ -1325,6 +1780,7 @@ func (ds *Datastore) addNodeStat(stat payloads.Stat) error {
 		StartFailures:        n.StartFailures,
 		AttachVolumeFailures: n.AttachVolumeFailures,
 		DeleteFailures:       n.DeleteFailures,
+		Labels:               stat.Labels,
 	}
 
 	ds.nodesLock.Unlock()
@@ -1465,10 +1921,21 @@ func (ds *Datastore) addInstanceStats(stats []payloads.InstanceStat, nodeID stri
 		instance, ok := ds.instances[stat.InstanceUUID]
 		if ok {
 			instance.State = stat.State
+			oldNodeID := instance.NodeID
 			instance.NodeID = nodeID
 			instance.SSHIP = stat.SSHIP
 			instance.SSHPort = stat.SSHPort
+			instance.ConsoleType = stat.ConsoleType
+			instance.ConsoleIP = stat.ConsoleIP
+			instance.ConsolePort = stat.ConsolePort
+			instance.ConsoleToken = stat.ConsoleToken
 			ds.nodesLock.Lock()
+			// the instance may have been migrated from another node
+			if oldNodeID != "" && oldNodeID != nodeID {
+				if n, ok := ds.nodes[oldNodeID]; ok {
+					delete(n.instances, instance.ID)
+				}
+			}
 			ds.nodes[nodeID].instances[instance.ID] = instance
 			ds.nodesLock.Unlock()
 		}
@@ -1720,19 +2187,148 @@ func (ds *Datastore) UpdateBlockDevice(data types.BlockData) error {
 	return errors.Wrapf(ds.AddBlockDevice(data), "error updating block device (%v)", data.ID)
 }
 
-// CreateStorageAttachment will associate an instance with a block device in
-// the datastore
-func (ds *Datastore) CreateStorageAttachment(instanceID string, volume payloads.StorageResource) (types.StorageAttachment, error) {
-	link := attachment{
-		instanceID: instanceID,
-		volumeID:   volume.ID,
+// UpdateBlockDeviceState changes the state of a block device to state,
+// provided it is in one of the states in from, and returns the block device
+// as it was before the change.  Only one of several concurrent callers can
+// move a block device out of a given state.
+func (ds *Datastore) UpdateBlockDeviceState(ID string, state types.BlockState, from ...types.BlockState) (types.BlockData, error) {
+	ds.bdLock.Lock()
+
+	data, ok := ds.blockDevices[ID]
+	if !ok {
+		ds.bdLock.Unlock()
+		return types.BlockData{}, ErrNoBlockData
 	}
 
-	a := types.StorageAttachment{
-		InstanceID: instanceID,
-		ID:         uuid.Generate().String(),
-		BlockID:    volume.ID,
-		Ephemeral:  volume.Ephemeral,
+	valid := false
+	for _, s := range from {
+		if data.State == s {
+			valid = true
+			break
+		}
+	}
+
+	if !valid {
+		ds.bdLock.Unlock()
+		return data, types.ErrBlockDeviceState
+	}
+
+	device := data
+	device.State = state
+
+	err := ds.db.updateBlockData(device)
+	if err != nil {
+		ds.bdLock.Unlock()
+		return data, errors.Wrap(err, "Error updating block data in database")
+	}
+
+	ds.blockDevices[ID] = device
+	ds.bdLock.Unlock()
+
+	// update tenants cache
+	ds.tenantsLock.Lock()
+	ds.tenants[device.TenantID].devices[ID] = device
+	ds.tenantsLock.Unlock()
+
+	return data, nil
+}
+
+// AddSnapshot stores a new snapshot of a block device in the datastore.
+func (ds *Datastore) AddSnapshot(snap types.SnapshotData) error {
+	ds.bdLock.Lock()
+	defer ds.bdLock.Unlock()
+
+	if _, ok := ds.blockDevices[snap.VolumeID]; !ok {
+		return ErrNoBlockData
+	}
+
+	err := ds.db.addSnapshot(snap)
+	if err != nil {
+		return errors.Wrap(err, "error adding snapshot to database")
+	}
+
+	ds.snapshots[snap.ID] = snap
+
+	return nil
+}
+
+// DeleteSnapshot deletes a snapshot from the datastore.
+func (ds *Datastore) DeleteSnapshot(ID string) error {
+	ds.bdLock.Lock()
+	defer ds.bdLock.Unlock()
+
+	if _, ok := ds.snapshots[ID]; !ok {
+		return types.ErrSnapshotNotFound
+	}
+
+	err := ds.db.deleteSnapshot(ID)
+	if err != nil {
+		return errors.Wrap(err, "error deleting snapshot from database")
+	}
+
+	delete(ds.snapshots, ID)
+
+	return nil
+}
+
+// GetSnapshot returns a snapshot from the datastore.
+func (ds *Datastore) GetSnapshot(ID string) (types.SnapshotData, error) {
+	ds.bdLock.RLock()
+	defer ds.bdLock.RUnlock()
+
+	snap, ok := ds.snapshots[ID]
+	if !ok {
+		return types.SnapshotData{}, types.ErrSnapshotNotFound
+	}
+
+	return snap, nil
+}
+
+// GetSnapshots returns all the snapshots belonging to a tenant.
+func (ds *Datastore) GetSnapshots(tenantID string) []types.SnapshotData {
+	var snaps []types.SnapshotData
+
+	ds.bdLock.RLock()
+	defer ds.bdLock.RUnlock()
+
+	for _, snap := range ds.snapshots {
+		if snap.TenantID == tenantID {
+			snaps = append(snaps, snap)
+		}
+	}
+
+	return snaps
+}
+
+// GetVolumeSnapshots returns all the snapshots of a block device.
+func (ds *Datastore) GetVolumeSnapshots(volumeID string) []types.SnapshotData {
+	var snaps []types.SnapshotData
+
+	ds.bdLock.RLock()
+	defer ds.bdLock.RUnlock()
+
+	for _, snap := range ds.snapshots {
+		if snap.VolumeID == volumeID {
+			snaps = append(snaps, snap)
+		}
+	}
+
+	return snaps
+}
+
+// CreateStorageAttachment will associate an instance with a block device in
+// the datastore
+func (ds *Datastore) CreateStorageAttachment(instanceID string, volume payloads.StorageResource) (types.StorageAttachment, error) {
+	link := attachment{
+		instanceID: instanceID,
+		volumeID:   volume.ID,
+	}
+
+	a := types.StorageAttachment{
+		InstanceID: instanceID,
+		ID:         uuid.Generate().String(),
+		BlockID:    volume.ID,
+		Ephemeral:  volume.Ephemeral,
 		Boot:       volume.Bootable,
 	}
 
@@ -2096,6 +2692,34 @@ func (ds *Datastore) DeletePool(ID string) error {
 	return err
 }
 
+// maxExternalSubnetBits is the largest number of host bits of an external
+// subnet, whose addresses are searched one by one when mapped.
+const maxExternalSubnetBits = 24
+
+// externalSubnetSize returns the number of addresses of an external subnet
+// which may be mapped: all but the network and broadcast addresses of an
+// IPv4 subnet, and all but the subnet-router anycast address of an IPv6
+// subnet.
+func externalSubnetSize(ipNet *net.IPNet) (int, error) {
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones > maxExternalSubnetBits {
+		return 0, types.ErrSubnetTooLarge
+	}
+
+	// intentionally do not support /32 here, user should add by IP address instead
+	// deduct gateway and broadcast
+	size := (1 << uint32(bits-ones)) - 2
+	if ipNet.IP.To4() == nil {
+		size++
+	}
+
+	if size <= 0 {
+		return 0, types.ErrSubnetTooSmall
+	}
+
+	return size, nil
+}
+
 // AddExternalSubnet will add a new subnet to an existing pool.
 func (ds *Datastore) AddExternalSubnet(poolID string, subnet string) error {
 	sub := types.ExternalSubnet{
@@ -2120,13 +2744,9 @@ func (ds *Datastore) AddExternalSubnet(poolID string, subnet string) error {
 		return types.ErrDuplicateSubnet
 	}
 
-	ones, bits := ipNet.Mask.Size()
-
-	// intentionally do not support /32 here, user should add by IP address instead
-	// deduct gateway and broadcast
-	newIPs := (1 << uint32(bits-ones)) - 2
-	if newIPs <= 0 {
-		return types.ErrSubnetTooSmall
+	newIPs, err := externalSubnetSize(ipNet)
+	if err != nil {
+		return err
 	}
 	p.TotalIPs += newIPs
 	p.Free += newIPs
@@ -2222,14 +2842,15 @@ func (ds *Datastore) DeleteSubnet(poolID string, subnetID string) error {
 
 		// check each address in this subnet is not mapped.
 		for IP := IP.Mask(ipNet.Mask); ipNet.Contains(IP); incrementIP(IP) {
-			_, ok := ds.mappedIPs[IP.String()]
-			if ok {
+			if ds.externalIPInUse(IP.String()) {
 				return types.ErrPoolNotEmpty
 			}
 		}
 
-		ones, bits := ipNet.Mask.Size()
-		numIPs := (1 << uint32(bits-ones)) - 2
+		numIPs, err := externalSubnetSize(ipNet)
+		if err != nil {
+			return err
+		}
 		p.TotalIPs -= numIPs
 		p.Free -= numIPs
 		p.Subnets = append(p.Subnets[:i], p.Subnets[i+1:]...)
@@ -2265,8 +2886,7 @@ func (ds *Datastore) DeleteExternalIP(poolID string, addrID string) error {
 
 		// this path will be taken only once.
 		// check address is not mapped.
-		_, ok := ds.mappedIPs[extIP.Address]
-		if ok {
+		if ds.externalIPInUse(extIP.Address) {
 			return types.ErrPoolNotEmpty
 		}
 
@@ -2329,32 +2949,47 @@ func (ds *Datastore) GetMappedIP(address string) (types.MappedIP, error) {
 	return m, nil
 }
 
-// MapExternalIP will allocate an external IP to an instance from a given pool.
-func (ds *Datastore) MapExternalIP(poolID string, instanceID string) (types.MappedIP, error) {
-	var m types.MappedIP
-
-	instance, err := ds.GetInstance(instanceID)
+// instanceIPv6 returns the IPv6 address of an instance derived from its
+// tenant address, or an empty string if it has none.
+func instanceIPv6(instance *types.Instance) string {
+	_, subnet, err := net.ParseCIDR(instance.Subnet)
 	if err != nil {
-		return m, errors.Wrapf(err, "error getting instance (%v)", instanceID)
+		return ""
 	}
 
-	ds.poolsLock.Lock()
-	defer ds.poolsLock.Unlock()
-
-	pool, ok := ds.pools[poolID]
-	if !ok {
-		return m, types.ErrPoolNotFound
+	ip := utils.TenantIPv6Addr(net.ParseIP(instance.IPAddress), subnet)
+	if ip == nil {
+		return ""
 	}
 
+	return ip.String()
+}
+
+// externalIPInUse returns true if an external IP is mapped to an instance,
+// used by a load balancer or has forwarded ports.  Lock must be held by
+// caller.
+func (ds *Datastore) externalIPInUse(address string) bool {
+	_, mapped := ds.mappedIPs[address]
+	_, balanced := ds.loadBalancerIPs[address]
+	return mapped || balanced || ds.portForwardIPs[address] > 0
+}
+
+// freeExternalIP returns the first address of a pool which is not in use and
+// which usable accepts.  Lock must be held by caller.
+func (ds *Datastore) freeExternalIP(pool types.Pool, usable func(IP net.IP) bool) (string, error) {
 	if pool.Free == 0 {
-		return m, types.ErrPoolEmpty
+		return "", types.ErrPoolEmpty
 	}
 
 	// find a free IP address in any subnet.
 	for _, sub := range pool.Subnets {
 		IP, ipNet, err := net.ParseCIDR(sub.CIDR)
 		if err != nil {
-			return m, errors.Wrapf(err, "error parsing subnet CIDR (%v)", sub.CIDR)
+			return "", errors.Wrapf(err, "error parsing subnet CIDR (%v)", sub.CIDR)
+		}
+
+		if !usable(IP) {
+			continue
 		}
 
 		initIP := IP.Mask(ipNet.Mask)
@@ -2364,70 +2999,90 @@ func (ds *Datastore) MapExternalIP(poolID string, instanceID string) (types.Mapp
 
 		// check each address in this subnet
 		for IP := initIP; ipNet.Contains(IP); incrementIP(IP) {
-			_, ok := ds.mappedIPs[IP.String()]
-			if !ok {
-				m.ID = uuid.Generate().String()
-				m.ExternalIP = IP.String()
-				m.InternalIP = instance.IPAddress
-				m.InstanceID = instanceID
-				m.TenantID = instance.TenantID
-				m.PoolID = pool.ID
-				m.PoolName = pool.Name
-
-				pool.Free--
-
-				err = ds.db.addMappedIP(m)
-				if err != nil {
-					return types.MappedIP{}, errors.Wrap(err, "error adding IP mapping to database")
-				}
-				ds.mappedIPs[IP.String()] = m
-
-				err = ds.db.updatePool(pool)
-				if err != nil {
-					return types.MappedIP{}, errors.Wrap(err, "error updating pool in database")
-				}
-
-				ds.pools[poolID] = pool
-
-				return m, nil
+			if !ds.externalIPInUse(IP.String()) {
+				return IP.String(), nil
 			}
 		}
 	}
 
 	// we are still looking. Check our individual IPs
 	for _, IP := range pool.IPs {
-		_, ok := ds.mappedIPs[IP.Address]
-		if !ok {
-			m.ID = uuid.Generate().String()
-			m.ExternalIP = IP.Address
-			m.InternalIP = instance.IPAddress
-			m.InstanceID = instanceID
-			m.TenantID = instance.TenantID
-			m.PoolID = pool.ID
-			m.PoolName = pool.Name
+		if !usable(net.ParseIP(IP.Address)) {
+			continue
+		}
 
-			pool.Free--
+		if !ds.externalIPInUse(IP.Address) {
+			return IP.Address, nil
+		}
+	}
 
-			err = ds.db.addMappedIP(m)
-			if err != nil {
-				return types.MappedIP{}, errors.Wrap(err, "error adding IP mapping to database")
-			}
-			ds.mappedIPs[IP.Address] = m
+	// if you got here you are out of luck. But you never should.
+	glog.Warningf("Pool reports %d free addresses but none found", pool.Free)
+	return "", types.ErrPoolEmpty
+}
 
-			err = ds.db.updatePool(pool)
-			if err != nil {
-				return types.MappedIP{}, errors.Wrap(err, "error updating pool in database")
-			}
+// instanceInternalIP returns the address of an instance of the same family
+// as an external IP, or an empty string if it has none.
+func instanceInternalIP(instance *types.Instance, external net.IP) string {
+	if external.To4() != nil {
+		return instance.IPAddress
+	}
+	return instanceIPv6(instance)
+}
 
-			ds.pools[poolID] = pool
+// MapExternalIP will allocate an external IP to an instance from a given pool.
+// IPv6 external IPs are mapped to the IPv6 address of the instance.
+func (ds *Datastore) MapExternalIP(poolID string, instanceID string) (types.MappedIP, error) {
+	var m types.MappedIP
 
-			return m, nil
-		}
+	instance, err := ds.GetInstance(instanceID)
+	if err != nil {
+		return m, errors.Wrapf(err, "error getting instance (%v)", instanceID)
 	}
 
-	// if you got here you are out of luck. But you never should.
-	glog.Warningf("Pool reports %d free addresses but none found", pool.Free)
-	return m, types.ErrPoolEmpty
+	internalIP := func(external net.IP) string {
+		return instanceInternalIP(instance, external)
+	}
+
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	pool, ok := ds.pools[poolID]
+	if !ok {
+		return m, types.ErrPoolNotFound
+	}
+
+	address, err := ds.freeExternalIP(pool, func(IP net.IP) bool {
+		return internalIP(IP) != ""
+	})
+	if err != nil {
+		return m, err
+	}
+
+	m.ID = uuid.Generate().String()
+	m.ExternalIP = address
+	m.InternalIP = internalIP(net.ParseIP(address))
+	m.InstanceID = instanceID
+	m.TenantID = instance.TenantID
+	m.PoolID = pool.ID
+	m.PoolName = pool.Name
+
+	pool.Free--
+
+	err = ds.db.addMappedIP(m)
+	if err != nil {
+		return types.MappedIP{}, errors.Wrap(err, "error adding IP mapping to database")
+	}
+	ds.mappedIPs[address] = m
+
+	err = ds.db.updatePool(pool)
+	if err != nil {
+		return types.MappedIP{}, errors.Wrap(err, "error updating pool in database")
+	}
+
+	ds.pools[poolID] = pool
+
+	return m, nil
 }
 
 // UnMapExternalIP will stop associating a given address with an instance.
@@ -2550,3 +3205,914 @@ func (ds *Datastore) ResolveInstance(tenantID string, name string) (string, erro
 
 	return "", nil
 }
+
+// AddServerGroup stores a new server group in the datastore.
+func (ds *Datastore) AddServerGroup(group types.ServerGroup) error {
+	ds.serverGroupsLock.Lock()
+	defer ds.serverGroupsLock.Unlock()
+
+	err := ds.db.addServerGroup(group)
+	if err != nil {
+		return errors.Wrap(err, "error adding server group to database")
+	}
+
+	group.Members = nil
+	ds.serverGroups[group.ID] = group
+
+	return nil
+}
+
+func copyServerGroup(group types.ServerGroup) types.ServerGroup {
+	group.Members = append([]string{}, group.Members...)
+	return group
+}
+
+// GetServerGroup returns the server group belonging to a tenant.
+func (ds *Datastore) GetServerGroup(tenantID string, ID string) (types.ServerGroup, error) {
+	ds.serverGroupsLock.RLock()
+	defer ds.serverGroupsLock.RUnlock()
+
+	group, ok := ds.serverGroups[ID]
+	if !ok || group.TenantID != tenantID {
+		return types.ServerGroup{}, types.ErrServerGroupNotFound
+	}
+
+	return copyServerGroup(group), nil
+}
+
+// GetServerGroups returns all the server groups belonging to a tenant.
+func (ds *Datastore) GetServerGroups(tenantID string) []types.ServerGroup {
+	var groups []types.ServerGroup
+
+	ds.serverGroupsLock.RLock()
+	defer ds.serverGroupsLock.RUnlock()
+
+	for _, group := range ds.serverGroups {
+		if group.TenantID == tenantID {
+			groups = append(groups, copyServerGroup(group))
+		}
+	}
+
+	return groups
+}
+
+// GetInstanceServerGroup returns the server group of which an instance is
+// a member, if any.
+func (ds *Datastore) GetInstanceServerGroup(instanceID string) (types.ServerGroup, bool) {
+	ds.serverGroupsLock.RLock()
+	defer ds.serverGroupsLock.RUnlock()
+
+	groupID, ok := ds.instanceGroups[instanceID]
+	if !ok {
+		return types.ServerGroup{}, false
+	}
+
+	return copyServerGroup(ds.serverGroups[groupID]), true
+}
+
+// DeleteServerGroup deletes an empty server group from the datastore.
+func (ds *Datastore) DeleteServerGroup(tenantID string, ID string) error {
+	ds.serverGroupsLock.Lock()
+	defer ds.serverGroupsLock.Unlock()
+
+	group, ok := ds.serverGroups[ID]
+	if !ok || group.TenantID != tenantID {
+		return types.ErrServerGroupNotFound
+	}
+
+	if len(group.Members) > 0 {
+		return types.ErrServerGroupInUse
+	}
+
+	err := ds.db.deleteServerGroup(ID)
+	if err != nil {
+		return errors.Wrapf(err, "error deleting server group (%v) from database", ID)
+	}
+
+	delete(ds.serverGroups, ID)
+
+	return nil
+}
+
+// AddServerGroupMember adds an instance to a server group.
+func (ds *Datastore) AddServerGroupMember(groupID string, instanceID string) error {
+	ds.serverGroupsLock.Lock()
+	defer ds.serverGroupsLock.Unlock()
+
+	group, ok := ds.serverGroups[groupID]
+	if !ok {
+		return types.ErrServerGroupNotFound
+	}
+
+	if _, ok := ds.instanceGroups[instanceID]; ok {
+		return fmt.Errorf("Instance %s is already a member of a server group", instanceID)
+	}
+
+	err := ds.db.addServerGroupMember(groupID, instanceID)
+	if err != nil {
+		return errors.Wrap(err, "error adding server group member to database")
+	}
+
+	group.Members = append(group.Members, instanceID)
+	ds.serverGroups[groupID] = group
+	ds.instanceGroups[instanceID] = groupID
+
+	return nil
+}
+
+func (ds *Datastore) removeServerGroupMember(instanceID string) error {
+	ds.serverGroupsLock.Lock()
+	defer ds.serverGroupsLock.Unlock()
+
+	groupID, ok := ds.instanceGroups[instanceID]
+	if !ok {
+		return nil
+	}
+
+	err := ds.db.deleteServerGroupMember(instanceID)
+	if err != nil {
+		return errors.Wrap(err, "error deleting server group member from database")
+	}
+
+	delete(ds.instanceGroups, instanceID)
+
+	group := ds.serverGroups[groupID]
+	for i, member := range group.Members {
+		if member == instanceID {
+			group.Members = append(group.Members[:i], group.Members[i+1:]...)
+			break
+		}
+	}
+	ds.serverGroups[groupID] = group
+
+	return nil
+}
+
+// AddSecurityGroup stores a new security group, and its rules, in the
+// datastore.  Security group names are unique within a tenant.
+func (ds *Datastore) AddSecurityGroup(group types.SecurityGroup) error {
+	ds.securityGroupsLock.Lock()
+	defer ds.securityGroupsLock.Unlock()
+
+	for _, g := range ds.securityGroups {
+		if g.TenantID == group.TenantID && g.Name == group.Name {
+			return types.ErrDuplicateSecurityGroupName
+		}
+	}
+
+	err := ds.db.addSecurityGroup(group)
+	if err != nil {
+		return errors.Wrap(err, "error adding security group to database")
+	}
+
+	for _, rule := range group.Rules {
+		err = ds.db.addSecurityGroupRule(group.ID, rule)
+		if err != nil {
+			return errors.Wrap(err, "error adding security group rule to database")
+		}
+	}
+
+	group.Members = nil
+	ds.securityGroups[group.ID] = copySecurityGroup(group)
+
+	return nil
+}
+
+func copySecurityGroup(group types.SecurityGroup) types.SecurityGroup {
+	group.Rules = append([]types.SecurityGroupRule{}, group.Rules...)
+	group.Members = append([]string{}, group.Members...)
+	return group
+}
+
+// GetSecurityGroup returns the security group belonging to a tenant.
+func (ds *Datastore) GetSecurityGroup(tenantID string, ID string) (types.SecurityGroup, error) {
+	ds.securityGroupsLock.RLock()
+	defer ds.securityGroupsLock.RUnlock()
+
+	group, ok := ds.securityGroups[ID]
+	if !ok || group.TenantID != tenantID {
+		return types.SecurityGroup{}, types.ErrSecurityGroupNotFound
+	}
+
+	return copySecurityGroup(group), nil
+}
+
+// GetSecurityGroups returns all the security groups belonging to a tenant.
+func (ds *Datastore) GetSecurityGroups(tenantID string) []types.SecurityGroup {
+	var groups []types.SecurityGroup
+
+	ds.securityGroupsLock.RLock()
+	defer ds.securityGroupsLock.RUnlock()
+
+	for _, group := range ds.securityGroups {
+		if group.TenantID == tenantID {
+			groups = append(groups, copySecurityGroup(group))
+		}
+	}
+
+	return groups
+}
+
+// GetInstanceSecurityGroups returns the security groups of which an instance
+// is a member.
+func (ds *Datastore) GetInstanceSecurityGroups(instanceID string) []types.SecurityGroup {
+	var groups []types.SecurityGroup
+
+	ds.securityGroupsLock.RLock()
+	defer ds.securityGroupsLock.RUnlock()
+
+	for _, groupID := range ds.instanceSecurityGroups[instanceID] {
+		groups = append(groups, copySecurityGroup(ds.securityGroups[groupID]))
+	}
+
+	return groups
+}
+
+// DeleteSecurityGroup deletes a security group, and its rules, from the
+// datastore.  The group must not have any members or be the remote group
+// of a rule of another group.
+func (ds *Datastore) DeleteSecurityGroup(tenantID string, ID string) error {
+	ds.securityGroupsLock.Lock()
+	defer ds.securityGroupsLock.Unlock()
+
+	group, ok := ds.securityGroups[ID]
+	if !ok || group.TenantID != tenantID {
+		return types.ErrSecurityGroupNotFound
+	}
+
+	if len(group.Members) > 0 {
+		return types.ErrSecurityGroupInUse
+	}
+
+	for _, g := range ds.securityGroups {
+		if g.ID == ID {
+			continue
+		}
+
+		for _, rule := range g.Rules {
+			if rule.RemoteGroupID == ID {
+				return types.ErrSecurityGroupInUse
+			}
+		}
+	}
+
+	err := ds.db.deleteSecurityGroup(ID)
+	if err != nil {
+		return errors.Wrapf(err, "error deleting security group (%v) from database", ID)
+	}
+
+	delete(ds.securityGroups, ID)
+
+	return nil
+}
+
+// AddSecurityGroupRule adds a rule to a security group.
+func (ds *Datastore) AddSecurityGroupRule(groupID string, rule types.SecurityGroupRule) error {
+	ds.securityGroupsLock.Lock()
+	defer ds.securityGroupsLock.Unlock()
+
+	group, ok := ds.securityGroups[groupID]
+	if !ok {
+		return types.ErrSecurityGroupNotFound
+	}
+
+	err := ds.db.addSecurityGroupRule(groupID, rule)
+	if err != nil {
+		return errors.Wrap(err, "error adding security group rule to database")
+	}
+
+	group.Rules = append(group.Rules, rule)
+	ds.securityGroups[groupID] = group
+
+	return nil
+}
+
+// DeleteSecurityGroupRule deletes a rule from a security group.
+func (ds *Datastore) DeleteSecurityGroupRule(tenantID string, groupID string, ID string) error {
+	ds.securityGroupsLock.Lock()
+	defer ds.securityGroupsLock.Unlock()
+
+	group, ok := ds.securityGroups[groupID]
+	if !ok || group.TenantID != tenantID {
+		return types.ErrSecurityGroupNotFound
+	}
+
+	for i, rule := range group.Rules {
+		if rule.ID != ID {
+			continue
+		}
+
+		err := ds.db.deleteSecurityGroupRule(ID)
+		if err != nil {
+			return errors.Wrapf(err, "error deleting security group rule (%v) from database", ID)
+		}
+
+		group.Rules = append(group.Rules[:i], group.Rules[i+1:]...)
+		ds.securityGroups[groupID] = group
+
+		return nil
+	}
+
+	return types.ErrSecurityGroupRuleNotFound
+}
+
+// AddSecurityGroupMember adds an instance to a security group.
+func (ds *Datastore) AddSecurityGroupMember(groupID string, instanceID string) error {
+	ds.securityGroupsLock.Lock()
+	defer ds.securityGroupsLock.Unlock()
+
+	group, ok := ds.securityGroups[groupID]
+	if !ok {
+		return types.ErrSecurityGroupNotFound
+	}
+
+	for _, ID := range ds.instanceSecurityGroups[instanceID] {
+		if ID == groupID {
+			return nil
+		}
+	}
+
+	err := ds.db.addSecurityGroupMember(groupID, instanceID)
+	if err != nil {
+		return errors.Wrap(err, "error adding security group member to database")
+	}
+
+	group.Members = append(group.Members, instanceID)
+	ds.securityGroups[groupID] = group
+	ds.instanceSecurityGroups[instanceID] = append(ds.instanceSecurityGroups[instanceID], groupID)
+
+	return nil
+}
+
+func (ds *Datastore) removeSecurityGroupMember(instanceID string) error {
+	ds.securityGroupsLock.Lock()
+	defer ds.securityGroupsLock.Unlock()
+
+	groupIDs, ok := ds.instanceSecurityGroups[instanceID]
+	if !ok {
+		return nil
+	}
+
+	err := ds.db.deleteSecurityGroupMembers(instanceID)
+	if err != nil {
+		return errors.Wrap(err, "error deleting security group members from database")
+	}
+
+	delete(ds.instanceSecurityGroups, instanceID)
+
+	for _, groupID := range groupIDs {
+		group := ds.securityGroups[groupID]
+		for i, member := range group.Members {
+			if member == instanceID {
+				group.Members = append(group.Members[:i], group.Members[i+1:]...)
+				break
+			}
+		}
+		ds.securityGroups[groupID] = group
+	}
+
+	return nil
+}
+
+// isTenantNetworkSubnet returns true if a subnet of a tenant is the subnet
+// of one of its tenant networks.  Lock must be held by caller.
+func (ds *Datastore) isTenantNetworkSubnet(tenantID string, subnetInt int) bool {
+	for _, network := range ds.tenantNetworks {
+		if network.TenantID != tenantID {
+			continue
+		}
+
+		k, err := tenantSubnetInt(network.CIDR)
+		if err == nil && k == subnetInt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddTenantNetwork stores a new tenant network and reserves its subnet.  The
+// subnet must not already be used by the tenant.
+func (ds *Datastore) AddTenantNetwork(network types.TenantNetwork) error {
+	subnetInt, err := tenantSubnetInt(network.CIDR)
+	if err != nil {
+		return err
+	}
+
+	ds.tenantsLock.Lock()
+	defer ds.tenantsLock.Unlock()
+
+	t, ok := ds.tenants[network.TenantID]
+	if !ok {
+		return ErrNoTenant
+	}
+
+	for _, n := range ds.tenantNetworks {
+		if n.TenantID == network.TenantID && n.Name == network.Name {
+			return types.ErrDuplicateTenantNetworkName
+		}
+	}
+
+	if _, ok := t.network[subnetInt]; ok {
+		return types.ErrTenantSubnetInUse
+	}
+
+	err = ds.db.addTenantNetwork(network)
+	if err != nil {
+		return errors.Wrap(err, "error adding tenant network to database")
+	}
+
+	network.Links = nil
+	ds.tenantNetworks[network.ID] = network
+	reserveTenantSubnet(t, subnetInt)
+
+	return nil
+}
+
+// GetTenantNetwork returns the tenant network belonging to a tenant.
+func (ds *Datastore) GetTenantNetwork(tenantID string, ID string) (types.TenantNetwork, error) {
+	ds.tenantsLock.RLock()
+	defer ds.tenantsLock.RUnlock()
+
+	network, ok := ds.tenantNetworks[ID]
+	if !ok || network.TenantID != tenantID {
+		return types.TenantNetwork{}, types.ErrTenantNetworkNotFound
+	}
+
+	return network, nil
+}
+
+// GetTenantNetworks returns all the tenant networks belonging to a tenant.
+func (ds *Datastore) GetTenantNetworks(tenantID string) []types.TenantNetwork {
+	var networks []types.TenantNetwork
+
+	ds.tenantsLock.RLock()
+	defer ds.tenantsLock.RUnlock()
+
+	for _, network := range ds.tenantNetworks {
+		if network.TenantID == tenantID {
+			networks = append(networks, network)
+		}
+	}
+
+	return networks
+}
+
+// DeleteTenantNetwork removes a tenant network and releases its subnet.
+// Instances must not be attached to the network anymore.
+func (ds *Datastore) DeleteTenantNetwork(tenantID string, ID string) error {
+	ds.tenantsLock.Lock()
+	defer ds.tenantsLock.Unlock()
+
+	network, ok := ds.tenantNetworks[ID]
+	if !ok || network.TenantID != tenantID {
+		return types.ErrTenantNetworkNotFound
+	}
+
+	subnetInt, err := tenantSubnetInt(network.CIDR)
+	if err != nil {
+		return err
+	}
+
+	t := ds.tenants[tenantID]
+	if t != nil && len(t.network[subnetInt]) > 0 {
+		return types.ErrTenantNetworkInUse
+	}
+
+	err = ds.db.deleteTenantNetwork(ID)
+	if err != nil {
+		return errors.Wrap(err, "error deleting tenant network from database")
+	}
+
+	delete(ds.tenantNetworks, ID)
+	if t != nil {
+		delete(t.network, subnetInt)
+	}
+
+	return nil
+}
+
+// AllocateTenantNetworkIP will find a free IP address within a tenant
+// network and wait for the CNCI of the network to be active.
+func (ds *Datastore) AllocateTenantNetworkIP(tenantID string, networkID string) (net.IP, error) {
+	ds.tenantsLock.Lock()
+
+	network, ok := ds.tenantNetworks[networkID]
+	t := ds.tenants[tenantID]
+	if !ok || network.TenantID != tenantID || t == nil {
+		ds.tenantsLock.Unlock()
+		return nil, types.ErrTenantNetworkNotFound
+	}
+
+	subnetInt, err := tenantSubnetInt(network.CIDR)
+	if err != nil {
+		ds.tenantsLock.Unlock()
+		return nil, err
+	}
+
+	reserveTenantSubnet(t, subnetInt)
+	hosts := t.network[subnetInt]
+
+	// like in the subnets allocated by AllocateTenantIP, .0, .1, .254
+	// and .255 are not handed out to instances.
+	rest := 0
+	for i := 2; i < 254; i++ {
+		if !hosts[i] {
+			hosts[i] = true
+			rest = i
+			break
+		}
+	}
+
+	mgr := t.CNCIctrl
+
+	ds.tenantsLock.Unlock()
+
+	if rest == 0 {
+		return nil, fmt.Errorf("Out of IP addresses in network %s", network.Name)
+	}
+
+	err = ds.db.claimTenantIP(tenantID, subnetInt, rest)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error claiming tenant IP in database")
+	}
+
+	if mgr != nil {
+		err = mgr.WaitForActive(subnetInt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subnetBytes := make([]byte, 2)
+	binary.BigEndian.PutUint16(subnetBytes, uint16(subnetInt))
+
+	return net.IPv4(172, subnetBytes[0], subnetBytes[1], byte(rest)), nil
+}
+
+// AddLoadBalancer stores a new load balancer, and its members, in the
+// datastore, giving it a free external IP of a pool.  Load balancer names
+// are unique within a tenant.
+func (ds *Datastore) AddLoadBalancer(poolID string, lb types.LoadBalancer) (types.LoadBalancer, error) {
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	for _, l := range ds.loadBalancers {
+		if l.TenantID == lb.TenantID && l.Name == lb.Name {
+			return types.LoadBalancer{}, types.ErrDuplicateLoadBalancerName
+		}
+	}
+
+	pool, ok := ds.pools[poolID]
+	if !ok {
+		return types.LoadBalancer{}, types.ErrPoolNotFound
+	}
+
+	// members are proxied to, so any address family will do.
+	address, err := ds.freeExternalIP(pool, func(IP net.IP) bool {
+		return true
+	})
+	if err != nil {
+		return types.LoadBalancer{}, err
+	}
+
+	lb.ExternalIP = address
+	lb.PoolID = pool.ID
+	lb.PoolName = pool.Name
+
+	err = ds.db.addLoadBalancer(lb)
+	if err != nil {
+		return types.LoadBalancer{}, errors.Wrap(err, "error adding load balancer to database")
+	}
+
+	for _, instanceID := range lb.Members {
+		err = ds.db.addLoadBalancerMember(lb.ID, instanceID)
+		if err != nil {
+			return types.LoadBalancer{}, errors.Wrap(err, "error adding load balancer member to database")
+		}
+	}
+
+	pool.Free--
+
+	err = ds.db.updatePool(pool)
+	if err != nil {
+		return types.LoadBalancer{}, errors.Wrap(err, "error updating pool in database")
+	}
+
+	ds.pools[poolID] = pool
+	ds.loadBalancers[lb.ID] = copyLoadBalancer(lb)
+	ds.loadBalancerIPs[address] = lb.ID
+
+	return lb, nil
+}
+
+func copyLoadBalancer(lb types.LoadBalancer) types.LoadBalancer {
+	lb.Members = append([]string{}, lb.Members...)
+	return lb
+}
+
+// GetLoadBalancer returns the load balancer belonging to a tenant.
+func (ds *Datastore) GetLoadBalancer(tenantID string, ID string) (types.LoadBalancer, error) {
+	ds.poolsLock.RLock()
+	defer ds.poolsLock.RUnlock()
+
+	lb, ok := ds.loadBalancers[ID]
+	if !ok || lb.TenantID != tenantID {
+		return types.LoadBalancer{}, types.ErrLoadBalancerNotFound
+	}
+
+	return copyLoadBalancer(lb), nil
+}
+
+// GetLoadBalancers returns all the load balancers belonging to a tenant.
+func (ds *Datastore) GetLoadBalancers(tenantID string) []types.LoadBalancer {
+	var lbs []types.LoadBalancer
+
+	ds.poolsLock.RLock()
+	defer ds.poolsLock.RUnlock()
+
+	for _, lb := range ds.loadBalancers {
+		if lb.TenantID == tenantID {
+			lbs = append(lbs, copyLoadBalancer(lb))
+		}
+	}
+
+	return lbs
+}
+
+// DeleteLoadBalancer deletes a load balancer, and its members, from the
+// datastore and returns its external IP to its pool.
+func (ds *Datastore) DeleteLoadBalancer(tenantID string, ID string) error {
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	lb, ok := ds.loadBalancers[ID]
+	if !ok || lb.TenantID != tenantID {
+		return types.ErrLoadBalancerNotFound
+	}
+
+	err := ds.db.deleteLoadBalancer(ID)
+	if err != nil {
+		return errors.Wrapf(err, "error deleting load balancer (%v) from database", ID)
+	}
+
+	delete(ds.loadBalancers, ID)
+	delete(ds.loadBalancerIPs, lb.ExternalIP)
+
+	pool, ok := ds.pools[lb.PoolID]
+	if !ok {
+		return types.ErrPoolNotFound
+	}
+
+	pool.Free++
+
+	err = ds.db.updatePool(pool)
+	if err != nil {
+		return errors.Wrap(err, "error updating pool in database")
+	}
+
+	ds.pools[pool.ID] = pool
+
+	return nil
+}
+
+// AddLoadBalancerMember adds an instance to a load balancer.
+func (ds *Datastore) AddLoadBalancerMember(tenantID string, ID string, instanceID string) error {
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	lb, ok := ds.loadBalancers[ID]
+	if !ok || lb.TenantID != tenantID {
+		return types.ErrLoadBalancerNotFound
+	}
+
+	for _, member := range lb.Members {
+		if member == instanceID {
+			return nil
+		}
+	}
+
+	err := ds.db.addLoadBalancerMember(ID, instanceID)
+	if err != nil {
+		return errors.Wrap(err, "error adding load balancer member to database")
+	}
+
+	lb.Members = append(lb.Members, instanceID)
+	ds.loadBalancers[ID] = lb
+
+	return nil
+}
+
+// DeleteLoadBalancerMember removes an instance from a load balancer.
+func (ds *Datastore) DeleteLoadBalancerMember(tenantID string, ID string, instanceID string) error {
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	lb, ok := ds.loadBalancers[ID]
+	if !ok || lb.TenantID != tenantID {
+		return types.ErrLoadBalancerNotFound
+	}
+
+	for i, member := range lb.Members {
+		if member != instanceID {
+			continue
+		}
+
+		err := ds.db.deleteLoadBalancerMember(ID, instanceID)
+		if err != nil {
+			return errors.Wrap(err, "error deleting load balancer member from database")
+		}
+
+		lb.Members = append(lb.Members[:i], lb.Members[i+1:]...)
+		ds.loadBalancers[ID] = lb
+
+		return nil
+	}
+
+	return types.ErrInstanceNotFound
+}
+
+func (ds *Datastore) removeLoadBalancerMember(instanceID string) error {
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	for ID, lb := range ds.loadBalancers {
+		for i, member := range lb.Members {
+			if member != instanceID {
+				continue
+			}
+
+			err := ds.db.deleteLoadBalancerMembers(instanceID)
+			if err != nil {
+				return errors.Wrap(err, "error deleting load balancer members from database")
+			}
+
+			lb.Members = append(lb.Members[:i], lb.Members[i+1:]...)
+			ds.loadBalancers[ID] = lb
+			break
+		}
+	}
+
+	return nil
+}
+
+// AddPortForward stores a new port forward in the datastore.  If
+// pf.ExternalIP is set, it must be an external IP whose ports the tenant
+// already forwards to instances on the subnet of pf.InstanceID.
+// Otherwise a free external IP of the pool is used.
+// IPv6 external IPs are forwarded to the IPv6 address of the instance.
+func (ds *Datastore) AddPortForward(poolID string, pf types.PortForward) (types.PortForward, error) {
+	instance, err := ds.GetInstance(pf.InstanceID)
+	if err != nil {
+		return types.PortForward{}, errors.Wrapf(err, "error getting instance (%v)", pf.InstanceID)
+	}
+
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	shared := pf.ExternalIP != ""
+	if shared {
+		poolID = ""
+		for _, f := range ds.portForwards {
+			if f.ExternalIP != pf.ExternalIP {
+				continue
+			}
+
+			if f.TenantID != pf.TenantID {
+				return types.PortForward{}, types.ErrAddressNotFound
+			}
+
+			if f.Protocol == pf.Protocol && f.ExternalPort == pf.ExternalPort {
+				return types.PortForward{}, types.ErrDuplicatePortForward
+			}
+
+			// the CNCI of a single subnet renders the port forwards
+			// of an external IP.
+			forwarded, err := ds.GetInstance(f.InstanceID)
+			if err == nil && forwarded.Subnet != instance.Subnet {
+				return types.PortForward{}, types.ErrPortForwardSubnet
+			}
+
+			poolID = f.PoolID
+		}
+
+		if poolID == "" {
+			return types.PortForward{}, types.ErrAddressNotFound
+		}
+	}
+
+	pool, ok := ds.pools[poolID]
+	if !ok {
+		return types.PortForward{}, types.ErrPoolNotFound
+	}
+
+	if !shared {
+		pf.ExternalIP, err = ds.freeExternalIP(pool, func(IP net.IP) bool {
+			return instanceInternalIP(instance, IP) != ""
+		})
+		if err != nil {
+			return types.PortForward{}, err
+		}
+	}
+
+	pf.InternalIP = instanceInternalIP(instance, net.ParseIP(pf.ExternalIP))
+	if pf.InternalIP == "" {
+		return types.PortForward{}, types.ErrInvalidIP
+	}
+
+	pf.PoolID = pool.ID
+	pf.PoolName = pool.Name
+
+	err = ds.db.addPortForward(pf)
+	if err != nil {
+		return types.PortForward{}, errors.Wrap(err, "error adding port forward to database")
+	}
+
+	if !shared {
+		pool.Free--
+
+		err = ds.db.updatePool(pool)
+		if err != nil {
+			return types.PortForward{}, errors.Wrap(err, "error updating pool in database")
+		}
+
+		ds.pools[poolID] = pool
+	}
+
+	ds.portForwards[pf.ID] = pf
+	ds.portForwardIPs[pf.ExternalIP]++
+
+	return pf, nil
+}
+
+// GetPortForward returns the port forward belonging to a tenant.
+func (ds *Datastore) GetPortForward(tenantID string, ID string) (types.PortForward, error) {
+	ds.poolsLock.RLock()
+	defer ds.poolsLock.RUnlock()
+
+	pf, ok := ds.portForwards[ID]
+	if !ok || pf.TenantID != tenantID {
+		return types.PortForward{}, types.ErrPortForwardNotFound
+	}
+
+	return pf, nil
+}
+
+// GetPortForwards returns all the port forwards belonging to a tenant.
+func (ds *Datastore) GetPortForwards(tenantID string) []types.PortForward {
+	var pfs []types.PortForward
+
+	ds.poolsLock.RLock()
+	defer ds.poolsLock.RUnlock()
+
+	for _, pf := range ds.portForwards {
+		if pf.TenantID == tenantID {
+			pfs = append(pfs, pf)
+		}
+	}
+
+	return pfs
+}
+
+// DeletePortForward deletes a port forward from the datastore.  The
+// external IP is returned to its pool when none of its ports is forwarded
+// any more, in which case released is true.
+func (ds *Datastore) DeletePortForward(tenantID string, ID string) (released bool, err error) {
+	ds.poolsLock.Lock()
+	defer ds.poolsLock.Unlock()
+
+	pf, ok := ds.portForwards[ID]
+	if !ok || pf.TenantID != tenantID {
+		return false, types.ErrPortForwardNotFound
+	}
+
+	err = ds.db.deletePortForward(ID)
+	if err != nil {
+		return false, errors.Wrapf(err, "error deleting port forward (%v) from database", ID)
+	}
+
+	delete(ds.portForwards, ID)
+
+	ds.portForwardIPs[pf.ExternalIP]--
+	if ds.portForwardIPs[pf.ExternalIP] > 0 {
+		return false, nil
+	}
+
+	delete(ds.portForwardIPs, pf.ExternalIP)
+
+	pool, ok := ds.pools[pf.PoolID]
+	if !ok {
+		return true, types.ErrPoolNotFound
+	}
+
+	pool.Free++
+
+	err = ds.db.updatePool(pool)
+	if err != nil {
+		return true, errors.Wrap(err, "error updating pool in database")
+	}
+
+	ds.pools[pool.ID] = pool
+
+	return true, nil
+}
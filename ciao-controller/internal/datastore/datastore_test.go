@@ -923,6 +923,58 @@ func TestAllocateTenantIP(t *testing.T) {
 	}
 }
 
+func TestGetSubnetKey(t *testing.T) {
+	tenant1, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant2, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key1, err := ds.GetSubnetKey(tenant1.ID, "172.16.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key2, err := ds.GetSubnetKey(tenant2.ID, "172.16.0.0/24")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key1 <= 0 || key2 <= 0 || key1 == key2 {
+		t.Fatalf("Subnet keys not unique across tenants: %d %d", key1, key2)
+	}
+
+	key, err := ds.GetSubnetKey(tenant1.ID, "172.16.0.0/24")
+	if err != nil || key != key1 {
+		t.Fatalf("Subnet key not kept: %d %d %v", key, key1, err)
+	}
+
+	key, err = ds.GetSubnetKey(tenant1.ID, "172.16.1.0/24")
+	if err != nil || key == key1 || key == key2 {
+		t.Fatalf("Subnet keys not unique across subnets: %d %v", key, err)
+	}
+}
+
+func TestNextGeneration(t *testing.T) {
+	g1, err := ds.NextGeneration()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g2, err := ds.NextGeneration()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if g1 <= 0 || g2 != g1+1 {
+		t.Fatalf("Generations not increasing: %d %d", g1, g2)
+	}
+}
+
 func TestGetCNCIWorkloadID(t *testing.T) {
 	_, err := ds.GetCNCIWorkloadID()
 	if err != nil {
@@ -1270,8 +1322,8 @@ func testAllocateTenantIPs(t *testing.T, nIPs int) {
 	}
 	ones, bits := ipNet.Mask.Size()
 
-	// deduct .0 and .1, and .255
-	nIPsPerSubnet := (1 << uint32(bits-ones)) - 3
+	// deduct .0 and .1, .254, and .255
+	nIPsPerSubnet := (1 << uint32(bits-ones)) - 4
 	if nIPsPerSubnet <= 0 {
 		t.Fatal("Invalid tenant config")
 	}
@@ -1462,6 +1514,131 @@ func TestUpdateBlockDevice(t *testing.T) {
 	}
 }
 
+func TestUpdateBlockDeviceState(t *testing.T) {
+	newTenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := types.BlockData{
+		BlockDevice: storage.BlockDevice{
+			ID: uuid.Generate().String(),
+		},
+		State:      types.Available,
+		TenantID:   newTenant.ID,
+		CreateTime: time.Now(),
+	}
+
+	err = ds.AddBlockDevice(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := ds.UpdateBlockDeviceState(data.ID, types.Extending, types.Available, types.InUse)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.State != types.Available {
+		t.Fatalf("expected previous State == %s, got %s\n", types.Available, d.State)
+	}
+
+	// the block device is no longer available.
+	_, err = ds.UpdateBlockDeviceState(data.ID, types.Extending, types.Available, types.InUse)
+	if err != types.ErrBlockDeviceState {
+		t.Fatalf("expected ErrBlockDeviceState, got %v", err)
+	}
+
+	d, err = ds.GetBlockDevice(data.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if d.State != types.Extending {
+		t.Fatalf("expected State == %s, got %s\n", types.Extending, d.State)
+	}
+
+	_, err = ds.UpdateBlockDeviceState(uuid.Generate().String(), types.Extending, types.Available)
+	if err != ErrNoBlockData {
+		t.Fatalf("expected ErrNoBlockData, got %v", err)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	newTenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := types.BlockData{
+		BlockDevice: storage.BlockDevice{ID: uuid.Generate().String(), Size: 2},
+		State:       types.Available,
+		TenantID:    newTenant.ID,
+		CreateTime:  time.Now(),
+	}
+
+	err = ds.AddBlockDevice(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snap := types.SnapshotData{
+		ID:         uuid.Generate().String(),
+		VolumeID:   data.ID,
+		TenantID:   newTenant.ID,
+		State:      types.Available,
+		Size:       data.Size,
+		CreateTime: time.Now(),
+		Name:       "snap",
+	}
+
+	err = ds.AddSnapshot(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orphan := snap
+	orphan.ID = uuid.Generate().String()
+	orphan.VolumeID = "invalidID"
+	err = ds.AddSnapshot(orphan)
+	if err != ErrNoBlockData {
+		t.Fatalf("Expected ErrNoBlockData, got %v", err)
+	}
+
+	s, err := ds.GetSnapshot(snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.VolumeID != data.ID || s.Name != snap.Name {
+		t.Fatalf("Unexpected snapshot %v", s)
+	}
+
+	snaps := ds.GetSnapshots(newTenant.ID)
+	if len(snaps) != 1 || snaps[0].ID != snap.ID {
+		t.Fatalf("Unexpected tenant snapshots %v", snaps)
+	}
+
+	snaps = ds.GetVolumeSnapshots(data.ID)
+	if len(snaps) != 1 || snaps[0].ID != snap.ID {
+		t.Fatalf("Unexpected volume snapshots %v", snaps)
+	}
+
+	err = ds.DeleteSnapshot(snap.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ds.GetSnapshot(snap.ID)
+	if err != types.ErrSnapshotNotFound {
+		t.Fatalf("Expected ErrSnapshotNotFound, got %v", err)
+	}
+
+	err = ds.DeleteSnapshot(snap.ID)
+	if err != types.ErrSnapshotNotFound {
+		t.Fatalf("Expected ErrSnapshotNotFound, got %v", err)
+	}
+}
+
 func TestGetBlockDevicesErr(t *testing.T) {
 	// confirm that sending a bad tenant id results in error
 	_, err := ds.GetBlockDevices("badID")
@@ -2197,6 +2374,27 @@ func TestAddExternalSubnet(t *testing.T) {
 		t.Fatal("invalid subnet allowed")
 	}
 
+	// try a subnet too large to be searched
+	err = ds.AddExternalSubnet(orig.ID, "2001:db8::/64")
+	if err != types.ErrSubnetTooLarge {
+		t.Fatal("too large subnet allowed")
+	}
+
+	// an IPv6 subnet has no broadcast address
+	err = ds.AddExternalSubnet(orig.ID, "2001:db8::/120")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err = ds.GetPool(orig.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pool.TotalIPs != 254+255 {
+		t.Fatalf("expected %d IPs, got %d", 254+255, pool.TotalIPs)
+	}
+
 	// cleanup.
 	err = ds.DeletePool(orig.ID)
 	if err != nil {
@@ -2513,6 +2711,71 @@ func TestMapIPs(t *testing.T) {
 	}
 }
 
+func TestMapIPv6IPs(t *testing.T) {
+	orig := types.Pool{
+		ID:   uuid.Generate().String(),
+		Name: "testv6",
+	}
+
+	err := ds.AddPool(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.AddExternalIPs(orig.ID, []string{"2001:db8::10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wls, err := ds.GetWorkloads(tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := addTestInstance(tenant, wls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// instances without a subnet have no IPv6 address
+	_, err = ds.MapExternalIP(orig.ID, instance.ID)
+	if err != types.ErrPoolEmpty {
+		t.Fatal("IPv6 address mapped to an IPv4 only instance")
+	}
+
+	ip := net.ParseIP(instance.IPAddress)
+	subnet := net.IPNet{
+		IP:   ip.Mask(net.CIDRMask(24, 32)),
+		Mask: net.CIDRMask(24, 32),
+	}
+	instance.Subnet = subnet.String()
+
+	m, err := ds.MapExternalIP(orig.ID, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := utils.TenantIPv6Addr(ip, &subnet).String()
+	if m.ExternalIP != "2001:db8::10" || m.InternalIP != expected {
+		t.Fatalf("expected 2001:db8::10 mapped to %s, got %v", expected, m)
+	}
+
+	err = ds.UnMapExternalIP(m.ExternalIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.DeletePool(orig.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestGetMappedIPs(t *testing.T) {
 	orig := types.Pool{
 		ID:   uuid.Generate().String(),
@@ -2674,6 +2937,187 @@ var ds *Datastore
 
 var workloadsPath = flag.String("workloads_path", "../../workloads", "path to yaml files")
 
+func TestServerGroups(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group := types.ServerGroup{
+		ID:       uuid.Generate().String(),
+		TenantID: tenant.ID,
+		Name:     "ha-pair",
+		Policy:   payloads.AntiAffinity,
+	}
+
+	err = ds.AddServerGroup(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := ds.GetServerGroups(tenant.ID)
+	if len(groups) != 1 || groups[0].ID != group.ID {
+		t.Fatalf("GetServerGroups failed: %v", groups)
+	}
+
+	_, err = ds.GetServerGroup("public", group.ID)
+	if err != types.ErrServerGroupNotFound {
+		t.Fatal("found server group of another tenant")
+	}
+
+	wls, err := ds.GetWorkloads(tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := addTestInstance(tenant, wls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.AddServerGroupMember(group.ID, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	member, ok := ds.GetInstanceServerGroup(instance.ID)
+	if !ok || member.ID != group.ID {
+		t.Fatal("GetInstanceServerGroup failed")
+	}
+
+	err = ds.DeleteServerGroup(tenant.ID, group.ID)
+	if err != types.ErrServerGroupInUse {
+		t.Fatal("deleted server group with members")
+	}
+
+	err = ds.DeleteInstance(instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err = ds.GetServerGroup(tenant.ID, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Members) != 0 {
+		t.Fatalf("deleted instance still in server group: %v", group.Members)
+	}
+
+	err = ds.DeleteServerGroup(tenant.ID, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSecurityGroups(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group := types.SecurityGroup{
+		ID:       uuid.Generate().String(),
+		TenantID: tenant.ID,
+		Name:     "web",
+		Rules: []types.SecurityGroupRule{
+			{
+				ID:             uuid.Generate().String(),
+				Direction:      payloads.Ingress,
+				Protocol:       "tcp",
+				PortRangeMin:   80,
+				PortRangeMax:   80,
+				RemoteIPPrefix: "0.0.0.0/0",
+			},
+		},
+	}
+
+	err = ds.AddSecurityGroup(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dup := group
+	dup.ID = uuid.Generate().String()
+	err = ds.AddSecurityGroup(dup)
+	if err != types.ErrDuplicateSecurityGroupName {
+		t.Fatal("added security group with duplicate name")
+	}
+
+	groups := ds.GetSecurityGroups(tenant.ID)
+	if len(groups) != 1 || groups[0].ID != group.ID || len(groups[0].Rules) != 1 {
+		t.Fatalf("GetSecurityGroups failed: %v", groups)
+	}
+
+	_, err = ds.GetSecurityGroup("public", group.ID)
+	if err != types.ErrSecurityGroupNotFound {
+		t.Fatal("found security group of another tenant")
+	}
+
+	rule := types.SecurityGroupRule{
+		ID:            uuid.Generate().String(),
+		Direction:     payloads.Ingress,
+		RemoteGroupID: group.ID,
+	}
+
+	err = ds.AddSecurityGroupRule(group.ID, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wls, err := ds.GetWorkloads(tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := addTestInstance(tenant, wls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.AddSecurityGroupMember(group.ID, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	member := ds.GetInstanceSecurityGroups(instance.ID)
+	if len(member) != 1 || member[0].ID != group.ID || len(member[0].Rules) != 2 {
+		t.Fatalf("GetInstanceSecurityGroups failed: %v", member)
+	}
+
+	err = ds.DeleteSecurityGroup(tenant.ID, group.ID)
+	if err != types.ErrSecurityGroupInUse {
+		t.Fatal("deleted security group with members")
+	}
+
+	err = ds.DeleteInstance(instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err = ds.GetSecurityGroup(tenant.ID, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(group.Members) != 0 {
+		t.Fatalf("deleted instance still in security group: %v", group.Members)
+	}
+
+	err = ds.DeleteSecurityGroupRule(tenant.ID, group.ID, rule.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.DeleteSecurityGroupRule(tenant.ID, group.ID, rule.ID)
+	if err != types.ErrSecurityGroupRuleNotFound {
+		t.Fatal("deleted security group rule twice")
+	}
+
+	err = ds.DeleteSecurityGroup(tenant.ID, group.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	flag.Parse()
 
@@ -2699,3 +3143,356 @@ func TestMain(m *testing.M) {
 
 	os.Exit(code)
 }
+
+func TestTenantNetworks(t *testing.T) {
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	network := types.TenantNetwork{
+		ID:       uuid.Generate().String(),
+		TenantID: tenant.ID,
+		Name:     "backend",
+		CIDR:     "172.18.5.0/24",
+	}
+
+	err = ds.AddTenantNetwork(network)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dup := network
+	dup.ID = uuid.Generate().String()
+	dup.CIDR = "172.18.6.0/24"
+	err = ds.AddTenantNetwork(dup)
+	if err != types.ErrDuplicateTenantNetworkName {
+		t.Fatal("added tenant network with duplicate name")
+	}
+
+	dup.Name = "frontend"
+	dup.CIDR = network.CIDR
+	err = ds.AddTenantNetwork(dup)
+	if err != types.ErrTenantSubnetInUse {
+		t.Fatal("added tenant network with subnet in use")
+	}
+
+	networks := ds.GetTenantNetworks(tenant.ID)
+	if len(networks) != 1 || networks[0].ID != network.ID {
+		t.Fatalf("GetTenantNetworks failed: %v", networks)
+	}
+
+	_, err = ds.GetTenantNetwork("public", network.ID)
+	if err != types.ErrTenantNetworkNotFound {
+		t.Fatal("found tenant network of another tenant")
+	}
+
+	ip, err := ds.AllocateTenantIP(tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, subnet, _ := net.ParseCIDR(network.CIDR)
+	if subnet.Contains(ip) {
+		t.Fatalf("AllocateTenantIP allocated %s from a tenant network", ip)
+	}
+
+	networkIP, err := ds.AllocateTenantNetworkIP(tenant.ID, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !networkIP.Equal(net.IPv4(172, 18, 5, 2)) {
+		t.Fatalf("AllocateTenantNetworkIP allocated %s", networkIP)
+	}
+
+	err = ds.DeleteTenantNetwork(tenant.ID, network.ID)
+	if err != types.ErrTenantNetworkInUse {
+		t.Fatal("deleted tenant network in use")
+	}
+
+	err = ds.ReleaseTenantIP(tenant.ID, networkIP.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the subnet stays reserved until the network is deleted
+	err = ds.AddTenantNetwork(dup)
+	if err != types.ErrTenantSubnetInUse {
+		t.Fatal("released subnet of tenant network")
+	}
+
+	err = ds.DeleteTenantNetwork(tenant.ID, network.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ds.GetTenantNetwork(tenant.ID, network.ID)
+	if err != types.ErrTenantNetworkNotFound {
+		t.Fatal("tenant network not deleted")
+	}
+
+	err = ds.ReleaseTenantIP(tenant.ID, ip.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadBalancers(t *testing.T) {
+	pool := types.Pool{
+		ID:   uuid.Generate().String(),
+		Name: "lbpool",
+	}
+
+	err := ds.AddPool(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.AddExternalIPs(pool.ID, []string{"192.168.10.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wls, err := ds.GetWorkloads(tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instance, err := addTestInstance(tenant, wls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lb := types.LoadBalancer{
+		ID:         uuid.Generate().String(),
+		TenantID:   tenant.ID,
+		Name:       "web",
+		Protocol:   "tcp",
+		Port:       80,
+		MemberPort: 8080,
+		Members:    []string{instance.ID},
+	}
+
+	lb, err = ds.AddLoadBalancer(pool.ID, lb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lb.ExternalIP != "192.168.10.1" || lb.PoolName != pool.Name {
+		t.Fatalf("load balancer not given an IP of its pool: %v", lb)
+	}
+
+	dup := lb
+	dup.ID = uuid.Generate().String()
+	_, err = ds.AddLoadBalancer(pool.ID, dup)
+	if err != types.ErrDuplicateLoadBalancerName {
+		t.Fatal("added load balancer with duplicate name")
+	}
+
+	// the only address of the pool is used by the load balancer
+	_, err = ds.MapExternalIP(pool.ID, instance.ID)
+	if err != types.ErrPoolEmpty {
+		t.Fatal("mapped the IP of a load balancer")
+	}
+
+	err = ds.DeletePool(pool.ID)
+	if err != types.ErrPoolNotEmpty {
+		t.Fatal("deleted pool of a load balancer")
+	}
+
+	_, err = ds.GetLoadBalancer("public", lb.ID)
+	if err != types.ErrLoadBalancerNotFound {
+		t.Fatal("found load balancer of another tenant")
+	}
+
+	lbs := ds.GetLoadBalancers(tenant.ID)
+	if len(lbs) != 1 || lbs[0].ID != lb.ID || len(lbs[0].Members) != 1 {
+		t.Fatalf("GetLoadBalancers failed: %v", lbs)
+	}
+
+	err = ds.DeleteLoadBalancerMember(tenant.ID, lb.ID, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.DeleteLoadBalancerMember(tenant.ID, lb.ID, instance.ID)
+	if err != types.ErrInstanceNotFound {
+		t.Fatal("removed load balancer member twice")
+	}
+
+	err = ds.AddLoadBalancerMember(tenant.ID, lb.ID, instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.DeleteInstance(instance.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lb, err = ds.GetLoadBalancer(tenant.ID, lb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lb.Members) != 0 {
+		t.Fatalf("deleted instance still in load balancer: %v", lb.Members)
+	}
+
+	err = ds.DeleteLoadBalancer(tenant.ID, lb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.DeleteLoadBalancer(tenant.ID, lb.ID)
+	if err != types.ErrLoadBalancerNotFound {
+		t.Fatal("deleted load balancer twice")
+	}
+
+	err = ds.DeletePool(pool.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPortForwards(t *testing.T) {
+	pool := types.Pool{
+		ID:   uuid.Generate().String(),
+		Name: "pfpool",
+	}
+
+	err := ds.AddPool(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ds.AddExternalIPs(pool.ID, []string{"192.168.11.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant, err := addTestTenant()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wls, err := ds.GetWorkloads(tenant.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var instances []*types.Instance
+	for i := 0; i < 2; i++ {
+		instance, err := addTestInstance(tenant, wls[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		instances = append(instances, instance)
+	}
+
+	ssh := types.PortForward{
+		ID:           uuid.Generate().String(),
+		TenantID:     tenant.ID,
+		Protocol:     "tcp",
+		ExternalPort: 2222,
+		InstanceID:   instances[0].ID,
+		InternalPort: 22,
+	}
+
+	ssh, err = ds.AddPortForward(pool.ID, ssh)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ssh.ExternalIP != "192.168.11.1" || ssh.PoolName != pool.Name ||
+		ssh.InternalIP != instances[0].IPAddress {
+		t.Fatalf("port forward not given an IP of its pool: %v", ssh)
+	}
+
+	web := types.PortForward{
+		ID:           uuid.Generate().String(),
+		TenantID:     tenant.ID,
+		ExternalIP:   ssh.ExternalIP,
+		Protocol:     "tcp",
+		ExternalPort: 80,
+		InstanceID:   instances[1].ID,
+		InternalPort: 8080,
+	}
+
+	web, err = ds.AddPortForward("", web)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if web.InternalIP != instances[1].IPAddress {
+		t.Fatalf("port forwarded to the wrong address: %v", web)
+	}
+
+	dup := web
+	dup.ID = uuid.Generate().String()
+	_, err = ds.AddPortForward("", dup)
+	if err != types.ErrDuplicatePortForward {
+		t.Fatal("forwarded the same port twice")
+	}
+
+	other := web
+	other.ID = uuid.Generate().String()
+	other.TenantID = "public"
+	other.ExternalPort = 443
+	_, err = ds.AddPortForward("", other)
+	if err != types.ErrAddressNotFound {
+		t.Fatal("forwarded a port of the external IP of another tenant")
+	}
+
+	// the only address of the pool has forwarded ports
+	_, err = ds.MapExternalIP(pool.ID, instances[0].ID)
+	if err != types.ErrPoolEmpty {
+		t.Fatal("mapped an IP with forwarded ports")
+	}
+
+	_, err = ds.GetPortForward("public", ssh.ID)
+	if err != types.ErrPortForwardNotFound {
+		t.Fatal("found port forward of another tenant")
+	}
+
+	pfs := ds.GetPortForwards(tenant.ID)
+	if len(pfs) != 2 {
+		t.Fatalf("GetPortForwards failed: %v", pfs)
+	}
+
+	released, err := ds.DeletePortForward(tenant.ID, ssh.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released {
+		t.Fatal("released an IP with forwarded ports")
+	}
+
+	err = ds.DeletePool(pool.ID)
+	if err != types.ErrPoolNotEmpty {
+		t.Fatal("deleted pool of a port forward")
+	}
+
+	released, err = ds.DeletePortForward(tenant.ID, web.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !released {
+		t.Fatal("IP without forwarded ports not released")
+	}
+
+	_, err = ds.DeletePortForward(tenant.ID, web.ID)
+	if err != types.ErrPortForwardNotFound {
+		t.Fatal("deleted port forward twice")
+	}
+
+	err = ds.DeletePool(pool.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -133,6 +133,10 @@ func (db *MemoryDB) deleteInstance(instanceID string) error {
 	return nil
 }
 
+func (db *MemoryDB) updateInstanceResources(instanceID string, resources []payloads.RequestedResource) error {
+	return nil
+}
+
 func (db *MemoryDB) addNodeStat(stat payloads.Stat) error {
 	return nil
 }
@@ -245,3 +249,127 @@ func (db *MemoryDB) deleteTenant(tenantID string) error {
 	delete(db.tenants, tenantID)
 	return nil
 }
+
+func (db *MemoryDB) addServerGroup(group types.ServerGroup) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteServerGroup(ID string) error {
+	return nil
+}
+
+func (db *MemoryDB) getServerGroups() (map[string]types.ServerGroup, error) {
+	return make(map[string]types.ServerGroup), nil
+}
+
+func (db *MemoryDB) addServerGroupMember(groupID string, instanceID string) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteServerGroupMember(instanceID string) error {
+	return nil
+}
+
+func (db *MemoryDB) addSecurityGroup(group types.SecurityGroup) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteSecurityGroup(ID string) error {
+	return nil
+}
+
+func (db *MemoryDB) getSecurityGroups() (map[string]types.SecurityGroup, error) {
+	return make(map[string]types.SecurityGroup), nil
+}
+
+func (db *MemoryDB) addSecurityGroupRule(groupID string, rule types.SecurityGroupRule) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteSecurityGroupRule(ID string) error {
+	return nil
+}
+
+func (db *MemoryDB) addSecurityGroupMember(groupID string, instanceID string) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteSecurityGroupMembers(instanceID string) error {
+	return nil
+}
+
+func (db *MemoryDB) addTenantNetwork(network types.TenantNetwork) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteTenantNetwork(ID string) error {
+	return nil
+}
+
+func (db *MemoryDB) getTenantNetworks() (map[string]types.TenantNetwork, error) {
+	return make(map[string]types.TenantNetwork), nil
+}
+
+func (db *MemoryDB) addSubnetKey(tenantID string, subnet int, key int) error {
+	return nil
+}
+
+func (db *MemoryDB) getSubnetKeys() (map[string]map[int]int, error) {
+	return make(map[string]map[int]int), nil
+}
+
+func (db *MemoryDB) updateGeneration(generation int64) error {
+	return nil
+}
+
+func (db *MemoryDB) getGeneration() (int64, error) {
+	return 0, nil
+}
+
+func (db *MemoryDB) addLoadBalancer(lb types.LoadBalancer) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteLoadBalancer(ID string) error {
+	return nil
+}
+
+func (db *MemoryDB) getLoadBalancers() (map[string]types.LoadBalancer, error) {
+	return make(map[string]types.LoadBalancer), nil
+}
+
+func (db *MemoryDB) addLoadBalancerMember(lbID string, instanceID string) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteLoadBalancerMember(lbID string, instanceID string) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteLoadBalancerMembers(instanceID string) error {
+	return nil
+}
+
+func (db *MemoryDB) addPortForward(pf types.PortForward) error {
+	return nil
+}
+
+func (db *MemoryDB) deletePortForward(ID string) error {
+	return nil
+}
+
+func (db *MemoryDB) getPortForwards() (map[string]types.PortForward, error) {
+	return make(map[string]types.PortForward), nil
+}
+
+func (db *MemoryDB) addSnapshot(snap types.SnapshotData) error {
+	return nil
+}
+
+func (db *MemoryDB) deleteSnapshot(ID string) error {
+	return nil
+}
+
+func (db *MemoryDB) getSnapshots() (map[string]types.SnapshotData, error) {
+	return make(map[string]types.SnapshotData), nil
+}
@@ -129,6 +129,43 @@ func (d instanceData) Init() error {
 	return d.ds.exec(d.db, cmd)
 }
 
+// Resources of resized instances
+type instanceResourceData struct {
+	namedData
+}
+
+func (d instanceResourceData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS instance_resources
+		(
+		instance_id string,
+		type string,
+		value int,
+		foreign key(instance_id) references instances(id)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+// Additional VNICs of instances attached to several tenant networks
+type instanceVnicData struct {
+	namedData
+}
+
+func (d instanceVnicData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS instance_vnics
+		(
+		instance_id string,
+		network_id string,
+		vnic_uuid string primary key,
+		mac_address string,
+		subnet string,
+		ip string,
+		foreign key(instance_id) references instances(id)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
 // Volume Data
 type blockData struct {
 	namedData
@@ -145,10 +182,17 @@ func (d blockData) Init() error {
 		name string,
 		description string,
 		internal int,
+		volume_type string,
 		foreign key(tenant_id) references tenants(id)
 		);`
 
-	return d.ds.exec(d.db, cmd)
+	err := d.ds.exec(d.db, cmd)
+	if err != nil {
+		return err
+	}
+
+	// block_data tables created before volume types lack the column
+	return d.ds.addColumn(d.db, "block_data", "volume_type", "string")
 }
 
 type attachments struct {
@@ -194,6 +238,25 @@ func (d workloadStorage) Init() error {
 	return d.ds.exec(d.db, cmd)
 }
 
+// workload node selectors
+type workloadNodeSelectorData struct {
+	namedData
+}
+
+func (d workloadNodeSelectorData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS workload_node_selectors
+		(
+		workload_id varchar(32),
+		label_key string,
+		label_value string,
+		foreign key(workload_id) references workload_template(id)
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS wlns_index
+		ON workload_node_selectors(workload_id, label_key);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
 // Tenants data
 type tenantData struct {
 	namedData
@@ -412,6 +475,214 @@ func (d quotaData) Init() error {
 	return d.ds.exec(d.db, cmd)
 }
 
+type serverGroupData struct {
+	namedData
+}
+
+func (d serverGroupData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS server_groups
+		(
+			id varchar(32) primary key,
+			tenant_id varchar(32),
+			name string,
+			policy string
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type serverGroupMemberData struct {
+	namedData
+}
+
+func (d serverGroupMemberData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS server_group_members
+		(
+			group_id varchar(32),
+			instance_id varchar(32) primary key
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type securityGroupData struct {
+	namedData
+}
+
+func (d securityGroupData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS security_groups
+		(
+			id varchar(32) primary key,
+			tenant_id varchar(32),
+			name string,
+			description string
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type securityGroupRuleData struct {
+	namedData
+}
+
+func (d securityGroupRuleData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS security_group_rules
+		(
+			id varchar(32) primary key,
+			group_id varchar(32),
+			direction string,
+			protocol string,
+			port_min integer,
+			port_max integer,
+			remote_ip_prefix string,
+			remote_group_id varchar(32)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type securityGroupMemberData struct {
+	namedData
+}
+
+func (d securityGroupMemberData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS security_group_members
+		(
+			group_id varchar(32),
+			instance_id varchar(32),
+			primary key(group_id, instance_id)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type subnetKeyData struct {
+	namedData
+}
+
+func (d subnetKeyData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS subnet_keys
+		(
+			tenant_id varchar(32),
+			subnet int,
+			subnet_key int unique,
+			primary key(tenant_id, subnet)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type generationData struct {
+	namedData
+}
+
+func (d generationData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS generation
+		(
+			id int primary key,
+			generation int
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type tenantNetworkData struct {
+	namedData
+}
+
+func (d tenantNetworkData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS tenant_networks
+		(
+			id varchar(32) primary key,
+			tenant_id varchar(32),
+			name string,
+			cidr string,
+			unique(tenant_id, name)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type loadBalancerData struct {
+	namedData
+}
+
+func (d loadBalancerData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS load_balancers
+		(
+			id varchar(32) primary key,
+			tenant_id varchar(32),
+			name string,
+			pool_id varchar(32),
+			external_ip string,
+			protocol string,
+			port integer,
+			member_port integer,
+			health_check_interval integer,
+			health_check_timeout integer,
+			unique(tenant_id, name)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type loadBalancerMemberData struct {
+	namedData
+}
+
+func (d loadBalancerMemberData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS load_balancer_members
+		(
+			lb_id varchar(32),
+			instance_id varchar(32),
+			primary key(lb_id, instance_id)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type portForwardData struct {
+	namedData
+}
+
+func (d portForwardData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS port_forwards
+		(
+			id varchar(32) primary key,
+			tenant_id varchar(32),
+			pool_id varchar(32),
+			external_ip string,
+			protocol string,
+			external_port integer,
+			instance_id varchar(32),
+			internal_ip string,
+			internal_port integer,
+			unique(external_ip, protocol, external_port)
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
+type snapshotData struct {
+	namedData
+}
+
+func (d snapshotData) Init() error {
+	cmd := `CREATE TABLE IF NOT EXISTS snapshots
+		(
+			id varchar(32) primary key,
+			volume_id varchar(32),
+			tenant_id varchar(32),
+			state string,
+			size integer,
+			create_time DATETIME,
+			name string,
+			description string
+		);`
+
+	return d.ds.exec(d.db, cmd)
+}
+
 func (ds *sqliteDB) exec(db *sql.DB, cmd string) error {
 	glog.V(2).Info("exec: ", cmd)
 
@@ -420,6 +691,53 @@ func (ds *sqliteDB) exec(db *sql.DB, cmd string) error {
 	return err
 }
 
+// addColumn adds a column to a table created by an older version of the
+// controller, unless the table already has it.
+func (ds *sqliteDB) addColumn(db *sql.DB, table string, column string, columnType string) error {
+	found, err := ds.hasColumn(db, table, column)
+	if err != nil || found {
+		return err
+	}
+
+	return ds.exec(db, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, columnType))
+}
+
+func (ds *sqliteDB) hasColumn(db *sql.DB, table string, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting columns of %s", table)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return false, errors.Wrapf(err, "error getting columns of %s", table)
+	}
+
+	for rows.Next() {
+		var name string
+		values := make([]interface{}, len(columns))
+		for i, c := range columns {
+			if c == "name" {
+				values[i] = &name
+			} else {
+				values[i] = new(interface{})
+			}
+		}
+
+		err = rows.Scan(values...)
+		if err != nil {
+			return false, errors.Wrapf(err, "error reading columns of %s", table)
+		}
+
+		if name == column {
+			return true, nil
+		}
+	}
+
+	return false, errors.Wrapf(rows.Err(), "error reading columns of %s", table)
+}
+
 // This function is deprecated and will be removed soon. It should not be used
 // for newly written or updated code.
 func (ds *sqliteDB) create(tableName string, record ...interface{}) error {
@@ -494,6 +812,8 @@ func (ds *sqliteDB) init(config Config) error {
 	ds.tables = []persistentData{
 		tenantData{namedData{ds: ds, name: "tenants", db: ds.db}},
 		instanceData{namedData{ds: ds, name: "instances", db: ds.db}},
+		instanceResourceData{namedData{ds: ds, name: "instance_resources", db: ds.db}},
+		instanceVnicData{namedData{ds: ds, name: "instance_vnics", db: ds.db}},
 		workloadTemplateData{namedData{ds: ds, name: "workload_template", db: ds.db}},
 		workloadResourceData{namedData{ds: ds, name: "workload_resources", db: ds.db}},
 		nodeStatisticsData{namedData{ds: ds, name: "node_statistics", db: ds.db}},
@@ -505,11 +825,24 @@ func (ds *sqliteDB) init(config Config) error {
 		blockData{namedData{ds: ds, name: "block_data", db: ds.db}},
 		attachments{namedData{ds: ds, name: "attachments", db: ds.db}},
 		workloadStorage{namedData{ds: ds, name: "workload_storage", db: ds.db}},
+		workloadNodeSelectorData{namedData{ds: ds, name: "workload_node_selectors", db: ds.db}},
 		poolData{namedData{ds: ds, name: "pools", db: ds.db}},
 		subnetPoolData{namedData{ds: ds, name: "subnet_pool", db: ds.db}},
 		addressData{namedData{ds: ds, name: "address_pool", db: ds.db}},
 		mappedIPData{namedData{ds: ds, name: "mapped_ips", db: ds.db}},
 		quotaData{namedData{ds: ds, name: "quotas", db: ds.db}},
+		serverGroupData{namedData{ds: ds, name: "server_groups", db: ds.db}},
+		serverGroupMemberData{namedData{ds: ds, name: "server_group_members", db: ds.db}},
+		securityGroupData{namedData{ds: ds, name: "security_groups", db: ds.db}},
+		securityGroupRuleData{namedData{ds: ds, name: "security_group_rules", db: ds.db}},
+		securityGroupMemberData{namedData{ds: ds, name: "security_group_members", db: ds.db}},
+		tenantNetworkData{namedData{ds: ds, name: "tenant_networks", db: ds.db}},
+		subnetKeyData{namedData{ds: ds, name: "subnet_keys", db: ds.db}},
+		generationData{namedData{ds: ds, name: "generation", db: ds.db}},
+		loadBalancerData{namedData{ds: ds, name: "load_balancers", db: ds.db}},
+		loadBalancerMemberData{namedData{ds: ds, name: "load_balancer_members", db: ds.db}},
+		portForwardData{namedData{ds: ds, name: "port_forwards", db: ds.db}},
+		snapshotData{namedData{ds: ds, name: "snapshots", db: ds.db}},
 	}
 
 	ds.workloadsPath = config.InitWorkloadsPath
@@ -683,6 +1016,55 @@ func (ds *sqliteDB) deleteWorkloadStorage(tx *sql.Tx, workloadID string) error {
 	return err
 }
 
+// lock must be held by caller
+func (ds *sqliteDB) createWorkloadNodeSelector(tx *sql.Tx, workloadID string, selector map[string]string) error {
+	for key, value := range selector {
+		_, err := tx.Exec("INSERT INTO workload_node_selectors (workload_id, label_key, label_value) VALUES (?, ?, ?)", workloadID, key, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lock must be held by caller
+func (ds *sqliteDB) deleteWorkloadNodeSelector(tx *sql.Tx, workloadID string) error {
+	_, err := tx.Exec("DELETE FROM workload_node_selectors WHERE workload_id = ?", workloadID)
+
+	return err
+}
+
+func (ds *sqliteDB) getWorkloadNodeSelector(ID string) (map[string]string, error) {
+	query := `SELECT label_key, label_value
+		  FROM workload_node_selectors
+		  WHERE workload_id = ?`
+
+	rows, err := ds.db.Query(query, ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var selector map[string]string
+
+	for rows.Next() {
+		var key, value string
+
+		err := rows.Scan(&key, &value)
+		if err != nil {
+			return nil, err
+		}
+
+		if selector == nil {
+			selector = make(map[string]string)
+		}
+		selector[key] = value
+	}
+
+	return selector, rows.Err()
+}
+
 func (ds *sqliteDB) getWorkloadStorage(ID string) ([]types.StorageResource, error) {
 	query := `SELECT volume_id, bootable, ephemeral, size,
 			 source_type, source_id, tag
@@ -814,6 +1196,11 @@ func (ds *sqliteDB) getTenantWorkloads(tenantID string) ([]types.Workload, error
 			return nil, err
 		}
 
+		wl.NodeSelector, err = ds.getWorkloadNodeSelector(wl.ID)
+		if err != nil {
+			return nil, err
+		}
+
 		wl.VMType = payloads.Hypervisor(VMType)
 
 		workloads = append(workloads, wl)
@@ -868,10 +1255,16 @@ func (ds *sqliteDB) updateWorkload(w types.Workload) error {
 			}
 		}
 
+		err := ds.createWorkloadNodeSelector(tx, w.ID, w.NodeSelector)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
 		// write config to file.
 		filename := fmt.Sprintf("%s_config.yaml", w.ID)
 		path := fmt.Sprintf("%s/%s", ds.workloadsPath, filename)
-		err := ioutil.WriteFile(path, []byte(w.Config), 0644)
+		err = ioutil.WriteFile(path, []byte(w.Config), 0644)
 		if err != nil {
 			tx.Rollback()
 			return err
@@ -915,6 +1308,12 @@ func (ds *sqliteDB) deleteWorkload(ID string) error {
 		return err
 	}
 
+	err = ds.deleteWorkloadNodeSelector(tx, ID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	_, err = tx.Exec("DELETE FROM workload_template WHERE id = ?", ID)
 	if err != nil {
 		tx.Rollback()
@@ -1092,6 +1491,12 @@ func (ds *sqliteDB) deleteTenant(tenantID string) error {
 		return err
 	}
 
+	_, err = tx.Exec("DELETE FROM subnet_keys WHERE tenant_id = ?", tenantID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	_, err = tx.Exec("DELETE FROM tenants WHERE id = ?", tenantID)
 	if err != nil {
 		tx.Rollback()
@@ -1141,14 +1546,24 @@ func (ds *sqliteDB) getInstances() ([]*types.Instance, error) {
 	ON instances.id = latest.instance_id
 	`
 
-	rows, err := db.Query(query)
+	resources, err := ds.getInstanceResources()
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var i types.Instance
+	vnics, err := ds.getInstanceVnics()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i types.Instance
 
 		var sshPort sql.NullInt64
 
@@ -1161,6 +1576,9 @@ func (ds *sqliteDB) getInstances() ([]*types.Instance, error) {
 			i.SSHPort = int(sshPort.Int64)
 		}
 
+		i.Resources = resources[i.ID]
+		i.Vnics = vnics[i.ID]
+
 		instances = append(instances, &i)
 	}
 
@@ -1208,6 +1626,16 @@ func (ds *sqliteDB) getTenantInstances(tenantID string) (map[string]*types.Insta
 	WHERE instances.tenant_id = ?
 	`
 
+	resources, err := ds.getInstanceResources()
+	if err != nil {
+		return nil, err
+	}
+
+	vnics, err := ds.getInstanceVnics()
+	if err != nil {
+		return nil, err
+	}
+
 	rows, err := db.Query(query, tenantID)
 	if err != nil {
 		return nil, err
@@ -1239,6 +1667,9 @@ func (ds *sqliteDB) getTenantInstances(tenantID string) (map[string]*types.Insta
 			i.SSHPort = int(sshPort.Int64)
 		}
 
+		i.Resources = resources[i.ID]
+		i.Vnics = vnics[i.ID]
+
 		instances[i.ID] = i
 	}
 
@@ -1255,9 +1686,26 @@ func (ds *sqliteDB) addInstance(instance *types.Instance) error {
 	ds.dbLock.Lock()
 	defer ds.dbLock.Unlock()
 
-	_, err := db.Exec("INSERT INTO instances VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", instance.ID, instance.TenantID, instance.WorkloadID, instance.MACAddress, instance.VnicUUID, instance.Subnet, instance.IPAddress, instance.CreateTime.Format(time.RFC3339Nano), instance.Name, instance.CNCI)
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
 
-	return err
+	_, err = tx.Exec("INSERT INTO instances VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", instance.ID, instance.TenantID, instance.WorkloadID, instance.MACAddress, instance.VnicUUID, instance.Subnet, instance.IPAddress, instance.CreateTime.Format(time.RFC3339Nano), instance.Name, instance.CNCI)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, vnic := range instance.Vnics {
+		_, err = tx.Exec("INSERT INTO instance_vnics (instance_id, network_id, vnic_uuid, mac_address, subnet, ip) VALUES (?, ?, ?, ?, ?, ?)", instance.ID, vnic.NetworkID, vnic.VnicUUID, vnic.MACAddress, vnic.Subnet, vnic.IPAddress)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (ds *sqliteDB) deleteInstance(instanceID string) error {
@@ -1266,11 +1714,103 @@ func (ds *sqliteDB) deleteInstance(instanceID string) error {
 	ds.dbLock.Lock()
 	defer ds.dbLock.Unlock()
 
-	_, err := db.Exec("DELETE FROM instances WHERE id = ?", instanceID)
+	_, err := db.Exec("DELETE FROM instance_resources WHERE instance_id = ?", instanceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM instance_vnics WHERE instance_id = ?", instanceID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM instances WHERE id = ?", instanceID)
 
 	return err
 }
 
+// lock must be held by caller
+func (ds *sqliteDB) getInstanceResources() (map[string][]payloads.RequestedResource, error) {
+	db := ds.getTableDB("instance_resources")
+
+	rows, err := db.Query("SELECT instance_id, type, value FROM instance_resources")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := make(map[string][]payloads.RequestedResource)
+
+	for rows.Next() {
+		var instanceID string
+		var r payloads.RequestedResource
+
+		err = rows.Scan(&instanceID, &r.Type, &r.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		resources[instanceID] = append(resources[instanceID], r)
+	}
+
+	return resources, rows.Err()
+}
+
+// lock must be held by caller
+func (ds *sqliteDB) getInstanceVnics() (map[string][]types.InstanceVnic, error) {
+	db := ds.getTableDB("instance_vnics")
+
+	rows, err := db.Query("SELECT instance_id, network_id, vnic_uuid, mac_address, subnet, ip FROM instance_vnics")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vnics := make(map[string][]types.InstanceVnic)
+
+	for rows.Next() {
+		var instanceID string
+		var v types.InstanceVnic
+
+		err = rows.Scan(&instanceID, &v.NetworkID, &v.VnicUUID, &v.MACAddress, &v.Subnet, &v.IPAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		vnics[instanceID] = append(vnics[instanceID], v)
+	}
+
+	return vnics, rows.Err()
+}
+
+func (ds *sqliteDB) updateInstanceResources(instanceID string, resources []payloads.RequestedResource) error {
+	db := ds.getTableDB("instance_resources")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM instance_resources WHERE instance_id = ?", instanceID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, r := range resources {
+		_, err = tx.Exec("INSERT INTO instance_resources (instance_id, type, value) VALUES (?, ?, ?)", instanceID, string(r.Type), r.Value)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (ds *sqliteDB) updateInstance(instance *types.Instance) error {
 	db := ds.getTableDB("instances")
 
@@ -1614,7 +2154,8 @@ func (ds *sqliteDB) getTenantDevices(tenantID string) (map[string]types.BlockDat
 				block_data.create_time,
 				block_data.name,
 				block_data.description,
-				block_data.internal
+				block_data.internal,
+				block_data.volume_type
 		  FROM	block_data
 		  WHERE block_data.tenant_id = ?`
 
@@ -1628,7 +2169,7 @@ func (ds *sqliteDB) getTenantDevices(tenantID string) (map[string]types.BlockDat
 		var state string
 		var data types.BlockData
 
-		err = rows.Scan(&data.ID, &data.TenantID, &data.Size, &state, &data.CreateTime, &data.Name, &data.Description, &data.Internal)
+		err = rows.Scan(&data.ID, &data.TenantID, &data.Size, &state, &data.CreateTime, &data.Name, &data.Description, &data.Internal, &data.VolumeType)
 		if err != nil {
 			continue
 		}
@@ -1656,7 +2197,8 @@ func (ds *sqliteDB) getAllBlockData() (map[string]types.BlockData, error) {
 				block_data.create_time,
 				block_data.name,
 				block_data.description,
-				block_data.internal
+				block_data.internal,
+				block_data.volume_type
 		  FROM	block_data `
 
 	rows, err := db.Query(query)
@@ -1669,7 +2211,7 @@ func (ds *sqliteDB) getAllBlockData() (map[string]types.BlockData, error) {
 		var data types.BlockData
 		var state string
 
-		err = rows.Scan(&data.ID, &data.TenantID, &data.Size, &state, &data.CreateTime, &data.Name, &data.Description, &data.Internal)
+		err = rows.Scan(&data.ID, &data.TenantID, &data.Size, &state, &data.CreateTime, &data.Name, &data.Description, &data.Internal, &data.VolumeType)
 		if err != nil {
 			continue
 		}
@@ -1688,7 +2230,7 @@ func (ds *sqliteDB) addBlockData(data types.BlockData) error {
 	ds.dbLock.Lock()
 	defer ds.dbLock.Unlock()
 
-	err := ds.create("block_data", data.ID, data.TenantID, data.Size, string(data.State), data.CreateTime.Format(time.RFC3339Nano), data.Name, data.Description, data.Internal)
+	err := ds.create("block_data", data.ID, data.TenantID, data.Size, string(data.State), data.CreateTime.Format(time.RFC3339Nano), data.Name, data.Description, data.Internal, data.VolumeType)
 
 	return err
 }
@@ -1700,7 +2242,7 @@ func (ds *sqliteDB) updateBlockData(data types.BlockData) error {
 	ds.dbLock.Lock()
 	defer ds.dbLock.Unlock()
 
-	_, err := db.Exec("UPDATE block_data SET state = ? WHERE id = ?", string(data.State), data.ID)
+	_, err := db.Exec("UPDATE block_data SET state = ?, size = ? WHERE id = ?", string(data.State), data.Size, data.ID)
 
 	return err
 }
@@ -2187,3 +2729,577 @@ func (ds *sqliteDB) getQuotas(tenantID string) ([]types.QuotaDetails, error) {
 
 	return results, nil
 }
+
+func (ds *sqliteDB) addServerGroup(group types.ServerGroup) error {
+	db := ds.getTableDB("server_groups")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO server_groups (id, tenant_id, name, policy) VALUES (?, ?, ?, ?)", group.ID, group.TenantID, group.Name, string(group.Policy))
+
+	return err
+}
+
+func (ds *sqliteDB) deleteServerGroup(ID string) error {
+	db := ds.getTableDB("server_groups")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM server_groups WHERE id = ?", ID)
+
+	return err
+}
+
+func (ds *sqliteDB) getServerGroups() (map[string]types.ServerGroup, error) {
+	groups := make(map[string]types.ServerGroup)
+
+	db := ds.getTableDB("server_groups")
+
+	rows, err := db.Query("SELECT id, tenant_id, name, policy FROM server_groups")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting server groups from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group types.ServerGroup
+		var policy string
+
+		err = rows.Scan(&group.ID, &group.TenantID, &group.Name, &policy)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading server group row from database")
+		}
+
+		group.Policy = payloads.ServerGroupPolicy(policy)
+		groups[group.ID] = group
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading server groups from database")
+	}
+
+	rows, err = db.Query("SELECT group_id, instance_id FROM server_group_members")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting server group members from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID, instanceID string
+
+		err = rows.Scan(&groupID, &instanceID)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading server group member row from database")
+		}
+
+		group, ok := groups[groupID]
+		if !ok {
+			continue
+		}
+
+		group.Members = append(group.Members, instanceID)
+		groups[groupID] = group
+	}
+
+	return groups, errors.Wrap(rows.Err(), "error reading server group members from database")
+}
+
+func (ds *sqliteDB) addServerGroupMember(groupID string, instanceID string) error {
+	db := ds.getTableDB("server_group_members")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO server_group_members (group_id, instance_id) VALUES (?, ?)", groupID, instanceID)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteServerGroupMember(instanceID string) error {
+	db := ds.getTableDB("server_group_members")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM server_group_members WHERE instance_id = ?", instanceID)
+
+	return err
+}
+
+func (ds *sqliteDB) addSecurityGroup(group types.SecurityGroup) error {
+	db := ds.getTableDB("security_groups")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO security_groups (id, tenant_id, name, description) VALUES (?, ?, ?, ?)", group.ID, group.TenantID, group.Name, group.Description)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteSecurityGroup(ID string) error {
+	db := ds.getTableDB("security_groups")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM security_group_rules WHERE group_id = ?", ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM security_groups WHERE id = ?", ID)
+
+	return err
+}
+
+func (ds *sqliteDB) getSecurityGroups() (map[string]types.SecurityGroup, error) {
+	groups := make(map[string]types.SecurityGroup)
+
+	db := ds.getTableDB("security_groups")
+
+	rows, err := db.Query("SELECT id, tenant_id, name, description FROM security_groups")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting security groups from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group types.SecurityGroup
+
+		err = rows.Scan(&group.ID, &group.TenantID, &group.Name, &group.Description)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading security group row from database")
+		}
+
+		groups[group.ID] = group
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading security groups from database")
+	}
+
+	rows, err = db.Query("SELECT id, group_id, direction, protocol, port_min, port_max, remote_ip_prefix, remote_group_id FROM security_group_rules")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting security group rules from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rule types.SecurityGroupRule
+		var groupID, direction string
+
+		err = rows.Scan(&rule.ID, &groupID, &direction, &rule.Protocol, &rule.PortRangeMin,
+			&rule.PortRangeMax, &rule.RemoteIPPrefix, &rule.RemoteGroupID)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading security group rule row from database")
+		}
+
+		group, ok := groups[groupID]
+		if !ok {
+			continue
+		}
+
+		rule.Direction = payloads.SecurityGroupDirection(direction)
+		group.Rules = append(group.Rules, rule)
+		groups[groupID] = group
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading security group rules from database")
+	}
+
+	rows, err = db.Query("SELECT group_id, instance_id FROM security_group_members")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting security group members from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var groupID, instanceID string
+
+		err = rows.Scan(&groupID, &instanceID)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading security group member row from database")
+		}
+
+		group, ok := groups[groupID]
+		if !ok {
+			continue
+		}
+
+		group.Members = append(group.Members, instanceID)
+		groups[groupID] = group
+	}
+
+	return groups, errors.Wrap(rows.Err(), "error reading security group members from database")
+}
+
+func (ds *sqliteDB) addSecurityGroupRule(groupID string, rule types.SecurityGroupRule) error {
+	db := ds.getTableDB("security_group_rules")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO security_group_rules (id, group_id, direction, protocol, port_min, port_max, remote_ip_prefix, remote_group_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		rule.ID, groupID, string(rule.Direction), rule.Protocol, rule.PortRangeMin,
+		rule.PortRangeMax, rule.RemoteIPPrefix, rule.RemoteGroupID)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteSecurityGroupRule(ID string) error {
+	db := ds.getTableDB("security_group_rules")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM security_group_rules WHERE id = ?", ID)
+
+	return err
+}
+
+func (ds *sqliteDB) addSecurityGroupMember(groupID string, instanceID string) error {
+	db := ds.getTableDB("security_group_members")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO security_group_members (group_id, instance_id) VALUES (?, ?)", groupID, instanceID)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteSecurityGroupMembers(instanceID string) error {
+	db := ds.getTableDB("security_group_members")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM security_group_members WHERE instance_id = ?", instanceID)
+
+	return err
+}
+
+func (ds *sqliteDB) addTenantNetwork(network types.TenantNetwork) error {
+	db := ds.getTableDB("tenant_networks")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO tenant_networks (id, tenant_id, name, cidr) VALUES (?, ?, ?, ?)", network.ID, network.TenantID, network.Name, network.CIDR)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteTenantNetwork(ID string) error {
+	db := ds.getTableDB("tenant_networks")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM tenant_networks WHERE id = ?", ID)
+
+	return err
+}
+
+func (ds *sqliteDB) getTenantNetworks() (map[string]types.TenantNetwork, error) {
+	networks := make(map[string]types.TenantNetwork)
+
+	db := ds.getTableDB("tenant_networks")
+
+	rows, err := db.Query("SELECT id, tenant_id, name, cidr FROM tenant_networks")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting tenant networks from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var network types.TenantNetwork
+
+		err = rows.Scan(&network.ID, &network.TenantID, &network.Name, &network.CIDR)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading tenant network row from database")
+		}
+
+		networks[network.ID] = network
+	}
+
+	return networks, errors.Wrap(rows.Err(), "error reading tenant networks from database")
+}
+
+func (ds *sqliteDB) addSubnetKey(tenantID string, subnet int, key int) error {
+	db := ds.getTableDB("subnet_keys")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO subnet_keys (tenant_id, subnet, subnet_key) VALUES (?, ?, ?)", tenantID, subnet, key)
+
+	return err
+}
+
+func (ds *sqliteDB) getSubnetKeys() (map[string]map[int]int, error) {
+	keys := make(map[string]map[int]int)
+
+	db := ds.getTableDB("subnet_keys")
+
+	rows, err := db.Query("SELECT tenant_id, subnet, subnet_key FROM subnet_keys")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting subnet keys from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var tenantID string
+		var subnet, key int
+
+		err = rows.Scan(&tenantID, &subnet, &key)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading subnet key row from database")
+		}
+
+		if keys[tenantID] == nil {
+			keys[tenantID] = make(map[int]int)
+		}
+		keys[tenantID][subnet] = key
+	}
+
+	return keys, errors.Wrap(rows.Err(), "error reading subnet keys from database")
+}
+
+func (ds *sqliteDB) updateGeneration(generation int64) error {
+	db := ds.getTableDB("generation")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT OR REPLACE INTO generation (id, generation) VALUES (0, ?)", generation)
+
+	return err
+}
+
+func (ds *sqliteDB) getGeneration() (int64, error) {
+	db := ds.getTableDB("generation")
+
+	var generation int64
+	err := db.QueryRow("SELECT generation FROM generation WHERE id = 0").Scan(&generation)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return generation, errors.Wrap(err, "error getting generation from database")
+}
+
+func (ds *sqliteDB) addLoadBalancer(lb types.LoadBalancer) error {
+	db := ds.getTableDB("load_balancers")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO load_balancers (id, tenant_id, name, pool_id, external_ip, protocol, port, member_port, health_check_interval, health_check_timeout) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		lb.ID, lb.TenantID, lb.Name, lb.PoolID, lb.ExternalIP, lb.Protocol, lb.Port, lb.MemberPort, lb.HealthCheckInterval, lb.HealthCheckTimeout)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteLoadBalancer(ID string) error {
+	db := ds.getTableDB("load_balancers")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM load_balancer_members WHERE lb_id = ?", ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec("DELETE FROM load_balancers WHERE id = ?", ID)
+
+	return err
+}
+
+func (ds *sqliteDB) getLoadBalancers() (map[string]types.LoadBalancer, error) {
+	lbs := make(map[string]types.LoadBalancer)
+
+	db := ds.getTableDB("load_balancers")
+
+	rows, err := db.Query("SELECT id, tenant_id, name, pool_id, external_ip, protocol, port, member_port, health_check_interval, health_check_timeout FROM load_balancers")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting load balancers from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var lb types.LoadBalancer
+
+		err = rows.Scan(&lb.ID, &lb.TenantID, &lb.Name, &lb.PoolID, &lb.ExternalIP, &lb.Protocol, &lb.Port, &lb.MemberPort, &lb.HealthCheckInterval, &lb.HealthCheckTimeout)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading load balancer row from database")
+		}
+
+		lbs[lb.ID] = lb
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error reading load balancers from database")
+	}
+
+	rows, err = db.Query("SELECT lb_id, instance_id FROM load_balancer_members")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting load balancer members from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var lbID, instanceID string
+
+		err = rows.Scan(&lbID, &instanceID)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading load balancer member row from database")
+		}
+
+		lb, ok := lbs[lbID]
+		if !ok {
+			continue
+		}
+
+		lb.Members = append(lb.Members, instanceID)
+		lbs[lbID] = lb
+	}
+
+	return lbs, errors.Wrap(rows.Err(), "error reading load balancer members from database")
+}
+
+func (ds *sqliteDB) addLoadBalancerMember(lbID string, instanceID string) error {
+	db := ds.getTableDB("load_balancer_members")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO load_balancer_members (lb_id, instance_id) VALUES (?, ?)", lbID, instanceID)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteLoadBalancerMember(lbID string, instanceID string) error {
+	db := ds.getTableDB("load_balancer_members")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM load_balancer_members WHERE lb_id = ? AND instance_id = ?", lbID, instanceID)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteLoadBalancerMembers(instanceID string) error {
+	db := ds.getTableDB("load_balancer_members")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM load_balancer_members WHERE instance_id = ?", instanceID)
+
+	return err
+}
+
+func (ds *sqliteDB) addPortForward(pf types.PortForward) error {
+	db := ds.getTableDB("port_forwards")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO port_forwards (id, tenant_id, pool_id, external_ip, protocol, external_port, instance_id, internal_ip, internal_port) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		pf.ID, pf.TenantID, pf.PoolID, pf.ExternalIP, pf.Protocol, pf.ExternalPort, pf.InstanceID, pf.InternalIP, pf.InternalPort)
+
+	return err
+}
+
+func (ds *sqliteDB) deletePortForward(ID string) error {
+	db := ds.getTableDB("port_forwards")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM port_forwards WHERE id = ?", ID)
+
+	return err
+}
+
+func (ds *sqliteDB) getPortForwards() (map[string]types.PortForward, error) {
+	pfs := make(map[string]types.PortForward)
+
+	db := ds.getTableDB("port_forwards")
+
+	rows, err := db.Query("SELECT id, tenant_id, pool_id, external_ip, protocol, external_port, instance_id, internal_ip, internal_port FROM port_forwards")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting port forwards from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pf types.PortForward
+
+		err = rows.Scan(&pf.ID, &pf.TenantID, &pf.PoolID, &pf.ExternalIP, &pf.Protocol, &pf.ExternalPort, &pf.InstanceID, &pf.InternalIP, &pf.InternalPort)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading port forward row from database")
+		}
+
+		pfs[pf.ID] = pf
+	}
+
+	return pfs, errors.Wrap(rows.Err(), "error reading port forwards from database")
+}
+
+func (ds *sqliteDB) addSnapshot(snap types.SnapshotData) error {
+	db := ds.getTableDB("snapshots")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("INSERT INTO snapshots (id, volume_id, tenant_id, state, size, create_time, name, description) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		snap.ID, snap.VolumeID, snap.TenantID, string(snap.State), snap.Size, snap.CreateTime.Format(time.RFC3339Nano), snap.Name, snap.Description)
+
+	return err
+}
+
+func (ds *sqliteDB) deleteSnapshot(ID string) error {
+	db := ds.getTableDB("snapshots")
+
+	ds.dbLock.Lock()
+	defer ds.dbLock.Unlock()
+
+	_, err := db.Exec("DELETE FROM snapshots WHERE id = ?", ID)
+
+	return err
+}
+
+func (ds *sqliteDB) getSnapshots() (map[string]types.SnapshotData, error) {
+	snaps := make(map[string]types.SnapshotData)
+
+	db := ds.getTableDB("snapshots")
+
+	rows, err := db.Query("SELECT id, volume_id, tenant_id, state, size, create_time, name, description FROM snapshots")
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting snapshots from database")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snap types.SnapshotData
+		var state string
+
+		err = rows.Scan(&snap.ID, &snap.VolumeID, &snap.TenantID, &state, &snap.Size, &snap.CreateTime, &snap.Name, &snap.Description)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading snapshot row from database")
+		}
+
+		snap.State = types.BlockState(state)
+		snaps[snap.ID] = snap
+	}
+
+	return snaps, errors.Wrap(rows.Err(), "error reading snapshots from database")
+}
@@ -69,6 +69,7 @@ func TestSQLiteDBGetTenantDevices(t *testing.T) {
 		State:       types.Available,
 		TenantID:    uuid.Generate().String(),
 		CreateTime:  time.Now(),
+		VolumeType:  "fast-ssd",
 	}
 
 	err = db.addBlockData(data)
@@ -82,11 +83,15 @@ func TestSQLiteDBGetTenantDevices(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	_, ok := devices[data.ID]
+	device, ok := devices[data.ID]
 	if !ok {
 		t.Fatal("device not in map")
 	}
 
+	if device.VolumeType != data.VolumeType {
+		t.Fatalf("expected volume type %s, got %s", data.VolumeType, device.VolumeType)
+	}
+
 	db.disconnect()
 }
 
@@ -182,6 +187,45 @@ func TestSQLiteDBGetAllBlockData(t *testing.T) {
 	db.disconnect()
 }
 
+func TestSQLiteDBAddColumn(t *testing.T) {
+	ps := &sqliteDB{}
+	config := Config{
+		PersistentURI:     fmt.Sprintf("file:memdb%d?mode=memory&cache=shared", dbCount),
+		InitWorkloadsPath: *workloadsPath,
+	}
+	dbCount = dbCount + 2
+
+	err := ps.init(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.disconnect()
+
+	db := ps.getTableDB("block_data")
+
+	err = ps.exec(db, "CREATE TABLE old_block_data (id string primary_key, size integer)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = ps.addColumn(db, "old_block_data", "volume_type", "string")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := ps.hasColumn(db, "old_block_data", "volume_type")
+	if err != nil || !found {
+		t.Fatalf("Column not added: %v", err)
+	}
+
+	_, err = db.Exec("INSERT INTO old_block_data (id, size, volume_type) VALUES (?, ?, ?)", "id", 1, "fast-ssd")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestSQLiteDBDeleteBlockData(t *testing.T) {
 	db, err := getPersistentStore()
 	if err != nil {
@@ -1270,3 +1314,74 @@ func TestSQLiteDBDeleteTenant(t *testing.T) {
 		t.Fatal("Tenant Delete not successful")
 	}
 }
+
+func TestUpdateInstanceResources(t *testing.T) {
+	db, err := getPersistentStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := types.Instance{
+		ID:         uuid.Generate().String(),
+		TenantID:   uuid.Generate().String(),
+		WorkloadID: uuid.Generate().String(),
+		IPAddress:  "172.16.0.2",
+	}
+
+	err = db.addInstance(&i)
+	if err != nil {
+		t.Fatalf("unable to store instance: %v\n", err)
+	}
+
+	resources := []payloads.RequestedResource{
+		{Type: payloads.VCPUs, Value: 4},
+		{Type: payloads.MemMB, Value: 1024},
+	}
+
+	err = db.updateInstanceResources(i.ID, resources)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	instances, err := db.getInstances()
+	if err != nil || len(instances) != 1 {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(instances[0].Resources, resources) == false {
+		t.Fatalf("expected %v, got %v\n", resources, instances[0].Resources)
+	}
+
+	err = db.deleteInstance(i.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.disconnect()
+}
+
+func TestSQLiteDBGeneration(t *testing.T) {
+	db, err := getPersistentStore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generation, err := db.getGeneration()
+	if err != nil || generation != 0 {
+		t.Fatalf("Unexpected initial generation %d: %v", generation, err)
+	}
+
+	for _, g := range []int64{1, 2} {
+		err = db.updateGeneration(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		generation, err = db.getGeneration()
+		if err != nil || generation != g {
+			t.Fatalf("Generation %d not stored, got %d: %v", g, generation, err)
+		}
+	}
+
+	db.disconnect()
+}
@@ -80,6 +80,8 @@ var supportedResources = [...]payloads.Resource{
 	payloads.Instance,
 	payloads.Image,
 	payloads.ExternalIP,
+	payloads.Snapshot,
+	payloads.SnapshotGiB,
 }
 
 func makeTentantData() *tenantData {
@@ -191,6 +193,10 @@ func quotaNameToResource(name string) payloads.Resource {
 		return payloads.Image
 	case "tenant-external-ips-quota":
 		return payloads.ExternalIP
+	case "tenant-snapshots-quota":
+		return payloads.Snapshot
+	case "tenant-snapshot-storage-quota":
+		return payloads.SnapshotGiB
 	}
 
 	return ""
@@ -212,6 +218,10 @@ func resourceToQuotaName(r payloads.Resource) string {
 		return "tenant-images-quota"
 	case payloads.ExternalIP:
 		return "tenant-external-ips-quota"
+	case payloads.Snapshot:
+		return "tenant-snapshots-quota"
+	case payloads.SnapshotGiB:
+		return "tenant-snapshot-storage-quota"
 	}
 	return ""
 }
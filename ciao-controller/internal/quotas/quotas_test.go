@@ -129,6 +129,8 @@ func TestResourceQuotaMapping(t *testing.T) {
 		payloads.Instance,
 		payloads.Image,
 		payloads.ExternalIP,
+		payloads.Snapshot,
+		payloads.SnapshotGiB,
 	}
 
 	for _, resource := range resources {
@@ -144,6 +144,9 @@ func legacyComputeRoutes(ctl *controller, r *mux.Router) *mux.Router {
 	r.Handle("/v2.1/{tenant}/servers/action",
 		legacyAPIHandler{ctl, tenantServersAction, false}).Methods("POST")
 
+	r.Handle("/v2.1/{tenant}/servers/{server}/console",
+		consoleHandler{ctl}).Methods("GET")
+
 	r.Handle("/v2.1/{tenant}/resources",
 		legacyAPIHandler{ctl, listTenantResources, false}).Methods("GET")
 
@@ -0,0 +1,304 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp/uuid"
+	"github.com/golang/glog"
+)
+
+const (
+	defaultHealthCheckInterval = 5
+	defaultHealthCheckTimeout  = 2
+)
+
+func (c *controller) makeLoadBalancerLinks(lb *types.LoadBalancer) {
+	ref := fmt.Sprintf("%s/%s/load-balancers/%s", c.apiURL, lb.TenantID, lb.ID)
+
+	link := types.Link{
+		Rel:  "self",
+		Href: ref,
+	}
+
+	lb.Links = []types.Link{link}
+
+	// we want an empty list rather than null in the API
+	if lb.Members == nil {
+		lb.Members = []string{}
+	}
+}
+
+func validLoadBalancerRequest(req types.LoadBalancerRequest) bool {
+	if req.Name == "" || req.Protocol != "tcp" {
+		return false
+	}
+
+	if req.Port <= 0 || req.Port > 65535 ||
+		req.MemberPort < 0 || req.MemberPort > 65535 {
+		return false
+	}
+
+	return req.HealthCheckInterval >= 0 && req.HealthCheckTimeout >= 0
+}
+
+// checkLoadBalancerMember checks that an instance of the tenant may be
+// added to a load balancer: all the members of a load balancer are on the
+// same subnet, whose CNCI runs the load balancer.
+func (c *controller) checkLoadBalancerMember(tenantID string, members []string, instanceID string) error {
+	i, err := c.ds.GetTenantInstance(tenantID, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if i.CNCI {
+		return types.ErrInstanceNotFound
+	}
+
+	for _, member := range members {
+		m, err := c.ds.GetInstance(member)
+		if err != nil {
+			continue
+		}
+
+		if m.Subnet != i.Subnet {
+			return types.ErrLoadBalancerMemberSubnet
+		}
+	}
+
+	return nil
+}
+
+func (c *controller) CreateLoadBalancer(tenantID string, req types.LoadBalancerRequest) (lb types.LoadBalancer, err error) {
+	if req.Protocol == "" {
+		req.Protocol = "tcp"
+	}
+
+	if !validLoadBalancerRequest(req) {
+		glog.V(2).Infof("Invalid load balancer request %+v", req)
+		return types.LoadBalancer{}, types.ErrBadRequest
+	}
+
+	err = c.confirmTenant(tenantID)
+	if err != nil {
+		return types.LoadBalancer{}, err
+	}
+
+	lb = types.LoadBalancer{
+		ID:                  uuid.Generate().String(),
+		TenantID:            tenantID,
+		Name:                req.Name,
+		Protocol:            req.Protocol,
+		Port:                req.Port,
+		MemberPort:          req.MemberPort,
+		HealthCheckInterval: req.HealthCheckInterval,
+		HealthCheckTimeout:  req.HealthCheckTimeout,
+	}
+
+	if lb.MemberPort == 0 {
+		lb.MemberPort = lb.Port
+	}
+
+	if lb.HealthCheckInterval == 0 {
+		lb.HealthCheckInterval = defaultHealthCheckInterval
+	}
+
+	if lb.HealthCheckTimeout == 0 {
+		lb.HealthCheckTimeout = defaultHealthCheckTimeout
+	}
+
+	for _, instanceID := range req.Members {
+		err = c.checkLoadBalancerMember(tenantID, lb.Members, instanceID)
+		if err != nil {
+			return types.LoadBalancer{}, err
+		}
+
+		lb.Members = append(lb.Members, instanceID)
+	}
+
+	// the external IP of a load balancer counts against the same quota as
+	// the ones mapped to instances.  It is released in DeleteLoadBalancer.
+	res := <-c.qs.Consume(tenantID, payloads.RequestedResource{Type: payloads.ExternalIP, Value: 1})
+	defer func() {
+		if err != nil {
+			c.qs.Release(tenantID, payloads.RequestedResource{Type: payloads.ExternalIP, Value: 1})
+		}
+	}()
+
+	if !res.Allowed() {
+		return types.LoadBalancer{}, types.ErrQuota
+	}
+
+	pools, err := c.ds.GetPools()
+	if err != nil {
+		return types.LoadBalancer{}, err
+	}
+
+	err = types.ErrPoolEmpty
+	if req.PoolName != "" {
+		err = types.ErrPoolNotFound
+	}
+
+	for _, pool := range pools {
+		if req.PoolName != "" {
+			if pool.Name == req.PoolName {
+				lb, err = c.ds.AddLoadBalancer(pool.ID, lb)
+				break
+			}
+		} else if pool.Free > 0 {
+			lb, err = c.ds.AddLoadBalancer(pool.ID, lb)
+			break
+		}
+	}
+
+	if err != nil {
+		return types.LoadBalancer{}, err
+	}
+
+	c.updateLoadBalancers(tenantID)
+
+	c.makeLoadBalancerLinks(&lb)
+
+	return lb, nil
+}
+
+func (c *controller) ListLoadBalancers(tenantID string) ([]types.LoadBalancer, error) {
+	lbs := c.ds.GetLoadBalancers(tenantID)
+
+	for i := range lbs {
+		c.makeLoadBalancerLinks(&lbs[i])
+	}
+
+	return lbs, nil
+}
+
+func (c *controller) ShowLoadBalancer(tenantID string, lbID string) (types.LoadBalancer, error) {
+	lb, err := c.ds.GetLoadBalancer(tenantID, lbID)
+	if err != nil {
+		return lb, err
+	}
+
+	c.makeLoadBalancerLinks(&lb)
+
+	return lb, nil
+}
+
+func (c *controller) DeleteLoadBalancer(tenantID string, lbID string) error {
+	err := c.ds.DeleteLoadBalancer(tenantID, lbID)
+	if err != nil {
+		return err
+	}
+
+	c.qs.Release(tenantID, payloads.RequestedResource{Type: payloads.ExternalIP, Value: 1})
+
+	c.updateLoadBalancers(tenantID)
+
+	return nil
+}
+
+func (c *controller) AddLoadBalancerMember(tenantID string, lbID string, instanceID string) error {
+	lb, err := c.ds.GetLoadBalancer(tenantID, lbID)
+	if err != nil {
+		return err
+	}
+
+	err = c.checkLoadBalancerMember(tenantID, lb.Members, instanceID)
+	if err != nil {
+		return err
+	}
+
+	err = c.ds.AddLoadBalancerMember(tenantID, lbID, instanceID)
+	if err != nil {
+		return err
+	}
+
+	c.updateLoadBalancers(tenantID)
+
+	return nil
+}
+
+func (c *controller) DeleteLoadBalancerMember(tenantID string, lbID string, instanceID string) error {
+	err := c.ds.DeleteLoadBalancerMember(tenantID, lbID, instanceID)
+	if err != nil {
+		return err
+	}
+
+	c.updateLoadBalancers(tenantID)
+
+	return nil
+}
+
+// updateLoadBalancers sends the load balancers of a tenant to the CNCIs of
+// the subnets of their members.  Each CNCI replaces the load balancers it
+// runs with the ones it is sent, so CNCIs which no longer run any load
+// balancer are sent an empty list.  Load balancers without members are not
+// run by any CNCI.
+func (c *controller) updateLoadBalancers(tenantID string) {
+	c.cnciUpdateLock.Lock()
+	defer c.cnciUpdateLock.Unlock()
+
+	cncis, err := c.ds.GetTenantCNCIs(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update load balancers of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	subnets := make(map[string][]payloads.LoadBalancer)
+	for _, lb := range c.ds.GetLoadBalancers(tenantID) {
+		var subnet string
+		var memberIPs []string
+
+		for _, member := range lb.Members {
+			i, err := c.ds.GetInstance(member)
+			if err != nil || i.IPAddress == "" {
+				continue
+			}
+
+			subnet = i.Subnet
+			memberIPs = append(memberIPs, i.IPAddress)
+		}
+
+		if subnet == "" {
+			continue
+		}
+
+		subnets[subnet] = append(subnets[subnet], payloads.LoadBalancer{
+			LoadBalancerUUID: lb.ID,
+			PublicIP:         lb.ExternalIP,
+			Protocol:         lb.Protocol,
+			Port:             lb.Port,
+			MemberPort:       lb.MemberPort,
+			MemberIPs:        memberIPs,
+			HealthInterval:   lb.HealthCheckInterval,
+			HealthTimeout:    lb.HealthCheckTimeout,
+		})
+	}
+
+	for _, cnci := range cncis {
+		cmd := payloads.LoadBalancersCmd{
+			ConcentratorUUID: cnci.ID,
+			TenantUUID:       tenantID,
+			LoadBalancers:    subnets[cnci.Subnet],
+		}
+
+		err = c.client.setLoadBalancers(cmd)
+		if err != nil {
+			glog.Warningf("Unable to send load balancers to CNCI %s: %v", cnci.ID, err)
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"sync"
 	"syscall"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/ciao-project/ciao/clogger/gloginterface"
 	"github.com/ciao-project/ciao/database"
 	"github.com/ciao-project/ciao/osprepare"
+	"github.com/ciao-project/ciao/payloads"
 	"github.com/ciao-project/ciao/ssntp"
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
@@ -55,11 +57,16 @@ type controller struct {
 	tenantReadinessLock sync.Mutex
 	qs                  *quotas.Quotas
 	httpServers         []*http.Server
+	networkReports      nodeNetworkReports
+	volumeTypes         storage.VolumeTypes
+	// serialises the updates replacing the state of the CNCIs so that
+	// they are generated in the order they are sent
+	cnciUpdateLock sync.Mutex
 }
 
 var cert = flag.String("cert", "", "Client certificate")
 var caCert = flag.String("cacert", "", "CA certificate")
-var serverURL = flag.String("url", "", "Server URL")
+var serverURL = flag.String("url", "", "Server URL, or comma separated list of scheduler URLs to fail over between")
 var controllerAPIPort = api.Port
 var httpsCAcert = "/etc/pki/ciao/ciao-controller-cacert.pem"
 var httpsKey = "/etc/pki/ciao/ciao-controller-key.pem"
@@ -76,6 +83,13 @@ var cephID = flag.String("ceph_id", "", "ceph client id")
 
 var adminSSHKey = ""
 
+// blockDriverConfig selects the block driver storing the volumes and images
+var blockDriverConfig payloads.ConfigureStorage
+
+// distributedRouting enables the routing between the subnets of a tenant
+// on the compute nodes
+var distributedRouting = false
+
 // default password set to "ciao"
 var adminPassword = "$6$rounds=4096$w9I3hR4g/hu$AnYjaC2DfznbPSG3vxsgtgAS4mJwWBkcR74Y/KHNB5OsfAlA4gpU5j6CHWMOkkt9j.9d7OYJXJ4icXHzKXTAO."
 
@@ -143,12 +157,17 @@ func main() {
 	populateQuotasFromDatastore(ctl.qs, ctl.ds)
 
 	config := &ssntp.Config{
-		URI:    *serverURL,
 		CAcert: *caCert,
 		Cert:   *cert,
 		Log:    ssntp.Log,
 	}
 
+	if *serverURL != "" {
+		urls := strings.Split(*serverURL, ",")
+		config.URI = urls[0]
+		config.URIs = urls[1:]
+	}
+
 	ctl.client, err = newSSNTPClient(ctl, config)
 	if err != nil {
 		// spawn some retry routine?
@@ -169,12 +188,15 @@ func main() {
 	if *cephID == "" {
 		*cephID = clusterConfig.Configure.Storage.CephID
 	}
+	blockDriverConfig = clusterConfig.Configure.Storage
+	blockDriverConfig.CephID = *cephID
 
 	cnciVCPUs := clusterConfig.Configure.Controller.CNCIVcpus
 	cnciMem := clusterConfig.Configure.Controller.CNCIMem
 	cnciDisk := clusterConfig.Configure.Controller.CNCIDisk
 
 	adminSSHKey = clusterConfig.Configure.Controller.AdminSSHKey
+	distributedRouting = clusterConfig.Configure.Controller.DistributedRouting
 
 	if clusterConfig.Configure.Controller.AdminPassword != "" {
 		adminPassword = clusterConfig.Configure.Controller.AdminPassword
@@ -196,12 +218,12 @@ func main() {
 	osprepare.Bootstrap(context.TODO(), logger)
 	osprepare.InstallDeps(context.TODO(), controllerDeps, logger)
 
-	ctl.BlockDriver = func() storage.BlockDriver {
-		driver := storage.CephDriver{
-			ID: *cephID,
-		}
-		return driver
-	}()
+	ctl.volumeTypes, err = storage.NewVolumeTypes(blockDriverConfig)
+	if err != nil {
+		glog.Fatalf("Unable to initialize block drivers: %v", err)
+		return
+	}
+	ctl.BlockDriver = ctl.volumeTypes[""]
 
 	err = initializeCNCICtrls(ctl)
 	if err != nil {
@@ -14,12 +14,76 @@
 
 package main
 
+import (
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/golang/glog"
+)
+
 func (c *controller) EvacuateNode(nodeID string) error {
 	// should I bother to see if nodeID is valid?
-	go c.client.EvacuateNode(nodeID)
+	go func() {
+		if err := c.client.EvacuateNode(nodeID); err != nil {
+			glog.Warningf("Unable to evacuate node %s: %v", nodeID, err)
+			return
+		}
+		c.migrateInstances(nodeID)
+	}()
 	return nil
 }
 
+// migrateInstances live migrates the running VMs hosted on an evacuated
+// node that boot from a volume.  Instances that cannot be migrated are
+// stopped by the node itself when it processes the EVACUATE command.
+func (c *controller) migrateInstances(nodeID string) {
+	instances, err := c.ds.GetAllInstancesByNode(nodeID)
+	if err != nil {
+		glog.Warningf("Unable to retrieve instances for node %s: %v", nodeID, err)
+		return
+	}
+
+	for _, i := range instances {
+		if i.CNCI || i.State != payloads.Running {
+			continue
+		}
+
+		w, err := c.ds.GetWorkload(i.TenantID, i.WorkloadID)
+		if err != nil {
+			glog.Warningf("Unable to migrate %s: %v", i.ID, err)
+			continue
+		}
+
+		if w.VMType == payloads.Docker || !c.bootsFromVolume(i.ID) {
+			continue
+		}
+
+		t, err := c.ds.GetTenant(i.TenantID)
+		if err != nil {
+			glog.Warningf("Unable to migrate %s: %v", i.ID, err)
+			continue
+		}
+
+		if err := c.client.MigrateInstance(i, &w, t); err != nil {
+			glog.Warningf("Unable to migrate %s: %v", i.ID, err)
+		}
+	}
+}
+
+// bootsFromVolume returns true if an instance boots from a volume, which
+// the node it is migrated to can access.
+func (c *controller) bootsFromVolume(instanceID string) bool {
+	for _, a := range c.ds.GetStorageAttachments(instanceID) {
+		if a.Boot {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *controller) LabelNode(nodeID string, labels map[string]string) error {
+	return c.client.LabelNode(nodeID, labels)
+}
+
 func (c *controller) RestoreNode(nodeID string) error {
 	go c.client.RestoreNode(nodeID)
 	return nil
@@ -0,0 +1,189 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/golang/glog"
+)
+
+// networkInspectTimeout is how long an inspection waits for the node to
+// report its network topology.
+var networkInspectTimeout = 30 * time.Second
+
+// nodeNetworkReports holds the last network report of each node and the
+// inspections waiting for the next one.  Reports are not persisted, a node
+// is inspected again after a controller restart.
+type nodeNetworkReports struct {
+	sync.Mutex
+	reports map[string]types.NodeNetwork
+	waiters map[string][]chan types.NodeNetwork
+}
+
+func toNodeNetwork(report payloads.NetworkReportEvent) types.NodeNetwork {
+	n := types.NodeNetwork{
+		NodeID:    report.NodeUUID,
+		Timestamp: time.Now(),
+		Repair:    report.Repair,
+		Expected:  []types.NodeNetworkLink{},
+		Actual:    []types.NodeNetworkLink{},
+		Dnsmasq:   []types.NodeNetworkDnsmasq{},
+		Problems:  []types.NodeNetworkProblem{},
+		Error:     report.Error,
+	}
+
+	for _, l := range report.Expected {
+		n.Expected = append(n.Expected, types.NodeNetworkLink{
+			Alias:  l.Alias,
+			Name:   l.Name,
+			Master: l.Master,
+		})
+	}
+
+	for _, l := range report.Actual {
+		n.Actual = append(n.Actual, types.NodeNetworkLink{
+			Alias:  l.Alias,
+			Name:   l.Name,
+			Master: l.Master,
+			Up:     l.Up,
+		})
+	}
+
+	for _, d := range report.Dnsmasq {
+		n.Dnsmasq = append(n.Dnsmasq, types.NodeNetworkDnsmasq{
+			Bridge:  d.Bridge,
+			PID:     d.PID,
+			Running: d.Running,
+		})
+	}
+
+	for _, p := range report.Problems {
+		n.Problems = append(n.Problems, types.NodeNetworkProblem{
+			Alias:    p.Alias,
+			Problem:  p.Problem,
+			Repaired: p.Repaired,
+		})
+	}
+
+	return n
+}
+
+// networkReported records the network report of a node and hands it to the
+// inspections waiting for it.
+func (c *controller) networkReported(report payloads.NetworkReportEvent) {
+	n := toNodeNetwork(report)
+
+	c.networkReports.Lock()
+	defer c.networkReports.Unlock()
+
+	if c.networkReports.reports == nil {
+		c.networkReports.reports = make(map[string]types.NodeNetwork)
+	}
+	c.networkReports.reports[n.NodeID] = n
+
+	for _, ch := range c.networkReports.waiters[n.NodeID] {
+		ch <- n
+	}
+	delete(c.networkReports.waiters, n.NodeID)
+}
+
+func (c *controller) waitNetworkReport(nodeID string) chan types.NodeNetwork {
+	ch := make(chan types.NodeNetwork, 1)
+
+	c.networkReports.Lock()
+	defer c.networkReports.Unlock()
+
+	if c.networkReports.waiters == nil {
+		c.networkReports.waiters = make(map[string][]chan types.NodeNetwork)
+	}
+	c.networkReports.waiters[nodeID] = append(c.networkReports.waiters[nodeID], ch)
+
+	return ch
+}
+
+func (c *controller) cancelNetworkReport(nodeID string, ch chan types.NodeNetwork) {
+	c.networkReports.Lock()
+	defer c.networkReports.Unlock()
+
+	waiters := c.networkReports.waiters[nodeID]
+	for i := range waiters {
+		if waiters[i] == ch {
+			c.networkReports.waiters[nodeID] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+
+	if len(c.networkReports.waiters[nodeID]) == 0 {
+		delete(c.networkReports.waiters, nodeID)
+	}
+}
+
+// confirmNetworkNode checks that nodeID is a connected node or a CNCI, the
+// agents which can inspect their network.
+func (c *controller) confirmNetworkNode(nodeID string) error {
+	if _, err := c.ds.GetNode(nodeID); err == nil {
+		return nil
+	}
+
+	i, err := c.ds.GetInstance(nodeID)
+	if err != nil || !i.CNCI {
+		return types.ErrNodeNotFound
+	}
+
+	return nil
+}
+
+// InspectNodeNetwork asks a node to inspect, and optionally repair, its
+// network topology and waits for its report.
+func (c *controller) InspectNodeNetwork(nodeID string, repair bool) (types.NodeNetwork, error) {
+	err := c.confirmNetworkNode(nodeID)
+	if err != nil {
+		return types.NodeNetwork{}, err
+	}
+
+	ch := c.waitNetworkReport(nodeID)
+
+	err = c.client.InspectNetwork(nodeID, repair)
+	if err != nil {
+		c.cancelNetworkReport(nodeID, ch)
+		return types.NodeNetwork{}, err
+	}
+
+	select {
+	case n := <-ch:
+		return n, nil
+	case <-time.After(networkInspectTimeout):
+		c.cancelNetworkReport(nodeID, ch)
+		glog.Warningf("Node %s did not report its network", nodeID)
+		return types.NodeNetwork{}, types.ErrNodeNetworkTimeout
+	}
+}
+
+// ShowNodeNetwork returns the last network report of a node.
+func (c *controller) ShowNodeNetwork(nodeID string) (types.NodeNetwork, error) {
+	c.networkReports.Lock()
+	defer c.networkReports.Unlock()
+
+	n, ok := c.networkReports.reports[nodeID]
+	if !ok {
+		return types.NodeNetwork{}, types.ErrNodeNetworkNotFound
+	}
+
+	return n, nil
+}
@@ -16,7 +16,6 @@ package main
 
 import (
 	"fmt"
-	"regexp"
 	"sort"
 	"strconv"
 
@@ -44,8 +43,9 @@ func instanceToServer(ctl *controller, instance *types.Instance) (compute.Server
 		Status:     instance.State,
 		PrivateAddresses: []compute.PrivateAddresses{
 			{
-				Addr:    instance.IPAddress,
-				MacAddr: instance.MACAddress,
+				Addr:     instance.IPAddress,
+				IPv6Addr: tenantIPv6Addr(instance.IPAddress, instance.Subnet),
+				MacAddr:  instance.MACAddress,
 			},
 		},
 		Volumes: volumes,
@@ -55,6 +55,15 @@ func instanceToServer(ctl *controller, instance *types.Instance) (compute.Server
 		Name:    instance.Name,
 	}
 
+	for _, vnic := range instance.Vnics {
+		server.PrivateAddresses = append(server.PrivateAddresses,
+			compute.PrivateAddresses{
+				Addr:     vnic.IPAddress,
+				IPv6Addr: tenantIPv6Addr(vnic.IPAddress, vnic.Subnet),
+				MacAddr:  vnic.MACAddress,
+			})
+	}
+
 	return server, nil
 }
 
@@ -343,12 +352,9 @@ func (c *controller) CreateServer(tenant string, server compute.CreateServerRequ
 		nInstances = server.Server.MinInstances
 	}
 
-	if server.Server.Name != "" {
-		// Between 1 and 64 (HOST_NAME_MAX) alphanum (+ "-")
-		r := regexp.MustCompile("^[a-z0-9-]{1,64}?$")
-		if !r.MatchString(server.Server.Name) {
-			return server, fmt.Errorf("Requested name must be between 1 and 64 lowercase letters, numbers and hyphens")
-		}
+	// A DNS label of 1 to 63 lowercase alphanum (+ "-")
+	if server.Server.Name != "" && !validInstanceName(server.Server.Name) {
+		return server, fmt.Errorf("Requested name must be between 1 and 63 lowercase letters, numbers and hyphens, and must not start or end with a hyphen")
 	}
 
 	blockDeviceMappings := server.Server.BlockDeviceMappings
@@ -368,6 +374,19 @@ func (c *controller) CreateServer(tenant string, server compute.CreateServerRequ
 		Volumes:    volumes,
 		Name:       server.Server.Name,
 	}
+
+	if server.SchedulerHints != nil {
+		w.ServerGroup = server.SchedulerHints.Group
+	}
+
+	for _, group := range server.Server.SecurityGroups {
+		w.SecurityGroups = append(w.SecurityGroups, group.Name)
+	}
+
+	for _, network := range server.Server.Networks {
+		w.Networks = append(w.Networks, network.UUID)
+	}
+
 	var e error
 	instances, err := c.startWorkload(w)
 	if err != nil {
@@ -503,6 +522,44 @@ func (c *controller) StopServer(tenant string, ID string) error {
 	return err
 }
 
+func (c *controller) ResizeServer(tenant string, ID string, flavor string) error {
+	_, err := c.ds.GetTenantInstance(tenant, ID)
+	if err != nil {
+		return compute.ErrServerNotFound
+	}
+
+	err = c.resizeInstance(ID, flavor)
+	switch err {
+	case types.ErrInstanceNotStopped:
+		return compute.ErrInstanceNotAvailable
+	case types.ErrWorkloadNotFound:
+		return compute.ErrFlavorNotFound
+	case types.ErrInvalidFlavor:
+		return compute.ErrInvalidFlavor
+	case types.ErrQuota:
+		return compute.ErrQuota
+	}
+
+	return err
+}
+
+func (c *controller) CreateServerImage(tenant string, ID string, name string) (string, error) {
+	_, err := c.ds.GetTenantInstance(tenant, ID)
+	if err != nil {
+		return "", compute.ErrServerNotFound
+	}
+
+	imageID, err := c.snapshotInstance(ID, name)
+	switch err {
+	case types.ErrInstanceNotStopped, types.ErrNoBootVolume, types.ErrBootVolumeType:
+		return "", compute.ErrInstanceNotAvailable
+	case types.ErrQuota:
+		return "", compute.ErrQuota
+	}
+
+	return imageID, err
+}
+
 func (c *controller) createComputeRoutes(r *mux.Router) error {
 	config := compute.APIConfig{ComputeService: c}
 	compute.Routes(config, r)
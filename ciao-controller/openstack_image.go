@@ -233,17 +233,20 @@ func (is *ImageService) Init(qs *quotas.Quotas) error {
 		return errors.Wrap(err, "Error on DB Tables Initialization")
 	}
 
+	blockDriver, err := storage.NewBlockDriver(blockDriverConfig)
+	if err != nil {
+		return errors.Wrap(err, "Error on block driver initialization")
+	}
+
 	rawDs := &imageDatastore.Ceph{
 		ImageTempDir: *imagesPath,
-		BlockDriver: storage.CephDriver{
-			ID: *cephID,
-		},
+		BlockDriver:  blockDriver,
 	}
 
 	glog.Info("ciao-image - Initialize raw datastore")
 	glog.Infof("rawDs        : %T", rawDs)
 	glog.Infof("ImageTempDir : %v", rawDs.ImageTempDir)
-	glog.Infof("ID           : %v", rawDs.BlockDriver.ID)
+	glog.Infof("BlockDriver  : %T", rawDs.BlockDriver)
 
 	config := ImageConfig{
 		HTTPSCACert:   httpsCAcert,
@@ -23,10 +23,38 @@ import (
 	"github.com/ciao-project/ciao/ciao-storage"
 	"github.com/ciao-project/ciao/openstack/block"
 	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp/uuid"
 	"github.com/golang/glog"
 	"github.com/gorilla/mux"
 )
 
+// volumeDriver returns the block driver storing the volumes of a volume
+// type.  The volumes of the default volume type, and the images, are
+// stored by the block driver of the controller.
+func (c *controller) volumeDriver(volumeType string) (storage.BlockDriver, error) {
+	if volumeType == "" {
+		return c.BlockDriver, nil
+	}
+
+	driver, err := c.volumeTypes.Driver(volumeType)
+	if err != nil {
+		return nil, block.ErrVolumeType
+	}
+
+	return driver, nil
+}
+
+// sourceVolumeType returns the volume type of a volume created from a
+// source, which is cloned by the block driver storing it.  The requested
+// volume type, if any, must be the volume type of the source.
+func sourceVolumeType(requested string, source string) (string, error) {
+	if requested != "" && requested != source {
+		return "", block.ErrVolumeType
+	}
+
+	return source, nil
+}
+
 // CreateVolume will create a new block device and store it in the datastore.
 func (c *controller) CreateVolume(tenant string, req block.RequestedVolume) (block.Volume, error) {
 	err := c.confirmTenant(tenant)
@@ -35,22 +63,56 @@ func (c *controller) CreateVolume(tenant string, req block.RequestedVolume) (blo
 	}
 
 	var bd storage.BlockDevice
+	var snap types.SnapshotData
+	var source types.BlockData
+	volumeType := req.VolumeType
+
+	if req.ImageRef != "" {
+		volumeType, err = sourceVolumeType(volumeType, "")
+	} else if req.SourceVolID != "" {
+		source, err = c.ds.GetBlockDevice(req.SourceVolID)
+		if err == nil {
+			volumeType, err = sourceVolumeType(volumeType, source.VolumeType)
+		}
+	} else if req.SnapshotID != "" {
+		snap, err = c.getTenantSnapshot(tenant, req.SnapshotID)
+		if err == nil {
+			source, err = c.ds.GetBlockDevice(snap.VolumeID)
+		}
+		if err == nil {
+			volumeType, err = sourceVolumeType(volumeType, source.VolumeType)
+		}
+	}
+	if err != nil {
+		return block.Volume{}, err
+	}
+
+	driver, err := c.volumeDriver(volumeType)
+	if err != nil {
+		return block.Volume{}, err
+	}
 
 	// no limits checking for now.
 	if req.ImageRef != "" {
 		// create bootable volume
-		bd, err = c.CreateBlockDeviceFromSnapshot(req.ImageRef, "ciao-image")
+		bd, err = driver.CreateBlockDeviceFromSnapshot(req.ImageRef, "ciao-image")
 		bd.Bootable = true
 	} else if req.SourceVolID != "" {
 		// copy existing volume
-		bd, err = c.CopyBlockDevice(req.SourceVolID)
+		bd, err = driver.CopyBlockDevice(req.SourceVolID)
+	} else if req.SnapshotID != "" {
+		// restore a snapshot to a new volume
+		bd, err = driver.CreateBlockDeviceFromSnapshot(snap.VolumeID, snap.ID)
+		if req.Size < snap.Size {
+			req.Size = snap.Size
+		}
 	} else {
 		// create empty volume
-		bd, err = c.CreateBlockDevice("", "", req.Size)
+		bd, err = driver.CreateBlockDevice("", "", req.Size)
 	}
 
 	if err == nil && req.Size > bd.Size {
-		bd.Size, err = c.Resize(bd.ID, req.Size)
+		bd.Size, err = driver.Resize(bd.ID, req.Size)
 	}
 
 	if err != nil {
@@ -68,6 +130,7 @@ func (c *controller) CreateVolume(tenant string, req block.RequestedVolume) (blo
 		State:       types.Available,
 		Name:        req.Name,
 		Description: req.Description,
+		VolumeType:  volumeType,
 	}
 
 	// It's best to make the quota request here as we don't know the volume
@@ -78,14 +141,14 @@ func (c *controller) CreateVolume(tenant string, req block.RequestedVolume) (blo
 		payloads.RequestedResource{Type: payloads.SharedDiskGiB, Value: bd.Size})
 
 	if !res.Allowed() {
-		c.DeleteBlockDevice(bd.ID)
+		driver.DeleteBlockDevice(bd.ID)
 		c.qs.Release(tenant, res.Resources()...)
 		return block.Volume{}, block.ErrQuota
 	}
 
 	err = c.ds.AddBlockDevice(data)
 	if err != nil {
-		c.DeleteBlockDevice(bd.ID)
+		driver.DeleteBlockDevice(bd.ID)
 		c.qs.Release(tenant, res.Resources()...)
 		return block.Volume{}, err
 	}
@@ -100,6 +163,8 @@ func (c *controller) CreateVolume(tenant string, req block.RequestedVolume) (blo
 		ID:          bd.ID,
 		Size:        data.Size,
 		Bootable:    strconv.FormatBool(data.Bootable),
+		SnapshotID:  req.SnapshotID,
+		VolumeType:  volumeType,
 	}, nil
 }
 
@@ -125,6 +190,16 @@ func (c *controller) DeleteVolume(tenant string, volume string) error {
 		return block.ErrVolumeNotAvailable
 	}
 
+	// the snapshots of the block device must be deleted first.
+	if len(c.ds.GetVolumeSnapshots(volume)) > 0 {
+		return block.ErrVolumeHasSnapshots
+	}
+
+	driver, err := c.volumeDriver(info.VolumeType)
+	if err != nil {
+		return err
+	}
+
 	// remove the block data from our datastore.
 	err = c.ds.DeleteBlockDevice(volume)
 	if err != nil {
@@ -132,7 +207,7 @@ func (c *controller) DeleteVolume(tenant string, volume string) error {
 	}
 
 	// tell the underlying storage media to remove.
-	err = c.DeleteBlockDevice(volume)
+	err = driver.DeleteBlockDevice(volume)
 	if err != nil {
 		return err
 	}
@@ -198,7 +273,7 @@ func (c *controller) AttachVolume(tenant string, volume string, instance string,
 	}
 
 	// send command to attach volume.
-	err = c.client.attachVolume(volume, instance, i.NodeID)
+	err = c.client.attachVolume(volume, instance, i.NodeID, info.VolumeType)
 	if err != nil {
 		info.State = types.Available
 		dsErr := c.ds.UpdateBlockDevice(info)
@@ -270,27 +345,151 @@ func (c *controller) DetachVolume(tenant string, volume string, attachment strin
 			continue
 		}
 
-		i.StateLock.RLock()
-		state := i.State
-		i.StateLock.RUnlock()
-
-		if state != payloads.Exited {
-			retval = errors.New("Can only detach from exited instances")
+		// instances that have not been scheduled yet have nothing
+		// to detach.
+		if i.NodeID == "" {
+			retval = block.ErrInstanceNotAvailable
 			continue
 		}
 
-		// update volume state to detaching
-		info.State = types.Available
+		// update volume state to detaching.  It becomes available
+		// once the launcher no longer reports it attached.
+		info.State = types.Detaching
 
 		err = c.ds.UpdateBlockDevice(info)
 		if err != nil {
 			return err
 		}
+
+		// send command to detach volume.
+		err = c.client.detachVolume(volume, i.ID, i.NodeID)
+		if err != nil {
+			info.State = types.InUse
+			dsErr := c.ds.UpdateBlockDevice(info)
+			if dsErr != nil {
+				glog.Error(dsErr)
+			}
+			retval = err
+		}
 	}
 
 	return retval
 }
 
+// extendBlockDevice resizes a block device being extended to size GiB and
+// stores its new size, returning it to the state it was in.
+func (c *controller) extendBlockDevice(info types.BlockData, size int) (int, error) {
+	driver, err := c.volumeDriver(info.VolumeType)
+	if err != nil {
+		return 0, err
+	}
+
+	// the nbd devices of the file driver do not grow with their file.
+	if _, ok := driver.(storage.FileDriver); ok && info.State == types.InUse {
+		return 0, block.ErrVolumeNotAvailable
+	}
+
+	if size <= info.Size {
+		return 0, block.ErrVolumeSize
+	}
+
+	// the volume size limit applies to the new size, the quota to the
+	// additional storage.
+	limit := findQuota(c.qs.DumpQuotas(info.TenantID), "tenant-volume-size-limit")
+	if limit != nil && limit.Value > -1 && size > limit.Value {
+		return 0, block.ErrQuota
+	}
+
+	res := <-c.qs.Consume(info.TenantID,
+		payloads.RequestedResource{Type: payloads.SharedDiskGiB, Value: size - info.Size})
+
+	if !res.Allowed() {
+		c.qs.Release(info.TenantID, res.Resources()...)
+		return 0, block.ErrQuota
+	}
+
+	newSize, err := driver.Resize(info.ID, size)
+	if err != nil {
+		c.qs.Release(info.TenantID, res.Resources()...)
+		return 0, err
+	}
+
+	info.Size = newSize
+	err = c.ds.UpdateBlockDevice(info)
+	if err != nil {
+		c.qs.Release(info.TenantID, res.Resources()...)
+		return 0, err
+	}
+
+	return newSize, nil
+}
+
+// ExtendVolume grows a block device to size GiB, charging the additional
+// storage to the tenant.  The instances a volume is attached to are told
+// that the volume has grown.
+func (c *controller) ExtendVolume(tenant string, volume string, size int) error {
+	err := c.confirmTenant(tenant)
+	if err != nil {
+		return err
+	}
+
+	// get the block device information
+	info, err := c.ds.GetBlockDevice(volume)
+	if err != nil {
+		return err
+	}
+
+	// check that the block device is owned by the tenant.
+	if info.TenantID != tenant {
+		return block.ErrVolumeOwner
+	}
+
+	// the volume is extending until it has been resized, so that it is
+	// neither attached, detached nor extended concurrently.
+	info, err = c.ds.UpdateBlockDeviceState(volume, types.Extending,
+		types.Available, types.InUse)
+	if err == types.ErrBlockDeviceState {
+		return block.ErrVolumeNotAvailable
+	} else if err != nil {
+		return err
+	}
+
+	newSize, err := c.extendBlockDevice(info, size)
+	if err != nil {
+		_, dsErr := c.ds.UpdateBlockDeviceState(volume, info.State, types.Extending)
+		if dsErr != nil {
+			glog.Error(dsErr)
+		}
+		return err
+	}
+
+	if info.State != types.InUse {
+		return nil
+	}
+
+	attachments, err := c.ds.GetVolumeAttachments(volume)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range attachments {
+		i, err := c.ds.GetTenantInstance(tenant, a.InstanceID)
+		if err != nil || i.NodeID == "" {
+			continue
+		}
+
+		// a failure to notify the instance is not fatal, it sees the
+		// new size of the volume when it is next started.
+		err = c.client.resizeVolume(volume, i.ID, i.NodeID, newSize)
+		if err != nil {
+			glog.Warningf("Unable to notify instance %s of resize of volume %s: %v",
+				i.ID, volume, err)
+		}
+	}
+
+	return nil
+}
+
 func (c *controller) ListVolumes(tenant string) ([]block.ListVolume, error) {
 	var vols []block.ListVolume
 
@@ -347,6 +546,7 @@ func (c *controller) ListVolumesDetail(tenant string) ([]block.VolumeDetail, err
 		vol.Bootable = strconv.FormatBool(data.Bootable)
 		vol.Name = data.Name
 		vol.Description = data.Description
+		vol.VolumeType = data.VolumeType
 
 		switch data.State {
 		case types.Attaching:
@@ -389,6 +589,7 @@ func (c *controller) ShowVolumeDetails(tenant string, volume string) (block.Volu
 	vol.Bootable = strconv.FormatBool(data.Bootable)
 	vol.Name = data.Name
 	vol.Description = data.Description
+	vol.VolumeType = data.VolumeType
 
 	switch data.State {
 	case types.Attaching:
@@ -404,6 +605,176 @@ func (c *controller) ShowVolumeDetails(tenant string, volume string) (block.Volu
 	return vol, nil
 }
 
+// getTenantSnapshot returns a snapshot owned by the tenant.
+func (c *controller) getTenantSnapshot(tenant string, snapshot string) (types.SnapshotData, error) {
+	snap, err := c.ds.GetSnapshot(snapshot)
+	if err == types.ErrSnapshotNotFound {
+		return snap, block.ErrSnapshotNotFound
+	} else if err != nil {
+		return snap, err
+	}
+
+	if snap.TenantID != tenant {
+		return snap, block.ErrSnapshotOwner
+	}
+
+	return snap, nil
+}
+
+// convert our snapshot info into the openstack desired format.
+func snapshotToBlock(snap *types.SnapshotData) block.Snapshot {
+	return block.Snapshot{
+		Status:         block.VolumeStatus(snap.State),
+		Description:    snap.Description,
+		CreatedAt:      &snap.CreateTime,
+		Name:           snap.Name,
+		VolumeID:       snap.VolumeID,
+		Size:           snap.Size,
+		ID:             snap.ID,
+		MetaData:       map[string]string{},
+		OSSnapTenantID: snap.TenantID,
+		OSSnapProgress: "100%",
+	}
+}
+
+// CreateSnapshot will snapshot a block device and store the snapshot in the
+// datastore.  Volumes in use can only be snapshotted if forced, as their
+// snapshot is only crash consistent.
+func (c *controller) CreateSnapshot(tenant string, req block.RequestedSnapshot) (block.Snapshot, error) {
+	err := c.confirmTenant(tenant)
+	if err != nil {
+		return block.Snapshot{}, err
+	}
+
+	// get the block device information
+	info, err := c.ds.GetBlockDevice(req.VolumeID)
+	if err != nil {
+		return block.Snapshot{}, err
+	}
+
+	// check that the block device is owned by the tenant.
+	if info.TenantID != tenant || info.Internal {
+		return block.Snapshot{}, block.ErrVolumeOwner
+	}
+
+	if info.State != types.Available && !(req.Force && info.State == types.InUse) {
+		return block.Snapshot{}, block.ErrVolumeNotAvailable
+	}
+
+	driver, err := c.volumeDriver(info.VolumeType)
+	if err != nil {
+		return block.Snapshot{}, err
+	}
+
+	res := <-c.qs.Consume(tenant,
+		payloads.RequestedResource{Type: payloads.Snapshot, Value: 1},
+		payloads.RequestedResource{Type: payloads.SnapshotGiB, Value: info.Size})
+
+	if !res.Allowed() {
+		c.qs.Release(tenant, res.Resources()...)
+		return block.Snapshot{}, block.ErrQuota
+	}
+
+	snap := types.SnapshotData{
+		ID:          uuid.Generate().String(),
+		VolumeID:    info.ID,
+		TenantID:    tenant,
+		State:       types.Available,
+		Size:        info.Size,
+		CreateTime:  time.Now(),
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	err = driver.CreateBlockDeviceSnapshot(snap.VolumeID, snap.ID)
+	if err != nil {
+		c.qs.Release(tenant, res.Resources()...)
+		return block.Snapshot{}, err
+	}
+
+	err = c.ds.AddSnapshot(snap)
+	if err != nil {
+		_ = driver.DeleteBlockDeviceSnapshot(snap.VolumeID, snap.ID)
+		c.qs.Release(tenant, res.Resources()...)
+		return block.Snapshot{}, err
+	}
+
+	return snapshotToBlock(&snap), nil
+}
+
+// DeleteSnapshot will delete a snapshot, unless volumes were restored from
+// it and the block driver cannot delete snapshots with clones.
+func (c *controller) DeleteSnapshot(tenant string, snapshot string) error {
+	err := c.confirmTenant(tenant)
+	if err != nil {
+		return err
+	}
+
+	snap, err := c.getTenantSnapshot(tenant, snapshot)
+	if err != nil {
+		return err
+	}
+
+	// the snapshot is stored with its volume.
+	info, err := c.ds.GetBlockDevice(snap.VolumeID)
+	if err != nil {
+		return err
+	}
+
+	driver, err := c.volumeDriver(info.VolumeType)
+	if err != nil {
+		return err
+	}
+
+	// tell the underlying storage media to remove.
+	err = driver.DeleteBlockDeviceSnapshot(snap.VolumeID, snap.ID)
+	if err != nil {
+		return err
+	}
+
+	err = c.ds.DeleteSnapshot(snap.ID)
+	if err != nil {
+		return err
+	}
+
+	// release quota associated with this snapshot
+	c.qs.Release(snap.TenantID,
+		payloads.RequestedResource{Type: payloads.Snapshot, Value: 1},
+		payloads.RequestedResource{Type: payloads.SnapshotGiB, Value: snap.Size})
+
+	return nil
+}
+
+func (c *controller) ListSnapshots(tenant string) ([]block.Snapshot, error) {
+	snaps := []block.Snapshot{}
+
+	err := c.confirmTenant(tenant)
+	if err != nil {
+		return snaps, err
+	}
+
+	data := c.ds.GetSnapshots(tenant)
+	for i := range data {
+		snaps = append(snaps, snapshotToBlock(&data[i]))
+	}
+
+	return snaps, nil
+}
+
+func (c *controller) ShowSnapshot(tenant string, snapshot string) (block.Snapshot, error) {
+	err := c.confirmTenant(tenant)
+	if err != nil {
+		return block.Snapshot{}, err
+	}
+
+	snap, err := c.getTenantSnapshot(tenant, snapshot)
+	if err != nil {
+		return block.Snapshot{}, err
+	}
+
+	return snapshotToBlock(&snap), nil
+}
+
 func (c *controller) createVolumeRoutes(r *mux.Router) error {
 	config := block.APIConfig{VolService: c}
 
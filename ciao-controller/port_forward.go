@@ -0,0 +1,233 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp/uuid"
+	"github.com/golang/glog"
+)
+
+func (c *controller) makePortForwardLinks(pf *types.PortForward) {
+	ref := fmt.Sprintf("%s/%s/external-ips/port-forwards/%s", c.apiURL, pf.TenantID, pf.ID)
+
+	link := types.Link{
+		Rel:  "self",
+		Href: ref,
+	}
+
+	pf.Links = []types.Link{link}
+}
+
+func validPortForwardRequest(req types.PortForwardRequest) bool {
+	if req.Protocol != "tcp" && req.Protocol != "udp" {
+		return false
+	}
+
+	return req.ExternalPort > 0 && req.ExternalPort <= 65535 &&
+		req.InternalPort >= 0 && req.InternalPort <= 65535
+}
+
+// checkPortForwardInstance checks that the ports of the tenant may be
+// forwarded to an instance.  The datastore checks that all the ports of an
+// external IP are forwarded to the same subnet when it stores the port
+// forward.
+func (c *controller) checkPortForwardInstance(tenantID string, instanceID string) error {
+	i, err := c.ds.GetTenantInstance(tenantID, instanceID)
+	if err != nil {
+		return err
+	}
+
+	if i.CNCI {
+		return types.ErrInstanceNotFound
+	}
+
+	return nil
+}
+
+func (c *controller) CreatePortForward(tenantID string, req types.PortForwardRequest) (pf types.PortForward, err error) {
+	if req.Protocol == "" {
+		req.Protocol = "tcp"
+	}
+
+	if !validPortForwardRequest(req) {
+		glog.V(2).Infof("Invalid port forward request %+v", req)
+		return types.PortForward{}, types.ErrBadRequest
+	}
+
+	err = c.confirmTenant(tenantID)
+	if err != nil {
+		return types.PortForward{}, err
+	}
+
+	err = c.checkPortForwardInstance(tenantID, req.InstanceID)
+	if err != nil {
+		return types.PortForward{}, err
+	}
+
+	pf = types.PortForward{
+		ID:           uuid.Generate().String(),
+		TenantID:     tenantID,
+		ExternalIP:   req.ExternalIP,
+		Protocol:     req.Protocol,
+		ExternalPort: req.ExternalPort,
+		InstanceID:   req.InstanceID,
+		InternalPort: req.InternalPort,
+	}
+
+	if pf.InternalPort == 0 {
+		pf.InternalPort = pf.ExternalPort
+	}
+
+	if pf.ExternalIP != "" {
+		pf, err = c.ds.AddPortForward("", pf)
+		if err != nil {
+			return types.PortForward{}, err
+		}
+	} else {
+		pf, err = c.addPortForwardIP(req.PoolName, pf)
+		if err != nil {
+			return types.PortForward{}, err
+		}
+	}
+
+	c.updatePortForwards(tenantID)
+
+	c.makePortForwardLinks(&pf)
+
+	return pf, nil
+}
+
+// addPortForwardIP forwards a port of a new external IP, taken from the
+// pool named poolName or from any pool with free addresses.
+func (c *controller) addPortForwardIP(poolName *string, pf types.PortForward) (_ types.PortForward, err error) {
+	// the external IP counts against the same quota as the ones mapped
+	// to instances.  It is released in DeletePortForward, once none of
+	// its ports is forwarded.
+	res := <-c.qs.Consume(pf.TenantID, payloads.RequestedResource{Type: payloads.ExternalIP, Value: 1})
+	defer func() {
+		if err != nil {
+			c.qs.Release(pf.TenantID, payloads.RequestedResource{Type: payloads.ExternalIP, Value: 1})
+		}
+	}()
+
+	if !res.Allowed() {
+		return types.PortForward{}, types.ErrQuota
+	}
+
+	pools, err := c.ds.GetPools()
+	if err != nil {
+		return types.PortForward{}, err
+	}
+
+	err = types.ErrPoolEmpty
+
+	for _, pool := range pools {
+		if poolName != nil {
+			if pool.Name == *poolName {
+				pf, err = c.ds.AddPortForward(pool.ID, pf)
+				break
+			}
+		} else if pool.Free > 0 {
+			pf, err = c.ds.AddPortForward(pool.ID, pf)
+			break
+		}
+	}
+
+	return pf, err
+}
+
+func (c *controller) ListPortForwards(tenantID string) ([]types.PortForward, error) {
+	pfs := c.ds.GetPortForwards(tenantID)
+
+	for i := range pfs {
+		c.makePortForwardLinks(&pfs[i])
+	}
+
+	return pfs, nil
+}
+
+func (c *controller) ShowPortForward(tenantID string, forwardID string) (types.PortForward, error) {
+	pf, err := c.ds.GetPortForward(tenantID, forwardID)
+	if err != nil {
+		return pf, err
+	}
+
+	c.makePortForwardLinks(&pf)
+
+	return pf, nil
+}
+
+func (c *controller) DeletePortForward(tenantID string, forwardID string) error {
+	released, err := c.ds.DeletePortForward(tenantID, forwardID)
+	if released {
+		c.qs.Release(tenantID, payloads.RequestedResource{Type: payloads.ExternalIP, Value: 1})
+	}
+	if err != nil {
+		return err
+	}
+
+	c.updatePortForwards(tenantID)
+
+	return nil
+}
+
+// updatePortForwards sends the port forwards of a tenant to the CNCIs of
+// the subnets of the instances the ports are forwarded to.  Each CNCI
+// replaces the port forwards it renders with the ones it is sent, so CNCIs
+// which no longer render any port forward are sent an empty list.
+func (c *controller) updatePortForwards(tenantID string) {
+	c.cnciUpdateLock.Lock()
+	defer c.cnciUpdateLock.Unlock()
+
+	cncis, err := c.ds.GetTenantCNCIs(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update port forwards of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	subnets := make(map[string][]payloads.PortForward)
+	for _, pf := range c.ds.GetPortForwards(tenantID) {
+		i, err := c.ds.GetInstance(pf.InstanceID)
+		if err != nil {
+			continue
+		}
+
+		subnets[i.Subnet] = append(subnets[i.Subnet], payloads.PortForward{
+			PortForwardUUID: pf.ID,
+			PublicIP:        pf.ExternalIP,
+			Protocol:        pf.Protocol,
+			PublicPort:      pf.ExternalPort,
+			PrivateIP:       pf.InternalIP,
+			PrivatePort:     pf.InternalPort,
+		})
+	}
+
+	for _, cnci := range cncis {
+		cmd := payloads.PortForwardsCmd{
+			ConcentratorUUID: cnci.ID,
+			TenantUUID:       tenantID,
+			PortForwards:     subnets[cnci.Subnet],
+		}
+
+		err = c.client.setPortForwards(cmd)
+		if err != nil {
+			glog.Warningf("Unable to send port forwards to CNCI %s: %v", cnci.ID, err)
+		}
+	}
+}
@@ -69,6 +69,16 @@ func populateQuotasFromDatastore(qs *quotas.Quotas, ds *datastore.Datastore) err
 			payloads.RequestedResource{Type: payloads.Volume, Value: count},
 			payloads.RequestedResource{Type: payloads.SharedDiskGiB, Value: size})
 
+		// Populate snapshot usage
+		size, count = 0, 0
+		for _, snap := range ds.GetSnapshots(t.ID) {
+			size += snap.Size
+			count++
+		}
+		<-qs.Consume(t.ID,
+			payloads.RequestedResource{Type: payloads.Snapshot, Value: count},
+			payloads.RequestedResource{Type: payloads.SnapshotGiB, Value: size})
+
 		instances, err := ds.GetAllInstancesFromTenant(t.ID)
 		if err != nil {
 			return errors.Wrapf(err, "error getting tenant instances")
@@ -80,7 +90,7 @@ func populateQuotasFromDatastore(qs *quotas.Quotas, ds *datastore.Datastore) err
 				return errors.Wrapf(err, "error getting workload")
 			}
 			resources := []payloads.RequestedResource{{Type: payloads.Instance, Value: 1}}
-			resources = append(resources, wl.Defaults...)
+			resources = append(resources, instanceResources(instance, &wl)...)
 			<-qs.Consume(t.ID, resources...)
 		}
 	}
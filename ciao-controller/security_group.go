@@ -0,0 +1,442 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp"
+	"github.com/ciao-project/ciao/ssntp/uuid"
+	"github.com/golang/glog"
+)
+
+func (c *controller) makeSecurityGroupLinks(group *types.SecurityGroup) {
+	ref := fmt.Sprintf("%s/%s/security-groups/%s", c.apiURL, group.TenantID, group.ID)
+
+	link := types.Link{
+		Rel:  "self",
+		Href: ref,
+	}
+
+	group.Links = []types.Link{link}
+
+	// we want empty lists rather than null in the API
+	if group.Rules == nil {
+		group.Rules = []types.SecurityGroupRule{}
+	}
+
+	if group.Members == nil {
+		group.Members = []string{}
+	}
+}
+
+func (c *controller) CreateSecurityGroup(tenantID string, req types.SecurityGroupRequest) (types.SecurityGroup, error) {
+	if req.Name == "" {
+		glog.V(2).Info("Invalid security group request: missing name")
+		return types.SecurityGroup{}, types.ErrBadRequest
+	}
+
+	err := c.confirmTenant(tenantID)
+	if err != nil {
+		return types.SecurityGroup{}, err
+	}
+
+	group := types.SecurityGroup{
+		ID:          uuid.Generate().String(),
+		TenantID:    tenantID,
+		Name:        req.Name,
+		Description: req.Description,
+	}
+
+	// like in the default group, members may reach anything by default
+	group.Rules = []types.SecurityGroupRule{
+		{
+			ID:        uuid.Generate().String(),
+			Direction: payloads.Egress,
+		},
+	}
+
+	err = c.ds.AddSecurityGroup(group)
+	if err != nil {
+		return types.SecurityGroup{}, err
+	}
+
+	c.makeSecurityGroupLinks(&group)
+
+	return group, nil
+}
+
+func (c *controller) ListSecurityGroups(tenantID string) ([]types.SecurityGroup, error) {
+	groups := c.ds.GetSecurityGroups(tenantID)
+
+	for i := range groups {
+		c.makeSecurityGroupLinks(&groups[i])
+	}
+
+	return groups, nil
+}
+
+func (c *controller) ShowSecurityGroup(tenantID string, groupID string) (types.SecurityGroup, error) {
+	group, err := c.ds.GetSecurityGroup(tenantID, groupID)
+	if err != nil {
+		return group, err
+	}
+
+	c.makeSecurityGroupLinks(&group)
+
+	return group, nil
+}
+
+func (c *controller) DeleteSecurityGroup(tenantID string, groupID string) error {
+	return c.ds.DeleteSecurityGroup(tenantID, groupID)
+}
+
+func validSecurityGroupRule(rule types.SecurityGroupRule) bool {
+	if rule.Direction != payloads.Ingress && rule.Direction != payloads.Egress {
+		return false
+	}
+
+	switch rule.Protocol {
+	case "", "icmp":
+		if rule.PortRangeMin != 0 || rule.PortRangeMax != 0 {
+			return false
+		}
+	case "tcp", "udp":
+		if rule.PortRangeMin < 0 || rule.PortRangeMax > 65535 ||
+			(rule.PortRangeMax != 0 && rule.PortRangeMax < rule.PortRangeMin) {
+			return false
+		}
+	default:
+		return false
+	}
+
+	if rule.RemoteIPPrefix != "" {
+		if rule.RemoteGroupID != "" {
+			return false
+		}
+
+		_, _, err := net.ParseCIDR(rule.RemoteIPPrefix)
+		if err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (c *controller) CreateSecurityGroupRule(tenantID string, groupID string, rule types.SecurityGroupRule) (types.SecurityGroupRule, error) {
+	if !validSecurityGroupRule(rule) {
+		glog.V(2).Infof("Invalid security group rule %+v", rule)
+		return types.SecurityGroupRule{}, types.ErrBadRequest
+	}
+
+	_, err := c.ds.GetSecurityGroup(tenantID, groupID)
+	if err != nil {
+		return types.SecurityGroupRule{}, err
+	}
+
+	if rule.RemoteGroupID != "" {
+		_, err = c.ds.GetSecurityGroup(tenantID, rule.RemoteGroupID)
+		if err != nil {
+			return types.SecurityGroupRule{}, err
+		}
+	}
+
+	rule.ID = uuid.Generate().String()
+	if rule.PortRangeMax == 0 {
+		rule.PortRangeMax = rule.PortRangeMin
+	}
+
+	err = c.ds.AddSecurityGroupRule(groupID, rule)
+	if err != nil {
+		return types.SecurityGroupRule{}, err
+	}
+
+	c.updateSecurityGroups(tenantID)
+
+	return rule, nil
+}
+
+func (c *controller) DeleteSecurityGroupRule(tenantID string, groupID string, ruleID string) error {
+	err := c.ds.DeleteSecurityGroupRule(tenantID, groupID, ruleID)
+	if err != nil {
+		return err
+	}
+
+	c.updateSecurityGroups(tenantID)
+
+	return nil
+}
+
+// defaultSecurityGroup returns the default security group of a tenant,
+// creating it if needed.  Its members may reach each other and anything
+// outside of the tenant, and may be reached through SSH from anywhere,
+// over IPv4 and IPv6.
+func (c *controller) defaultSecurityGroup(tenantID string) (types.SecurityGroup, error) {
+	for _, group := range c.ds.GetSecurityGroups(tenantID) {
+		if group.Name == types.DefaultSecurityGroup {
+			return group, nil
+		}
+	}
+
+	group := types.SecurityGroup{
+		ID:          uuid.Generate().String(),
+		TenantID:    tenantID,
+		Name:        types.DefaultSecurityGroup,
+		Description: "Default security group",
+	}
+
+	group.Rules = []types.SecurityGroupRule{
+		{
+			ID:            uuid.Generate().String(),
+			Direction:     payloads.Ingress,
+			RemoteGroupID: group.ID,
+		},
+		{
+			ID:             uuid.Generate().String(),
+			Direction:      payloads.Ingress,
+			Protocol:       "tcp",
+			PortRangeMin:   22,
+			PortRangeMax:   22,
+			RemoteIPPrefix: "0.0.0.0/0",
+		},
+		{
+			ID:             uuid.Generate().String(),
+			Direction:      payloads.Ingress,
+			Protocol:       "tcp",
+			PortRangeMin:   22,
+			PortRangeMax:   22,
+			RemoteIPPrefix: "::/0",
+		},
+		{
+			ID:        uuid.Generate().String(),
+			Direction: payloads.Egress,
+		},
+	}
+
+	err := c.ds.AddSecurityGroup(group)
+	if err == types.ErrDuplicateSecurityGroupName {
+		// created concurrently by another request
+		return c.defaultSecurityGroup(tenantID)
+	}
+
+	return group, err
+}
+
+// resolveSecurityGroups returns the IDs of the security groups whose IDs or
+// names are given, or of the default security group if none are.
+func (c *controller) resolveSecurityGroups(tenantID string, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		group, err := c.defaultSecurityGroup(tenantID)
+		if err != nil {
+			return nil, err
+		}
+
+		return []string{group.ID}, nil
+	}
+
+	groups := c.ds.GetSecurityGroups(tenantID)
+
+	var IDs []string
+	for _, r := range requested {
+		found := false
+		for _, group := range groups {
+			if group.ID == r || group.Name == r {
+				IDs = append(IDs, group.ID)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			if r != types.DefaultSecurityGroup {
+				return nil, types.ErrSecurityGroupNotFound
+			}
+
+			group, err := c.defaultSecurityGroup(tenantID)
+			if err != nil {
+				return nil, err
+			}
+			IDs = append(IDs, group.ID)
+		}
+	}
+
+	return IDs, nil
+}
+
+// hostCIDR returns the CIDR notation of a single IPv4 or IPv6 address.
+func hostCIDR(ip string) string {
+	if net.ParseIP(ip).To4() != nil {
+		return ip + "/32"
+	}
+
+	return ip + "/128"
+}
+
+// instanceSecurityRules expands the rules of the security groups of an
+// instance, replacing remote groups with the IPv4 and IPv6 addresses of
+// their members.
+func instanceSecurityRules(groups []types.SecurityGroup, memberIPs map[string][]string) []payloads.SecurityGroupRule {
+	var rules []payloads.SecurityGroupRule
+
+	for _, group := range groups {
+		for _, r := range group.Rules {
+			rule := payloads.SecurityGroupRule{
+				Direction: r.Direction,
+				Protocol:  r.Protocol,
+				PortMin:   r.PortRangeMin,
+				PortMax:   r.PortRangeMax,
+			}
+
+			if r.RemoteGroupID == "" {
+				rule.RemoteCIDR = r.RemoteIPPrefix
+				rules = append(rules, rule)
+				continue
+			}
+
+			for _, ip := range memberIPs[r.RemoteGroupID] {
+				rule.RemoteCIDR = hostCIDR(ip)
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	return rules
+}
+
+// instanceVnics returns the primary VNIC of an instance followed by the
+// VNICs it has on other tenant networks.  Only their subnets and addresses
+// are set.
+func instanceVnics(i *types.Instance) []types.InstanceVnic {
+	vnics := []types.InstanceVnic{{Subnet: i.Subnet, IPAddress: i.IPAddress}}
+	for _, vnic := range i.Vnics {
+		if vnic.IPAddress != "" {
+			vnics = append(vnics, vnic)
+		}
+	}
+
+	return vnics
+}
+
+// tenantSecurityRules returns the rules of the instances of a tenant that
+// are members of security groups, indexed by subnet, and all these rules.
+// The rules of an instance are given for each of its VNICs, and the members
+// of a group are all the addresses of its instances, so that the traffic
+// an instance exchanges on any of its networks is filtered.
+func tenantSecurityRules(instances []*types.Instance, instanceGroups map[string][]types.SecurityGroup) (map[string][]payloads.InstanceSecurityRules, []payloads.InstanceSecurityRules) {
+	memberIPs := make(map[string][]string)
+	for _, i := range instances {
+		for _, group := range instanceGroups[i.ID] {
+			for _, vnic := range instanceVnics(i) {
+				memberIPs[group.ID] = append(memberIPs[group.ID], vnic.IPAddress)
+				if ipv6 := tenantIPv6Addr(vnic.IPAddress, vnic.Subnet); ipv6 != "" {
+					memberIPs[group.ID] = append(memberIPs[group.ID], ipv6)
+				}
+			}
+		}
+	}
+
+	subnets := make(map[string][]payloads.InstanceSecurityRules)
+	var all []payloads.InstanceSecurityRules
+	for _, i := range instances {
+		groups := instanceGroups[i.ID]
+		if len(groups) == 0 {
+			continue
+		}
+
+		for _, vnic := range instanceVnics(i) {
+			rules := payloads.InstanceSecurityRules{
+				InstanceUUID: i.ID,
+				PrivateIP:    vnic.IPAddress,
+				PrivateIPv6:  tenantIPv6Addr(vnic.IPAddress, vnic.Subnet),
+				Rules:        instanceSecurityRules(groups, memberIPs),
+			}
+			subnets[vnic.Subnet] = append(subnets[vnic.Subnet], rules)
+			all = append(all, rules)
+		}
+	}
+
+	return subnets, all
+}
+
+// updateSecurityGroups sends the security group rules of all the instances
+// of a tenant to the CNCIs of their subnets.  Each CNCI replaces the rules
+// it enforces with the ones it is sent.  Instances that are not members of
+// any security group are not filtered.  Every CNCI of the tenant is sent
+// the rules of its subnet, even when there are none left, so that it drops
+// the rules of the instances that left their groups.
+//
+// The CNCIs only filter the traffic they route, so all the rules of the
+// tenant are also sent to every compute node, which enforces the rules of
+// the instances it runs on their bridge ports.  They are sent before the
+// instances are known to run on a node, so that the nodes enforce them as
+// soon as the instances are started.
+func (c *controller) updateSecurityGroups(tenantID string) {
+	c.cnciUpdateLock.Lock()
+	defer c.cnciUpdateLock.Unlock()
+
+	cncis, err := c.ds.GetTenantCNCIs(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update security groups of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	instances, err := c.ds.GetAllInstancesFromTenant(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update security groups of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	instanceGroups := make(map[string][]types.SecurityGroup)
+	for _, i := range instances {
+		if i.CNCI || i.IPAddress == "" {
+			continue
+		}
+
+		instanceGroups[i.ID] = c.ds.GetInstanceSecurityGroups(i.ID)
+	}
+
+	subnets, all := tenantSecurityRules(instances, instanceGroups)
+
+	for _, cnci := range cncis {
+		cmd := payloads.SecurityGroupsCmd{
+			ConcentratorUUID: cnci.ID,
+			TenantUUID:       tenantID,
+			Instances:        subnets[cnci.Subnet],
+		}
+
+		err = c.client.setSecurityGroups(cmd)
+		if err != nil {
+			glog.Warningf("Unable to send security groups to CNCI %s: %v", cnci.ID, err)
+		}
+	}
+
+	nodes, _ := trimComputeNodes(c, c.ds.GetNodeLastStats(), ssntp.AGENT)
+	for _, node := range nodes.Nodes {
+		cmd := payloads.SecurityGroupsCmd{
+			NodeUUID:   node.ID,
+			TenantUUID: tenantID,
+			Instances:  all,
+		}
+
+		err = c.client.setSecurityGroups(cmd)
+		if err != nil {
+			glog.Warningf("Unable to send security groups to node %s: %v", node.ID, err)
+		}
+	}
+}
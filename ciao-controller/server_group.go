@@ -0,0 +1,123 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp/uuid"
+	"github.com/golang/glog"
+)
+
+func (c *controller) makeServerGroupLinks(group *types.ServerGroup) {
+	ref := fmt.Sprintf("%s/%s/server-groups/%s", c.apiURL, group.TenantID, group.ID)
+
+	link := types.Link{
+		Rel:  "self",
+		Href: ref,
+	}
+
+	group.Links = []types.Link{link}
+
+	// we want an empty list rather than null in the API
+	if group.Members == nil {
+		group.Members = []string{}
+	}
+}
+
+func (c *controller) CreateServerGroup(tenantID string, req types.ServerGroupRequest) (types.ServerGroup, error) {
+	if req.Policy != payloads.Affinity && req.Policy != payloads.AntiAffinity {
+		glog.V(2).Infof("Invalid server group request: unknown policy %s", req.Policy)
+		return types.ServerGroup{}, types.ErrBadRequest
+	}
+
+	err := c.confirmTenant(tenantID)
+	if err != nil {
+		return types.ServerGroup{}, err
+	}
+
+	group := types.ServerGroup{
+		ID:       uuid.Generate().String(),
+		TenantID: tenantID,
+		Name:     req.Name,
+		Policy:   req.Policy,
+	}
+
+	err = c.ds.AddServerGroup(group)
+	if err != nil {
+		return types.ServerGroup{}, err
+	}
+
+	c.makeServerGroupLinks(&group)
+
+	return group, nil
+}
+
+func (c *controller) ListServerGroups(tenantID string) ([]types.ServerGroup, error) {
+	groups := c.ds.GetServerGroups(tenantID)
+
+	for i := range groups {
+		c.makeServerGroupLinks(&groups[i])
+	}
+
+	return groups, nil
+}
+
+func (c *controller) ShowServerGroup(tenantID string, groupID string) (types.ServerGroup, error) {
+	group, err := c.ds.GetServerGroup(tenantID, groupID)
+	if err != nil {
+		return group, err
+	}
+
+	c.makeServerGroupLinks(&group)
+
+	return group, nil
+}
+
+func (c *controller) DeleteServerGroup(tenantID string, groupID string) error {
+	return c.ds.DeleteServerGroup(tenantID, groupID)
+}
+
+// serverGroupHint returns the scheduler hint for an instance of the given
+// server group, listing the nodes on which the other members of the group
+// are known to be.  The scheduler keeps track of members it has recently
+// dispatched itself.
+func (c *controller) serverGroupHint(group types.ServerGroup, instanceID string) *payloads.ServerGroupHint {
+	hint := &payloads.ServerGroupHint{
+		GroupUUID: group.ID,
+		Policy:    group.Policy,
+	}
+
+	nodes := make(map[string]bool)
+	for _, member := range group.Members {
+		if member == instanceID {
+			continue
+		}
+
+		i, err := c.ds.GetInstance(member)
+		if err != nil || i.NodeID == "" || i.State == payloads.Pending {
+			continue
+		}
+
+		if !nodes[i.NodeID] {
+			nodes[i.NodeID] = true
+			hint.MemberNodes = append(hint.MemberNodes, i.NodeID)
+		}
+	}
+
+	return hint
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"regexp"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/golang/glog"
+)
+
+// instanceNameRegexp matches the RFC 1123 host name labels, lower case only.
+var instanceNameRegexp = regexp.MustCompile("^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$")
+
+// validInstanceName reports whether an instance name can be served as a
+// host name in the DNS zone of its tenant.
+func validInstanceName(name string) bool {
+	return instanceNameRegexp.MatchString(name)
+}
+
+// tenantDNSZone returns the DNS zone in which the instances of a tenant are
+// named, such as <name>.<tenant>.ciao.
+func tenantDNSZone(tenantID string) string {
+	return tenantID + ".ciao"
+}
+
+// instanceDNSRecord returns the record of an instance as served on a
+// subnet.  Instances attached to several tenant networks are given the
+// address they have on the subnet, if any, so that their traffic does not
+// need to be routed by the CNCI.
+func instanceDNSRecord(i *types.Instance, subnet string) payloads.DNSRecord {
+	ip := i.IPAddress
+	ipSubnet := i.Subnet
+
+	for _, vnic := range i.Vnics {
+		if vnic.Subnet == subnet && vnic.IPAddress != "" {
+			ip = vnic.IPAddress
+			ipSubnet = vnic.Subnet
+			break
+		}
+	}
+
+	return payloads.DNSRecord{
+		Name: i.Name,
+		IP:   ip,
+		IPv6: tenantIPv6Addr(ip, ipSubnet),
+	}
+}
+
+// updateTenantDNS sends the names of the instances of a tenant to all its
+// CNCIs, which serve them in the DNS zone of the tenant.  Each CNCI replaces
+// the records it serves with the ones it is sent.  Instances without a name,
+// or whose name is not a valid DNS label, are not served.
+func (c *controller) updateTenantDNS(tenantID string) {
+	c.cnciUpdateLock.Lock()
+	defer c.cnciUpdateLock.Unlock()
+
+	cncis, err := c.ds.GetTenantCNCIs(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update DNS of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	if len(cncis) == 0 {
+		return
+	}
+
+	instances, err := c.ds.GetAllInstancesFromTenant(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update DNS of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	for _, cnci := range cncis {
+		records := []payloads.DNSRecord{}
+		for _, i := range instances {
+			if i.CNCI || !validInstanceName(i.Name) || i.IPAddress == "" {
+				continue
+			}
+
+			records = append(records, instanceDNSRecord(i, cnci.Subnet))
+		}
+
+		cmd := payloads.TenantDNSCmd{
+			ConcentratorUUID: cnci.ID,
+			TenantUUID:       tenantID,
+			Zone:             tenantDNSZone(tenantID),
+			Records:          records,
+		}
+
+		err = c.client.setTenantDNS(cmd)
+		if err != nil {
+			glog.Warningf("Unable to send DNS records to CNCI %s: %v", cnci.ID, err)
+		}
+	}
+}
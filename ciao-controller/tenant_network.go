@@ -0,0 +1,173 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/ciao-controller/utils"
+	"github.com/ciao-project/ciao/ssntp/uuid"
+	"github.com/golang/glog"
+)
+
+// tenantSubnets is the address range from which the subnets of tenants,
+// whether allocated automatically or chosen for a tenant network, are taken.
+var tenantSubnets = net.IPNet{
+	IP:   net.IPv4(172, 16, 0, 0).To4(),
+	Mask: net.CIDRMask(12, 32),
+}
+
+// tenantNetworkCIDR checks that the CIDR of a new tenant network is a /24
+// subnet of the tenant address range, like the subnets allocated
+// automatically, and returns it in canonical form.
+func tenantNetworkCIDR(cidr string) (string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", types.ErrBadRequest
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if ones != 24 || bits != 32 || !tenantSubnets.Contains(ipNet.IP) {
+		return "", types.ErrBadRequest
+	}
+
+	return ipNet.String(), nil
+}
+
+// tenantIPv6Addr returns the IPv6 address of an instance on a tenant
+// subnet, or an empty string if it has none.
+func tenantIPv6Addr(ip string, subnet string) string {
+	_, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return ""
+	}
+
+	ipv6 := utils.TenantIPv6Addr(net.ParseIP(ip), ipNet)
+	if ipv6 == nil {
+		return ""
+	}
+
+	return ipv6.String()
+}
+
+func (c *controller) makeTenantNetworkLinks(network *types.TenantNetwork) {
+	ref := fmt.Sprintf("%s/%s/networks/%s", c.apiURL, network.TenantID, network.ID)
+
+	link := types.Link{
+		Rel:  "self",
+		Href: ref,
+	}
+
+	network.Links = []types.Link{link}
+}
+
+func (c *controller) CreateTenantNetwork(tenantID string, req types.TenantNetworkRequest) (types.TenantNetwork, error) {
+	if req.Name == "" {
+		glog.V(2).Info("Invalid network request: missing name")
+		return types.TenantNetwork{}, types.ErrBadRequest
+	}
+
+	cidr, err := tenantNetworkCIDR(req.CIDR)
+	if err != nil {
+		glog.V(2).Infof("Invalid network request: bad CIDR %s", req.CIDR)
+		return types.TenantNetwork{}, err
+	}
+
+	err = c.confirmTenant(tenantID)
+	if err != nil {
+		return types.TenantNetwork{}, err
+	}
+
+	network := types.TenantNetwork{
+		ID:       uuid.Generate().String(),
+		TenantID: tenantID,
+		Name:     req.Name,
+		CIDR:     cidr,
+	}
+
+	err = c.ds.AddTenantNetwork(network)
+	if err != nil {
+		return types.TenantNetwork{}, err
+	}
+
+	c.makeTenantNetworkLinks(&network)
+
+	return network, nil
+}
+
+func (c *controller) ListTenantNetworks(tenantID string) ([]types.TenantNetwork, error) {
+	networks := c.ds.GetTenantNetworks(tenantID)
+
+	for i := range networks {
+		c.makeTenantNetworkLinks(&networks[i])
+	}
+
+	return networks, nil
+}
+
+func (c *controller) ShowTenantNetwork(tenantID string, networkID string) (types.TenantNetwork, error) {
+	network, err := c.ds.GetTenantNetwork(tenantID, networkID)
+	if err != nil {
+		return network, err
+	}
+
+	c.makeTenantNetworkLinks(&network)
+
+	return network, nil
+}
+
+func (c *controller) DeleteTenantNetwork(tenantID string, networkID string) error {
+	return c.ds.DeleteTenantNetwork(tenantID, networkID)
+}
+
+// resolveTenantNetworks returns the tenant networks whose IDs or names are
+// given, in the same order.  An instance cannot be attached twice to the
+// same network.
+func (c *controller) resolveTenantNetworks(tenantID string, requested []string) ([]types.TenantNetwork, error) {
+	var networks []types.TenantNetwork
+
+	if len(requested) == 0 {
+		return nil, nil
+	}
+
+	all := c.ds.GetTenantNetworks(tenantID)
+
+	for _, r := range requested {
+		found := false
+		for _, network := range all {
+			if network.ID != r && network.Name != r {
+				continue
+			}
+
+			for _, n := range networks {
+				if n.ID == network.ID {
+					return nil, fmt.Errorf("Network %s requested more than once", r)
+				}
+			}
+
+			networks = append(networks, network)
+			found = true
+			break
+		}
+
+		if !found {
+			return nil, types.ErrTenantNetworkNotFound
+		}
+	}
+
+	return networks, nil
+}
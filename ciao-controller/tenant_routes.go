@@ -0,0 +1,116 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sort"
+
+	"github.com/ciao-project/ciao/ciao-controller/types"
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/golang/glog"
+)
+
+// tenantRouterSubnets returns the subnets the distributed routers of a
+// tenant connect on the compute nodes, or nil if distributed routing is
+// disabled or the tenant has less than two subnets to route between.
+func tenantRouterSubnets(t *types.Tenant) []payloads.RouterSubnet {
+	if !distributedRouting || t == nil || t.CNCIctrl == nil {
+		return nil
+	}
+
+	subnets := t.CNCIctrl.RouterSubnets()
+	if len(subnets) < 2 {
+		return nil
+	}
+
+	return subnets
+}
+
+// tenantRouterNodes returns the compute nodes running instances of a
+// tenant, each of which has a router of the tenant.
+func tenantRouterNodes(instances []*types.Instance) []string {
+	var nodes []string
+
+	seen := make(map[string]bool)
+	for _, i := range instances {
+		if i.CNCI || i.NodeID == "" || seen[i.NodeID] {
+			continue
+		}
+
+		seen[i.NodeID] = true
+		nodes = append(nodes, i.NodeID)
+	}
+
+	sort.Strings(nodes)
+
+	return nodes
+}
+
+// updateTenantRoutes sends the subnets of a tenant to the compute nodes
+// running its instances, which connect their router of the tenant to the
+// subnets, and to the CNCIs of the tenant, which hand out routes to the
+// other subnets through the routers of the compute nodes.  It is called
+// when the CNCIs of the tenant change.
+func (c *controller) updateTenantRoutes(tenantID string) {
+	if !distributedRouting {
+		return
+	}
+
+	c.cnciUpdateLock.Lock()
+	defer c.cnciUpdateLock.Unlock()
+
+	tenant, err := c.ds.GetTenant(tenantID)
+	if err != nil || tenant == nil {
+		glog.Warningf("Unable to update routes of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	cncis, err := c.ds.GetTenantCNCIs(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update routes of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	instances, err := c.ds.GetAllInstancesFromTenant(tenantID)
+	if err != nil {
+		glog.Warningf("Unable to update routes of tenant %s: %v", tenantID, err)
+		return
+	}
+
+	// an empty list tears down the routers of the tenant
+	subnets := tenantRouterSubnets(tenant)
+	if subnets == nil {
+		subnets = []payloads.RouterSubnet{}
+	}
+
+	var nodes []string
+	for _, cnci := range cncis {
+		nodes = append(nodes, cnci.ID)
+	}
+	nodes = append(nodes, tenantRouterNodes(instances)...)
+
+	for _, node := range nodes {
+		cmd := payloads.TenantRoutesCmd{
+			NodeUUID:   node,
+			TenantUUID: tenantID,
+			Subnets:    subnets,
+		}
+
+		err = c.client.setTenantRoutes(cmd)
+		if err != nil {
+			glog.Warningf("Unable to send routes to node %s: %v", node, err)
+		}
+	}
+}
@@ -169,6 +169,14 @@ func (c *controller) deleteInstances(tenantID string) error {
 		}
 	}
 
+	// and any port forwards
+	for _, pf := range c.ds.GetPortForwards(tenantID) {
+		err := c.DeletePortForward(tenantID, pf.ID)
+		if err != nil {
+			return errors.Wrap(err, "Unable to remove tenant")
+		}
+	}
+
 	// delete all this tenant's instances.
 	instances, err := c.ds.GetAllInstancesFromTenant(tenantID)
 	if err != nil {
@@ -244,7 +252,20 @@ func (c *controller) DeleteTenant(tenantID string) error {
 	}
 
 	for _, bd := range bds {
-		err := c.DeleteBlockDevice(bd.ID)
+		driver, err := c.volumeDriver(bd.VolumeType)
+		if err != nil {
+			return errors.Wrap(err, "Unable to remove tenant")
+		}
+
+		err = driver.DeleteBlockDevice(bd.ID)
+		if err != nil {
+			return errors.Wrap(err, "Unable to remove tenant")
+		}
+	}
+
+	// remove any networks of this tenant, all instances are gone.
+	for _, network := range c.ds.GetTenantNetworks(tenantID) {
+		err := c.ds.DeleteTenantNetwork(tenantID, network.ID)
 		if err != nil {
 			return errors.Wrap(err, "Unable to remove tenant")
 		}
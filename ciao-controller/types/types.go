@@ -89,6 +89,10 @@ type Workload struct {
 	Config      string                       `json:"config"`
 	Defaults    []payloads.RequestedResource `json:"defaults"`
 	Storage     []StorageResource            `json:"storage"`
+
+	// NodeSelector restricts instances of the workload to nodes
+	// carrying all of the given labels.
+	NodeSelector map[string]string `json:"node_selector,omitempty"`
 }
 
 // WorkloadResponse will be returned from /workloads apis
@@ -101,33 +105,58 @@ type WorkloadResponse struct {
 // WorkloadRequest contains resource and configuration for a user
 // workload.
 type WorkloadRequest struct {
-	WorkloadID string
-	TenantID   string
-	Instances  int
-	TraceLabel string
-	Volumes    []storage.BlockDevice
-	Name       string
-	Subnet     string
+	WorkloadID     string
+	TenantID       string
+	Instances      int
+	TraceLabel     string
+	Volumes        []storage.BlockDevice
+	Name           string
+	Subnet         string
+	ServerGroup    string
+	SecurityGroups []string
+	Networks       []string
 }
 
 // Instance contains information about an instance of a workload.
 type Instance struct {
-	ID          string       `json:"instance_id"`
-	TenantID    string       `json:"tenant_id"`
-	State       string       `json:"instance_state"`
-	WorkloadID  string       `json:"workload_id"`
-	NodeID      string       `json:"node_id"`
-	MACAddress  string       `json:"mac_address"`
-	VnicUUID    string       `json:"vnic_uuid"`
-	Subnet      string       `json:"subnet"`
-	IPAddress   string       `json:"ip_address"`
-	SSHIP       string       `json:"ssh_ip"`
-	SSHPort     int          `json:"ssh_port"`
-	CNCI        bool         `json:"-"`
-	CreateTime  time.Time    `json:"-"`
-	Name        string       `json:"name"`
-	StateLock   sync.RWMutex `json:"-"`
-	StateChange *sync.Cond   `json:"-"`
+	ID           string       `json:"instance_id"`
+	TenantID     string       `json:"tenant_id"`
+	State        string       `json:"instance_state"`
+	WorkloadID   string       `json:"workload_id"`
+	NodeID       string       `json:"node_id"`
+	MACAddress   string       `json:"mac_address"`
+	VnicUUID     string       `json:"vnic_uuid"`
+	Subnet       string       `json:"subnet"`
+	IPAddress    string       `json:"ip_address"`
+	SSHIP        string       `json:"ssh_ip"`
+	SSHPort      int          `json:"ssh_port"`
+	ConsoleType  string       `json:"-"`
+	ConsoleIP    string       `json:"-"`
+	ConsolePort  int          `json:"-"`
+	ConsoleToken string       `json:"-"`
+	CNCI         bool         `json:"-"`
+	CreateTime   time.Time    `json:"-"`
+	Name         string       `json:"name"`
+	StateLock    sync.RWMutex `json:"-"`
+	StateChange  *sync.Cond   `json:"-"`
+
+	// Resources the instance has been resized to, overriding the
+	// defaults of its workload.
+	Resources []payloads.RequestedResource `json:"-"`
+
+	// VNICs of the instance beyond the one described above, for
+	// instances attached to more than one tenant network.
+	Vnics []InstanceVnic `json:"vnics,omitempty"`
+}
+
+// InstanceVnic describes an additional VNIC of an instance attached to
+// more than one tenant network.
+type InstanceVnic struct {
+	NetworkID  string `json:"network_id"`
+	VnicUUID   string `json:"vnic_uuid"`
+	MACAddress string `json:"mac_address"`
+	Subnet     string `json:"subnet"`
+	IPAddress  string `json:"ip_address"`
 }
 
 // SortedInstancesByID implements sort.Interface for Instance by ID string
@@ -276,6 +305,9 @@ const (
 	// Detaching means that the volume is in process
 	// of detaching.
 	Detaching BlockState = "detaching"
+
+	// Extending means that the volume is being resized.
+	Extending BlockState = "extending"
 )
 
 // BlockData respresents the attributes of this block device.
@@ -289,6 +321,20 @@ type BlockData struct {
 	Name        string     // a human readable name for this volume
 	Description string     // some text to describe this volume.
 	Internal    bool       // whether this storage should be shown to the user
+	VolumeType  string     // the volume type, empty for the default type
+}
+
+// SnapshotData represents the attributes of a snapshot of a block device.
+// The block driver names the snapshot after its ID.
+type SnapshotData struct {
+	ID          string     // a uuid
+	VolumeID    string     // the block device of the snapshot
+	TenantID    string     // the tenant who owns this snapshot
+	State       BlockState // status of the snapshot
+	Size        int        // the size of the block device, in GiB
+	CreateTime  time.Time  // when we created the snapshot
+	Name        string     // a human readable name for this snapshot
+	Description string     // some text to describe this snapshot
 }
 
 // StorageAttachment represents a link between a block device and
@@ -304,24 +350,25 @@ type StorageAttachment struct {
 // CiaoNode contains status and statistic information for an individual
 // node.
 type CiaoNode struct {
-	ID                    string    `json:"id"`
-	Hostname              string    `json:"hostname"`
-	Timestamp             time.Time `json:"updated"`
-	Status                string    `json:"status"`
-	MemTotal              int       `json:"ram_total"`
-	MemAvailable          int       `json:"ram_available"`
-	DiskTotal             int       `json:"disk_total"`
-	DiskAvailable         int       `json:"disk_available"`
-	Load                  int       `json:"load"`
-	OnlineCPUs            int       `json:"online_cpus"`
-	TotalInstances        int       `json:"total_instances"`
-	TotalRunningInstances int       `json:"total_running_instances"`
-	TotalPendingInstances int       `json:"total_pending_instances"`
-	TotalPausedInstances  int       `json:"total_paused_instances"`
-	TotalFailures         int       `json:"total_failures"`
-	StartFailures         int       `json:"start_failures"`
-	AttachVolumeFailures  int       `json:"attach_failures"`
-	DeleteFailures        int       `json:"delete_failures"`
+	ID                    string            `json:"id"`
+	Hostname              string            `json:"hostname"`
+	Timestamp             time.Time         `json:"updated"`
+	Status                string            `json:"status"`
+	MemTotal              int               `json:"ram_total"`
+	MemAvailable          int               `json:"ram_available"`
+	DiskTotal             int               `json:"disk_total"`
+	DiskAvailable         int               `json:"disk_available"`
+	Load                  int               `json:"load"`
+	OnlineCPUs            int               `json:"online_cpus"`
+	TotalInstances        int               `json:"total_instances"`
+	TotalRunningInstances int               `json:"total_running_instances"`
+	TotalPendingInstances int               `json:"total_pending_instances"`
+	TotalPausedInstances  int               `json:"total_paused_instances"`
+	TotalFailures         int               `json:"total_failures"`
+	StartFailures         int               `json:"start_failures"`
+	AttachVolumeFailures  int               `json:"attach_failures"`
+	DeleteFailures        int               `json:"delete_failures"`
+	Labels                map[string]string `json:"labels,omitempty"`
 }
 
 // NodeStatusType contains the valid values of a node's status
@@ -342,8 +389,55 @@ const (
 )
 
 // CiaoNodeStatus contains status information for an individual node.
+// Labels, when present, replace the labels set on the node.
 type CiaoNodeStatus struct {
-	Status NodeStatusType `json:"status"`
+	Status NodeStatusType    `json:"status,omitempty"`
+	Labels map[string]string `json:"labels"`
+}
+
+// NodeNetworkInspectRequest asks a node to inspect its network topology.
+// When Repair is set, the node fixes the problems it can before reporting.
+type NodeNetworkInspectRequest struct {
+	Repair bool `json:"repair"`
+}
+
+// NodeNetworkLink is a network device created by ciao on a node.  Master is
+// the alias of the bridge the device is attached to.
+type NodeNetworkLink struct {
+	Alias  string `json:"alias"`
+	Name   string `json:"name"`
+	Master string `json:"master,omitempty"`
+	Up     bool   `json:"up,omitempty"`
+}
+
+// NodeNetworkDnsmasq describes the dnsmasq serving the subnet of a CNCI
+// bridge.
+type NodeNetworkDnsmasq struct {
+	Bridge  string `json:"bridge"`
+	PID     int    `json:"pid"`
+	Running bool   `json:"running"`
+}
+
+// NodeNetworkProblem is a difference between the expected and the actual
+// network topology of a node.
+type NodeNetworkProblem struct {
+	Alias    string `json:"alias"`
+	Problem  string `json:"problem"`
+	Repaired bool   `json:"repaired"`
+}
+
+// NodeNetwork is the last network report of a compute node, network node
+// or CNCI.  Expected is the topology recorded by the node and Actual the
+// devices found on the node, before any repair.
+type NodeNetwork struct {
+	NodeID    string               `json:"node_id"`
+	Timestamp time.Time            `json:"updated"`
+	Repair    bool                 `json:"repair"`
+	Expected  []NodeNetworkLink    `json:"expected"`
+	Actual    []NodeNetworkLink    `json:"actual"`
+	Dnsmasq   []NodeNetworkDnsmasq `json:"dnsmasq"`
+	Problems  []NodeNetworkProblem `json:"problems"`
+	Error     string               `json:"error,omitempty"`
 }
 
 // CiaoNodes represents the unmarshalled version of the contents of a
@@ -565,6 +659,16 @@ var (
 	// ErrInstanceNotAssigned is returned when an instance is not assigned to a node.
 	ErrInstanceNotAssigned = errors.New("Cannot perform operation: instance not assigned to Node")
 
+	// ErrInstanceNotStopped is returned when an operation requires a stopped instance.
+	ErrInstanceNotStopped = errors.New("Cannot perform operation: instance not stopped")
+
+	// ErrNoBootVolume is returned when an operation requires an instance booted from a volume.
+	ErrNoBootVolume = errors.New("Cannot perform operation: instance has no boot volume")
+
+	// ErrBootVolumeType is returned when an image is to be created from a
+	// boot volume that is not stored by the driver storing the images.
+	ErrBootVolumeType = errors.New("Cannot perform operation: boot volume is not of the default volume type")
+
 	// ErrDuplicateSubnet is returned when a subnet already exists
 	ErrDuplicateSubnet = errors.New("Cannot add overlapping subnet")
 
@@ -577,6 +681,9 @@ var (
 	// ErrSubnetTooSmall is returned when an invalid subnet is used
 	ErrSubnetTooSmall = errors.New("Requested subnet is too small to be usable")
 
+	// ErrSubnetTooLarge is returned when a subnet has too many addresses
+	ErrSubnetTooLarge = errors.New("Requested subnet is too large to be usable")
+
 	// ErrPoolNotFound is returned when an external IP pool is not found
 	ErrPoolNotFound = errors.New("Pool not found")
 
@@ -599,7 +706,8 @@ var (
 	ErrDuplicatePoolName = errors.New("Pool by that name already exists")
 
 	// ErrInstanceMapped is returned when an instance cannot be deleted
-	// due to having an external IP assigned to it.
+	// due to having an external IP assigned to it or ports of an external
+	// IP forwarded to it.
 	ErrInstanceMapped = errors.New("Unmap the external IP prior to deletion")
 
 	// ErrWorkloadNotFound is returned when a workload ID cannot be found
@@ -607,6 +715,90 @@ var (
 
 	// ErrWorkloadInUse is returned by DeleteWorkload when an instance of a workload is still active.
 	ErrWorkloadInUse = errors.New("Workload definition still in use")
+
+	// ErrServerGroupNotFound is returned when a server group ID cannot be found
+	ErrServerGroupNotFound = errors.New("Server group not found")
+
+	// ErrServerGroupInUse is returned by DeleteServerGroup when the group still has members.
+	ErrServerGroupInUse = errors.New("Server group still has members")
+
+	// ErrSecurityGroupNotFound is returned when a security group ID cannot be found
+	ErrSecurityGroupNotFound = errors.New("Security group not found")
+
+	// ErrSecurityGroupInUse is returned by DeleteSecurityGroup when the group
+	// still has members or is the remote group of another group's rule.
+	ErrSecurityGroupInUse = errors.New("Security group still in use")
+
+	// ErrDuplicateSecurityGroupName is returned when a tenant already has a
+	// security group with the same name.
+	ErrDuplicateSecurityGroupName = errors.New("Duplicate security group name")
+
+	// ErrSecurityGroupRuleNotFound is returned when a security group rule ID cannot be found
+	ErrSecurityGroupRuleNotFound = errors.New("Security group rule not found")
+
+	// ErrTenantNetworkNotFound is returned when a tenant network ID cannot be found
+	ErrTenantNetworkNotFound = errors.New("Network not found")
+
+	// ErrTenantNetworkInUse is returned by DeleteTenantNetwork when
+	// instances are still attached to the network.
+	ErrTenantNetworkInUse = errors.New("Network still has instances attached")
+
+	// ErrInvalidFlavor is returned when an instance is resized to a
+	// workload of another type or without VCPUs or memory.
+	ErrInvalidFlavor = errors.New("Workload cannot be used to resize the instance")
+
+	// ErrInvalidInstanceName is returned when an instance name is not a
+	// valid DNS label.
+	ErrInvalidInstanceName = errors.New("Instance name is not a valid DNS label")
+
+	// ErrDuplicateTenantNetworkName is returned when a tenant already has a
+	// network with the same name.
+	ErrDuplicateTenantNetworkName = errors.New("Duplicate network name")
+
+	// ErrTenantSubnetInUse is returned when the subnet of a new tenant
+	// network is already used by the tenant.
+	ErrTenantSubnetInUse = errors.New("Subnet already in use")
+
+	// ErrLoadBalancerNotFound is returned when a load balancer ID cannot be found
+	ErrLoadBalancerNotFound = errors.New("Load balancer not found")
+
+	// ErrDuplicateLoadBalancerName is returned when a tenant already has a
+	// load balancer with the same name.
+	ErrDuplicateLoadBalancerName = errors.New("Duplicate load balancer name")
+
+	// ErrLoadBalancerMemberSubnet is returned when a new member of a load
+	// balancer is not on the same subnet as its other members.
+	ErrLoadBalancerMemberSubnet = errors.New("Load balancer members must share a subnet")
+
+	// ErrPortForwardNotFound is returned when a port forward ID cannot be found
+	ErrPortForwardNotFound = errors.New("Port forward not found")
+
+	// ErrDuplicatePortForward is returned when a port of an external IP is
+	// already forwarded for the same protocol.
+	ErrDuplicatePortForward = errors.New("Port already forwarded")
+
+	// ErrPortForwardSubnet is returned when a port of an external IP would be
+	// forwarded to an instance which is not on the same subnet as the
+	// instances the other ports of the external IP are forwarded to.
+	ErrPortForwardSubnet = errors.New("Ports of an external IP must be forwarded to a single subnet")
+
+	// ErrSnapshotNotFound is returned when a snapshot ID cannot be found
+	ErrSnapshotNotFound = errors.New("Snapshot not found")
+
+	// ErrBlockDeviceState is returned when the state of a block device
+	// cannot be changed from the state it is in.
+	ErrBlockDeviceState = errors.New("Block device state cannot be changed")
+
+	// ErrNodeNotFound is returned when a node or a CNCI is not connected.
+	ErrNodeNotFound = errors.New("Node not found")
+
+	// ErrNodeNetworkNotFound is returned when a node has not yet reported
+	// its network topology.
+	ErrNodeNetworkNotFound = errors.New("Node network report not found")
+
+	// ErrNodeNetworkTimeout is returned when a node does not report its
+	// network topology in time.
+	ErrNodeNetworkTimeout = errors.New("Timed out waiting for node network report")
 )
 
 // Link provides a url and relationship for a resource.
@@ -770,6 +962,172 @@ func (qd *QuotaDetails) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ServerGroup represents a group of instances placed according to a common
+// affinity or anti-affinity policy.
+type ServerGroup struct {
+	ID       string                     `json:"id"`
+	TenantID string                     `json:"tenant_id"`
+	Name     string                     `json:"name"`
+	Policy   payloads.ServerGroupPolicy `json:"policy"`
+	Members  []string                   `json:"members"`
+	Links    []Link                     `json:"links,omitempty"`
+}
+
+// ServerGroupRequest is used to create a new server group.
+type ServerGroupRequest struct {
+	Name   string                     `json:"name"`
+	Policy payloads.ServerGroupPolicy `json:"policy"`
+}
+
+// ListServerGroupsResponse represents a list of server groups.
+type ListServerGroupsResponse struct {
+	ServerGroups []ServerGroup `json:"server_groups"`
+}
+
+// DefaultSecurityGroup is the name of the security group instances are
+// members of when they are not started in any other group.
+const DefaultSecurityGroup = "default"
+
+// SecurityGroupRule allows a class of traffic to or from the members of a
+// security group.  The other end of the traffic is either in the
+// RemoteIPPrefix address range or a member of the RemoteGroupID security
+// group.  It is unrestricted if neither is set.
+type SecurityGroupRule struct {
+	ID             string                          `json:"id"`
+	Direction      payloads.SecurityGroupDirection `json:"direction"`
+	Protocol       string                          `json:"protocol,omitempty"`
+	PortRangeMin   int                             `json:"port_range_min,omitempty"`
+	PortRangeMax   int                             `json:"port_range_max,omitempty"`
+	RemoteIPPrefix string                          `json:"remote_ip_prefix,omitempty"`
+	RemoteGroupID  string                          `json:"remote_group_id,omitempty"`
+}
+
+// SecurityGroup represents a set of rules filtering the traffic of the
+// instances that are members of the group.  Traffic allowed by none of the
+// rules of the groups of an instance is dropped.
+type SecurityGroup struct {
+	ID          string              `json:"id"`
+	TenantID    string              `json:"tenant_id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description"`
+	Rules       []SecurityGroupRule `json:"rules"`
+	Members     []string            `json:"members"`
+	Links       []Link              `json:"links,omitempty"`
+}
+
+// SecurityGroupRequest is used to create a new security group.
+type SecurityGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ListSecurityGroupsResponse represents a list of security groups.
+type ListSecurityGroupsResponse struct {
+	SecurityGroups []SecurityGroup `json:"security_groups"`
+}
+
+// TenantNetwork is a tenant subnet created by the tenant.  Instances may be
+// attached to several tenant networks, with one VNIC in each of them.
+type TenantNetwork struct {
+	ID       string `json:"id"`
+	TenantID string `json:"tenant_id"`
+	Name     string `json:"name"`
+	CIDR     string `json:"cidr"`
+	Links    []Link `json:"links,omitempty"`
+}
+
+// TenantNetworkRequest is used to create a new tenant network.
+type TenantNetworkRequest struct {
+	Name string `json:"name"`
+	CIDR string `json:"cidr"`
+}
+
+// ListTenantNetworksResponse represents a list of tenant networks.
+type ListTenantNetworksResponse struct {
+	Networks []TenantNetwork `json:"networks"`
+}
+
+// LoadBalancer accepts TCP connections on an external IP and port and
+// proxies them to the member port of its healthy members.  Members are
+// instances of the tenant on a single subnet, whose CNCI runs the load
+// balancer.  Members are healthy when they accept connections on their
+// member port.
+type LoadBalancer struct {
+	ID                  string   `json:"id"`
+	TenantID            string   `json:"tenant_id"`
+	Name                string   `json:"name"`
+	ExternalIP          string   `json:"external_ip"`
+	PoolID              string   `json:"pool_id"`
+	PoolName            string   `json:"pool_name"`
+	Protocol            string   `json:"protocol"`
+	Port                int      `json:"port"`
+	MemberPort          int      `json:"member_port"`
+	HealthCheckInterval int      `json:"health_check_interval"`
+	HealthCheckTimeout  int      `json:"health_check_timeout"`
+	Members             []string `json:"members"`
+	Links               []Link   `json:"links,omitempty"`
+}
+
+// LoadBalancerRequest is used to create a new load balancer, whose external
+// IP is taken from the pool named PoolName.  The member port defaults to
+// the port and the health check interval and timeout, in seconds, to 5 and
+// 2 seconds.
+type LoadBalancerRequest struct {
+	Name                string   `json:"name"`
+	PoolName            string   `json:"pool_name"`
+	Protocol            string   `json:"protocol"`
+	Port                int      `json:"port"`
+	MemberPort          int      `json:"member_port,omitempty"`
+	HealthCheckInterval int      `json:"health_check_interval,omitempty"`
+	HealthCheckTimeout  int      `json:"health_check_timeout,omitempty"`
+	Members             []string `json:"members,omitempty"`
+}
+
+// LoadBalancerMemberRequest is used to add an instance to a load balancer.
+type LoadBalancerMemberRequest struct {
+	InstanceID string `json:"instance_id"`
+}
+
+// ListLoadBalancersResponse represents a list of load balancers.
+type ListLoadBalancersResponse struct {
+	LoadBalancers []LoadBalancer `json:"load_balancers"`
+}
+
+// PortForward forwards a TCP or UDP port of an external IP to a port of an
+// instance.  Several instances of a tenant subnet may share an external IP,
+// which is released once none of its ports is forwarded.
+type PortForward struct {
+	ID           string `json:"id"`
+	TenantID     string `json:"tenant_id"`
+	ExternalIP   string `json:"external_ip"`
+	PoolID       string `json:"pool_id"`
+	PoolName     string `json:"pool_name"`
+	Protocol     string `json:"protocol"`
+	ExternalPort int    `json:"external_port"`
+	InstanceID   string `json:"instance_id"`
+	InternalIP   string `json:"internal_ip"`
+	InternalPort int    `json:"internal_port"`
+	Links        []Link `json:"links,omitempty"`
+}
+
+// PortForwardRequest is used to forward a port of an external IP to an
+// instance.  The external IP is either one whose ports are already
+// forwarded by the tenant or, if ExternalIP is empty, a new one taken from
+// the pool named PoolName.  The internal port defaults to the external one.
+type PortForwardRequest struct {
+	ExternalIP   string  `json:"external_ip,omitempty"`
+	PoolName     *string `json:"pool_name,omitempty"`
+	Protocol     string  `json:"protocol"`
+	ExternalPort int     `json:"external_port"`
+	InstanceID   string  `json:"instance_id"`
+	InternalPort int     `json:"internal_port,omitempty"`
+}
+
+// ListPortForwardsResponse represents a list of port forwards.
+type ListPortForwardsResponse struct {
+	PortForwards []PortForward `json:"port_forwards"`
+}
+
 // QuotaUpdateRequest holds the layout for updating quota API
 type QuotaUpdateRequest struct {
 	Quotas []QuotaDetails `json:"quotas"`
@@ -793,5 +1151,6 @@ type CNCIController interface {
 	WaitForActiveSubnetString(subnet string) error
 	GetInstanceCNCI(InstanceID string) (*Instance, error)
 	GetSubnetCNCI(subnet string) (*Instance, error)
+	RouterSubnets() []payloads.RouterSubnet
 	Shutdown()
 }
@@ -29,6 +29,34 @@ func NewTenantHardwareAddr(ip net.IP) net.HardwareAddr {
 	return net.HardwareAddr(buf)
 }
 
+// TenantIPv6Prefix is the unique local prefix from which the IPv6 subnets
+// of tenants are derived.  It must match the one used by the CNCIs.
+var TenantIPv6Prefix = net.ParseIP("fd00:c1a0::")
+
+// TenantIPv6Addr returns the IPv6 address paired with the IPv4 address of
+// a tenant instance, the same way the CNCI of its subnet derives it.  The
+// subnet ID is made of the second and third bytes of the IPv4 subnet and
+// the interface ID of the last two bytes of the IPv4 address.  Nil is
+// returned for addresses which have no IPv6 address.
+func TenantIPv6Addr(ip net.IP, subnet *net.IPNet) net.IP {
+	ipBytes := ip.To4()
+	subnetBytes := subnet.IP.To4()
+	ones, bits := subnet.Mask.Size()
+	if ipBytes == nil || subnetBytes == nil || bits != 32 || ones < 16 ||
+		!subnet.Contains(ipBytes) {
+		return nil
+	}
+	subnetBytes = subnetBytes.Mask(subnet.Mask)
+
+	buf := make(net.IP, net.IPv6len)
+	copy(buf, TenantIPv6Prefix)
+	buf[6] = subnetBytes[1]
+	buf[7] = subnetBytes[2]
+	buf[14] = ipBytes[2]
+	buf[15] = ipBytes[3]
+	return buf
+}
+
 // NewHardwareAddr will generate a MAC address for a CNCI.
 func NewHardwareAddr() (net.HardwareAddr, error) {
 	buf := make([]byte, 6)
@@ -31,6 +31,27 @@ func TestNewTenantHardwareAddr(t *testing.T) {
 	}
 }
 
+// TestTenantIPv6Addr
+// Confirm that the IPv6 addresses derived from given IPv4
+// addresses and subnets are as expected.
+func TestTenantIPv6Addr(t *testing.T) {
+	_, subnet, _ := net.ParseCIDR("172.16.5.0/24")
+	ip := TenantIPv6Addr(net.ParseIP("172.16.5.7"), subnet)
+	expectedIP := "fd00:c1a0:0:1005::507"
+	if ip.String() != expectedIP {
+		t.Error("Expected: ", expectedIP, " Received: ", ip.String())
+	}
+
+	if TenantIPv6Addr(net.ParseIP("172.16.6.7"), subnet) != nil {
+		t.Error("Expected no IPv6 address outside of the subnet")
+	}
+
+	_, large, _ := net.ParseCIDR("10.0.0.0/8")
+	if TenantIPv6Addr(net.ParseIP("10.1.2.3"), large) != nil {
+		t.Error("Expected no IPv6 address for subnets larger than /16")
+	}
+}
+
 // TestHardwareAddr
 // Confirm that the mac addresses generated from a given
 // IP address is as expected.
@@ -135,6 +135,13 @@ func validateWorkloadRequest(req types.Workload) error {
 		}
 	}
 
+	for _, r := range req.Defaults {
+		if (r.Type == payloads.NetworkIngressKbps || r.Type == payloads.NetworkEgressKbps) && r.Value < 0 {
+			glog.V(2).Info("Invalid workload request: negative bandwidth limit")
+			return types.ErrBadRequest
+		}
+	}
+
 	return nil
 }
 
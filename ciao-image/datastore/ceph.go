@@ -23,10 +23,11 @@ import (
 	"github.com/ciao-project/ciao/ciao-storage"
 )
 
-// Ceph implements the RawDataStore interface for Ceph RBD based storage
+// Ceph implements the RawDataStore interface for block device based
+// storage. It was written for Ceph RBD but works with any block driver.
 type Ceph struct {
 	ImageTempDir string
-	BlockDriver  storage.CephDriver
+	BlockDriver  storage.BlockDriver
 }
 
 // Write copies an image onto the fileystem into a tempory location and uploads
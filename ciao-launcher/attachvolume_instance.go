@@ -17,13 +17,42 @@
 package main
 
 import (
+	"fmt"
+
 	storage "github.com/ciao-project/ciao/ciao-storage"
 	"github.com/ciao-project/ciao/payloads"
 	"github.com/golang/glog"
 )
 
+// mapVolume maps a volume to the node, unless it is already mapped, and
+// returns the path to its device.
+func mapVolume(storageDriver storage.BlockDriver, volumeUUID string) (string, error) {
+	volumeMap, err := storageDriver.GetVolumeMapping()
+	if err != nil {
+		return "", fmt.Errorf("Unable to retrieve list of mapped volumes: %v", err)
+	}
+
+	if len(volumeMap[volumeUUID]) > 0 {
+		devName := volumeMap[volumeUUID][0]
+		glog.Infof("Volume %s already mapped %s", volumeUUID, devName)
+		return devName, nil
+	}
+
+	return storageDriver.MapVolumeToNode(volumeUUID)
+}
+
+// volumeDriver returns the block driver that manages volumes of the given
+// type.  Volumes without a type are managed by the default driver.
+func volumeDriver(storageDriver storage.BlockDriver, volumeType string) (storage.BlockDriver, error) {
+	if volumeType == "" {
+		return storageDriver, nil
+	}
+
+	return volumeTypes.Driver(volumeType)
+}
+
 func processAttachVolume(storageDriver storage.BlockDriver, monitorCh chan interface{}, cfg *vmConfig,
-	instance, instanceDir, volumeUUID string, conn serverConn) *attachVolumeError {
+	instance, instanceDir, volumeUUID, volumeType string, conn serverConn) *attachVolumeError {
 
 	if cfg.Container {
 		attachErr := &attachVolumeError{nil, payloads.AttachVolumeNotSupported}
@@ -38,30 +67,23 @@ func processAttachVolume(storageDriver storage.BlockDriver, monitorCh chan inter
 		return attachErr
 	}
 
+	driver, err := volumeDriver(storageDriver, volumeType)
+	if err != nil {
+		attachErr := &attachVolumeError{err, payloads.AttachVolumeAttachFailure}
+		glog.Errorf("Unable to attach volume %s [%s]: %v",
+			volumeUUID, string(attachErr.code), err)
+		return attachErr
+	}
+
 	if monitorCh != nil {
-		volumeMap, err := storageDriver.GetVolumeMapping()
+		devName, err := mapVolume(driver, volumeUUID)
 		if err != nil {
 			attachErr := &attachVolumeError{err, payloads.AttachVolumeAttachFailure}
-			glog.Errorf("Unable to retrieve list of mapped volumes [%s]: %v",
-				string(attachErr.code), err)
+			glog.Errorf("Unable to map volume  %s [%s]: %v",
+				volumeUUID, string(attachErr.code), err)
 			return attachErr
 		}
-
-		var devName string
-
-		if len(volumeMap[volumeUUID]) > 0 {
-			devName = volumeMap[volumeUUID][0]
-			glog.Infof("Volume %s already mapped %s", volumeUUID, devName)
-		} else {
-			devName, err = storageDriver.MapVolumeToNode(volumeUUID)
-			if err != nil {
-				attachErr := &attachVolumeError{err, payloads.AttachVolumeAttachFailure}
-				glog.Errorf("Unable to map volume  %s [%s]: %v",
-					volumeUUID, string(attachErr.code), err)
-				return attachErr
-			}
-			glog.Infof("Mapped instance %s volume %s as %s", instance, volumeUUID, devName)
-		}
+		glog.Infof("Mapped instance %s volume %s as %s", instance, volumeUUID, devName)
 
 		responseCh := make(chan error)
 
@@ -75,15 +97,15 @@ func processAttachVolume(storageDriver storage.BlockDriver, monitorCh chan inter
 		if err != nil {
 			glog.Errorf("Unable to attach volume %s to instance %s: %v",
 				volumeUUID, instance, err)
-			_ = storageDriver.UnmapVolumeFromNode(devName)
+			_ = driver.UnmapVolumeFromNode(devName)
 			attachErr := &attachVolumeError{err, payloads.AttachVolumeAttachFailure}
 			return attachErr
 		}
 	}
 
-	cfg.Volumes = append(cfg.Volumes, volumeConfig{UUID: volumeUUID})
+	cfg.Volumes = append(cfg.Volumes, volumeConfig{UUID: volumeUUID, VolumeType: volumeType})
 
-	err := cfg.save(instanceDir)
+	err = cfg.save(instanceDir)
 	if err != nil {
 		// TODO: should we detach and unmap here?
 		cfg.removeVolume(volumeUUID)
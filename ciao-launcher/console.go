@@ -0,0 +1,261 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/pkg/errors"
+)
+
+const (
+	consoleAuthTimeout = 10 * time.Second
+	consoleAuthOK      = "OK\n"
+	consoleTokenSize   = 16
+
+	consoleAcceptMinDelay = 5 * time.Millisecond
+	consoleAcceptMaxDelay = time.Second
+)
+
+/*
+The virtual consoles of the instances only listen on localhost.  They are
+reached through the console proxy, which listens on the management IP address
+of the node and is the address reported for the consoles in the STATS
+payloads.  Each console is given a random token, which is only sent to
+ciao-controller in these payloads.  A client of the proxy sends the UUID of
+the instance and its token, separated by a space and terminated by a newline.
+The proxy replies with consoleAuthOK once connected to the console and closes
+the connection if the token is wrong.
+*/
+type consoleProxy struct {
+	sync.Mutex
+	listener net.Listener
+	consoles map[string]consoleAuth
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+type consoleAuth struct {
+	port  int
+	token string
+}
+
+// consoles is nil when the launcher does not expose virtual consoles.
+var consoles *consoleProxy
+
+func startConsoleProxy(ipAddress string) (*consoleProxy, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(ipAddress, "0"))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to start console proxy")
+	}
+
+	p := &consoleProxy{
+		listener: listener,
+		consoles: make(map[string]consoleAuth),
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	glog.Infof("Console proxy listening on %s", listener.Addr())
+
+	p.wg.Add(1)
+	go p.accept()
+
+	return p, nil
+}
+
+func (p *consoleProxy) shutdown() {
+	p.Lock()
+	p.closed = true
+	_ = p.listener.Close()
+	for conn := range p.conns {
+		_ = conn.Close()
+	}
+	p.Unlock()
+
+	p.wg.Wait()
+}
+
+// address returns the IP address and the port on which the proxy listens.
+func (p *consoleProxy) address() (string, int) {
+	addr := p.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// register returns the token of the console of an instance listening on
+// port.  A new token is generated when the port of the console changes.
+func (p *consoleProxy) register(instance string, port int) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if c, ok := p.consoles[instance]; ok && c.port == port {
+		return c.token, nil
+	}
+
+	buf := make([]byte, consoleTokenSize)
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to generate console token")
+	}
+
+	token := hex.EncodeToString(buf)
+	p.consoles[instance] = consoleAuth{port: port, token: token}
+
+	return token, nil
+}
+
+func (p *consoleProxy) unregister(instance string) {
+	p.Lock()
+	delete(p.consoles, instance)
+	p.Unlock()
+}
+
+func (p *consoleProxy) authenticate(instance, token string) (int, bool) {
+	p.Lock()
+	c, ok := p.consoles[instance]
+	p.Unlock()
+
+	if !ok || subtle.ConstantTimeCompare([]byte(c.token), []byte(token)) != 1 {
+		return 0, false
+	}
+
+	return c.port, true
+}
+
+func (p *consoleProxy) track(conn net.Conn) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return false
+	}
+
+	p.conns[conn] = struct{}{}
+	p.wg.Add(1)
+
+	return true
+}
+
+func (p *consoleProxy) untrack(conn net.Conn) {
+	p.Lock()
+	delete(p.conns, conn)
+	p.Unlock()
+
+	_ = conn.Close()
+	p.wg.Done()
+}
+
+func (p *consoleProxy) accept() {
+	defer p.wg.Done()
+
+	var delay time.Duration
+	for {
+		conn, err := p.listener.Accept()
+		if err != nil {
+			p.Lock()
+			closed := p.closed
+			p.Unlock()
+			if closed {
+				return
+			}
+
+			if delay == 0 {
+				delay = consoleAcceptMinDelay
+			} else if delay *= 2; delay > consoleAcceptMaxDelay {
+				delay = consoleAcceptMaxDelay
+			}
+			glog.Warningf("Console proxy accept error: %v, retrying in %v", err, delay)
+			time.Sleep(delay)
+			continue
+		}
+		delay = 0
+
+		if !p.track(conn) {
+			_ = conn.Close()
+			return
+		}
+
+		go p.proxy(conn)
+	}
+}
+
+func (p *consoleProxy) proxy(conn net.Conn) {
+	defer p.untrack(conn)
+
+	_ = conn.SetReadDeadline(time.Now().Add(consoleAuthTimeout))
+
+	r := bufio.NewReader(conn)
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return
+	}
+
+	fields := strings.Fields(string(line))
+	if len(fields) != 2 {
+		return
+	}
+
+	port, ok := p.authenticate(fields[0], fields[1])
+	if !ok {
+		glog.Warningf("Console connection from %s to %s refused",
+			conn.RemoteAddr(), fields[0])
+		return
+	}
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+	backend, err := net.DialTimeout("tcp", addr, consoleAuthTimeout)
+	if err != nil {
+		glog.Warningf("Unable to connect to console of %s: %v", fields[0], err)
+		return
+	}
+	defer func() { _ = backend.Close() }()
+
+	_ = conn.SetReadDeadline(time.Time{})
+	_, err = io.WriteString(conn, consoleAuthOK)
+	if err != nil {
+		return
+	}
+
+	glog.Infof("Proxying console of %s to %s", fields[0], conn.RemoteAddr())
+
+	doneCh := make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(backend, r)
+		doneCh <- struct{}{}
+	}()
+
+	go func() {
+		_, _ = io.Copy(conn, backend)
+		doneCh <- struct{}{}
+	}()
+
+	<-doneCh
+	_ = conn.Close()
+	_ = backend.Close()
+	<-doneCh
+}
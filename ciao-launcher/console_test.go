@@ -0,0 +1,114 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func dialConsoleProxy(t *testing.T, p *consoleProxy, instance, token string) (net.Conn, *bufio.Reader, string) {
+	ip, port := p.address()
+	conn, err := net.Dial("tcp", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = fmt.Fprintf(conn, "%s %s\n", instance, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := bufio.NewReader(conn)
+	reply, _ := r.ReadString('\n')
+
+	return conn, r, reply
+}
+
+// Checks that the console proxy authenticates the connections to consoles.
+//
+// We register the console of an instance, listening on localhost, with a
+// proxy and connect to the proxy with a wrong token, then with the token of
+// the console.
+//
+// The first connection should be closed and the second one should be
+// proxied to the console.
+func TestConsoleProxy(t *testing.T) {
+	console, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = console.Close() }()
+
+	go func() {
+		conn, err := console.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(conn, conn)
+		_ = conn.Close()
+	}()
+
+	p, err := startConsoleProxy("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.shutdown()
+
+	token, err := p.register("testInstance", console.Addr().(*net.TCPAddr).Port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := p.register("testInstance", console.Addr().(*net.TCPAddr).Port)
+	if err != nil || again != token {
+		t.Errorf("Console token changed from %s to %s", token, again)
+	}
+
+	conn, _, reply := dialConsoleProxy(t, p, "testInstance", "wrong")
+	_ = conn.Close()
+	if reply != "" {
+		t.Fatalf("Connection with wrong token accepted: %q", reply)
+	}
+
+	conn, r, reply := dialConsoleProxy(t, p, "testInstance", token)
+	defer func() { _ = conn.Close() }()
+	if reply != consoleAuthOK {
+		t.Fatalf("Connection with token refused: %q", reply)
+	}
+
+	_, err = conn.Write([]byte("ping\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := r.ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("Expected ping from console, got %q: %v", line, err)
+	}
+
+	p.unregister("testInstance")
+	conn2, _, reply := dialConsoleProxy(t, p, "testInstance", token)
+	_ = conn2.Close()
+	if reply != "" {
+		t.Fatalf("Connection to unregistered console accepted: %q", reply)
+	}
+}
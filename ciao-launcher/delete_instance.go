@@ -39,6 +39,27 @@ func deleteVnic(instanceDir string, conn serverConn) {
 	if err != nil {
 		glog.Warningf("Unable to destroy vnic: %s", err)
 	}
+
+	extraCfgs, err := createExtraVnicCfgs(cfg)
+	if err != nil {
+		glog.Warningf("Unable to create extra vnicCfgs: %s", err)
+		return
+	}
+
+	for _, extraCfg := range extraCfgs {
+		err = destroyVnic(conn, extraCfg)
+		if err != nil {
+			glog.Warningf("Unable to destroy vnic %s: %s", extraCfg.VnicID, err)
+		}
+	}
+
+	if !cfg.NetworkNode {
+		releaseTenantRouter(conn, cfg.TenantUUID)
+
+		if err := refreshSecurityGroups(); err != nil {
+			glog.Warningf("%v", err)
+		}
+	}
 }
 
 func processDelete(vm virtualizer, instanceDir string, conn serverConn, running ovsRunningState) error {
@@ -259,7 +259,11 @@ func (d *docker) umountVolumes(vols []volumeConfig) {
 
 func (d *docker) unmapVolumes() {
 	for _, vol := range d.cfg.Volumes {
-		if err := d.storageDriver.UnmapVolumeFromNode(vol.UUID); err != nil {
+		driver, err := volumeDriver(d.storageDriver, vol.VolumeType)
+		if err == nil {
+			err = driver.UnmapVolumeFromNode(vol.UUID)
+		}
+		if err != nil {
 			glog.Warningf("Unable to unmap %s: %v", vol.UUID, err)
 			continue
 		}
@@ -270,8 +274,11 @@ func (d *docker) unmapVolumes() {
 func (d *docker) mapAndMountVolumes() error {
 	for mapped, vol := range d.cfg.Volumes {
 		var devName string
-		var err error
-		if devName, err = d.storageDriver.MapVolumeToNode(vol.UUID); err != nil {
+		driver, err := volumeDriver(d.storageDriver, vol.VolumeType)
+		if err == nil {
+			devName, err = driver.MapVolumeToNode(vol.UUID)
+		}
+		if err != nil {
 			d.umountVolumes(d.cfg.Volumes[:mapped])
 			return fmt.Errorf("Unable to map (%s) %v", vol.UUID, err)
 		}
@@ -373,7 +380,7 @@ func (d *docker) deleteImage() error {
 	return dockerDeleteContainer(d.cli, d.dockerID, d.cfg.Instance)
 }
 
-func (d *docker) startVM(vnicName, ipAddress, cephID string) error {
+func (d *docker) startVM(vnicName string, extraVnicNames []string, ipAddress, cephID string) error {
 	err := d.initDockerClient()
 	if err != nil {
 		return err
@@ -426,6 +433,12 @@ DONE:
 			case virtualizerAttachCmd:
 				err := fmt.Errorf("Live Attach of volumes not supported for containers")
 				cmd.responseCh <- err
+			case virtualizerDetachCmd:
+				err := fmt.Errorf("Live Detach of volumes not supported for containers")
+				cmd.responseCh <- err
+			case virtualizerResizeCmd:
+				err := fmt.Errorf("Live Resize of volumes not supported for containers")
+				cmd.responseCh <- err
 			}
 		}
 	}
@@ -508,6 +521,10 @@ func (d *docker) computeInstanceDiskspace() int {
 	return int(*con.SizeRootFs / (1024 * 1024))
 }
 
+func (d *docker) console() (string, int) {
+	return "", 0
+}
+
 func (d *docker) stats() (disk, memory, cpu int) {
 	disk = d.computeInstanceDiskspace()
 	memory = -1
@@ -16,5 +16,11 @@
 
 package main
 
+import "flag"
+
 var launchWithUI uiFlag = "none"
 var qemuVirtualisation qemuVirtualisationFlag = "kvm"
+
+func init() {
+	flag.Var(&launchWithUI, "with-ui", "Enables virtual consoles on VM instances, proxied by ciao-controller.  Can be 'none', 'spice', 'nc'")
+}
@@ -17,6 +17,7 @@
 package main
 
 import (
+	"fmt"
 	"path"
 	"sync"
 	"time"
@@ -48,6 +49,9 @@ type instanceData struct {
 	rcvStamp       time.Time
 	st             *startTimes
 	storageDriver  storage.BlockDriver
+	migrateCh      chan error
+	migrateDest    string
+	migrated       bool
 }
 
 type insStartCmd struct {
@@ -82,8 +86,27 @@ type insMonitorCmd struct{}
 
 type insAttachVolumeCmd struct {
 	volumeUUID string
+	volumeType string
 }
 
+type insDetachVolumeCmd struct {
+	volumeUUID string
+}
+
+type insResizeVolumeCmd struct {
+	volumeUUID string
+	sizeGiB    int
+}
+
+type insMigrateCmd struct {
+	destination string
+	uri         string
+}
+
+// Sent to an instance waiting for an incoming migration when the source
+// node reports that the migration has failed.
+type insAbortMigrationCmd struct{}
+
 /*
 This functions asks the server loop to kill the instance.  An instance
 needs to request that the server loop kill it if Start fails completly.
@@ -155,6 +178,17 @@ func (id *instanceData) startCommand(cmd *insStartCmd) {
 	if cmd.frame != nil && cmd.frame.PathTrace() {
 		id.ovsCh <- &ovsTraceFrame{cmd.frame}
 	}
+
+	if cmd.cfg.MigrateFrom != "" {
+		err := requestMigration(id.ac.conn, cmd.cfg)
+		if err != nil {
+			glog.Errorf("Unable to migrate instance %s: %v", id.instance, err)
+			startErr := &startError{err, payloads.LaunchFailure, cmd.cfg.Restart}
+			startErr.send(id.ac.conn, id.instance)
+			killMe(id.instance, true, false, id.doneCh, id.ac, &id.instanceWg)
+			id.shuttingDown = true
+		}
+	}
 }
 
 func (id *instanceData) monitorCommand(cmd *insMonitorCmd) {
@@ -236,17 +270,121 @@ func (id *instanceData) attachVolumeCommand(cmd *insAttachVolumeCmd) {
 	}
 
 	attachErr := processAttachVolume(id.storageDriver, id.monitorCh, id.cfg, id.instance, id.instanceDir,
-		cmd.volumeUUID, id.ac.conn)
+		cmd.volumeUUID, cmd.volumeType, id.ac.conn)
 	if attachErr != nil {
 		attachErr.send(id.ac.conn, id.instance, cmd.volumeUUID)
 		return
 	}
-	d, m, c := id.vm.stats()
-	id.ovsCh <- &ovsStatsUpdateCmd{id.instance, m, d, c, id.getVolumes()}
+	id.sendStats()
 
 	glog.Infof("Volume %s attached to instance %s", cmd.volumeUUID, id.instance)
 }
 
+func (id *instanceData) detachVolumeCommand(cmd *insDetachVolumeCmd) {
+	if id.shuttingDown {
+		detachErr := &detachVolumeError{nil, payloads.DetachVolumeInstanceFailure}
+		glog.Errorf("Unable to detach instance[%s]", string(detachErr.code))
+		detachErr.send(id.ac.conn, id.instance, cmd.volumeUUID)
+		return
+	}
+
+	detachErr := processDetachVolume(id.storageDriver, id.monitorCh, id.cfg, id.instance, id.instanceDir,
+		cmd.volumeUUID)
+	if detachErr != nil {
+		detachErr.send(id.ac.conn, id.instance, cmd.volumeUUID)
+		return
+	}
+	id.sendStats()
+
+	glog.Infof("Volume %s detached from instance %s", cmd.volumeUUID, id.instance)
+}
+
+func (id *instanceData) resizeVolumeCommand(cmd *insResizeVolumeCmd) {
+	if id.shuttingDown {
+		glog.Errorf("Unable to resize volume %s of instance %s: instance shutting down",
+			cmd.volumeUUID, id.instance)
+		return
+	}
+
+	err := processResizeVolume(id.storageDriver, id.monitorCh, id.cfg, id.instance,
+		cmd.volumeUUID, cmd.sizeGiB)
+	if err != nil {
+		glog.Errorf("Unable to resize volume %s of instance %s: %v",
+			cmd.volumeUUID, id.instance, err)
+		return
+	}
+
+	glog.Infof("Volume %s of instance %s resized to %d GiB", cmd.volumeUUID,
+		id.instance, cmd.sizeGiB)
+}
+
+func (id *instanceData) migrateCommand(cmd *insMigrateCmd) {
+	if id.shuttingDown || id.connectedCh != nil {
+		migrateErr := &migrateError{nil, payloads.MigrateNotRunning}
+		glog.Errorf("Unable to migrate instance[%s]", string(migrateErr.code))
+		migrateErr.send(id.ac.conn, id.instance, cmd.destination)
+		return
+	}
+
+	if id.migrateCh != nil {
+		migrateErr := &migrateError{nil, payloads.MigrateTransferFailure}
+		glog.Errorf("Migration of instance %s to %s already in progress",
+			id.instance, id.migrateDest)
+		migrateErr.send(id.ac.conn, id.instance, cmd.destination)
+		return
+	}
+
+	migrateCh, migrateErr := processMigrate(id.monitorCh, id.cfg, id.instance, cmd.uri)
+	if migrateErr != nil {
+		migrateErr.send(id.ac.conn, id.instance, cmd.destination)
+		return
+	}
+
+	glog.Infof("Migrating instance %s to %s", id.instance, cmd.uri)
+	id.migrateCh = migrateCh
+	id.migrateDest = cmd.destination
+}
+
+func (id *instanceData) migrationComplete(err error) {
+	id.migrateCh = nil
+
+	if err != nil {
+		glog.Errorf("Failed to migrate instance %s: %v", id.instance, err)
+		migrateErr := &migrateError{err, payloads.MigrateTransferFailure}
+		migrateErr.send(id.ac.conn, id.instance, id.migrateDest)
+		return
+	}
+
+	glog.Infof("Instance %s migrated to %s", id.instance, id.migrateDest)
+
+	// The instance is now running on the destination node.  We just need
+	// to shut down our copy and clean up without telling anyone.
+
+	id.migrated = true
+	id.monitorCh <- virtualizerQuitCmd{}
+}
+
+func (id *instanceData) abortMigrationCommand(cmd *insAbortMigrationCmd) {
+	if id.shuttingDown || id.cfg.IncomingURI == "" {
+		return
+	}
+
+	glog.Warningf("Incoming migration of %s failed.  Killing it", id.instance)
+	killMe(id.instance, true, true, id.doneCh, id.ac, &id.instanceWg)
+	id.shuttingDown = true
+}
+
+func (id *instanceData) incomingMigrationComplete() {
+	glog.Infof("Instance %s migrated from %s", id.instance, id.cfg.MigrateFrom)
+
+	id.cfg.IncomingURI = ""
+	id.cfg.MigrateFrom = ""
+	err := id.cfg.save(id.instanceDir)
+	if err != nil {
+		glog.Errorf("Unable to persist instance %s state: %v", id.instance, err)
+	}
+}
+
 func (id *instanceData) logStartTrace() {
 	if id.st == nil {
 		return
@@ -284,6 +422,14 @@ func (id *instanceData) instanceCommand(cmd interface{}) bool {
 		id.monitorCommand(cmd)
 	case *insAttachVolumeCmd:
 		id.attachVolumeCommand(cmd)
+	case *insDetachVolumeCmd:
+		id.detachVolumeCommand(cmd)
+	case *insResizeVolumeCmd:
+		id.resizeVolumeCommand(cmd)
+	case *insMigrateCmd:
+		id.migrateCommand(cmd)
+	case *insAbortMigrationCmd:
+		id.abortMigrationCommand(cmd)
 	case *insDeleteCmd:
 		if id.deleteCommand(cmd) {
 			return false
@@ -307,23 +453,34 @@ func (id *instanceData) unmapVolumes() {
 	glog.Infof("Unmapping volumes for %s", id.instance)
 
 	for _, v := range id.cfg.Volumes {
+		driver, err := volumeDriver(id.storageDriver, v.VolumeType)
+		if err != nil {
+			glog.Warningf("Unable to unmap volume %s: %v", v.UUID, err)
+			continue
+		}
 
 		// UnmapVolumeFromNode might fail if it's mapped to multiple
 		// instances on the same node.  We don't treat this as an
 		// error for now.
 
-		if err := id.storageDriver.UnmapVolumeFromNode(v.UUID); err == nil {
+		if err := driver.UnmapVolumeFromNode(v.UUID); err == nil {
 			glog.Infof("Unmapping volume %s", v.UUID)
 		}
 	}
 }
 
+func (id *instanceData) sendStats() {
+	d, m, c := id.vm.stats()
+	consoleType, consolePort := id.vm.console()
+	id.ovsCh <- &ovsStatsUpdateCmd{id.instance, m, d, c, id.getVolumes(),
+		consoleType, consolePort}
+}
+
 func (id *instanceData) instanceLoop() {
 
 	id.vm.init(id.cfg, id.instanceDir)
 
-	d, m, c := id.vm.stats()
-	id.ovsCh <- &ovsStatsUpdateCmd{id.instance, m, d, c, id.getVolumes()}
+	id.sendStats()
 
 DONE:
 	for {
@@ -331,18 +488,18 @@ DONE:
 		case <-id.doneCh:
 			break DONE
 		case <-id.statsTimer:
-			d, m, c := id.vm.stats()
-			id.ovsCh <- &ovsStatsUpdateCmd{id.instance, m, d, c, id.getVolumes()}
+			id.sendStats()
 			id.statsTimer = time.After(time.Second * resourcePeriod)
 		case cmd := <-id.cmdCh:
 			if !id.instanceCommand(cmd) {
 				break DONE
 			}
+		case err := <-id.migrateCh:
+			id.migrationComplete(err)
 		case <-id.monitorCloseCh:
 			// Means we've lost VM for now
 			id.vm.lostVM()
-			d, m, c := id.vm.stats()
-			id.ovsCh <- &ovsStatsUpdateCmd{id.instance, m, d, c, id.getVolumes()}
+			id.sendStats()
 
 			glog.Infof("Lost VM instance: %s", id.instance)
 			id.monitorCloseCh = nil
@@ -352,15 +509,24 @@ DONE:
 			id.statsTimer = nil
 			id.ovsCh <- &ovsStateChange{id.instance, ovsStopped}
 			id.st = nil
-			killMe(id.instance, false, true, id.doneCh, id.ac, &id.instanceWg)
+			if id.migrateCh != nil {
+				id.migrationComplete(fmt.Errorf("Instance lost during migration"))
+			}
+
+			// Instances that have been migrated away from, or that failed
+			// to migrate to, this node are removed silently.
+			skipDeleteEvent := id.migrated || id.cfg.IncomingURI != ""
+			killMe(id.instance, skipDeleteEvent, true, id.doneCh, id.ac, &id.instanceWg)
 			id.shuttingDown = true
 		case <-id.connectedCh:
 			id.logStartTrace()
 			id.connectedCh = nil
 			id.vm.connected()
+			if id.cfg.IncomingURI != "" {
+				id.incomingMigrationComplete()
+			}
 			id.ovsCh <- &ovsStateChange{id.instance, ovsRunning}
-			d, m, c := id.vm.stats()
-			id.ovsCh <- &ovsStatsUpdateCmd{id.instance, m, d, c, id.getVolumes()}
+			id.sendStats()
 			id.statsTimer = time.After(time.Second * resourcePeriod)
 		}
 	}
@@ -399,18 +565,14 @@ func startInstanceWithVM(instance string, cfg *vmConfig, wg *sync.WaitGroup, don
 func startInstance(instance string, cfg *vmConfig, wg *sync.WaitGroup, doneCh chan struct{},
 	ac *agentClient, ovsCh chan<- interface{}) chan<- interface{} {
 
-	storageDriver := storage.CephDriver{
-		ID: cephID,
-	}
-
 	var vm virtualizer
 	if simulate == true {
 		vm = &simulation{}
 	} else if cfg.Container {
-		vm = &docker{storageDriver: storageDriver}
+		vm = &docker{storageDriver: blockDriver}
 	} else {
-		vm = &qemuV{}
+		vm = &qemuV{storageDriver: blockDriver}
 	}
-	return startInstanceWithVM(instance, cfg, wg, doneCh, ac, ovsCh, vm, storageDriver,
+	return startInstanceWithVM(instance, cfg, wg, doneCh, ac, ovsCh, vm, blockDriver,
 		instancesDir)
 }
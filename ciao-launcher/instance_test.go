@@ -56,6 +56,7 @@ type instanceTestState struct {
 	t               *testing.T
 	instance        string
 	statsArray      [3]int
+	consolePort     int
 	stf             payloads.ErrorStartFailure
 	df              payloads.ErrorDeleteFailure
 	avf             payloads.ErrorAttachVolumeFailure
@@ -91,7 +92,7 @@ func (v *instanceTestState) deleteImage() error {
 	return nil
 }
 
-func (v *instanceTestState) startVM(vnicName, ipAddress, cephID string) error {
+func (v *instanceTestState) startVM(vnicName string, extraVnicNames []string, ipAddress, cephID string) error {
 	if v.failStartVM {
 		return fmt.Errorf("Failed to start VM")
 	}
@@ -118,6 +119,13 @@ func (v *instanceTestState) stats() (disk, memory, cpu int) {
 	return v.statsArray[0], v.statsArray[1], v.statsArray[2]
 }
 
+func (v *instanceTestState) console() (string, int) {
+	if v.consolePort == 0 {
+		return "", 0
+	}
+	return payloads.SerialConsole, v.consolePort
+}
+
 func (v *instanceTestState) connected() {
 
 }
@@ -478,6 +486,37 @@ func TestStartInstanceLoop(t *testing.T) {
 	}
 }
 
+// Checks that the console of an instance is reported to the overseer
+//
+// We start an instance loop whose virtualizer exposes a serial console and
+// wait for the first stats update.
+//
+// The stats update should contain the console type and port.
+func TestInstanceConsole(t *testing.T) {
+	var wg sync.WaitGroup
+	doneCh := make(chan struct{})
+	ovsCh := make(chan interface{})
+	state := &instanceTestState{
+		t:           t,
+		instance:    "testInstance",
+		statsArray:  [3]int{10, 128, 10},
+		consolePort: 5909,
+	}
+	cfg := &vmConfig{}
+	cmdWrapCh := make(chan *cmdWrapper)
+	ac := &agentClient{conn: state, cmdCh: cmdWrapCh}
+	_ = startInstanceWithVM(state.instance, cfg, &wg, doneCh, ac, ovsCh, state, &storage.NoopDriver{}, testInstancesDir)
+	stats := state.getStatsUpdate(t, ovsCh)
+	shutdownInstanceLoop(doneCh, ovsCh, &wg, t)
+	if stats == nil {
+		t.FailNow()
+	}
+
+	if stats.consoleType != payloads.SerialConsole || stats.consolePort != 5909 {
+		t.Errorf("Unexpected console %s:%d", stats.consoleType, stats.consolePort)
+	}
+}
+
 // Checks an instance loop can be deleted before an instance is launched.
 //
 // We start the instance loop and then delete the instance straight away.
@@ -829,7 +868,7 @@ func TestAttachVolumeToInstance(t *testing.T) {
 	state, ovsCh, cmdCh, doneCh := startVMWithCFG(t, &wg, &cfg, true, false)
 
 	select {
-	case cmdCh <- &insAttachVolumeCmd{testutil.VolumeUUID}:
+	case cmdCh <- &insAttachVolumeCmd{testutil.VolumeUUID, ""}:
 	case <-time.After(time.Second):
 		t.Error("Timed out sending attach volume command")
 	}
@@ -864,7 +903,7 @@ func TestAttachExistingVolumeToInstance(t *testing.T) {
 	state, ovsCh, cmdCh, doneCh := startVMWithCFG(t, &wg, &cfg, true, false)
 
 	select {
-	case cmdCh <- &insAttachVolumeCmd{testutil.VolumeUUID}:
+	case cmdCh <- &insAttachVolumeCmd{testutil.VolumeUUID, ""}:
 	case <-time.After(time.Second):
 		t.Error("Timed out sending attach volume command")
 	}
@@ -881,7 +920,7 @@ func TestAttachExistingVolumeToInstance(t *testing.T) {
 	select {
 	case <-state.errorCh:
 		t.Error("Initial Volume attach failed")
-	case cmdCh <- &insAttachVolumeCmd{testutil.VolumeUUID}:
+	case cmdCh <- &insAttachVolumeCmd{testutil.VolumeUUID, ""}:
 	case <-time.After(time.Second):
 		t.Error("Timed out sending attach volume command")
 	}
@@ -30,6 +30,7 @@ import (
 	"syscall"
 	"time"
 
+	storage "github.com/ciao-project/ciao/ciao-storage"
 	"github.com/ciao-project/ciao/clogger/gloginterface"
 	"github.com/ciao-project/ciao/networking/libsnnet"
 	"github.com/ciao-project/ciao/osprepare"
@@ -83,6 +84,8 @@ var hardReset bool
 var diskLimit bool
 var memLimit bool
 var cephID string
+var blockDriver storage.BlockDriver
+var volumeTypes storage.VolumeTypes
 var simulate bool
 var maxInstances = int(math.MaxInt32)
 
@@ -102,6 +105,7 @@ const (
 	dataDir         = ciaoDir + "/data/launcher/"
 	logDir          = ciaoDir + "/logs/launcher"
 	maintenanceFile = dataDir + "/maintenance"
+	labelsFile      = dataDir + "/labels"
 	networkFile     = dataDir + "/network"
 	instanceState   = "state"
 	lockFile        = "client-agent.lock"
@@ -171,7 +175,7 @@ func processCommand(conn serverConn, cmd *cmdWrapper, ovsCh chan<- interface{})
 		return
 	}
 
-	switch cmd.cmd.(type) {
+	switch nodeCmd := cmd.cmd.(type) {
 	case *statusCmd:
 		ovsCh <- &ovsStatsStatusCmd{}
 		return
@@ -181,6 +185,12 @@ func processCommand(conn serverConn, cmd *cmdWrapper, ovsCh chan<- interface{})
 		<-doneCh
 		var wg sync.WaitGroup
 		for _, i := range getAllInstances(ovsCh) {
+			// Running VMs are left alone so that controller can
+			// live migrate them to other nodes.
+			if i.migratable && i.running == ovsRunning {
+				glog.Infof("Leaving %s running for migration", i.instance)
+				continue
+			}
 			wg.Add(1)
 			go func(i ovsInstance) {
 				i.cmdCh <- &insDeleteCmd{
@@ -200,6 +210,23 @@ func processCommand(conn serverConn, cmd *cmdWrapper, ovsCh chan<- interface{})
 		ovsCh <- &ovsRestoreCmd{doneCh}
 		<-doneCh
 		glog.Info("Node restored")
+	case *labelNodeCmd:
+		doneCh := make(chan struct{})
+		ovsCh <- &ovsLabelsCmd{nodeCmd.labels, doneCh}
+		<-doneCh
+		// Let the scheduler know about the new labels straight away
+		ovsCh <- &ovsStatsStatusCmd{}
+		glog.Info("Node labels updated")
+	case *networkInspectCmd:
+		inspectNetwork(conn, nodeCmd.repair)
+	case *tenantRoutesCmd:
+		if networking {
+			setTenantRouter(conn, nodeCmd.tenant, nodeCmd.routers)
+		}
+	case *securityGroupsCmd:
+		if networking {
+			setSecurityGroups(nodeCmd)
+		}
 	}
 }
 
@@ -237,6 +264,14 @@ func processInstanceCommand(conn serverConn, cmd *cmdWrapper, ovsCh chan<- inter
 		}
 		delCmd = insCmd
 		delCmd.running = insState.running
+	case *insMigrateCmd:
+		target = insCmdChannel(cmd.instance, ovsCh)
+		if target == nil {
+			glog.Errorf("Instance %s does not exist", cmd.instance)
+			me := migrateError{nil, payloads.MigrateNoInstance}
+			me.send(conn, cmd.instance, insCmd.destination)
+			return
+		}
 	default:
 		target = insCmdChannel(cmd.instance, ovsCh)
 	}
@@ -284,12 +319,21 @@ func loadClusterConfig(conn serverConn) error {
 	}
 	netConfig.ComputeNet = clusterConfig.Configure.Launcher.ComputeNetwork
 	netConfig.MgmtNet = clusterConfig.Configure.Launcher.ManagementNetwork
+	netConfig.TunnelType = clusterConfig.Configure.Launcher.TunnelType
 	diskLimit = clusterConfig.Configure.Launcher.DiskLimit
 	memLimit = clusterConfig.Configure.Launcher.MemoryLimit
 	if cephID == "" {
 		cephID = clusterConfig.Configure.Storage.CephID
 	}
 
+	storageConfig := clusterConfig.Configure.Storage
+	storageConfig.CephID = cephID
+	volumeTypes, err = storage.NewVolumeTypes(storageConfig)
+	if err != nil {
+		return err
+	}
+	blockDriver = volumeTypes[""]
+
 	if err := netConfig.Save(); err != nil {
 		glog.Warningf("Unable to save networking config: %v", err)
 	}
@@ -302,9 +346,16 @@ func printClusterConfig() {
 	glog.Info("-----------------------")
 	glog.Infof("Compute Network:      %v", netConfig.ComputeNet)
 	glog.Infof("Management Network:   %v", netConfig.MgmtNet)
+	glog.Infof("Tunnel Type:          %v", netConfig.TunnelType)
 	glog.Infof("Disk Limit:           %v", diskLimit)
 	glog.Infof("Memory Limit:         %v", memLimit)
 	glog.Infof("Ceph ID:              %v", cephID)
+	glog.Infof("Block Driver:         %T", blockDriver)
+	for name, driver := range volumeTypes {
+		if name != "" {
+			glog.Infof("Volume Type:          %s %T", name, driver)
+		}
+	}
 }
 
 func connectToServer(doneCh chan struct{}, statusCh chan struct{}) {
@@ -366,6 +417,16 @@ func connectToServer(doneCh chan struct{}, statusCh chan struct{}) {
 		}
 		defer shutdownNetwork()
 
+		if launchWithUI.Enabled() {
+			consoles, err = startConsoleProxy(getMgmtIPAddress())
+			if err != nil {
+				glog.Errorf("Failed to start console proxy: %v\n", err)
+				client.conn.Close()
+				return
+			}
+			defer consoles.shutdown()
+		}
+
 		ovsCh = startOverseer(&wg, client)
 	case <-doneCh:
 		client.conn.Close()
@@ -0,0 +1,86 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp"
+	"github.com/golang/glog"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// requestMigration is called on the destination node of a live migration
+// once the incoming instance has been launched.  It asks the node currently
+// hosting the instance to start streaming the instance to us.
+func requestMigration(conn serverConn, cfg *vmConfig) error {
+	if cfg.IncomingURI == "" {
+		return fmt.Errorf("Instance %s is not waiting for an incoming migration",
+			cfg.Instance)
+	}
+
+	var cmd payloads.Migrate
+	cmd.Migrate.InstanceUUID = cfg.Instance
+	cmd.Migrate.WorkloadAgentUUID = cfg.MigrateFrom
+	cmd.Migrate.DestinationAgentUUID = conn.UUID()
+	cmd.Migrate.DestinationURI = cfg.IncomingURI
+
+	payload, err := yaml.Marshal(&cmd)
+	if err != nil {
+		return fmt.Errorf("Unable to marshall MIGRATE command: %v", err)
+	}
+
+	_, err = conn.SendCommand(ssntp.MIGRATE, payload)
+	if err != nil {
+		return fmt.Errorf("Unable to send MIGRATE command: %v", err)
+	}
+
+	glog.Infof("Requested migration of %s from %s to %s", cfg.Instance,
+		cfg.MigrateFrom, cfg.IncomingURI)
+
+	return nil
+}
+
+// processMigrate is called on the source node of a live migration.  It asks
+// the monitor go routine of the instance to start the migration and returns
+// a channel on which the result of the migration will be delivered.
+func processMigrate(monitorCh chan interface{}, cfg *vmConfig, instance,
+	uri string) (chan error, *migrateError) {
+
+	if !cfg.canMigrate() {
+		migrateErr := &migrateError{nil, payloads.MigrateNotSupported}
+		glog.Errorf("Cannot migrate instance %s [%s]", instance,
+			string(migrateErr.code))
+		return nil, migrateErr
+	}
+
+	if monitorCh == nil {
+		migrateErr := &migrateError{nil, payloads.MigrateNotRunning}
+		glog.Errorf("Cannot migrate instance %s [%s]", instance,
+			string(migrateErr.code))
+		return nil, migrateErr
+	}
+
+	responseCh := make(chan error, 1)
+	monitorCh <- virtualizerMigrateCmd{
+		responseCh: responseCh,
+		uri:        uri,
+	}
+
+	return responseCh, nil
+}
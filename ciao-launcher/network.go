@@ -41,6 +41,7 @@ var dockerNet *libsnnet.DockerPlugin
 type networkConfig struct {
 	ComputeNet []string
 	MgmtNet    []string
+	TunnelType payloads.TunnelType
 }
 
 func (nc *networkConfig) Save() error {
@@ -83,10 +84,20 @@ func initNetworkPhase1() error {
 		mnetList[i] = *mnet
 	}
 
+	var mode libsnnet.NetworkMode
+	switch netConfig.TunnelType {
+	case "", payloads.GRETunnel:
+		mode = libsnnet.GreTunnel
+	case payloads.VXLANTunnel:
+		mode = libsnnet.VxlanTunnel
+	default:
+		return fmt.Errorf("Unsupported tunnel type %s", netConfig.TunnelType)
+	}
+
 	cn.NetworkConfig = &libsnnet.NetworkConfig{
 		ManagementNet: mnetList,
 		ComputeNet:    cnetList,
-		Mode:          libsnnet.GreTunnel,
+		Mode:          mode,
 	}
 
 	libsnnet.CnMaxAPIConcurrency = 1
@@ -192,6 +203,18 @@ func initNetworking(ctx context.Context) chan error {
 	return ch
 }
 
+// tunnelKey returns the key of the tunnels of a subnet, the key allocated
+// to the subnet by the controller.  Instances started before the
+// controller allocated subnet keys use a key derived from the address of
+// their subnet, as they did then.
+func tunnelKey(subnetKey int, subnet *net.IPNet) int {
+	if subnetKey != 0 {
+		return subnetKey
+	}
+
+	return int(binary.LittleEndian.Uint32(subnet.IP))
+}
+
 func createCNVnicCfg(cfg *vmConfig) (*libsnnet.VnicConfig, error) {
 
 	glog.Info("Creating CN Vnic CFG")
@@ -216,7 +239,6 @@ func createCNVnicCfg(cfg *vmConfig) (*libsnnet.VnicConfig, error) {
 		return nil, fmt.Errorf("Invalid vnicIP ip %s", cfg.VnicIP)
 	}
 
-	subnetKey := binary.LittleEndian.Uint32(vnet.IP)
 	var role libsnnet.VnicRole
 	if cfg.Container {
 		role = libsnnet.TenantContainer
@@ -230,12 +252,16 @@ func createCNVnicCfg(cfg *vmConfig) (*libsnnet.VnicConfig, error) {
 		ConcIP:     concIP,
 		VnicMAC:    mac,
 		Subnet:     *vnet,
-		SubnetKey:  int(subnetKey),
+		SubnetKey:  tunnelKey(cfg.SubnetKey, vnet),
 		VnicID:     cfg.VnicUUID,
 		InstanceID: cfg.Instance,
 		TenantID:   cfg.TenantUUID,
 		SubnetID:   cfg.SubnetIP,
-		ConcID:     cfg.ConcUUID}, nil
+		ConcID:     cfg.ConcUUID,
+		Bandwidth: libsnnet.BandwidthLimits{
+			IngressKbps: cfg.IngressKbps,
+			EgressKbps:  cfg.EgressKbps,
+		}}, nil
 }
 
 func createCNCIVnicCfg(cfg *vmConfig) (*libsnnet.VnicConfig, error) {
@@ -255,6 +281,31 @@ func createCNCIVnicCfg(cfg *vmConfig) (*libsnnet.VnicConfig, error) {
 		TenantID:   cfg.TenantUUID}, nil
 }
 
+// createExtraVnicCfgs returns the configurations of the additional VNICs of
+// a VM, in the order in which they are to be presented to the guest.
+func createExtraVnicCfgs(cfg *vmConfig) ([]*libsnnet.VnicConfig, error) {
+	vnicCfgs := make([]*libsnnet.VnicConfig, 0, len(cfg.ExtraVnics))
+
+	for _, extra := range cfg.ExtraVnics {
+		extraCfg := *cfg
+		extraCfg.VnicMAC = extra.VnicMAC
+		extraCfg.VnicIP = extra.VnicIP
+		extraCfg.ConcIP = extra.ConcIP
+		extraCfg.SubnetIP = extra.SubnetIP
+		extraCfg.SubnetKey = extra.SubnetKey
+		extraCfg.ConcUUID = extra.ConcUUID
+		extraCfg.VnicUUID = extra.VnicUUID
+
+		vnicCfg, err := createCNVnicCfg(&extraCfg)
+		if err != nil {
+			return nil, err
+		}
+		vnicCfgs = append(vnicCfgs, vnicCfg)
+	}
+
+	return vnicCfgs, nil
+}
+
 func createVnicCfg(cfg *vmConfig) (*libsnnet.VnicConfig, error) {
 	if cfg.NetworkNode {
 		return createCNCIVnicCfg(cfg)
@@ -282,6 +333,33 @@ func sendNetworkEvent(conn serverConn, eventType ssntp.Event,
 	}
 }
 
+// inspectNetwork compares the network topology of the node to the one
+// recorded by libsnnet, optionally repairing it, and sends the result to
+// the controller in a NetworkReport event.
+func inspectNetwork(conn serverConn, repair bool) {
+	report, err := cnNet.InspectTopology(repair)
+	if err != nil {
+		glog.Warningf("Network inspection failed: %v", err)
+	} else {
+		glog.Infof("Network inspection found %d problems", len(report.Problems))
+	}
+
+	if !conn.isConnected() {
+		return
+	}
+
+	payload, err := generateNetworkReportPayload(report, conn.UUID(), repair, err)
+	if err != nil {
+		glog.Warningf("Unable to generate NetworkReport: %v", err)
+		return
+	}
+
+	_, err = conn.SendEvent(ssntp.NetworkReport, payload)
+	if err != nil {
+		glog.Warningf("Unable to send NetworkReport: %v", err)
+	}
+}
+
 func createVnic(conn serverConn, vnicCfg *libsnnet.VnicConfig) (string, string, string, error) {
 	var name string
 	var bridge string
@@ -358,6 +436,112 @@ func destroyVnic(conn serverConn, vnicCfg *libsnnet.VnicConfig) error {
 	return nil
 }
 
+func createRouterSubnets(routers []routerSubnetConfig) ([]libsnnet.RouterSubnet, error) {
+	var subnets []libsnnet.RouterSubnet
+
+	for _, r := range routers {
+		_, vnet, err := net.ParseCIDR(r.SubnetIP)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid router subnet %v", err)
+		}
+
+		concIP := net.ParseIP(r.ConcIP)
+		if concIP == nil {
+			return nil, fmt.Errorf("Invalid concentrator ip %s", r.ConcIP)
+		}
+
+		// The subnet ID and key must match the ones of the VNICs
+		// created by createCNVnicCfg
+		subnets = append(subnets, libsnnet.RouterSubnet{
+			Subnet:    *vnet,
+			SubnetKey: tunnelKey(r.SubnetKey, vnet),
+			SubnetID:  r.SubnetIP,
+			ConcID:    r.ConcUUID,
+			ConcIP:    concIP,
+		})
+	}
+
+	return subnets, nil
+}
+
+func sendRouterEvents(conn serverConn, events []*libsnnet.SsntpEventInfo) {
+	for _, event := range events {
+		if event.Event == libsnnet.SsntpTunAdd {
+			sendNetworkEvent(conn, ssntp.TenantAdded, event)
+		} else {
+			sendNetworkEvent(conn, ssntp.TenantRemoved, event)
+		}
+	}
+}
+
+// setTenantRouter connects the distributed router of a tenant on the node
+// to the subnets of the tenant.  The router is only an optimisation, the
+// instances are still reachable through the CNCIs when it fails, so errors
+// are only logged.  The router only routes directly between the instances
+// of the node, the rest of each subnet is reached through its CNCI.
+func setTenantRouter(conn serverConn, tenant string, routers []routerSubnetConfig) {
+	subnets, err := createRouterSubnets(routers)
+	if err != nil {
+		glog.Errorf("Unable to set router of tenant %s: %v", tenant, err)
+		return
+	}
+
+	events, err := cnNet.SetTenantRouter(tenant, subnets)
+	sendRouterEvents(conn, events)
+	if err != nil {
+		glog.Errorf("cn.SetTenantRouter failed %v", err)
+		return
+	}
+
+	glog.Infof("Router of tenant %s connected to %d subnets", tenant, len(subnets))
+}
+
+// releaseTenantRouter destroys the distributed router of a tenant once the
+// tenant has no instances left on the node.
+func releaseTenantRouter(conn serverConn, tenant string) {
+	events, err := cnNet.ReleaseTenantRouter(tenant)
+	sendRouterEvents(conn, events)
+	if err != nil {
+		glog.Errorf("cn.ReleaseTenantRouter failed %v", err)
+	}
+}
+
+// secGroupGenerations holds the generation of the last security group
+// update of each tenant.  It is only accessed by the main loop.
+var secGroupGenerations = make(map[string]int64)
+
+// setSecurityGroups enforces the security group rules of the instances of a
+// tenant on the bridge ports of the VNICs of the instances running on the
+// node.  SSNTP commands may be received out of order, updates older than the
+// last one applied are dropped.
+func setSecurityGroups(cmd *securityGroupsCmd) {
+	if cmd.generation < secGroupGenerations[cmd.tenant] {
+		glog.Infof("Dropping stale security groups %d of tenant %s",
+			cmd.generation, cmd.tenant)
+		return
+	}
+	secGroupGenerations[cmd.tenant] = cmd.generation
+
+	err := cnNet.SetSecurityGroups(cmd.tenant, cmd.instances)
+	if err != nil {
+		glog.Errorf("cn.SetSecurityGroups failed %v", err)
+		return
+	}
+
+	glog.Infof("Security groups of tenant %s updated", cmd.tenant)
+}
+
+// refreshSecurityGroups enforces the security group rules on the VNICs of
+// the node once one of them is created or destroyed.
+func refreshSecurityGroups() error {
+	err := cnNet.RefreshSecurityGroups()
+	if err != nil {
+		return fmt.Errorf("Unable to enforce security groups: %v", err)
+	}
+
+	return nil
+}
+
 func getNodeIPAddress() string {
 	if len(nicInfo) == 0 {
 		return "127.0.0.1"
@@ -365,3 +549,13 @@ func getNodeIPAddress() string {
 
 	return nicInfo[0].NodeIP
 }
+
+// getMgmtIPAddress returns the IP address of the node on the management
+// network, on which ciao-controller reaches the node.
+func getMgmtIPAddress() string {
+	if cnNet == nil || len(cnNet.MgtAddr) == 0 {
+		return getNodeIPAddress()
+	}
+
+	return cnNet.MgtAddr[0].IP.String()
+}
@@ -19,6 +19,7 @@ package main
 import (
 	"container/list"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
@@ -54,9 +55,10 @@ type ovsGetCmd struct {
 }
 
 type ovsInstance struct {
-	instance string
-	running  ovsRunningState
-	cmdCh    chan<- interface{}
+	instance   string
+	running    ovsRunningState
+	cmdCh      chan<- interface{}
+	migratable bool
 }
 
 type ovsGetAllResult struct {
@@ -83,6 +85,8 @@ type ovsStatsUpdateCmd struct {
 	diskUsageMB   int
 	CPUUsage      int
 	volumes       []string
+	consoleType   string
+	consolePort   int
 }
 
 type ovsMaintenanceCmd struct {
@@ -93,6 +97,11 @@ type ovsRestoreCmd struct {
 	doneCh chan struct{}
 }
 
+type ovsLabelsCmd struct {
+	labels map[string]string
+	doneCh chan struct{}
+}
+
 type ovsTraceFrame struct {
 	frame *ssntp.Frame
 }
@@ -151,7 +160,11 @@ type ovsInstanceState struct {
 	maxMemoryMB    int
 	sshIP          string
 	sshPort        int
+	consoleType    string
+	consolePort    int
+	consoleToken   string
 	volumes        []string
+	migratable     bool
 }
 
 type overseer struct {
@@ -172,6 +185,7 @@ type overseer struct {
 	statsInterval      time.Duration
 	di                 deviceInfo
 	maintenance        bool
+	labels             map[string]string
 }
 
 type cnStats struct {
@@ -275,6 +289,8 @@ func (ovs *overseer) sendReadyStatusCommand(cns *cnStats) {
 	s.MemTotalMB, s.MemAvailableMB = cns.totalMemMB, cns.availableMemMB
 	s.Load = cns.load
 	s.CpusOnline = cns.cpusOnline
+	s.VCPUsAllocated, s.MemAllocatedMB = ovs.vcpusAllocated, ovs.memoryAllocated
+	s.Labels = ovs.labels
 	s.DiskTotalMB, s.DiskAvailableMB = cns.totalDiskMB, cns.availableDiskMB
 	s.Networks = make([]payloads.NetworkStat, len(nicInfo))
 	for i, nic := range nicInfo {
@@ -323,6 +339,7 @@ func (ovs *overseer) sendStats(cns *cnStats, status ssntp.Status) {
 	s.CpusOnline = cns.cpusOnline
 	s.DiskTotalMB, s.DiskAvailableMB = cns.totalDiskMB, cns.availableDiskMB
 	s.NodeHostName = hostname // global from network.go
+	s.Labels = ovs.labels
 	s.Networks = make([]payloads.NetworkStat, len(nicInfo))
 	for i, nic := range nicInfo {
 		s.Networks[i] = *nic
@@ -343,6 +360,11 @@ func (ovs *overseer) sendStats(cns *cnStats, status ssntp.Status) {
 		s.Instances[i].CPUUsage = state.CPUUsage
 		s.Instances[i].SSHIP = state.sshIP
 		s.Instances[i].SSHPort = state.sshPort
+		if state.consoleToken != "" {
+			s.Instances[i].ConsoleType = state.consoleType
+			s.Instances[i].ConsoleIP, s.Instances[i].ConsolePort = consoles.address()
+			s.Instances[i].ConsoleToken = state.consoleToken
+		}
 		s.Instances[i].Volumes = state.volumes
 		i++
 	}
@@ -422,9 +444,10 @@ func (ovs *overseer) processGetAllCommand(cmd *ovsGetAllCmd) {
 		glog.Infof("Overseer: Found %s", k)
 		res.instances = append(res.instances,
 			ovsInstance{
-				instance: k,
-				running:  v.running,
-				cmdCh:    v.cmdCh,
+				instance:   k,
+				running:    v.running,
+				cmdCh:      v.cmdCh,
+				migratable: v.migratable,
 			})
 	}
 	cmd.targetCh <- res
@@ -457,6 +480,7 @@ func (ovs *overseer) processAddCommand(cmd *ovsAddCmd) {
 			maxMemoryMB:    cfg.Mem,
 			sshIP:          cfg.ConcIP,
 			sshPort:        cfg.SSHPort,
+			migratable:     cfg.canMigrate(),
 		}
 	}
 	cmd.targetCh <- ovsAddResult{targetCh, errCode}
@@ -485,6 +509,10 @@ func (ovs *overseer) processRemoveCommand(cmd *ovsRemoveCmd) {
 		ovs.memoryAllocated = 0
 	}
 
+	if consoles != nil {
+		consoles.unregister(cmd.instance)
+	}
+
 	delete(ovs.instances, cmd.instance)
 	cmd.errCh <- nil
 }
@@ -531,7 +559,33 @@ func (ovs *overseer) processStatusUpdateCommand(cmd *ovsStatsUpdateCmd) {
 		target.diskUsageMB = cmd.diskUsageMB
 		target.CPUUsage = cmd.CPUUsage
 		target.volumes = cmd.volumes
+		target.consoleType = cmd.consoleType
+		target.consolePort = cmd.consolePort
+		ovs.updateConsoleToken(cmd.instance, target)
+	}
+}
+
+// updateConsoleToken registers the console of an instance with the console
+// proxy, which is the only way to reach it.
+func (ovs *overseer) updateConsoleToken(instance string, target *ovsInstanceState) {
+	if consoles == nil {
+		return
+	}
+
+	if target.consolePort == 0 {
+		consoles.unregister(instance)
+		target.consoleToken = ""
+		return
+	}
+
+	token, err := consoles.register(instance, target.consolePort)
+	if err != nil {
+		glog.Warningf("Unable to register console of %s: %v", instance, err)
+		target.consoleToken = ""
+		return
 	}
+
+	target.consoleToken = token
 }
 
 func (ovs *overseer) processTraceFrameCommand(cmd *ovsTraceFrame) {
@@ -571,6 +625,46 @@ func (ovs *overseer) processRestoreCommand(cmd *ovsRestoreCmd) {
 	}
 }
 
+func (ovs *overseer) processLabelsCommand(cmd *ovsLabelsCmd) {
+	defer close(cmd.doneCh)
+	glog.Infof("Setting node labels: %v", cmd.labels)
+	ovs.labels = cmd.labels
+	if err := saveNodeLabels(labelsFile, cmd.labels); err != nil {
+		glog.Errorf("Unable to save node labels to %s : %v",
+			labelsFile, err)
+	}
+}
+
+func saveNodeLabels(path string, labels map[string]string) error {
+	if len(labels) == 0 {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	data, err := yaml.Marshal(labels)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func loadNodeLabels(path string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var labels map[string]string
+	err = yaml.Unmarshal(data, &labels)
+	return labels, err
+}
+
 func (ovs *overseer) processCommand(cmd interface{}) {
 	switch cmd := cmd.(type) {
 	case *ovsGetCmd:
@@ -595,6 +689,8 @@ func (ovs *overseer) processCommand(cmd interface{}) {
 		ovs.processMaintenanceCommand(cmd)
 	case *ovsRestoreCmd:
 		ovs.processRestoreCommand(cmd)
+	case *ovsLabelsCmd:
+		ovs.processLabelsCommand(cmd)
 	default:
 		panic("Unknown Overseer Command")
 	}
@@ -715,6 +811,7 @@ func startOverseerFull(instancesDir string, wg *sync.WaitGroup, ac *agentClient,
 			maxMemoryMB:    cfg.Mem,
 			sshIP:          cfg.ConcIP,
 			sshPort:        cfg.SSHPort,
+			migratable:     cfg.canMigrate(),
 		}
 		toMonitor = append(toMonitor, target)
 
@@ -728,6 +825,11 @@ func startOverseerFull(instancesDir string, wg *sync.WaitGroup, ac *agentClient,
 		glog.Info("Node is in MAINTENANCE mode")
 	}
 
+	labels, err := loadNodeLabels(labelsFile)
+	if err != nil {
+		glog.Warningf("Unable to load node labels from %s : %v", labelsFile, err)
+	}
+
 	ovs := &overseer{
 		instancesDir:       instancesDir,
 		instances:          instances,
@@ -744,6 +846,7 @@ func startOverseerFull(instancesDir string, wg *sync.WaitGroup, ac *agentClient,
 		statsInterval:      statsInterval,
 		di:                 di,
 		maintenance:        maintenance,
+		labels:             labels,
 	}
 	ovs.parentWg.Add(1)
 	glog.Info("Starting Overseer")
@@ -21,6 +21,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -29,6 +30,7 @@ import (
 
 	"github.com/ciao-project/ciao/payloads"
 	"github.com/ciao-project/ciao/ssntp"
+	"github.com/ciao-project/ciao/testutil"
 )
 
 type fakeStatus struct {
@@ -573,6 +575,94 @@ func TestMaintenanceStatus(t *testing.T) {
 	wg.Wait()
 }
 
+// Check the overseer reports the node labels it is given
+//
+// Start the overseer, send an ovsLabelsCmd followed by an ovsStatusCmd.  Then
+// clear the labels and shutdown the overseer.
+//
+// The READY status command should contain the labels.  The overseer should
+// shut down cleanly.
+func TestNodeLabels(t *testing.T) {
+	instancesDir, err := ioutil.TempDir("", "overseer-tests")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory")
+	}
+	defer func() { _ = os.RemoveAll(instancesDir) }()
+
+	var wg sync.WaitGroup
+	state := &overseerTestState{
+		t:        t,
+		statusCh: make(chan *fakeStatus),
+	}
+	state.ac = &agentClient{conn: state, cmdCh: make(chan *cmdWrapper)}
+
+	ovsCh := startOverseerFull(instancesDir, &wg, state.ac, time.Second*1000,
+		fakeDeviceInfo{})
+
+	for _, labels := range []map[string]string{testutil.NodeLabels, nil} {
+		doneCh := make(chan struct{})
+		select {
+		case ovsCh <- &ovsLabelsCmd{labels, doneCh}:
+		case <-time.After(time.Second):
+			t.Fatal("Unable to send ovsLabelsCmd")
+		}
+
+		select {
+		case <-doneCh:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for ovsLabelsCmd to complete")
+		}
+
+		select {
+		case ovsCh <- &ovsStatusCmd{}:
+		case <-time.After(time.Second):
+			t.Fatal("Unable to send ovsStatusCmd")
+		}
+
+		var status *fakeStatus
+		select {
+		case status = <-state.statusCh:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for status update from overseer")
+		}
+
+		if len(status.ready.Labels) != len(labels) ||
+			(labels != nil && !reflect.DeepEqual(status.ready.Labels, labels)) {
+			t.Errorf("Unexpected labels in READY: %v", status.ready.Labels)
+		}
+	}
+
+	shutdownOverseer(ovsCh, state)
+	wg.Wait()
+}
+
+func TestSaveLoadNodeLabels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "overseer-tests")
+	if err != nil {
+		t.Fatalf("Unable to create temporary directory")
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	labelsPath := path.Join(dir, "labels")
+	if err := saveNodeLabels(labelsPath, testutil.NodeLabels); err != nil {
+		t.Fatalf("Unable to save labels: %v", err)
+	}
+
+	labels, err := loadNodeLabels(labelsPath)
+	if err != nil || !reflect.DeepEqual(labels, testutil.NodeLabels) {
+		t.Fatalf("Unexpected labels loaded %v: %v", labels, err)
+	}
+
+	if err := saveNodeLabels(labelsPath, nil); err != nil {
+		t.Fatalf("Unable to clear labels: %v", err)
+	}
+
+	labels, err = loadNodeLabels(labelsPath)
+	if err != nil || labels != nil {
+		t.Fatalf("Unexpected labels loaded %v: %v", labels, err)
+	}
+}
+
 // Check we can add and delete an instance
 //
 // Start the overseer, send and ovsAddCmd, check the instance is reflected
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"net"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/ciao-project/ciao/networking/libsnnet"
@@ -67,7 +68,12 @@ func printCloudinit(data *payloads.Start) {
 	glog.Infof("SubnetIP:             %v", net.Subnet)
 	glog.Infof("ConcUUID:             %v", net.ConcentratorUUID)
 	glog.Infof("VnicUUID:             %v", net.VnicUUID)
+	for _, extra := range start.ExtraNetworking {
+		glog.Infof("Extra VNIC:           %v %v %v", extra.VnicUUID,
+			extra.VnicMAC, extra.PrivateIP)
+	}
 	glog.Infof("Restart:              %t", start.Restart)
+	glog.Infof("MigrateFrom:          %v", start.MigrateFrom)
 
 	glog.Info("Requested resources:")
 	for i := range start.RequestedResources {
@@ -139,7 +145,7 @@ func parseStartPayload(data []byte) (*vmConfig, *payloadError) {
 	}
 	legacy := fwType == payloads.Legacy
 
-	var cpus, mem int
+	var cpus, mem, ingressKbps, egressKbps int
 	var networkNode bool
 	container, err := parseVMTtype(start)
 	if err != nil {
@@ -154,18 +160,65 @@ func parseStartPayload(data []byte) (*vmConfig, *payloadError) {
 			mem = start.RequestedResources[i].Value
 		case payloads.NetworkNode:
 			networkNode = start.RequestedResources[i].Value != 0
+		case payloads.NetworkIngressKbps:
+			ingressKbps = start.RequestedResources[i].Value
+		case payloads.NetworkEgressKbps:
+			egressKbps = start.RequestedResources[i].Value
 		}
 	}
 
+	if ingressKbps < 0 || egressKbps < 0 {
+		err = fmt.Errorf("Invalid bandwidth limits received: %d %d", ingressKbps, egressKbps)
+		return nil, &payloadError{err, payloads.InvalidData}
+	}
+
+	migrateFrom := strings.TrimSpace(start.MigrateFrom)
+	if migrateFrom != "" && (container || networkNode) {
+		err = fmt.Errorf("Live migration is only supported for compute node VMs")
+		return nil, &payloadError{err, payloads.InvalidData}
+	}
+
+	if len(start.ExtraNetworking) > 0 && (container || networkNode) {
+		err = fmt.Errorf("Multiple VNICs are only supported for compute node VMs")
+		return nil, &payloadError{err, payloads.InvalidData}
+	}
+
+	var extraVnics []vnicConfig
+	for _, extra := range start.ExtraNetworking {
+		subnetKey, err := parseSubnetKey(extra.SubnetKey)
+		if err != nil {
+			return nil, &payloadError{err, payloads.InvalidData}
+		}
+
+		extraVnics = append(extraVnics, vnicConfig{
+			VnicMAC:   strings.TrimSpace(extra.VnicMAC),
+			VnicIP:    strings.TrimSpace(extra.PrivateIP),
+			ConcIP:    strings.TrimSpace(extra.ConcentratorIP),
+			SubnetIP:  strings.TrimSpace(extra.Subnet),
+			SubnetKey: subnetKey,
+			ConcUUID:  strings.TrimSpace(extra.ConcentratorUUID),
+			VnicUUID:  strings.TrimSpace(extra.VnicUUID),
+		})
+	}
+
+	routers := routerSubnets(start.RouterSubnets)
+
 	net := &start.Networking
+	subnetKey, err := parseSubnetKey(net.SubnetKey)
+	if err != nil {
+		return nil, &payloadError{err, payloads.InvalidData}
+	}
+
 	vnicIP := strings.TrimSpace(net.PrivateIP)
 	sshPort := computeSSHPort(networkNode, vnicIP)
 	var volumes []volumeConfig
 	for _, storage := range start.Storage {
 		if storage.ID != "" {
 			volumes = append(volumes, volumeConfig{
-				UUID:     storage.ID,
-				Bootable: storage.Bootable,
+				UUID:       storage.ID,
+				Bootable:   storage.Bootable,
+				VolumeType: strings.TrimSpace(storage.VolumeType),
+				Local:      storage.Local,
 			})
 		} else {
 			/* See github issue #972:
@@ -186,12 +239,18 @@ func parseStartPayload(data []byte) (*vmConfig, *payloadError) {
 		VnicIP:      vnicIP,
 		ConcIP:      strings.TrimSpace(net.ConcentratorIP),
 		SubnetIP:    strings.TrimSpace(net.Subnet),
+		SubnetKey:   subnetKey,
 		TenantUUID:  strings.TrimSpace(start.TenantUUID),
 		ConcUUID:    strings.TrimSpace(net.ConcentratorUUID),
 		VnicUUID:    strings.TrimSpace(net.VnicUUID),
+		ExtraVnics:  extraVnics,
+		Routers:     routers,
+		IngressKbps: ingressKbps,
+		EgressKbps:  egressKbps,
 		SSHPort:     sshPort,
 		Volumes:     volumes,
 		Restart:     clouddata.Start.Restart,
+		MigrateFrom: migrateFrom,
 	}, nil
 }
 
@@ -224,6 +283,26 @@ func generateAttachVolumeError(node, instance, volume string, ave *attachVolumeE
 	return yaml.Marshal(avf)
 }
 
+func generateDetachVolumeError(node, instance, volume string, dve *detachVolumeError) (out []byte, err error) {
+	dvf := &payloads.ErrorDetachVolumeFailure{
+		NodeUUID:     node,
+		InstanceUUID: instance,
+		VolumeUUID:   volume,
+		Reason:       dve.code,
+	}
+	return yaml.Marshal(dvf)
+}
+
+func generateMigrateError(node, instance, destination string, me *migrateError) (out []byte, err error) {
+	mf := &payloads.ErrorMigrateFailure{
+		NodeUUID:             node,
+		InstanceUUID:         instance,
+		DestinationAgentUUID: destination,
+		Reason:               me.code,
+	}
+	return yaml.Marshal(mf)
+}
+
 func generateNetEventPayload(ssntpEvent *libsnnet.SsntpEventInfo, agentUUID string) ([]byte, error) {
 	var event interface{}
 	var eventData *payloads.TenantAddedEvent
@@ -249,6 +328,9 @@ func generateNetEventPayload(ssntpEvent *libsnnet.SsntpEventInfo, agentUUID stri
 	eventData.ConcentratorUUID = ssntpEvent.ConcID
 	eventData.ConcentratorIP = ssntpEvent.CnciIP
 	eventData.SubnetKey = ssntpEvent.SubnetKey
+	if ssntpEvent.Mode == libsnnet.VxlanTunnel {
+		eventData.TunnelType = payloads.VXLANTunnel
+	}
 
 	return yaml.Marshal(event)
 }
@@ -284,16 +366,279 @@ func extractVolumeInfo(cmd *payloads.VolumeCmd, errString string) (string, strin
 	return instance, volume, nil
 }
 
-func parseAttachVolumePayload(data []byte) (string, string, *payloadError) {
+func parseAttachVolumePayload(data []byte) (string, string, string, *payloadError) {
 	var clouddata payloads.AttachVolume
 
 	err := yaml.Unmarshal(data, &clouddata)
 	if err != nil {
 		glog.Errorf("YAML error: %v", err)
-		return "", "", &payloadError{err, payloads.AttachVolumeInvalidPayload}
+		return "", "", "", &payloadError{err, payloads.AttachVolumeInvalidPayload}
+	}
+
+	instance, volume, payloadErr := extractVolumeInfo(&clouddata.Attach, payloads.AttachVolumeInvalidData)
+	if payloadErr != nil {
+		return "", "", "", payloadErr
+	}
+
+	return instance, volume, strings.TrimSpace(clouddata.Attach.VolumeType), nil
+}
+
+func parseDetachVolumePayload(data []byte) (string, string, *payloadError) {
+	var clouddata payloads.DetachVolume
+
+	err := yaml.Unmarshal(data, &clouddata)
+	if err != nil {
+		glog.Errorf("YAML error: %v", err)
+		return "", "", &payloadError{err, payloads.DetachVolumeInvalidPayload}
+	}
+
+	return extractVolumeInfo(&clouddata.Detach, payloads.DetachVolumeInvalidData)
+}
+
+func parseResizeVolumePayload(data []byte) (string, string, int, error) {
+	var clouddata payloads.ResizeVolume
+
+	err := yaml.Unmarshal(data, &clouddata)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	instance := strings.TrimSpace(clouddata.Resize.InstanceUUID)
+	if !uuidRegexp.MatchString(instance) {
+		return "", "", 0, fmt.Errorf("Invalid instance id received: %s", instance)
+	}
+
+	volume := strings.TrimSpace(clouddata.Resize.VolumeUUID)
+	if !uuidRegexp.MatchString(volume) {
+		return "", "", 0, fmt.Errorf("Invalid volume id received: %s", volume)
+	}
+
+	if clouddata.Resize.SizeGiB <= 0 {
+		return "", "", 0, fmt.Errorf("Invalid volume size received: %d",
+			clouddata.Resize.SizeGiB)
+	}
+
+	return instance, volume, clouddata.Resize.SizeGiB, nil
+}
+
+func parseMigratePayload(data []byte) (string, string, string, *payloadError) {
+	var clouddata payloads.Migrate
+
+	err := yaml.Unmarshal(data, &clouddata)
+	if err != nil {
+		glog.Errorf("YAML error: %v", err)
+		return "", "", "", &payloadError{err, payloads.MigrateInvalidPayload}
+	}
+
+	instance := strings.TrimSpace(clouddata.Migrate.InstanceUUID)
+	if !uuidRegexp.MatchString(instance) {
+		err = fmt.Errorf("Invalid instance id received: %s", instance)
+		return "", "", "", &payloadError{err, payloads.MigrateInvalidData}
+	}
+
+	destination := strings.TrimSpace(clouddata.Migrate.DestinationAgentUUID)
+	if !uuidRegexp.MatchString(destination) {
+		err = fmt.Errorf("Invalid destination id received: %s", destination)
+		return "", "", "", &payloadError{err, payloads.MigrateInvalidData}
+	}
+
+	uri := strings.TrimSpace(clouddata.Migrate.DestinationURI)
+	if uri == "" {
+		err = fmt.Errorf("No destination URI received")
+		return "", "", "", &payloadError{err, payloads.MigrateInvalidData}
+	}
+
+	return instance, destination, uri, nil
+}
+
+func parseLabelNodePayload(data []byte) (map[string]string, error) {
+	var clouddata payloads.LabelNode
+
+	err := yaml.Unmarshal(data, &clouddata)
+	if err != nil {
+		return nil, err
+	}
+
+	for key := range clouddata.LabelNode.Labels {
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("Invalid empty label key received")
+		}
+	}
+
+	return clouddata.LabelNode.Labels, nil
+}
+
+func parseNetworkInspectPayload(data []byte) (bool, error) {
+	var clouddata payloads.NetworkInspect
+
+	err := yaml.Unmarshal(data, &clouddata)
+	if err != nil {
+		return false, err
+	}
+
+	return clouddata.Inspect.Repair, nil
+}
+
+// parseSubnetKey parses the key allocated by the controller to the subnet
+// of a VNIC.  Payloads of controllers that did not allocate subnet keys
+// carry an empty key.
+func parseSubnetKey(key string) (int, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return 0, nil
+	}
+
+	k, err := strconv.Atoi(key)
+	if err != nil || k <= 0 {
+		return 0, fmt.Errorf("Invalid subnet key %s", key)
+	}
+
+	return k, nil
+}
+
+func routerSubnets(subnets []payloads.RouterSubnet) []routerSubnetConfig {
+	var routers []routerSubnetConfig
+	for _, s := range subnets {
+		routers = append(routers, routerSubnetConfig{
+			SubnetIP:  strings.TrimSpace(s.Subnet),
+			ConcIP:    strings.TrimSpace(s.ConcentratorIP),
+			ConcUUID:  strings.TrimSpace(s.ConcentratorUUID),
+			SubnetKey: s.SubnetKey,
+		})
+	}
+	return routers
+}
+
+func parseTenantRoutesPayload(data []byte) (string, []routerSubnetConfig, error) {
+	var clouddata payloads.CommandTenantRoutes
+
+	err := yaml.Unmarshal(data, &clouddata)
+	if err != nil {
+		return "", nil, err
+	}
+
+	tenant := strings.TrimSpace(clouddata.TenantRoutes.TenantUUID)
+	if tenant == "" {
+		return "", nil, fmt.Errorf("Missing tenant UUID")
+	}
+
+	return tenant, routerSubnets(clouddata.TenantRoutes.Subnets), nil
+}
+
+// instanceSecurity converts the security rules of an instance.  Rules with
+// an IPv6 remote CIDR apply to its IPv6 address, rules without a remote CIDR
+// to both of its addresses.
+func instanceSecurity(i payloads.InstanceSecurityRules) (libsnnet.InstanceSecurity, error) {
+	var sec libsnnet.InstanceSecurity
+
+	sec.IP = net.ParseIP(strings.TrimSpace(i.PrivateIP))
+	if sec.IP == nil || sec.IP.To4() == nil {
+		return sec, fmt.Errorf("Invalid private IP %s", i.PrivateIP)
+	}
+
+	if i.PrivateIPv6 != "" {
+		sec.IPv6 = net.ParseIP(strings.TrimSpace(i.PrivateIPv6))
+		if sec.IPv6 == nil || sec.IPv6.To4() != nil {
+			return sec, fmt.Errorf("Invalid private IPv6 %s", i.PrivateIPv6)
+		}
+	}
+
+	for _, r := range i.Rules {
+		rule := libsnnet.SecurityRule{
+			InstanceIP: sec.IP,
+			Protocol:   r.Protocol,
+			PortMin:    r.PortMin,
+			PortMax:    r.PortMax,
+		}
+
+		switch r.Direction {
+		case payloads.Ingress:
+			rule.Direction = libsnnet.FwIngress
+		case payloads.Egress:
+			rule.Direction = libsnnet.FwEgress
+		default:
+			return sec, fmt.Errorf("Invalid direction %s", r.Direction)
+		}
+
+		if r.RemoteCIDR != "" {
+			_, remote, err := net.ParseCIDR(r.RemoteCIDR)
+			if err != nil {
+				return sec, fmt.Errorf("Invalid remote %s: %v", r.RemoteCIDR, err)
+			}
+			rule.Remote = remote
+
+			if remote.IP.To4() == nil {
+				if sec.IPv6 == nil {
+					continue
+				}
+				rule.InstanceIP = sec.IPv6
+			}
+		}
+
+		sec.Rules = append(sec.Rules, rule)
+
+		if rule.Remote == nil && sec.IPv6 != nil {
+			rule.InstanceIP = sec.IPv6
+			sec.Rules = append(sec.Rules, rule)
+		}
+	}
+
+	return sec, nil
+}
+
+func parseSecurityGroupsPayload(data []byte) (*securityGroupsCmd, error) {
+	var clouddata payloads.CommandSecurityGroups
+
+	err := yaml.Unmarshal(data, &clouddata)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &securityGroupsCmd{
+		tenant:     strings.TrimSpace(clouddata.SecurityGroups.TenantUUID),
+		generation: clouddata.SecurityGroups.Generation,
+	}
+	if cmd.tenant == "" {
+		return nil, fmt.Errorf("Missing tenant UUID")
+	}
+
+	for _, i := range clouddata.SecurityGroups.Instances {
+		sec, err := instanceSecurity(i)
+		if err != nil {
+			return nil, err
+		}
+		cmd.instances = append(cmd.instances, sec)
+	}
+
+	return cmd, nil
+}
+
+func generateNetworkReportPayload(report *libsnnet.TopologyReport, agentUUID string,
+	repair bool, inspectErr error) ([]byte, error) {
+	var event payloads.NetworkReport
+
+	event.Report.NodeUUID = agentUUID
+	event.Report.Repair = repair
+	if inspectErr != nil {
+		event.Report.Error = inspectErr.Error()
+	}
+
+	if report != nil {
+		for _, l := range report.Expected {
+			event.Report.Expected = append(event.Report.Expected,
+				payloads.NetworkLink{Alias: l.Alias, Name: l.Name, Master: l.Master})
+		}
+		for _, l := range report.Actual {
+			event.Report.Actual = append(event.Report.Actual,
+				payloads.NetworkLink{Alias: l.Alias, Name: l.Name, Master: l.Master, Up: l.Up})
+		}
+		for _, p := range report.Problems {
+			event.Report.Problems = append(event.Report.Problems,
+				payloads.NetworkProblem{Alias: p.Alias, Problem: p.Problem, Repaired: p.Repaired})
+		}
 	}
 
-	return extractVolumeInfo(&clouddata.Attach, payloads.AttachVolumeInvalidData)
+	return yaml.Marshal(&event)
 }
 
 func linesToBytes(doc []string, buf *bytes.Buffer) {
@@ -17,7 +17,9 @@
 package main
 
 import (
+	"fmt"
 	"reflect"
+	"strings"
 	"testing"
 
 	yaml "gopkg.in/yaml.v2"
@@ -71,6 +73,8 @@ start:
 				{
 					"69e84267-ed01-4738-b15f-b47de06b62e7",
 					true,
+					"",
+					false,
 				},
 			},
 		},
@@ -149,6 +153,156 @@ start:
   storage:
      - id: 69e84267-ed01-4738-b15f-b47de06b62e7
        boot: true
+`,
+		nil,
+	},
+	{
+		`
+start:
+  requested_resources:
+     - type: vcpus
+       value: 2
+     - type: mem_mb
+       value: 370
+  instance_uuid: d7d86208-b46c-4465-9018-ee14087d415f
+  tenant_uuid: 67d86208-000-4465-9018-fe14087d415f
+  fw_type: legacy
+  vm_type: qemu
+  networking:
+    vnic_mac: 02:00:e6:f5:af:f9
+    vnic_uuid: 67d86208-b46c-0000-9018-fe14087d415f
+    concentrator_ip: 192.168.42.21
+    concentrator_uuid: 67d86208-b46c-4465-0000-fe14087d415f
+    subnet: 192.168.8.0/21
+    subnet_key: 5
+    private_ip: 192.168.8.2
+  extra_networking:
+  - vnic_mac: 02:00:ac:12:05:02
+    vnic_uuid: 67d86208-b46c-1111-9018-fe14087d415f
+    concentrator_ip: 192.168.42.22
+    concentrator_uuid: 67d86208-b46c-4465-1111-fe14087d415f
+    subnet: 172.18.5.0/24
+    subnet_key: 6
+    private_ip: 172.18.5.2
+`,
+		&vmConfig{
+			Cpus:       2,
+			Mem:        370,
+			Instance:   "d7d86208-b46c-4465-9018-ee14087d415f",
+			Legacy:     true,
+			VnicMAC:    "02:00:e6:f5:af:f9",
+			VnicIP:     "192.168.8.2",
+			ConcIP:     "192.168.42.21",
+			SubnetIP:   "192.168.8.0/21",
+			SubnetKey:  5,
+			TenantUUID: "67d86208-000-4465-9018-fe14087d415f",
+			ConcUUID:   "67d86208-b46c-4465-0000-fe14087d415f",
+			VnicUUID:   "67d86208-b46c-0000-9018-fe14087d415f",
+			ExtraVnics: []vnicConfig{
+				{
+					VnicMAC:   "02:00:ac:12:05:02",
+					VnicIP:    "172.18.5.2",
+					ConcIP:    "192.168.42.22",
+					SubnetIP:  "172.18.5.0/24",
+					SubnetKey: 6,
+					ConcUUID:  "67d86208-b46c-4465-1111-fe14087d415f",
+					VnicUUID:  "67d86208-b46c-1111-9018-fe14087d415f",
+				},
+			},
+			SSHPort: 35050,
+		},
+	},
+	{
+		`
+start:
+  requested_resources:
+     - type: vcpus
+       value: 2
+     - type: mem_mb
+       value: 370
+  instance_uuid: d7d86208-b46c-4465-9018-ee14087d415f
+  tenant_uuid: 67d86208-000-4465-9018-fe14087d415f
+  vm_type: docker
+  docker_image: ubuntu:latest
+  networking:
+    vnic_mac: 02:00:e6:f5:af:f9
+    vnic_uuid: 67d86208-b46c-0000-9018-fe14087d415f
+    concentrator_ip: 192.168.42.21
+    concentrator_uuid: 67d86208-b46c-4465-0000-fe14087d415f
+    subnet: 192.168.8.0/21
+    private_ip: 192.168.8.2
+  extra_networking:
+  - vnic_mac: 02:00:ac:12:05:02
+    vnic_uuid: 67d86208-b46c-1111-9018-fe14087d415f
+    concentrator_ip: 192.168.42.22
+    concentrator_uuid: 67d86208-b46c-4465-1111-fe14087d415f
+    subnet: 172.18.5.0/24
+    private_ip: 172.18.5.2
+`,
+		nil,
+	},
+	{
+		`
+start:
+  requested_resources:
+     - type: vcpus
+       value: 2
+     - type: mem_mb
+       value: 370
+     - type: network_ingress_kbps
+       value: 10000
+     - type: network_egress_kbps
+       value: 1000
+  instance_uuid: d7d86208-b46c-4465-9018-ee14087d415f
+  tenant_uuid: 67d86208-000-4465-9018-fe14087d415f
+  fw_type: legacy
+  vm_type: qemu
+  networking:
+    vnic_mac: 02:00:e6:f5:af:f9
+    vnic_uuid: 67d86208-b46c-0000-9018-fe14087d415f
+    concentrator_ip: 192.168.42.21
+    concentrator_uuid: 67d86208-b46c-4465-0000-fe14087d415f
+    subnet: 192.168.8.0/21
+    private_ip: 192.168.8.2
+`,
+		&vmConfig{
+			Cpus:        2,
+			Mem:         370,
+			Instance:    "d7d86208-b46c-4465-9018-ee14087d415f",
+			Legacy:      true,
+			VnicMAC:     "02:00:e6:f5:af:f9",
+			VnicIP:      "192.168.8.2",
+			ConcIP:      "192.168.42.21",
+			SubnetIP:    "192.168.8.0/21",
+			TenantUUID:  "67d86208-000-4465-9018-fe14087d415f",
+			ConcUUID:    "67d86208-b46c-4465-0000-fe14087d415f",
+			VnicUUID:    "67d86208-b46c-0000-9018-fe14087d415f",
+			IngressKbps: 10000,
+			EgressKbps:  1000,
+			SSHPort:     35050,
+		},
+	},
+	{
+		`
+start:
+  requested_resources:
+     - type: vcpus
+       value: 2
+     - type: mem_mb
+       value: 370
+     - type: network_egress_kbps
+       value: -1
+  instance_uuid: d7d86208-b46c-4465-9018-ee14087d415f
+  tenant_uuid: 67d86208-000-4465-9018-fe14087d415f
+  fw_type: legacy
+  vm_type: qemu
+  networking:
+    vnic_mac: 02:00:e6:f5:af:f9
+    vnic_uuid: 67d86208-b46c-0000-9018-fe14087d415f
+    concentrator_ip: 192.168.42.21
+    concentrator_uuid: 67d86208-b46c-4465-0000-fe14087d415f
+    subnet: 192.168.8.0/21
+    private_ip: 192.168.8.2
 `,
 		nil,
 	},
@@ -162,25 +316,117 @@ start:
 // and volume UUIDs should match what is in the payload.  Errors should be
 // returned for the invalid payloads.
 func TestParseAttachVolumePayload(t *testing.T) {
-	instance, volume, err := parseAttachVolumePayload([]byte(testutil.AttachVolumeYaml))
+	instance, volume, volumeType, err := parseAttachVolumePayload([]byte(testutil.AttachVolumeYaml))
 	if err != nil {
 		t.Fatalf("parseAttachVolumePayload failed: %v", err)
 	}
 	if instance != testutil.InstanceUUID || volume != testutil.VolumeUUID {
 		t.Fatalf("VolumeUUID or InstanceUUID is invalid")
 	}
+	if volumeType != "" {
+		t.Fatalf("Unexpected volume type %s", volumeType)
+	}
 
-	_, _, err = parseAttachVolumePayload([]byte("  -"))
+	_, _, _, err = parseAttachVolumePayload([]byte("  -"))
 	if err == nil || err.code != payloads.AttachVolumeInvalidPayload {
 		t.Fatalf("AttachVolumeInvalidPayload error expected")
 	}
 
-	_, _, err = parseAttachVolumePayload([]byte(testutil.BadAttachVolumeYaml))
+	_, _, _, err = parseAttachVolumePayload([]byte(testutil.BadAttachVolumeYaml))
 	if err == nil || err.code != payloads.AttachVolumeInvalidData {
 		t.Fatalf("AttachVolumeInvalidData error expected")
 	}
 }
 
+// Verify the parseDetachVolumePayload function.
+//
+// The function is passed one valid payload and two invalid payloads.
+//
+// No error should be returned for the valid payload and the returned instance
+// and volume UUIDs should match what is in the payload.  Errors should be
+// returned for the invalid payloads.
+func TestParseDetachVolumePayload(t *testing.T) {
+	instance, volume, err := parseDetachVolumePayload([]byte(testutil.DetachVolumeYaml))
+	if err != nil {
+		t.Fatalf("parseDetachVolumePayload failed: %v", err)
+	}
+	if instance != testutil.InstanceUUID || volume != testutil.VolumeUUID {
+		t.Fatalf("VolumeUUID or InstanceUUID is invalid")
+	}
+
+	_, _, err = parseDetachVolumePayload([]byte("  -"))
+	if err == nil || err.code != payloads.DetachVolumeInvalidPayload {
+		t.Fatalf("DetachVolumeInvalidPayload error expected")
+	}
+
+	_, _, err = parseDetachVolumePayload([]byte(testutil.BadDetachVolumeYaml))
+	if err == nil || err.code != payloads.DetachVolumeInvalidData {
+		t.Fatalf("DetachVolumeInvalidData error expected")
+	}
+}
+
+// Verify the parseResizeVolumePayload function.
+//
+// The function is passed one valid payload and two invalid payloads.
+//
+// No error should be returned for the valid payload and the returned instance
+// and volume UUIDs and size should match what is in the payload.  Errors
+// should be returned for the invalid payloads.
+func TestParseResizeVolumePayload(t *testing.T) {
+	instance, volume, size, err := parseResizeVolumePayload([]byte(testutil.ResizeVolumeYaml))
+	if err != nil {
+		t.Fatalf("parseResizeVolumePayload failed: %v", err)
+	}
+	if instance != testutil.InstanceUUID || volume != testutil.VolumeUUID || size != 20 {
+		t.Fatalf("VolumeUUID, InstanceUUID or size is invalid")
+	}
+
+	_, _, _, err = parseResizeVolumePayload([]byte("  -"))
+	if err == nil {
+		t.Fatalf("Error expected for invalid payload")
+	}
+
+	_, _, _, err = parseResizeVolumePayload([]byte(testutil.AttachVolumeYaml))
+	if err == nil {
+		t.Fatalf("Error expected for payload without resize_volume")
+	}
+}
+
+// Verify the parseMigratePayload function.
+//
+// A valid MIGRATE payload should be parsed correctly and a malformed
+// payload should result in a MigrateInvalidPayload error.
+func TestParseMigratePayload(t *testing.T) {
+	instance, destination, uri, err := parseMigratePayload([]byte(testutil.LiveMigrateYaml))
+	if err != nil {
+		t.Fatalf("parseMigratePayload failed: %v", err)
+	}
+	if instance != testutil.InstanceUUID || destination != testutil.DestAgentUUID ||
+		uri != testutil.MigrationURI {
+		t.Fatalf("InstanceUUID, DestinationAgentUUID or DestinationURI is invalid")
+	}
+
+	_, _, _, err = parseMigratePayload([]byte("  -"))
+	if err == nil || err.code != payloads.MigrateInvalidPayload {
+		t.Fatalf("MigrateInvalidPayload error expected")
+	}
+}
+
+func TestParseLabelNodePayload(t *testing.T) {
+	labels, err := parseLabelNodePayload([]byte(testutil.LabelNodeYaml))
+	if err != nil {
+		t.Fatalf("parseLabelNodePayload failed: %v", err)
+	}
+	if !reflect.DeepEqual(labels, testutil.NodeLabels) {
+		t.Fatalf("Unexpected labels %v", labels)
+	}
+
+	_, err = parseLabelNodePayload([]byte("label_node:\n  labels:\n    \"\": x\n"))
+	if err == nil {
+		t.Fatalf("Error expected for empty label key")
+	}
+}
+
 // Verify the parseStartPayload function.
 //
 // The function is passed one valid payload and a number of invalid payloads.
@@ -208,23 +454,176 @@ func TestParseStartPayload(t *testing.T) {
 }
 
 func compareNetEvents(t *testing.T, ev *libsnnet.SsntpEventInfo, eventData *payloads.TenantAddedEvent) {
+	var tunnelType payloads.TunnelType
+	if ev.Mode == libsnnet.VxlanTunnel {
+		tunnelType = payloads.VXLANTunnel
+	}
+
 	if eventData.AgentUUID != testutil.AgentUUID ||
 		eventData.AgentIP != ev.CnIP ||
 		eventData.TenantUUID != ev.TenantID ||
 		eventData.TenantSubnet != ev.SubnetID ||
 		eventData.ConcentratorUUID != ev.ConcID ||
 		eventData.ConcentratorIP != ev.CnciIP ||
-		eventData.SubnetKey != ev.SubnetKey {
+		eventData.SubnetKey != ev.SubnetKey ||
+		eventData.TunnelType != tunnelType {
 		t.Errorf("payloads and ssntp events do not match")
 	}
 }
 
+func TestParseNetworkInspectPayload(t *testing.T) {
+	repair, err := parseNetworkInspectPayload([]byte(testutil.NetworkInspectYaml))
+	if err != nil {
+		t.Fatalf("parseNetworkInspectPayload failed: %v", err)
+	}
+	if !repair {
+		t.Fatalf("Repair expected")
+	}
+}
+
+// Verify that parseTenantRoutesPayload parses the subnets of a tenant.
+//
+// A valid TenantRoutes payload is parsed, then one without a tenant.
+//
+// The tenant and subnets of the valid payload should be returned and the
+// payload without a tenant should fail to parse.
+func TestParseTenantRoutesPayload(t *testing.T) {
+	tenant, routers, err := parseTenantRoutesPayload([]byte(testutil.TenantRoutesYaml))
+	if err != nil {
+		t.Fatalf("parseTenantRoutesPayload failed: %v", err)
+	}
+	if tenant != testutil.TenantUUID {
+		t.Fatalf("Unexpected tenant %s", tenant)
+	}
+
+	expected := []routerSubnetConfig{
+		{testutil.TenantSubnet, testutil.CNCIIP, testutil.CNCIUUID, 8},
+		{testutil.ExtraTenantSubnet, testutil.CNCIIP, testutil.CNCIUUID, 9},
+	}
+	if !reflect.DeepEqual(routers, expected) {
+		t.Fatalf("Unexpected subnets %v", routers)
+	}
+
+	subnets, err := createRouterSubnets(routers)
+	if err != nil {
+		t.Fatalf("createRouterSubnets failed: %v", err)
+	}
+	if len(subnets) != 2 || subnets[1].SubnetID != testutil.ExtraTenantSubnet ||
+		subnets[1].Subnet.String() != testutil.ExtraTenantSubnet || subnets[1].SubnetKey != 9 {
+		t.Fatalf("Unexpected router subnets %v", subnets)
+	}
+
+	_, _, err = parseTenantRoutesPayload([]byte("tenant_routes:\n  node_uuid: x\n"))
+	if err == nil {
+		t.Fatalf("Error expected for missing tenant")
+	}
+}
+
+// Verify that parseSecurityGroupsPayload parses the rules of the instances
+// of a tenant.
+//
+// A valid SecurityGroups payload sent to a compute node is parsed, then one
+// with an invalid direction.
+//
+// The tenant and the ingress rule of the instance of the valid payload
+// should be returned and the payload with an invalid direction should fail
+// to parse.
+func TestParseSecurityGroupsPayload(t *testing.T) {
+	cmd, err := parseSecurityGroupsPayload([]byte(testutil.NodeSecurityGroupsYaml))
+	if err != nil {
+		t.Fatalf("parseSecurityGroupsPayload failed: %v", err)
+	}
+	if cmd.tenant != testutil.TenantUUID {
+		t.Fatalf("Unexpected tenant %s", cmd.tenant)
+	}
+
+	instances := cmd.instances
+
+	if len(instances) != 1 || len(instances[0].Rules) != 1 ||
+		instances[0].IP.String() != testutil.InstancePrivateIP {
+		t.Fatalf("Unexpected instances %v", instances)
+	}
+
+	rule := instances[0].Rules[0]
+	if rule.Direction != libsnnet.FwIngress || rule.Protocol != "tcp" ||
+		rule.PortMin != 22 || rule.Remote.String() != "0.0.0.0/0" ||
+		!rule.InstanceIP.Equal(instances[0].IP) {
+		t.Fatalf("Unexpected rule %v", rule)
+	}
+
+	invalid := strings.Replace(testutil.NodeSecurityGroupsYaml, "ingress", "inwards", 1)
+	_, err = parseSecurityGroupsPayload([]byte(invalid))
+	if err == nil {
+		t.Fatalf("Error expected for invalid direction")
+	}
+}
+
+// Verify that generateNetworkReportPayload converts topology reports.
+//
+// A report with one expected link, one actual link and one problem is
+// converted, then a failed inspection without a report.
+//
+// The links and problems of the report should be found in the payload and
+// the error of the failed inspection should be reported.
+func TestGenerateNetworkReportPayload(t *testing.T) {
+	report := &libsnnet.TopologyReport{
+		Expected: []libsnnet.TopologyLink{
+			{Alias: "vnic_a##10.0.0.2", Name: "svn_1", Master: "br_a"},
+		},
+		Actual: []libsnnet.TopologyLink{
+			{Alias: "vnic_a##10.0.0.2", Name: "svn_1", Up: true},
+		},
+		Problems: []libsnnet.TopologyProblem{
+			{Alias: "vnic_a##10.0.0.2", Problem: "VNIC has no bridge br_a"},
+		},
+	}
+
+	pl, err := generateNetworkReportPayload(report, testutil.AgentUUID, false, nil)
+	if err != nil {
+		t.Fatalf("Failed to generate payload : %v", err)
+	}
+	var event payloads.NetworkReport
+	err = yaml.Unmarshal(pl, &event)
+	if err != nil {
+		t.Fatalf("Unable to unmarshall event : %v", err)
+	}
+
+	expected := payloads.NetworkReportEvent{
+		NodeUUID: testutil.AgentUUID,
+		Expected: []payloads.NetworkLink{
+			{Alias: "vnic_a##10.0.0.2", Name: "svn_1", Master: "br_a"},
+		},
+		Actual: []payloads.NetworkLink{
+			{Alias: "vnic_a##10.0.0.2", Name: "svn_1", Up: true},
+		},
+		Problems: []payloads.NetworkProblem{
+			{Alias: "vnic_a##10.0.0.2", Problem: "VNIC has no bridge br_a"},
+		},
+	}
+	if !reflect.DeepEqual(event.Report, expected) {
+		t.Errorf("Unexpected network report %+v", event.Report)
+	}
+
+	pl, err = generateNetworkReportPayload(nil, testutil.AgentUUID, true, fmt.Errorf("failed"))
+	if err != nil {
+		t.Fatalf("Failed to generate payload : %v", err)
+	}
+	event = payloads.NetworkReport{}
+	err = yaml.Unmarshal(pl, &event)
+	if err != nil {
+		t.Fatalf("Unable to unmarshall event : %v", err)
+	}
+	if event.Report.Error != "failed" || !event.Report.Repair {
+		t.Errorf("Unexpected network report %+v", event.Report)
+	}
+}
+
 // Verify that the generateNetEventPayload parses payloads correctly.
 //
 // Two valid payloads are passed to generateNetEventPayload, the first
 // representing a payloads.EventTenantAdded event, the second a
-// payloads.EventTenantRemoved event.  Finally, an event with an invalid
-// id is parsed.
+// payloads.EventTenantRemoved event.  The first is then generated again
+// for a VXLAN tunnel.  Finally, an event with an invalid id is parsed.
 //
 // Both valid payloads should be parsed correctly and the resulting
 // payloads data structures should have the correct contents.  An
@@ -268,6 +667,20 @@ func TestGenerateNetEventPayload(t *testing.T) {
 
 	compareNetEvents(t, ev, &eventData2.TenantRemoved)
 
+	ev.Event = libsnnet.SsntpTunAdd
+	ev.Mode = libsnnet.VxlanTunnel
+	pl, err = generateNetEventPayload(ev, testutil.AgentUUID)
+	if err != nil {
+		t.Fatalf("Failed to generate payload : %v", err)
+	}
+	var eventData3 payloads.EventTenantAdded
+	err = yaml.Unmarshal(pl, &eventData3)
+	if err != nil {
+		t.Fatalf("Unable to unmarshall event : %v", err)
+	}
+
+	compareNetEvents(t, ev, &eventData3.TenantAdded)
+
 	ev.Event = 666
 	_, err = generateNetEventPayload(ev, testutil.AgentUUID)
 	if err == nil {
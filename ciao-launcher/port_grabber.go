@@ -25,6 +25,9 @@ import (
 const (
 	portGrabberStart = 5900
 	portGrabberMax   = 6900
+
+	migrationPortGrabberStart = 49152
+	migrationPortGrabberMax   = 49216
 )
 
 /*
@@ -43,16 +46,28 @@ a port was in use or not, a la libvirt, but there'd still be a race condition.
 
 type portGrabber struct {
 	sync.Mutex
-	free map[int]struct{}
+	free  map[int]struct{}
+	start int
+	max   int
 }
 
-var uiPortGrabber = portGrabber{}
+var uiPortGrabber = newPortGrabber(portGrabberStart, portGrabberMax)
+
+// migrationPortGrabber hands out the ports on which instances being live
+// migrated to this node listen for their incoming migration stream.
+var migrationPortGrabber = newPortGrabber(migrationPortGrabberStart,
+	migrationPortGrabberMax)
 
-func init() {
-	uiPortGrabber.free = make(map[int]struct{})
-	for i := portGrabberStart; i < portGrabberMax; i++ {
-		uiPortGrabber.free[i] = struct{}{}
+func newPortGrabber(start, max int) *portGrabber {
+	pg := &portGrabber{
+		free:  make(map[int]struct{}),
+		start: start,
+		max:   max,
+	}
+	for i := start; i < max; i++ {
+		pg.free[i] = struct{}{}
 	}
+	return pg
 }
 
 func (pg *portGrabber) grabPort() int {
@@ -77,7 +92,7 @@ func (pg *portGrabber) grabPort() int {
 func (pg *portGrabber) releasePort(port int) {
 	glog.Infof("Releasing port: %d", port)
 
-	if port < portGrabberStart || port >= portGrabberMax {
+	if port < pg.start || port >= pg.max {
 		glog.Warningf("Unable to release invalid port number %d", port)
 		return
 	}
@@ -31,14 +31,17 @@ import (
 
 	"context"
 
+	storage "github.com/ciao-project/ciao/ciao-storage"
+	"github.com/ciao-project/ciao/payloads"
 	"github.com/ciao-project/ciao/qemu"
 	"github.com/golang/glog"
 )
 
 const (
-	qemuEfiFw = "/usr/share/qemu/OVMF.fd"
-	seedImage = "seed.iso"
-	vcTries   = 10
+	qemuEfiFw             = "/usr/share/qemu/OVMF.fd"
+	seedImage             = "seed.iso"
+	vcTries               = 10
+	migrationPollInterval = time.Second
 )
 
 type qmpGlogLogger struct{}
@@ -75,6 +78,8 @@ type qemuV struct {
 	prevCPUTime    int64
 	prevSampleTime time.Time
 	isoPath        string
+	migrationPort  int
+	storageDriver  storage.BlockDriver
 }
 
 func (q *qemuV) init(cfg *vmConfig, instanceDir string) {
@@ -203,7 +208,7 @@ func computeTapParam(vnicName string, mac string) ([]string, error) {
 	return params, nil
 }
 
-func launchQemuWithNC(params []string, fds []*os.File, ipAddress string) (int, error) {
+func launchQemuWithNC(params []string, fds []*os.File) (int, error) {
 	var err error
 
 	tries := 0
@@ -215,14 +220,14 @@ func launchQemuWithNC(params []string, fds []*os.File, ipAddress string) (int, e
 		if port == 0 {
 			break
 		}
-		ncString := "socket,port=%d,host=%s,server,id=gnc0,server,nowait"
-		params[len(params)-1] = fmt.Sprintf(ncString, port, ipAddress)
+		ncString := "socket,port=%d,host=127.0.0.1,server,id=gnc0,server,nowait"
+		params[len(params)-1] = fmt.Sprintf(ncString, port)
 		var errStr string
 
 		errStr, err = qemu.LaunchCustomQemu(context.Background(), "", params, fds, qmpGlogLogger{})
 		if err == nil {
 			glog.Info("============================================")
-			glog.Infof("Connect to vm with netcat %s %d", ipAddress, port)
+			glog.Infof("Connect to vm with netcat 127.0.0.1 %d", port)
 			glog.Info("============================================")
 			break
 		}
@@ -236,13 +241,17 @@ func launchQemuWithNC(params []string, fds []*os.File, ipAddress string) (int, e
 
 	if port == 0 || (err != nil && tries == vcTries) {
 		glog.Warning("Failed to launch qemu due to chardev error.  Relaunching without virtual console")
+		if port != 0 {
+			uiPortGrabber.releasePort(port)
+			port = 0
+		}
 		_, err = qemu.LaunchCustomQemu(context.Background(), "", params[:len(params)-4], fds, qmpGlogLogger{})
 	}
 
 	return port, err
 }
 
-func launchQemuWithSpice(params []string, fds []*os.File, ipAddress string) (int, error) {
+func launchQemuWithSpice(params []string, fds []*os.File) (int, error) {
 	var err error
 
 	tries := 0
@@ -253,12 +262,12 @@ func launchQemuWithSpice(params []string, fds []*os.File, ipAddress string) (int
 		if port == 0 {
 			break
 		}
-		params[len(params)-1] = fmt.Sprintf("port=%d,addr=%s,disable-ticketing", port, ipAddress)
+		params[len(params)-1] = fmt.Sprintf("port=%d,addr=127.0.0.1,disable-ticketing", port)
 		var errStr string
 		errStr, err = qemu.LaunchCustomQemu(context.Background(), "", params, fds, qmpGlogLogger{})
 		if err == nil {
 			glog.Info("============================================")
-			glog.Infof("Connect to vm with spicec -h %s -p %d", ipAddress, port)
+			glog.Infof("Connect to vm with spicec -h 127.0.0.1 -p %d", port)
 			glog.Info("============================================")
 			break
 		}
@@ -273,6 +282,10 @@ func launchQemuWithSpice(params []string, fds []*os.File, ipAddress string) (int
 
 	if port == 0 || (err != nil && tries == vcTries) {
 		glog.Warning("Failed to launch qemu due to spice error.  Relaunching without virtual console")
+		if port != 0 {
+			uiPortGrabber.releasePort(port)
+			port = 0
+		}
 		params = append(params[:len(params)-2], "-display", "none", "-vga", "none")
 		_, err = qemu.LaunchCustomQemu(context.Background(), "", params, fds, qmpGlogLogger{})
 	}
@@ -280,8 +293,11 @@ func launchQemuWithSpice(params []string, fds []*os.File, ipAddress string) (int
 	return port, err
 }
 
+// generateQEMULaunchParams generates the qemu command line of an instance.
+// Volumes are accessed through ceph unless they are mapped to a device of
+// the node, in which case volumeDevices holds that device.
 func generateQEMULaunchParams(cfg *vmConfig, isoPath, instanceDir string,
-	networkParams []string, cephID string) []string {
+	networkParams []string, cephID string, volumeDevices map[string]string) []string {
 	params := make([]string, 0, 32)
 
 	addr := 3
@@ -299,6 +315,10 @@ func generateQEMULaunchParams(cfg *vmConfig, isoPath, instanceDir string,
 		blockdevID := fmt.Sprintf("drive_%s", v.UUID)
 		volDriveStr := fmt.Sprintf("file=rbd:rbd/%s:id=%s,if=none,id=%s,format=raw",
 			v.UUID, cephID, blockdevID)
+		if devName, ok := volumeDevices[v.UUID]; ok {
+			volDriveStr = fmt.Sprintf("file=%s,if=none,id=%s,format=raw",
+				devName, blockdevID)
+		}
 		params = append(params, "-drive", volDriveStr)
 		volDeviceStr :=
 			fmt.Sprintf("virtio-blk-pci,scsi=off,bus=pci.0,addr=0x%x,id=device_%s,drive=%s",
@@ -349,10 +369,63 @@ func generateQEMULaunchParams(cfg *vmConfig, isoPath, instanceDir string,
 	if !cfg.Legacy {
 		params = append(params, "-bios", qemuEfiFw)
 	}
+
+	if cfg.IncomingURI != "" {
+		params = append(params, "-incoming", cfg.IncomingURI)
+	}
 	return params
 }
 
-func (q *qemuV) startVM(vnicName, ipAddress, cephID string) error {
+func (q *qemuV) prepareIncomingMigration(ipAddress string) error {
+	q.migrationPort = migrationPortGrabber.grabPort()
+	if q.migrationPort == 0 {
+		return fmt.Errorf("No ports available for incoming migration")
+	}
+	q.cfg.IncomingURI = fmt.Sprintf("tcp:%s:%d", ipAddress, q.migrationPort)
+	return nil
+}
+
+func (q *qemuV) releaseMigrationPort() {
+	if q.migrationPort != 0 {
+		migrationPortGrabber.releasePort(q.migrationPort)
+		q.migrationPort = 0
+	}
+}
+
+// mapVolumes maps the volumes of the instance to the node, unless qemu
+// accesses them directly through ceph, and returns their devices.  Ceph
+// volumes of a named volume type are returned as rbd image specifications
+// as they may live in a pool other than the default one.
+func (q *qemuV) mapVolumes() (map[string]string, error) {
+	if q.storageDriver == nil {
+		return nil, nil
+	}
+
+	volumeDevices := make(map[string]string)
+	for _, v := range q.cfg.Volumes {
+		driver, err := volumeDriver(q.storageDriver, v.VolumeType)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to map volume %s: %v", v.UUID, err)
+		}
+
+		if ceph, ok := driver.(storage.CephDriver); ok {
+			if v.VolumeType != "" {
+				volumeDevices[v.UUID] = ceph.ImageSpec(v.UUID)
+			}
+			continue
+		}
+
+		devName, err := mapVolume(driver, v.UUID)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to map volume %s: %v", v.UUID, err)
+		}
+		volumeDevices[v.UUID] = devName
+	}
+
+	return volumeDevices, nil
+}
+
+func (q *qemuV) startVM(vnicName string, extraVnicNames []string, ipAddress, cephID string) error {
 
 	var fds []*os.File
 
@@ -379,33 +452,52 @@ func (q *qemuV) startVM(vnicName, ipAddress, cephID string) error {
 			}
 			networkParams = append(networkParams, tapParam...)
 		}
+
+		for i, extraName := range extraVnicNames {
+			tapParam, err := computeTapParam(extraName, q.cfg.ExtraVnics[i].VnicMAC)
+			if err != nil {
+				return err
+			}
+			networkParams = append(networkParams, tapParam...)
+		}
 	} else {
 		networkParams = append(networkParams, "-net", "nic,model=virtio")
 		networkParams = append(networkParams, "-net", "user")
 	}
 
-	params := generateQEMULaunchParams(q.cfg, q.isoPath, q.instanceDir, networkParams, cephID)
+	if q.cfg.MigrateFrom != "" {
+		if err := q.prepareIncomingMigration(ipAddress); err != nil {
+			return err
+		}
+	}
 
-	var err error
+	volumeDevices, err := q.mapVolumes()
+	if err != nil {
+		return err
+	}
+
+	params := generateQEMULaunchParams(q.cfg, q.isoPath, q.instanceDir, networkParams, cephID,
+		volumeDevices)
 
 	if !launchWithUI.Enabled() {
 		params = append(params, "-display", "none", "-vga", "none")
 		_, err = qemu.LaunchCustomQemu(context.Background(), "", params, fds, qmpGlogLogger{})
 	} else if launchWithUI.String() == "spice" {
 		var port int
-		port, err = launchQemuWithSpice(params, fds, ipAddress)
+		port, err = launchQemuWithSpice(params, fds)
 		if err == nil {
 			q.vcPort = port
 		}
 	} else {
 		var port int
-		port, err = launchQemuWithNC(params, fds, ipAddress)
+		port, err = launchQemuWithNC(params, fds)
 		if err == nil {
 			q.vcPort = port
 		}
 	}
 
 	if err != nil {
+		q.releaseMigrationPort()
 		return err
 	}
 
@@ -415,11 +507,12 @@ func (q *qemuV) startVM(vnicName, ipAddress, cephID string) error {
 }
 
 func (q *qemuV) lostVM() {
-	if launchWithUI.Enabled() {
+	if q.vcPort != 0 {
 		glog.Infof("Releasing VC Port %d", q.vcPort)
 		uiPortGrabber.releasePort(q.vcPort)
 		q.vcPort = 0
 	}
+	q.releaseMigrationPort()
 	q.pid = 0
 	q.prevCPUTime = -1
 }
@@ -441,20 +534,97 @@ func qmpAttach(cmd virtualizerAttachCmd, q *qemu.QMP) {
 	cmd.responseCh <- err
 }
 
+func qmpDetach(cmd virtualizerDetachCmd, q *qemu.QMP) {
+	glog.Info("Detach command received")
+	devID := fmt.Sprintf("device_%s", cmd.volumeUUID)
+
+	// device_del only completes once the guest has released the device
+	ctx, cancelFN := context.WithTimeout(context.Background(), time.Second*30)
+	err := q.ExecuteDeviceDel(ctx, devID)
+	cancelFN()
+	if err != nil {
+		glog.Errorf("Failed to execute device_del: %v", err)
+		cmd.responseCh <- err
+		return
+	}
+
+	// Drives given on the command line are deleted along with their
+	// device, so a failure here is not an error.
+	blockdevID := fmt.Sprintf("drive_%s", cmd.volumeUUID)
+	err = q.ExecuteBlockdevDel(context.Background(), blockdevID)
+	if err != nil {
+		glog.Infof("blockdev-del of %s failed: %v", blockdevID, err)
+	}
+	cmd.responseCh <- nil
+}
+
+func qmpResize(cmd virtualizerResizeCmd, q *qemu.QMP) {
+	glog.Info("Resize command received")
+	blockdevID := fmt.Sprintf("drive_%s", cmd.volumeUUID)
+	err := q.ExecuteBlockResize(context.Background(), blockdevID, cmd.size)
+	if err != nil {
+		glog.Errorf("Failed to execute block_resize: %v", err)
+	}
+	cmd.responseCh <- err
+}
+
+func qmpMigrate(cmd virtualizerMigrateCmd, q *qemu.QMP) error {
+	glog.Infof("Migrate command received: %s", cmd.uri)
+	err := q.ExecuteMigrate(context.Background(), cmd.uri)
+	if err != nil {
+		glog.Errorf("Failed to execute migrate: %v", err)
+	}
+	return err
+}
+
+// qmpQueryMigrate returns true once the migration started by qmpMigrate
+// has finished, together with an error if the migration failed.
+func qmpQueryMigrate(q *qemu.QMP) (bool, error) {
+	status, err := q.ExecuteQueryMigrate(context.Background())
+	if err != nil {
+		glog.Errorf("Failed to execute query-migrate: %v", err)
+		return true, err
+	}
+
+	switch status.Status {
+	case "completed":
+		glog.Infof("Migration completed in %d ms", status.TotalTime)
+		return true, nil
+	case "failed", "cancelled":
+		return true, fmt.Errorf("Migration %s: %s", status.Status, status.ErrorDesc)
+	}
+
+	glog.Infof("Migration %s: %d/%d bytes remaining", status.Status,
+		status.RemainingRAM, status.TotalRAM)
+	return false, nil
+}
+
 func qmpConnect(qmpChannel chan interface{}, instance, instanceDir string, closedCh chan struct{},
-	connectedCh chan struct{}, wg *sync.WaitGroup, boot bool) {
+	connectedCh chan struct{}, wg *sync.WaitGroup, boot, incoming bool) {
 
 	var q *qemu.QMP
+	var eventCh chan qemu.QMPEvent
 	defer func() {
 		if q != nil {
 			q.Shutdown()
+			if eventCh != nil {
+				for range eventCh {
+				}
+			}
 		}
 		glog.Infof("Monitor function for %s exitting", instance)
 		wg.Done()
 	}()
 
+	// Instances waiting for an incoming migration are not considered
+	// to be running until QEMU tells us that the guest has resumed.
+
 	socket := path.Join(instanceDir, "socket")
 	cfg := qemu.QMPConfig{Logger: qmpGlogLogger{}}
+	if incoming {
+		eventCh = make(chan qemu.QMPEvent)
+		cfg.EventCh = eventCh
+	}
 	q, ver, err := qemu.QMPStart(context.Background(), socket, cfg, closedCh)
 	if err != nil {
 		glog.Warningf("Failed to connect to QEMU instance %s: %v", instance, err)
@@ -471,14 +641,43 @@ func qmpConnect(qmpChannel chan interface{}, instance, instanceDir string, close
 		return
 	}
 
-	close(connectedCh)
+	if !incoming {
+		close(connectedCh)
+	}
+
+	var migrateCh chan error
+	var migratePollCh <-chan time.Time
 
 DONE:
 	for {
-		cmd, ok := <-qmpChannel
-		if !ok {
-			break DONE
+		var cmd interface{}
+		var ok bool
+
+		select {
+		case cmd, ok = <-qmpChannel:
+			if !ok {
+				break DONE
+			}
+		case <-migratePollCh:
+			migratePollCh = nil
+			if done, err := qmpQueryMigrate(q); done {
+				migrateCh <- err
+				migrateCh = nil
+			} else {
+				migratePollCh = time.After(migrationPollInterval)
+			}
+			continue
+		case ev, ok := <-eventCh:
+			if !ok {
+				eventCh = nil
+			} else if ev.Name == "RESUME" && incoming {
+				glog.Infof("Incoming migration of %s complete", instance)
+				incoming = false
+				close(connectedCh)
+			}
+			continue
 		}
+
 		switch cmd := cmd.(type) {
 		case virtualizerStopCmd:
 			ctx, cancelFN := context.WithTimeout(context.Background(), time.Second*10)
@@ -493,6 +692,23 @@ DONE:
 			}
 		case virtualizerAttachCmd:
 			qmpAttach(cmd, q)
+		case virtualizerDetachCmd:
+			qmpDetach(cmd, q)
+		case virtualizerResizeCmd:
+			qmpResize(cmd, q)
+		case virtualizerMigrateCmd:
+			err = qmpMigrate(cmd, q)
+			if err != nil {
+				cmd.responseCh <- err
+			} else {
+				migrateCh = cmd.responseCh
+				migratePollCh = time.After(migrationPollInterval)
+			}
+		case virtualizerQuitCmd:
+			err = q.ExecuteQuit(context.Background())
+			if err != nil {
+				glog.Warningf("Failed to execute quit instance: %v", err)
+			}
 		}
 	}
 }
@@ -509,10 +725,24 @@ func (q *qemuV) monitorVM(closedCh chan struct{}, connectedCh chan struct{},
 	wg *sync.WaitGroup, boot bool) chan interface{} {
 	qmpChannel := make(chan interface{})
 	wg.Add(1)
-	go qmpConnect(qmpChannel, q.cfg.Instance, q.instanceDir, closedCh, connectedCh, wg, boot)
+	incoming := !boot && q.cfg.IncomingURI != ""
+	go qmpConnect(qmpChannel, q.cfg.Instance, q.instanceDir, closedCh, connectedCh, wg, boot,
+		incoming)
 	return qmpChannel
 }
 
+func (q *qemuV) console() (string, int) {
+	if q.vcPort == 0 {
+		return "", 0
+	}
+
+	if launchWithUI.String() == "spice" {
+		return payloads.SpiceConsole, q.vcPort
+	}
+
+	return payloads.SerialConsole, q.vcPort
+}
+
 func (q *qemuV) stats() (disk, memory, cpu int) {
 	disk = 0
 	memory = -1
@@ -546,6 +776,8 @@ func (q *qemuV) stats() (disk, memory, cpu int) {
 }
 
 func (q *qemuV) connected() {
+	q.releaseMigrationPort()
+
 	qmpSocket := path.Join(q.instanceDir, "socket")
 	var buf bytes.Buffer
 	cmd := exec.Command("fuser", qmpSocket)
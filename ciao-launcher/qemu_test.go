@@ -23,9 +23,12 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/ciao-project/ciao/testutil"
 )
 
 func genQEMUParams(networkParams []string) []string {
@@ -49,7 +52,7 @@ func TestGenerateQEMULaunchParams(t *testing.T) {
 	cfg.Cpus = 0
 	params = append(params, "-bios", qemuEfiFw)
 	genParams := generateQEMULaunchParams(&cfg, "/var/lib/ciao/instance/1/seed.iso",
-		"/var/lib/ciao/instance/1", nil, "ciao")
+		"/var/lib/ciao/instance/1", nil, "ciao", nil)
 	if !reflect.DeepEqual(params, genParams) {
 		t.Fatalf("%s and %s do not match", params, genParams)
 	}
@@ -60,7 +63,7 @@ func TestGenerateQEMULaunchParams(t *testing.T) {
 	cfg.Legacy = true
 	params = append(params, "-m", "100")
 	genParams = generateQEMULaunchParams(&cfg, "/var/lib/ciao/instance/1/seed.iso",
-		"/var/lib/ciao/instance/1", nil, "ciao")
+		"/var/lib/ciao/instance/1", nil, "ciao", nil)
 	if !reflect.DeepEqual(params, genParams) {
 		t.Fatalf("%s and %s do not match", params, genParams)
 	}
@@ -71,7 +74,7 @@ func TestGenerateQEMULaunchParams(t *testing.T) {
 	cfg.Legacy = true
 	params = append(params, "-smp", "cpus=4")
 	genParams = generateQEMULaunchParams(&cfg, "/var/lib/ciao/instance/1/seed.iso",
-		"/var/lib/ciao/instance/1", nil, "ciao")
+		"/var/lib/ciao/instance/1", nil, "ciao", nil)
 	if !reflect.DeepEqual(params, genParams) {
 		t.Fatalf("%s and %s do not match", params, genParams)
 	}
@@ -82,7 +85,32 @@ func TestGenerateQEMULaunchParams(t *testing.T) {
 	cfg.Cpus = 0
 	cfg.Legacy = true
 	genParams = generateQEMULaunchParams(&cfg, "/var/lib/ciao/instance/1/seed.iso",
-		"/var/lib/ciao/instance/1", netParams, "ciao")
+		"/var/lib/ciao/instance/1", netParams, "ciao", nil)
+	if !reflect.DeepEqual(params, genParams) {
+		t.Fatalf("%s and %s do not match", params, genParams)
+	}
+
+	params = genQEMUParams(nil)
+	cfg.Legacy = true
+	cfg.IncomingURI = "tcp:192.168.0.2:49152"
+	params = append(params, "-incoming", cfg.IncomingURI)
+	genParams = generateQEMULaunchParams(&cfg, "/var/lib/ciao/instance/1/seed.iso",
+		"/var/lib/ciao/instance/1", nil, "ciao", nil)
+	if !reflect.DeepEqual(params, genParams) {
+		t.Fatalf("%s and %s do not match", params, genParams)
+	}
+
+	cfg.IncomingURI = ""
+	cfg.Volumes = []volumeConfig{{UUID: "a"}, {UUID: "b"}}
+	params = []string{
+		"-drive", "file=rbd:rbd/a:id=ciao,if=none,id=drive_a,format=raw",
+		"-device", "virtio-blk-pci,scsi=off,bus=pci.0,addr=0x3,id=device_a,drive=drive_a",
+		"-drive", "file=/dev/ciao/b,if=none,id=drive_b,format=raw",
+		"-device", "virtio-blk-pci,scsi=off,bus=pci.0,addr=0x4,id=device_b,drive=drive_b",
+	}
+	params = append(params, genQEMUParams(nil)...)
+	genParams = generateQEMULaunchParams(&cfg, "/var/lib/ciao/instance/1/seed.iso",
+		"/var/lib/ciao/instance/1", nil, "ciao", map[string]string{"b": "/dev/ciao/b"})
 	if !reflect.DeepEqual(params, genParams) {
 		t.Fatalf("%s and %s do not match", params, genParams)
 	}
@@ -97,7 +125,7 @@ func TestQmpConnectBadSocket(t *testing.T) {
 	instanceDir := path.Join("/tmp", instance)
 
 	wg.Add(1)
-	go qmpConnect(qmpChannel, instance, instanceDir, closedCh, connectedCh, &wg, false)
+	go qmpConnect(qmpChannel, instance, instanceDir, closedCh, connectedCh, &wg, false, false)
 	wg.Wait()
 	select {
 	case <-closedCh:
@@ -129,7 +157,7 @@ func setupQmpSocket(t *testing.T, runTest func(net.Conn, *bufio.Scanner, chan in
 	}
 	defer ln.Close()
 	wg.Add(1)
-	go qmpConnect(qmpChannel, instance, instanceDir, closedCh, connectedCh, &wg, false)
+	go qmpConnect(qmpChannel, instance, instanceDir, closedCh, connectedCh, &wg, false, false)
 	fd, err := ln.Accept()
 	if err != nil {
 		t.Fatalf("Unable to accept client %v", err)
@@ -208,3 +236,142 @@ func TestQmpLost(t *testing.T) {
 		return false
 	})
 }
+
+func TestQmpMigrate(t *testing.T) {
+	setupQmpSocket(t, func(fd net.Conn, sc *bufio.Scanner, qmpChannel chan interface{}, t *testing.T) bool {
+		responseCh := make(chan error, 1)
+		qmpChannel <- virtualizerMigrateCmd{
+			responseCh: responseCh,
+			uri:        "tcp:192.168.0.2:49152",
+		}
+		if !sc.Scan() {
+			t.Fatalf("migrate command expected")
+		}
+		_, err := fmt.Fprintln(fd, `{ "return": {}}`)
+		if err != nil {
+			t.Fatalf("Unable to write to domain socket: %v", err)
+		}
+
+		if !sc.Scan() {
+			t.Fatalf("query-migrate command expected")
+		}
+		_, err = fmt.Fprintln(fd, `{ "return": {"status": "completed", "total-time": 100}}`)
+		if err != nil {
+			t.Fatalf("Unable to write to domain socket: %v", err)
+		}
+
+		select {
+		case err = <-responseCh:
+			if err != nil {
+				t.Errorf("Unexpected migration failure: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("Timed out waiting for migration to complete")
+		}
+
+		return true
+	})
+}
+
+func TestQmpDetach(t *testing.T) {
+	setupQmpSocket(t, func(fd net.Conn, sc *bufio.Scanner, qmpChannel chan interface{}, t *testing.T) bool {
+		responseCh := make(chan error, 1)
+		qmpChannel <- virtualizerDetachCmd{
+			responseCh: responseCh,
+			volumeUUID: testutil.VolumeUUID,
+		}
+		if !sc.Scan() {
+			t.Fatalf("device_del command expected")
+		}
+		if !strings.Contains(sc.Text(), "device_"+testutil.VolumeUUID) {
+			t.Errorf("Unexpected device_del command: %s", sc.Text())
+		}
+		_, err := fmt.Fprintln(fd, `{ "return": {}}`)
+		if err != nil {
+			t.Fatalf("Unable to write to domain socket: %v", err)
+		}
+		_, err = fmt.Fprintf(fd, `{ "event": "DEVICE_DELETED", "data": { "device": "device_%s" }}`+"\n",
+			testutil.VolumeUUID)
+		if err != nil {
+			t.Fatalf("Unable to write to domain socket: %v", err)
+		}
+
+		if !sc.Scan() {
+			t.Fatalf("blockdev-del command expected")
+		}
+		if !strings.Contains(sc.Text(), "drive_"+testutil.VolumeUUID) {
+			t.Errorf("Unexpected blockdev-del command: %s", sc.Text())
+		}
+		_, err = fmt.Fprintln(fd, `{ "return": {}}`)
+		if err != nil {
+			t.Fatalf("Unable to write to domain socket: %v", err)
+		}
+
+		select {
+		case err = <-responseCh:
+			if err != nil {
+				t.Errorf("Unexpected detach failure: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("Timed out waiting for detach to complete")
+		}
+
+		return true
+	})
+}
+
+func TestQmpResize(t *testing.T) {
+	setupQmpSocket(t, func(fd net.Conn, sc *bufio.Scanner, qmpChannel chan interface{}, t *testing.T) bool {
+		responseCh := make(chan error, 1)
+		qmpChannel <- virtualizerResizeCmd{
+			responseCh: responseCh,
+			volumeUUID: testutil.VolumeUUID,
+			size:       20 << 30,
+		}
+		if !sc.Scan() {
+			t.Fatalf("block_resize command expected")
+		}
+		if !strings.Contains(sc.Text(), "drive_"+testutil.VolumeUUID) {
+			t.Errorf("Unexpected block_resize command: %s", sc.Text())
+		}
+		_, err := fmt.Fprintln(fd, `{ "return": {}}`)
+		if err != nil {
+			t.Fatalf("Unable to write to domain socket: %v", err)
+		}
+
+		select {
+		case err = <-responseCh:
+			if err != nil {
+				t.Errorf("Unexpected resize failure: %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("Timed out waiting for resize to complete")
+		}
+
+		return true
+	})
+}
+
+// Checks that only VMs whose disks are all volumes can be live migrated.
+func TestCanMigrate(t *testing.T) {
+	cfg := vmConfig{}
+	if cfg.canMigrate() {
+		t.Errorf("VM without a bootable volume can be migrated")
+	}
+
+	cfg.Volumes = []volumeConfig{{UUID: "boot", Bootable: true}}
+	if !cfg.canMigrate() {
+		t.Errorf("VM booting from a volume cannot be migrated")
+	}
+
+	cfg.Volumes = append(cfg.Volumes, volumeConfig{UUID: "local", Local: true})
+	if cfg.canMigrate() {
+		t.Errorf("VM with a local disk can be migrated")
+	}
+
+	cfg.Volumes = cfg.Volumes[:1]
+	cfg.Container = true
+	if cfg.canMigrate() {
+		t.Errorf("Container can be migrated")
+	}
+}
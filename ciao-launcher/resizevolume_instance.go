@@ -0,0 +1,69 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	storage "github.com/ciao-project/ciao/ciao-storage"
+	"github.com/golang/glog"
+)
+
+// processResizeVolume tells a running instance that a volume attached to it
+// has been extended.  Instances that are not running see the new size of
+// the volume when they are next started.
+func processResizeVolume(storageDriver storage.BlockDriver, monitorCh chan interface{}, cfg *vmConfig,
+	instance, volumeUUID string, sizeGiB int) error {
+
+	if cfg.Container {
+		return fmt.Errorf("Cannot resize a volume of a container")
+	}
+
+	vol := cfg.findVolume(volumeUUID)
+	if vol == nil {
+		return fmt.Errorf("%s is not attached to instance %s", volumeUUID, instance)
+	}
+
+	if monitorCh == nil {
+		glog.Infof("Instance %s not running, volume %s not resized", instance, volumeUUID)
+		return nil
+	}
+
+	// logical volumes active on the node keep their old size until
+	// they are refreshed
+	driver, err := volumeDriver(storageDriver, vol.VolumeType)
+	if err != nil {
+		return err
+	}
+
+	if lvm, ok := driver.(storage.LVMDriver); ok {
+		err := lvm.RefreshVolume(volumeUUID)
+		if err != nil {
+			return err
+		}
+	}
+
+	responseCh := make(chan error)
+
+	monitorCh <- virtualizerResizeCmd{
+		responseCh: responseCh,
+		volumeUUID: volumeUUID,
+		size:       uint64(sizeGiB) << 30,
+	}
+
+	return <-responseCh
+}
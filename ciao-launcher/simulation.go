@@ -93,7 +93,7 @@ VM:
 
 }
 
-func (s *simulation) startVM(vnicName, ipAddress, cephID string) error {
+func (s *simulation) startVM(vnicName string, extraVnicNames []string, ipAddress, cephID string) error {
 	glog.Infof("startVM\n")
 
 	s.killCh = make(chan struct{})
@@ -114,6 +114,10 @@ func (s *simulation) monitorVM(closedCh chan struct{}, connectedCh chan struct{}
 	return s.monitorCh
 }
 
+func (s *simulation) console() (string, int) {
+	return "", 0
+}
+
 func (s *simulation) stats() (disk, memory, cpu int) {
 	return s.disk / 10, s.mem / 10, s.cpus / 10
 }
@@ -20,9 +20,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/ciao-project/ciao/networking/libsnnet"
 	"github.com/ciao-project/ciao/payloads"
 	"github.com/ciao-project/ciao/ssntp"
 	"github.com/golang/glog"
+	yaml "gopkg.in/yaml.v2"
 )
 
 type cmdWrapper struct {
@@ -32,6 +34,21 @@ type cmdWrapper struct {
 type statusCmd struct{}
 type evacuateCmd struct{}
 type restoreCmd struct{}
+type labelNodeCmd struct {
+	labels map[string]string
+}
+type networkInspectCmd struct {
+	repair bool
+}
+type tenantRoutesCmd struct {
+	tenant  string
+	routers []routerSubnetConfig
+}
+type securityGroupsCmd struct {
+	tenant     string
+	generation int64
+	instances  []libsnnet.InstanceSecurity
+}
 
 // serverConn is an abstract interface representing a connection to
 // a server.  It contains methods to connect to the server and to
@@ -125,7 +142,7 @@ func (client *agentClient) CommandNotify(cmd ssntp.Command, frame *ssntp.Frame)
 		}
 		client.cmdCh <- &cmdWrapper{instance, &insDeleteCmd{stop: stop}}
 	case ssntp.AttachVolume:
-		instance, volume, payloadErr := parseAttachVolumePayload(payload)
+		instance, volume, volumeType, payloadErr := parseAttachVolumePayload(payload)
 		if payloadErr != nil {
 			attachVolumeError := &attachVolumeError{
 				payloadErr.err,
@@ -135,11 +152,70 @@ func (client *agentClient) CommandNotify(cmd ssntp.Command, frame *ssntp.Frame)
 			glog.Errorf("Unable to parse YAML: %s", payloadErr.err)
 			return
 		}
-		client.cmdCh <- &cmdWrapper{instance, &insAttachVolumeCmd{volume}}
+		client.cmdCh <- &cmdWrapper{instance, &insAttachVolumeCmd{volume, volumeType}}
+	case ssntp.DetachVolume:
+		instance, volume, payloadErr := parseDetachVolumePayload(payload)
+		if payloadErr != nil {
+			detachVolumeError := &detachVolumeError{
+				payloadErr.err,
+				payloads.DetachVolumeFailureReason(payloadErr.code),
+			}
+			detachVolumeError.send(client.conn, "", "")
+			glog.Errorf("Unable to parse YAML: %s", payloadErr.err)
+			return
+		}
+		client.cmdCh <- &cmdWrapper{instance, &insDetachVolumeCmd{volume}}
+	case ssntp.ResizeVolume:
+		instance, volume, size, err := parseResizeVolumePayload(payload)
+		if err != nil {
+			glog.Errorf("Unable to parse YAML: %v", err)
+			return
+		}
+		client.cmdCh <- &cmdWrapper{instance, &insResizeVolumeCmd{volume, size}}
+	case ssntp.MIGRATE:
+		instance, destination, uri, payloadErr := parseMigratePayload(payload)
+		if payloadErr != nil {
+			migrateError := &migrateError{
+				payloadErr.err,
+				payloads.MigrateFailureReason(payloadErr.code),
+			}
+			migrateError.send(client.conn, "", "")
+			glog.Errorf("Unable to parse YAML: %s", payloadErr.err)
+			return
+		}
+		client.cmdCh <- &cmdWrapper{instance, &insMigrateCmd{destination, uri}}
 	case ssntp.EVACUATE:
 		client.cmdCh <- &cmdWrapper{"", &evacuateCmd{}}
 	case ssntp.Restore:
 		client.cmdCh <- &cmdWrapper{"", &restoreCmd{}}
+	case ssntp.LabelNode:
+		labels, err := parseLabelNodePayload(payload)
+		if err != nil {
+			glog.Errorf("Unable to parse YAML: %v", err)
+			return
+		}
+		client.cmdCh <- &cmdWrapper{"", &labelNodeCmd{labels}}
+	case ssntp.NetworkInspect:
+		repair, err := parseNetworkInspectPayload(payload)
+		if err != nil {
+			glog.Errorf("Unable to parse YAML: %v", err)
+			return
+		}
+		client.cmdCh <- &cmdWrapper{"", &networkInspectCmd{repair}}
+	case ssntp.TenantRoutes:
+		tenant, routers, err := parseTenantRoutesPayload(payload)
+		if err != nil {
+			glog.Errorf("Unable to parse YAML: %v", err)
+			return
+		}
+		client.cmdCh <- &cmdWrapper{"", &tenantRoutesCmd{tenant, routers}}
+	case ssntp.SecurityGroups:
+		secGroupsCmd, err := parseSecurityGroupsPayload(payload)
+		if err != nil {
+			glog.Errorf("Unable to parse YAML: %v", err)
+			return
+		}
+		client.cmdCh <- &cmdWrapper{"", secGroupsCmd}
 	}
 }
 
@@ -149,4 +225,21 @@ func (client *agentClient) EventNotify(event ssntp.Event, frame *ssntp.Frame) {
 
 func (client *agentClient) ErrorNotify(err ssntp.Error, frame *ssntp.Frame) {
 	glog.Infof("ERROR %d", err)
+
+	if err != ssntp.MigrateFailure {
+		return
+	}
+
+	var failure payloads.ErrorMigrateFailure
+	if yamlErr := yaml.Unmarshal(frame.Payload, &failure); yamlErr != nil {
+		glog.Errorf("Unable to parse YAML: %v", yamlErr)
+		return
+	}
+
+	if failure.DestinationAgentUUID != client.conn.UUID() ||
+		!uuidRegexp.MatchString(failure.InstanceUUID) {
+		return
+	}
+
+	client.cmdCh <- &cmdWrapper{failure.InstanceUUID, &insAbortMigrationCmd{}}
 }
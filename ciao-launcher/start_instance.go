@@ -70,6 +70,8 @@ func processStart(cmd *insStartCmd, instanceDir string, vm virtualizer, conn ser
 	var bridge string
 	var gatewayIP string
 	var vnicCfg *libsnnet.VnicConfig
+	var extraCfgs []*libsnnet.VnicConfig
+	var extraVnicNames []string
 	var st startTimes
 
 	st.startStamp = time.Now()
@@ -101,6 +103,12 @@ func processStart(cmd *insStartCmd, instanceDir string, vm virtualizer, conn ser
 			glog.Errorf("Could not create VnicCFG: %s", err)
 			return nil, &startError{err, payloads.InvalidData, cmd.cfg.Restart}
 		}
+
+		extraCfgs, err = createExtraVnicCfgs(cfg)
+		if err != nil {
+			glog.Errorf("Could not create extra VnicCFGs: %s", err)
+			return nil, &startError{err, payloads.InvalidData, cmd.cfg.Restart}
+		}
 	}
 
 	if vnicCfg != nil {
@@ -110,6 +118,25 @@ func processStart(cmd *insStartCmd, instanceDir string, vm virtualizer, conn ser
 		}
 	}
 
+	for _, extraCfg := range extraCfgs {
+		name, _, _, err := createVnic(conn, extraCfg)
+		if err != nil {
+			return nil, &startError{err, payloads.NetworkFailure, cmd.cfg.Restart}
+		}
+		extraVnicNames = append(extraVnicNames, name)
+	}
+
+	if vnicCfg != nil && !cfg.NetworkNode && len(cfg.Routers) > 0 {
+		setTenantRouter(conn, cfg.TenantUUID, cfg.Routers)
+	}
+
+	if vnicCfg != nil && !cfg.NetworkNode {
+		err = refreshSecurityGroups()
+		if err != nil {
+			return nil, &startError{err, payloads.NetworkFailure, cmd.cfg.Restart}
+		}
+	}
+
 	st.networkStamp = time.Now()
 
 	err = createInstance(vm, instanceDir, cfg, bridge, gatewayIP, cmd.userData,
@@ -120,7 +147,7 @@ func processStart(cmd *insStartCmd, instanceDir string, vm virtualizer, conn ser
 
 	st.creationStamp = time.Now()
 
-	err = vm.startVM(vnicName, getNodeIPAddress(), cephID)
+	err = vm.startVM(vnicName, extraVnicNames, getNodeIPAddress(), cephID)
 	if err != nil {
 		return nil, &startError{err, payloads.LaunchFailure, cmd.cfg.Restart}
 	}
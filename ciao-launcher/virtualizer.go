@@ -27,6 +27,20 @@ type virtualizerAttachCmd struct {
 	volumeUUID string
 	device     string
 }
+type virtualizerDetachCmd struct {
+	responseCh chan error
+	volumeUUID string
+}
+type virtualizerResizeCmd struct {
+	responseCh chan error
+	volumeUUID string
+	size       uint64
+}
+type virtualizerMigrateCmd struct {
+	responseCh chan error
+	uri        string
+}
+type virtualizerQuitCmd struct{}
 
 var errImageNotFound = errors.New("Image Not Found")
 
@@ -61,8 +75,10 @@ type virtualizer interface {
 	// deleted by the instance go routine.
 	deleteImage() error
 
-	// Boots a VM.  This method is called by START
-	startVM(vnicName, ipAddress, cephID string) error
+	// Boots a VM.  This method is called by START.  extraVnicNames contains
+	// the names of the additional VNICs of the instance, in the same order
+	// as vmConfig.ExtraVnics.
+	startVM(vnicName string, extraVnicNames []string, ipAddress, cephID string) error
 
 	//BUG(markus): Need to use context rather than the monitor channel to
 	//detect when we need to quit.
@@ -99,6 +115,12 @@ type virtualizer interface {
 	// cpu: Normalized CPU time of VM or container process
 	stats() (disk, memory, cpu int)
 
+	// Returns the console exposed by the instance, if any.
+	// consoleType: payloads.SerialConsole, payloads.SpiceConsole or "" if the
+	// instance has no console.
+	// port: Port on which the console listens on localhost.
+	console() (consoleType string, port int)
+
 	// connected is called by the instance go routine to inform the virtualizer that
 	// the VM is running.  The virtualizer can used this notification to perform some
 	// bookkeeping, for example determine the pid of the underlying process.  It may
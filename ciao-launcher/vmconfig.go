@@ -25,8 +25,31 @@ import (
 )
 
 type volumeConfig struct {
-	UUID     string
-	Bootable bool
+	UUID       string
+	Bootable   bool
+	VolumeType string
+	Local      bool
+}
+
+// vnicConfig describes the additional VNICs of a VM attached to more than
+// one tenant network.
+type vnicConfig struct {
+	VnicMAC   string
+	VnicIP    string
+	ConcIP    string
+	SubnetIP  string
+	SubnetKey int
+	ConcUUID  string
+	VnicUUID  string
+}
+
+// routerSubnetConfig describes a subnet of the tenant to which the
+// distributed router of the tenant on the node is connected.
+type routerSubnetConfig struct {
+	SubnetIP  string
+	ConcIP    string
+	ConcUUID  string
+	SubnetKey int
 }
 
 type vmConfig struct {
@@ -42,12 +65,19 @@ type vmConfig struct {
 	VnicIP      string
 	ConcIP      string
 	SubnetIP    string
+	SubnetKey   int
 	TenantUUID  string
 	ConcUUID    string
 	VnicUUID    string
+	ExtraVnics  []vnicConfig
+	Routers     []routerSubnetConfig
+	IngressKbps int
+	EgressKbps  int
 	SSHPort     int
 	Volumes     []volumeConfig
 	Restart     bool
+	MigrateFrom string
+	IncomingURI string
 }
 
 func loadVMConfig(instanceDir string) (*vmConfig, error) {
@@ -97,6 +127,24 @@ func (cfg *vmConfig) findVolume(UUID string) *volumeConfig {
 	}
 	return nil
 }
+
+// canMigrate returns true if the VM can be live migrated to another node.
+// Only the RAM of the VM is migrated, so its disks must all be volumes
+// that the destination node can access, including the one it boots from.
+func (cfg *vmConfig) canMigrate() bool {
+	if simulate || cfg.Container || cfg.NetworkNode {
+		return false
+	}
+
+	for _, vol := range cfg.Volumes {
+		if vol.Local {
+			return false
+		}
+	}
+
+	return cfg.haveBootableVolume()
+}
+
 func (cfg *vmConfig) haveBootableVolume() bool {
 	for _, vol := range cfg.Volumes {
 		if vol.Bootable {
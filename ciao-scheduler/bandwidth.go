@@ -0,0 +1,175 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"github.com/golang/glog"
+)
+
+// Launchers limit the bandwidth of each VNIC but do not report it, so the
+// bandwidth committed to the instances dispatched to a node is tracked
+// here, keyed by instance UUID, until they are deleted or fail to start.
+// A live migration moves the claim of its instance to the destination node
+// and a failed one moves it back to the source node.
+type bandwidthClaim struct {
+	nodeUUID    string
+	migrateFrom string
+	ingressKbps int
+	egressKbps  int
+}
+
+// The sum of the claims of the instances of a node.
+type nodeBandwidth struct {
+	ingressKbps int
+	egressKbps  int
+}
+
+// Commit the bandwidth of a claim to its node.  Must be called with the
+// bandwidthMutex held.
+func (sched *ssntpSchedulerServer) commitBandwidth(claim *bandwidthClaim, sign int) {
+	committed := sched.committedBandwidth[claim.nodeUUID]
+	if committed == nil {
+		committed = &nodeBandwidth{}
+		sched.committedBandwidth[claim.nodeUUID] = committed
+	}
+
+	committed.ingressKbps += sign * claim.ingressKbps
+	committed.egressKbps += sign * claim.egressKbps
+
+	if committed.ingressKbps == 0 && committed.egressKbps == 0 {
+		delete(sched.committedBandwidth, claim.nodeUUID)
+	}
+}
+
+func (sched *ssntpSchedulerServer) addBandwidthClaim(workload *workResources, nodeUUID string) {
+	if workload.ingressKbps == 0 && workload.egressKbps == 0 {
+		return
+	}
+
+	sched.bandwidthMutex.Lock()
+	defer sched.bandwidthMutex.Unlock()
+
+	// a restarted or migrated instance gives up its previous claim
+	if old := sched.bandwidthClaims[workload.instanceUUID]; old != nil {
+		sched.commitBandwidth(old, -1)
+	}
+
+	claim := &bandwidthClaim{
+		nodeUUID:    nodeUUID,
+		migrateFrom: workload.excludeNode,
+		ingressKbps: workload.ingressKbps,
+		egressKbps:  workload.egressKbps,
+	}
+	sched.bandwidthClaims[workload.instanceUUID] = claim
+	sched.commitBandwidth(claim, 1)
+}
+
+func (sched *ssntpSchedulerServer) deleteBandwidthClaim(instanceUUID string) {
+	sched.bandwidthMutex.Lock()
+	defer sched.bandwidthMutex.Unlock()
+
+	claim := sched.bandwidthClaims[instanceUUID]
+	if claim == nil {
+		return
+	}
+
+	sched.commitBandwidth(claim, -1)
+	delete(sched.bandwidthClaims, instanceUUID)
+}
+
+// Move the claim of an instance back to the source node of its live
+// migration.  Must be called with the bandwidthMutex held.
+func (sched *ssntpSchedulerServer) restoreBandwidthClaim(instanceUUID string, claim *bandwidthClaim) {
+	glog.V(2).Infof("Bandwidth of instance %s moved back to node %s",
+		instanceUUID, claim.migrateFrom)
+
+	sched.commitBandwidth(claim, -1)
+	claim.nodeUUID = claim.migrateFrom
+	claim.migrateFrom = ""
+	sched.commitBandwidth(claim, 1)
+}
+
+// Release the claim of an instance which failed to start.  An instance
+// which failed to start on the destination of a live migration still runs
+// on its source node, where its claim is moved back.
+func (sched *ssntpSchedulerServer) releaseBandwidthClaim(instanceUUID string) {
+	sched.bandwidthMutex.Lock()
+	defer sched.bandwidthMutex.Unlock()
+
+	claim := sched.bandwidthClaims[instanceUUID]
+	if claim == nil {
+		return
+	}
+
+	if claim.migrateFrom != "" {
+		sched.restoreBandwidthClaim(instanceUUID, claim)
+		return
+	}
+
+	sched.commitBandwidth(claim, -1)
+	delete(sched.bandwidthClaims, instanceUUID)
+}
+
+// Move the claim of an instance whose live migration failed back to its
+// source node.
+func (sched *ssntpSchedulerServer) abortBandwidthMigration(instanceUUID string) {
+	sched.bandwidthMutex.Lock()
+	defer sched.bandwidthMutex.Unlock()
+
+	claim := sched.bandwidthClaims[instanceUUID]
+	if claim == nil || claim.migrateFrom == "" {
+		return
+	}
+
+	sched.restoreBandwidthClaim(instanceUUID, claim)
+}
+
+// Check the bandwidth committed to the instances of the referenced, locked
+// nodeStat object leaves room for the workload within the node bandwidth.
+// A node bandwidth of 0 is not limited.
+func (sched *ssntpSchedulerServer) bandwidthSatisfied(node *nodeStat, workload *workResources) bool {
+	if workload.ingressKbps == 0 && workload.egressKbps == 0 {
+		return true
+	}
+
+	sched.bandwidthMutex.Lock()
+	defer sched.bandwidthMutex.Unlock()
+
+	var committed nodeBandwidth
+	if c := sched.committedBandwidth[node.uuid]; c != nil {
+		committed = *c
+	}
+
+	// a restarted instance gives up its previous claim on the node
+	if claim := sched.bandwidthClaims[workload.instanceUUID]; claim != nil &&
+		claim.nodeUUID == node.uuid {
+		committed.ingressKbps -= claim.ingressKbps
+		committed.egressKbps -= claim.egressKbps
+	}
+
+	if sched.nodeIngressKbps > 0 &&
+		committed.ingressKbps+workload.ingressKbps > sched.nodeIngressKbps {
+		return false
+	}
+
+	if sched.nodeEgressKbps > 0 &&
+		committed.egressKbps+workload.egressKbps > sched.nodeEgressKbps {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,181 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp"
+	"gopkg.in/yaml.v2"
+)
+
+func bandwidthWorkload(t *testing.T, index int, ingressKbps int, egressKbps int) workResources {
+	work := createStartWorkload(2, 256, 0)
+	work.Start.InstanceUUID = fmt.Sprintf("%08d-0000-0000-0000-000000000000", index)
+	work.Start.RequestedResources = append(work.Start.RequestedResources,
+		payloads.RequestedResource{Type: payloads.NetworkIngressKbps, Value: ingressKbps},
+		payloads.RequestedResource{Type: payloads.NetworkEgressKbps, Value: egressKbps})
+
+	workload, err := sched.getWorkloadResources(work)
+	if err != nil {
+		t.Fatalf("bad workload resources: %v", err)
+	}
+
+	return workload
+}
+
+// pickBandwidthWorkload picks a node for a workload and commits its
+// bandwidth, as startWorkload would.
+func pickBandwidthWorkload(sched *ssntpSchedulerServer, workload *workResources) *nodeStat {
+	node := PickComputeNode(sched, "", workload, false)
+	if node == nil {
+		return nil
+	}
+	sched.addBandwidthClaim(workload, node.uuid)
+	node.mutex.Unlock()
+
+	return node
+}
+
+func committedBandwidth(sched *ssntpSchedulerServer, nodeUUID string) nodeBandwidth {
+	sched.bandwidthMutex.Lock()
+	defer sched.bandwidthMutex.Unlock()
+
+	if c := sched.committedBandwidth[nodeUUID]; c != nil {
+		return *c
+	}
+
+	return nodeBandwidth{}
+}
+
+func TestPickComputeNodeBandwidth(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+	sched.nodeIngressKbps = 10000
+	sched.nodeEgressKbps = 5000
+
+	spinUpComputeNodeLarge(sched, 1)
+
+	for i := 0; i < 2; i++ {
+		workload := bandwidthWorkload(t, i, 4000, 1000)
+		if node := pickBandwidthWorkload(sched, &workload); node == nil {
+			t.Fatalf("found no compute fit for workload %d", i)
+		}
+	}
+
+	committed := committedBandwidth(sched, fmt.Sprintf("%08d", 1))
+	if committed.ingressKbps != 8000 || committed.egressKbps != 2000 {
+		t.Fatalf("unexpected committed bandwidth %+v", committed)
+	}
+
+	// the node has 2000 kbps of ingress bandwidth left
+	workload := bandwidthWorkload(t, 2, 4000, 1000)
+	if node := PickComputeNode(sched, "", &workload, false); node != nil {
+		t.Fatalf("found compute fit on over-committed node %s", node.uuid)
+	}
+
+	workload = bandwidthWorkload(t, 2, 2000, 3000)
+	if node := pickBandwidthWorkload(sched, &workload); node == nil {
+		t.Fatal("found no compute fit within node bandwidth")
+	}
+
+	// a restarted instance does not count against itself
+	workload = bandwidthWorkload(t, 2, 2000, 3000)
+	if node := pickBandwidthWorkload(sched, &workload); node == nil {
+		t.Fatal("found no compute fit for restarted instance")
+	}
+
+	// workloads without limits are not constrained by the node bandwidth
+	workload = bandwidthWorkload(t, 3, 0, 0)
+	if node := PickComputeNode(sched, "", &workload, false); node == nil {
+		t.Fatal("found no compute fit for workload without bandwidth limits")
+	} else {
+		node.mutex.Unlock()
+	}
+
+	// deleting an instance releases its bandwidth
+	sched.deleteBandwidthClaim(fmt.Sprintf("%08d-0000-0000-0000-000000000000", 0))
+	workload = bandwidthWorkload(t, 4, 4000, 1000)
+	if node := pickBandwidthWorkload(sched, &workload); node == nil {
+		t.Fatal("found no compute fit after instance deleted")
+	}
+
+	// as does an instance which failed to start
+	failure := payloads.ErrorStartFailure{
+		InstanceUUID: fmt.Sprintf("%08d-0000-0000-0000-000000000000", 4),
+		Reason:       payloads.FullComputeNode,
+	}
+	y, err := yaml.Marshal(&failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched.ErrorNotify("", ssntp.StartFailure, &ssntp.Frame{Payload: y})
+
+	committed = committedBandwidth(sched, fmt.Sprintf("%08d", 1))
+	if committed.ingressKbps != 6000 || committed.egressKbps != 4000 {
+		t.Fatalf("unexpected committed bandwidth after start failure %+v", committed)
+	}
+}
+
+func TestBandwidthMigration(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+	sched.nodeIngressKbps = 10000
+
+	spinUpComputeNodeLarge(sched, 1)
+	spinUpComputeNodeLarge(sched, 2)
+
+	workload := bandwidthWorkload(t, 0, 6000, 0)
+	source := pickBandwidthWorkload(sched, &workload)
+	if source == nil {
+		t.Fatal("found no compute fit")
+	}
+
+	workload.excludeNode = source.uuid
+	dest := pickBandwidthWorkload(sched, &workload)
+	if dest == nil || dest == source {
+		t.Fatal("found no compute fit for migration")
+	}
+
+	if committedBandwidth(sched, source.uuid).ingressKbps != 0 ||
+		committedBandwidth(sched, dest.uuid).ingressKbps != 6000 {
+		t.Fatal("bandwidth not moved to the migration destination")
+	}
+
+	failure := payloads.ErrorMigrateFailure{
+		NodeUUID:             source.uuid,
+		InstanceUUID:         workload.instanceUUID,
+		DestinationAgentUUID: dest.uuid,
+		Reason:               payloads.MigrateTransferFailure,
+	}
+	y, err := yaml.Marshal(&failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched.ErrorForward(source.uuid, ssntp.MigrateFailure, &ssntp.Frame{Payload: y})
+
+	if committedBandwidth(sched, source.uuid).ingressKbps != 6000 ||
+		committedBandwidth(sched, dest.uuid).ingressKbps != 0 {
+		t.Fatal("bandwidth not moved back to the source of the failed migration")
+	}
+}
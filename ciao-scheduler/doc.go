@@ -131,11 +131,118 @@ restart, or be stopped and updated and restarted, and launcher agents
 will simply reconnect and keep on continually updating the scheduler of
 any changes in their node statistics.
 
+High Availability
+
+The same property allows running a standby scheduler next to the active
+one.  SSNTP clients are given the URIs of both, through their SSNTP
+configuration's URI and URIs fields or the IP addresses and host names of
+the CA certificate, and connect to the first one that answers.  When the
+active scheduler dies they fail over to the standby, which rebuilds its
+compute and network node lists as the agents reconnect and send READY.
+Pending reservations and recently dispatched server group members are
+not carried over.  As clients always try the URIs in order, a failed
+scheduler should only be brought back once the standby has been stopped,
+or be moved to the end of the list, so that the cluster does not end up
+split between two schedulers.
+
 Fairness
 
-Ciao-scheduler currently implements an extremely trivial algorithm to
+By default ciao-scheduler implements an extremely trivial algorithm to
 prefer not using the most-recently-used compute node.  This is inexpensive
 and leads to sufficient spread of new workloads across a cluster.
 
+Scheduling Policies
+
+The policy used to choose between the nodes able to run a workload is
+selected at startup with the -policy flag:
+
+  first-fit     the default, first node that fits after the most recently
+                used one
+  bin-packing   node left with the least free memory, filling up nodes
+                before using new ones
+  spread        node with the largest proportion of free memory
+  least-loaded  node with the lowest load per CPU
+
+All but first-fit visit every node for each workload, trading some
+dispatch latency for better placement.
+
+Overcommit
+
+Whatever the policy, a node is only chosen if the VCPUs and memory already
+allocated to its instances, as reported by its launcher in READY, leave
+room for the workload within the configured overcommit ratios:
+
+  -cpu-overcommit  VCPUs per CPU online, 4.0 by default
+  -mem-overcommit  instance memory per MB of node memory, 1.5 by default
+
+A ratio of 0 removes the corresponding limit.  Memory overcommit does not
+relax the requirement that the node reports enough free memory for the
+workload.
+
+Reservations
+
+The memory and VCPUs claimed by a START are subtracted from its node
+straight away, but the node's next READY may well predate the instance.
+The scheduler therefore keeps a ledger of pending reservations, keyed by
+instance UUID, and subtracts them again from every READY until the
+instance appears in a STATS frame from its node.  Reservations are also
+dropped when the instance is deleted, fails to start or its node
+disconnects, and expire after -reservation-timeout, 2 minutes by default.
+
+Bandwidth
+
+A START may limit the ingress and egress bandwidth of the VNICs of its
+instance.  Launchers enforce these limits but do not report them, so the
+scheduler keeps a ledger of the bandwidth committed to each instance it
+dispatches, keyed by instance UUID, until the instance is deleted or fails
+to start.  A node is only chosen if the bandwidth committed to its
+instances leaves room for the workload within:
+
+  -node-ingress-kbps  ingress bandwidth of each node, no limit by default
+  -node-egress-kbps   egress bandwidth of each node, no limit by default
+
+A live migration moves the bandwidth of its instance to the destination
+node, and back to the source node if it fails.  The ledger is not
+persisted, instances dispatched before the scheduler started are not
+accounted for.
+
+Node Labels
+
+Operators label nodes with key/value pairs through the controller, which
+sends them to the node's launcher in a LabelNode command.  The launcher
+reports its labels in READY.  A START carrying a node selector is only
+placed on a node whose labels include every key/value pair of the selector.
+The scheduler forwards LabelNode commands to their node like EVACUATE.
+
+Server Groups
+
+A START may carry a server group hint naming the group, its policy and the
+nodes the controller knows its other members to be running on.  Members of
+an affinity group are placed on those nodes, members of an anti-affinity
+group on any other node.  The scheduler adds the nodes of members it has
+dispatched itself until they are deleted, as the controller only learns
+of them once they report their statistics.  A member being live migrated
+constrains both its source and destination nodes, and is moved to its
+destination once its source node no longer reports it.  When no node
+satisfies the policy but one would otherwise fit, the START fails with
+server_group_conflict rather than full_cloud.
+
+Network Inspection
+
+The scheduler forwards NetworkInspect commands to the compute node, network
+node or CNCI they name, and the NetworkReport events they reply with to the
+controllers.
+
+Distributed Routing
+
+The scheduler forwards TenantRoutes commands to the compute node or CNCI
+they name.
+
+Volume Resizing and Detaching
+
+The scheduler forwards ResizeVolume and DetachVolume commands to the compute
+node running the instance the volume is attached to, and DetachVolumeFailure
+errors to the controllers.
+
 */
 package main
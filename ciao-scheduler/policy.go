@@ -0,0 +1,160 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	firstFitPolicyName    = "first-fit"
+	binPackingPolicyName  = "bin-packing"
+	spreadPolicyName      = "spread"
+	leastLoadedPolicyName = "least-loaded"
+)
+
+// fitsFunc reports whether a workload can be started on a locked node.
+type fitsFunc func(node *nodeStat, workload *workResources) bool
+
+// schedulingPolicy decides which of the nodes capable of running a workload
+// the workload should be dispatched to.
+type schedulingPolicy interface {
+	// pickNode returns the chosen node, locked, together with its index
+	// in nodes, or nil if the workload fits nowhere.  mru and mruIndex
+	// identify the node picked by the previous call for the same list.
+	pickNode(nodes []*nodeStat, mru *nodeStat, mruIndex int,
+		workload *workResources, fits fitsFunc) (*nodeStat, int)
+}
+
+// firstFitPolicy picks the first node that can accommodate the workload,
+// starting the search just after the most recently used node.  It is cheap
+// and gives a reasonable spread of workloads across the cluster.
+type firstFitPolicy struct{}
+
+func (p firstFitPolicy) pickNode(nodes []*nodeStat, mru *nodeStat, mruIndex int,
+	workload *workResources, fits fitsFunc) (*nodeStat, int) {
+	/* First try nodes after the MRU */
+	if mruIndex != -1 && mruIndex < len(nodes)-1 {
+		for i, node := range nodes[mruIndex+1:] {
+			node.mutex.Lock()
+			if node == mru {
+				node.mutex.Unlock()
+				continue
+			}
+
+			if fits(node, workload) {
+				return node, mruIndex + 1 + i // locked nodeStat
+			}
+			node.mutex.Unlock()
+		}
+	}
+
+	/* Then try the whole list, including the MRU */
+	for i, node := range nodes {
+		node.mutex.Lock()
+		if fits(node, workload) {
+			return node, i // locked nodeStat
+		}
+		node.mutex.Unlock()
+	}
+
+	return nil, -1
+}
+
+// scoreFunc rates a locked node that is able to run a workload.  The node
+// with the highest score is chosen.
+type scoreFunc func(node *nodeStat, workload *workResources) float64
+
+// scoringPolicy visits every node, picking the one with the best score.
+type scoringPolicy struct {
+	score scoreFunc
+}
+
+type scoredNode struct {
+	index int
+	score float64
+}
+
+func (p scoringPolicy) pickNode(nodes []*nodeStat, mru *nodeStat, mruIndex int,
+	workload *workResources, fits fitsFunc) (*nodeStat, int) {
+	candidates := make([]scoredNode, 0, len(nodes))
+	for i, node := range nodes {
+		node.mutex.Lock()
+		if fits(node, workload) {
+			candidates = append(candidates, scoredNode{i, p.score(node, workload)})
+		}
+		node.mutex.Unlock()
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	// Nodes are unlocked while scoring so the state of our candidates
+	// may have changed by the time we get round to picking one.
+
+	for _, c := range candidates {
+		node := nodes[c.index]
+		node.mutex.Lock()
+		if fits(node, workload) {
+			return node, c.index // locked nodeStat
+		}
+		node.mutex.Unlock()
+	}
+
+	return nil, -1
+}
+
+// binPackingScore prefers the nodes that will have the least memory left
+// once the workload has started, filling up nodes before using new ones.
+func binPackingScore(node *nodeStat, workload *workResources) float64 {
+	return -float64(node.memAvailMB - workload.memReqMB)
+}
+
+// spreadScore prefers the nodes with the largest proportion of free memory,
+// spreading workloads evenly across the cluster.
+func spreadScore(node *nodeStat, workload *workResources) float64 {
+	if node.memTotalMB <= 0 {
+		return 0
+	}
+	return float64(node.memAvailMB) / float64(node.memTotalMB)
+}
+
+// leastLoadedScore prefers the nodes with the lowest load per CPU.
+func leastLoadedScore(node *nodeStat, workload *workResources) float64 {
+	cpus := node.cpus
+	if cpus <= 0 {
+		cpus = 1
+	}
+	return -float64(node.load) / float64(cpus)
+}
+
+func newSchedulingPolicy(name string) (schedulingPolicy, error) {
+	switch name {
+	case firstFitPolicyName:
+		return firstFitPolicy{}, nil
+	case binPackingPolicyName:
+		return scoringPolicy{binPackingScore}, nil
+	case spreadPolicyName:
+		return scoringPolicy{spreadScore}, nil
+	case leastLoadedPolicyName:
+		return scoringPolicy{leastLoadedScore}, nil
+	}
+
+	return nil, fmt.Errorf("unknown scheduling policy %q", name)
+}
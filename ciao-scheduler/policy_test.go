@@ -0,0 +1,99 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNewSchedulingPolicy(t *testing.T) {
+	for _, name := range []string{firstFitPolicyName, binPackingPolicyName,
+		spreadPolicyName, leastLoadedPolicyName} {
+		if _, err := newSchedulingPolicy(name); err != nil {
+			t.Errorf("Unable to create %s policy: %v", name, err)
+		}
+	}
+
+	if _, err := newSchedulingPolicy("random"); err == nil {
+		t.Error("Expected unknown policy to fail")
+	}
+}
+
+func pickWithPolicy(t *testing.T, name string, memMB int) *nodeStat {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	policy, err := newSchedulingPolicy(name)
+	if err != nil {
+		t.Fatalf("Unable to create %s policy: %v", name, err)
+	}
+	sched.policy = policy
+
+	// node 1: roomy and idle, node 2: tight and busy, node 3: too small
+	spinUpComputeNode(sched, 1, 8192)
+	spinUpComputeNode(sched, 2, 8192)
+	spinUpComputeNode(sched, 3, 8192)
+	sched.cnMap[fmt.Sprintf("%08d", 2)].memAvailMB = 1024
+	sched.cnMap[fmt.Sprintf("%08d", 2)].load = 8
+	sched.cnMap[fmt.Sprintf("%08d", 3)].memAvailMB = 128
+
+	var work = createStartWorkload(2, memMB, 0)
+	resources, err := sched.getWorkloadResources(work)
+	if err != nil {
+		t.Fatalf("bad workload resources: %v", err)
+	}
+
+	node := PickComputeNode(sched, "", &resources, false)
+	if node == nil {
+		t.Fatalf("%s: found no compute fit when one should exist", name)
+	}
+	node.mutex.Unlock()
+
+	return node
+}
+
+func TestPickComputeNodePolicies(t *testing.T) {
+	var policyTests = []struct {
+		policy   string
+		expected int
+	}{
+		{binPackingPolicyName, 2},
+		{spreadPolicyName, 1},
+		{leastLoadedPolicyName, 1},
+	}
+
+	for _, test := range policyTests {
+		node := pickWithPolicy(t, test.policy, 256)
+		expected := fmt.Sprintf("%08d", test.expected)
+		if node.uuid != expected {
+			t.Errorf("%s: expected node %s, got %s", test.policy, expected,
+				node.uuid)
+		}
+	}
+}
+
+func TestPickComputeNodeBinPackingOverflow(t *testing.T) {
+	// the tightest node cannot take the workload so we fall back to
+	// the next tightest.
+	node := pickWithPolicy(t, binPackingPolicyName, 2048)
+	if node.uuid != fmt.Sprintf("%08d", 1) {
+		t.Errorf("expected node %08d, got %s", 1, node.uuid)
+	}
+}
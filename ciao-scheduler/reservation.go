@@ -0,0 +1,105 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"time"
+
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/golang/glog"
+)
+
+const defaultReservationTimeout = 2 * time.Minute
+
+// The resources claimed by a START are only accounted for in a node's READY
+// statistics once its launcher has created the instance.  Until the instance
+// shows up in the node's STATS, they are held here, keyed by instance UUID,
+// and subtracted again from every READY the node sends.  Reservations for
+// instances which never show up are dropped once they expire.
+type reservation struct {
+	nodeUUID string
+	memReqMB int
+	vcpusReq int
+	expiry   time.Time
+}
+
+func (sched *ssntpSchedulerServer) addReservation(nodeUUID string, workload *workResources) {
+	sched.reservationMutex.Lock()
+	defer sched.reservationMutex.Unlock()
+
+	sched.reservations[workload.instanceUUID] = &reservation{
+		nodeUUID: nodeUUID,
+		memReqMB: workload.memReqMB,
+		vcpusReq: workload.vcpusReq,
+		expiry:   time.Now().Add(sched.reservationTimeout),
+	}
+}
+
+func (sched *ssntpSchedulerServer) deleteReservation(instanceUUID string) {
+	sched.reservationMutex.Lock()
+	defer sched.reservationMutex.Unlock()
+
+	delete(sched.reservations, instanceUUID)
+}
+
+// Drop the reservations of all the instances a node is reporting in STATS,
+// as its next READY accounts for their resources.
+func (sched *ssntpSchedulerServer) reconcileReservations(stats *payloads.Stat) {
+	sched.reservationMutex.Lock()
+	defer sched.reservationMutex.Unlock()
+
+	for _, instance := range stats.Instances {
+		delete(sched.reservations, instance.InstanceUUID)
+	}
+}
+
+// Drop the reservations of a departed node.
+func (sched *ssntpSchedulerServer) deleteNodeReservations(nodeUUID string) {
+	sched.reservationMutex.Lock()
+	defer sched.reservationMutex.Unlock()
+
+	for instance, r := range sched.reservations {
+		if r.nodeUUID == nodeUUID {
+			delete(sched.reservations, instance)
+		}
+	}
+}
+
+// Apply the outstanding reservations to the referenced, locked nodeStat
+// object, freshly updated from a READY, expiring those which are overdue.
+func (sched *ssntpSchedulerServer) applyReservations(node *nodeStat) {
+	sched.reservationMutex.Lock()
+	defer sched.reservationMutex.Unlock()
+
+	now := time.Now()
+	for instance, r := range sched.reservations {
+		if r.nodeUUID != node.uuid {
+			continue
+		}
+
+		if now.After(r.expiry) {
+			glog.Warningf("Reservation for instance %s on node %s expired",
+				instance, node.uuid)
+			delete(sched.reservations, instance)
+			continue
+		}
+
+		node.memAvailMB -= r.memReqMB
+		node.memAllocatedMB += r.memReqMB
+		node.vcpusAllocated += r.vcpusReq
+	}
+}
@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"testing"
+
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp"
+	"gopkg.in/yaml.v2"
+)
+
+// sendReady updates the node as if it had sent a READY reporting all of
+// its memory available.
+func sendReady(t *testing.T, sched *ssntpSchedulerServer, node *nodeStat) {
+	ready := payloads.Ready{
+		NodeUUID:       node.uuid,
+		MemTotalMB:     node.memTotalMB,
+		MemAvailableMB: node.memTotalMB,
+		CpusOnline:     node.cpus,
+	}
+
+	b, err := yaml.Marshal(&ready)
+	if err != nil {
+		t.Fatalf("unable to marshal READY: %v", err)
+	}
+
+	sched.updateNodeStat(node, ssntp.READY, &ssntp.Frame{Payload: b})
+}
+
+func reserveWorkload(t *testing.T, sched *ssntpSchedulerServer, memMB int) (*nodeStat, workResources) {
+	workload, err := sched.getWorkloadResources(createStartWorkload(2, memMB, 0))
+	if err != nil {
+		t.Fatalf("bad workload resources: %v", err)
+	}
+
+	node := PickComputeNode(sched, "", &workload, false)
+	if node == nil {
+		t.Fatal("found no compute fit")
+	}
+	sched.decrementResourceUsage(node, &workload)
+	sched.addReservation(node.uuid, &workload)
+	node.mutex.Unlock()
+
+	return node, workload
+}
+
+func TestReservationReconcile(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	spinUpComputeNode(sched, 1, 1000)
+
+	node, workload := reserveWorkload(t, sched, 600)
+	if node.memAvailMB != 400 {
+		t.Fatalf("expected 400MB available after dispatch, got %d", node.memAvailMB)
+	}
+
+	// the instance is not running yet, the reservation must survive READY
+	sendReady(t, sched, node)
+	if node.memAvailMB != 400 || node.vcpusAllocated != 2 {
+		t.Fatalf("reservation lost on READY: %dMB available, %d vcpus allocated",
+			node.memAvailMB, node.vcpusAllocated)
+	}
+
+	if sched.workloadFits(node, &workload) {
+		t.Fatal("node double booked before the instance was reported")
+	}
+
+	stats := payloads.Stat{
+		NodeUUID: node.uuid,
+		Instances: []payloads.InstanceStat{
+			{InstanceUUID: workload.instanceUUID},
+		},
+	}
+	sched.reconcileReservations(&stats)
+
+	if len(sched.reservations) != 0 {
+		t.Fatalf("expected no reservations after STATS, got %d", len(sched.reservations))
+	}
+
+	sendReady(t, sched, node)
+	if node.memAvailMB != 1000 {
+		t.Fatalf("expected READY to be trusted once instance is reported, got %dMB",
+			node.memAvailMB)
+	}
+}
+
+func TestReservationExpiry(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+	sched.reservationTimeout = -1
+
+	spinUpComputeNode(sched, 1, 1000)
+
+	node, _ := reserveWorkload(t, sched, 600)
+
+	sendReady(t, sched, node)
+	if node.memAvailMB != 1000 {
+		t.Fatalf("expired reservation applied, %dMB available", node.memAvailMB)
+	}
+
+	if len(sched.reservations) != 0 {
+		t.Fatalf("expected expired reservation to be dropped, got %d", len(sched.reservations))
+	}
+}
+
+func TestReservationNodeDisconnect(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	spinUpComputeNode(sched, 1, 1000)
+
+	node, _ := reserveWorkload(t, sched, 600)
+
+	DisconnectComputeNode(sched, node.uuid)
+
+	if len(sched.reservations) != 0 {
+		t.Fatalf("expected reservations of departed node to be dropped, got %d",
+			len(sched.reservations))
+	}
+}
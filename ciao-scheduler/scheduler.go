@@ -43,11 +43,35 @@ var heartbeat = flag.Bool("heartbeat", false, "Emit status heartbeat text")
 var logDir = "/var/lib/ciao/logs/scheduler"
 var configURI = flag.String("configuration-uri", "file:///etc/ciao/configuration.yaml",
 	"Cluster configuration URI")
+var policyName = flag.String("policy", firstFitPolicyName,
+	"Scheduling policy: first-fit, bin-packing, spread or least-loaded")
+var cpuOvercommit = flag.Float64("cpu-overcommit", 4.0,
+	"Ratio of VCPUs that may be allocated to CPUs online on a node, 0 for no limit")
+var memOvercommit = flag.Float64("mem-overcommit", 1.5,
+	"Ratio of instance memory that may be allocated to memory present on a node, 0 for no limit")
+var nodeIngressKbps = flag.Int("node-ingress-kbps", 0,
+	"Ingress bandwidth of a node that may be committed to its instances, 0 for no limit")
+var nodeEgressKbps = flag.Int("node-egress-kbps", 0,
+	"Egress bandwidth of a node that may be committed to its instances, 0 for no limit")
+var reservationTimeout = flag.Duration("reservation-timeout", defaultReservationTimeout,
+	"Time after which resources claimed by a START its node has not reported are released")
 
 type ssntpSchedulerServer struct {
 	// user config overrides ------------------------------------------
 	heartbeat  bool
 	cpuprofile string
+	policy     schedulingPolicy
+
+	// maximum ratios of allocated to physical resources, 0 = unlimited
+	cpuOvercommit float64
+	memOvercommit float64
+
+	// bandwidth of each node in kbps, 0 = unlimited
+	nodeIngressKbps int
+	nodeEgressKbps  int
+
+	// lifetime of resource reservations not yet reported by their node
+	reservationTimeout time.Duration
 
 	// ssntp ----------------------------------------------------------
 	config *ssntp.Config
@@ -74,30 +98,59 @@ type ssntpSchedulerServer struct {
 	nnMutex    sync.RWMutex // Rlock traversing map, Lock modifying map
 	nnMRU      *nodeStat
 	nnMRUIndex int
+
+	// Server Groups
+	groupMap         map[string]*serverGroupStat
+	instanceGroupMap map[string]string // instance UUID -> group UUID
+	groupMigrations  map[string]string // instance UUID -> destination node UUID
+	groupMutex       sync.Mutex
+
+	// Resources claimed by instances not yet reported by their node
+	reservations     map[string]*reservation // instance UUID -> reservation
+	reservationMutex sync.Mutex
+
+	// Bandwidth committed to the instances of each node
+	bandwidthClaims    map[string]*bandwidthClaim // instance UUID -> claim
+	committedBandwidth map[string]*nodeBandwidth  // node UUID -> claims
+	bandwidthMutex     sync.Mutex
 }
 
 func newSsntpSchedulerServer() *ssntpSchedulerServer {
 	return &ssntpSchedulerServer{
+		policy:        firstFitPolicy{},
 		controllerMap: make(map[string]*controllerStat),
 		cnMap:         make(map[string]*nodeStat),
 		cnMRUIndex:    -1,
 		nnMap:         make(map[string]*nodeStat),
 		nnMRUIndex:    -1,
+
+		groupMap:         make(map[string]*serverGroupStat),
+		instanceGroupMap: make(map[string]string),
+		groupMigrations:  make(map[string]string),
+
+		reservationTimeout: defaultReservationTimeout,
+		reservations:       make(map[string]*reservation),
+
+		bandwidthClaims:    make(map[string]*bandwidthClaim),
+		committedBandwidth: make(map[string]*nodeBandwidth),
 	}
 }
 
 type nodeStat struct {
-	mutex       sync.Mutex
-	status      ssntp.Status
-	uuid        string
-	memTotalMB  int
-	memAvailMB  int
-	diskTotalMB int
-	diskAvailMB int
-	load        int
-	cpus        int
-	isNetNode   bool
-	networks    []payloads.NetworkStat
+	mutex          sync.Mutex
+	status         ssntp.Status
+	uuid           string
+	memTotalMB     int
+	memAvailMB     int
+	memAllocatedMB int
+	diskTotalMB    int
+	diskAvailMB    int
+	load           int
+	cpus           int
+	vcpusAllocated int
+	isNetNode      bool
+	networks       []payloads.NetworkStat
+	labels         map[string]string
 }
 
 type controllerStatus uint8
@@ -325,6 +378,8 @@ func disconnectComputeNode(sched *ssntpSchedulerServer, uuid string) {
 		sched.cnMRUIndex = -1
 	}
 
+	sched.deleteNodeReservations(uuid)
+
 	go sched.sendNodeConnectionEvents(uuid, payloads.ComputeNode, false)
 }
 
@@ -377,6 +432,8 @@ func disconnectNetworkNode(sched *ssntpSchedulerServer, uuid string) {
 		sched.nnMRUIndex = -1
 	}
 
+	sched.deleteNodeReservations(uuid)
+
 	go sched.sendNodeConnectionEvents(uuid, payloads.NetworkNode, false)
 }
 
@@ -430,7 +487,12 @@ func (sched *ssntpSchedulerServer) updateNodeStat(node *nodeStat, status ssntp.S
 		node.diskAvailMB = stats.DiskAvailableMB
 		node.load = stats.Load
 		node.cpus = stats.CpusOnline
+		node.vcpusAllocated = stats.VCPUsAllocated
+		node.memAllocatedMB = stats.MemAllocatedMB
 		node.networks = stats.Networks
+		node.labels = stats.Labels
+
+		sched.applyReservations(node)
 
 		//any changes to the payloads.Ready struct should be
 		//accompanied by a change here
@@ -471,10 +533,18 @@ func (sched *ssntpSchedulerServer) StatusNotify(uuid string, status ssntp.Status
 
 type workResources struct {
 	instanceUUID string
+	vcpusReq     int
 	memReqMB     int
 	diskReqMB    int
 	networkNode  bool
 	physNets     []string
+	excludeNode  string
+	groupUUID    string
+	groupPolicy  payloads.ServerGroupPolicy
+	groupNodes   map[string]bool
+	nodeSelector map[string]string
+	ingressKbps  int
+	egressKbps   int
 }
 
 func (sched *ssntpSchedulerServer) getWorkloadResources(work *payloads.Start) (workload workResources, err error) {
@@ -484,6 +554,11 @@ func (sched *ssntpSchedulerServer) getWorkloadResources(work *payloads.Start) (w
 		reqValue := work.Start.RequestedResources[idx].Value
 		reqString := work.Start.RequestedResources[idx].ValueString
 
+		// vcpus:
+		if reqType == payloads.VCPUs {
+			workload.vcpusReq = reqValue
+		}
+
 		// memory:
 		if reqType == payloads.MemMB {
 			workload.memReqMB = reqValue
@@ -507,6 +582,14 @@ func (sched *ssntpSchedulerServer) getWorkloadResources(work *payloads.Start) (w
 
 		}
 
+		// bandwidth limits of each VNIC
+		if reqType == payloads.NetworkIngressKbps {
+			workload.ingressKbps = reqValue
+		}
+		if reqType == payloads.NetworkEgressKbps {
+			workload.egressKbps = reqValue
+		}
+
 		// network node physical networks
 		if workload.networkNode {
 			if reqType == payloads.PhysicalNetwork {
@@ -525,17 +608,30 @@ func (sched *ssntpSchedulerServer) getWorkloadResources(work *payloads.Start) (w
 	}
 
 	// validate the found resources
+	if workload.vcpusReq < 0 {
+		return workload, fmt.Errorf("invalid start payload resource demand: vcpus (%d) < 0, must be >= 0", workload.vcpusReq)
+	}
 	if workload.memReqMB <= 0 {
 		return workload, fmt.Errorf("invalid start payload resource demand: mem_mb (%d) <= 0, must be > 0", workload.memReqMB)
 	}
 	if workload.diskReqMB < 0 {
 		return workload, fmt.Errorf("invalid start payload local disk demand: disk MB (%d) < 0, must be >= 0", workload.diskReqMB)
 	}
+	if workload.ingressKbps < 0 || workload.egressKbps < 0 {
+		return workload, fmt.Errorf("invalid start payload bandwidth limits: ingress (%d) and egress (%d) kbps must be >= 0", workload.ingressKbps, workload.egressKbps)
+	}
 
 	// note the uuid
 	workload.instanceUUID = work.Start.InstanceUUID
 
-	return workload, nil
+	// a live migration must not land back on its source node
+	workload.excludeNode = work.Start.MigrateFrom
+
+	workload.nodeSelector = work.Start.NodeSelector
+
+	err = parseServerGroupHint(work.Start.ServerGroup, &workload)
+
+	return workload, err
 }
 
 func networkDemandsSatisfied(node *nodeStat, workload *workResources) bool {
@@ -562,13 +658,51 @@ func networkDemandsSatisfied(node *nodeStat, workload *workResources) bool {
 	return true
 }
 
-// Check resource demands are satisfiable by the referenced, locked nodeStat object
+// Check the referenced, locked nodeStat object carries all the labels
+// required by the workload's node selector.
+func nodeSelectorSatisfied(node *nodeStat, workload *workResources) bool {
+	for key, value := range workload.nodeSelector {
+		if label, ok := node.labels[key]; !ok || label != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check the VCPUs and memory already allocated on the referenced, locked
+// nodeStat object leave room for the workload within the overcommit ratios.
+// Nodes which have not reported their capacity are not limited.
+func (sched *ssntpSchedulerServer) overcommitSatisfied(node *nodeStat, workload *workResources) bool {
+	if sched.cpuOvercommit > 0 && node.cpus > 0 {
+		vcpusLimit := int(float64(node.cpus) * sched.cpuOvercommit)
+		if node.vcpusAllocated+workload.vcpusReq > vcpusLimit {
+			return false
+		}
+	}
+
+	if sched.memOvercommit > 0 && node.memTotalMB > 0 {
+		memLimitMB := int(float64(node.memTotalMB) * sched.memOvercommit)
+		if node.memAllocatedMB+workload.memReqMB > memLimitMB {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check resource demands are satisfiable by the referenced, locked nodeStat object.
+// Choosing between the nodes that fit is left to the scheduling policy.
 func (sched *ssntpSchedulerServer) workloadFits(node *nodeStat, workload *workResources) bool {
-	// simple scheduling policy == first fit
 	if node.memAvailMB >= workload.memReqMB &&
 		node.diskAvailMB >= workload.diskReqMB &&
 		node.status == ssntp.READY &&
-		networkDemandsSatisfied(node, workload) {
+		node.uuid != workload.excludeNode &&
+		sched.overcommitSatisfied(node, workload) &&
+		sched.bandwidthSatisfied(node, workload) &&
+		networkDemandsSatisfied(node, workload) &&
+		nodeSelectorSatisfied(node, workload) &&
+		serverGroupSatisfied(node, workload) {
 
 		return true
 	}
@@ -604,6 +738,22 @@ func (sched *ssntpSchedulerServer) getCommandConcentratorUUID(command ssntp.Comm
 		var cmd payloads.CommandReleasePublicIP
 		err := yaml.Unmarshal(payload, &cmd)
 		return cmd.ReleaseIP.ConcentratorUUID, err
+	case ssntp.SecurityGroups:
+		var cmd payloads.CommandSecurityGroups
+		err := yaml.Unmarshal(payload, &cmd)
+		return cmd.SecurityGroups.ConcentratorUUID, err
+	case ssntp.LoadBalancers:
+		var cmd payloads.CommandLoadBalancers
+		err := yaml.Unmarshal(payload, &cmd)
+		return cmd.LoadBalancers.ConcentratorUUID, err
+	case ssntp.PortForwards:
+		var cmd payloads.CommandPortForwards
+		err := yaml.Unmarshal(payload, &cmd)
+		return cmd.PortForwards.ConcentratorUUID, err
+	case ssntp.TenantDNS:
+		var cmd payloads.CommandTenantDNS
+		err := yaml.Unmarshal(payload, &cmd)
+		return cmd.TenantDNS.ConcentratorUUID, err
 	}
 }
 
@@ -676,6 +826,34 @@ func getWorkloadAgentUUID(sched *ssntpSchedulerServer, command ssntp.Command, pa
 		var cmd payloads.AttachVolume
 		err := yaml.Unmarshal(payload, &cmd)
 		return cmd.Attach.InstanceUUID, cmd.Attach.WorkloadAgentUUID, err
+	case ssntp.DetachVolume:
+		var cmd payloads.DetachVolume
+		err := yaml.Unmarshal(payload, &cmd)
+		return cmd.Detach.InstanceUUID, cmd.Detach.WorkloadAgentUUID, err
+	case ssntp.ResizeVolume:
+		var cmd payloads.ResizeVolume
+		err := yaml.Unmarshal(payload, &cmd)
+		return cmd.Resize.InstanceUUID, cmd.Resize.WorkloadAgentUUID, err
+	case ssntp.MIGRATE:
+		var cmd payloads.Migrate
+		err := yaml.Unmarshal(payload, &cmd)
+		return cmd.Migrate.InstanceUUID, cmd.Migrate.WorkloadAgentUUID, err
+	case ssntp.LabelNode:
+		var cmd payloads.LabelNode
+		err := yaml.Unmarshal(payload, &cmd)
+		return "", cmd.LabelNode.WorkloadAgentUUID, err
+	case ssntp.NetworkInspect:
+		var cmd payloads.NetworkInspect
+		err := yaml.Unmarshal(payload, &cmd)
+		return "", cmd.Inspect.NodeUUID, err
+	case ssntp.TenantRoutes:
+		var cmd payloads.CommandTenantRoutes
+		err := yaml.Unmarshal(payload, &cmd)
+		return "", cmd.TenantRoutes.NodeUUID, err
+	case ssntp.SecurityGroups:
+		var cmd payloads.CommandSecurityGroups
+		err := yaml.Unmarshal(payload, &cmd)
+		return "", cmd.SecurityGroups.NodeUUID, err
 	}
 }
 
@@ -695,9 +873,38 @@ func (sched *ssntpSchedulerServer) fwdCmdToComputeNode(command ssntp.Command, pa
 	return
 }
 
+// fwdSecurityGroups forwards a SecurityGroups command to the compute node
+// it is meant for, if any, and otherwise to its CNCI.
+func (sched *ssntpSchedulerServer) fwdSecurityGroups(payload []byte) (dest ssntp.ForwardDestination, instanceUUID string) {
+	var cmd payloads.CommandSecurityGroups
+	if err := yaml.Unmarshal(payload, &cmd); err == nil && cmd.SecurityGroups.NodeUUID != "" {
+		return sched.fwdCmdToComputeNode(ssntp.SecurityGroups, payload)
+	}
+
+	return sched.fwdCmdToCNCI(ssntp.SecurityGroups, payload), ""
+}
+
+// fwdMigrateToComputeNode forwards a MIGRATE command sent by the
+// destination launcher of a live migration to the launcher currently
+// running the instance.
+func (sched *ssntpSchedulerServer) fwdMigrateToComputeNode(agentUUID string, payload []byte) (dest ssntp.ForwardDestination, instanceUUID string) {
+	sched.cnMutex.RLock()
+	node := sched.cnMap[agentUUID]
+	sched.cnMutex.RUnlock()
+	if node == nil {
+		glog.Warningf("Ignoring MIGRATE command from unknown agent %s\n", agentUUID)
+		dest.SetDecision(ssntp.Discard)
+		return
+	}
+
+	return sched.fwdCmdToComputeNode(ssntp.MIGRATE, payload)
+}
+
 // Decrement resource claims for the referenced locked nodeStat object
 func (sched *ssntpSchedulerServer) decrementResourceUsage(node *nodeStat, workload *workResources) {
 	node.memAvailMB -= workload.memReqMB
+	node.memAllocatedMB += workload.memReqMB
+	node.vcpusAllocated += workload.vcpusReq
 }
 
 // Find suitable compute node, returning referenced to a locked nodeStat if found
@@ -711,36 +918,29 @@ func pickComputeNode(sched *ssntpSchedulerServer, controllerUUID string, workloa
 		return nil
 	}
 
-	/* First try nodes after the MRU */
-	if sched.cnMRUIndex != -1 && sched.cnMRUIndex < len(sched.cnList)-1 {
-		for i, node := range sched.cnList[sched.cnMRUIndex+1:] {
-			node.mutex.Lock()
-			if node == sched.cnMRU {
-				node.mutex.Unlock()
-				continue
-			}
+	sched.addServerGroupNodes(workload)
 
-			if sched.workloadFits(node, workload) == true {
-				sched.cnMRUIndex = sched.cnMRUIndex + 1 + i
-				sched.cnMRU = node
-				return node // locked nodeStat
-			}
-			node.mutex.Unlock()
-		}
+	node, index := sched.policy.pickNode(sched.cnList, sched.cnMRU, sched.cnMRUIndex,
+		workload, sched.workloadFits)
+	if node != nil {
+		sched.cnMRUIndex = index
+		sched.cnMRU = node
+		return node // locked nodeStat
 	}
 
-	/* Then try the whole list, including the MRU */
-	for i, node := range sched.cnList {
-		node.mutex.Lock()
-		if sched.workloadFits(node, workload) == true {
-			sched.cnMRUIndex = i
-			sched.cnMRU = node
-			return node // locked nodeStat
+	reason := payloads.FullCloud
+	if workload.groupPolicy != "" {
+		// distinguish a full cloud from a server group we cannot honour
+		relaxed := *workload
+		relaxed.groupPolicy = ""
+		node, _ = firstFitPolicy{}.pickNode(sched.cnList, nil, -1, &relaxed, sched.workloadFits)
+		if node != nil {
+			node.mutex.Unlock()
+			reason = payloads.ServerGroupConflict
 		}
-		node.mutex.Unlock()
 	}
 
-	sched.sendStartFailureError(controllerUUID, workload.instanceUUID, payloads.FullCloud, restart)
+	sched.sendStartFailureError(controllerUUID, workload.instanceUUID, reason, restart)
 	return nil
 }
 
@@ -755,33 +955,12 @@ func pickNetworkNode(sched *ssntpSchedulerServer, controllerUUID string, workloa
 		return nil
 	}
 
-	/* First try nodes after the MRU */
-	if sched.nnMRUIndex != -1 && sched.nnMRUIndex < len(sched.nnList)-1 {
-		for i, node := range sched.nnList[sched.nnMRUIndex+1:] {
-			node.mutex.Lock()
-			if node == sched.nnMRU {
-				node.mutex.Unlock()
-				continue
-			}
-
-			if sched.workloadFits(node, workload) == true {
-				sched.nnMRUIndex = sched.nnMRUIndex + 1 + i
-				sched.nnMRU = node
-				return node // locked nodeStat
-			}
-			node.mutex.Unlock()
-		}
-	}
-
-	/* Then try the whole list, including the MRU */
-	for i, node := range sched.nnList {
-		node.mutex.Lock()
-		if sched.workloadFits(node, workload) == true {
-			sched.nnMRUIndex = i
-			sched.nnMRU = node
-			return node // locked nodeStat
-		}
-		node.mutex.Unlock()
+	node, index := sched.policy.pickNode(sched.nnList, sched.nnMRU, sched.nnMRUIndex,
+		workload, sched.workloadFits)
+	if node != nil {
+		sched.nnMRUIndex = index
+		sched.nnMRU = node
+		return node // locked nodeStat
 	}
 
 	sched.sendStartFailureError(controllerUUID, workload.instanceUUID, payloads.NoNetworkNodes, restart)
@@ -815,12 +994,13 @@ func startWorkload(sched *ssntpSchedulerServer, controllerUUID string, payload [
 	}
 
 	if targetNode != nil {
-		//TODO: mark the targetNode as unavailable until next stats / READY checkin?
-		//	or is subtracting mem demand sufficiently speculative enough?
-		//	Goal is to have spread, not schedule "too many" workloads back
-		//	to back on the same targetNode, but also not add latency to dispatch and
-		//	hopefully not queue when all nodes have just started a workload.
+		// Hold on to the claimed resources until the node reports the
+		// instance, so that READY frames sent in the meantime do not
+		// hand them out again.
 		sched.decrementResourceUsage(targetNode, &workload)
+		sched.addReservation(targetNode.uuid, &workload)
+		sched.addServerGroupMember(&workload, targetNode.uuid)
+		sched.addBandwidthClaim(&workload, targetNode.uuid)
 
 		dest.AddRecipient(targetNode.uuid)
 		targetNode.mutex.Unlock()
@@ -836,6 +1016,13 @@ func (sched *ssntpSchedulerServer) CommandForward(controllerUUID string, command
 	payload := frame.Payload
 	instanceUUID := ""
 
+	// MIGRATE is the only command originating from an agent
+	if command == ssntp.MIGRATE {
+		dest, instanceUUID = sched.fwdMigrateToComputeNode(controllerUUID, payload)
+		glog.V(2).Infof("%s command processed for instance %s\n", command, instanceUUID)
+		return
+	}
+
 	sched.controllerMutex.RLock()
 	defer sched.controllerMutex.RUnlock()
 	if sched.controllerMap[controllerUUID] == nil {
@@ -862,16 +1049,37 @@ func (sched *ssntpSchedulerServer) CommandForward(controllerUUID string, command
 	case ssntp.START:
 		dest, instanceUUID = startWorkload(sched, controllerUUID, payload)
 	case ssntp.DELETE:
-		fallthrough
+		dest, instanceUUID = sched.fwdCmdToComputeNode(command, payload)
+		sched.deleteServerGroupMember(instanceUUID)
+		sched.deleteReservation(instanceUUID)
+		sched.deleteBandwidthClaim(instanceUUID)
 	case ssntp.AttachVolume:
 		fallthrough
+	case ssntp.ResizeVolume:
+		fallthrough
+	case ssntp.DetachVolume:
+		fallthrough
 	case ssntp.EVACUATE:
 		fallthrough
 	case ssntp.Restore:
+		fallthrough
+	case ssntp.LabelNode:
+		fallthrough
+	case ssntp.NetworkInspect:
+		fallthrough
+	case ssntp.TenantRoutes:
 		dest, instanceUUID = sched.fwdCmdToComputeNode(command, payload)
+	case ssntp.SecurityGroups:
+		dest, instanceUUID = sched.fwdSecurityGroups(payload)
 	case ssntp.AssignPublicIP:
 		fallthrough
 	case ssntp.ReleasePublicIP:
+		fallthrough
+	case ssntp.LoadBalancers:
+		fallthrough
+	case ssntp.PortForwards:
+		fallthrough
+	case ssntp.TenantDNS:
 		dest = sched.fwdCmdToCNCI(command, payload)
 	default:
 		dest.SetDecision(ssntp.Discard)
@@ -887,6 +1095,19 @@ func (sched *ssntpSchedulerServer) CommandNotify(uuid string, command ssntp.Comm
 	// Currently all commands are handled by CommandForward, the SSNTP command forwader,
 	// or directly by role defined forwarding rules.
 	glog.V(2).Infof("COMMAND %v from %s\n", command, uuid)
+
+	// STATS are forwarded to the controllers, but also tell us which
+	// instances a node now accounts for.
+	if command == ssntp.STATS {
+		var stats payloads.Stat
+		err := yaml.Unmarshal(frame.Payload, &stats)
+		if err != nil {
+			glog.Errorf("Bad STATS yaml from %s: %s\n", uuid, err)
+			return
+		}
+		sched.reconcileReservations(&stats)
+		sched.completeServerGroupMigrations(&stats)
+	}
 }
 
 func (sched *ssntpSchedulerServer) EventForward(uuid string, event ssntp.Event, frame *ssntp.Frame) (dest ssntp.ForwardDestination) {
@@ -913,8 +1134,54 @@ func (sched *ssntpSchedulerServer) EventNotify(uuid string, event ssntp.Event, f
 	glog.V(2).Infof("EVENT %v from %s\n", event, uuid)
 }
 
+// ErrorForward sends MigrateFailure errors to all controllers and to the
+// node that was waiting to receive the migrated instance, so that it can
+// tear down its incoming instance.
+func (sched *ssntpSchedulerServer) ErrorForward(uuid string, error ssntp.Error, frame *ssntp.Frame) (dest ssntp.ForwardDestination) {
+	if error != ssntp.MigrateFailure {
+		dest.SetDecision(ssntp.Discard)
+		return
+	}
+
+	var failure payloads.ErrorMigrateFailure
+	err := yaml.Unmarshal(frame.Payload, &failure)
+	if err != nil {
+		glog.Errorf("Bad %s error yaml from %s: %s\n", error, uuid, err)
+		dest.SetDecision(ssntp.Discard)
+		return
+	}
+
+	sched.abortServerGroupMigration(failure.InstanceUUID)
+	sched.abortBandwidthMigration(failure.InstanceUUID)
+
+	sched.controllerMutex.RLock()
+	for _, c := range sched.controllerList {
+		dest.AddRecipient(c.uuid)
+	}
+	sched.controllerMutex.RUnlock()
+
+	if failure.DestinationAgentUUID != "" {
+		dest.AddRecipient(failure.DestinationAgentUUID)
+	}
+
+	return dest
+}
+
 func (sched *ssntpSchedulerServer) ErrorNotify(uuid string, error ssntp.Error, frame *ssntp.Frame) {
 	glog.V(2).Infof("ERROR %v from %s\n", error, uuid)
+
+	if error == ssntp.StartFailure {
+		var failure payloads.ErrorStartFailure
+		err := yaml.Unmarshal(frame.Payload, &failure)
+		if err != nil {
+			glog.Errorf("Bad %s error yaml from %s: %s\n", error, uuid, err)
+			return
+		}
+		// the instance never ran, it no longer constrains its group
+		sched.releaseServerGroupMember(failure.InstanceUUID)
+		sched.deleteReservation(failure.InstanceUUID)
+		sched.releaseBandwidthClaim(failure.InstanceUUID)
+	}
 }
 
 func setLimits() {
@@ -1167,6 +1434,22 @@ func setSSNTPForwardRules(sched *ssntpSchedulerServer) {
 			Operand:        ssntp.Restore,
 			CommandForward: sched,
 		},
+		{ // all LabelNode command are processed by the Command forwarder
+			Operand:        ssntp.LabelNode,
+			CommandForward: sched,
+		},
+		{ // all NetworkInspect command are processed by the Command forwarder
+			Operand:        ssntp.NetworkInspect,
+			CommandForward: sched,
+		},
+		{ // all TenantRoutes command are processed by the Command forwarder
+			Operand:        ssntp.TenantRoutes,
+			CommandForward: sched,
+		},
+		{ // all NetworkReport events go to all Controllers
+			Operand: ssntp.NetworkReport,
+			Dest:    ssntp.Controller,
+		},
 		{ // all TenantAdded events are processed by the Event forwarder
 			Operand:      ssntp.TenantAdded,
 			EventForward: sched,
@@ -1179,10 +1462,22 @@ func setSSNTPForwardRules(sched *ssntpSchedulerServer) {
 			Operand:        ssntp.AttachVolume,
 			CommandForward: sched,
 		},
+		{ // all ResizeVolume command are processed by the Command forwarder
+			Operand:        ssntp.ResizeVolume,
+			CommandForward: sched,
+		},
 		{ // all AttachVolumeFailure errors go to all Controllers
 			Operand: ssntp.AttachVolumeFailure,
 			Dest:    ssntp.Controller,
 		},
+		{ // all DetachVolume command are processed by the Command forwarder
+			Operand:        ssntp.DetachVolume,
+			CommandForward: sched,
+		},
+		{ // all DetachVolumeFailure errors go to all Controllers
+			Operand: ssntp.DetachVolumeFailure,
+			Dest:    ssntp.Controller,
+		},
 		{ // all AssignPublicIP commands are processed by the Command forwarder
 			Operand:        ssntp.AssignPublicIP,
 			CommandForward: sched,
@@ -1191,6 +1486,30 @@ func setSSNTPForwardRules(sched *ssntpSchedulerServer) {
 			Operand:        ssntp.ReleasePublicIP,
 			CommandForward: sched,
 		},
+		{ // all SecurityGroups commands are processed by the Command forwarder
+			Operand:        ssntp.SecurityGroups,
+			CommandForward: sched,
+		},
+		{ // all LoadBalancers commands are processed by the Command forwarder
+			Operand:        ssntp.LoadBalancers,
+			CommandForward: sched,
+		},
+		{ // all PortForwards commands are processed by the Command forwarder
+			Operand:        ssntp.PortForwards,
+			CommandForward: sched,
+		},
+		{ // all TenantDNS commands are processed by the Command forwarder
+			Operand:        ssntp.TenantDNS,
+			CommandForward: sched,
+		},
+		{ // all MIGRATE commands are processed by the Command forwarder
+			Operand:        ssntp.MIGRATE,
+			CommandForward: sched,
+		},
+		{ // all MigrateFailure errors are processed by the Error forwarder
+			Operand:      ssntp.MigrateFailure,
+			ErrorForward: sched,
+		},
 	}
 }
 
@@ -1217,6 +1536,36 @@ func configSchedulerServer() (sched *ssntpSchedulerServer) {
 	sched.cpuprofile = *cpuprofile
 	sched.heartbeat = *heartbeat
 
+	policy, err := newSchedulingPolicy(*policyName)
+	if err != nil {
+		glog.Errorf("Invalid scheduler configuration: %v", err)
+		return nil
+	}
+	sched.policy = policy
+	glog.Infof("Using %s scheduling policy", *policyName)
+
+	if *cpuOvercommit < 0 || *memOvercommit < 0 {
+		glog.Errorf("Invalid scheduler configuration: negative overcommit ratio")
+		return nil
+	}
+	sched.cpuOvercommit = *cpuOvercommit
+	sched.memOvercommit = *memOvercommit
+	glog.Infof("CPU overcommit ratio %.2f, memory overcommit ratio %.2f",
+		sched.cpuOvercommit, sched.memOvercommit)
+
+	if *nodeIngressKbps < 0 || *nodeEgressKbps < 0 {
+		glog.Errorf("Invalid scheduler configuration: negative node bandwidth")
+		return nil
+	}
+	sched.nodeIngressKbps = *nodeIngressKbps
+	sched.nodeEgressKbps = *nodeEgressKbps
+
+	if *reservationTimeout <= 0 {
+		glog.Errorf("Invalid scheduler configuration: non-positive reservation timeout")
+		return nil
+	}
+	sched.reservationTimeout = *reservationTimeout
+
 	toggleDebug(sched)
 
 	sched.config = &ssntp.Config{
@@ -222,6 +222,125 @@ func TestPickComputeNode(t *testing.T) {
 	}
 }
 
+func TestPickComputeNodeMigration(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	var work = createStartWorkload(2, 256, 10000)
+	work.Start.MigrateFrom = fmt.Sprintf("%08d", 1)
+	resources, err := sched.getWorkloadResources(work)
+	if err != nil || resources.excludeNode != work.Start.MigrateFrom {
+		t.Fatalf("bad workload resources, excluded node %s", resources.excludeNode)
+	}
+
+	// the only compute node is the migration source
+	spinUpComputeNodeLarge(sched, 1)
+	node := PickComputeNode(sched, "", &resources, true)
+	if node != nil {
+		t.Error("found compute fit on migration source node")
+	}
+
+	// add a migration destination
+	spinUpComputeNodeLarge(sched, 2)
+	node = PickComputeNode(sched, "", &resources, true)
+	if node == nil {
+		t.Fatal("found no compute fit when one should exist")
+	}
+	if node.uuid == work.Start.MigrateFrom {
+		t.Errorf("migration scheduled on source node %s", node.uuid)
+	}
+	node.mutex.Unlock()
+}
+
+func TestPickComputeNodeOvercommit(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+	sched.cpuOvercommit = 2.0
+	sched.memOvercommit = 1.0
+
+	var work = createStartWorkload(2, 256, 0)
+	resources, err := sched.getWorkloadResources(work)
+	if err != nil || resources.vcpusReq != 2 {
+		t.Fatalf("bad workload resources, vcpus %d", resources.vcpusReq)
+	}
+
+	// 4 CPUs allow 8 VCPUs to be allocated, ie. 4 workloads
+	spinUpComputeNodeLarge(sched, 1)
+	for i := 0; i < 4; i++ {
+		node := PickComputeNode(sched, "", &resources, false)
+		if node == nil {
+			t.Fatalf("found no compute fit for workload %d", i)
+		}
+		sched.decrementResourceUsage(node, &resources)
+		node.mutex.Unlock()
+	}
+
+	node := PickComputeNode(sched, "", &resources, false)
+	if node != nil {
+		t.Fatalf("found compute fit with %d VCPUs allocated", node.vcpusAllocated)
+	}
+
+	// no VCPU limit, but memory allocations are limited to memory present
+	sched.cpuOvercommit = 0
+	node = PickComputeNode(sched, "", &resources, false)
+	if node == nil {
+		t.Fatal("found no compute fit without CPU overcommit limit")
+	}
+	node.memAllocatedMB = node.memTotalMB
+	node.mutex.Unlock()
+
+	node = PickComputeNode(sched, "", &resources, false)
+	if node != nil {
+		t.Fatalf("found compute fit with %d MB allocated", node.memAllocatedMB)
+	}
+
+	sched.memOvercommit = 1.5
+	node = PickComputeNode(sched, "", &resources, false)
+	if node == nil {
+		t.Fatal("found no compute fit with memory overcommit")
+	}
+	node.mutex.Unlock()
+}
+
+func TestPickComputeNodeSelector(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	var work = createStartWorkload(2, 256, 0)
+	work.Start.NodeSelector = map[string]string{"ssd": "true"}
+	resources, err := sched.getWorkloadResources(work)
+	if err != nil {
+		t.Fatalf("bad workload resources: %v", err)
+	}
+
+	spinUpComputeNodeLarge(sched, 1)
+	spinUpComputeNodeLarge(sched, 2)
+	sched.cnMap[fmt.Sprintf("%08d", 1)].labels = map[string]string{"ssd": "false"}
+
+	node := PickComputeNode(sched, "", &resources, false)
+	if node != nil {
+		t.Fatalf("found compute fit on %s without matching labels", node.uuid)
+	}
+
+	target := fmt.Sprintf("%08d", 2)
+	sched.cnMap[target].labels = map[string]string{"ssd": "true", "rack": "r12"}
+
+	node = PickComputeNode(sched, "", &resources, false)
+	if node == nil {
+		t.Fatal("found no compute fit with matching labels")
+	}
+	if node.uuid != target {
+		t.Fatalf("workload placed on %s, expected %s", node.uuid, target)
+	}
+	node.mutex.Unlock()
+}
+
 func benchmarkPickComputeNode(b *testing.B, nodecount int) {
 	sched = configSchedulerServer()
 	if sched == nil {
@@ -761,6 +880,12 @@ func TestGetWorkloadAgentUUID(t *testing.T) {
 		{ssntp.EVACUATE, []byte(testutil.EvacuateYaml), "", testutil.AgentUUID},
 		{ssntp.Restore, []byte(testutil.RestoreYaml), "", testutil.AgentUUID},
 		{ssntp.AttachVolume, []byte(testutil.AttachVolumeYaml), testutil.InstanceUUID, testutil.AgentUUID},
+		{ssntp.DetachVolume, []byte(testutil.DetachVolumeYaml), testutil.InstanceUUID, testutil.AgentUUID},
+		{ssntp.ResizeVolume, []byte(testutil.ResizeVolumeYaml), testutil.InstanceUUID, testutil.AgentUUID},
+		{ssntp.MIGRATE, []byte(testutil.LiveMigrateYaml), testutil.InstanceUUID, testutil.AgentUUID},
+		{ssntp.NetworkInspect, []byte(testutil.NetworkInspectYaml), "", testutil.CNCIUUID},
+		{ssntp.TenantRoutes, []byte(testutil.TenantRoutesYaml), "", testutil.AgentUUID},
+		{ssntp.SecurityGroups, []byte(testutil.NodeSecurityGroupsYaml), "", testutil.AgentUUID},
 	}
 	for _, test := range stringTests {
 		instanceUUID, agentUUID, _ := GetWorkloadAgentUUID(sched, test.cmd, test.yaml)
@@ -772,3 +897,79 @@ func TestGetWorkloadAgentUUID(t *testing.T) {
 		}
 	}
 }
+
+func TestGetCommandConcentratorUUID(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	var stringTests = []struct {
+		cmd  ssntp.Command
+		yaml []byte
+	}{
+		{ssntp.AssignPublicIP, []byte(testutil.AssignIPYaml)},
+		{ssntp.ReleasePublicIP, []byte(testutil.ReleaseIPYaml)},
+		{ssntp.SecurityGroups, []byte(testutil.SecurityGroupsYaml)},
+		{ssntp.LoadBalancers, []byte(testutil.LoadBalancersYaml)},
+		{ssntp.PortForwards, []byte(testutil.PortForwardsYaml)},
+		{ssntp.TenantDNS, []byte(testutil.TenantDNSYaml)},
+	}
+	for _, test := range stringTests {
+		fwd := sched.fwdCmdToCNCI(test.cmd, test.yaml)
+		recipients := fwd.Recipients()
+		if fwd.Decision() != ssntp.Forward || len(recipients) != 1 ||
+			recipients[0] != testutil.CNCIUUID {
+			t.Errorf("%s not forwarded to CNCI %s: %v", test.cmd, testutil.CNCIUUID, recipients)
+		}
+	}
+}
+
+func TestFwdSecurityGroups(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	var stringTests = []struct {
+		yaml     string
+		expected string
+	}{
+		{testutil.SecurityGroupsYaml, testutil.CNCIUUID},
+		{testutil.NodeSecurityGroupsYaml, testutil.AgentUUID},
+	}
+	for _, test := range stringTests {
+		fwd, _ := sched.fwdSecurityGroups([]byte(test.yaml))
+		recipients := fwd.Recipients()
+		if fwd.Decision() != ssntp.Forward || len(recipients) != 1 ||
+			recipients[0] != test.expected {
+			t.Errorf("SecurityGroups not forwarded to %s: %v", test.expected, recipients)
+		}
+	}
+}
+
+func TestGetWorkloadResourcesBandwidth(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	work := createStartWorkload(2, 256, 0)
+	work.Start.RequestedResources = append(work.Start.RequestedResources,
+		payloads.RequestedResource{Type: payloads.NetworkIngressKbps, Value: 10000},
+		payloads.RequestedResource{Type: payloads.NetworkEgressKbps, Value: 1000})
+
+	workload, err := sched.getWorkloadResources(work)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if workload.ingressKbps != 10000 || workload.egressKbps != 1000 {
+		t.Errorf("unexpected bandwidth limits %d %d", workload.ingressKbps, workload.egressKbps)
+	}
+
+	work.Start.RequestedResources[len(work.Start.RequestedResources)-1].Value = -1
+	if _, err := sched.getWorkloadResources(work); err == nil {
+		t.Error("expected negative bandwidth limit to fail")
+	}
+}
@@ -407,6 +407,22 @@ func TestReconnects(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	// the new scheduler knows the cluster from the reconnecting agents
+	waitForAgent(testutil.AgentUUID, nil)
+	waitForNetAgent(testutil.NetAgentUUID, nil)
+
+	server.cnMutex.RLock()
+	if len(server.cnList) != 1 || server.cnList[0].uuid != testutil.AgentUUID {
+		t.Errorf("compute node list not rebuilt: %d nodes", len(server.cnList))
+	}
+	server.cnMutex.RUnlock()
+
+	server.nnMutex.RLock()
+	if len(server.nnList) != 1 || server.nnList[0].uuid != testutil.NetAgentUUID {
+		t.Errorf("network node list not rebuilt: %d nodes", len(server.nnList))
+	}
+	server.nnMutex.RUnlock()
 }
 
 func TestTenantAdded(t *testing.T) {
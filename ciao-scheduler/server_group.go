@@ -0,0 +1,191 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/ciao-project/ciao/payloads"
+)
+
+// The controller tells us where the running members of a server group are,
+// but only learns of a new member's node once the member reports its
+// statistics.  Members dispatched in quick succession are therefore
+// tracked here, per group, until they are deleted.  A member being live
+// migrated stays on its source node until the migration completes, but its
+// destination node is also taken into account in the meantime.
+type serverGroupStat struct {
+	members map[string]string // instance UUID -> node UUID
+}
+
+func parseServerGroupHint(hint *payloads.ServerGroupHint, workload *workResources) error {
+	if hint == nil {
+		return nil
+	}
+
+	switch hint.Policy {
+	case payloads.Affinity, payloads.AntiAffinity:
+	default:
+		return fmt.Errorf("invalid start payload server group policy: %q", hint.Policy)
+	}
+
+	if hint.GroupUUID == "" {
+		return fmt.Errorf("invalid start payload server group: missing group uuid")
+	}
+
+	workload.groupUUID = hint.GroupUUID
+	workload.groupPolicy = hint.Policy
+	workload.groupNodes = make(map[string]bool)
+	for _, node := range hint.MemberNodes {
+		workload.groupNodes[node] = true
+	}
+
+	return nil
+}
+
+// Add the nodes to which we have recently dispatched the other members of
+// the workload's server group to those reported by the controller.
+func (sched *ssntpSchedulerServer) addServerGroupNodes(workload *workResources) {
+	if workload.groupUUID == "" {
+		return
+	}
+
+	sched.groupMutex.Lock()
+	defer sched.groupMutex.Unlock()
+
+	group := sched.groupMap[workload.groupUUID]
+	if group == nil {
+		return
+	}
+
+	for instance, node := range group.members {
+		if instance != workload.instanceUUID {
+			workload.groupNodes[node] = true
+			if dest, ok := sched.groupMigrations[instance]; ok {
+				workload.groupNodes[dest] = true
+			}
+		}
+	}
+}
+
+func (sched *ssntpSchedulerServer) addServerGroupMember(workload *workResources, nodeUUID string) {
+	if workload.groupUUID == "" {
+		return
+	}
+
+	sched.groupMutex.Lock()
+	defer sched.groupMutex.Unlock()
+
+	group := sched.groupMap[workload.groupUUID]
+	if group == nil {
+		group = &serverGroupStat{members: make(map[string]string)}
+		sched.groupMap[workload.groupUUID] = group
+	}
+	sched.instanceGroupMap[workload.instanceUUID] = workload.groupUUID
+
+	// a migrating member keeps running on its source node
+	if workload.excludeNode != "" {
+		group.members[workload.instanceUUID] = workload.excludeNode
+		sched.groupMigrations[workload.instanceUUID] = nodeUUID
+		return
+	}
+
+	group.members[workload.instanceUUID] = nodeUUID
+	delete(sched.groupMigrations, workload.instanceUUID)
+}
+
+func (sched *ssntpSchedulerServer) deleteServerGroupMember(instanceUUID string) {
+	sched.groupMutex.Lock()
+	defer sched.groupMutex.Unlock()
+
+	groupUUID, ok := sched.instanceGroupMap[instanceUUID]
+	if !ok {
+		return
+	}
+	delete(sched.instanceGroupMap, instanceUUID)
+	delete(sched.groupMigrations, instanceUUID)
+
+	group := sched.groupMap[groupUUID]
+	if group == nil {
+		return
+	}
+	delete(group.members, instanceUUID)
+	if len(group.members) == 0 {
+		delete(sched.groupMap, groupUUID)
+	}
+}
+
+// Release a member which failed to start.  A member which failed to start
+// on the destination of a live migration still runs on its source node.
+func (sched *ssntpSchedulerServer) releaseServerGroupMember(instanceUUID string) {
+	sched.groupMutex.Lock()
+	_, migrating := sched.groupMigrations[instanceUUID]
+	delete(sched.groupMigrations, instanceUUID)
+	sched.groupMutex.Unlock()
+
+	if !migrating {
+		sched.deleteServerGroupMember(instanceUUID)
+	}
+}
+
+// Forget the destination of a member whose live migration failed.
+func (sched *ssntpSchedulerServer) abortServerGroupMigration(instanceUUID string) {
+	sched.groupMutex.Lock()
+	defer sched.groupMutex.Unlock()
+
+	delete(sched.groupMigrations, instanceUUID)
+}
+
+// Move the migrating members no longer reported by their source node in
+// its STATS to their destination node, as their migration has completed.
+func (sched *ssntpSchedulerServer) completeServerGroupMigrations(stats *payloads.Stat) {
+	sched.groupMutex.Lock()
+	defer sched.groupMutex.Unlock()
+
+	if len(sched.groupMigrations) == 0 {
+		return
+	}
+
+	reported := make(map[string]bool)
+	for _, instance := range stats.Instances {
+		reported[instance.InstanceUUID] = true
+	}
+
+	for instance, dest := range sched.groupMigrations {
+		group := sched.groupMap[sched.instanceGroupMap[instance]]
+		if group == nil || group.members[instance] != stats.NodeUUID || reported[instance] {
+			continue
+		}
+
+		group.members[instance] = dest
+		delete(sched.groupMigrations, instance)
+	}
+}
+
+// Check the referenced, locked nodeStat object can host the workload
+// without violating the policy of the workload's server group.
+func serverGroupSatisfied(node *nodeStat, workload *workResources) bool {
+	switch workload.groupPolicy {
+	case payloads.Affinity:
+		// the first member of the group goes anywhere
+		return len(workload.groupNodes) == 0 || workload.groupNodes[node.uuid]
+	case payloads.AntiAffinity:
+		return !workload.groupNodes[node.uuid]
+	}
+
+	return true
+}
@@ -0,0 +1,232 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+func serverGroupMember(t *testing.T, index int, policy payloads.ServerGroupPolicy,
+	memberNodes ...string) workResources {
+	work := createStartWorkload(2, 256, 0)
+	work.Start.InstanceUUID = fmt.Sprintf("%08d-0000-0000-0000-000000000000", index)
+	work.Start.ServerGroup = &payloads.ServerGroupHint{
+		GroupUUID:   testutil.ServerGroupUUID,
+		Policy:      policy,
+		MemberNodes: memberNodes,
+	}
+
+	resources, err := sched.getWorkloadResources(work)
+	if err != nil {
+		t.Fatalf("bad workload resources: %v", err)
+	}
+
+	return resources
+}
+
+// pickServerGroupMember picks a node for a group member and records the
+// placement, as startWorkload would.
+func pickServerGroupMember(sched *ssntpSchedulerServer, workload *workResources) *nodeStat {
+	node := PickComputeNode(sched, "", workload, false)
+	if node == nil {
+		return nil
+	}
+	sched.addServerGroupMember(workload, node.uuid)
+	node.mutex.Unlock()
+
+	return node
+}
+
+func TestPickComputeNodeAntiAffinity(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	for i := 1; i <= 3; i++ {
+		spinUpComputeNodeLarge(sched, i)
+	}
+
+	used := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		member := serverGroupMember(t, i, payloads.AntiAffinity)
+		node := pickServerGroupMember(sched, &member)
+		if node == nil {
+			t.Fatalf("found no compute fit for member %d", i)
+		}
+		if used[node.uuid] {
+			t.Fatalf("member %d placed on already used node %s", i, node.uuid)
+		}
+		used[node.uuid] = true
+	}
+
+	member := serverGroupMember(t, 3, payloads.AntiAffinity)
+	if node := PickComputeNode(sched, "", &member, false); node != nil {
+		t.Fatalf("found compute fit for fourth member on %s", node.uuid)
+	}
+
+	// once a member has been deleted its node can be used again
+	sched.deleteServerGroupMember(fmt.Sprintf("%08d-0000-0000-0000-000000000000", 1))
+	member = serverGroupMember(t, 3, payloads.AntiAffinity)
+	if node := pickServerGroupMember(sched, &member); node == nil {
+		t.Fatal("found no compute fit after member deleted")
+	}
+
+	// as is the node of a member which failed to start
+	failure := payloads.ErrorStartFailure{
+		InstanceUUID: fmt.Sprintf("%08d-0000-0000-0000-000000000000", 2),
+		Reason:       payloads.FullComputeNode,
+	}
+	y, err := yaml.Marshal(&failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched.ErrorNotify("", ssntp.StartFailure, &ssntp.Frame{Payload: y})
+	member = serverGroupMember(t, 4, payloads.AntiAffinity)
+	if node := pickServerGroupMember(sched, &member); node == nil {
+		t.Fatal("found no compute fit after member failed to start")
+	}
+}
+
+func TestPickComputeNodeAffinity(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	for i := 1; i <= 3; i++ {
+		spinUpComputeNodeLarge(sched, i)
+	}
+
+	// members already running on node 2 are reported by the controller
+	target := fmt.Sprintf("%08d", 2)
+	for i := 0; i < 3; i++ {
+		member := serverGroupMember(t, i, payloads.Affinity, target)
+		node := pickServerGroupMember(sched, &member)
+		if node == nil {
+			t.Fatalf("found no compute fit for member %d", i)
+		}
+		if node.uuid != target {
+			t.Fatalf("member %d placed on %s, expected %s", i, node.uuid, target)
+		}
+	}
+
+	// the group's node is no longer usable
+	sched.cnMap[target].memAvailMB = 0
+	member := serverGroupMember(t, 3, payloads.Affinity, target)
+	if node := PickComputeNode(sched, "", &member, false); node != nil {
+		t.Fatalf("found compute fit for member on %s", node.uuid)
+	}
+}
+
+func TestServerGroupMigration(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	for i := 1; i <= 3; i++ {
+		spinUpComputeNodeLarge(sched, i)
+	}
+
+	member := serverGroupMember(t, 0, payloads.AntiAffinity)
+	source := pickServerGroupMember(sched, &member)
+	if source == nil {
+		t.Fatal("found no compute fit")
+	}
+
+	member.excludeNode = source.uuid
+	dest := pickServerGroupMember(sched, &member)
+	if dest == nil || dest == source {
+		t.Fatal("found no compute fit for migration")
+	}
+
+	// both nodes are used by the member while it is migrating
+	other := serverGroupMember(t, 1, payloads.AntiAffinity)
+	node := pickServerGroupMember(sched, &other)
+	if node == nil || node == source || node == dest {
+		t.Fatal("member placed on node of migrating member")
+	}
+	sched.deleteServerGroupMember(other.instanceUUID)
+
+	// the source node still runs the instance
+	stats := payloads.Stat{
+		NodeUUID: source.uuid,
+		Instances: []payloads.InstanceStat{
+			{InstanceUUID: member.instanceUUID},
+		},
+	}
+	sched.completeServerGroupMigrations(&stats)
+	if sched.groupMap[testutil.ServerGroupUUID].members[member.instanceUUID] != source.uuid {
+		t.Fatal("member moved before its migration completed")
+	}
+
+	// the migration completed once the source no longer reports it
+	stats.Instances = nil
+	sched.completeServerGroupMigrations(&stats)
+	if sched.groupMap[testutil.ServerGroupUUID].members[member.instanceUUID] != dest.uuid {
+		t.Fatal("member not moved to its migration destination")
+	}
+	if len(sched.groupMigrations) != 0 {
+		t.Fatalf("expected no migrations, got %d", len(sched.groupMigrations))
+	}
+
+	// a failed migration leaves the member on its source node
+	member.excludeNode = dest.uuid
+	if node := pickServerGroupMember(sched, &member); node == nil {
+		t.Fatal("found no compute fit for second migration")
+	}
+
+	failure := payloads.ErrorMigrateFailure{
+		NodeUUID:     dest.uuid,
+		InstanceUUID: member.instanceUUID,
+		Reason:       payloads.MigrateTransferFailure,
+	}
+	y, err := yaml.Marshal(&failure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sched.ErrorForward(dest.uuid, ssntp.MigrateFailure, &ssntp.Frame{Payload: y})
+
+	if sched.groupMap[testutil.ServerGroupUUID].members[member.instanceUUID] != dest.uuid ||
+		len(sched.groupMigrations) != 0 {
+		t.Fatal("member moved by failed migration")
+	}
+}
+
+func TestGetWorkloadResourcesServerGroup(t *testing.T) {
+	sched = configSchedulerServer()
+	if sched == nil {
+		t.Fatal("unable to configure test scheduler")
+	}
+
+	work := createStartWorkload(2, 256, 0)
+	work.Start.ServerGroup = &payloads.ServerGroupHint{
+		GroupUUID: testutil.ServerGroupUUID,
+		Policy:    "packed",
+	}
+
+	if _, err := sched.getWorkloadResources(work); err == nil {
+		t.Error("expected invalid server group policy to fail")
+	}
+}
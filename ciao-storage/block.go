@@ -15,7 +15,14 @@
 package storage
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/ssntp/uuid"
 )
 
 var (
@@ -50,3 +57,164 @@ type BlockDevice struct {
 	Tag       string // arbitrary text identifier
 	Size      int    // size in GiB
 }
+
+// VolumeTypes maps the names of the volume types of a cluster to the
+// block drivers storing their volumes.  The default volume type, used by
+// the volumes that have no type, has an empty name.
+type VolumeTypes map[string]BlockDriver
+
+// NewBlockDriver returns the block driver selected in the storage section
+// of the cluster configuration.  Ceph is used when no driver is selected.
+func NewBlockDriver(conf payloads.ConfigureStorage) (BlockDriver, error) {
+	return newBlockDriver(payloads.ConfigureVolumeType{
+		BlockDriver:    conf.BlockDriver,
+		CephID:         conf.CephID,
+		LVMVolumeGroup: conf.LVMVolumeGroup,
+		LVMThinPool:    conf.LVMThinPool,
+		VolumePath:     conf.VolumePath,
+	})
+}
+
+// NewVolumeTypes returns the block drivers of the default volume type and
+// of the named volume types of the storage section of the cluster
+// configuration.
+func NewVolumeTypes(conf payloads.ConfigureStorage) (VolumeTypes, error) {
+	driver, err := NewBlockDriver(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	types := VolumeTypes{"": driver}
+
+	for _, vt := range conf.VolumeTypes {
+		if vt.Name == "" {
+			return nil, fmt.Errorf("Volume types must be named")
+		}
+
+		if _, ok := types[vt.Name]; ok {
+			return nil, fmt.Errorf("Volume type %s defined twice", vt.Name)
+		}
+
+		if vt.CephID == "" {
+			vt.CephID = conf.CephID
+		}
+
+		driver, err := newBlockDriver(vt)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid volume type %s: %v", vt.Name, err)
+		}
+
+		types[vt.Name] = driver
+	}
+
+	return types, nil
+}
+
+// Driver returns the block driver of a volume type.
+func (v VolumeTypes) Driver(volumeType string) (BlockDriver, error) {
+	driver, ok := v[volumeType]
+	if !ok {
+		return nil, fmt.Errorf("Unknown volume type %s", volumeType)
+	}
+
+	return driver, nil
+}
+
+func newBlockDriver(conf payloads.ConfigureVolumeType) (BlockDriver, error) {
+	switch conf.BlockDriver {
+	case "", payloads.CephBlockDriver:
+		return CephDriver{ID: conf.CephID, Pool: conf.CephPool}, nil
+	case payloads.LVMBlockDriver:
+		if conf.LVMVolumeGroup == "" || conf.LVMThinPool == "" {
+			return nil, fmt.Errorf("lvm_volume_group and lvm_thin_pool must be set")
+		}
+		return LVMDriver{
+			VolumeGroup: conf.LVMVolumeGroup,
+			ThinPool:    conf.LVMThinPool,
+		}, nil
+	case payloads.FileBlockDriver:
+		if conf.VolumePath == "" {
+			return nil, fmt.Errorf("volume_path must be set")
+		}
+		return FileDriver{Path: conf.VolumePath}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown block driver %s", conf.BlockDriver)
+}
+
+// bytesToGiB converts a size to GiB, rounding up unless the size is a
+// multiple of 1GiB
+func bytesToGiB(bytes uint64) int {
+	res := bytes / (1024 * 1024 * 1024)
+	rem := bytes % (1024 * 1024 * 1024)
+	if rem == 0 {
+		return int(res)
+	}
+	return int(res + 1)
+}
+
+// isValidSnapshotUUID checks for the Ciao standard snapshot uuid form of
+// {UUID}@{UUID}
+func isValidSnapshotUUID(snapshotUUID string) error {
+	UUIDs := strings.Split(snapshotUUID, "@")
+	if len(UUIDs) != 2 {
+		return fmt.Errorf("missing '@'")
+	}
+	_, e1 := uuid.Parse(UUIDs[0])
+	_, e2 := uuid.Parse(UUIDs[1])
+	if e1 != nil || e2 != nil {
+		return fmt.Errorf("uuid not of form \"{UUID}@{UUID}\"")
+	}
+
+	return nil
+}
+
+// newVolumeUUID returns the UUID of a new volume, generating one if none
+// was requested
+func newVolumeUUID(volumeUUID string) (string, error) {
+	if volumeUUID == "" {
+		return uuid.Generate().String(), nil
+	}
+
+	_, err := uuid.Parse(volumeUUID)
+	if err != nil {
+		return "", fmt.Errorf("invalid UUID supplied for volume ID")
+	}
+
+	return volumeUUID, nil
+}
+
+// runCmd runs a command, returning its output or an error including the
+// output of the command
+func runCmd(name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("Error when running: %v: %v: %s", cmd.Args, err, out)
+	}
+	return out, nil
+}
+
+// imageSize returns the virtual size, in bytes, of an image file
+func imageSize(imagePath string) (uint64, error) {
+	cmd := exec.Command("qemu-img", "info", "--output=json", imagePath)
+	data, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("Error when running: %v: %v", cmd.Args, err)
+	}
+
+	return parseImageSize(data)
+}
+
+// parseImageSize parses the virtual size from the output of qemu-img info
+func parseImageSize(data []byte) (uint64, error) {
+	info := struct {
+		VirtualSize uint64 `json:"virtual-size"`
+	}{}
+	err := json.Unmarshal(data, &info)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse output from qemu-img info: %v", err)
+	}
+
+	return info.VirtualSize, nil
+}
@@ -0,0 +1,134 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ciao-project/ciao/payloads"
+)
+
+func TestNewBlockDriver(t *testing.T) {
+	tests := []struct {
+		conf   payloads.ConfigureStorage
+		driver BlockDriver
+	}{
+		{payloads.ConfigureStorage{CephID: "ciao"}, CephDriver{ID: "ciao"}},
+		{payloads.ConfigureStorage{
+			BlockDriver: payloads.CephBlockDriver,
+			CephID:      "ciao",
+		}, CephDriver{ID: "ciao"}},
+		{payloads.ConfigureStorage{
+			BlockDriver:    payloads.LVMBlockDriver,
+			LVMVolumeGroup: "ciao",
+			LVMThinPool:    "volumes",
+		}, LVMDriver{VolumeGroup: "ciao", ThinPool: "volumes"}},
+		{payloads.ConfigureStorage{
+			BlockDriver: payloads.FileBlockDriver,
+			VolumePath:  "/var/lib/ciao/volumes",
+		}, FileDriver{Path: "/var/lib/ciao/volumes"}},
+	}
+
+	for _, test := range tests {
+		driver, err := NewBlockDriver(test.conf)
+		if err != nil {
+			t.Errorf("unexpected error for %+v: %v", test.conf, err)
+			continue
+		}
+		if !reflect.DeepEqual(driver, test.driver) {
+			t.Errorf("expected %+v, got %+v", test.driver, driver)
+		}
+	}
+
+	invalid := []payloads.ConfigureStorage{
+		{BlockDriver: payloads.LVMBlockDriver, LVMVolumeGroup: "ciao"},
+		{BlockDriver: payloads.FileBlockDriver},
+		{BlockDriver: "nfs"},
+	}
+
+	for _, conf := range invalid {
+		if _, err := NewBlockDriver(conf); err == nil {
+			t.Errorf("expected error for %+v", conf)
+		}
+	}
+}
+
+func TestNewVolumeTypes(t *testing.T) {
+	conf := payloads.ConfigureStorage{
+		CephID: "ciao",
+		VolumeTypes: []payloads.ConfigureVolumeType{
+			{
+				Name:        "fast-ssd",
+				BlockDriver: payloads.CephBlockDriver,
+				CephPool:    "ssd",
+			},
+			{
+				Name:           "local",
+				BlockDriver:    payloads.LVMBlockDriver,
+				LVMVolumeGroup: "ciao",
+				LVMThinPool:    "volumes",
+			},
+		},
+	}
+
+	types, err := NewVolumeTypes(conf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := VolumeTypes{
+		"":         CephDriver{ID: "ciao"},
+		"fast-ssd": CephDriver{ID: "ciao", Pool: "ssd"},
+		"local":    LVMDriver{VolumeGroup: "ciao", ThinPool: "volumes"},
+	}
+	if !reflect.DeepEqual(types, expected) {
+		t.Errorf("expected %+v, got %+v", expected, types)
+	}
+
+	driver, err := types.Driver("fast-ssd")
+	if err != nil || driver != expected["fast-ssd"] {
+		t.Errorf("unexpected driver %+v for fast-ssd: %v", driver, err)
+	}
+
+	if _, err = types.Driver("bulk"); err == nil {
+		t.Errorf("expected error for unknown volume type")
+	}
+
+	invalid := [][]payloads.ConfigureVolumeType{
+		{{BlockDriver: payloads.CephBlockDriver}},
+		{{Name: "local", BlockDriver: payloads.LVMBlockDriver}},
+		{{Name: "ssd"}, {Name: "ssd"}},
+	}
+
+	for _, vts := range invalid {
+		conf.VolumeTypes = vts
+		if _, err := NewVolumeTypes(conf); err == nil {
+			t.Errorf("expected error for %+v", vts)
+		}
+	}
+}
+
+func TestParseImageSize(t *testing.T) {
+	size, err := parseImageSize([]byte(`{"virtual-size": 2147483648, "filename": "clear.img", "format": "raw"}`))
+	if err != nil || size != 2147483648 {
+		t.Errorf("expected 2147483648, got %d: %v", size, err)
+	}
+
+	_, err = parseImageSize([]byte("not json"))
+	if err == nil {
+		t.Errorf("expected error for invalid output")
+	}
+}
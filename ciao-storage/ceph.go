@@ -30,6 +30,22 @@ import (
 type CephDriver struct {
 	// ID is the cephx user ID to use
 	ID string
+
+	// Pool is the pool containing the rbd images, the rbd pool if empty
+	Pool string
+}
+
+func (d CephDriver) pool() string {
+	if d.Pool == "" {
+		return "rbd"
+	}
+	return d.Pool
+}
+
+// ImageSpec returns the specification of the rbd image of a volume
+// understood by qemu.
+func (d CephDriver) ImageSpec(volumeUUID string) string {
+	return fmt.Sprintf("rbd:%s/%s:id=%s", d.pool(), volumeUUID, d.ID)
 }
 
 func (d CephDriver) getBlockDeviceSizeGiB(volumeUUID string) (int, error) {
@@ -39,24 +55,14 @@ func (d CephDriver) getBlockDeviceSizeGiB(volumeUUID string) (int, error) {
 		return 0, err
 	}
 
-	// When converting to GiB round up unless we've got a multiple of 1GiB
-	res := bytes / (1024 * 1024 * 1024)
-	rem := bytes % (1024 * 1024 * 1024)
-	if rem == 0 {
-		return int(res), nil
-	}
-	return int(res + 1), nil
+	return bytesToGiB(bytes), nil
 }
 
 // CreateBlockDevice will create a rbd image in the ceph cluster.
 func (d CephDriver) CreateBlockDevice(volumeUUID string, imagePath string, size int) (BlockDevice, error) {
-	if volumeUUID == "" {
-		volumeUUID = uuid.Generate().String()
-	} else {
-		_, err := uuid.Parse(volumeUUID)
-		if err != nil {
-			return BlockDevice{}, fmt.Errorf("invalid UUID supplied for volume ID")
-		}
+	volumeUUID, err := newVolumeUUID(volumeUUID)
+	if err != nil {
+		return BlockDevice{}, err
 	}
 
 	var cmd *exec.Cmd
@@ -65,11 +71,10 @@ func (d CephDriver) CreateBlockDevice(volumeUUID string, imagePath string, size
 	// Currently the kernel rdb client only supports layering but in the future more feaures
 	// should be added as they are enabled in the kernel.
 	if imagePath != "" {
-		rbdStr := fmt.Sprintf("rbd:rbd/%s:id=%s", volumeUUID, d.ID)
-		cmd = exec.Command("qemu-img", "convert", "-O", "rbd", imagePath, rbdStr)
+		cmd = exec.Command("qemu-img", "convert", "-O", "rbd", imagePath, d.ImageSpec(volumeUUID))
 	} else {
 		// create an empty volume
-		cmd = exec.Command("rbd", "--id", d.ID, "--image-feature", "layering", "create", "--size", strconv.Itoa(size)+"G", volumeUUID)
+		cmd = exec.Command("rbd", d.args("--image-feature", "layering", "create", "--size", strconv.Itoa(size)+"G", volumeUUID)...)
 	}
 
 	out, err := cmd.CombinedOutput()
@@ -86,7 +91,7 @@ func (d CephDriver) CreateBlockDeviceFromSnapshot(volumeUUID string, snapshotID
 
 	var cmd *exec.Cmd
 
-	cmd = exec.Command("rbd", "--id", d.ID, "clone", volumeUUID+"@"+snapshotID, ID)
+	cmd = exec.Command("rbd", d.args("clone", volumeUUID+"@"+snapshotID, ID)...)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -105,14 +110,14 @@ func (d CephDriver) CreateBlockDeviceFromSnapshot(volumeUUID string, snapshotID
 // CreateBlockDeviceSnapshot creates and protects the snapshot with the provided name
 func (d CephDriver) CreateBlockDeviceSnapshot(volumeUUID string, snapshotID string) error {
 	var cmd *exec.Cmd
-	cmd = exec.Command("rbd", "--id", d.ID, "snap", "create", volumeUUID+"@"+snapshotID)
+	cmd = exec.Command("rbd", d.args("snap", "create", volumeUUID+"@"+snapshotID)...)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Error when running: %v: %v: %s", cmd.Args, err, out)
 	}
 
-	cmd = exec.Command("rbd", "--id", d.ID, "snap", "protect", volumeUUID+"@"+snapshotID)
+	cmd = exec.Command("rbd", d.args("snap", "protect", volumeUUID+"@"+snapshotID)...)
 
 	out, err = cmd.CombinedOutput()
 	if err != nil {
@@ -128,7 +133,7 @@ func (d CephDriver) CopyBlockDevice(volumeUUID string) (BlockDevice, error) {
 
 	var cmd *exec.Cmd
 
-	cmd = exec.Command("rbd", "--id", d.ID, "cp", volumeUUID, ID)
+	cmd = exec.Command("rbd", d.args("cp", volumeUUID, ID)...)
 
 	out, err := cmd.CombinedOutput()
 	if err != nil {
@@ -146,7 +151,7 @@ func (d CephDriver) CopyBlockDevice(volumeUUID string) (BlockDevice, error) {
 
 // DeleteBlockDevice will remove a rbd image from the ceph cluster.
 func (d CephDriver) DeleteBlockDevice(volumeUUID string) error {
-	cmd := exec.Command("rbd", "--id", d.ID, "rm", volumeUUID)
+	cmd := exec.Command("rbd", d.args("rm", volumeUUID)...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Error when running: %v: %v: %s", cmd.Args, err, out)
@@ -158,13 +163,13 @@ func (d CephDriver) DeleteBlockDevice(volumeUUID string) error {
 func (d CephDriver) DeleteBlockDeviceSnapshot(volumeUUID string, snapshotID string) error {
 	var cmd *exec.Cmd
 
-	cmd = exec.Command("rbd", "--id", d.ID, "snap", "unprotect", volumeUUID+"@"+snapshotID)
+	cmd = exec.Command("rbd", d.args("snap", "unprotect", volumeUUID+"@"+snapshotID)...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Error when running: %v: %v: %s", cmd.Args, err, out)
 	}
 
-	cmd = exec.Command("rbd", "--id", d.ID, "snap", "rm", volumeUUID+"@"+snapshotID)
+	cmd = exec.Command("rbd", d.args("snap", "rm", volumeUUID+"@"+snapshotID)...)
 	out, err = cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("Error when running: %v: %v: %s", cmd.Args, err, out)
@@ -174,7 +179,7 @@ func (d CephDriver) DeleteBlockDeviceSnapshot(volumeUUID string, snapshotID stri
 
 // GetBlockDeviceSize returns the number of bytes used by the block device
 func (d CephDriver) GetBlockDeviceSize(volumeUUID string) (uint64, error) {
-	args := append(d.getCredentials(), "info", "--format", "json", volumeUUID)
+	args := d.args("info", "--format", "json", volumeUUID)
 	cmd := exec.Command("rbd", args...)
 	data, err := cmd.Output()
 	if err != nil {
@@ -203,10 +208,17 @@ func (d CephDriver) getCredentials() []string {
 	return args
 }
 
+// args returns the arguments of an rbd command operating on the images of
+// the pool of the driver
+func (d CephDriver) args(args ...string) []string {
+	cmdArgs := append(d.getCredentials(), "--pool", d.pool())
+	return append(cmdArgs, args...)
+}
+
 // MapVolumeToNode maps a ceph volume to a rbd device on a node.  The
 // path to the new device is returned if the mapping succeeds.
 func (d CephDriver) MapVolumeToNode(volumeUUID string) (string, error) {
-	args := append(d.getCredentials(), "map", volumeUUID)
+	args := d.args("map", volumeUUID)
 	cmd := exec.Command("rbd", args...)
 	data, err := cmd.Output()
 	if err != nil {
@@ -220,8 +232,14 @@ func (d CephDriver) MapVolumeToNode(volumeUUID string) (string, error) {
 }
 
 // UnmapVolumeFromNode unmaps a ceph volume from a local device on a node.
+// It accepts the UUID of the volume or the path to its device.
 func (d CephDriver) UnmapVolumeFromNode(volumeUUID string) error {
-	args := append(d.getCredentials(), "unmap", volumeUUID)
+	spec := volumeUUID
+	if !strings.HasPrefix(volumeUUID, "/dev/") {
+		spec = d.pool() + "/" + volumeUUID
+	}
+
+	args := append(d.getCredentials(), "unmap", spec)
 	cmd := exec.Command("rbd", args...)
 
 	out, err := cmd.CombinedOutput()
@@ -231,7 +249,8 @@ func (d CephDriver) UnmapVolumeFromNode(volumeUUID string) error {
 	return nil
 }
 
-// GetVolumeMapping returns a map of volumeUUID to mapped devices.
+// GetVolumeMapping returns a map of volumeUUID to mapped devices.  Only
+// the images of the pool of the driver are returned.
 func (d CephDriver) GetVolumeMapping() (map[string][]string, error) {
 	args := append(d.getCredentials(), "showmapped", "--format", "json")
 	cmd := exec.Command("rbd", args...)
@@ -243,11 +262,18 @@ func (d CephDriver) GetVolumeMapping() (map[string][]string, error) {
 		return nil, fmt.Errorf("Error when running: %v: %v", cmd.Args, err)
 	}
 
+	return d.parseVolumeMapping(data)
+}
+
+// parseVolumeMapping returns the images of the pool of the driver listed
+// by rbd showmapped
+func (d CephDriver) parseVolumeMapping(data []byte) (map[string][]string, error) {
 	vmap := map[string]struct {
+		Pool   string `json:"pool"`
 		Name   string `json:"name"`
 		Device string `json:"device"`
 	}{}
-	err = json.Unmarshal([]byte(data), &vmap)
+	err := json.Unmarshal(data, &vmap)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to parse output from rbd show mapped: %v", err)
 	}
@@ -255,6 +281,9 @@ func (d CephDriver) GetVolumeMapping() (map[string][]string, error) {
 	volumeDevMap := make(map[string][]string)
 
 	for _, v := range vmap {
+		if v.Pool != d.pool() {
+			continue
+		}
 		volumeDevMap[v.Name] = append(volumeDevMap[v.Name], v.Device)
 	}
 
@@ -264,22 +293,12 @@ func (d CephDriver) GetVolumeMapping() (map[string][]string, error) {
 // IsValidSnapshotUUID returns true if the uuid matches the ciao/ceph expected
 // form of {UUID}@{UUID}
 func (d CephDriver) IsValidSnapshotUUID(snapshotUUID string) error {
-	UUIDs := strings.Split(snapshotUUID, "@")
-	if len(UUIDs) != 2 {
-		return fmt.Errorf("missing '@'")
-	}
-	_, e1 := uuid.Parse(UUIDs[0])
-	_, e2 := uuid.Parse(UUIDs[1])
-	if e1 != nil || e2 != nil {
-		return fmt.Errorf("uuid not of form \"{UUID}@{UUID}\"")
-	}
-
-	return nil
+	return isValidSnapshotUUID(snapshotUUID)
 }
 
 // Resize the underlying rbd image. Only extending is permitted. Returns the new size in GiB.
 func (d CephDriver) Resize(volumeUUID string, sizeGiB int) (int, error) {
-	args := append(d.getCredentials(), "resize", volumeUUID, "--no-progress", "-s", fmt.Sprintf("%dG", sizeGiB))
+	args := d.args("resize", volumeUUID, "--no-progress", "-s", fmt.Sprintf("%dG", sizeGiB))
 	cmd := exec.Command("rbd", args...)
 
 	out, err := cmd.CombinedOutput()
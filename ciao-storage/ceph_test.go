@@ -41,3 +41,36 @@ func TestCephIsValidSnapshotUUID(t *testing.T) {
 		t.Errorf("expected nil, got \"%s\"", err)
 	}
 }
+
+func TestCephImageSpec(t *testing.T) {
+	spec := cephDriver.ImageSpec("a2dec44c-e1b5-40c0-a2b1-bc700d12cfde")
+	if spec != "rbd:rbd/a2dec44c-e1b5-40c0-a2b1-bc700d12cfde:id=unittest" {
+		t.Errorf("unexpected image spec %s", spec)
+	}
+
+	driver := CephDriver{ID: "unittest", Pool: "ssd"}
+	spec = driver.ImageSpec("a2dec44c-e1b5-40c0-a2b1-bc700d12cfde")
+	if spec != "rbd:ssd/a2dec44c-e1b5-40c0-a2b1-bc700d12cfde:id=unittest" {
+		t.Errorf("unexpected image spec %s", spec)
+	}
+}
+
+func TestCephParseVolumeMapping(t *testing.T) {
+	data := []byte(`{"0":{"pool":"rbd","name":"a","snap":"-","device":"/dev/rbd0"},
+		"1":{"pool":"ssd","name":"b","snap":"-","device":"/dev/rbd1"}}`)
+
+	driver := CephDriver{ID: "unittest", Pool: "ssd"}
+	volumeDevMap, err := driver.parseVolumeMapping(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(volumeDevMap) != 1 || len(volumeDevMap["b"]) != 1 || volumeDevMap["b"][0] != "/dev/rbd1" {
+		t.Errorf("unexpected mapping %v", volumeDevMap)
+	}
+
+	_, err = driver.parseVolumeMapping([]byte("-"))
+	if err == nil {
+		t.Errorf("expected error for invalid output")
+	}
+}
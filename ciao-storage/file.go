@@ -0,0 +1,352 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ciao-project/ciao/ssntp/uuid"
+)
+
+const qcow2Ext = ".qcow2"
+
+// nbdLock serializes the mappings of volumes to the nbd devices of a node
+var nbdLock sync.Mutex
+
+// FileDriver maintains context for the file driver interface.  Volumes are
+// sparse qcow2 files in a directory, named after their UUID.  Snapshots are
+// copies of their volume and clones are qcow2 files backed by a snapshot,
+// so a snapshot cannot be deleted while it has clones.  A volume is mapped
+// to a node by connecting it to a nbd device with qemu-nbd, so the
+// directory must be local to, or shared through NFS with, the nodes using
+// it.
+type FileDriver struct {
+	// Path is the directory containing the volume files
+	Path string
+}
+
+func (d FileDriver) volumePath(volumeUUID string) string {
+	return filepath.Join(d.Path, volumeUUID+qcow2Ext)
+}
+
+func (d FileDriver) snapshotPath(volumeUUID string, snapshotID string) string {
+	return filepath.Join(d.Path, snapshotName(volumeUUID, snapshotID)+qcow2Ext)
+}
+
+// qcow2Header returns the virtual size and the backing file of a qcow2
+// file, read from its header so that files in use can be inspected.
+func qcow2Header(path string) (uint64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	header := struct {
+		Magic             uint32
+		Version           uint32
+		BackingFileOffset uint64
+		BackingFileSize   uint32
+		ClusterBits       uint32
+		Size              uint64
+	}{}
+	err = binary.Read(f, binary.BigEndian, &header)
+	if err != nil {
+		return 0, "", fmt.Errorf("Unable to read header of %s: %v", path, err)
+	}
+
+	if header.Magic != 0x514649fb {
+		return 0, "", fmt.Errorf("%s is not a qcow2 file", path)
+	}
+
+	if header.BackingFileOffset == 0 {
+		return header.Size, "", nil
+	}
+
+	backing := make([]byte, header.BackingFileSize)
+	_, err = f.ReadAt(backing, int64(header.BackingFileOffset))
+	if err != nil && err != io.EOF {
+		return 0, "", fmt.Errorf("Unable to read backing file of %s: %v", path, err)
+	}
+
+	return header.Size, string(backing), nil
+}
+
+func (d FileDriver) getBlockDeviceSizeGiB(volumeUUID string) (int, error) {
+	size, err := d.GetBlockDeviceSize(volumeUUID)
+	if err != nil {
+		return 0, err
+	}
+
+	return bytesToGiB(size), nil
+}
+
+// CreateBlockDevice will create a qcow2 file in the volume directory.
+func (d FileDriver) CreateBlockDevice(volumeUUID string, imagePath string, size int) (BlockDevice, error) {
+	volumeUUID, err := newVolumeUUID(volumeUUID)
+	if err != nil {
+		return BlockDevice{}, err
+	}
+
+	path := d.volumePath(volumeUUID)
+
+	if imagePath != "" {
+		_, err = runCmd("qemu-img", "convert", "-O", "qcow2", imagePath, path)
+	} else {
+		_, err = runCmd("qemu-img", "create", "-f", "qcow2", path, strconv.Itoa(size)+"G")
+	}
+	if err != nil {
+		return BlockDevice{}, err
+	}
+
+	if imagePath != "" {
+		size, err = d.getBlockDeviceSizeGiB(volumeUUID)
+		if err != nil {
+			_ = os.Remove(path)
+			return BlockDevice{}, fmt.Errorf("Error when querying block device size: %v", err)
+		}
+	}
+
+	return BlockDevice{ID: volumeUUID, Size: size}, nil
+}
+
+// CreateBlockDeviceFromSnapshot will create a block device derived from the previously created snapshot.
+func (d FileDriver) CreateBlockDeviceFromSnapshot(volumeUUID string, snapshotID string) (BlockDevice, error) {
+	ID := uuid.Generate().String()
+
+	_, err := runCmd("qemu-img", "create", "-f", "qcow2", "-b", d.snapshotPath(volumeUUID, snapshotID),
+		"-F", "qcow2", d.volumePath(ID))
+	if err != nil {
+		return BlockDevice{}, err
+	}
+
+	size, err := d.getBlockDeviceSizeGiB(ID)
+	if err != nil {
+		_ = d.DeleteBlockDevice(ID)
+		return BlockDevice{}, fmt.Errorf("Error when querying block device size: %v", err)
+	}
+
+	return BlockDevice{ID: ID, Size: size}, nil
+}
+
+// CreateBlockDeviceSnapshot copies the volume to a snapshot with the provided name
+func (d FileDriver) CreateBlockDeviceSnapshot(volumeUUID string, snapshotID string) error {
+	_, err := runCmd("qemu-img", "convert", "-O", "qcow2", d.volumePath(volumeUUID),
+		d.snapshotPath(volumeUUID, snapshotID))
+	return err
+}
+
+// CopyBlockDevice will copy an existing volume
+func (d FileDriver) CopyBlockDevice(volumeUUID string) (BlockDevice, error) {
+	ID := uuid.Generate().String()
+
+	_, err := runCmd("qemu-img", "convert", "-O", "qcow2", d.volumePath(volumeUUID), d.volumePath(ID))
+	if err != nil {
+		return BlockDevice{}, err
+	}
+
+	size, err := d.getBlockDeviceSizeGiB(ID)
+	if err != nil {
+		_ = d.DeleteBlockDevice(ID)
+		return BlockDevice{}, fmt.Errorf("Error when querying block device size: %v", err)
+	}
+
+	return BlockDevice{ID: ID, Size: size}, nil
+}
+
+// DeleteBlockDevice will remove a volume file.
+func (d FileDriver) DeleteBlockDevice(volumeUUID string) error {
+	return os.Remove(d.volumePath(volumeUUID))
+}
+
+// DeleteBlockDeviceSnapshot deletes the snapshot with the provided name,
+// unless volumes were cloned from it
+func (d FileDriver) DeleteBlockDeviceSnapshot(volumeUUID string, snapshotID string) error {
+	path := d.snapshotPath(volumeUUID, snapshotID)
+
+	files, err := filepath.Glob(filepath.Join(d.Path, "*"+qcow2Ext))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		_, backing, err := qcow2Header(f)
+		if err == nil && backing == path {
+			return fmt.Errorf("Snapshot %s has clones", snapshotID)
+		}
+	}
+
+	return os.Remove(path)
+}
+
+// GetBlockDeviceSize returns the number of bytes used by the block device
+func (d FileDriver) GetBlockDeviceSize(volumeUUID string) (uint64, error) {
+	size, _, err := qcow2Header(d.volumePath(volumeUUID))
+	return size, err
+}
+
+// nbdDevices returns the nbd devices of the node
+func nbdDevices() ([]string, error) {
+	devices, err := filepath.Glob("/sys/block/nbd*")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("No nbd device found, is the nbd module loaded?")
+	}
+
+	return devices, nil
+}
+
+// nbdVolumeFile returns the file a qemu-nbd process serves, from its
+// command line
+func nbdVolumeFile(cmdline []byte) string {
+	args := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
+	if len(args) == 0 {
+		return ""
+	}
+
+	return string(args[len(args)-1])
+}
+
+// MapVolumeToNode connects a volume to a free nbd device of a node.  The
+// path to the device is returned if the connection succeeds.
+func (d FileDriver) MapVolumeToNode(volumeUUID string) (string, error) {
+	nbdLock.Lock()
+	defer nbdLock.Unlock()
+
+	devices, err := nbdDevices()
+	if err != nil {
+		return "", err
+	}
+
+	for _, dev := range devices {
+		// devices in use have the pid of their qemu-nbd
+		if _, err := os.Stat(filepath.Join(dev, "pid")); err == nil {
+			continue
+		}
+
+		devName := filepath.Join("/dev", filepath.Base(dev))
+		_, err = runCmd("qemu-nbd", "--format=qcow2", "--connect="+devName, d.volumePath(volumeUUID))
+		if err != nil {
+			return "", err
+		}
+
+		return devName, nil
+	}
+
+	return "", fmt.Errorf("No free nbd device to map %s", volumeUUID)
+}
+
+// UnmapVolumeFromNode disconnects a volume from the nbd devices of a node.
+// It accepts the UUID of the volume or the path to its device.
+func (d FileDriver) UnmapVolumeFromNode(volumeUUID string) error {
+	nbdLock.Lock()
+	defer nbdLock.Unlock()
+
+	if strings.HasPrefix(volumeUUID, "/dev/") {
+		_, err := runCmd("qemu-nbd", "--disconnect", volumeUUID)
+		return err
+	}
+
+	volumeDevMap, err := d.GetVolumeMapping()
+	if err != nil {
+		return err
+	}
+
+	devices, ok := volumeDevMap[volumeUUID]
+	if !ok {
+		return fmt.Errorf("Volume %s is not mapped", volumeUUID)
+	}
+
+	for _, devName := range devices {
+		_, err = runCmd("qemu-nbd", "--disconnect", devName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetVolumeMapping returns a map of volumeUUID to mapped devices.
+func (d FileDriver) GetVolumeMapping() (map[string][]string, error) {
+	devices, err := nbdDevices()
+	if err != nil {
+		return nil, err
+	}
+
+	volumeDevMap := make(map[string][]string)
+
+	for _, dev := range devices {
+		pid, err := ioutil.ReadFile(filepath.Join(dev, "pid"))
+		if err != nil {
+			continue
+		}
+
+		cmdline, err := ioutil.ReadFile(filepath.Join("/proc", strings.TrimSpace(string(pid)), "cmdline"))
+		if err != nil {
+			continue
+		}
+
+		file := nbdVolumeFile(cmdline)
+		if filepath.Dir(file) != filepath.Clean(d.Path) {
+			continue
+		}
+
+		volumeUUID := strings.TrimSuffix(filepath.Base(file), qcow2Ext)
+		if _, err := uuid.Parse(volumeUUID); err != nil {
+			continue
+		}
+
+		devName := filepath.Join("/dev", filepath.Base(dev))
+		volumeDevMap[volumeUUID] = append(volumeDevMap[volumeUUID], devName)
+	}
+
+	return volumeDevMap, nil
+}
+
+// IsValidSnapshotUUID checks for the Ciao standard snapshot uuid form of
+// {UUID}@{UUID}
+func (d FileDriver) IsValidSnapshotUUID(snapshotUUID string) error {
+	return isValidSnapshotUUID(snapshotUUID)
+}
+
+// Resize the volume file. Only extending is permitted. Returns the new size in GiB.
+func (d FileDriver) Resize(volumeUUID string, sizeGiB int) (int, error) {
+	size, err := d.getBlockDeviceSizeGiB(volumeUUID)
+	if err != nil {
+		return 0, err
+	}
+
+	if sizeGiB < size {
+		return size, fmt.Errorf("Volume %s cannot be shrunk", volumeUUID)
+	}
+
+	_, err = runCmd("qemu-img", "resize", d.volumePath(volumeUUID), fmt.Sprintf("%dG", sizeGiB))
+
+	size, _ = d.getBlockDeviceSizeGiB(volumeUUID)
+	return size, err
+}
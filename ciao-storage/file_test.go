@@ -0,0 +1,108 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeQcow2Header writes the start of the header of a qcow2 file
+func writeQcow2Header(path string, size uint64, backing string) error {
+	header := make([]byte, 512)
+	binary.BigEndian.PutUint32(header[0:], 0x514649fb)
+	binary.BigEndian.PutUint32(header[4:], 3)
+	if backing != "" {
+		binary.BigEndian.PutUint64(header[8:], 104)
+		binary.BigEndian.PutUint32(header[16:], uint32(len(backing)))
+		copy(header[104:], backing)
+	}
+	binary.BigEndian.PutUint32(header[20:], 16)
+	binary.BigEndian.PutUint64(header[24:], size)
+
+	return ioutil.WriteFile(path, header, 0600)
+}
+
+func TestFileDriverSizeAndSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ciao-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	driver := FileDriver{Path: dir}
+	volumeUUID := "dc1d3e23-e32a-49f5-8c59-402c13031d49"
+	cloneUUID := "e1f4834b-af32-46d9-8ec3-e4cea3de78cb"
+	snapshotPath := driver.snapshotPath(volumeUUID, "ciao-image")
+
+	err = writeQcow2Header(driver.volumePath(volumeUUID), 3*1024*1024*1024+1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeQcow2Header(snapshotPath, 3*1024*1024*1024+1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writeQcow2Header(driver.volumePath(cloneUUID), 3*1024*1024*1024+1, snapshotPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := driver.getBlockDeviceSizeGiB(volumeUUID)
+	if err != nil || size != 4 {
+		t.Errorf("expected 4 GiB, got %d: %v", size, err)
+	}
+
+	_, backing, err := qcow2Header(driver.volumePath(cloneUUID))
+	if err != nil || backing != snapshotPath {
+		t.Errorf("expected backing file %s, got %s: %v", snapshotPath, backing, err)
+	}
+
+	err = driver.DeleteBlockDeviceSnapshot(volumeUUID, "ciao-image")
+	if err == nil {
+		t.Errorf("expected error deleting snapshot with clones")
+	}
+
+	err = driver.DeleteBlockDevice(cloneUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = driver.DeleteBlockDeviceSnapshot(volumeUUID, "ciao-image")
+	if err != nil {
+		t.Errorf("expected nil, got \"%s\"", err)
+	}
+
+	_, _, err = qcow2Header(filepath.Join(dir, "missing"+qcow2Ext))
+	if err == nil {
+		t.Errorf("expected error for missing volume")
+	}
+}
+
+func TestNbdVolumeFile(t *testing.T) {
+	cmdline := []byte("qemu-nbd\x00--format=qcow2\x00--connect=/dev/nbd0\x00/var/lib/ciao/volumes/dc1d3e23-e32a-49f5-8c59-402c13031d49.qcow2\x00")
+
+	file := nbdVolumeFile(cmdline)
+	if file != "/var/lib/ciao/volumes/dc1d3e23-e32a-49f5-8c59-402c13031d49.qcow2" {
+		t.Errorf("unexpected volume file %s", file)
+	}
+
+	if nbdVolumeFile(nil) != "" {
+		t.Errorf("expected no volume file")
+	}
+}
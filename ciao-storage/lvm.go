@@ -0,0 +1,253 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ciao-project/ciao/ssntp/uuid"
+)
+
+// LVMDriver maintains context for the LVM driver interface.  Volumes are
+// thin logical volumes of a thin pool, named after their UUID.  Snapshots
+// and clones are thin snapshots, so they share the unmodified blocks of
+// their origin.  A volume is mapped to a node by activating it, so the
+// volume group must be local to, or shared with, the nodes using it.
+type LVMDriver struct {
+	// VolumeGroup is the volume group containing the thin pool
+	VolumeGroup string
+
+	// ThinPool is the thin pool in which volumes are allocated
+	ThinPool string
+}
+
+func (d LVMDriver) lv(name string) string {
+	return d.VolumeGroup + "/" + name
+}
+
+func (d LVMDriver) devicePath(name string) string {
+	return filepath.Join("/dev", d.VolumeGroup, name)
+}
+
+// snapshotName returns the name of the logical volume of a snapshot.  LVM
+// does not allow '@' in names.
+func snapshotName(volumeUUID string, snapshotID string) string {
+	return volumeUUID + "_" + snapshotID
+}
+
+func (d LVMDriver) getBlockDeviceSizeGiB(volumeUUID string) (int, error) {
+	size, err := d.GetBlockDeviceSize(volumeUUID)
+	if err != nil {
+		return 0, err
+	}
+
+	return bytesToGiB(size), nil
+}
+
+// clone creates a volume as a thin snapshot of another logical volume.
+// Unlike snapshots, clones are not skipped by activation.
+func (d LVMDriver) clone(origin string) (BlockDevice, error) {
+	ID := uuid.Generate().String()
+
+	_, err := runCmd("lvcreate", "--snapshot", "--setactivationskip", "n", "--name", ID,
+		d.lv(origin))
+	if err != nil {
+		return BlockDevice{}, err
+	}
+
+	// volumes are only active on the nodes they are mapped to
+	_, err = runCmd("lvchange", "--activate", "n", d.lv(ID))
+	if err != nil {
+		_ = d.DeleteBlockDevice(ID)
+		return BlockDevice{}, err
+	}
+
+	size, err := d.getBlockDeviceSizeGiB(ID)
+	if err != nil {
+		_ = d.DeleteBlockDevice(ID)
+		return BlockDevice{}, fmt.Errorf("Error when querying block device size: %v", err)
+	}
+
+	return BlockDevice{ID: ID, Size: size}, nil
+}
+
+// CreateBlockDevice will create a thin logical volume in the thin pool,
+// large enough to hold the image if one is given.
+func (d LVMDriver) CreateBlockDevice(volumeUUID string, imagePath string, size int) (BlockDevice, error) {
+	volumeUUID, err := newVolumeUUID(volumeUUID)
+	if err != nil {
+		return BlockDevice{}, err
+	}
+
+	if imagePath != "" {
+		imageBytes, err := imageSize(imagePath)
+		if err != nil {
+			return BlockDevice{}, err
+		}
+
+		if imageGiB := bytesToGiB(imageBytes); imageGiB > size {
+			size = imageGiB
+		}
+	}
+
+	_, err = runCmd("lvcreate", "--name", volumeUUID, "--virtualsize", strconv.Itoa(size)+"G",
+		"--thinpool", d.lv(d.ThinPool))
+	if err != nil {
+		return BlockDevice{}, err
+	}
+
+	if imagePath != "" {
+		_, err = runCmd("qemu-img", "convert", "-O", "raw", imagePath, d.devicePath(volumeUUID))
+		if err != nil {
+			_ = d.DeleteBlockDevice(volumeUUID)
+			return BlockDevice{}, err
+		}
+	}
+
+	// volumes are only active on the nodes they are mapped to
+	_, err = runCmd("lvchange", "--activate", "n", d.lv(volumeUUID))
+	if err != nil {
+		_ = d.DeleteBlockDevice(volumeUUID)
+		return BlockDevice{}, err
+	}
+
+	return BlockDevice{ID: volumeUUID, Size: size}, nil
+}
+
+// CreateBlockDeviceFromSnapshot will create a block device derived from the previously created snapshot.
+func (d LVMDriver) CreateBlockDeviceFromSnapshot(volumeUUID string, snapshotID string) (BlockDevice, error) {
+	return d.clone(snapshotName(volumeUUID, snapshotID))
+}
+
+// CreateBlockDeviceSnapshot creates a thin snapshot of the volume with the provided name
+func (d LVMDriver) CreateBlockDeviceSnapshot(volumeUUID string, snapshotID string) error {
+	_, err := runCmd("lvcreate", "--snapshot", "--name", snapshotName(volumeUUID, snapshotID),
+		d.lv(volumeUUID))
+	return err
+}
+
+// CopyBlockDevice will copy an existing volume.  The copy is a thin
+// snapshot of the volume, independent of it.
+func (d LVMDriver) CopyBlockDevice(volumeUUID string) (BlockDevice, error) {
+	return d.clone(volumeUUID)
+}
+
+// DeleteBlockDevice will remove a logical volume from the thin pool.
+func (d LVMDriver) DeleteBlockDevice(volumeUUID string) error {
+	_, err := runCmd("lvremove", "--yes", d.lv(volumeUUID))
+	return err
+}
+
+// DeleteBlockDeviceSnapshot deletes the snapshot with the provided name
+func (d LVMDriver) DeleteBlockDeviceSnapshot(volumeUUID string, snapshotID string) error {
+	_, err := runCmd("lvremove", "--yes", d.lv(snapshotName(volumeUUID, snapshotID)))
+	return err
+}
+
+// GetBlockDeviceSize returns the number of bytes used by the block device
+func (d LVMDriver) GetBlockDeviceSize(volumeUUID string) (uint64, error) {
+	out, err := runCmd("lvs", "--noheadings", "--nosuffix", "--units", "b",
+		"--options", "lv_size", d.lv(volumeUUID))
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to parse output from lvs: %v", err)
+	}
+
+	return size, nil
+}
+
+// MapVolumeToNode activates a logical volume on a node.  The path to the
+// device of the volume is returned if the activation succeeds.
+func (d LVMDriver) MapVolumeToNode(volumeUUID string) (string, error) {
+	_, err := runCmd("lvchange", "--activate", "y", "--ignoreactivationskip", d.lv(volumeUUID))
+	if err != nil {
+		return "", err
+	}
+
+	return d.devicePath(volumeUUID), nil
+}
+
+// UnmapVolumeFromNode deactivates a logical volume on a node.  It accepts
+// the UUID of the volume or the path to its device.
+func (d LVMDriver) UnmapVolumeFromNode(volumeUUID string) error {
+	_, err := runCmd("lvchange", "--activate", "n", d.lv(filepath.Base(volumeUUID)))
+	return err
+}
+
+// RefreshVolume reloads the metadata of a logical volume active on a node,
+// so that its device grows after the volume has been extended on another
+// node.
+func (d LVMDriver) RefreshVolume(volumeUUID string) error {
+	_, err := runCmd("lvchange", "--refresh", d.lv(volumeUUID))
+	return err
+}
+
+// GetVolumeMapping returns a map of volumeUUID to mapped devices.
+func (d LVMDriver) GetVolumeMapping() (map[string][]string, error) {
+	out, err := runCmd("lvs", "--noheadings", "--separator", ",",
+		"--options", "lv_name,lv_attr", d.VolumeGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.parseVolumeMapping(out), nil
+}
+
+// parseVolumeMapping returns the active volumes listed by lvs.  The fifth
+// attribute of a logical volume is its state, 'a' when it is active.
+func (d LVMDriver) parseVolumeMapping(out []byte) map[string][]string {
+	volumeDevMap := make(map[string][]string)
+
+	scanner := bufio.NewScanner(bytes.NewBuffer(out))
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ",")
+		if len(fields) != 2 || len(fields[1]) < 5 || fields[1][4] != 'a' {
+			continue
+		}
+
+		// the thin pool and the snapshots are not volumes
+		name := fields[0]
+		if _, err := uuid.Parse(name); err != nil {
+			continue
+		}
+
+		volumeDevMap[name] = append(volumeDevMap[name], d.devicePath(name))
+	}
+
+	return volumeDevMap
+}
+
+// IsValidSnapshotUUID checks for the Ciao standard snapshot uuid form of
+// {UUID}@{UUID}
+func (d LVMDriver) IsValidSnapshotUUID(snapshotUUID string) error {
+	return isValidSnapshotUUID(snapshotUUID)
+}
+
+// Resize the logical volume. Only extending is permitted. Returns the new size in GiB.
+func (d LVMDriver) Resize(volumeUUID string, sizeGiB int) (int, error) {
+	_, err := runCmd("lvextend", "--size", fmt.Sprintf("%dG", sizeGiB), d.lv(volumeUUID))
+
+	size, _ := d.getBlockDeviceSizeGiB(volumeUUID)
+	return size, err
+}
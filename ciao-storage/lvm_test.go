@@ -0,0 +1,54 @@
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"reflect"
+	"testing"
+)
+
+var lvmDriver = LVMDriver{
+	VolumeGroup: "ciao",
+	ThinPool:    "volumes",
+}
+
+func TestLVMParseVolumeMapping(t *testing.T) {
+	out := `  dc1d3e23-e32a-49f5-8c59-402c13031d49,Vwi-a-tz--
+  e1f4834b-af32-46d9-8ec3-e4cea3de78cb,Vwi---tz--
+  dc1d3e23-e32a-49f5-8c59-402c13031d49_ciao-image,Vwi-a-tz-k
+  volumes,twi-aotz--
+`
+
+	expected := map[string][]string{
+		"dc1d3e23-e32a-49f5-8c59-402c13031d49": {"/dev/ciao/dc1d3e23-e32a-49f5-8c59-402c13031d49"},
+	}
+
+	volumeDevMap := lvmDriver.parseVolumeMapping([]byte(out))
+	if !reflect.DeepEqual(volumeDevMap, expected) {
+		t.Errorf("expected %v, got %v", expected, volumeDevMap)
+	}
+}
+
+func TestLVMIsValidSnapshotUUID(t *testing.T) {
+	err := lvmDriver.IsValidSnapshotUUID("a@b")
+	if err == nil {
+		t.Errorf("expected error for invalid snapshot UUID")
+	}
+
+	err = lvmDriver.IsValidSnapshotUUID("dc1d3e23-e32a-49f5-8c59-402c13031d49@e1f4834b-af32-46d9-8ec3-e4cea3de78cb")
+	if err != nil {
+		t.Errorf("expected nil, got \"%s\"", err)
+	}
+}
@@ -16,7 +16,6 @@ package storage
 
 import (
 	"fmt"
-	"strings"
 	"sync/atomic"
 
 	"github.com/ciao-project/ciao/ssntp/uuid"
@@ -82,17 +81,7 @@ func (d *NoopDriver) GetVolumeMapping() (map[string][]string, error) {
 // IsValidSnapshotUUID checks for the Ciao standard snapshot uuid form of
 // {UUID}@{UUID}
 func (d *NoopDriver) IsValidSnapshotUUID(snapshotUUID string) error {
-	UUIDs := strings.Split(snapshotUUID, "@")
-	if len(UUIDs) != 2 {
-		return fmt.Errorf("missing '@'")
-	}
-	_, e1 := uuid.Parse(UUIDs[0])
-	_, e2 := uuid.Parse(UUIDs[1])
-	if e1 != nil || e2 != nil {
-		return fmt.Errorf("uuid not of form \"{UUID}@{UUID}\"")
-	}
-
-	return nil
+	return isValidSnapshotUUID(snapshotUUID)
 }
 
 // Resize the underlying rbd image. Only extending is permitted.
@@ -35,8 +35,11 @@ import (
 //
 // TODO: proper validation of values set in yaml setup
 func validMinConf(conf *payloads.Configure) bool {
-	if conf.Configure.Storage.CephID == "" {
-		fmt.Printf("Warning, ceph_id not set (will become an error soon)")
+	if !validStorageConf(&conf.Configure.Storage) {
+		return false
+	}
+	if !validTunnelType(conf.Configure.Launcher.TunnelType) {
+		return false
 	}
 	return (conf.Configure.Scheduler.ConfigStorageURI != "" &&
 		conf.Configure.Controller.HTTPSCACert != "" &&
@@ -44,6 +47,59 @@ func validMinConf(conf *payloads.Configure) bool {
 		conf.Configure.Controller.ClientAuthCACertPath != "")
 }
 
+// validTunnelType checks that the tunnel type is one supported by the
+// launchers and CNCIs
+func validTunnelType(tunnelType payloads.TunnelType) bool {
+	switch tunnelType {
+	case "", payloads.GRETunnel, payloads.VXLANTunnel:
+		return true
+	}
+
+	fmt.Printf("Invalid tunnel_type %s\n", tunnelType)
+	return false
+}
+
+// validStorageConf checks that the settings of the configured block
+// driver are set and that the volume types are named once and valid
+func validStorageConf(storage *payloads.ConfigureStorage) bool {
+	if storage.CephID == "" && (storage.BlockDriver == "" || storage.BlockDriver == payloads.CephBlockDriver) {
+		fmt.Printf("Warning, ceph_id not set (will become an error soon)")
+	}
+
+	if !validBlockDriverConf(payloads.ConfigureVolumeType{
+		BlockDriver:    storage.BlockDriver,
+		LVMVolumeGroup: storage.LVMVolumeGroup,
+		LVMThinPool:    storage.LVMThinPool,
+		VolumePath:     storage.VolumePath,
+	}) {
+		return false
+	}
+
+	names := make(map[string]bool)
+	for _, vt := range storage.VolumeTypes {
+		if vt.Name == "" || names[vt.Name] || !validBlockDriverConf(vt) {
+			return false
+		}
+		names[vt.Name] = true
+	}
+
+	return true
+}
+
+// validBlockDriverConf checks that the settings of a block driver are set
+func validBlockDriverConf(conf payloads.ConfigureVolumeType) bool {
+	switch conf.BlockDriver {
+	case "", payloads.CephBlockDriver:
+		return true
+	case payloads.LVMBlockDriver:
+		return conf.LVMVolumeGroup != "" && conf.LVMThinPool != ""
+	case payloads.FileBlockDriver:
+		return conf.VolumePath != ""
+	}
+
+	return false
+}
+
 func discoverDriver(uriStr string) (storageType payloads.StorageType, err error) {
 	uri, err := url.Parse(uriStr)
 	if err != nil {
@@ -66,6 +66,7 @@ const fullValidConf = `configure:
     admin_ssh_key: ""
     admin_password: ""
     client_auth_ca_cert_path: /etc/pki/ciao/auth-CA.pem
+    distributed_routing: false
   launcher:
     compute_net:
     - 192.168.1.0/24
@@ -73,6 +74,7 @@ const fullValidConf = `configure:
     - 192.168.1.0/24
     disk_limit: true
     mem_limit: true
+    tunnel_type: gre
 `
 
 func testBlob(t *testing.T, conf *payloads.Configure, expectedBlob []byte, positive bool) {
@@ -201,6 +203,73 @@ func TestValidMinConf(t *testing.T) {
 	if valid != true {
 		t.Fatalf("Expected true, got %v", valid)
 	}
+
+	conf.Configure.Launcher.TunnelType = payloads.VXLANTunnel
+	valid = validMinConf(&conf)
+	if valid != true {
+		t.Fatalf("Expected true, got %v", valid)
+	}
+
+	conf.Configure.Launcher.TunnelType = payloads.TunnelType("ipip")
+	valid = validMinConf(&conf)
+	if valid != false {
+		t.Fatalf("Expected false, got %v", valid)
+	}
+}
+
+func TestValidStorageConf(t *testing.T) {
+	tests := []struct {
+		storage payloads.ConfigureStorage
+		valid   bool
+	}{
+		{payloads.ConfigureStorage{CephID: cephID}, true},
+		{payloads.ConfigureStorage{BlockDriver: payloads.CephBlockDriver}, true},
+		{payloads.ConfigureStorage{BlockDriver: payloads.LVMBlockDriver}, false},
+		{payloads.ConfigureStorage{
+			BlockDriver:    payloads.LVMBlockDriver,
+			LVMVolumeGroup: "ciao",
+		}, false},
+		{payloads.ConfigureStorage{
+			BlockDriver:    payloads.LVMBlockDriver,
+			LVMVolumeGroup: "ciao",
+			LVMThinPool:    "volumes",
+		}, true},
+		{payloads.ConfigureStorage{BlockDriver: payloads.FileBlockDriver}, false},
+		{payloads.ConfigureStorage{
+			BlockDriver: payloads.FileBlockDriver,
+			VolumePath:  "/var/lib/ciao/volumes",
+		}, true},
+		{payloads.ConfigureStorage{BlockDriver: "nfs"}, false},
+		{payloads.ConfigureStorage{
+			CephID: cephID,
+			VolumeTypes: []payloads.ConfigureVolumeType{
+				{Name: "fast-ssd", CephPool: "ssd"},
+				{Name: "local", BlockDriver: payloads.FileBlockDriver, VolumePath: "/var/lib/ciao/volumes"},
+			},
+		}, true},
+		{payloads.ConfigureStorage{
+			CephID:      cephID,
+			VolumeTypes: []payloads.ConfigureVolumeType{{CephPool: "ssd"}},
+		}, false},
+		{payloads.ConfigureStorage{
+			CephID: cephID,
+			VolumeTypes: []payloads.ConfigureVolumeType{
+				{Name: "ssd", CephPool: "ssd"},
+				{Name: "ssd", CephPool: "fast"},
+			},
+		}, false},
+		{payloads.ConfigureStorage{
+			CephID:      cephID,
+			VolumeTypes: []payloads.ConfigureVolumeType{{Name: "local", BlockDriver: payloads.LVMBlockDriver}},
+		}, false},
+	}
+
+	for _, test := range tests {
+		valid := validStorageConf(&test.storage)
+		if valid != test.valid {
+			t.Errorf("Expected %v for %+v, got %v", test.valid, test.storage, valid)
+		}
+	}
 }
 
 func testExtractBlob(t *testing.T, uri string, expectedBlob []byte, positive bool) {
@@ -169,6 +169,36 @@ func createMandatoryDirs() error {
 	return nil
 }
 
+//updateOrder applies the updates replacing some state of a tenant in the
+//order of their generations. SSNTP processes each frame in its own
+//goroutine, so an update can get here after a more recent one
+type updateOrder struct {
+	sync.Mutex
+	applied map[string]int64 //index: Update type + Tenant UUID
+}
+
+var gUpdateOrder updateOrder
+
+//apply runs an update unless a more recent update of the same type was
+//already applied, in which case the update is dropped
+func (o *updateOrder) apply(update string, tenantID string, generation int64, fn func() error) error {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.applied == nil {
+		o.applied = make(map[string]int64)
+	}
+
+	key := update + tenantID
+	if generation < o.applied[key] {
+		glog.Infof("Dropping stale %s %d of tenant %s", update, generation, tenantID)
+		return nil
+	}
+	o.applied[key] = generation
+
+	return fn()
+}
+
 func processCommand(client *ssntpConn, cmd *cmdWrapper) {
 
 	switch netCmd := cmd.cmd.(type) {
@@ -232,6 +262,83 @@ func processCommand(client *ssntpConn, cmd *cmdWrapper) {
 			}
 		}(cmd)
 
+	case *payloads.CommandSecurityGroups:
+
+		go func(cmd *cmdWrapper) {
+			c := &netCmd.SecurityGroups
+			glog.Infof("Processing: CiaoCommandSecurityGroups %v", c)
+			err := gUpdateOrder.apply("SecurityGroups", c.TenantUUID, c.Generation, func() error {
+				return setSecurityGroups(c)
+			})
+			if err != nil {
+				glog.Errorf("Error Processing: CiaoCommandSecurityGroups %+v", err)
+			}
+		}(cmd)
+
+	case *payloads.CommandLoadBalancers:
+
+		go func(cmd *cmdWrapper) {
+			c := &netCmd.LoadBalancers
+			glog.Infof("Processing: CiaoCommandLoadBalancers %v", c)
+			err := gUpdateOrder.apply("LoadBalancers", c.TenantUUID, c.Generation, func() error {
+				return setLoadBalancers(c)
+			})
+			if err != nil {
+				glog.Errorf("Error Processing: CiaoCommandLoadBalancers %+v", err)
+			}
+		}(cmd)
+
+	case *payloads.CommandPortForwards:
+
+		go func(cmd *cmdWrapper) {
+			c := &netCmd.PortForwards
+			glog.Infof("Processing: CiaoCommandPortForwards %v", c)
+			err := gUpdateOrder.apply("PortForwards", c.TenantUUID, c.Generation, func() error {
+				return setPortForwards(c)
+			})
+			if err != nil {
+				glog.Errorf("Error Processing: CiaoCommandPortForwards %+v", err)
+			}
+		}(cmd)
+
+	case *payloads.CommandTenantDNS:
+
+		go func(cmd *cmdWrapper) {
+			c := &netCmd.TenantDNS
+			glog.Infof("Processing: CiaoCommandTenantDNS %v", c)
+			err := gUpdateOrder.apply("TenantDNS", c.TenantUUID, c.Generation, func() error {
+				return setTenantDNS(c)
+			})
+			if err != nil {
+				glog.Errorf("Error Processing: CiaoCommandTenantDNS %+v", err)
+			}
+		}(cmd)
+
+	case *payloads.CommandTenantRoutes:
+
+		go func(cmd *cmdWrapper) {
+			c := &netCmd.TenantRoutes
+			glog.Infof("Processing: CiaoCommandTenantRoutes %v", c)
+			err := gUpdateOrder.apply("TenantRoutes", c.TenantUUID, c.Generation, func() error {
+				return setTenantRoutes(c)
+			})
+			if err != nil {
+				glog.Errorf("Error Processing: CiaoCommandTenantRoutes %+v", err)
+			}
+		}(cmd)
+
+	case *payloads.NetworkInspect:
+
+		go func(cmd *cmdWrapper) {
+			c := &netCmd.Inspect
+			glog.Infof("Processing: CiaoCommandNetworkInspect %v", c)
+			report := inspectNetwork(c)
+			err := sendNetworkEvent(client, ssntp.NetworkReport, report)
+			if err != nil {
+				glog.Errorf("Unable to send event : %+v", err)
+			}
+		}(cmd)
+
 	case *statusConnected:
 		//Block and send this as it does not make sense to send other events
 		//or process commands when we have not yet registered
@@ -291,6 +398,137 @@ func (client *agentClient) CommandNotify(cmd ssntp.Command, frame *ssntp.Frame)
 			client.cmdCh <- &cmdWrapper{&releaseIP}
 		}(payload)
 
+	case ssntp.SecurityGroups:
+		glog.Infof("CMD: ssntp.SecurityGroups %v", len(payload))
+
+		go func(payload []byte) {
+			var secGroups payloads.CommandSecurityGroups
+			err := yaml.Unmarshal(payload, &secGroups)
+			if err != nil {
+				glog.Warning("Error unmarshalling SecurityGroups")
+				return
+			}
+			glog.Infof("EVENT: ssntp.SecurityGroups %v", secGroups)
+
+			err = dbProcessCommand(client.db, &secGroups)
+			if err == errStaleUpdate {
+				glog.Infof("Dropping stale update %v", secGroups)
+				return
+			} else if err != nil {
+				glog.Errorf("unable to save state %+v", err)
+			}
+
+			client.cmdCh <- &cmdWrapper{&secGroups}
+		}(payload)
+
+	case ssntp.LoadBalancers:
+		glog.Infof("CMD: ssntp.LoadBalancers %v", len(payload))
+
+		go func(payload []byte) {
+			var loadBalancers payloads.CommandLoadBalancers
+			err := yaml.Unmarshal(payload, &loadBalancers)
+			if err != nil {
+				glog.Warning("Error unmarshalling LoadBalancers")
+				return
+			}
+			glog.Infof("EVENT: ssntp.LoadBalancers %v", loadBalancers)
+
+			err = dbProcessCommand(client.db, &loadBalancers)
+			if err == errStaleUpdate {
+				glog.Infof("Dropping stale update %v", loadBalancers)
+				return
+			} else if err != nil {
+				glog.Errorf("unable to save state %+v", err)
+			}
+
+			client.cmdCh <- &cmdWrapper{&loadBalancers}
+		}(payload)
+
+	case ssntp.PortForwards:
+		glog.Infof("CMD: ssntp.PortForwards %v", len(payload))
+
+		go func(payload []byte) {
+			var portForwards payloads.CommandPortForwards
+			err := yaml.Unmarshal(payload, &portForwards)
+			if err != nil {
+				glog.Warning("Error unmarshalling PortForwards")
+				return
+			}
+			glog.Infof("EVENT: ssntp.PortForwards %v", portForwards)
+
+			err = dbProcessCommand(client.db, &portForwards)
+			if err == errStaleUpdate {
+				glog.Infof("Dropping stale update %v", portForwards)
+				return
+			} else if err != nil {
+				glog.Errorf("unable to save state %+v", err)
+			}
+
+			client.cmdCh <- &cmdWrapper{&portForwards}
+		}(payload)
+
+	case ssntp.TenantDNS:
+		glog.Infof("CMD: ssntp.TenantDNS %v", len(payload))
+
+		go func(payload []byte) {
+			var tenantDNS payloads.CommandTenantDNS
+			err := yaml.Unmarshal(payload, &tenantDNS)
+			if err != nil {
+				glog.Warning("Error unmarshalling TenantDNS")
+				return
+			}
+			glog.Infof("EVENT: ssntp.TenantDNS %v", tenantDNS)
+
+			err = dbProcessCommand(client.db, &tenantDNS)
+			if err == errStaleUpdate {
+				glog.Infof("Dropping stale update %v", tenantDNS)
+				return
+			} else if err != nil {
+				glog.Errorf("unable to save state %+v", err)
+			}
+
+			client.cmdCh <- &cmdWrapper{&tenantDNS}
+		}(payload)
+
+	case ssntp.TenantRoutes:
+		glog.Infof("CMD: ssntp.TenantRoutes %v", len(payload))
+
+		go func(payload []byte) {
+			var tenantRoutes payloads.CommandTenantRoutes
+			err := yaml.Unmarshal(payload, &tenantRoutes)
+			if err != nil {
+				glog.Warning("Error unmarshalling TenantRoutes")
+				return
+			}
+			glog.Infof("EVENT: ssntp.TenantRoutes %v", tenantRoutes)
+
+			err = dbProcessCommand(client.db, &tenantRoutes)
+			if err == errStaleUpdate {
+				glog.Infof("Dropping stale update %v", tenantRoutes)
+				return
+			} else if err != nil {
+				glog.Errorf("unable to save state %+v", err)
+			}
+
+			client.cmdCh <- &cmdWrapper{&tenantRoutes}
+		}(payload)
+
+	case ssntp.NetworkInspect:
+		glog.Infof("CMD: ssntp.NetworkInspect %v", len(payload))
+
+		go func(payload []byte) {
+			var inspect payloads.NetworkInspect
+			err := yaml.Unmarshal(payload, &inspect)
+			if err != nil {
+				glog.Warning("Error unmarshalling NetworkInspect")
+				return
+			}
+			glog.Infof("EVENT: ssntp.NetworkInspect %v", inspect)
+
+			//The inspection is not part of the state of the CNCI
+			client.cmdCh <- &cmdWrapper{&inspect}
+		}(payload)
+
 	default:
 		glog.Infof("CMD: %s", cmd)
 	}
@@ -473,6 +711,66 @@ func rebuildNetworkState(db *cnciDatabase) error {
 		}
 	}
 
+	db.SecurityGroupsMap.Lock()
+	defer db.SecurityGroupsMap.Unlock()
+
+	for key, secGroups := range db.SecurityGroupsMap.m {
+		glog.Infof("Key: %v SecurityGroups: %v", key, secGroups)
+		err := setSecurityGroups(secGroups)
+		if err != nil {
+			lastError = err
+			glog.Errorf("rebuildNetworkState: %v", err)
+		}
+	}
+
+	db.LoadBalancersMap.Lock()
+	defer db.LoadBalancersMap.Unlock()
+
+	for key, loadBalancers := range db.LoadBalancersMap.m {
+		glog.Infof("Key: %v LoadBalancers: %v", key, loadBalancers)
+		err := setLoadBalancers(loadBalancers)
+		if err != nil {
+			lastError = err
+			glog.Errorf("rebuildNetworkState: %v", err)
+		}
+	}
+
+	db.PortForwardsMap.Lock()
+	defer db.PortForwardsMap.Unlock()
+
+	for key, portForwards := range db.PortForwardsMap.m {
+		glog.Infof("Key: %v PortForwards: %v", key, portForwards)
+		err := setPortForwards(portForwards)
+		if err != nil {
+			lastError = err
+			glog.Errorf("rebuildNetworkState: %v", err)
+		}
+	}
+
+	db.TenantDNSMap.Lock()
+	defer db.TenantDNSMap.Unlock()
+
+	for key, tenantDNS := range db.TenantDNSMap.m {
+		glog.Infof("Key: %v TenantDNS: %v", key, tenantDNS)
+		err := setTenantDNS(tenantDNS)
+		if err != nil {
+			lastError = err
+			glog.Errorf("rebuildNetworkState: %v", err)
+		}
+	}
+
+	db.TenantRoutesMap.Lock()
+	defer db.TenantRoutesMap.Unlock()
+
+	for key, tenantRoutes := range db.TenantRoutesMap.m {
+		glog.Infof("Key: %v TenantRoutes: %v", key, tenantRoutes)
+		err := setTenantRoutes(tenantRoutes)
+		if err != nil {
+			lastError = err
+			glog.Errorf("rebuildNetworkState: %v", err)
+		}
+	}
+
 	return errors.Wrapf(lastError, "rebuild network state")
 }
 
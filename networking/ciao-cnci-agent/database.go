@@ -30,13 +30,27 @@ type cnciDatabase struct {
 	database.DbProvider //Database used to persist the CNCI state
 	SubnetMap
 	PublicIPMap
+	SecurityGroupsMap
+	LoadBalancersMap
+	PortForwardsMap
+	TenantDNSMap
+	TenantRoutesMap
 }
 
 const (
-	tableSubnetMap   = "SubnetMap"
-	tablePublicIPMap = "PublicIPMap"
+	tableSubnetMap         = "SubnetMap"
+	tablePublicIPMap       = "PublicIPMap"
+	tableSecurityGroupsMap = "SecurityGroupsMap"
+	tableLoadBalancersMap  = "LoadBalancersMap"
+	tablePortForwardsMap   = "PortForwardsMap"
+	tableTenantDNSMap      = "TenantDNSMap"
+	tableTenantRoutesMap   = "TenantRoutesMap"
 )
 
+//errStaleUpdate is returned for an update replacing some state that is
+//older than the one it would replace
+var errStaleUpdate = errors.New("stale update")
+
 //dbCfg controls plugin data base attributes
 //these may be overridden by the caller if needed
 var dbCfg = struct {
@@ -114,11 +128,172 @@ func (d *PublicIPMap) Add(k string, v interface{}) error {
 	return nil
 }
 
+//SecurityGroupsMap maintains the security group rules enforced by this CNCI
+type SecurityGroupsMap struct {
+	sync.Mutex
+	m map[string]*payloads.SecurityGroupsCmd //index: Tenant UUID
+}
+
+//NewTable creates a new map
+func (d *SecurityGroupsMap) NewTable() {
+	d.m = make(map[string]*payloads.SecurityGroupsCmd)
+}
+
+//Name provides the name of the map
+func (d *SecurityGroupsMap) Name() string {
+	return tableSecurityGroupsMap
+}
+
+//NewElement allocates and returns a security groups value
+func (d *SecurityGroupsMap) NewElement() interface{} {
+	return &payloads.SecurityGroupsCmd{}
+}
+
+//Add adds a value to the map with the specified key
+func (d *SecurityGroupsMap) Add(k string, v interface{}) error {
+	val, ok := v.(*payloads.SecurityGroupsCmd)
+	if !ok {
+		return errors.Errorf("Invalid value type %t", v)
+	}
+	d.m[k] = val
+	return nil
+}
+
+//LoadBalancersMap maintains the load balancers run by this CNCI
+type LoadBalancersMap struct {
+	sync.Mutex
+	m map[string]*payloads.LoadBalancersCmd //index: Tenant UUID
+}
+
+//NewTable creates a new map
+func (d *LoadBalancersMap) NewTable() {
+	d.m = make(map[string]*payloads.LoadBalancersCmd)
+}
+
+//Name provides the name of the map
+func (d *LoadBalancersMap) Name() string {
+	return tableLoadBalancersMap
+}
+
+//NewElement allocates and returns a load balancers value
+func (d *LoadBalancersMap) NewElement() interface{} {
+	return &payloads.LoadBalancersCmd{}
+}
+
+//Add adds a value to the map with the specified key
+func (d *LoadBalancersMap) Add(k string, v interface{}) error {
+	val, ok := v.(*payloads.LoadBalancersCmd)
+	if !ok {
+		return errors.Errorf("Invalid value type %t", v)
+	}
+	d.m[k] = val
+	return nil
+}
+
+//PortForwardsMap maintains the port forwards rendered by this CNCI
+type PortForwardsMap struct {
+	sync.Mutex
+	m map[string]*payloads.PortForwardsCmd //index: Tenant UUID
+}
+
+//NewTable creates a new map
+func (d *PortForwardsMap) NewTable() {
+	d.m = make(map[string]*payloads.PortForwardsCmd)
+}
+
+//Name provides the name of the map
+func (d *PortForwardsMap) Name() string {
+	return tablePortForwardsMap
+}
+
+//NewElement allocates and returns a port forwards value
+func (d *PortForwardsMap) NewElement() interface{} {
+	return &payloads.PortForwardsCmd{}
+}
+
+//Add adds a value to the map with the specified key
+func (d *PortForwardsMap) Add(k string, v interface{}) error {
+	val, ok := v.(*payloads.PortForwardsCmd)
+	if !ok {
+		return errors.Errorf("Invalid value type %t", v)
+	}
+	d.m[k] = val
+	return nil
+}
+
+//TenantDNSMap maintains the DNS records served by this CNCI
+type TenantDNSMap struct {
+	sync.Mutex
+	m map[string]*payloads.TenantDNSCmd //index: Tenant UUID
+}
+
+//NewTable creates a new map
+func (d *TenantDNSMap) NewTable() {
+	d.m = make(map[string]*payloads.TenantDNSCmd)
+}
+
+//Name provides the name of the map
+func (d *TenantDNSMap) Name() string {
+	return tableTenantDNSMap
+}
+
+//NewElement allocates and returns a tenant DNS value
+func (d *TenantDNSMap) NewElement() interface{} {
+	return &payloads.TenantDNSCmd{}
+}
+
+//Add adds a value to the map with the specified key
+func (d *TenantDNSMap) Add(k string, v interface{}) error {
+	val, ok := v.(*payloads.TenantDNSCmd)
+	if !ok {
+		return errors.Errorf("Invalid value type %t", v)
+	}
+	d.m[k] = val
+	return nil
+}
+
+//TenantRoutesMap maintains the subnets routed by the tenant routers of
+//the compute nodes
+type TenantRoutesMap struct {
+	sync.Mutex
+	m map[string]*payloads.TenantRoutesCmd //index: Tenant UUID
+}
+
+//NewTable creates a new map
+func (d *TenantRoutesMap) NewTable() {
+	d.m = make(map[string]*payloads.TenantRoutesCmd)
+}
+
+//Name provides the name of the map
+func (d *TenantRoutesMap) Name() string {
+	return tableTenantRoutesMap
+}
+
+//NewElement allocates and returns a tenant routes value
+func (d *TenantRoutesMap) NewElement() interface{} {
+	return &payloads.TenantRoutesCmd{}
+}
+
+//Add adds a value to the map with the specified key
+func (d *TenantRoutesMap) Add(k string, v interface{}) error {
+	val, ok := v.(*payloads.TenantRoutesCmd)
+	if !ok {
+		return errors.Errorf("Invalid value type %t", v)
+	}
+	d.m[k] = val
+	return nil
+}
+
 func dbInit() (*cnciDatabase, error) {
 	db := &cnciDatabase{}
 	db.DbProvider = database.NewBoltDBProvider()
 	db.SubnetMap.m = make(map[string]*payloads.TenantAddedEvent)
 	db.PublicIPMap.m = make(map[string]*payloads.PublicIPCommand)
+	db.SecurityGroupsMap.m = make(map[string]*payloads.SecurityGroupsCmd)
+	db.LoadBalancersMap.m = make(map[string]*payloads.LoadBalancersCmd)
+	db.PortForwardsMap.m = make(map[string]*payloads.PortForwardsCmd)
+	db.TenantDNSMap.m = make(map[string]*payloads.TenantDNSCmd)
+	db.TenantRoutesMap.m = make(map[string]*payloads.TenantRoutesCmd)
 
 	if err := db.DbInit(dbCfg.DataDir, dbCfg.DbFile); err != nil {
 		return nil, errors.Wrapf(err, "db init: %v, %v", dbCfg.DataDir, dbCfg.DbFile)
@@ -129,6 +304,21 @@ func dbInit() (*cnciDatabase, error) {
 	if err := db.DbTableRebuild(&db.PublicIPMap); err != nil {
 		return nil, errors.Wrapf(err, "publicIPMap")
 	}
+	if err := db.DbTableRebuild(&db.SecurityGroupsMap); err != nil {
+		return nil, errors.Wrapf(err, "securityGroupsMap")
+	}
+	if err := db.DbTableRebuild(&db.LoadBalancersMap); err != nil {
+		return nil, errors.Wrapf(err, "loadBalancersMap")
+	}
+	if err := db.DbTableRebuild(&db.PortForwardsMap); err != nil {
+		return nil, errors.Wrapf(err, "portForwardsMap")
+	}
+	if err := db.DbTableRebuild(&db.TenantDNSMap); err != nil {
+		return nil, errors.Wrapf(err, "tenantDNSMap")
+	}
+	if err := db.DbTableRebuild(&db.TenantRoutesMap); err != nil {
+		return nil, errors.Wrapf(err, "tenantRoutesMap")
+	}
 	return db, nil
 }
 
@@ -140,6 +330,8 @@ func dbInit() (*cnciDatabase, error) {
 //CNCI instance. Hence if a particular network command fails
 //assuming it passes consistency checks, then on the restart of
 //the CNCI the command may succeed.
+//An update replacing some state is not stored, and errStaleUpdate is
+//returned, when its generation is older than the stored one.
 func dbProcessCommand(db *cnciDatabase, cmd interface{}) error {
 
 	switch netCmd := cmd.(type) {
@@ -199,6 +391,91 @@ func dbProcessCommand(db *cnciDatabase, cmd interface{}) error {
 			return errors.Wrapf(err, "delete Public IP from db: %v", c)
 		}
 
+	case *payloads.CommandSecurityGroups:
+
+		c := &netCmd.SecurityGroups
+
+		db.SecurityGroupsMap.Lock()
+		defer db.SecurityGroupsMap.Unlock()
+
+		key := c.TenantUUID
+		if old := db.SecurityGroupsMap.m[key]; old != nil && c.Generation < old.Generation {
+			return errStaleUpdate
+		}
+		db.SecurityGroupsMap.m[key] = c
+
+		if err := db.DbAdd(tableSecurityGroupsMap, key, db.SecurityGroupsMap.m[key]); err != nil {
+			return errors.Wrapf(err, "add security groups to db: %v", c)
+		}
+
+	case *payloads.CommandLoadBalancers:
+
+		c := &netCmd.LoadBalancers
+
+		db.LoadBalancersMap.Lock()
+		defer db.LoadBalancersMap.Unlock()
+
+		key := c.TenantUUID
+		if old := db.LoadBalancersMap.m[key]; old != nil && c.Generation < old.Generation {
+			return errStaleUpdate
+		}
+		db.LoadBalancersMap.m[key] = c
+
+		if err := db.DbAdd(tableLoadBalancersMap, key, db.LoadBalancersMap.m[key]); err != nil {
+			return errors.Wrapf(err, "add load balancers to db: %v", c)
+		}
+
+	case *payloads.CommandPortForwards:
+
+		c := &netCmd.PortForwards
+
+		db.PortForwardsMap.Lock()
+		defer db.PortForwardsMap.Unlock()
+
+		key := c.TenantUUID
+		if old := db.PortForwardsMap.m[key]; old != nil && c.Generation < old.Generation {
+			return errStaleUpdate
+		}
+		db.PortForwardsMap.m[key] = c
+
+		if err := db.DbAdd(tablePortForwardsMap, key, db.PortForwardsMap.m[key]); err != nil {
+			return errors.Wrapf(err, "add port forwards to db: %v", c)
+		}
+
+	case *payloads.CommandTenantDNS:
+
+		c := &netCmd.TenantDNS
+
+		db.TenantDNSMap.Lock()
+		defer db.TenantDNSMap.Unlock()
+
+		key := c.TenantUUID
+		if old := db.TenantDNSMap.m[key]; old != nil && c.Generation < old.Generation {
+			return errStaleUpdate
+		}
+		db.TenantDNSMap.m[key] = c
+
+		if err := db.DbAdd(tableTenantDNSMap, key, db.TenantDNSMap.m[key]); err != nil {
+			return errors.Wrapf(err, "add tenant DNS to db: %v", c)
+		}
+
+	case *payloads.CommandTenantRoutes:
+
+		c := &netCmd.TenantRoutes
+
+		db.TenantRoutesMap.Lock()
+		defer db.TenantRoutesMap.Unlock()
+
+		key := c.TenantUUID
+		if old := db.TenantRoutesMap.m[key]; old != nil && c.Generation < old.Generation {
+			return errStaleUpdate
+		}
+		db.TenantRoutesMap.m[key] = c
+
+		if err := db.DbAdd(tableTenantRoutesMap, key, db.TenantRoutesMap.m[key]); err != nil {
+			return errors.Wrapf(err, "add tenant routes to db: %v", c)
+		}
+
 	default:
 		return errors.Errorf("unknown command: %v", netCmd)
 
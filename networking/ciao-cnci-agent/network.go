@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"reflect"
+	"sync"
 	"time"
 
 	"gopkg.in/yaml.v2"
@@ -86,6 +88,8 @@ func initNetwork(cancelCh <-chan os.Signal) error {
 		fw, err := libsnnet.InitFirewall(gCnci.ComputeLink[0].Attrs().Name)
 		if err != nil {
 			glog.Errorf("Firewall initialize failed %v", err) //Explicit ignore
+		} else if err := fw.EnableIPv6(); err != nil {
+			glog.Warningf("IPv6 routing unavailable %v", err)
 		}
 		gFw = fw
 	}
@@ -105,13 +109,10 @@ func unmarshallSubnetParams(cmd *payloads.TenantAddedEvent) (*net.IPNet, int, ne
 		return nil, 0, nil, errors.Wrapf(err, "invalid CN IP %s", cmd.ConcentratorIP)
 	}
 
-	//TODO
-	//When we go away from a 1:1 subnet to key map remove this check
-	//Today this ensures the sanity of the YAML and CN
-	key := int(binary.LittleEndian.Uint32(snet.IP))
+	//Subnet keys are allocated by the controller, unique across tenants
 	subnetKey := cmd.SubnetKey
-	if key != subnetKey {
-		return nil, 0, nil, errors.Wrapf(err, "invalid subnet key %s %x", cmd.TenantSubnet, cmd.SubnetKey)
+	if subnetKey <= 0 {
+		return nil, 0, nil, errors.Errorf("invalid subnet key %s %x", cmd.TenantSubnet, cmd.SubnetKey)
 	}
 
 	return snet, subnetKey, cIP, nil
@@ -167,6 +168,19 @@ func natSSHSubnet(action libsnnet.FwAction, subnet net.IPNet, intIf string, extI
 	return nil
 }
 
+// tunnelMode returns the mode of the tunnel announced by the compute node.
+// Compute nodes that predate VXLAN support announce no tunnel type.
+func tunnelMode(cmd *payloads.TenantAddedEvent) (libsnnet.NetworkMode, error) {
+	switch cmd.TunnelType {
+	case "", payloads.GRETunnel:
+		return libsnnet.GreTunnel, nil
+	case payloads.VXLANTunnel:
+		return libsnnet.VxlanTunnel, nil
+	}
+
+	return 0, errors.Errorf("invalid tunnel type %s", cmd.TunnelType)
+}
+
 func addRemoteSubnet(cmd *payloads.TenantAddedEvent) error {
 	rs, tk, rip, err := unmarshallSubnetParams(cmd)
 
@@ -177,7 +191,12 @@ func addRemoteSubnet(cmd *payloads.TenantAddedEvent) error {
 	if !enableNetwork {
 		return nil
 	}
-	bridge, err := gCnci.AddRemoteSubnet(*rs, tk, rip)
+	mode, err := tunnelMode(cmd)
+	if err != nil {
+		return err
+	}
+
+	bridge, err := gCnci.AddRemoteSubnetMode(mode, *rs, tk, rip)
 	if err != nil {
 		return errors.Wrapf(err, "add remote subnet %s %x %s", rs, tk, rip)
 	}
@@ -244,6 +263,63 @@ func cnciAddedMarshal(agentUUID string) ([]byte, error) {
 	return yaml.Marshal(&cnciAdded)
 }
 
+//networkReport is the result of the inspection of the network topology of
+//the CNCI
+type networkReport struct {
+	repair bool
+	report *libsnnet.TopologyReport
+	err    error
+}
+
+//inspectNetwork compares the network topology of the CNCI to the one
+//recorded by libsnnet, optionally repairing it
+func inspectNetwork(cmd *payloads.NetworkInspectCmd) *networkReport {
+	report, err := gCnci.InspectTopology(cmd.Repair)
+	if err != nil {
+		glog.Warningf("Network inspection failed: %v", err)
+	}
+
+	return &networkReport{
+		repair: cmd.Repair,
+		report: report,
+		err:    err,
+	}
+}
+
+func networkReportMarshal(r *networkReport, agentUUID string) ([]byte, error) {
+	var networkReport payloads.NetworkReport
+	evt := &networkReport.Report
+
+	evt.NodeUUID = agentUUID
+	evt.Repair = r.repair
+	if r.err != nil {
+		evt.Error = r.err.Error()
+	}
+
+	if r.report != nil {
+		for _, l := range r.report.Expected {
+			evt.Expected = append(evt.Expected,
+				payloads.NetworkLink{Alias: l.Alias, Name: l.Name, Master: l.Master})
+		}
+		for _, l := range r.report.Actual {
+			evt.Actual = append(evt.Actual,
+				payloads.NetworkLink{Alias: l.Alias, Name: l.Name, Master: l.Master, Up: l.Up})
+		}
+		for _, d := range r.report.Dnsmasq {
+			evt.Dnsmasq = append(evt.Dnsmasq,
+				payloads.DnsmasqProcess{Bridge: d.Bridge, PID: d.PID, Running: d.Running})
+		}
+		for _, p := range r.report.Problems {
+			evt.Problems = append(evt.Problems,
+				payloads.NetworkProblem{Alias: p.Alias, Problem: p.Problem, Repaired: p.Repaired})
+		}
+	}
+
+	glog.Infoln("networkReportMarshal Event ", networkReport)
+
+	return yaml.Marshal(&networkReport)
+}
+
 func publicIPAssignedMarshal(cmd *payloads.PublicIPCommand) ([]byte, error) {
 	var publicIPAssigned payloads.EventPublicIPAssigned
 	evt := &publicIPAssigned.AssignedIP
@@ -366,6 +442,13 @@ func generateNetEventPayload(eventType ssntp.Event, eventInfo interface{}, agent
 			return nil, errors.Errorf("invalid eventInfo [%T] %v", eventInfo, eventInfo)
 		}
 		return publicIPUnassignedMarshal(cmd)
+	case ssntp.NetworkReport:
+		glog.Infof("generating network Report Event Payload %s", agentUUID)
+		report, ok := eventInfo.(*networkReport)
+		if !ok {
+			return nil, errors.Errorf("invalid eventInfo [%T] %v", eventInfo, eventInfo)
+		}
+		return networkReportMarshal(report, agentUUID)
 	default:
 		return nil, errors.Errorf("unsupported ssntpEventInfo type: %v", eventType)
 	}
@@ -409,3 +492,361 @@ func releasePubIP(cmd *payloads.PublicIPCommand) error {
 	err = gFw.PublicIPAccess(libsnnet.FwDisable, prIP, puIP, gCnci.ComputeLink[0].Attrs().Name)
 	return errors.Wrapf(err, "release ip")
 }
+
+func securityRules(cmd *payloads.SecurityGroupsCmd) ([]net.IP, []libsnnet.SecurityRule, error) {
+	var guarded []net.IP
+	var rules []libsnnet.SecurityRule
+
+	for _, i := range cmd.Instances {
+		ip := net.ParseIP(i.PrivateIP)
+		if ip == nil {
+			return nil, nil, errors.Errorf("invalid private IP %v", i.PrivateIP)
+		}
+		guarded = append(guarded, ip)
+
+		var ip6 net.IP
+		if i.PrivateIPv6 != "" {
+			ip6 = net.ParseIP(i.PrivateIPv6)
+			if ip6 == nil || ip6.To4() != nil {
+				return nil, nil, errors.Errorf("invalid private IPv6 %v", i.PrivateIPv6)
+			}
+			guarded = append(guarded, ip6)
+		}
+
+		for _, r := range i.Rules {
+			rule := libsnnet.SecurityRule{
+				InstanceIP: ip,
+				Protocol:   r.Protocol,
+				PortMin:    r.PortMin,
+				PortMax:    r.PortMax,
+			}
+
+			switch r.Direction {
+			case payloads.Ingress:
+				rule.Direction = libsnnet.FwIngress
+			case payloads.Egress:
+				rule.Direction = libsnnet.FwEgress
+			default:
+				return nil, nil, errors.Errorf("invalid direction %v", r.Direction)
+			}
+
+			if r.RemoteCIDR != "" {
+				_, remote, err := net.ParseCIDR(r.RemoteCIDR)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "invalid remote %v", r.RemoteCIDR)
+				}
+				rule.Remote = remote
+
+				if remote.IP.To4() == nil {
+					if ip6 == nil {
+						continue
+					}
+					rule.InstanceIP = ip6
+				}
+			}
+
+			rules = append(rules, rule)
+
+			if rule.Remote == nil && ip6 != nil {
+				rule.InstanceIP = ip6
+				rules = append(rules, rule)
+			}
+		}
+	}
+
+	return guarded, rules, nil
+}
+
+func setSecurityGroups(cmd *payloads.SecurityGroupsCmd) error {
+
+	guarded, rules, err := securityRules(cmd)
+	if err != nil {
+		return errors.Wrapf(err, "invalid params %v", cmd)
+	}
+
+	err = gFw.SecurityGroups(guarded, rules)
+	return errors.Wrapf(err, "security groups")
+}
+
+type loadBalancer struct {
+	cfg payloads.LoadBalancer
+	lb  *libsnnet.LoadBalancer
+}
+
+//gLoadBalancers holds the load balancers run by the CNCI, by UUID
+var gLoadBalancers = struct {
+	sync.Mutex
+	m map[string]loadBalancer
+}{m: make(map[string]loadBalancer)}
+
+func newLoadBalancer(cfg payloads.LoadBalancer) (*libsnnet.LoadBalancer, error) {
+	if cfg.Protocol != "tcp" {
+		return nil, errors.Errorf("unsupported protocol %v", cfg.Protocol)
+	}
+
+	puIP := net.ParseIP(cfg.PublicIP)
+	if puIP == nil {
+		return nil, errors.Errorf("invalid public IP %v", cfg.PublicIP)
+	}
+
+	lb := &libsnnet.LoadBalancer{
+		ID:             cfg.LoadBalancerUUID,
+		Listen:         net.TCPAddr{IP: puIP, Port: cfg.Port},
+		HealthInterval: time.Duration(cfg.HealthInterval) * time.Second,
+		HealthTimeout:  time.Duration(cfg.HealthTimeout) * time.Second,
+	}
+
+	for _, m := range cfg.MemberIPs {
+		ip := net.ParseIP(m)
+		if ip == nil {
+			return nil, errors.Errorf("invalid member IP %v", m)
+		}
+		lb.Members = append(lb.Members, net.TCPAddr{IP: ip, Port: cfg.MemberPort})
+	}
+
+	return lb, nil
+}
+
+func startLoadBalancer(lb *libsnnet.LoadBalancer) error {
+	extIf := gCnci.ComputeLink[0].Attrs().Name
+
+	err := libsnnet.ExternalIPAssign(libsnnet.FwEnable, lb.Listen.IP, extIf)
+	if err != nil {
+		return err
+	}
+
+	err = lb.Start()
+	if err != nil {
+		_ = libsnnet.ExternalIPAssign(libsnnet.FwDisable, lb.Listen.IP, extIf)
+	}
+	return err
+}
+
+func stopLoadBalancer(lb *libsnnet.LoadBalancer) error {
+	lb.Stop()
+	return libsnnet.ExternalIPAssign(libsnnet.FwDisable, lb.Listen.IP, gCnci.ComputeLink[0].Attrs().Name)
+}
+
+//setLoadBalancers replaces the load balancers run by the CNCI. Load
+//balancers whose configuration did not change keep running
+func setLoadBalancers(cmd *payloads.LoadBalancersCmd) error {
+	var lastError error
+
+	gLoadBalancers.Lock()
+	defer gLoadBalancers.Unlock()
+
+	wanted := make(map[string]payloads.LoadBalancer)
+	for _, cfg := range cmd.LoadBalancers {
+		wanted[cfg.LoadBalancerUUID] = cfg
+	}
+
+	for id, running := range gLoadBalancers.m {
+		cfg, ok := wanted[id]
+		if ok && reflect.DeepEqual(cfg, running.cfg) {
+			continue
+		}
+
+		glog.Infof("Stopping load balancer %v", id)
+		err := stopLoadBalancer(running.lb)
+		if err != nil {
+			lastError = err
+			glog.Errorf("Unable to stop load balancer %v: %v", id, err)
+		}
+		delete(gLoadBalancers.m, id)
+	}
+
+	for id, cfg := range wanted {
+		if _, ok := gLoadBalancers.m[id]; ok {
+			continue
+		}
+
+		lb, err := newLoadBalancer(cfg)
+		if err != nil {
+			lastError = errors.Wrapf(err, "invalid params %v", cfg)
+			continue
+		}
+
+		glog.Infof("Starting load balancer %v on %v", id, lb.Listen.String())
+		err = startLoadBalancer(lb)
+		if err != nil {
+			lastError = err
+			glog.Errorf("Unable to start load balancer %v: %v", id, err)
+			continue
+		}
+
+		gLoadBalancers.m[id] = loadBalancer{cfg: cfg, lb: lb}
+	}
+
+	return errors.Wrapf(lastError, "load balancers")
+}
+
+//gPortForwards holds the port forwards rendered by the CNCI, by UUID, and
+//the number of forwarded ports of each public IP assigned to the CNCI
+var gPortForwards = struct {
+	sync.Mutex
+	m   map[string]payloads.PortForward
+	ips map[string]int
+}{m: make(map[string]payloads.PortForward), ips: make(map[string]int)}
+
+func portForwardRule(cfg payloads.PortForward) (libsnnet.PortForwardRule, error) {
+	rule := libsnnet.PortForwardRule{
+		Protocol:     cfg.Protocol,
+		PublicIP:     net.ParseIP(cfg.PublicIP),
+		PublicPort:   cfg.PublicPort,
+		InternalIP:   net.ParseIP(cfg.PrivateIP),
+		InternalPort: cfg.PrivatePort,
+	}
+
+	if rule.PublicIP == nil {
+		return rule, errors.Errorf("invalid public IP %v", cfg.PublicIP)
+	}
+
+	if rule.InternalIP == nil {
+		return rule, errors.Errorf("invalid private IP %v", cfg.PrivateIP)
+	}
+
+	return rule, nil
+}
+
+//addPortForward forwards a port, assigning its public IP to the CNCI when
+//none of its other ports are forwarded. Lock must be held by caller
+func addPortForward(rule libsnnet.PortForwardRule) error {
+	extIf := gCnci.ComputeLink[0].Attrs().Name
+	puIP := rule.PublicIP.String()
+
+	if gPortForwards.ips[puIP] == 0 {
+		err := libsnnet.ExternalIPAssign(libsnnet.FwEnable, rule.PublicIP, extIf)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := gFw.PortForward(libsnnet.FwEnable, rule)
+	if err != nil {
+		if gPortForwards.ips[puIP] == 0 {
+			_ = libsnnet.ExternalIPAssign(libsnnet.FwDisable, rule.PublicIP, extIf)
+		}
+		return err
+	}
+
+	gPortForwards.ips[puIP]++
+	return nil
+}
+
+//removePortForward stops forwarding a port, unassigning its public IP
+//from the CNCI when none of its other ports are forwarded. Lock must be
+//held by caller
+func removePortForward(rule libsnnet.PortForwardRule) error {
+	puIP := rule.PublicIP.String()
+
+	err := gFw.PortForward(libsnnet.FwDisable, rule)
+
+	gPortForwards.ips[puIP]--
+	if gPortForwards.ips[puIP] > 0 {
+		return err
+	}
+
+	delete(gPortForwards.ips, puIP)
+	ipErr := libsnnet.ExternalIPAssign(libsnnet.FwDisable, rule.PublicIP, gCnci.ComputeLink[0].Attrs().Name)
+	if err == nil {
+		err = ipErr
+	}
+	return err
+}
+
+//setPortForwards replaces the port forwards rendered by the CNCI
+func setPortForwards(cmd *payloads.PortForwardsCmd) error {
+	var lastError error
+
+	gPortForwards.Lock()
+	defer gPortForwards.Unlock()
+
+	wanted := make(map[string]payloads.PortForward)
+	for _, cfg := range cmd.PortForwards {
+		wanted[cfg.PortForwardUUID] = cfg
+	}
+
+	for id, current := range gPortForwards.m {
+		if cfg, ok := wanted[id]; ok && cfg == current {
+			continue
+		}
+
+		glog.Infof("Removing port forward %v", id)
+		rule, _ := portForwardRule(current)
+		err := removePortForward(rule)
+		if err != nil {
+			lastError = err
+			glog.Errorf("Unable to remove port forward %v: %v", id, err)
+		}
+		delete(gPortForwards.m, id)
+	}
+
+	for id, cfg := range wanted {
+		if _, ok := gPortForwards.m[id]; ok {
+			continue
+		}
+
+		rule, err := portForwardRule(cfg)
+		if err != nil {
+			lastError = errors.Wrapf(err, "invalid params %v", cfg)
+			continue
+		}
+
+		glog.Infof("Adding port forward %v", id)
+		err = addPortForward(rule)
+		if err != nil {
+			lastError = err
+			glog.Errorf("Unable to add port forward %v: %v", id, err)
+			continue
+		}
+
+		gPortForwards.m[id] = cfg
+	}
+
+	return errors.Wrapf(lastError, "port forwards")
+}
+
+//setTenantDNS replaces the DNS records served by the CNCI on all the
+//subnets of the tenant
+func setTenantDNS(cmd *payloads.TenantDNSCmd) error {
+	var records []libsnnet.DNSRecord
+
+	for _, r := range cmd.Records {
+		record := libsnnet.DNSRecord{
+			Name:   r.Name,
+			IPAddr: net.ParseIP(r.IP),
+		}
+
+		if record.IPAddr == nil {
+			glog.Warningf("Invalid IP %v for %v", r.IP, r.Name)
+			continue
+		}
+
+		if r.IPv6 != "" {
+			record.IPv6Addr = net.ParseIP(r.IPv6)
+		}
+
+		records = append(records, record)
+	}
+
+	err := gCnci.SetDNSRecords(cmd.Zone, records)
+	return errors.Wrapf(err, "tenant DNS")
+}
+
+//setTenantRoutes replaces the routes to the other subnets of the tenant
+//handed out by the CNCI to the instances of its subnet
+func setTenantRoutes(cmd *payloads.TenantRoutesCmd) error {
+	var subnets []net.IPNet
+
+	for _, s := range cmd.Subnets {
+		_, subnet, err := net.ParseCIDR(s.Subnet)
+		if err != nil {
+			glog.Warningf("Invalid subnet %v: %v", s.Subnet, err)
+			continue
+		}
+		subnets = append(subnets, *subnet)
+	}
+
+	err := gCnci.SetRoutedSubnets(subnets)
+	return errors.Wrapf(err, "tenant routes")
+}
@@ -18,6 +18,7 @@ package libsnnet
 
 import (
 	"net"
+	"syscall"
 
 	"github.com/vishvananda/netlink"
 )
@@ -139,12 +140,17 @@ func (b *Bridge) Disable() error {
 }
 
 // AddIP Adds an IP Address to the bridge
+// IPv6 addresses skip duplicate address detection so that services can
+// bind to them right away
 func (b *Bridge) AddIP(ip *net.IPNet) error {
 	if b.Link == nil || b.Link.Index == 0 {
 		return netError(b, "add ip bridge unnitialized")
 	}
 
 	addr := &netlink.Addr{IPNet: ip}
+	if ip.IP.To4() == nil {
+		addr.Flags = syscall.IFA_F_NODAD
+	}
 
 	if err := netlink.AddrAdd(b.Link, addr); err != nil {
 		return netError(b, "assigning IP address to bridge %v %v", addr.String(), err)
@@ -66,6 +66,7 @@ type VnicConfig struct {
 	TenantID   string // UUID
 	SubnetID   string // UUID
 	ConcID     string // UUID
+	Bandwidth  BandwidthLimits
 }
 
 // CNSsntpEvent to be generated in response to a VNIC creation
@@ -94,7 +95,8 @@ type SsntpEventInfo struct {
 	ConcID            string       // CNCI UUID
 	CnID              string       // CN UUID
 	SubnetKey         int
-	containerSubnetID string // Logical name of the container network.
+	Mode              NetworkMode // Type of the tunnel to the CNCI
+	containerSubnetID string      // Logical name of the container network.
 	// Hack: Will be removed once we drop deprecated APIs
 }
 
@@ -183,6 +185,11 @@ type ComputeNode struct {
 
 	*cnTopology
 	apiThrottleSem chan int
+	routerLock     sync.Mutex //Serializes the tenant router updates
+
+	secGroupLock sync.Mutex                    //Serializes the security group updates
+	secGroups    map[string][]InstanceSecurity //Security rules by tenant
+	ip6t         ipTables                      //Set once IPv6 rules are enforced
 }
 
 //Adds a physical link to the management or compute network
@@ -289,7 +296,7 @@ func (cn *ComputeNode) Init() error {
 	}
 
 	//TODO: Support all modes
-	if cn.Mode != GreTunnel {
+	if cn.Mode != GreTunnel && cn.Mode != VxlanTunnel {
 		return NewAPIError(fmt.Sprintf("Unsupported network mode %v", cn.Mode))
 	}
 
@@ -308,12 +315,14 @@ type vnicAliases struct {
 	bridge string
 	vnic   string
 	gre    string
+	vxlan  string
 }
 
 const (
 	bridgePrefix   = "br_"
 	vnicPrefix     = "vnic_"
 	grePrefix      = "gre_"
+	vxlanPrefix    = "vxlan_"
 	cnciVnicPrefix = "cncivnic_"
 )
 
@@ -352,12 +361,25 @@ func genCnVnicAliases(cfg *VnicConfig) *vnicAliases {
 		cfg.ConcID,
 		cfg.ConcIP)
 
+	vnic.vxlan = fmt.Sprintf("%s%s_%s_%s_%s", vxlanPrefix,
+		cfg.TenantID,
+		cfg.SubnetID,
+		cfg.ConcID,
+		cfg.ConcIP)
+
+	//The router ports of a tenant have no address of their own on the
+	//subnet, they all share the router address
+	id := cfg.VnicIP.String()
+	if cfg.VnicRole == TenantRouter {
+		id = routerPortID
+	}
+
 	vnic.vnic = fmt.Sprintf("%s%s_%s_%s_%s##%s", vnicPrefix,
 		cfg.TenantID,
 		cfg.SubnetID,
 		cfg.ConcID,
 		cfg.ConcIP,
-		cfg.VnicIP)
+		id)
 
 	return vnic
 }
@@ -428,13 +450,16 @@ func (cn *ComputeNode) rebuildVnicMap(links []netlink.Link) error {
 				id = strings.Split(id, "##")[0]
 				bridge := bridgePrefix + id
 				gre := grePrefix + id
+				vxlan := vxlanPrefix + id
 				if _, err := cn.dbUpdate(bridge, vnic, dbInsVnic); err != nil {
 					return NewFatalError("db rebuild: add vnic" + err.Error())
 				}
-				if _, ok := cn.linkMap[gre]; !ok {
-					return NewFatalError("db rebuild: missing gre tunnel " + gre)
+				_, greOk := cn.linkMap[gre]
+				_, vxlanOk := cn.linkMap[vxlan]
+				if !greOk && !vxlanOk {
+					return NewFatalError("db rebuild: missing tunnel " + gre)
 				}
-				if link.Type() == "veth" {
+				if link.Type() == "veth" && !routerPortAlias(vnic) {
 					cn.containerMap[bridge] = true
 				}
 			}
@@ -705,12 +730,18 @@ func newCNVnic(cfg *VnicConfig, id string) (*Vnic, error) {
 		vnic, err = NewVnic(id)
 	case TenantContainer:
 		vnic, err = NewContainerVnic(id)
+	case TenantRouter:
+		vnic, err = newRouterVnic(id)
 	}
 	if err != nil {
 		return nil, NewAPIError(err.Error())
 	}
-	vnic.MACAddr = &cfg.VnicMAC
+	//Router ports use the random MAC address given by the kernel
+	if cfg.VnicMAC != nil {
+		vnic.MACAddr = &cfg.VnicMAC
+	}
 	vnic.MTU = cfg.MTU
+	vnic.Bandwidth = cfg.Bandwidth
 
 	return vnic, nil
 }
@@ -739,7 +770,25 @@ func (cn *ComputeNode) waitForExistingVnic(vnic *Vnic, bridge *Bridge, vLink *li
 
 }
 
-func (cn *ComputeNode) createDevicesFromCfg(cfg *VnicConfig) (*Vnic, *Bridge, *GreTunEP, error) {
+// tunnelAlias returns the alias of the tunnel to the CNCI of a subnet
+// for the network mode of the compute node
+func (cn *ComputeNode) tunnelAlias(alias *vnicAliases) string {
+	if cn.Mode == VxlanTunnel {
+		return alias.vxlan
+	}
+	return alias.gre
+}
+
+// newTunnelEP creates the tunnel to the CNCI of a subnet for the network
+// mode of the compute node. The subnet key is the GRE key or the VNI
+func (cn *ComputeNode) newTunnelEP(alias *vnicAliases, local net.IP, remote net.IP, key int) (tunnelEP, error) {
+	if cn.Mode == VxlanTunnel {
+		return newVxlanTunEP(alias.vxlan, local, remote, uint32(key))
+	}
+	return newGreTunEP(alias.gre, local, remote, uint32(key))
+}
+
+func (cn *ComputeNode) createDevicesFromCfg(cfg *VnicConfig) (*Vnic, *Bridge, tunnelEP, error) {
 
 	alias := genCnVnicAliases(cfg)
 
@@ -754,12 +803,12 @@ func (cn *ComputeNode) createDevicesFromCfg(cfg *VnicConfig) (*Vnic, *Bridge, *G
 	}
 
 	local := cn.ComputeAddr[0].IPNet.IP
-	gre, err := newGreTunEP(alias.gre, local, cfg.ConcIP, uint32(cfg.SubnetKey))
+	tunnel, err := cn.newTunnelEP(alias, local, cfg.ConcIP, cfg.SubnetKey)
 	if err != nil {
 		return nil, nil, nil, NewAPIError(err.Error())
 	}
 
-	return vnic, bridge, gre, nil
+	return vnic, bridge, tunnel, nil
 
 }
 
@@ -799,7 +848,7 @@ func (cn *ComputeNode) addVnicToBridge(cfg *VnicConfig, vnic *Vnic, bridge *Brid
 func (cn *ComputeNode) createVnicInternal(cfg *VnicConfig) (*Vnic, *SsntpEventInfo, *ContainerInfo, error) {
 	var gLink *linkInfo
 
-	vnic, bridge, gre, err := cn.createDevicesFromCfg(cfg)
+	vnic, bridge, tunnel, err := cn.createDevicesFromCfg(cfg)
 
 	if err != nil {
 		return nil, nil, nil, err
@@ -831,12 +880,12 @@ func (cn *ComputeNode) createVnicInternal(cfg *VnicConfig) (*Vnic, *SsntpEventIn
 		return cn.addVnicToBridge(cfg, vnic, bridge, vLink, bLink)
 	}
 
-	if err := cn.logicallyCreateBridge(bridge, gre, vnic); err != nil {
+	if err := cn.logicallyCreateBridge(bridge, tunnel, vnic); err != nil {
 		cn.cnTopology.Unlock()
 		return nil, nil, nil, NewFatalError(err.Error())
 	}
 
-	gLink = cn.linkMap[gre.GlobalID]
+	gLink = cn.linkMap[tunnel.attrs().GlobalID]
 	defer close(gLink.ready)
 
 	bLink = cn.linkMap[bridge.GlobalID]
@@ -861,15 +910,16 @@ func (cn *ComputeNode) createVnicInternal(cfg *VnicConfig) (*Vnic, *SsntpEventIn
 		SubnetID:  cfg.SubnetID,
 		SubnetKey: cfg.SubnetKey,
 		Subnet:    cfg.Subnet.String(),
-		CnIP:      gre.LocalIP.String(),
+		CnIP:      cn.ComputeAddr[0].IPNet.IP.String(),
 		CnID:      cn.ID,
+		Mode:      cn.Mode,
 	}
 
-	if err := createAndEnableBridge(bridge, gre); err != nil {
+	if err := createAndEnableBridge(bridge, tunnel); err != nil {
 		return nil, brCreateMsg, nil, NewFatalError(err.Error())
 	}
 	bLink.index = bridge.Link.Index
-	gLink.index = gre.Link.Index
+	gLink.index = tunnel.index()
 
 	//iptables -A FORWARD -p all -i "$bridge" -j ACCEPT
 	err = cn.AppendUnique("filter", "FORWARD",
@@ -936,11 +986,12 @@ func (cn *ComputeNode) logicallyCreateVnic(vnic *Vnic) (err error) {
 //The physical devices are not yet created but their names aliases
 //are added to the topology reserving them
 //TODO: Check for global topology issues. E.g. Two tenants with same CNCI
-func (cn *ComputeNode) logicallyCreateBridge(bridge *Bridge, gre *GreTunEP, vnic *Vnic) (err error) {
+func (cn *ComputeNode) logicallyCreateBridge(bridge *Bridge, tunnel tunnelEP, vnic *Vnic) (err error) {
+	gre := tunnel.attrs()
 	if bridge.LinkName, err = cn.genLinkName(bridge); err != nil {
 		return err
 	}
-	if gre.LinkName, err = cn.genLinkName(gre); err != nil {
+	if gre.LinkName, err = cn.genLinkName(tunnel); err != nil {
 		return err
 	}
 	if _, err = cn.dbUpdate(bridge.GlobalID, "", dbInsBr); err != nil {
@@ -966,22 +1017,23 @@ func (cn *ComputeNode) logicallyCreateBridge(bridge *Bridge, gre *GreTunEP, vnic
 //Physically create the devices by calling into the kernel
 //TODO: Try to be more fault tolerant here. We may miss errors but try to
 // honor the request  e.g. If bridge exists use it and try and create tunnel
-func createAndEnableBridge(bridge *Bridge, gre *GreTunEP) error {
+func createAndEnableBridge(bridge *Bridge, tunnel tunnelEP) error {
+	tunnelID := tunnel.attrs().GlobalID
 	if err := bridge.Create(); err != nil {
 		return fmt.Errorf("Bridge creation failed %s %s", bridge.GlobalID, err.Error())
 	}
-	if err := gre.create(); err != nil {
-		return fmt.Errorf("GRE creation failed %s %s", gre.GlobalID, err.Error())
+	if err := tunnel.create(); err != nil {
+		return fmt.Errorf("Tunnel creation failed %s %s", tunnelID, err.Error())
 	}
-	if err := gre.attach(bridge); err != nil {
-		return fmt.Errorf("GRE attach failed %s %s %s", gre.GlobalID, bridge.GlobalID, err.Error())
+	if err := tunnel.attach(bridge); err != nil {
+		return fmt.Errorf("Tunnel attach failed %s %s %s", tunnelID, bridge.GlobalID, err.Error())
 	}
 
-	if err := gre.enable(); err != nil {
-		return fmt.Errorf("GRE enable failed %s %s %s", gre.GlobalID, bridge.GlobalID, err.Error())
+	if err := tunnel.enable(); err != nil {
+		return fmt.Errorf("Tunnel enable failed %s %s %s", tunnelID, bridge.GlobalID, err.Error())
 	}
 	if err := bridge.Enable(); err != nil {
-		return fmt.Errorf("Bridge enable failed %s %s %s", gre.GlobalID, bridge.GlobalID, err.Error())
+		return fmt.Errorf("Bridge enable failed %s %s %s", tunnelID, bridge.GlobalID, err.Error())
 	}
 	return nil
 }
@@ -996,8 +1048,10 @@ func createAndEnableVnic(vnic *Vnic, bridge *Bridge) error {
 	if err != nil {
 		return fmt.Errorf("VNIC Initialize Peer %s %s", vnic.GlobalID, err.Error())
 	}
-	if err := vnicPeer.SetHardwareAddr(*vnic.MACAddr); err != nil {
-		return fmt.Errorf("VNIC Set MAC Address %s %s", vnic.GlobalID, err.Error())
+	if vnic.MACAddr != nil {
+		if err := vnicPeer.SetHardwareAddr(*vnic.MACAddr); err != nil {
+			return fmt.Errorf("VNIC Set MAC Address %s %s", vnic.GlobalID, err.Error())
+		}
 	}
 	if err := vnic.SetMTU(vnic.MTU); err != nil {
 		return fmt.Errorf("VNIC Set MTU Address %s %s", vnic.GlobalID, err.Error())
@@ -1009,6 +1063,9 @@ func createAndEnableVnic(vnic *Vnic, bridge *Bridge) error {
 	if err := vnic.Enable(); err != nil {
 		return fmt.Errorf("VNIC enable failed %s %s %s", vnic.GlobalID, bridge.GlobalID, err.Error())
 	}
+	if err := vnic.setBandwidth(); err != nil {
+		return fmt.Errorf("VNIC set bandwidth failed %s %s", vnic.GlobalID, err.Error())
+	}
 	return nil
 }
 
@@ -1016,7 +1073,7 @@ func initializeVnicPeer(vnic *Vnic) (*Vnic, error) {
 	switch vnic.Role {
 	case TenantVM:
 		return vnic, nil
-	case TenantContainer:
+	case TenantContainer, TenantRouter:
 		vnicPeer, err := NewContainerVnic(vnic.GlobalID)
 		if err != nil {
 			return nil, err
@@ -1108,15 +1165,18 @@ func (cn *ComputeNode) deleteVnicInternal(vnic *Vnic, vLink *linkInfo) (err erro
 }
 
 //Note: Can only be called when holding the topology lock cn.cnTopology.Lock()
-func (cn *ComputeNode) deleteGreInternal(gre *GreTunEP, gLink *linkInfo) (err error) {
-	gre.LinkName, gre.Link.Index, err = waitForDeviceReady(gLink, cn.APITimeout)
+func (cn *ComputeNode) deleteGreInternal(tunnel tunnelEP, gLink *linkInfo) (err error) {
+	var index int
+	gre := tunnel.attrs()
+	gre.LinkName, index, err = waitForDeviceReady(gLink, cn.APITimeout)
 	if err != nil {
 		return NewFatalError(gre.GlobalID + err.Error())
 	}
+	tunnel.setIndex(index)
 
-	err = gre.destroy()
+	err = tunnel.destroy()
 	if err != nil {
-		return NewFatalError("gre destroy " + gre.GlobalID + err.Error())
+		return NewFatalError("tunnel destroy " + gre.GlobalID + err.Error())
 	}
 	delete(cn.nameMap, gre.LinkName)
 	delete(cn.linkMap, gre.GlobalID)
@@ -1200,7 +1260,7 @@ func (cn *ComputeNode) destroyVnicInternal(cfg *VnicConfig) (*SsntpEventInfo, er
 		return nil, NewFatalError(err.Error())
 	}
 
-	gre, err := newGreTunEP(alias.gre, nil, nil, 0)
+	tunnel, err := cn.newTunnelEP(alias, nil, nil, 0)
 	if err != nil {
 		return nil, NewFatalError(err.Error())
 	}
@@ -1215,18 +1275,19 @@ func (cn *ComputeNode) destroyVnicInternal(cfg *VnicConfig) (*SsntpEventInfo, er
 		Subnet:    cfg.Subnet.String(),
 		CnIP:      cn.ComputeAddr[0].IPNet.IP.String(),
 		CnID:      cn.ID,
+		Mode:      cn.Mode,
 	}
 
 	//TODO: Try and make forward progress even on error
-	gLink, present := cn.linkMap[alias.gre]
+	gLink, present := cn.linkMap[cn.tunnelAlias(alias)]
 	if present {
-		err := cn.deleteGreInternal(gre, gLink)
+		err := cn.deleteGreInternal(tunnel, gLink)
 		if err != nil {
 			return nil, err
 		}
 	} else {
 		//TODO: Consider logging this and continue to delete bridge
-		return nil, NewFatalError(fmt.Sprintf("gre tunnel not present %s", gre.GlobalID))
+		return nil, NewFatalError(fmt.Sprintf("tunnel not present %s", tunnel.attrs().GlobalID))
 	}
 
 	bLink, present := cn.linkMap[alias.bridge]
@@ -0,0 +1,378 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+/*
+The CNCI only filters the traffic it routes. The traffic between the
+instances of a subnet is bridged, on the compute nodes and on the CNCI, and
+the traffic routed by the router of a tenant on a compute node never reaches
+the CNCI. So the compute nodes also enforce the security rules of the
+instances they run, on the bridge ports of their VNICs.
+
+The bridged traffic is passed through iptables by br_netfilter. The traffic
+of the tenant bridges jumps to the main chain, which sends the traffic from
+a guarded port to the egress chain and the traffic to a guarded port to the
+ingress chain. The egress chain checks the rules of the source of the
+traffic and then sends it to the ingress chain, which checks the rules of
+its destination. The rest of the bridged traffic is accepted.
+
+Routed traffic crosses a bridge between the instance and the router of the
+tenant, so it is filtered as well.
+*/
+
+const (
+	procBridgeNfIPTables  = "/proc/sys/net/bridge/bridge-nf-call-iptables"
+	procBridgeNfIP6Tables = "/proc/sys/net/bridge/bridge-nf-call-ip6tables"
+
+	brSecGroupChain    = "ciao-sg-br"
+	brSecGroupInChain  = "ciao-sg-br-in"
+	brSecGroupOutChain = "ciao-sg-br-out"
+	newChainSuffix     = "-new"
+)
+
+//InstanceSecurity holds the security rules of an instance of a tenant
+type InstanceSecurity struct {
+	IP    net.IP         //IPv4 address of the VNIC of the instance
+	IPv6  net.IP         //IPv6 address of the VNIC, nil if it has none
+	Rules []SecurityRule //Rules of both addresses
+}
+
+//guardedPort is the bridge port of the VNIC of an instance and the rules
+//of one of its addresses
+type guardedPort struct {
+	name  string
+	ip    net.IP
+	rules []SecurityRule
+}
+
+//bridgeMatch matches the bridged traffic of the tenant bridges
+func bridgeMatch() []string {
+	return []string{"-o", prefixBridge + "+", "-m", "physdev", "--physdev-is-bridged"}
+}
+
+//bridgeInfraRules match the traffic the instances need to configure their
+//addresses, which is never filtered
+func bridgeInfraRules(ipv4 bool) (egress [][]string, ingress [][]string) {
+	if ipv4 {
+		return [][]string{{"-p", "udp", "--sport", "68", "--dport", "67"}},
+			[][]string{{"-p", "udp", "--sport", "67", "--dport", "68"}}
+	}
+
+	icmp := func(t string) []string {
+		return []string{"-p", "icmpv6", "--icmpv6-type", t}
+	}
+
+	return [][]string{
+			{"-p", "udp", "--sport", "546", "--dport", "547"},
+			icmp("router-solicitation"),
+			icmp("neighbour-solicitation"),
+			icmp("neighbour-advertisement"),
+		}, [][]string{
+			{"-p", "udp", "--sport", "547", "--dport", "546"},
+			icmp("router-advertisement"),
+			icmp("neighbour-solicitation"),
+			icmp("neighbour-advertisement"),
+		}
+}
+
+//bridgeSecurityChains returns the rules of the main, egress and ingress
+//chains filtering the bridged traffic of the guarded ports of one address
+//family. The egress and ingress chains are named in and out
+func bridgeSecurityChains(ports []guardedPort, ipv4 bool, in string, out string) ([][]string, [][]string, [][]string, error) {
+	var main, egress, ingress [][]string
+
+	for _, p := range ports {
+		main = append(main, []string{"-m", "physdev", "--physdev-in", p.name, "-g", out})
+	}
+	for _, p := range ports {
+		main = append(main, []string{"-m", "physdev", "--physdev-out", p.name, "-g", in})
+	}
+	main = append(main, []string{"-j", "ACCEPT"})
+
+	established := []string{"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT"}
+	egress = append(egress, established)
+	ingress = append(ingress, established)
+
+	infraEgress, infraIngress := bridgeInfraRules(ipv4)
+	for _, spec := range infraEgress {
+		egress = append(egress, append(spec, "-g", in))
+	}
+	for _, spec := range infraIngress {
+		ingress = append(ingress, append(spec, "-j", "ACCEPT"))
+	}
+
+	for _, p := range ports {
+		for _, r := range p.rules {
+			match, err := r.ruleMatch()
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("Invalid security rule %+v: %v", r, err)
+			}
+
+			if r.Direction == FwEgress {
+				spec := append([]string{"-m", "physdev", "--physdev-in", p.name}, match...)
+				egress = append(egress, append(spec, "-g", in))
+			} else {
+				spec := append([]string{"-m", "physdev", "--physdev-out", p.name}, match...)
+				ingress = append(ingress, append(spec, "-j", "ACCEPT"))
+			}
+		}
+	}
+
+	egress = append(egress, []string{"-j", "DROP"})
+
+	for _, p := range ports {
+		ingress = append(ingress, []string{"-m", "physdev", "--physdev-out", p.name, "-j", "DROP"})
+	}
+	ingress = append(ingress, []string{"-j", "ACCEPT"})
+
+	return main, egress, ingress, nil
+}
+
+//bridgeSecurityGroups replaces the chains filtering the bridged traffic of
+//the guarded ports of one address family. The rules are built in new
+//chains which then replace the current ones
+func bridgeSecurityGroups(ipt ipTables, ports []guardedPort, ipv4 bool) error {
+	newMain := brSecGroupChain + newChainSuffix
+	newIn := brSecGroupInChain + newChainSuffix
+	newOut := brSecGroupOutChain + newChainSuffix
+
+	main, egress, ingress, err := bridgeSecurityChains(ports, ipv4, newIn, newOut)
+	if err != nil {
+		return err
+	}
+
+	//The chains jumped to are created first
+	chains := []struct {
+		name  string
+		specs [][]string
+	}{
+		{newIn, ingress},
+		{newOut, egress},
+		{newMain, main},
+	}
+
+	for _, c := range chains {
+		//ClearChain creates the chain if it does not exist
+		if err := ipt.ClearChain("filter", c.name); err != nil {
+			return fmt.Errorf("Unable to create chain %s: %v", c.name, err)
+		}
+
+		for _, spec := range c.specs {
+			if err := ipt.Append("filter", c.name, spec...); err != nil {
+				return fmt.Errorf("Unable to add rule %v to %s: %v", spec, c.name, err)
+			}
+		}
+	}
+
+	//The jump may be left over from a previously failed update
+	jump := append(bridgeMatch(), "-j", newMain)
+	ok, err := ipt.Exists("filter", "FORWARD", jump...)
+	if err != nil {
+		return fmt.Errorf("Unable to verify chain %s: %v", newMain, err)
+	}
+	if !ok {
+		if err := ipt.Insert("filter", "FORWARD", 1, jump...); err != nil {
+			return fmt.Errorf("Unable to enable chain %s: %v", newMain, err)
+		}
+	}
+
+	//The main chain refers to the others, it is deleted first
+	if err := deleteSecGroupChain(ipt, brSecGroupChain, bridgeMatch()...); err != nil {
+		return err
+	}
+	if err := deleteSecGroupChain(ipt, brSecGroupInChain); err != nil {
+		return err
+	}
+	if err := deleteSecGroupChain(ipt, brSecGroupOutChain); err != nil {
+		return err
+	}
+
+	for _, chain := range []string{brSecGroupInChain, brSecGroupOutChain, brSecGroupChain} {
+		if err := ipt.RenameChain("filter", chain+newChainSuffix, chain); err != nil {
+			return fmt.Errorf("Unable to rename chain %s: %v", chain+newChainSuffix, err)
+		}
+	}
+
+	return nil
+}
+
+//enableBridgeFiltering passes the bridged traffic through iptables and
+//ip6tables
+func enableBridgeFiltering() error {
+	if _, err := os.Stat(procBridgeNfIPTables); err != nil {
+		out, err := exec.Command("modprobe", "br_netfilter").CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("Unable to load br_netfilter: %v %s",
+				err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if err := writeProc(procBridgeNfIPTables, "1"); err != nil {
+		return err
+	}
+
+	return writeProc(procBridgeNfIP6Tables, "1")
+}
+
+//guardedPorts returns the IPv4 and IPv6 guarded ports of the instances of
+//the node that have security rules. The addresses of the VNICs are part of
+//their aliases
+//Note: Can only be called when holding cn.secGroupLock
+func (cn *ComputeNode) guardedPorts() ([]guardedPort, []guardedPort) {
+	var tenants []string
+	for tenantID := range cn.secGroups {
+		tenants = append(tenants, tenantID)
+	}
+	sort.Strings(tenants)
+
+	cn.cnTopology.Lock()
+	defer cn.cnTopology.Unlock()
+
+	var ports4, ports6 []guardedPort
+	for _, tenantID := range tenants {
+		prefix := vnicPrefix + tenantID + "_"
+		names := make(map[string]string)
+		for alias, link := range cn.linkMap {
+			if !strings.HasPrefix(alias, prefix) || routerPortAlias(alias) {
+				continue
+			}
+			if i := strings.LastIndex(alias, "##"); i >= 0 {
+				names[alias[i+2:]] = link.name
+			}
+		}
+
+		for _, i := range cn.secGroups[tenantID] {
+			name, ok := names[i.IP.String()]
+			if !ok {
+				continue
+			}
+
+			port4 := guardedPort{name: name, ip: i.IP}
+			port6 := guardedPort{name: name, ip: i.IPv6}
+			for _, r := range i.Rules {
+				if r.InstanceIP.To4() != nil {
+					port4.rules = append(port4.rules, r)
+				} else {
+					port6.rules = append(port6.rules, r)
+				}
+			}
+
+			ports4 = append(ports4, port4)
+			if i.IPv6 != nil {
+				ports6 = append(ports6, port6)
+			}
+		}
+	}
+
+	return ports4, ports6
+}
+
+//renderSecurityGroups enforces the security rules of the node on the
+//current VNICs
+//Note: Can only be called when holding cn.secGroupLock
+func (cn *ComputeNode) renderSecurityGroups() error {
+	ports4, ports6 := cn.guardedPorts()
+
+	if len(ports4) > 0 {
+		if err := enableBridgeFiltering(); err != nil {
+			return NewFatalError(err.Error())
+		}
+	}
+
+	if err := bridgeSecurityGroups(cn.IPTables, ports4, true); err != nil {
+		return NewFatalError(err.Error())
+	}
+
+	if len(ports6) == 0 && cn.ip6t == nil {
+		return nil
+	}
+
+	if cn.ip6t == nil {
+		ip6t, err := newIP6Tables()
+		if err != nil {
+			return NewFatalError(fmt.Sprintf("Unable to setup ip6tables %v", err))
+		}
+		cn.ip6t = ip6t
+	}
+
+	if err := bridgeSecurityGroups(cn.ip6t, ports6, false); err != nil {
+		return NewFatalError(err.Error())
+	}
+
+	return nil
+}
+
+// SetSecurityGroups replaces the security rules of the instances of a
+// tenant enforced on the compute node. The rules of an instance are
+// enforced on the bridge port of its VNIC, so the traffic it exchanges with
+// the other instances of its subnet is filtered, whether they run on the
+// same node or not, as is the traffic routed to it by the router of the
+// tenant. Instances without a VNIC on the node are ignored until one is
+// created, see RefreshSecurityGroups.
+func (cn *ComputeNode) SetSecurityGroups(tenantID string, instances []InstanceSecurity) error {
+	if cn.cnTopology == nil || tenantID == "" {
+		return NewAPIError("invalid security groups configuration")
+	}
+
+	for _, i := range instances {
+		if i.IP.To4() == nil || (i.IPv6 != nil && i.IPv6.To4() != nil) {
+			return NewAPIError(fmt.Sprintf("invalid instance addresses %v %v", i.IP, i.IPv6))
+		}
+	}
+
+	cn.secGroupLock.Lock()
+	defer cn.secGroupLock.Unlock()
+
+	if cn.secGroups == nil {
+		cn.secGroups = make(map[string][]InstanceSecurity)
+	}
+
+	if len(instances) == 0 {
+		delete(cn.secGroups, tenantID)
+	} else {
+		cn.secGroups[tenantID] = instances
+	}
+
+	return cn.renderSecurityGroups()
+}
+
+// RefreshSecurityGroups enforces the security rules set on the compute node
+// on its current VNICs. It is meant to be called after a VNIC of a tenant
+// instance is created or destroyed.
+func (cn *ComputeNode) RefreshSecurityGroups() error {
+	if cn.cnTopology == nil {
+		return NewAPIError("invalid security groups configuration")
+	}
+
+	cn.secGroupLock.Lock()
+	defer cn.secGroupLock.Unlock()
+
+	if cn.secGroups == nil {
+		return nil
+	}
+
+	return cn.renderSecurityGroups()
+}
@@ -0,0 +1,207 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func joinSpecs(specs [][]string) []string {
+	var joined []string
+	for _, spec := range specs {
+		joined = append(joined, strings.Join(spec, " "))
+	}
+	return joined
+}
+
+//Tests the filtering of the bridged traffic between two instances
+//of the same subnet on a compute node
+//
+//Test checks that the bridge ports of the VNICs of the instances
+//are guarded and that an ingress rule of the first instance only
+//accepts the traffic of the second one sent to its port, after the
+//egress rules of the second instance are checked
+//
+//Test is expected to pass
+func TestCN_SecurityGroupsSameSubnet(t *testing.T) {
+	assert := assert.New(t)
+
+	cn := &ComputeNode{cnTopology: newCnTopology()}
+
+	ipA := net.ParseIP("172.16.0.2")
+	ipB := net.ParseIP("172.16.0.3")
+	_, remoteB, _ := net.ParseCIDR("172.16.0.3/32")
+
+	for _, vnic := range []struct {
+		ip   net.IP
+		name string
+	}{{ipA, "svnA"}, {ipB, "svnB"}} {
+		cfg := &VnicConfig{
+			TenantID: "tenant",
+			SubnetID: "subnet",
+			ConcID:   "cnci",
+			ConcIP:   net.ParseIP("192.168.0.100"),
+			VnicIP:   vnic.ip,
+			VnicRole: TenantVM,
+		}
+		cn.linkMap[genCnVnicAliases(cfg).vnic] = &linkInfo{name: vnic.name}
+	}
+
+	cn.secGroups = map[string][]InstanceSecurity{
+		"tenant": {
+			{
+				IP: ipA,
+				Rules: []SecurityRule{
+					{Direction: FwIngress, InstanceIP: ipA,
+						Protocol: "tcp", PortMin: 22, Remote: remoteB},
+				},
+			},
+			{
+				IP: ipB,
+				Rules: []SecurityRule{
+					{Direction: FwEgress, InstanceIP: ipB},
+				},
+			},
+			{
+				IP: net.ParseIP("172.16.0.4"),
+			},
+		},
+		"other": {
+			{
+				IP: ipA,
+			},
+		},
+	}
+
+	ports, ports6 := cn.guardedPorts()
+	require.Len(t, ports, 2)
+	assert.Empty(ports6)
+	assert.Equal("svnA", ports[0].name)
+	assert.Equal("svnB", ports[1].name)
+
+	main, egress, ingress, err := bridgeSecurityChains(ports, true, "in", "out")
+	require.Nil(t, err)
+
+	assert.Equal([]string{
+		"-m physdev --physdev-in svnA -g out",
+		"-m physdev --physdev-in svnB -g out",
+		"-m physdev --physdev-out svnA -g in",
+		"-m physdev --physdev-out svnB -g in",
+		"-j ACCEPT",
+	}, joinSpecs(main))
+
+	assert.Equal([]string{
+		"-m state --state RELATED,ESTABLISHED -j ACCEPT",
+		"-p udp --sport 68 --dport 67 -g in",
+		"-m physdev --physdev-in svnB -s 172.16.0.3/32 -g in",
+		"-j DROP",
+	}, joinSpecs(egress))
+
+	assert.Equal([]string{
+		"-m state --state RELATED,ESTABLISHED -j ACCEPT",
+		"-p udp --sport 67 --dport 68 -j ACCEPT",
+		"-m physdev --physdev-out svnA -d 172.16.0.2/32 -s 172.16.0.3/32 -p tcp --dport 22 -j ACCEPT",
+		"-m physdev --physdev-out svnA -j DROP",
+		"-m physdev --physdev-out svnB -j DROP",
+		"-j ACCEPT",
+	}, joinSpecs(ingress))
+
+	ports[0].rules = append(ports[0].rules, SecurityRule{Direction: FwIngress})
+	_, _, _, err = bridgeSecurityChains(ports, true, "in", "out")
+	assert.NotNil(err)
+}
+
+//Tests the filtering of the traffic routed by the router of a tenant
+//between two of its subnets
+//
+//Test checks that the router ports are not guarded, so that the
+//traffic routed to an instance is checked against its ingress rules
+//on its bridge port and the traffic sent by an instance against its
+//egress rules
+//
+//Test is expected to pass
+func TestCN_SecurityGroupsRouted(t *testing.T) {
+	assert := assert.New(t)
+
+	cn := &ComputeNode{cnTopology: newCnTopology()}
+
+	ipA := net.ParseIP("172.16.0.2")
+	ipB := net.ParseIP("172.16.1.2")
+	_, subnetA, _ := net.ParseCIDR("172.16.0.0/24")
+
+	for _, vnic := range []struct {
+		subnet string
+		ip     net.IP
+		role   VnicRole
+		name   string
+	}{
+		{"subnetA", ipA, TenantVM, "svnA"},
+		{"subnetB", ipB, TenantVM, "svnB"},
+		{"subnetA", nil, TenantRouter, "svpRouterA"},
+		{"subnetB", nil, TenantRouter, "svpRouterB"},
+	} {
+		cfg := &VnicConfig{
+			TenantID: "tenant",
+			SubnetID: vnic.subnet,
+			ConcID:   "cnci",
+			ConcIP:   net.ParseIP("192.168.0.100"),
+			VnicIP:   vnic.ip,
+			VnicRole: vnic.role,
+		}
+		cn.linkMap[genCnVnicAliases(cfg).vnic] = &linkInfo{name: vnic.name}
+	}
+
+	cn.secGroups = map[string][]InstanceSecurity{
+		"tenant": {
+			{
+				IP: ipA,
+				Rules: []SecurityRule{
+					{Direction: FwEgress, InstanceIP: ipA, Protocol: "tcp"},
+				},
+			},
+			{
+				IP: ipB,
+				Rules: []SecurityRule{
+					{Direction: FwIngress, InstanceIP: ipB,
+						Protocol: "tcp", PortMin: 80, Remote: subnetA},
+				},
+			},
+		},
+	}
+
+	ports, _ := cn.guardedPorts()
+	require.Len(t, ports, 2)
+	assert.Equal("svnA", ports[0].name)
+	assert.Equal("svnB", ports[1].name)
+
+	main, egress, ingress, err := bridgeSecurityChains(ports, true, "in", "out")
+	require.Nil(t, err)
+
+	for _, spec := range joinSpecs(append(append(main, egress...), ingress...)) {
+		assert.NotContains(spec, "svpRouter")
+	}
+
+	assert.Contains(joinSpecs(egress),
+		"-m physdev --physdev-in svnA -s 172.16.0.2/32 -p tcp -g in")
+	assert.Contains(joinSpecs(ingress),
+		"-m physdev --physdev-out svnB -d 172.16.1.2/32 -s 172.16.0.0/24 -p tcp --dport 80 -j ACCEPT")
+}
@@ -56,6 +56,17 @@ type Cnci struct {
 	PublicIPMap map[string]net.IP //Key is public IPNet
 
 	topology *cnciTopology
+	dns      cnciDNS
+}
+
+//DNS zone of the tenant and subnets routed by the compute nodes, served
+//on all the subnets of the concentrator. The lock is held while the
+//dnsmasq of a new subnet is started so that it serves the latest records
+type cnciDNS struct {
+	sync.Mutex
+	zone    string
+	records []DNSRecord
+	routed  []net.IPNet
 }
 
 //Network topology of the node
@@ -82,9 +93,19 @@ func reinitTopology(topology *cnciTopology) {
 
 type bridgeInfo struct {
 	tunnels int
+	remotes map[string]bool //CN IPs of the VXLAN tunnel of the bridge
+	mode    NetworkMode     //Type of the tunnels of the subnet
 	*Dnsmasq
 }
 
+//tunnelMode returns the network mode of a tunnel
+func tunnelMode(tunnel tunnelEP) NetworkMode {
+	if _, ok := tunnel.(*VxlanTunEP); ok {
+		return VxlanTunnel
+	}
+	return GreTunnel
+}
+
 func enableForwarding() error {
 	return nil
 }
@@ -244,7 +265,8 @@ func (cnci *Cnci) rebuildBridgeMap(links []netlink.Link) error {
 			return (err)
 		}
 
-		dns, err := startDnsmasq(br, cnci.Tenant, *subnet)
+		//The zone is set again once the agent replays its state
+		dns, err := startDnsmasq(br, cnci.Tenant, *subnet, nil)
 		if err != nil {
 			return (err)
 		}
@@ -279,6 +301,53 @@ func (cnci *Cnci) verifyTopology(links []netlink.Link) error {
 			return fmt.Errorf("missing bridge map for gre tunnel %s", gre)
 		}
 		brInfo.tunnels++
+		brInfo.mode = GreTunnel
+	}
+
+	return cnci.verifyVxlanTopology(links)
+}
+
+func (cnci *Cnci) verifyVxlanTopology(links []netlink.Link) error {
+	for _, link := range links {
+		if link.Type() != "vxlan" {
+			continue
+		}
+
+		alias := link.Attrs().Alias
+		if !strings.HasPrefix(alias, vxlanPrefix) {
+			continue
+		}
+
+		bridgeID := bridgePrefix + strings.TrimPrefix(alias, vxlanPrefix)
+
+		if _, ok := cnci.topology.linkMap[bridgeID]; !ok {
+			return fmt.Errorf("missing bridge for vxlan tunnel %s", alias)
+		}
+
+		brInfo, ok := cnci.topology.bridgeMap[bridgeID]
+		if !ok {
+			return fmt.Errorf("missing bridge map for vxlan tunnel %s", alias)
+		}
+
+		vxlan, err := newVxlanTunEP(alias, nil, nil, 0)
+		if err != nil {
+			return err
+		}
+		if err := vxlan.getDevice(); err != nil {
+			return err
+		}
+
+		remotes, err := vxlan.remotes()
+		if err != nil {
+			return err
+		}
+
+		brInfo.mode = VxlanTunnel
+		brInfo.remotes = make(map[string]bool)
+		for _, remote := range remotes {
+			brInfo.remotes[remote.String()] = true
+			brInfo.tunnels++
+		}
 	}
 	return nil
 }
@@ -337,6 +406,11 @@ func genGreAlias(subnet net.IPNet, cnIP net.IP) string {
 	return fmt.Sprintf("%s%s##%s", grePrefix, subnetToString(subnet), cnIP.String())
 }
 
+//A single VXLAN tunnel per subnet is shared by all the CNs of the subnet
+func genVxlanAlias(subnet net.IPNet) string {
+	return fmt.Sprintf("%s%s", vxlanPrefix, subnetToString(subnet))
+}
+
 func genLinkName(device interface{}, nameMap map[string]bool) (string, error) {
 	for i := 0; i < ifaceRetryLimit; {
 		name, _ := genIface(device, false)
@@ -348,12 +422,18 @@ func genLinkName(device interface{}, nameMap map[string]bool) (string, error) {
 	return "", fmt.Errorf("Unable to generate unique device name")
 }
 
-func startDnsmasq(bridge *Bridge, tenant string, subnet net.IPNet) (*Dnsmasq, error) {
+func startDnsmasq(bridge *Bridge, tenant string, subnet net.IPNet, zone *cnciDNS) (*Dnsmasq, error) {
 	dns, err := newDnsmasq(bridge.GlobalID, tenant, subnet, 0, bridge)
 	if err != nil {
 		return nil, fmt.Errorf("NewDnsmasq failed %v", err)
 	}
 
+	if zone != nil {
+		dns.Zone = zone.zone
+		dns.Records = zone.records
+		dns.RoutedSubnets = zone.routed
+	}
+
 	if _, err = dns.attach(); err != nil {
 		err = dns.restart()
 		if err != nil {
@@ -363,7 +443,7 @@ func startDnsmasq(bridge *Bridge, tenant string, subnet net.IPNet) (*Dnsmasq, er
 	return dns, nil
 }
 
-func createCnciBridge(bridge *Bridge, brInfo *bridgeInfo, tenant string, subnet net.IPNet) (err error) {
+func createCnciBridge(bridge *Bridge, brInfo *bridgeInfo, tenant string, subnet net.IPNet, zone *cnciDNS) (err error) {
 	if bridge == nil || brInfo == nil {
 		return fmt.Errorf("nil pointer encountered bridge[%v] brInfo[%v]", bridge, brInfo)
 	}
@@ -373,11 +453,13 @@ func createCnciBridge(bridge *Bridge, brInfo *bridgeInfo, tenant string, subnet
 	if err = bridge.Enable(); err != nil {
 		return err
 	}
-	brInfo.Dnsmasq, err = startDnsmasq(bridge, tenant, subnet)
+	zone.Lock()
+	defer zone.Unlock()
+	brInfo.Dnsmasq, err = startDnsmasq(bridge, tenant, subnet, zone)
 	return err
 }
 
-func createCnciTunnel(gre *GreTunEP) (err error) {
+func createCnciTunnel(gre tunnelEP) (err error) {
 	if err = gre.create(); err != nil {
 		return err
 	}
@@ -405,9 +487,10 @@ func checkInputParams(subnet net.IPNet, subnetKey int, cnIP net.IP) error {
 //If the function returns error the bridgeName can be ignored
 //If the function does not return error and has a valid bridge name
 //then the subnet has been found and no further processing is needed
-func (cnci *Cnci) addSubnetToTopology(bridge *Bridge, gre *GreTunEP, brInfo **bridgeInfo) (brExists bool,
+func (cnci *Cnci) addSubnetToTopology(bridge *Bridge, tunnel tunnelEP, brInfo **bridgeInfo) (brExists bool,
 	greExists bool, bLink *linkInfo, gLink *linkInfo, err error) {
 	err = nil
+	gre := tunnel.attrs()
 
 	// CS Start
 	cnci.topology.Lock()
@@ -441,10 +524,16 @@ func (cnci *Cnci) addSubnetToTopology(bridge *Bridge, gre *GreTunEP, brInfo **br
 			err = fmt.Errorf("Internal error. Missing bridge info")
 			return
 		}
+		if (*brInfo).tunnels > 0 && (*brInfo).mode != tunnelMode(tunnel) {
+			cnci.topology.Unlock()
+			err = fmt.Errorf("Subnet %s uses tunnels of mode %v", bridge.GlobalID, (*brInfo).mode)
+			return
+		}
 	}
+	(*brInfo).mode = tunnelMode(tunnel)
 
 	if !greExists {
-		gre.LinkName, err = genLinkName(gre, cnci.topology.nameMap)
+		gre.LinkName, err = genLinkName(tunnel, cnci.topology.nameMap)
 		if err != nil {
 			cnci.topology.Unlock()
 			return
@@ -455,7 +544,9 @@ func (cnci *Cnci) addSubnetToTopology(bridge *Bridge, gre *GreTunEP, brInfo **br
 			ready: make(chan struct{}),
 		}
 		cnci.topology.linkMap[gre.GlobalID] = gLink
-		(*brInfo).tunnels++
+		if _, ok := tunnel.(*GreTunEP); ok {
+			(*brInfo).tunnels++
+		}
 	}
 	cnci.topology.Unlock()
 	//End CS
@@ -463,15 +554,34 @@ func (cnci *Cnci) addSubnetToTopology(bridge *Bridge, gre *GreTunEP, brInfo **br
 }
 
 //AddRemoteSubnet attaches a remote subnet to a local bridge on the CNCI
+//using tunnels of the network mode of the CNCI.
+//See AddRemoteSubnetMode
+func (cnci *Cnci) AddRemoteSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP) (string, error) {
+	return cnci.AddRemoteSubnetMode(cnci.Mode, subnet, subnetKey, cnIP)
+}
+
+//AddRemoteSubnetMode attaches a remote subnet to a local bridge on the CNCI
 //If the bridge and DHCP server does not exist it will be created.
 //If the tunnel exists and the bridge does not exist the bridge is created
 //The bridge name interface name is returned if the bridge is newly created
-func (cnci *Cnci) AddRemoteSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP) (string, error) {
+//In VXLAN mode the CN is added as a remote end point of the VXLAN tunnel of
+//the subnet, which is created along with the bridge
+//The mode is recorded per subnet, all the tunnels of a subnet have the same
+//mode
+func (cnci *Cnci) AddRemoteSubnetMode(mode NetworkMode, subnet net.IPNet, subnetKey int, cnIP net.IP) (string, error) {
 
 	if err := checkInputParams(subnet, subnetKey, cnIP); err != nil {
 		return "", err
 	}
 
+	if mode != GreTunnel && mode != VxlanTunnel {
+		return "", fmt.Errorf("Unsupported network mode %v", mode)
+	}
+
+	if mode == VxlanTunnel {
+		return cnci.addRemoteVxlanSubnet(subnet, subnetKey, cnIP)
+	}
+
 	bridge, err := NewBridge(genBridgeAlias(subnet))
 	if err != nil {
 		return "", err
@@ -495,7 +605,7 @@ func (cnci *Cnci) AddRemoteSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP)
 
 	//Now create them. This is time consuming
 	if !brExists {
-		err = createCnciBridge(bridge, brInfo, cnci.Tenant, subnet)
+		err = createCnciBridge(bridge, brInfo, cnci.Tenant, subnet, &cnci.dns)
 		bLink.index = bridge.Link.Index
 		close(bLink.ready)
 		if err != nil {
@@ -534,6 +644,7 @@ func (cnci *Cnci) AddRemoteSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP)
 //DelRemoteSubnet detaches a remote subnet from the local bridge
 //The bridge and DHCP server is kept around as they impose minimal overhead
 //and helps in the case where instances keep getting added and deleted constantly
+//The tunnel removed is of the mode recorded for the subnet
 func (cnci *Cnci) DelRemoteSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP) error {
 
 	if err := checkInputParams(subnet, subnetKey, cnIP); err != nil {
@@ -542,6 +653,17 @@ func (cnci *Cnci) DelRemoteSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP)
 
 	bridgeID := genBridgeAlias(subnet)
 
+	mode := cnci.Mode
+	cnci.topology.Lock()
+	if brInfo, present := cnci.topology.bridgeMap[bridgeID]; present && brInfo.mode != Routed {
+		mode = brInfo.mode
+	}
+	cnci.topology.Unlock()
+
+	if mode == VxlanTunnel {
+		return cnci.delRemoteVxlanSubnet(subnet, subnetKey, cnIP)
+	}
+
 	gre, err := newGreTunEP(genGreAlias(subnet, cnIP),
 		cnci.ComputeAddr[0].IPNet.IP,
 		cnIP, uint32(subnetKey))
@@ -581,6 +703,230 @@ func (cnci *Cnci) DelRemoteSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP)
 	return err
 }
 
+//Adds the CN as a remote end point of the VXLAN tunnel of the subnet
+//creating the bridge and the tunnel if needed
+func (cnci *Cnci) addRemoteVxlanSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP) (string, error) {
+
+	bridge, err := NewBridge(genBridgeAlias(subnet))
+	if err != nil {
+		return "", err
+	}
+
+	vxlan, err := newVxlanTunEP(genVxlanAlias(subnet), cnci.ComputeAddr[0].IPNet.IP, nil, uint32(subnetKey))
+	if err != nil {
+		return "", err
+	}
+
+	//Logically add the bridge and vxlan tunnel to the topology
+	var brInfo *bridgeInfo
+	brExists, vxlanExists, bLink, vLink, err := cnci.addSubnetToTopology(bridge, vxlan, &brInfo)
+	if err != nil {
+		return "", err
+	}
+
+	remote := cnIP.String()
+	cnci.topology.Lock()
+	if brInfo.remotes == nil {
+		brInfo.remotes = make(map[string]bool)
+	}
+	remoteExists := brInfo.remotes[remote]
+	if !remoteExists {
+		brInfo.remotes[remote] = true
+		brInfo.tunnels++
+	}
+	cnci.topology.Unlock()
+
+	if brExists && vxlanExists && remoteExists {
+		//The subnet already exists and is fully setup
+		return bLink.name, nil
+	}
+
+	//Now create them. This is time consuming
+	if !brExists {
+		err = createCnciBridge(bridge, brInfo, cnci.Tenant, subnet, &cnci.dns)
+		bLink.index = bridge.Link.Index
+		close(bLink.ready)
+		if err != nil {
+			//Do not leave the VXLAN hanging
+			if !vxlanExists {
+				close(vLink.ready)
+			}
+			return "", err
+		}
+	}
+
+	if !vxlanExists {
+		err = createCnciTunnel(vxlan)
+		vLink.index = vxlan.Link.Index
+		close(vLink.ready)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	bridge.LinkName, bridge.Link.Index, err = waitForDeviceReady(bLink, cnci.APITimeout)
+	if err != nil {
+		return "", err
+	}
+	vxlan.LinkName, vxlan.Link.Index, err = waitForDeviceReady(vLink, cnci.APITimeout)
+	if err != nil {
+		return "", err
+	}
+
+	if err = vxlan.attach(bridge); err != nil {
+		return "", err
+	}
+
+	if !remoteExists {
+		if err = vxlan.addRemote(cnIP); err != nil {
+			cnci.topology.Lock()
+			delete(brInfo.remotes, remote)
+			brInfo.tunnels--
+			cnci.topology.Unlock()
+			return "", err
+		}
+	}
+
+	if brExists {
+		return "", nil
+	}
+	return bridge.LinkName, nil
+}
+
+//Removes the CN from the remote end points of the VXLAN tunnel of the subnet
+//The tunnel is destroyed along with its last remote end point
+func (cnci *Cnci) delRemoteVxlanSubnet(subnet net.IPNet, subnetKey int, cnIP net.IP) error {
+
+	bridgeID := genBridgeAlias(subnet)
+
+	vxlan, err := newVxlanTunEP(genVxlanAlias(subnet),
+		cnci.ComputeAddr[0].IPNet.IP, nil, uint32(subnetKey))
+	if err != nil {
+		return err
+	}
+
+	// CS Start
+	cnci.topology.Lock()
+	defer cnci.topology.Unlock()
+
+	vLink, present := cnci.topology.linkMap[vxlan.GlobalID]
+	if !present {
+		return nil
+	}
+
+	remote := cnIP.String()
+	brInfo, present := cnci.topology.bridgeMap[bridgeID]
+	if !present || !brInfo.remotes[remote] {
+		return nil
+	}
+	delete(brInfo.remotes, remote)
+	brInfo.tunnels--
+
+	vxlan.LinkName, vxlan.Link.Index, err = waitForDeviceReady(vLink, cnci.APITimeout)
+	if err != nil {
+		return fmt.Errorf("DelRemoteSubnet %s %v", vxlan.GlobalID, err)
+	}
+
+	if len(brInfo.remotes) > 0 {
+		return vxlan.delRemote(cnIP)
+	}
+
+	delete(cnci.topology.nameMap, vxlan.LinkName)
+	delete(cnci.topology.linkMap, vxlan.GlobalID)
+	return vxlan.destroy()
+}
+
+//SetDNSRecords sets the DNS zone of the tenant and the host names served
+//in it by the dnsmasq of every subnet of the concentrator. The records
+//replace any records previously set. The dnsmasq processes are reloaded,
+//or restarted when the zone changes
+func (cnci *Cnci) SetDNSRecords(zone string, records []DNSRecord) error {
+	var lasterr error
+
+	if zone != "" && !validDNSZone(zone) {
+		return NewAPIError(fmt.Sprintf("Invalid DNS zone %q", zone))
+	}
+
+	cnci.dns.Lock()
+	defer cnci.dns.Unlock()
+
+	cnci.dns.zone = zone
+	cnci.dns.records = records
+
+	cnci.topology.Lock()
+	defer cnci.topology.Unlock()
+
+	for _, b := range cnci.topology.bridgeMap {
+		if b.Dnsmasq == nil {
+			//The dnsmasq is being started and will pick up the records
+			continue
+		}
+
+		restart := b.Dnsmasq.Zone != zone
+		b.Dnsmasq.Zone = zone
+		b.Dnsmasq.Records = records
+
+		var err error
+		if restart {
+			err = b.Dnsmasq.restart()
+		} else {
+			err = b.Dnsmasq.reload()
+		}
+		if err != nil {
+			lasterr = err
+		}
+	}
+
+	return lasterr
+}
+
+func sameSubnets(a []net.IPNet, b []net.IPNet) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].String() != b[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+//SetRoutedSubnets sets the subnets of the tenant that are routed by the
+//distributed router of the compute nodes. The dnsmasq of every subnet of
+//the concentrator serves routes to the other subnets via the router
+//address of its subnet. The dnsmasq processes whose routes change are
+//restarted, the instances pick up the routes when they renew their lease
+func (cnci *Cnci) SetRoutedSubnets(subnets []net.IPNet) error {
+	var lasterr error
+
+	cnci.dns.Lock()
+	defer cnci.dns.Unlock()
+
+	cnci.dns.routed = subnets
+
+	cnci.topology.Lock()
+	defer cnci.topology.Unlock()
+
+	for _, b := range cnci.topology.bridgeMap {
+		if b.Dnsmasq == nil {
+			//The dnsmasq is being started and will pick up the routes
+			continue
+		}
+
+		if sameSubnets(b.Dnsmasq.RoutedSubnets, subnets) {
+			continue
+		}
+		b.Dnsmasq.RoutedSubnets = subnets
+
+		if err := b.Dnsmasq.restart(); err != nil {
+			lasterr = err
+		}
+	}
+
+	return lasterr
+}
+
 //Shutdown stops all DHCP Servers. Tears down all links and tunnels
 //It will continue even on encountering an error and perform as much
 //cleanup as possible
@@ -56,16 +56,33 @@ type Dnsmasq struct {
 	MTU         int                   // MTU that takes into account the tunnel overhead
 	DomainName  string                // Domain Name to be assigned to the subnet
 
+	// The DNS zone of the tenant, such as <tenant>.ciao, and the host
+	// names of the tenant instances served in it. Names in the zone are
+	// only resolved locally, they are never forwarded
+	Zone    string
+	Records []DNSRecord
+
+	// The other subnets of the tenant, reached through the distributed
+	// router of the compute nodes instead of the gateway. They are served
+	// as classless static routes via the router address of the subnet
+	RoutedSubnets []net.IPNet
+
+	// The IPv6 subnet paired with the tenant subnet, served through
+	// DHCPv6 only. Only subnets of /16 or longer are given one
+	TenantNetIPv6 *net.IPNet
+
 	// Private fields
-	dhcpSize  int
-	subnet    net.IP    // The DHCP addresses will be served from this subnet
-	gateway   net.IPNet // The address of the bridge. Will also be default gw to the instances
-	startIP   net.IP    // First address in the DHCP range Skipping ReservedIPs
-	endIP     net.IP    // Last address in the DHCP range excluding broadcast
-	confFile  string
-	pidFile   string
-	leaseFile string
-	hostsFile string
+	dhcpSize    int
+	subnet      net.IP    // The DHCP addresses will be served from this subnet
+	gateway     net.IPNet // The address of the bridge. Will also be default gw to the instances
+	gatewayIPv6 net.IPNet // The IPv6 address of the bridge
+	startIP     net.IP    // First address in the DHCP range Skipping ReservedIPs
+	endIP       net.IP    // Last address in the DHCP range excluding broadcast
+	confFile    string
+	pidFile     string
+	leaseFile   string
+	hostsFile   string
+	addnHosts   string
 }
 
 // NewDnsmasq initializes a new dnsmasq instance and attaches it to the specified bridge
@@ -111,6 +128,10 @@ func (d *Dnsmasq) start() error {
 		return fmt.Errorf("d.createHostsFile failed %v", err)
 	}
 
+	if err := d.createAddnHostsFile(); err != nil {
+		return fmt.Errorf("d.createAddnHostsFile failed %v", err)
+	}
+
 	if err := d.Dev.AddIP(&d.gateway); err != nil {
 		_ = d.Dev.DelIP(&d.gateway) //TODO: check it already has the IP
 		if err = d.Dev.AddIP(&d.gateway); err != nil {
@@ -118,6 +139,15 @@ func (d *Dnsmasq) start() error {
 		}
 	}
 
+	if d.TenantNetIPv6 != nil {
+		if err := d.Dev.AddIP(&d.gatewayIPv6); err != nil {
+			_ = d.Dev.DelIP(&d.gatewayIPv6)
+			if err = d.Dev.AddIP(&d.gatewayIPv6); err != nil {
+				return fmt.Errorf("d.Dev.AddIP failed %v %v", err, d.gatewayIPv6.String())
+			}
+		}
+	}
+
 	if err := d.launch(); err != nil {
 		return fmt.Errorf("d.launch failed %v", err)
 	}
@@ -165,6 +195,12 @@ func (d *Dnsmasq) stop() error {
 		cumError = append(cumError, fmt.Errorf("Unable to delete bridge IP %v", err))
 	}
 
+	if d.TenantNetIPv6 != nil {
+		if err = d.Dev.DelIP(&d.gatewayIPv6); err != nil {
+			cumError = append(cumError, fmt.Errorf("Unable to delete bridge IPv6 %v", err))
+		}
+	}
+
 	if err = os.Remove(d.confFile); err != nil {
 		cumError = append(cumError, fmt.Errorf("Unable to delete file %v %v", d.confFile, err))
 	}
@@ -172,6 +208,7 @@ func (d *Dnsmasq) stop() error {
 		cumError = append(cumError, fmt.Errorf("Unable to delete file %v %v", d.hostsFile, err))
 	}
 	_ = os.Remove(d.leaseFile)
+	_ = os.Remove(d.addnHosts)
 
 	if cumError != nil {
 		allErrors := ""
@@ -214,6 +251,10 @@ func (d *Dnsmasq) reload() error {
 	if err = d.createHostsFile(); err != nil {
 		return fmt.Errorf("Unable to delete hosts file %v", err)
 	}
+	//The DNS records are reread by dnsmasq on SIGHUP
+	if err = d.createAddnHostsFile(); err != nil {
+		return fmt.Errorf("Unable to create DNS records file %v", err)
+	}
 	if err = syscall.Kill(pid, syscall.SIGHUP); err != nil {
 		return fmt.Errorf("Unable to reload/SIGHUP dnsmasq %v", err)
 	}
@@ -240,6 +281,7 @@ func (d *Dnsmasq) getFileConfiguration() error {
 	d.confFile = fmt.Sprintf("%sdnsmasq_%s.conf", configPath, d.SubnetID)
 	d.leaseFile = fmt.Sprintf("%sdnsmasq_%s.leases", leasePath, d.SubnetID)
 	d.hostsFile = fmt.Sprintf("%sdnsmasq_%s.hosts", hostsPath, d.SubnetID)
+	d.addnHosts = fmt.Sprintf("%sdnsmasq_%s.addn-hosts", hostsPath, d.SubnetID)
 
 	return nil
 }
@@ -288,6 +330,21 @@ func (d *Dnsmasq) getSubnetConfiguration() error {
 	endU32 += startU32 + uint32(d.dhcpSize)
 	binary.BigEndian.PutUint32(d.endIP, endU32)
 
+	d.TenantNetIPv6 = nil
+	if ones >= 16 {
+		subnetIPv6, err := TenantIPv6Subnet(d.TenantNet)
+		if err != nil {
+			return err
+		}
+		d.TenantNetIPv6 = &subnetIPv6
+
+		//The first address after the subnet-router anycast address
+		d.gatewayIPv6.IP = make(net.IP, net.IPv6len)
+		copy(d.gatewayIPv6.IP, subnetIPv6.IP)
+		d.gatewayIPv6.IP[net.IPv6len-1] = 1
+		d.gatewayIPv6.Mask = subnetIPv6.Mask
+	}
+
 	//Generate all valid IPs in this subnet and pre-assign a MAC address
 	for i := 0; i < d.dhcpSize; i++ {
 		vIP := make(net.IP, net.IPv4len)
@@ -305,6 +362,13 @@ func (d *Dnsmasq) getSubnetConfiguration() error {
 			IPAddr:  vIP,
 		}
 
+		if d.TenantNetIPv6 != nil {
+			dhcpEntry.IPv6Addr, err = TenantIPv6Addr(vIP, d.TenantNet)
+			if err != nil {
+				return err
+			}
+		}
+
 		if err := d.addDhcpEntry(dhcpEntry); err != nil {
 			return err
 		}
@@ -322,6 +386,9 @@ func (d *Dnsmasq) createHostsFile() error {
 
 	for _, e := range d.IPMap {
 		s := fmt.Sprintf("%s,%s", e.MACAddr, e.IPAddr)
+		if e.IPv6Addr != nil {
+			s = fmt.Sprintf("%s,[%s]", s, e.IPv6Addr)
+		}
 		if e.Hostname != "" {
 			s = fmt.Sprintf("%s,%s", s, e.Hostname)
 		}
@@ -334,6 +401,76 @@ func (d *Dnsmasq) createHostsFile() error {
 	return file.Sync()
 }
 
+//validDNSLabel reports whether a name is a RFC 1123 host name label
+func validDNSLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 {
+		return false
+	}
+
+	for i, c := range label {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' && i != 0 && i != len(label)-1:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+//validDNSZone reports whether a zone is made of RFC 1123 host name labels
+func validDNSZone(zone string) bool {
+	if len(zone) > 253 {
+		return false
+	}
+
+	for _, label := range strings.Split(zone, ".") {
+		if !validDNSLabel(label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//createAddnHostsFile writes the DNS records of the zone in the hosts file
+//format. Both the forward and reverse lookups of the records are served.
+//The records whose name is not a valid host name are skipped, they would
+//be written as is in the file
+func (d *Dnsmasq) createAddnHostsFile() error {
+	file, err := os.Create(d.addnHosts)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = file.Close() }()
+
+	if d.Zone == "" {
+		return file.Sync()
+	}
+
+	if !validDNSZone(d.Zone) {
+		return fmt.Errorf("Invalid DNS zone %q", d.Zone)
+	}
+
+	for _, r := range d.Records {
+		if !validDNSLabel(r.Name) || r.IPAddr == nil {
+			continue
+		}
+
+		name := fmt.Sprintf("%s.%s", r.Name, d.Zone)
+		s := fmt.Sprintf("%s %s\n", r.IPAddr, name)
+		if r.IPv6Addr != nil {
+			s = fmt.Sprintf("%s%s %s\n", s, r.IPv6Addr, name)
+		}
+		if _, err := file.WriteString(s); err != nil {
+			return err
+		}
+	}
+
+	return file.Sync()
+}
+
 func (d *Dnsmasq) createConfigFile() error {
 	params := make([]string, 20)
 
@@ -345,13 +482,23 @@ func (d *Dnsmasq) createConfigFile() error {
 		return fmt.Errorf("bridge uninitialized")
 	}
 
+	if d.Zone != "" && !validDNSZone(d.Zone) {
+		return fmt.Errorf("Invalid DNS zone %q", d.Zone)
+	}
+
 	params = append(params, fmt.Sprintf("pid-file=%s\n", d.pidFile))
 	params = append(params, fmt.Sprintf("dhcp-leasefile=%s\n", d.leaseFile))
 	params = append(params, fmt.Sprintf("dhcp-hostsfile=%s\n", d.hostsFile))
 	//params = append(params, "strict-order\n")
 	//params = append(params, "expand-hosts\n")
 	if d.DomainName != "" {
-		params = append(params, "domain=%s\n", d.DomainName)
+		params = append(params, fmt.Sprintf("domain=%s\n", d.DomainName))
+	} else if d.Zone != "" {
+		params = append(params, fmt.Sprintf("domain=%s\n", d.Zone))
+	}
+	if d.Zone != "" {
+		params = append(params, fmt.Sprintf("local=/%s/\n", d.Zone))
+		params = append(params, fmt.Sprintf("addn-hosts=%s\n", d.addnHosts))
 	}
 	params = append(params, "domain-needed\n")
 	params = append(params, "bogus-priv\n")
@@ -364,6 +511,17 @@ func (d *Dnsmasq) createConfigFile() error {
 	params = append(params, fmt.Sprintf("dhcp-range=%s,static\n", d.subnet.String()))
 	params = append(params, fmt.Sprintf("dhcp-lease-max=%d\n", d.dhcpSize))
 	params = append(params, fmt.Sprintf("dhcp-option-force=26,%d\n", d.MTU))
+	if routes := d.classlessRoutes(); routes != "" {
+		params = append(params, fmt.Sprintf("dhcp-option=option:classless-static-route,%s\n", routes))
+	}
+	if d.TenantNetIPv6 != nil {
+		params = append(params, "enable-ra\n")
+		params = append(params, fmt.Sprintf("listen-address=%s\n", d.gatewayIPv6.IP.String()))
+		//No SLAAC, the instances must use the address derived from
+		//their IPv4 address which is the one the controller knows
+		params = append(params, fmt.Sprintf("dhcp-range=%s,static,64\n", d.TenantNetIPv6.IP.String()))
+		params = append(params, fmt.Sprintf("ra-param=%s,mtu:%d,60\n", d.Dev.LinkName, d.MTU))
+	}
 	//params = append(params, "log-dhcp\n")
 
 	file, err := os.Create(d.confFile)
@@ -381,6 +539,30 @@ func (d *Dnsmasq) createConfigFile() error {
 	return file.Sync()
 }
 
+//classlessRoutes returns the routes to the other subnets of the tenant
+//through the distributed router. The instances ignore the default gateway
+//when given classless routes, so the default route is added to them
+func (d *Dnsmasq) classlessRoutes() string {
+	router := RouterIP(d.TenantNet)
+	if router == nil {
+		return ""
+	}
+
+	var routes []string
+	for _, subnet := range d.RoutedSubnets {
+		if subnet.String() == d.TenantNet.String() {
+			continue
+		}
+		routes = append(routes, subnet.String(), router.String())
+	}
+	if len(routes) == 0 {
+		return ""
+	}
+
+	routes = append(routes, "0.0.0.0/0", d.gateway.IP.String())
+	return strings.Join(routes, ",")
+}
+
 func (d *Dnsmasq) launch() error {
 	prog := "dnsmasq"
 	args := fmt.Sprintf("--conf-file=%s", d.confFile)
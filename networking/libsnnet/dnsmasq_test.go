@@ -19,6 +19,7 @@ package libsnnet
 import (
 	"io/ioutil"
 	"net"
+	"os"
 	"strconv"
 	"testing"
 
@@ -150,3 +151,114 @@ func TestDnsmasq_Negative(t *testing.T) {
 		assert.Nil(d.stop())
 	}
 }
+
+//Tests the DNS zone served by dnsmasq
+//
+//This test checks that the records of the tenant zone are written
+//to the additional hosts file and that the zone is only resolved
+//locally. It does not launch dnsmasq
+//
+//Test is expected to pass
+func TestDnsmasq_Records(t *testing.T) {
+	assert := assert.New(t)
+
+	subnet := net.IPNet{
+		IP:   net.IPv4(192, 168, 1, 0),
+		Mask: net.IPv4Mask(255, 255, 255, 0),
+	}
+
+	bridge, _ := NewBridge("dns_testbr")
+	bridge.LinkName = "dns_testbr"
+
+	d, err := newDnsmasq("dnsrecorduuid", "tenantuuid", subnet, 0, bridge)
+	if !assert.Nil(err) {
+		return
+	}
+	defer func() {
+		_ = os.Remove(d.confFile)
+		_ = os.Remove(d.addnHosts)
+	}()
+
+	d.Zone = "tenantuuid.ciao"
+	d.Records = []DNSRecord{
+		{
+			Name:     "web-0",
+			IPAddr:   net.ParseIP("192.168.1.2"),
+			IPv6Addr: net.ParseIP("fd00:c1a0:0:a801::102"),
+		},
+		{
+			Name:   "db",
+			IPAddr: net.ParseIP("192.168.1.3"),
+		},
+		{
+			Name:   "evil\n192.168.1.4 web-0",
+			IPAddr: net.ParseIP("192.168.1.4"),
+		},
+		{
+			Name:   "-bad",
+			IPAddr: net.ParseIP("192.168.1.5"),
+		},
+	}
+
+	assert.Nil(d.createConfigFile())
+	conf, err := ioutil.ReadFile(d.confFile)
+	assert.Nil(err)
+	assert.Contains(string(conf), "domain=tenantuuid.ciao\n")
+	assert.Contains(string(conf), "local=/tenantuuid.ciao/\n")
+	assert.Contains(string(conf), "addn-hosts="+d.addnHosts+"\n")
+	assert.Contains(string(conf), "dhcp-range=fd00:c1a0:0:a801::,static,64\n")
+	assert.NotContains(string(conf), "slaac")
+
+	assert.Nil(d.createAddnHostsFile())
+	hosts, err := ioutil.ReadFile(d.addnHosts)
+	assert.Nil(err)
+	assert.Equal("192.168.1.2 web-0.tenantuuid.ciao\n"+
+		"fd00:c1a0:0:a801::102 web-0.tenantuuid.ciao\n"+
+		"192.168.1.3 db.tenantuuid.ciao\n", string(hosts))
+
+	d.Zone = "tenant uuid.ciao"
+	assert.NotNil(d.createConfigFile())
+	assert.NotNil(d.createAddnHostsFile())
+
+	d.Zone = ""
+	assert.Nil(d.createAddnHostsFile())
+	hosts, err = ioutil.ReadFile(d.addnHosts)
+	assert.Nil(err)
+	assert.Empty(hosts)
+}
+
+//Tests the routes to the subnets of the distributed router
+//
+//This test checks that dnsmasq serves classless routes to the other
+//subnets of the tenant via the router address of its subnet, with the
+//default route via the gateway. It does not launch dnsmasq
+//
+//Test is expected to pass
+func TestDnsmasq_RoutedSubnets(t *testing.T) {
+	assert := assert.New(t)
+
+	_, subnet, _ := net.ParseCIDR("192.168.1.0/24")
+	_, other, _ := net.ParseCIDR("192.168.2.0/24")
+
+	bridge, _ := NewBridge("dns_testbr")
+	bridge.LinkName = "dns_testbr"
+
+	d, err := newDnsmasq("dnsrouteduuid", "tenantuuid", *subnet, 0, bridge)
+	if !assert.Nil(err) {
+		return
+	}
+	defer func() { _ = os.Remove(d.confFile) }()
+
+	d.RoutedSubnets = []net.IPNet{*subnet}
+	assert.Nil(d.createConfigFile())
+	conf, err := ioutil.ReadFile(d.confFile)
+	assert.Nil(err)
+	assert.NotContains(string(conf), "classless-static-route")
+
+	d.RoutedSubnets = []net.IPNet{*subnet, *other}
+	assert.Nil(d.createConfigFile())
+	conf, err = ioutil.ReadFile(d.confFile)
+	assert.Nil(err)
+	assert.Contains(string(conf), "dhcp-option=option:classless-static-route,"+
+		"192.168.2.0/24,192.168.1.254,0.0.0.0/0,192.168.1.1\n")
+}
@@ -0,0 +1,421 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/vishvananda/netlink"
+)
+
+//TopologyLink is a network device of the topology of a node, identified
+//by its alias
+type TopologyLink struct {
+	Alias string
+	Name  string
+	//Master is the alias of the bridge the device is attached to
+	Master string
+	//Up is only meaningful for the actual topology
+	Up bool
+}
+
+//TopologyProblem is a difference between the expected and the actual
+//network topology of a node
+type TopologyProblem struct {
+	Alias    string
+	Problem  string
+	Repaired bool
+}
+
+//DnsmasqState describes the dnsmasq serving the subnet of a CNCI bridge
+type DnsmasqState struct {
+	Bridge  string
+	PID     int
+	Running bool
+}
+
+//TopologyReport compares the network topology a node expects, i.e. the
+//one recorded in its network database, to the devices actually present
+//on the node. The actual topology is the one found before any repair
+type TopologyReport struct {
+	Expected []TopologyLink
+	Actual   []TopologyLink
+	Dnsmasq  []DnsmasqState
+	Problems []TopologyProblem
+}
+
+//topologyCheck holds the state of a single topology inspection
+type topologyCheck struct {
+	expected map[string]TopologyLink
+	links    map[string]netlink.Link //Alias to Link of the actual devices
+	pending  map[string]bool         //Aliases of the devices being created
+	repair   bool
+	resync   bool //The network database needs to be rebuilt
+	report   *TopologyReport
+}
+
+func ciaoAlias(alias string) bool {
+	for _, prefix := range []string{bridgePrefix, vnicPrefix, grePrefix, vxlanPrefix, cnciVnicPrefix, vrfPrefix} {
+		if strings.HasPrefix(alias, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//masterAlias returns the alias of the bridge a VNIC or a tunnel is
+//expected to be attached to, or "" for devices that are not attached
+func masterAlias(alias string) string {
+	for _, prefix := range []string{vnicPrefix, grePrefix, vxlanPrefix} {
+		if strings.HasPrefix(alias, prefix) {
+			id := strings.Split(strings.TrimPrefix(alias, prefix), "##")[0]
+			return bridgePrefix + id
+		}
+	}
+	return ""
+}
+
+func newTopologyCheck(linkMap map[string]*linkInfo, repair bool) (*topologyCheck, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, NewFatalError("Cannot retrieve links" + err.Error())
+	}
+
+	tc := &topologyCheck{
+		expected: make(map[string]TopologyLink),
+		links:    make(map[string]netlink.Link),
+		pending:  make(map[string]bool),
+		repair:   repair,
+		report:   &TopologyReport{},
+	}
+
+	for alias, info := range linkMap {
+		if !ciaoAlias(alias) {
+			continue
+		}
+
+		//Skip the devices being created
+		select {
+		case <-info.ready:
+		default:
+			tc.pending[alias] = true
+			continue
+		}
+
+		tc.expected[alias] = TopologyLink{
+			Alias:  alias,
+			Name:   info.name,
+			Master: masterAlias(alias),
+		}
+	}
+
+	masters := make(map[int]string)
+	for _, link := range links {
+		masters[link.Attrs().Index] = link.Attrs().Alias
+	}
+
+	for _, link := range links {
+		alias := link.Attrs().Alias
+		if !ciaoAlias(alias) {
+			continue
+		}
+
+		tc.links[alias] = link
+		tc.report.Actual = append(tc.report.Actual, TopologyLink{
+			Alias:  alias,
+			Name:   link.Attrs().Name,
+			Master: masters[link.Attrs().MasterIndex],
+			Up:     link.Attrs().Flags&net.FlagUp != 0,
+		})
+	}
+
+	for _, link := range tc.expected {
+		tc.report.Expected = append(tc.report.Expected, link)
+	}
+
+	sort.Slice(tc.report.Expected, func(i, j int) bool {
+		return tc.report.Expected[i].Alias < tc.report.Expected[j].Alias
+	})
+	sort.Slice(tc.report.Actual, func(i, j int) bool {
+		return tc.report.Actual[i].Alias < tc.report.Actual[j].Alias
+	})
+
+	return tc, nil
+}
+
+//busyBridge tells whether a device attached to the bridge, or the bridge
+//itself, is being created
+func (tc *topologyCheck) busyBridge(bridgeAlias string) bool {
+	for alias := range tc.pending {
+		if alias == bridgeAlias || masterAlias(alias) == bridgeAlias {
+			return true
+		}
+	}
+	return false
+}
+
+//problem records a problem, fixing it first when a repair was requested
+//and the problem can be fixed
+func (tc *topologyCheck) problem(alias string, problem string, fix func() error) {
+	p := TopologyProblem{
+		Alias:   alias,
+		Problem: problem,
+	}
+
+	if tc.repair && fix != nil {
+		if err := fix(); err != nil {
+			p.Problem = fmt.Sprintf("%s: repair failed %v", problem, err)
+		} else {
+			p.Repaired = true
+		}
+	}
+
+	tc.report.Problems = append(tc.report.Problems, p)
+}
+
+func (tc *topologyCheck) resyncDb() error {
+	tc.resync = true
+	return nil
+}
+
+//checkDb compares the network database to the devices present
+func (tc *topologyCheck) checkDb() {
+	for alias, expected := range tc.expected {
+		link, ok := tc.links[alias]
+		if !ok {
+			tc.problem(alias, "device "+expected.Name+" is missing", tc.resyncDb)
+			continue
+		}
+
+		if name := link.Attrs().Name; name != expected.Name {
+			tc.problem(alias, "device "+expected.Name+" was renamed to "+name, tc.resyncDb)
+		}
+	}
+
+	for alias, link := range tc.links {
+		if tc.pending[alias] {
+			continue
+		}
+		if _, ok := tc.expected[alias]; !ok {
+			tc.problem(alias, "device "+link.Attrs().Name+" is not in the network database", tc.resyncDb)
+		}
+	}
+}
+
+//checkLinks checks that the devices are up and attached to their bridge.
+//Tunnels whose bridge is gone are deleted, VNICs whose bridge is gone
+//belong to an instance and are left alone
+func (tc *topologyCheck) checkLinks() {
+	for alias, link := range tc.links {
+		link := link
+		if tc.pending[alias] || tc.pending[masterAlias(alias)] {
+			continue
+		}
+
+		master := masterAlias(alias)
+		if master != "" {
+			bridge, ok := tc.links[master]
+			switch {
+			case !ok && strings.HasPrefix(alias, vnicPrefix):
+				tc.problem(alias, "VNIC has no bridge "+master, nil)
+				continue
+			case !ok:
+				tc.problem(alias, "tunnel has no bridge "+master, func() error {
+					return netlink.LinkDel(link)
+				})
+				continue
+			case link.Attrs().MasterIndex != bridge.Attrs().Index:
+				tc.problem(alias, "device is not attached to bridge "+master, func() error {
+					return netlink.LinkSetMasterByIndex(link, bridge.Attrs().Index)
+				})
+			}
+		}
+
+		if link.Attrs().Flags&net.FlagUp == 0 {
+			tc.problem(alias, "device is down", func() error {
+				return netlink.LinkSetUp(link)
+			})
+		}
+	}
+}
+
+//checkBridgePorts reports the bridges of a compute node that have no
+//tunnel to their CNCI or no VNIC attached. The last VNIC on a bridge
+//deletes the bridge and its tunnel, so bridges without VNICs are leftovers.
+//The ports of a tenant router count as VNICs. Bridges with devices being
+//created are skipped, their VNIC may not be attached yet
+func (tc *topologyCheck) checkBridgePorts() {
+	ports := make(map[string]int)
+	for alias := range tc.links {
+		if strings.HasPrefix(alias, vnicPrefix) {
+			ports[masterAlias(alias)]++
+		}
+	}
+
+	for alias, link := range tc.links {
+		link := link
+		if !strings.HasPrefix(alias, bridgePrefix) {
+			continue
+		}
+
+		if tc.busyBridge(alias) {
+			continue
+		}
+
+		id := strings.TrimPrefix(alias, bridgePrefix)
+		if ports[alias] > 0 {
+			_, gre := tc.links[grePrefix+id]
+			_, vxlan := tc.links[vxlanPrefix+id]
+			if !gre && !vxlan {
+				tc.problem(alias, "bridge has no tunnel", nil)
+			}
+			continue
+		}
+
+		tc.problem(alias, "bridge has no VNIC", func() error {
+			for _, tunnel := range []string{grePrefix + id, vxlanPrefix + id} {
+				if t, ok := tc.links[tunnel]; ok {
+					if err := netlink.LinkDel(t); err != nil {
+						return err
+					}
+				}
+			}
+			return netlink.LinkDel(link)
+		})
+	}
+}
+
+//InspectTopology compares the network topology of the compute node
+//recorded in its network database to the devices present on the node.
+//When repair is set, the problems that can safely be fixed are fixed and
+//the network database is rebuilt from the devices present
+func (cn *ComputeNode) InspectTopology(repair bool) (*TopologyReport, error) {
+	if cn.NetworkConfig == nil || cn.cnTopology == nil {
+		return nil, NewAPIError(fmt.Sprintf("CN has not been initialized %v", cn))
+	}
+
+	cn.cnTopology.Lock()
+	tc, err := newTopologyCheck(cn.linkMap, repair)
+	if err != nil {
+		cn.cnTopology.Unlock()
+		return nil, err
+	}
+
+	tc.checkDb()
+	tc.checkLinks()
+	tc.checkBridgePorts()
+	cn.cnTopology.Unlock()
+
+	if tc.resync {
+		if err := cn.DbRebuild(nil); err != nil {
+			return tc.report, err
+		}
+	}
+
+	return tc.report, nil
+}
+
+//checkDnsmasq checks that a dnsmasq is running for each bridge of the
+//concentrator, restarting the ones that are not
+func (cnci *Cnci) checkDnsmasq(tc *topologyCheck) {
+	var bridges []string
+	for bridgeID := range cnci.topology.bridgeMap {
+		bridges = append(bridges, bridgeID)
+	}
+	sort.Strings(bridges)
+
+	for _, bridgeID := range bridges {
+		brInfo := cnci.topology.bridgeMap[bridgeID]
+		state := DnsmasqState{Bridge: bridgeID}
+
+		if _, ok := tc.links[bridgeID]; !ok {
+			tc.problem(bridgeID, "subnet has no bridge", func() error {
+				if brInfo.Dnsmasq != nil {
+					_ = brInfo.Dnsmasq.stop()
+				}
+				delete(cnci.topology.bridgeMap, bridgeID)
+				return nil
+			})
+			tc.report.Dnsmasq = append(tc.report.Dnsmasq, state)
+			continue
+		}
+
+		if brInfo.Dnsmasq == nil {
+			tc.problem(bridgeID, "subnet has no dnsmasq", nil)
+			tc.report.Dnsmasq = append(tc.report.Dnsmasq, state)
+			continue
+		}
+
+		pid, err := brInfo.Dnsmasq.attach()
+		if err == nil {
+			state.PID = pid
+			state.Running = true
+		} else {
+			tc.problem(bridgeID, "dnsmasq is not running", func() error {
+				//cnci.dns is held by InspectTopology
+				brInfo.Dnsmasq.Zone = cnci.dns.zone
+				brInfo.Dnsmasq.Records = cnci.dns.records
+				brInfo.Dnsmasq.RoutedSubnets = cnci.dns.routed
+				return brInfo.Dnsmasq.restart()
+			})
+		}
+		tc.report.Dnsmasq = append(tc.report.Dnsmasq, state)
+	}
+}
+
+//InspectTopology compares the network topology of the concentrator
+//recorded in its network database to the devices and the dnsmasq
+//processes present on the concentrator. When repair is set, the problems
+//that can safely be fixed are fixed and the network database is updated
+//to match the devices present. A subnet whose bridge is gone is dropped,
+//it is recreated when the subnet is next added
+func (cnci *Cnci) InspectTopology(repair bool) (*TopologyReport, error) {
+	if cnci.NetworkConfig == nil || cnci.topology == nil {
+		return nil, fmt.Errorf("cnci not initialized")
+	}
+
+	//dns is locked before topology, in the order in which the DNS
+	//records and routed subnets are set
+	cnci.dns.Lock()
+	defer cnci.dns.Unlock()
+	cnci.topology.Lock()
+	defer cnci.topology.Unlock()
+
+	tc, err := newTopologyCheck(cnci.topology.linkMap, repair)
+	if err != nil {
+		return nil, err
+	}
+
+	tc.checkDb()
+	tc.checkLinks()
+	cnci.checkDnsmasq(tc)
+
+	if tc.resync {
+		links, err := netlink.LinkList()
+		if err != nil {
+			return tc.report, err
+		}
+		cnci.topology.linkMap = make(map[string]*linkInfo)
+		cnci.topology.nameMap = make(map[string]bool)
+		cnci.rebuildLinkAndNameMap(links)
+	}
+
+	return tc.report, nil
+}
@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+func findProblem(report *TopologyReport, alias string, problem string) *TopologyProblem {
+	for i, p := range report.Problems {
+		if p.Alias == alias && p.Problem == problem {
+			return &report.Problems[i]
+		}
+	}
+	return nil
+}
+
+//Tests the inspection and repair of a network topology
+//
+//Test creates a bridge without a tunnel and a VNIC that is down and not
+//attached to the bridge, none of which are in the network database. It
+//checks that the problems are reported and that the VNIC is attached to
+//the bridge and brought up on repair
+//
+//Test is expected to pass
+func TestTopology_InspectRepair(t *testing.T) {
+	assert := assert.New(t)
+
+	bridge, err := NewBridge(bridgePrefix + "doctortest")
+	require.Nil(t, err)
+	require.Nil(t, bridge.Create())
+	defer func() { _ = bridge.Destroy() }()
+	require.Nil(t, bridge.Enable())
+
+	vnic, err := NewVnic(vnicPrefix + "doctortest##10.0.0.2")
+	require.Nil(t, err)
+	require.Nil(t, vnic.Create())
+	defer func() { _ = vnic.Destroy() }()
+
+	tc, err := newTopologyCheck(map[string]*linkInfo{}, false)
+	require.Nil(t, err)
+	tc.checkDb()
+	tc.checkLinks()
+	tc.checkBridgePorts()
+
+	assert.NotNil(findProblem(tc.report, bridge.GlobalID, "device "+bridge.LinkName+" is not in the network database"))
+	assert.NotNil(findProblem(tc.report, bridge.GlobalID, "bridge has no tunnel"))
+	assert.NotNil(findProblem(tc.report, vnic.GlobalID, "device is not attached to bridge "+bridge.GlobalID))
+	assert.NotNil(findProblem(tc.report, vnic.GlobalID, "device is down"))
+	for _, p := range tc.report.Problems {
+		assert.False(p.Repaired)
+	}
+	assert.False(tc.resync)
+
+	tc, err = newTopologyCheck(map[string]*linkInfo{}, true)
+	require.Nil(t, err)
+	tc.checkDb()
+	tc.checkLinks()
+
+	assert.True(findProblem(tc.report, vnic.GlobalID, "device is down").Repaired)
+	assert.True(tc.resync)
+
+	link, err := netlink.LinkByName(vnic.LinkName)
+	require.Nil(t, err)
+	assert.Equal(bridge.Link.Index, link.Attrs().MasterIndex)
+	assert.NotEqual(net.Flags(0), link.Attrs().Flags&net.FlagUp)
+
+	tc, err = newTopologyCheck(map[string]*linkInfo{}, false)
+	require.Nil(t, err)
+	tc.checkLinks()
+	assert.Nil(findProblem(tc.report, vnic.GlobalID, "device is down"))
+	assert.Nil(findProblem(tc.report, vnic.GlobalID, "device is not attached to bridge "+bridge.GlobalID))
+}
+
+//Tests that bridges with devices being created are left alone
+//
+//Test creates a bridge without a VNIC while the creation of a VNIC to be
+//attached to it is pending in the network database. It checks that the
+//bridge is not reported, so a repair does not delete it
+//
+//Test is expected to pass
+func TestTopology_PendingBridge(t *testing.T) {
+	assert := assert.New(t)
+
+	bridge, err := NewBridge(bridgePrefix + "doctorpending")
+	require.Nil(t, err)
+	require.Nil(t, bridge.Create())
+	defer func() { _ = bridge.Destroy() }()
+
+	linkMap := map[string]*linkInfo{
+		vnicPrefix + "doctorpending##10.0.0.2": {ready: make(chan struct{})},
+	}
+
+	tc, err := newTopologyCheck(linkMap, true)
+	require.Nil(t, err)
+	tc.checkBridgePorts()
+
+	assert.Nil(findProblem(tc.report, bridge.GlobalID, "bridge has no VNIC"))
+
+	_, err = netlink.LinkByName(bridge.LinkName)
+	assert.Nil(err)
+}
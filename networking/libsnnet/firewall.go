@@ -67,7 +67,10 @@ Routing decision                                                  |
 */
 
 const (
-	procIPFwd = "/proc/sys/net/ipv4/ip_forward"
+	procIPFwd        = "/proc/sys/net/ipv4/ip_forward"
+	procIPv6Fwd      = "/proc/sys/net/ipv6/conf/all/forwarding"
+	procIPv6AccRA    = "/proc/sys/net/ipv6/conf/%s/accept_ra"
+	procIPv4AccRedir = "/proc/sys/net/ipv4/conf/%s/accept_redirects"
 )
 
 //FwAction defines firewall action to be performed
@@ -84,30 +87,14 @@ const (
 type Firewall struct {
 	ExtInterfaces []string
 	*iptables.IPTables
+	ip6t *ip6Tables //nil unless IPv6 is routed
 }
 
-//InitFirewall Enables routing on the node and NAT on all
-//external facing interfaces. Enable NAT right away to prevent
-//tenant traffic escape
-//TODO: Only enable external routing. Internal routing should
-//always be enabled
-func InitFirewall(devices ...string) (*Firewall, error) {
-
-	if len(devices) == 0 {
-		return nil, fmt.Errorf("initFirewall: Invalid input params")
-	}
-
-	ipt, err := iptables.New()
-	if err != nil {
-		return nil, fmt.Errorf("initFirewall: Unable to setup iptables %v", err)
-	}
-
-	f := &Firewall{
-		IPTables: ipt,
-	}
+var floatingIPsChains = []string{"ciao-floating-ip-pre", "ciao-floating-ip-post"}
 
-	// create CIAO Floating IPs user defined chains
-	floatingIPsChains := []string{"ciao-floating-ip-pre", "ciao-floating-ip-post"}
+//initFloatingIPChains creates the chains holding the NAT rules of
+//public IPs and jumps to them from the nat table
+func initFloatingIPChains(ipt ipTables) error {
 	for _, chain := range floatingIPsChains {
 		// verify it exists if not create it
 		_ = ipt.NewChain("nat", chain)
@@ -116,39 +103,75 @@ func InitFirewall(devices ...string) (*Firewall, error) {
 	// insert ciao-floating-ip-pre into PREROUTING Chain
 	ok, err := ipt.Exists("nat", "PREROUTING", "-j", "ciao-floating-ip-pre")
 	if err != nil {
-		return nil, fmt.Errorf("Error: InitFirewall could not verify existence of chain ciao-floating-ip-pre, %v", err)
+		return fmt.Errorf("Error: InitFirewall could not verify existence of chain ciao-floating-ip-pre, %v", err)
 	}
 	if !ok {
 		err := ipt.Insert("nat", "PREROUTING", 1, "-j", "ciao-floating-ip-pre")
 		if err != nil {
-			return nil, fmt.Errorf("Error: InitFirewall could not create ciao-floating-ip-pre chain")
+			return fmt.Errorf("Error: InitFirewall could not create ciao-floating-ip-pre chain")
 		}
 	}
 
 	// insert ciao-floating-ip-post into POSTROUTING Chain
 	ok, err = ipt.Exists("nat", "POSTROUTING", "-j", "ciao-floating-ip-post")
 	if err != nil {
-		return nil, fmt.Errorf("Error: InitFirewall could not verify existence of chain ciao-floating-ip-post, %v", err)
+		return fmt.Errorf("Error: InitFirewall could not verify existence of chain ciao-floating-ip-post, %v", err)
 	}
 	if !ok {
 		err := ipt.Insert("nat", "POSTROUTING", 1, "-j", "ciao-floating-ip-post")
 		if err != nil {
-			return nil, fmt.Errorf("Error: InitFirewall could not create ciao-floating-ip-post chain")
+			return fmt.Errorf("Error: InitFirewall could not create ciao-floating-ip-post chain")
 		}
 	}
 
-	for _, device := range devices {
+	return nil
+}
+
+//enableMasquerade NATs the traffic leaving through an external device
+func enableMasquerade(ipt ipTables, device string) error {
+	//iptables -t nat -A POSTROUTING -o $device -j MASQUERADE
+	err := ipt.AppendUnique("nat", "POSTROUTING",
+		"-o", device, "-j", "MASQUERADE")
 
-		//iptables -t nat -A POSTROUTING -o $device -j MASQUERADE
-		err = ipt.AppendUnique("nat", "POSTROUTING",
+	if err != nil {
+		ok, err := ipt.Exists("nat", "POSTROUTING",
 			"-o", device, "-j", "MASQUERADE")
+		if !ok {
+			return fmt.Errorf("Error: InitFirewall NAT enable [%v] %v", device, err)
+		}
+	}
 
-		if err != nil {
-			ok, err := ipt.Exists("nat", "POSTROUTING",
-				"-o", device, "-j", "MASQUERADE")
-			if !ok {
-				return nil, fmt.Errorf("Error: InitFirewall NAT enable [%v] %v", device, err)
-			}
+	return nil
+}
+
+//InitFirewall Enables routing on the node and NAT on all
+//external facing interfaces. Enable NAT right away to prevent
+//tenant traffic escape
+//TODO: Only enable external routing. Internal routing should
+//always be enabled
+func InitFirewall(devices ...string) (*Firewall, error) {
+
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("initFirewall: Invalid input params")
+	}
+
+	ipt, err := iptables.New()
+	if err != nil {
+		return nil, fmt.Errorf("initFirewall: Unable to setup iptables %v", err)
+	}
+
+	f := &Firewall{
+		IPTables: ipt,
+	}
+
+	// create CIAO Floating IPs user defined chains
+	if err := initFloatingIPChains(ipt); err != nil {
+		return nil, err
+	}
+
+	for _, device := range devices {
+		if err := enableMasquerade(ipt, device); err != nil {
+			return nil, err
 		}
 
 		f.ExtInterfaces = append(f.ExtInterfaces, device)
@@ -162,6 +185,39 @@ func InitFirewall(devices ...string) (*Firewall, error) {
 
 }
 
+//EnableIPv6 routes and NATs the IPv6 traffic of tenants the same way
+//InitFirewall does for IPv4. It fails on nodes without IPv6 NAT
+//support, in which case tenant IPv6 traffic stays within the tenant
+func (f *Firewall) EnableIPv6() error {
+	ipt, err := newIP6Tables()
+	if err != nil {
+		return fmt.Errorf("EnableIPv6: Unable to setup ip6tables %v", err)
+	}
+
+	if err := initFloatingIPChains(ipt); err != nil {
+		return err
+	}
+
+	for _, device := range f.ExtInterfaces {
+		if err := enableMasquerade(ipt, device); err != nil {
+			return err
+		}
+
+		//Keep accepting router advertisements once forwarding
+		if err := writeProc(fmt.Sprintf(procIPv6AccRA, device), "2"); err != nil {
+			return fmt.Errorf("EnableIPv6: %v", err)
+		}
+	}
+
+	if err := writeProc(procIPv6Fwd, "1"); err != nil {
+		return fmt.Errorf("EnableIPv6: routing enable %v", err)
+	}
+
+	f.ip6t = ipt
+
+	return nil
+}
+
 //ShutdownFirewall Disables routing and NAT
 //TODO: Only external routing should be disabled.
 func (f *Firewall) ShutdownFirewall() error {
@@ -170,6 +226,12 @@ func (f *Firewall) ShutdownFirewall() error {
 		return fmt.Errorf("Error: Shutdown Firewall routing disable %v", err)
 	}
 
+	if f.ip6t != nil {
+		if err := writeProc(procIPv6Fwd, "0"); err != nil {
+			return fmt.Errorf("Error: Shutdown Firewall IPv6 routing disable %v", err)
+		}
+	}
+
 	for _, device := range f.ExtInterfaces {
 
 		err := f.Delete("nat", "POSTROUTING",
@@ -178,6 +240,31 @@ func (f *Firewall) ShutdownFirewall() error {
 		if err != nil {
 			return fmt.Errorf("Error: Shutdown Firewall NAT disable %v", err)
 		}
+
+		if f.ip6t == nil {
+			continue
+		}
+
+		err = f.ip6t.Delete("nat", "POSTROUTING",
+			"-o", device, "-j", "MASQUERADE")
+
+		if err != nil {
+			return fmt.Errorf("Error: Shutdown Firewall IPv6 NAT disable %v", err)
+		}
+	}
+
+	return nil
+}
+
+func writeProc(path string, value string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("Unable to open %v %v", path, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if _, err = file.WriteString(value); err != nil {
+		return fmt.Errorf("Unable to write %v %v", path, err)
 	}
 
 	return nil
@@ -187,17 +274,13 @@ func (f *Firewall) ShutdownFirewall() error {
 //echo 0 > /proc/sys/net/ipv4/ip_forward
 //echo 1 > /proc/sys/net/ipv4/ip_forward
 func Routing(action FwAction) error {
-	file, err := os.OpenFile(procIPFwd, os.O_WRONLY|os.O_TRUNC, 0644)
-	if err != nil {
-		return fmt.Errorf("Routing: Unable to open %v %v", procIPFwd, err)
-	}
-	defer func() { _ = file.Close() }()
+	var err error
 
 	switch action {
 	case FwEnable:
-		_, err = file.WriteString("1")
+		err = writeProc(procIPFwd, "1")
 	case FwDisable:
-		_, err = file.WriteString("0")
+		err = writeProc(procIPFwd, "0")
 	}
 
 	if err != nil {
@@ -207,6 +290,14 @@ func Routing(action FwAction) error {
 	return nil
 }
 
+//hostCIDR returns the CIDR notation of a single address
+func hostCIDR(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
 //ExtFwding enables or disables fwding between an externally connected interface
 //and a tenant bridge (hence a tenant subnet)
 //Each tenant subnet created needs explicit enabling/disabling
@@ -308,6 +399,217 @@ func (f *Firewall) ExtPortAccess(action FwAction, protocol string, extDevice str
 	return nil
 }
 
+const (
+	secGroupChain    = "ciao-sg"
+	secGroupNewChain = "ciao-sg-new"
+)
+
+//FwDirection defines the direction of the traffic a security rule
+//applies to, as seen from the instance
+type FwDirection int
+
+const (
+	//FwIngress matches traffic sent to the instance
+	FwIngress FwDirection = iota
+	//FwEgress matches traffic sent by the instance
+	FwEgress
+)
+
+//SecurityRule allows a class of traffic to or from a tenant instance
+type SecurityRule struct {
+	Direction  FwDirection
+	InstanceIP net.IP
+	Protocol   string     //tcp, udp or icmp, all traffic if empty
+	PortMin    int        //destination ports, all ports if 0
+	PortMax    int        //last destination port, PortMin if 0
+	Remote     *net.IPNet //address range of the other end, any if nil
+}
+
+func (r SecurityRule) ruleSpec() ([]string, error) {
+	spec, err := r.ruleMatch()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(spec, "-j", "ACCEPT"), nil
+}
+
+//ruleMatch returns the iptables matches of the traffic allowed by the rule
+func (r SecurityRule) ruleMatch() ([]string, error) {
+	if r.InstanceIP == nil {
+		return nil, fmt.Errorf("Invalid instance IP")
+	}
+	instance := hostCIDR(r.InstanceIP)
+	ipv4 := r.InstanceIP.To4() != nil
+
+	if r.Remote != nil && (r.Remote.IP.To4() != nil) != ipv4 {
+		return nil, fmt.Errorf("Remote %s and instance %s address families differ",
+			r.Remote, r.InstanceIP)
+	}
+
+	var spec []string
+	switch r.Direction {
+	case FwIngress:
+		spec = append(spec, "-d", instance)
+		if r.Remote != nil {
+			spec = append(spec, "-s", r.Remote.String())
+		}
+	case FwEgress:
+		spec = append(spec, "-s", instance)
+		if r.Remote != nil {
+			spec = append(spec, "-d", r.Remote.String())
+		}
+	default:
+		return nil, fmt.Errorf("Invalid direction %v", r.Direction)
+	}
+
+	switch r.Protocol {
+	case "":
+	case "tcp", "udp":
+		spec = append(spec, "-p", r.Protocol)
+		if r.PortMin != 0 {
+			ports := strconv.Itoa(r.PortMin)
+			if r.PortMax > r.PortMin {
+				ports += ":" + strconv.Itoa(r.PortMax)
+			}
+			spec = append(spec, "--dport", ports)
+		}
+	case "icmp":
+		if ipv4 {
+			spec = append(spec, "-p", r.Protocol)
+		} else {
+			spec = append(spec, "-p", "icmpv6")
+		}
+	default:
+		return nil, fmt.Errorf("Invalid protocol %s", r.Protocol)
+	}
+
+	if r.Protocol != "tcp" && r.Protocol != "udp" && r.PortMin != 0 {
+		return nil, fmt.Errorf("Ports require tcp or udp, not %q", r.Protocol)
+	}
+
+	return spec, nil
+}
+
+//SecurityGroups replaces the security group rules enforced on forwarded
+//traffic. Traffic to or from any of the guarded instance IPs is dropped
+//unless a rule allows it or it belongs to an established connection.
+//Traffic of other addresses is not affected.
+//The rules are built in a new chain which then atomically replaces the
+//one jumped to from the FORWARD chain. IPv6 addresses and rules are
+//ignored unless IPv6 is routed
+func (f *Firewall) SecurityGroups(guarded []net.IP, rules []SecurityRule) error {
+	var specs, specs6 [][]string
+	for _, r := range rules {
+		spec, err := r.ruleSpec()
+		if err != nil {
+			return fmt.Errorf("Invalid security rule %+v: %v", r, err)
+		}
+		if r.InstanceIP.To4() != nil {
+			specs = append(specs, spec)
+		} else {
+			specs6 = append(specs6, spec)
+		}
+	}
+
+	var guarded4, guarded6 []net.IP
+	for _, ip := range guarded {
+		if ip.To4() != nil {
+			guarded4 = append(guarded4, ip)
+		} else {
+			guarded6 = append(guarded6, ip)
+		}
+	}
+
+	if err := securityGroups(f.IPTables, guarded4, specs); err != nil {
+		return err
+	}
+
+	if f.ip6t == nil {
+		return nil
+	}
+
+	return securityGroups(f.ip6t, guarded6, specs6)
+}
+
+func securityGroups(ipt ipTables, guarded []net.IP, specs [][]string) error {
+	//ClearChain creates the chain if it does not exist
+	if err := ipt.ClearChain("filter", secGroupNewChain); err != nil {
+		return fmt.Errorf("Unable to create chain %s: %v", secGroupNewChain, err)
+	}
+
+	err := ipt.Append("filter", secGroupNewChain,
+		"-m", "state", "--state", "RELATED,ESTABLISHED", "-j", "ACCEPT")
+	if err != nil {
+		return fmt.Errorf("Unable to accept established traffic: %v", err)
+	}
+
+	for _, spec := range specs {
+		if err := ipt.Append("filter", secGroupNewChain, spec...); err != nil {
+			return fmt.Errorf("Unable to add security rule %v: %v", spec, err)
+		}
+	}
+
+	for _, ip := range guarded {
+		instance := hostCIDR(ip)
+		if err := ipt.Append("filter", secGroupNewChain, "-d", instance, "-j", "DROP"); err != nil {
+			return fmt.Errorf("Unable to guard %s: %v", instance, err)
+		}
+		if err := ipt.Append("filter", secGroupNewChain, "-s", instance, "-j", "DROP"); err != nil {
+			return fmt.Errorf("Unable to guard %s: %v", instance, err)
+		}
+	}
+
+	//The jump may be left over from a previously failed update
+	ok, err := ipt.Exists("filter", "FORWARD", "-j", secGroupNewChain)
+	if err != nil {
+		return fmt.Errorf("Unable to verify chain %s: %v", secGroupNewChain, err)
+	}
+	if !ok {
+		err := ipt.Insert("filter", "FORWARD", 1, "-j", secGroupNewChain)
+		if err != nil {
+			return fmt.Errorf("Unable to enable chain %s: %v", secGroupNewChain, err)
+		}
+	}
+
+	if err := deleteSecGroupChain(ipt, secGroupChain); err != nil {
+		return err
+	}
+
+	if err := ipt.RenameChain("filter", secGroupNewChain, secGroupChain); err != nil {
+		return fmt.Errorf("Unable to rename chain %s: %v", secGroupNewChain, err)
+	}
+
+	return nil
+}
+
+//deleteSecGroupChain deletes a chain and the jump to it from the FORWARD
+//chain, the match of the jump precedes its target
+func deleteSecGroupChain(ipt ipTables, chain string, match ...string) error {
+	jump := append(append([]string{}, match...), "-j", chain)
+	ok, err := ipt.Exists("filter", "FORWARD", jump...)
+	if err != nil {
+		//The chain itself does not exist
+		return nil
+	}
+
+	if ok {
+		if err := ipt.Delete("filter", "FORWARD", jump...); err != nil {
+			return fmt.Errorf("Unable to disable chain %s: %v", chain, err)
+		}
+	}
+
+	if err := ipt.ClearChain("filter", chain); err != nil {
+		return fmt.Errorf("Unable to clear chain %s: %v", chain, err)
+	}
+
+	if err := ipt.DeleteChain("filter", chain); err != nil {
+		return fmt.Errorf("Unable to delete chain %s: %v", chain, err)
+	}
+
+	return nil
+}
+
 func ipAssign(action FwAction, ip net.IP, iface string) error {
 
 	link, err := netlink.LinkByName(iface)
@@ -315,11 +617,19 @@ func ipAssign(action FwAction, ip net.IP, iface string) error {
 		return fmt.Errorf("Unable to detect interface %v %v", iface, err)
 	}
 
+	family := netlink.FAMILY_V4
 	addr := &netlink.Addr{IPNet: &net.IPNet{
 		IP:   ip.To4(),
-		Mask: net.IPv4Mask(255, 255, 255, 255),
+		Mask: net.CIDRMask(32, 32),
 	},
 	}
+	if addr.IP == nil {
+		family = netlink.FAMILY_V6
+		addr.IPNet = &net.IPNet{
+			IP:   ip,
+			Mask: net.CIDRMask(128, 128),
+		}
+	}
 
 	switch action {
 	case FwEnable:
@@ -344,7 +654,7 @@ func ipAssign(action FwAction, ip net.IP, iface string) error {
 		}
 
 		//Check if someone deleted it
-		addrs, err := netlink.AddrList(link, netlink.FAMILY_V4)
+		addrs, err := netlink.AddrList(link, family)
 		if err != nil || len(addrs) == 0 {
 			return fmt.Errorf("Unable to unassign IP from interface %s %v %v", ip, iface, err)
 		}
@@ -361,11 +671,22 @@ func ipAssign(action FwAction, ip net.IP, iface string) error {
 }
 
 //PublicIPAccess Enables/Disables public access to an internal IP
+//Both addresses have to be of the same family. IPv6 addresses require
+//IPv6 to be routed
 func (f *Firewall) PublicIPAccess(action FwAction,
 	internalIP net.IP, publicIP net.IP, extInterface string) error {
 
-	intIP := internalIP.String()
-	pubIP := publicIP.String()
+	var ipt ipTables = f.IPTables
+	if (internalIP.To4() != nil) != (publicIP.To4() != nil) {
+		return fmt.Errorf("Public IP %v and internal IP %v address families differ",
+			publicIP, internalIP)
+	}
+	if publicIP.To4() == nil {
+		if f.ip6t == nil {
+			return fmt.Errorf("Public IP %v requires IPv6 routing", publicIP)
+		}
+		ipt = f.ip6t
+	}
 
 	switch action {
 	case FwEnable:
@@ -374,7 +695,7 @@ func (f *Firewall) PublicIPAccess(action FwAction,
 		if err != nil {
 			return fmt.Errorf("Public IP Assignment failure %v", err)
 		}
-		return enablePublicIP(intIP, pubIP)
+		return enablePublicIP(ipt, internalIP, publicIP)
 	case FwDisable:
 		// remove the pubIP from the cnci agent
 		err := ipAssign(FwDisable, publicIP, extInterface)
@@ -382,27 +703,25 @@ func (f *Firewall) PublicIPAccess(action FwAction,
 			return fmt.Errorf("Public IP Assignment failure %v", err)
 		}
 
-		return disablePublicIP(intIP, pubIP)
+		return disablePublicIP(ipt, internalIP, publicIP)
 	default:
 		return fmt.Errorf("Invalid parameter %v", action)
 	}
 }
 
-func enablePublicIP(intIP, pubIP string) error {
-	ipt, err := iptables.New()
-	if err != nil {
-		return fmt.Errorf("initFirewall: Unable to setup iptables %v", err)
-	}
+func enablePublicIP(ipt ipTables, internalIP, publicIP net.IP) error {
+	intIP := internalIP.String()
+	pubIP := publicIP.String()
 
 	// insert DNAT rule of PREROUTING
 	// iptables -t nat -I ciao-floating-ip-pre -d <pubIP> -j DNAT --to-destination <intIP>
-	ok, err := ipt.Exists("nat", "ciao-floating-ip-pre", "-d", pubIP+"/32", "-j", "DNAT", "--to-destination", intIP)
+	ok, err := ipt.Exists("nat", "ciao-floating-ip-pre", "-d", hostCIDR(publicIP), "-j", "DNAT", "--to-destination", intIP)
 	if err != nil {
 		return fmt.Errorf("Error: InitFirewall could not verify existence of PREROUTING rule %s to %s", pubIP, intIP)
 	}
 
 	if !ok {
-		err := ipt.Insert("nat", "ciao-floating-ip-pre", 1, "-d", pubIP+"/32", "-j", "DNAT", "--to-destination", intIP)
+		err := ipt.Insert("nat", "ciao-floating-ip-pre", 1, "-d", hostCIDR(publicIP), "-j", "DNAT", "--to-destination", intIP)
 		if err != nil {
 			return fmt.Errorf("Could not insert firewall PREROUTING rule %s to %s into chain ciao-floating-ip-pre", pubIP, intIP)
 		}
@@ -410,13 +729,13 @@ func enablePublicIP(intIP, pubIP string) error {
 
 	// insert SNAT rule of POSTROUTING
 	// iptables -t nat -I ciao-floating-ip-post -s <intIP> -j SNAT --to-source <pubIP>
-	ok, err = ipt.Exists("nat", "ciao-floating-ip-post", "-s", intIP+"/32", "-j", "SNAT", "--to-source", pubIP)
+	ok, err = ipt.Exists("nat", "ciao-floating-ip-post", "-s", hostCIDR(internalIP), "-j", "SNAT", "--to-source", pubIP)
 	if err != nil {
 		return fmt.Errorf("Error: InitFirewall could not verify existence of POSTROUTING rule %s to %s", intIP, pubIP)
 	}
 
 	if !ok {
-		err := ipt.Insert("nat", "ciao-floating-ip-post", 1, "-s", intIP+"/32", "-j", "SNAT", "--to-source", pubIP)
+		err := ipt.Insert("nat", "ciao-floating-ip-post", 1, "-s", hostCIDR(internalIP), "-j", "SNAT", "--to-source", pubIP)
 		if err != nil {
 			return fmt.Errorf("Could not insert firewall POSTROUTING rule %s to %s into chain ciao-floating-ip-post", intIP, pubIP)
 		}
@@ -425,21 +744,19 @@ func enablePublicIP(intIP, pubIP string) error {
 	return nil
 }
 
-func disablePublicIP(intIP, pubIP string) error {
-	ipt, err := iptables.New()
-	if err != nil {
-		return fmt.Errorf("initFirewall: Unable to setup iptables %v", err)
-	}
+func disablePublicIP(ipt ipTables, internalIP, publicIP net.IP) error {
+	intIP := internalIP.String()
+	pubIP := publicIP.String()
 
 	// delete DNAT PREROUTING rule
 	// iptables -t nat -D ciao-floating-ip-pre -d <pubIP> -j DNAT --to-destination <intIP>
-	ok, err := ipt.Exists("nat", "ciao-floating-ip-pre", "-d", pubIP+"/32", "-j", "DNAT", "--to-destination", intIP)
+	ok, err := ipt.Exists("nat", "ciao-floating-ip-pre", "-d", hostCIDR(publicIP), "-j", "DNAT", "--to-destination", intIP)
 	if err != nil {
 		return fmt.Errorf("Error: InitFirewall could not verify existence of PREROUTING rule %s to %s", pubIP, intIP)
 	}
 
 	if ok {
-		err := ipt.Delete("nat", "ciao-floating-ip-pre", "-d", pubIP+"/32", "-j", "DNAT", "--to-destination", intIP)
+		err := ipt.Delete("nat", "ciao-floating-ip-pre", "-d", hostCIDR(publicIP), "-j", "DNAT", "--to-destination", intIP)
 		if err != nil {
 			return fmt.Errorf("Could not delete firewall PREROUTING rule %s to %s into chain ciao-floating-ip-pre", pubIP, intIP)
 		}
@@ -447,13 +764,13 @@ func disablePublicIP(intIP, pubIP string) error {
 
 	// delete SNAT POSTROUTING rule
 	// iptables -t nat -D ciao-floating-ip-post -s <intIP> -j SNAT --to-source <pubIP>
-	ok, err = ipt.Exists("nat", "ciao-floating-ip-post", "-s", intIP+"/32", "-j", "SNAT", "--to-source", pubIP)
+	ok, err = ipt.Exists("nat", "ciao-floating-ip-post", "-s", hostCIDR(internalIP), "-j", "SNAT", "--to-source", pubIP)
 	if err != nil {
 		return fmt.Errorf("Error: InitFirewall could not verify existence of POSTROUTING rule %s to %s", intIP, pubIP)
 	}
 
 	if ok {
-		err := ipt.Delete("nat", "ciao-floating-ip-post", "-s", intIP+"/32", "-j", "SNAT", "--to-source", pubIP)
+		err := ipt.Delete("nat", "ciao-floating-ip-post", "-s", hostCIDR(internalIP), "-j", "SNAT", "--to-source", pubIP)
 		if err != nil {
 			return fmt.Errorf("Could not delete firewall POSTROUTING rule %s to %s into chain ciao-floating-ip-post", intIP, pubIP)
 		}
@@ -462,6 +779,98 @@ func disablePublicIP(intIP, pubIP string) error {
 	return nil
 }
 
+//ExternalIPAssign assigns or unassigns a public IP to the external
+//interface of the CNCI, for the services the CNCI runs on it
+func ExternalIPAssign(action FwAction, publicIP net.IP, extInterface string) error {
+	err := ipAssign(action, publicIP, extInterface)
+	if err != nil {
+		return fmt.Errorf("Public IP Assignment failure %v", err)
+	}
+
+	return nil
+}
+
+//PortForwardRule forwards a tcp or udp port of a public IP to a port
+//of an internal IP of the same address family
+type PortForwardRule struct {
+	Protocol     string
+	PublicIP     net.IP
+	PublicPort   int
+	InternalIP   net.IP
+	InternalPort int
+}
+
+//ruleSpec returns the iptables rule specification of a port forward
+func (r PortForwardRule) ruleSpec() ([]string, error) {
+	if r.PublicIP == nil || r.InternalIP == nil {
+		return nil, fmt.Errorf("Invalid port forward IP")
+	}
+
+	if (r.PublicIP.To4() != nil) != (r.InternalIP.To4() != nil) {
+		return nil, fmt.Errorf("Public IP %v and internal IP %v address families differ",
+			r.PublicIP, r.InternalIP)
+	}
+
+	if r.Protocol != "tcp" && r.Protocol != "udp" {
+		return nil, fmt.Errorf("Invalid protocol %s", r.Protocol)
+	}
+
+	if r.PublicPort <= 0 || r.PublicPort > 65535 ||
+		r.InternalPort <= 0 || r.InternalPort > 65535 {
+		return nil, fmt.Errorf("Invalid port forward %d to %d", r.PublicPort, r.InternalPort)
+	}
+
+	dest := net.JoinHostPort(r.InternalIP.String(), strconv.Itoa(r.InternalPort))
+
+	return []string{"-d", hostCIDR(r.PublicIP), "-p", r.Protocol,
+		"--dport", strconv.Itoa(r.PublicPort),
+		"-j", "DNAT", "--to-destination", dest}, nil
+}
+
+//PortForward Enables/Disables the forwarding of a port of a public IP.
+//The public IP has to be assigned to the CNCI, see ExternalIPAssign.
+//IPv6 addresses require IPv6 to be routed
+func (f *Firewall) PortForward(action FwAction, rule PortForwardRule) error {
+	spec, err := rule.ruleSpec()
+	if err != nil {
+		return err
+	}
+
+	var ipt ipTables = f.IPTables
+	if rule.PublicIP.To4() == nil {
+		if f.ip6t == nil {
+			return fmt.Errorf("Public IP %v requires IPv6 routing", rule.PublicIP)
+		}
+		ipt = f.ip6t
+	}
+
+	ok, err := ipt.Exists("nat", "ciao-floating-ip-pre", spec...)
+	if err != nil {
+		return fmt.Errorf("Unable to verify existence of port forward rule %v %v", spec, err)
+	}
+
+	switch action {
+	case FwEnable:
+		if ok {
+			return nil
+		}
+		err = ipt.Insert("nat", "ciao-floating-ip-pre", 1, spec...)
+	case FwDisable:
+		if !ok {
+			return nil
+		}
+		err = ipt.Delete("nat", "ciao-floating-ip-pre", spec...)
+	default:
+		return fmt.Errorf("Invalid parameter %v", action)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Unable to update port forward rule %v %v", spec, err)
+	}
+
+	return nil
+}
+
 //DumpIPTables provides a utility routine that returns
 //the current state of the iptables
 func DumpIPTables() string {
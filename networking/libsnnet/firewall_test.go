@@ -20,6 +20,7 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -136,6 +137,189 @@ func TestFw_PublicIP(t *testing.T) {
 	}
 }
 
+//Tests the translation of port forwards to iptables rules
+//
+//Test checks the iptables rule generated for valid port
+//forwards and that invalid port forwards are rejected
+//
+//Test is expected to pass
+func TestFw_PortForwardSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	pubIP := net.ParseIP("198.51.100.100")
+	intIP := net.ParseIP("192.168.0.2")
+	pubIP6 := net.ParseIP("2001:db8::100")
+	intIP6 := net.ParseIP("fd00:c1a0:0:1000::2")
+
+	rule := PortForwardRule{Protocol: "tcp", PublicIP: pubIP, PublicPort: 2222,
+		InternalIP: intIP, InternalPort: 22}
+	spec, err := rule.ruleSpec()
+	assert.Nil(err)
+	assert.Equal("-d 198.51.100.100/32 -p tcp --dport 2222 -j DNAT --to-destination 192.168.0.2:22",
+		strings.Join(spec, " "))
+
+	rule = PortForwardRule{Protocol: "udp", PublicIP: pubIP6, PublicPort: 53,
+		InternalIP: intIP6, InternalPort: 5353}
+	spec, err = rule.ruleSpec()
+	assert.Nil(err)
+	assert.Equal("-d 2001:db8::100/128 -p udp --dport 53 -j DNAT --to-destination [fd00:c1a0:0:1000::2]:5353",
+		strings.Join(spec, " "))
+
+	invalid := []PortForwardRule{
+		{Protocol: "tcp", PublicPort: 22, InternalIP: intIP, InternalPort: 22},
+		{Protocol: "tcp", PublicIP: pubIP, PublicPort: 22, InternalIP: intIP6, InternalPort: 22},
+		{Protocol: "icmp", PublicIP: pubIP, PublicPort: 22, InternalIP: intIP, InternalPort: 22},
+		{Protocol: "tcp", PublicIP: pubIP, InternalIP: intIP, InternalPort: 22},
+		{Protocol: "tcp", PublicIP: pubIP, PublicPort: 22, InternalIP: intIP, InternalPort: 65536},
+	}
+
+	for _, rule := range invalid {
+		_, err := rule.ruleSpec()
+		assert.NotNil(err)
+	}
+}
+
+//Tests port forward setup
+//
+//Test checks that ports of a public IP can be forwarded
+//to an internal IP and that the forwards can be removed
+//
+//Test is expected to pass
+func TestFw_PortForward(t *testing.T) {
+	fwinit()
+	fw, err := InitFirewall(fwIf)
+	require.Nil(t, err)
+
+	rule := PortForwardRule{
+		Protocol:     "tcp",
+		PublicIP:     net.ParseIP("198.51.100.100"),
+		PublicPort:   2222,
+		InternalIP:   net.ParseIP("198.51.100.1"),
+		InternalPort: 22,
+	}
+
+	assert.Nil(t, fw.PortForward(FwEnable, rule))
+	assert.Nil(t, fw.PortForward(FwEnable, rule))
+	assert.Nil(t, fw.PortForward(FwDisable, rule))
+	assert.Nil(t, fw.PortForward(FwDisable, rule))
+
+	assert.Nil(t, fw.ShutdownFirewall())
+}
+
+//Tests the translation of security rules to iptables rules
+//
+//Test checks the iptables rule generated for valid security
+//rules and that invalid rules are rejected
+//
+//Test is expected to pass
+func TestFw_SecurityRuleSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	ip := net.ParseIP("192.168.0.2")
+	_, remote, _ := net.ParseCIDR("10.0.0.0/8")
+	ip6 := net.ParseIP("fd00:c1a0:0:1000::2")
+	_, remote6, _ := net.ParseCIDR("2001:db8::/32")
+
+	var specTests = []struct {
+		rule     SecurityRule
+		expected string
+	}{
+		{
+			SecurityRule{Direction: FwIngress, InstanceIP: ip,
+				Protocol: "tcp", PortMin: 22},
+			"-d 192.168.0.2/32 -p tcp --dport 22 -j ACCEPT",
+		},
+		{
+			SecurityRule{Direction: FwIngress, InstanceIP: ip,
+				Protocol: "udp", PortMin: 5000, PortMax: 5010, Remote: remote},
+			"-d 192.168.0.2/32 -s 10.0.0.0/8 -p udp --dport 5000:5010 -j ACCEPT",
+		},
+		{
+			SecurityRule{Direction: FwEgress, InstanceIP: ip, Remote: remote},
+			"-s 192.168.0.2/32 -d 10.0.0.0/8 -j ACCEPT",
+		},
+		{
+			SecurityRule{Direction: FwEgress, InstanceIP: ip, Protocol: "icmp"},
+			"-s 192.168.0.2/32 -p icmp -j ACCEPT",
+		},
+		{
+			SecurityRule{Direction: FwIngress, InstanceIP: ip6,
+				Protocol: "tcp", PortMin: 22, Remote: remote6},
+			"-d fd00:c1a0:0:1000::2/128 -s 2001:db8::/32 -p tcp --dport 22 -j ACCEPT",
+		},
+		{
+			SecurityRule{Direction: FwEgress, InstanceIP: ip6, Protocol: "icmp"},
+			"-s fd00:c1a0:0:1000::2/128 -p icmpv6 -j ACCEPT",
+		},
+	}
+
+	for _, test := range specTests {
+		spec, err := test.rule.ruleSpec()
+		assert.Nil(err)
+		assert.Equal(test.expected, strings.Join(spec, " "))
+	}
+
+	invalid := []SecurityRule{
+		{Direction: FwIngress},
+		{Direction: FwIngress, InstanceIP: ip, Protocol: "sctp"},
+		{Direction: FwIngress, InstanceIP: ip, Protocol: "icmp", PortMin: 22},
+		{Direction: FwIngress, InstanceIP: ip, PortMin: 22},
+		{Direction: FwIngress, InstanceIP: ip, Remote: remote6},
+		{Direction: FwIngress, InstanceIP: ip6, Remote: remote},
+	}
+
+	for _, rule := range invalid {
+		_, err := rule.ruleSpec()
+		assert.NotNil(err)
+	}
+}
+
+//Tests security group rules setup
+//
+//Test checks that the security group rules can be set,
+//replaced and removed
+//
+//Test is expected to pass
+func TestFw_SecurityGroups(t *testing.T) {
+	assert := assert.New(t)
+
+	fwinit()
+	fw, err := InitFirewall(fwIf)
+	require.Nil(t, err)
+
+	ip := net.ParseIP("198.51.100.2")
+	_, remote, _ := net.ParseCIDR("198.51.100.0/24")
+
+	rules := []SecurityRule{
+		{Direction: FwIngress, InstanceIP: ip, Protocol: "tcp", PortMin: 22},
+		{Direction: FwEgress, InstanceIP: ip, Remote: remote},
+	}
+
+	err = fw.SecurityGroups([]net.IP{ip}, rules)
+	assert.Nil(err)
+
+	ok, err := fw.Exists("filter", secGroupChain,
+		"-d", "198.51.100.2/32", "-p", "tcp", "--dport", "22", "-j", "ACCEPT")
+	assert.Nil(err)
+	assert.True(ok)
+
+	err = fw.SecurityGroups([]net.IP{ip}, rules[1:])
+	assert.Nil(err)
+
+	ok, err = fw.Exists("filter", secGroupChain,
+		"-d", "198.51.100.2/32", "-p", "tcp", "--dport", "22", "-j", "ACCEPT")
+	assert.Nil(err)
+	assert.False(ok)
+
+	err = fw.SecurityGroups(nil, nil)
+	assert.Nil(err)
+
+	assert.Nil(deleteSecGroupChain(fw.IPTables, secGroupChain))
+
+	err = fw.ShutdownFirewall()
+	assert.Nil(err)
+}
+
 //Exercises all valid CNCI Firewall APIs
 //
 //This tests performs the sequence of operations typically
@@ -0,0 +1,146 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+//ipTables is the subset of iptables operations used by the firewall,
+//implemented for IPv4 by go-iptables and for IPv6 by ip6Tables
+type ipTables interface {
+	Exists(table, chain string, rulespec ...string) (bool, error)
+	Insert(table, chain string, pos int, rulespec ...string) error
+	Append(table, chain string, rulespec ...string) error
+	AppendUnique(table, chain string, rulespec ...string) error
+	Delete(table, chain string, rulespec ...string) error
+	NewChain(table, chain string) error
+	ClearChain(table, chain string) error
+	RenameChain(table, oldChain, newChain string) error
+	DeleteChain(table, chain string) error
+}
+
+//ip6Tables runs ip6tables commands. The vendored go-iptables only
+//handles iptables
+type ip6Tables struct {
+	path string
+}
+
+func newIP6Tables() (*ip6Tables, error) {
+	path, err := exec.LookPath("ip6tables")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ip6Tables{path: path}, nil
+}
+
+//run runs an ip6tables command, returning its exit status on failure
+func (ipt *ip6Tables) run(args ...string) (int, error) {
+	args = append(args, "--wait")
+	out, err := exec.Command(ipt.path, args...).CombinedOutput()
+	if err == nil {
+		return 0, nil
+	}
+
+	status := -1
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok {
+			status = ws.ExitStatus()
+		}
+	}
+
+	return status, fmt.Errorf("ip6tables %s failed: %v %s",
+		strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+}
+
+func (ipt *ip6Tables) Exists(table, chain string, rulespec ...string) (bool, error) {
+	args := append([]string{"-t", table, "-C", chain}, rulespec...)
+	status, err := ipt.run(args...)
+	switch {
+	case err == nil:
+		return true, nil
+	case status == 1:
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+func (ipt *ip6Tables) Insert(table, chain string, pos int, rulespec ...string) error {
+	args := append([]string{"-t", table, "-I", chain, strconv.Itoa(pos)}, rulespec...)
+	_, err := ipt.run(args...)
+	return err
+}
+
+func (ipt *ip6Tables) Append(table, chain string, rulespec ...string) error {
+	args := append([]string{"-t", table, "-A", chain}, rulespec...)
+	_, err := ipt.run(args...)
+	return err
+}
+
+func (ipt *ip6Tables) AppendUnique(table, chain string, rulespec ...string) error {
+	exists, err := ipt.Exists(table, chain, rulespec...)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return nil
+	}
+
+	return ipt.Append(table, chain, rulespec...)
+}
+
+func (ipt *ip6Tables) Delete(table, chain string, rulespec ...string) error {
+	args := append([]string{"-t", table, "-D", chain}, rulespec...)
+	_, err := ipt.run(args...)
+	return err
+}
+
+func (ipt *ip6Tables) NewChain(table, chain string) error {
+	_, err := ipt.run("-t", table, "-N", chain)
+	return err
+}
+
+//ClearChain flushes the chain, creating it if it does not exist
+func (ipt *ip6Tables) ClearChain(table, chain string) error {
+	status, err := ipt.run("-t", table, "-N", chain)
+	switch {
+	case err == nil:
+		return nil
+	case status == 1:
+		_, err = ipt.run("-t", table, "-F", chain)
+		return err
+	default:
+		return err
+	}
+}
+
+func (ipt *ip6Tables) RenameChain(table, oldChain, newChain string) error {
+	_, err := ipt.run("-t", table, "-E", oldChain, newChain)
+	return err
+}
+
+func (ipt *ip6Tables) DeleteChain(table, chain string) error {
+	_, err := ipt.run("-t", table, "-X", chain)
+	return err
+}
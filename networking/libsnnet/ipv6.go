@@ -0,0 +1,74 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"fmt"
+	"net"
+)
+
+//TenantIPv6Prefix is the unique local prefix from which the IPv6
+//subnets of tenants are derived. The IPv6 subnet and addresses of a
+//tenant subnet are derived from its IPv4 subnet and addresses, the
+//same way MAC addresses are, so that no separate allocation is needed
+var TenantIPv6Prefix = net.IPNet{
+	IP:   net.ParseIP("fd00:c1a0::"),
+	Mask: net.CIDRMask(48, 128),
+}
+
+//TenantIPv6Subnet returns the /64 IPv6 subnet paired with an IPv4
+//tenant subnet. The subnet ID is made of the second and third bytes of
+//the IPv4 subnet, which have to be at least a /16
+func TenantIPv6Subnet(subnet net.IPNet) (net.IPNet, error) {
+	ip := subnet.IP.To4()
+	ones, bits := subnet.Mask.Size()
+	if ip == nil || bits != 32 || ones < 16 {
+		return net.IPNet{}, fmt.Errorf("invalid tenant subnet %s", subnet.String())
+	}
+	ip = ip.Mask(subnet.Mask)
+
+	ipv6 := make(net.IP, net.IPv6len)
+	copy(ipv6, TenantIPv6Prefix.IP.To16())
+	ipv6[6] = ip[1]
+	ipv6[7] = ip[2]
+
+	return net.IPNet{
+		IP:   ipv6,
+		Mask: net.CIDRMask(64, 128),
+	}, nil
+}
+
+//TenantIPv6Addr returns the IPv6 address paired with an IPv4 tenant
+//address. Its interface ID is made of the last two bytes of the IPv4
+//address
+func TenantIPv6Addr(ip net.IP, subnet net.IPNet) (net.IP, error) {
+	ip4 := ip.To4()
+	if ip4 == nil || !subnet.Contains(ip4) {
+		return nil, fmt.Errorf("invalid tenant address %s", ip)
+	}
+
+	ipv6Net, err := TenantIPv6Subnet(subnet)
+	if err != nil {
+		return nil, err
+	}
+
+	ipv6 := ipv6Net.IP
+	ipv6[14] = ip4[2]
+	ipv6[15] = ip4[3]
+
+	return ipv6, nil
+}
@@ -0,0 +1,65 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+//Tests the derivation of tenant IPv6 subnets and addresses
+//
+//Test checks that IPv6 subnets and addresses are derived from
+//IPv4 tenant subnets and addresses and that invalid ones are
+//rejected
+//
+//Test is expected to pass
+func TestIPv6_TenantAddresses(t *testing.T) {
+	assert := assert.New(t)
+
+	_, subnet, _ := net.ParseCIDR("172.16.5.0/24")
+
+	subnetIPv6, err := TenantIPv6Subnet(*subnet)
+	assert.Nil(err)
+	assert.Equal("fd00:c1a0:0:1005::/64", subnetIPv6.String())
+
+	ip, err := TenantIPv6Addr(net.ParseIP("172.16.5.7"), *subnet)
+	assert.Nil(err)
+	assert.Equal("fd00:c1a0:0:1005::507", ip.String())
+	assert.True(subnetIPv6.Contains(ip))
+
+	_, wide, _ := net.ParseCIDR("172.16.4.0/22")
+	ip, err = TenantIPv6Addr(net.ParseIP("172.16.6.9"), *wide)
+	assert.Nil(err)
+	assert.Equal("fd00:c1a0:0:1004::609", ip.String())
+
+	_, err = TenantIPv6Addr(net.ParseIP("172.16.6.9"), *subnet)
+	assert.NotNil(err)
+
+	_, err = TenantIPv6Addr(net.ParseIP("fd00::1"), *subnet)
+	assert.NotNil(err)
+
+	_, large, _ := net.ParseCIDR("10.0.0.0/8")
+	_, err = TenantIPv6Subnet(*large)
+	assert.NotNil(err)
+
+	_, subnet6, _ := net.ParseCIDR("fd00::/64")
+	_, err = TenantIPv6Subnet(*subnet6)
+	assert.NotNil(err)
+}
@@ -0,0 +1,301 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	//DefaultHealthInterval is the time between two health checks of
+	//the members of a load balancer
+	DefaultHealthInterval = 5 * time.Second
+	//DefaultHealthTimeout is the time after which a member that does
+	//not accept a connection is considered unhealthy
+	DefaultHealthTimeout = 2 * time.Second
+)
+
+const (
+	acceptMinDelay = 5 * time.Millisecond
+	acceptMaxDelay = time.Second
+)
+
+//LoadBalancer is a userspace TCP load balancer. It accepts connections on
+//its listen address and proxies each of them to one of its healthy
+//members, in turn. A member is healthy when it accepted a connection at
+//its last health check. On a CNCI, the listen address is a public IP
+//assigned with ExternalIPAssign
+type LoadBalancer struct {
+	ID             string
+	Listen         net.TCPAddr
+	Members        []net.TCPAddr
+	HealthInterval time.Duration
+	HealthTimeout  time.Duration
+
+	listener *net.TCPListener
+	done     chan struct{}
+	wg       sync.WaitGroup
+
+	sync.Mutex
+	healthy []bool
+	next    int
+	conns   map[net.Conn]bool
+}
+
+//Start checks the health of the members of the load balancer and starts
+//accepting connections
+func (lb *LoadBalancer) Start() error {
+	if lb.HealthInterval <= 0 {
+		lb.HealthInterval = DefaultHealthInterval
+	}
+	if lb.HealthTimeout <= 0 {
+		lb.HealthTimeout = DefaultHealthTimeout
+	}
+
+	listener, err := net.ListenTCP("tcp", &lb.Listen)
+	if err != nil {
+		return fmt.Errorf("Unable to listen on %v %v", lb.Listen.String(), err)
+	}
+
+	lb.listener = listener
+	lb.done = make(chan struct{})
+	lb.healthy = make([]bool, len(lb.Members))
+	lb.conns = make(map[net.Conn]bool)
+	lb.checkMembers()
+
+	lb.wg.Add(2)
+	go lb.healthChecks()
+	go lb.accept()
+
+	return nil
+}
+
+//Stop stops accepting connections and closes the connections being
+//proxied
+func (lb *LoadBalancer) Stop() {
+	if lb.listener == nil {
+		return
+	}
+
+	close(lb.done)
+	_ = lb.listener.Close()
+
+	lb.Lock()
+	for conn := range lb.conns {
+		_ = conn.Close()
+	}
+	lb.Unlock()
+
+	lb.wg.Wait()
+	lb.listener = nil
+}
+
+//Addr returns the address the load balancer listens on
+func (lb *LoadBalancer) Addr() net.Addr {
+	if lb.listener == nil {
+		return nil
+	}
+
+	return lb.listener.Addr()
+}
+
+//HealthyMembers returns the members that passed their last health check
+func (lb *LoadBalancer) HealthyMembers() []net.TCPAddr {
+	var members []net.TCPAddr
+
+	lb.Lock()
+	defer lb.Unlock()
+
+	for i, healthy := range lb.healthy {
+		if healthy {
+			members = append(members, lb.Members[i])
+		}
+	}
+
+	return members
+}
+
+func (lb *LoadBalancer) setHealth(member int, healthy bool) {
+	lb.Lock()
+	lb.healthy[member] = healthy
+	lb.Unlock()
+}
+
+//checkMembers connects to all the members concurrently and records
+//which ones accepted the connection
+func (lb *LoadBalancer) checkMembers() {
+	var wg sync.WaitGroup
+
+	for i := range lb.Members {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", lb.Members[i].String(), lb.HealthTimeout)
+			if err == nil {
+				_ = conn.Close()
+			}
+			lb.setHealth(i, err == nil)
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func (lb *LoadBalancer) healthChecks() {
+	defer lb.wg.Done()
+
+	ticker := time.NewTicker(lb.HealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lb.done:
+			return
+		case <-ticker.C:
+			lb.checkMembers()
+		}
+	}
+}
+
+//nextMember returns the next healthy member after the last one
+//returned, or -1 if no member is healthy
+func (lb *LoadBalancer) nextMember() int {
+	lb.Lock()
+	defer lb.Unlock()
+
+	for i := 0; i < len(lb.Members); i++ {
+		member := (lb.next + i) % len(lb.Members)
+		if lb.healthy[member] {
+			lb.next = member + 1
+			return member
+		}
+	}
+
+	return -1
+}
+
+//track records a connection so that it is closed when the load balancer
+//stops. It returns false if the load balancer is stopping
+func (lb *LoadBalancer) track(conn net.Conn) bool {
+	lb.Lock()
+	defer lb.Unlock()
+
+	select {
+	case <-lb.done:
+		return false
+	default:
+	}
+
+	lb.conns[conn] = true
+	return true
+}
+
+func (lb *LoadBalancer) untrack(conn net.Conn) {
+	lb.Lock()
+	delete(lb.conns, conn)
+	lb.Unlock()
+}
+
+//accept proxies the accepted connections. As net/http.Server does, it
+//backs off on accept errors, such as running out of file descriptors,
+//instead of spinning
+func (lb *LoadBalancer) accept() {
+	defer lb.wg.Done()
+
+	var delay time.Duration
+	for {
+		conn, err := lb.listener.AcceptTCP()
+		if err != nil {
+			if delay == 0 {
+				delay = acceptMinDelay
+			} else if delay *= 2; delay > acceptMaxDelay {
+				delay = acceptMaxDelay
+			}
+
+			select {
+			case <-lb.done:
+				return
+			case <-time.After(delay):
+				continue
+			}
+		}
+		delay = 0
+
+		if !lb.track(conn) {
+			_ = conn.Close()
+			return
+		}
+
+		lb.wg.Add(1)
+		go lb.proxy(conn)
+	}
+}
+
+//dialMember connects to a healthy member, marking the members it fails
+//to connect to as unhealthy
+func (lb *LoadBalancer) dialMember() (*net.TCPConn, error) {
+	for i := 0; i < len(lb.Members); i++ {
+		member := lb.nextMember()
+		if member < 0 {
+			break
+		}
+
+		conn, err := net.DialTimeout("tcp", lb.Members[member].String(), lb.HealthTimeout)
+		if err == nil {
+			return conn.(*net.TCPConn), nil
+		}
+
+		lb.setHealth(member, false)
+	}
+
+	return nil, fmt.Errorf("No healthy member in load balancer %v", lb.ID)
+}
+
+func (lb *LoadBalancer) proxy(client *net.TCPConn) {
+	defer lb.wg.Done()
+	defer lb.untrack(client)
+	defer func() { _ = client.Close() }()
+
+	server, err := lb.dialMember()
+	if err != nil {
+		return
+	}
+
+	if !lb.track(server) {
+		_ = server.Close()
+		return
+	}
+	defer lb.untrack(server)
+	defer func() { _ = server.Close() }()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(server, client)
+		_ = server.CloseWrite()
+	}()
+
+	_, _ = io.Copy(client, server)
+	_ = client.CloseWrite()
+
+	wg.Wait()
+}
@@ -0,0 +1,110 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//lbTestMember starts a server that writes its name to every client
+func lbTestMember(t *testing.T, name string) *net.TCPListener {
+	l, err := net.ListenTCP("tcp", &net.TCPAddr{IP: net.ParseIP("127.0.0.1")})
+	require.Nil(t, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Write([]byte(name))
+			_ = conn.Close()
+		}
+	}()
+
+	return l
+}
+
+func lbTestRead(t *testing.T, lb *LoadBalancer) string {
+	conn, err := net.Dial("tcp", lb.Addr().String())
+	require.Nil(t, err)
+	defer func() { _ = conn.Close() }()
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	data, _ := ioutil.ReadAll(conn)
+	return string(data)
+}
+
+//Tests the userspace load balancer
+//
+//Test checks that connections are proxied in turn to the healthy
+//members of a load balancer, that members which stop accepting
+//connections are skipped and that no connection is accepted once
+//the load balancer is stopped
+//
+//Test is expected to pass
+func TestLoadBalancer_Proxy(t *testing.T) {
+	assert := assert.New(t)
+
+	m1 := lbTestMember(t, "m1")
+	m2 := lbTestMember(t, "m2")
+	defer func() { _ = m2.Close() }()
+
+	// a member that is not listening
+	m3 := lbTestMember(t, "m3")
+	_ = m3.Close()
+
+	lb := &LoadBalancer{
+		ID:     "lbtest",
+		Listen: net.TCPAddr{IP: net.ParseIP("127.0.0.1")},
+		Members: []net.TCPAddr{
+			*m1.Addr().(*net.TCPAddr),
+			*m2.Addr().(*net.TCPAddr),
+			*m3.Addr().(*net.TCPAddr),
+		},
+		HealthInterval: time.Hour,
+	}
+
+	require.Nil(t, lb.Start())
+
+	assert.Equal(2, len(lb.HealthyMembers()))
+
+	replies := map[string]int{}
+	for i := 0; i < 4; i++ {
+		replies[lbTestRead(t, lb)]++
+	}
+	assert.Equal(map[string]int{"m1": 2, "m2": 2}, replies)
+
+	_ = m1.Close()
+	for i := 0; i < 2; i++ {
+		assert.Equal("m2", lbTestRead(t, lb))
+	}
+	assert.Equal([]net.TCPAddr{*m2.Addr().(*net.TCPAddr)}, lb.HealthyMembers())
+
+	addr := lb.Addr().String()
+	lb.Stop()
+	assert.Nil(lb.Addr())
+
+	_, err := net.Dial("tcp", addr)
+	assert.NotNil(err)
+}
@@ -35,6 +35,8 @@ func netError(dev interface{}, format string, args ...interface{}) error {
 		return fmt.Errorf("cncivnic error: "+format, args...)
 	case GreTunEP, *GreTunEP:
 		return fmt.Errorf("gre error: "+format, args...)
+	case VxlanTunEP, *VxlanTunEP:
+		return fmt.Errorf("vxlan error: "+format, args...)
 	}
 	return fmt.Errorf("network error: "+format, args...)
 }
@@ -97,6 +99,9 @@ const (
 	Routed NetworkMode = iota
 	// GreTunnel means tenant instances interlinked using GRE tunnels. Full tenant isolation
 	GreTunnel
+	// VxlanTunnel means tenant instances interlinked using VXLAN tunnels. Full tenant isolation
+	// The subnet key is used as the VXLAN network identifier
+	VxlanTunnel
 )
 
 // VnicRole specifies the role of the VNIC
@@ -109,6 +114,9 @@ const (
 	TenantContainer //Attach to a container in the tenant network
 	//DataCenter role is assigned to resources owned by the data center
 	DataCenter //Attached to the data center network
+	//TenantRouter role is assigned to the ports of the distributed router
+	//of a tenant on a compute node
+	TenantRouter //Attached to the tenant router of the compute node
 )
 
 // Network describes the configuration of the data center network.
@@ -147,9 +155,18 @@ type Bridge struct {
 type DhcpEntry struct {
 	MACAddr  net.HardwareAddr
 	IPAddr   net.IP
+	IPv6Addr net.IP // Optional
 	Hostname string // Optional
 }
 
+// DNSRecord maps the host name of an instance, within the DNS zone of its
+// tenant, to its addresses
+type DNSRecord struct {
+	Name     string
+	IPAddr   net.IP
+	IPv6Addr net.IP // Optional
+}
+
 //VnicAttrs represent common Vnic attributes
 type VnicAttrs struct {
 	Attrs
@@ -158,6 +175,7 @@ type VnicAttrs struct {
 	BridgeID   string // ID of bridge it has attached to
 	IPAddr     *net.IP
 	MTU        int
+	Bandwidth  BandwidthLimits // Rates at which the VNIC traffic is shaped
 }
 
 // Vnic represents a ciao VNIC (typically a tap or veth interface)
@@ -186,3 +204,34 @@ type GreTunEP struct {
 	CNCIId   string // UUID of the CNCI
 	CNId     string // UUID of the CN
 }
+
+// VxlanTunEP ciao VXLAN Tunnel representation
+// This represents one end of the tunnel. On a CNCI a single end point
+// per tenant subnet is shared by all the compute nodes of the subnet
+type VxlanTunEP struct {
+	Attrs
+	Link     *netlink.Vxlan
+	VNI      uint32
+	LocalIP  net.IP
+	RemoteIP net.IP // nil when the remote end points are FDB entries
+	Port     int
+}
+
+// tunnelEP is the tunnel end point connecting a tenant bridge on a CN
+// to the CNCI of the tenant subnet, a GreTunEP or a VxlanTunEP
+type tunnelEP interface {
+	attrs() *Attrs
+	index() int
+	setIndex(index int)
+	create() error
+	destroy() error
+	enable() error
+	attach(dev interface{}) error
+}
+
+func (g *GreTunEP) attrs() *Attrs        { return &g.Attrs }
+func (g *GreTunEP) index() int           { return g.Link.Index }
+func (g *GreTunEP) setIndex(index int)   { g.Link.Index = index }
+func (v *VxlanTunEP) attrs() *Attrs      { return &v.Attrs }
+func (v *VxlanTunEP) index() int         { return v.Link.Index }
+func (v *VxlanTunEP) setIndex(index int) { v.Link.Index = index }
@@ -0,0 +1,178 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+//BandwidthLimits are the rates, in kbit/s, at which the traffic of a VNIC
+//is shaped. A rate of zero is not limited
+type BandwidthLimits struct {
+	IngressKbps int //Traffic received by the instance
+	EgressKbps  int //Traffic sent by the instance
+}
+
+var (
+	htbRootHandle  = netlink.MakeHandle(1, 0)
+	htbClassHandle = netlink.MakeHandle(1, 1)
+	ingressHandle  = netlink.MakeHandle(0xffff, 0)
+)
+
+//ifbName returns the name of the IFB device to which the traffic sent
+//by the instance on a VNIC is redirected to be shaped
+func ifbName(linkName string) string {
+	return prefixIfb + strings.TrimPrefix(linkName, prefixVnic)
+}
+
+//shapeLink limits the rate at which a link transmits with a HTB qdisc
+//whose default class is the only class
+func shapeLink(link netlink.Link, kbps int) error {
+	qdisc := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: link.Attrs().Index,
+		Handle:    htbRootHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	qdisc.Defcls = 1
+
+	if err := netlink.QdiscReplace(qdisc); err != nil {
+		return err
+	}
+
+	class := netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: link.Attrs().Index,
+		Parent:    htbRootHandle,
+		Handle:    htbClassHandle,
+	}, netlink.HtbClassAttrs{
+		Rate: uint64(kbps) * 1000,
+	})
+
+	return netlink.ClassReplace(class)
+}
+
+//createIfb creates and enables the IFB device of a VNIC
+func (v *Vnic) createIfb() (netlink.Link, error) {
+	name := ifbName(v.LinkName)
+
+	if link, err := netlink.LinkByName(name); err == nil {
+		return link, nil
+	}
+
+	ifb := &netlink.Ifb{
+		LinkAttrs: netlink.LinkAttrs{Name: name},
+	}
+
+	if err := netlink.LinkAdd(ifb); err != nil {
+		return nil, netError(v, "create ifb %v %v", name, err)
+	}
+
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, netError(v, "create ifb link by name %v %v", name, err)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		_ = netlink.LinkDel(link)
+		return nil, netError(v, "enable ifb %v %v", name, err)
+	}
+
+	return link, nil
+}
+
+//redirectIngress redirects all the traffic the VNIC receives, i.e. the
+//traffic sent by the instance, to the IFB device where it can be shaped
+func (v *Vnic) redirectIngress(ifb netlink.Link) error {
+	index := v.Link.Attrs().Index
+
+	ingress := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: index,
+			Handle:    ingressHandle,
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+
+	if err := netlink.QdiscReplace(ingress); err != nil {
+		return netError(v, "ingress qdisc %v %v", v.LinkName, err)
+	}
+
+	filter := &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: index,
+			Parent:    ingressHandle,
+			Priority:  1,
+			Protocol:  syscall.ETH_P_ALL,
+		},
+		RedirIndex: ifb.Attrs().Index,
+	}
+
+	if err := netlink.FilterAdd(filter); err != nil {
+		return netError(v, "ingress redirect %v %v", v.LinkName, err)
+	}
+
+	return nil
+}
+
+//setBandwidth applies the bandwidth limits of the VNIC. The traffic
+//received by the instance is shaped on the VNIC itself. The traffic sent
+//by the instance is received by the VNIC, where it cannot be shaped, so
+//it is redirected to an IFB device and shaped when the IFB transmits it
+func (v *Vnic) setBandwidth() error {
+	if v.Link == nil || v.Link.Attrs().Index == 0 {
+		return netError(v, "set bandwidth unnitialized")
+	}
+
+	if v.Bandwidth.IngressKbps > 0 {
+		if err := shapeLink(v.Link, v.Bandwidth.IngressKbps); err != nil {
+			return netError(v, "shape ingress %v %v", v.LinkName, err)
+		}
+	}
+
+	if v.Bandwidth.EgressKbps <= 0 {
+		return nil
+	}
+
+	ifb, err := v.createIfb()
+	if err != nil {
+		return err
+	}
+
+	if err := shapeLink(ifb, v.Bandwidth.EgressKbps); err != nil {
+		_ = netlink.LinkDel(ifb)
+		return netError(v, "shape egress %v %v", ifb.Attrs().Name, err)
+	}
+
+	if err := v.redirectIngress(ifb); err != nil {
+		_ = netlink.LinkDel(ifb)
+		return err
+	}
+
+	return nil
+}
+
+//destroyIfb deletes the IFB device of the VNIC, if any
+func (v *Vnic) destroyIfb() error {
+	link, err := netlink.LinkByName(ifbName(v.LinkName))
+	if err != nil {
+		return nil
+	}
+
+	return netlink.LinkDel(link)
+}
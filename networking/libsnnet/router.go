@@ -0,0 +1,800 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+//The distributed router of a tenant on a compute node is a VRF with one
+//port on the bridge of each subnet of the tenant. A port is a veth pair,
+//the bridge side is handled like the VNIC of a container, the other side
+//is enslaved to the VRF and owns the router address of the subnet.
+//Traffic between two subnets of the tenant is routed by the router of the
+//node of the instance that sends it, instead of hairpinning through the
+//CNCI of the subnet. The same router address is used on every node, so
+//the ARP traffic and the packets for that address are dropped on the
+//tunnel of the subnet and each instance only ever reaches the router of
+//its own node. For the same reason the router only reaches the instances
+//of its node directly, through host routes, and the rest of the subnet
+//through the CNCI of the subnet. Only the traffic between two instances
+//on the same node avoids the CNCIs
+const (
+	vrfPrefix    = "vrf_"
+	routerPortID = "router"
+	//vrfTableBase is the first routing table used for the tenant routers
+	vrfTableBase = 1000
+	//vrfUnreachableMetric is the metric of the default route of the table
+	//of a tenant router. It stops the lookups falling through to the tables
+	//of the node without overriding any other route
+	vrfUnreachableMetric = 4278198272
+	//routerMTU matches the MTU dnsmasq gives the tenant instances
+	routerMTU = 1400
+	//ifaNoPrefixRoute is IFA_F_NOPREFIXROUTE, it stops the kernel from
+	//routing the whole subnet of the router address on the router port
+	ifaNoPrefixRoute = 0x200
+)
+
+//RouterSubnet describes a subnet of a tenant to which the router of the
+//tenant on a compute node is connected. The fields identify the bridge of
+//the subnet and must match the ones of the VNICs on the subnet
+type RouterSubnet struct {
+	Subnet    net.IPNet
+	SubnetKey int
+	SubnetID  string // UUID
+	ConcID    string // UUID
+	ConcIP    net.IP
+}
+
+//RouterIP returns the address of the distributed router on a tenant
+//subnet, the last host address of the subnet. The first host address is
+//the gateway, which is the CNCI of the subnet
+func RouterIP(subnet net.IPNet) net.IP {
+	ip := subnet.IP.To4()
+	if ip == nil || len(subnet.Mask) != net.IPv4len {
+		return nil
+	}
+
+	router := make(net.IP, net.IPv4len)
+	for i := range router {
+		router[i] = ip[i] | ^subnet.Mask[i]
+	}
+	router[net.IPv4len-1]--
+	return router
+}
+
+//routerGateway returns the address of the CNCI on a tenant subnet, the
+//first host address of the subnet
+func routerGateway(subnet net.IPNet) net.IP {
+	gateway := subnet.IP.To4().Mask(subnet.Mask)
+	gateway[net.IPv4len-1]++
+	return gateway
+}
+
+func newRouterVnic(id string) (*Vnic, error) {
+	vnic, err := NewContainerVnic(id)
+	if err != nil {
+		return nil, err
+	}
+	vnic.Role = TenantRouter
+	return vnic, nil
+}
+
+func routerPortAlias(alias string) bool {
+	return strings.HasPrefix(alias, vnicPrefix) &&
+		strings.HasSuffix(alias, "##"+routerPortID)
+}
+
+func checkRouterSubnet(s RouterSubnet) error {
+	switch {
+	case RouterIP(s.Subnet) == nil:
+		return fmt.Errorf("Invalid router subnet - Subnet %v", s.Subnet)
+	case s.SubnetID == "":
+		return fmt.Errorf("Invalid router subnet - SubnetID")
+	case s.ConcID == "":
+		return fmt.Errorf("Invalid router subnet - ConcID")
+	case s.ConcIP == nil:
+		return fmt.Errorf("Invalid router subnet - ConcIP")
+	}
+
+	return nil
+}
+
+func routerPortCfg(tenantID string, s RouterSubnet) *VnicConfig {
+	return &VnicConfig{
+		VnicRole:  TenantRouter,
+		ConcIP:    s.ConcIP,
+		MTU:       routerMTU,
+		SubnetKey: s.SubnetKey,
+		Subnet:    s.Subnet,
+		VnicID:    routerPortID,
+		TenantID:  tenantID,
+		SubnetID:  s.SubnetID,
+		ConcID:    s.ConcID,
+	}
+}
+
+//tenantPorts reports if a tenant has instances on the node and returns
+//the aliases of the ports of its router
+//Note: Can only be called when holding the topology lock cn.cnTopology.Lock()
+func (cn *ComputeNode) tenantPorts(tenantID string) (bool, map[string]bool) {
+	prefix := vnicPrefix + tenantID + "_"
+	instances := false
+	ports := make(map[string]bool)
+
+	for alias := range cn.linkMap {
+		if !strings.HasPrefix(alias, prefix) {
+			continue
+		}
+		if routerPortAlias(alias) {
+			ports[alias] = true
+		} else {
+			instances = true
+		}
+	}
+
+	return instances, ports
+}
+
+//tunnelKey returns the subnet key of an existing tunnel
+func tunnelKey(alias string) (int, error) {
+	link, err := netlink.LinkByAlias(alias)
+	if err != nil {
+		return 0, err
+	}
+
+	switch l := link.(type) {
+	case *netlink.Gretap:
+		return int(l.IKey), nil
+	case *netlink.Vxlan:
+		return l.VxlanId, nil
+	}
+
+	return 0, fmt.Errorf("invalid tunnel type %v %v", alias, link.Type())
+}
+
+//existingRouterPortCfg rebuilds the configuration of an existing router
+//port from its alias, the address of its peer and the tunnel of its bridge.
+//The subnet is only set when it could be retrieved, the port can be
+//destroyed without it. It also returns the name of the peer of the port
+func (cn *ComputeNode) existingRouterPortCfg(alias string) (*VnicConfig, string) {
+	id := strings.TrimPrefix(alias, vnicPrefix)
+	id = strings.Split(id, "##")[0]
+	fields := strings.Split(id, "_")
+
+	cfg := &VnicConfig{
+		VnicRole: TenantRouter,
+		VnicID:   routerPortID,
+	}
+	if len(fields) == 4 {
+		cfg.TenantID = fields[0]
+		cfg.SubnetID = fields[1]
+		cfg.ConcID = fields[2]
+		cfg.ConcIP = net.ParseIP(fields[3])
+	}
+
+	cn.cnTopology.Lock()
+	vLink, present := cn.linkMap[alias]
+	cn.cnTopology.Unlock()
+	if !present {
+		return cfg, ""
+	}
+
+	vnic := &Vnic{}
+	vnic.Role = TenantRouter
+	vnic.LinkName = vLink.name
+	peerName := vnic.PeerName()
+
+	key, err := tunnelKey(cn.tunnelAlias(genCnVnicAliases(cfg)))
+	if err != nil {
+		return cfg, peerName
+	}
+
+	peer, err := netlink.LinkByName(peerName)
+	if err != nil {
+		return cfg, peerName
+	}
+
+	addrs, err := netlink.AddrList(peer, netlink.FAMILY_V4)
+	if err != nil || len(addrs) == 0 {
+		return cfg, peerName
+	}
+
+	cfg.SubnetKey = key
+	cfg.Subnet = net.IPNet{
+		IP:   addrs[0].IP.Mask(addrs[0].Mask),
+		Mask: addrs[0].Mask,
+	}
+
+	return cfg, peerName
+}
+
+//removeRouterPorts destroys router ports. The bridges left without VNICs
+//are destroyed too, an event is returned for each of them
+func (cn *ComputeNode) removeRouterPorts(ports map[string]bool) ([]*SsntpEventInfo, error) {
+	var aliases []string
+	for alias := range ports {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	var events []*SsntpEventInfo
+	for _, alias := range aliases {
+		cfg, peerName := cn.existingRouterPortCfg(alias)
+
+		if peerName != "" {
+			//Make forward progress even on error
+			err := cn.Delete("filter", "FORWARD",
+				"-p", "all", "-i", peerName, "-j", "ACCEPT")
+			if err != nil {
+				fmt.Printf("Unable to delete firewall rule %v", err)
+			}
+		}
+
+		if cfg.Subnet.IP != nil {
+			tunnel, err := cn.routerTunnel(cfg)
+			if err == nil {
+				err = filterRouterTunnel(FwDisable, tunnel, cfg.Subnet)
+			}
+			if err != nil {
+				fmt.Printf("Unable to delete router filter %v", err)
+			}
+		}
+
+		event, err := cn.destroyVnicInternal(cfg)
+		if err != nil {
+			return events, err
+		}
+
+		//The CNCI cannot remove its end of the tunnel without the subnet
+		if event != nil && cfg.Subnet.IP != nil {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func freeVrfTable() (uint32, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return 0, err
+	}
+
+	used := make(map[uint32]bool)
+	for _, link := range links {
+		if vrf, ok := link.(*netlink.Vrf); ok {
+			used[vrf.Table] = true
+		}
+	}
+
+	table := uint32(vrfTableBase)
+	for used[table] {
+		table++
+	}
+	return table, nil
+}
+
+func vrfUnreachableRoute(table uint32) *netlink.Route {
+	return &netlink.Route{
+		Dst: &net.IPNet{
+			IP:   net.IPv4zero,
+			Mask: net.CIDRMask(0, 8*net.IPv4len),
+		},
+		Table:    int(table),
+		Type:     syscall.RTN_UNREACHABLE,
+		Priority: vrfUnreachableMetric,
+	}
+}
+
+//createVrf creates the VRF of the router of a tenant, or returns the
+//existing one
+func (cn *ComputeNode) createVrf(tenantID string) (*netlink.Vrf, error) {
+	alias := vrfPrefix + tenantID
+
+	cn.cnTopology.Lock()
+	_, present := cn.linkMap[alias]
+	cn.cnTopology.Unlock()
+
+	if present {
+		return cn.routerVrf(tenantID)
+	}
+
+	table, err := freeVrfTable()
+	if err != nil {
+		return nil, fmt.Errorf("VRF %s table %v", alias, err)
+	}
+
+	vrf := &netlink.Vrf{Table: table}
+
+	cn.cnTopology.Lock()
+	vrf.Name, err = cn.genLinkName(vrf)
+	cn.cnTopology.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := netlink.LinkAdd(vrf); err != nil {
+		cn.cnTopology.Lock()
+		delete(cn.nameMap, vrf.Name)
+		cn.cnTopology.Unlock()
+		return nil, fmt.Errorf("VRF creation failed %s %v", alias, err)
+	}
+
+	link, err := netlink.LinkByName(vrf.Name)
+	if err != nil {
+		return nil, fmt.Errorf("VRF creation failed %s %v", alias, err)
+	}
+
+	//Publish the VRF before configuring it so that it is destroyed with
+	//the router even if the configuration fails
+	ready := make(chan struct{})
+	close(ready)
+	cn.cnTopology.Lock()
+	cn.linkMap[alias] = &linkInfo{
+		index: link.Attrs().Index,
+		name:  vrf.Name,
+		ready: ready,
+	}
+	cn.cnTopology.Unlock()
+
+	if err := netlink.LinkSetAlias(link, alias); err != nil {
+		return nil, fmt.Errorf("VRF set alias %s %v", alias, err)
+	}
+	if err := netlink.LinkSetUp(link); err != nil {
+		return nil, fmt.Errorf("VRF enable failed %s %v", alias, err)
+	}
+	err = netlink.RouteAdd(vrfUnreachableRoute(table))
+	if err != nil && err != syscall.EEXIST {
+		return nil, fmt.Errorf("VRF default route %s %v", alias, err)
+	}
+
+	vrf.Index = link.Attrs().Index
+	return vrf, nil
+}
+
+//destroyVrf destroys the VRF of the router of a tenant if it exists
+func (cn *ComputeNode) destroyVrf(tenantID string) error {
+	alias := vrfPrefix + tenantID
+
+	cn.cnTopology.Lock()
+	defer cn.cnTopology.Unlock()
+
+	vLink, present := cn.linkMap[alias]
+	if !present {
+		return nil
+	}
+
+	link, err := netlink.LinkByName(vLink.name)
+	if err == nil {
+		if vrf, ok := link.(*netlink.Vrf); ok {
+			_ = netlink.RouteDel(vrfUnreachableRoute(vrf.Table))
+		}
+		if err := netlink.LinkDel(link); err != nil {
+			return NewFatalError("VRF destroy failed " + err.Error())
+		}
+	}
+
+	delete(cn.nameMap, vLink.name)
+	delete(cn.linkMap, alias)
+	return nil
+}
+
+func ebtables(args ...string) error {
+	args = append([]string{"--concurrent"}, args...)
+	out, err := exec.Command("ebtables", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ebtables %s failed: %v %s",
+			strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+//routerTunnelRules returns the ebtables rules that keep the router address
+//of a subnet off its tunnel. The router still resolves the CNCI of the
+//subnet, every other ARP packet from or for the router address is dropped,
+//as are the packets sent to it
+func routerTunnelRules(tunnel string, subnet net.IPNet) [][]string {
+	router := RouterIP(subnet).String()
+	gateway := routerGateway(subnet).String()
+
+	return [][]string{
+		{"-o", tunnel, "-p", "ARP", "--arp-ip-src", router,
+			"--arp-ip-dst", gateway, "-j", "ACCEPT"},
+		{"-i", tunnel, "-p", "ARP", "--arp-op", "Reply",
+			"--arp-ip-src", gateway, "--arp-ip-dst", router, "-j", "ACCEPT"},
+		{"-o", tunnel, "-p", "ARP", "--arp-ip-src", router, "-j", "DROP"},
+		{"-o", tunnel, "-p", "ARP", "--arp-ip-dst", router, "-j", "DROP"},
+		{"-o", tunnel, "-p", "IPv4", "--ip-dst", router, "-j", "DROP"},
+		{"-i", tunnel, "-p", "ARP", "--arp-ip-src", router, "-j", "DROP"},
+		{"-i", tunnel, "-p", "ARP", "--arp-ip-dst", router, "-j", "DROP"},
+		{"-i", tunnel, "-p", "IPv4", "--ip-dst", router, "-j", "DROP"},
+	}
+}
+
+//filterRouterTunnel adds or removes the routerTunnelRules of a tunnel.
+//The rules are always removed first so that they are never duplicated
+//and keep their order
+func filterRouterTunnel(action FwAction, tunnel string, subnet net.IPNet) error {
+	rules := routerTunnelRules(tunnel, subnet)
+
+	for _, rule := range rules {
+		_ = ebtables(append([]string{"-D", "FORWARD"}, rule...)...)
+	}
+
+	if action != FwEnable {
+		return nil
+	}
+
+	for _, rule := range rules {
+		if err := ebtables(append([]string{"-A", "FORWARD"}, rule...)...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//routerTunnel returns the name of the tunnel of the bridge of a router port
+func (cn *ComputeNode) routerTunnel(cfg *VnicConfig) (string, error) {
+	alias := cn.tunnelAlias(genCnVnicAliases(cfg))
+
+	cn.cnTopology.Lock()
+	defer cn.cnTopology.Unlock()
+
+	vLink, present := cn.linkMap[alias]
+	if !present {
+		return "", fmt.Errorf("Router tunnel %s not present", alias)
+	}
+	return vLink.name, nil
+}
+
+//routerInstances returns the addresses of the VNICs of the node on the
+//subnet of a router port, they are part of the VNIC aliases
+func (cn *ComputeNode) routerInstances(alias string) []net.IP {
+	prefix := strings.TrimSuffix(alias, routerPortID)
+	var instances []net.IP
+
+	cn.cnTopology.Lock()
+	defer cn.cnTopology.Unlock()
+
+	for a := range cn.linkMap {
+		if a == alias || !strings.HasPrefix(a, prefix) {
+			continue
+		}
+		if ip := net.ParseIP(strings.TrimPrefix(a, prefix)); ip != nil {
+			instances = append(instances, ip)
+		}
+	}
+
+	return instances
+}
+
+//routerRoutes returns the routes of a router port: a host route to each
+//instance of the node on the subnet and a route to the rest of the subnet
+//through its CNCI
+func routerRoutes(port int, table int, subnet net.IPNet, instances []net.IP) []netlink.Route {
+	routes := []netlink.Route{
+		{
+			LinkIndex: port,
+			Dst:       &subnet,
+			Gw:        routerGateway(subnet),
+			Table:     table,
+			Flags:     int(netlink.FLAG_ONLINK),
+		},
+	}
+
+	for _, ip := range instances {
+		routes = append(routes, netlink.Route{
+			LinkIndex: port,
+			Dst: &net.IPNet{
+				IP:   ip.To4(),
+				Mask: net.CIDRMask(8*net.IPv4len, 8*net.IPv4len),
+			},
+			Scope: netlink.SCOPE_LINK,
+			Table: table,
+		})
+	}
+
+	return routes
+}
+
+//setRouterRoutes replaces the unicast routes of a router port in the table
+//of its router with the routerRoutes of the port
+func setRouterRoutes(peer netlink.Link, table int, subnet net.IPNet, instances []net.IP) error {
+	filter := &netlink.Route{
+		LinkIndex: peer.Attrs().Index,
+		Table:     table,
+	}
+	current, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter,
+		netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE)
+	if err != nil {
+		return err
+	}
+
+	routeID := func(r netlink.Route) string {
+		return fmt.Sprintf("%v via %v", r.Dst, r.Gw)
+	}
+
+	wanted := make(map[string]bool)
+	routes := routerRoutes(peer.Attrs().Index, table, subnet, instances)
+	for _, r := range routes {
+		wanted[routeID(r)] = true
+	}
+
+	present := make(map[string]bool)
+	for i := range current {
+		r := current[i]
+		if r.Type != syscall.RTN_UNICAST {
+			continue
+		}
+		if wanted[routeID(r)] {
+			present[routeID(r)] = true
+			continue
+		}
+		if err := netlink.RouteDel(&r); err != nil {
+			return fmt.Errorf("Router route delete %v %v", r, err)
+		}
+	}
+
+	for i := range routes {
+		if present[routeID(routes[i])] {
+			continue
+		}
+		err := netlink.RouteAdd(&routes[i])
+		if err != nil && err != syscall.EEXIST {
+			return fmt.Errorf("Router route add %v %v", routes[i], err)
+		}
+	}
+
+	return nil
+}
+
+//routerVrf returns the VRF of the router of a tenant
+func (cn *ComputeNode) routerVrf(tenantID string) (*netlink.Vrf, error) {
+	alias := vrfPrefix + tenantID
+
+	cn.cnTopology.Lock()
+	vLink, present := cn.linkMap[alias]
+	cn.cnTopology.Unlock()
+	if !present {
+		return nil, fmt.Errorf("VRF %s not present", alias)
+	}
+
+	link, err := netlink.LinkByName(vLink.name)
+	if err != nil {
+		return nil, fmt.Errorf("VRF %s not present %v", alias, err)
+	}
+	vrf, ok := link.(*netlink.Vrf)
+	if !ok {
+		return nil, fmt.Errorf("VRF %s incorrect interface type %v", alias, link.Type())
+	}
+	return vrf, nil
+}
+
+//updateRouterRoutes refreshes the host routes of the ports of the router
+//of a tenant after an instance of the tenant left the node
+func (cn *ComputeNode) updateRouterRoutes(tenantID string, ports map[string]bool) error {
+	if len(ports) == 0 {
+		return nil
+	}
+
+	vrf, err := cn.routerVrf(tenantID)
+	if err != nil {
+		return err
+	}
+
+	for alias := range ports {
+		cfg, peerName := cn.existingRouterPortCfg(alias)
+		if peerName == "" || cfg.Subnet.IP == nil {
+			continue
+		}
+
+		peer, err := netlink.LinkByName(peerName)
+		if err != nil {
+			return fmt.Errorf("Router port peer %s %v", alias, err)
+		}
+
+		err = setRouterRoutes(peer, int(vrf.Table), cfg.Subnet, cn.routerInstances(alias))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//enableRouterPort enslaves the peer of a router port to the VRF of the
+//router, gives it the router address of the subnet and isolates it from
+//the routers of the other nodes
+func (cn *ComputeNode) enableRouterPort(vnic *Vnic, vrf *netlink.Vrf, cfg *VnicConfig) error {
+	peer, err := netlink.LinkByName(vnic.PeerName())
+	if err != nil {
+		return fmt.Errorf("Router port peer %s %v", vnic.GlobalID, err)
+	}
+
+	if err := netlink.LinkSetMasterByIndex(peer, vrf.Index); err != nil {
+		return fmt.Errorf("Router port set VRF %s %v", vnic.GlobalID, err)
+	}
+
+	tunnel, err := cn.routerTunnel(cfg)
+	if err != nil {
+		return err
+	}
+	if err := filterRouterTunnel(FwEnable, tunnel, cfg.Subnet); err != nil {
+		return fmt.Errorf("Router port filter %s %v", vnic.GlobalID, err)
+	}
+
+	//The redirects of the CNCI would point the router to remote
+	//instances it cannot resolve
+	err = writeProc(fmt.Sprintf(procIPv4AccRedir, peer.Attrs().Name), "0")
+	if err != nil {
+		return fmt.Errorf("Router port redirects %s %v", vnic.GlobalID, err)
+	}
+
+	addr := &netlink.Addr{
+		IPNet: &net.IPNet{
+			IP:   RouterIP(cfg.Subnet),
+			Mask: cfg.Subnet.Mask,
+		},
+		Flags: ifaNoPrefixRoute,
+	}
+	if err := netlink.AddrAdd(peer, addr); err != nil && err != syscall.EEXIST {
+		return fmt.Errorf("Router port address %s %v", vnic.GlobalID, err)
+	}
+
+	if err := netlink.LinkSetUp(peer); err != nil {
+		return fmt.Errorf("Router port enable %s %v", vnic.GlobalID, err)
+	}
+
+	alias := genCnVnicAliases(cfg).vnic
+	err = setRouterRoutes(peer, int(vrf.Table), cfg.Subnet, cn.routerInstances(alias))
+	if err != nil {
+		return fmt.Errorf("Router port routes %s %v", vnic.GlobalID, err)
+	}
+
+	//The routed traffic is not filtered here, it crosses the bridge ports
+	//of the instances which enforce their security rules, see
+	//SetSecurityGroups
+	//iptables -A FORWARD -p all -i "$peer" -j ACCEPT
+	return cn.AppendUnique("filter", "FORWARD",
+		"-p", "all", "-i", peer.Attrs().Name, "-j", "ACCEPT")
+}
+
+// SetTenantRouter connects the distributed router of a tenant on the
+// compute node to the given subnets of the tenant. The router is only
+// built while the tenant has instances on the node and more than one
+// subnet. Ports to subnets no longer listed are removed.
+//
+// A port to a subnet with no instances on the node creates the bridge
+// and the tunnel of the subnet. As for CreateVnic, a SSNTP message is
+// returned for each bridge created or destroyed and the caller is
+// responsible to send them to the scheduler.
+//
+// Note: The router is only reached by the instances that route their
+// traffic to the other subnets through the router address of their
+// subnet, see RouterIP
+func (cn *ComputeNode) SetTenantRouter(tenantID string, subnets []RouterSubnet) ([]*SsntpEventInfo, error) {
+	if cn.cnTopology == nil || tenantID == "" {
+		return nil, NewAPIError("invalid tenant router configuration")
+	}
+
+	for _, s := range subnets {
+		if err := checkRouterSubnet(s); err != nil {
+			return nil, NewAPIError(err.Error())
+		}
+	}
+
+	cn.apiThrottleSem <- 1
+	defer func() {
+		<-cn.apiThrottleSem
+	}()
+
+	cn.routerLock.Lock()
+	defer cn.routerLock.Unlock()
+
+	cn.cnTopology.Lock()
+	instances, ports := cn.tenantPorts(tenantID)
+	cn.cnTopology.Unlock()
+
+	var cfgs []*VnicConfig
+	if instances && len(subnets) > 1 {
+		for _, s := range subnets {
+			cfg := routerPortCfg(tenantID, s)
+			delete(ports, genCnVnicAliases(cfg).vnic)
+			cfgs = append(cfgs, cfg)
+		}
+	}
+
+	events, err := cn.removeRouterPorts(ports)
+	if err != nil {
+		return events, err
+	}
+
+	if len(cfgs) == 0 {
+		return events, cn.destroyVrf(tenantID)
+	}
+
+	if err := Routing(FwEnable); err != nil {
+		return events, NewFatalError(err.Error())
+	}
+
+	vrf, err := cn.createVrf(tenantID)
+	if err != nil {
+		return events, NewFatalError(err.Error())
+	}
+
+	for _, cfg := range cfgs {
+		vnic, event, _, err := cn.createVnicInternal(cfg)
+		if event != nil {
+			events = append(events, event)
+		}
+		if err != nil {
+			return events, err
+		}
+
+		if err := cn.enableRouterPort(vnic, vrf, cfg); err != nil {
+			return events, NewFatalError(err.Error())
+		}
+	}
+
+	return events, nil
+}
+
+// ReleaseTenantRouter destroys the distributed router of a tenant on the
+// compute node once the tenant has no instances left on the node. It is
+// meant to be called after a VNIC of the tenant is destroyed. A SSNTP
+// message is returned for each bridge destroyed with the router and the
+// caller is responsible to send them to the scheduler.
+func (cn *ComputeNode) ReleaseTenantRouter(tenantID string) ([]*SsntpEventInfo, error) {
+	if cn.cnTopology == nil || tenantID == "" {
+		return nil, NewAPIError("invalid tenant router configuration")
+	}
+
+	cn.apiThrottleSem <- 1
+	defer func() {
+		<-cn.apiThrottleSem
+	}()
+
+	cn.routerLock.Lock()
+	defer cn.routerLock.Unlock()
+
+	cn.cnTopology.Lock()
+	instances, ports := cn.tenantPorts(tenantID)
+	cn.cnTopology.Unlock()
+
+	if instances {
+		if err := cn.updateRouterRoutes(tenantID, ports); err != nil {
+			return nil, NewFatalError(err.Error())
+		}
+		return nil, nil
+	}
+
+	events, err := cn.removeRouterPorts(ports)
+	if err != nil {
+		return events, err
+	}
+
+	return events, cn.destroyVrf(tenantID)
+}
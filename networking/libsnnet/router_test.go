@@ -0,0 +1,180 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vishvananda/netlink"
+)
+
+//Tests the router address of tenant subnets
+//
+//Test checks that the router address is the last host address of
+//IPv4 subnets and that IPv6 subnets have no router address
+//
+//Test is expected to pass
+func TestRouterIP(t *testing.T) {
+	tests := []struct {
+		subnet string
+		router string
+	}{
+		{"172.16.0.0/24", "172.16.0.254"},
+		{"172.16.4.0/22", "172.16.7.254"},
+		{"10.0.0.128/25", "10.0.0.254"},
+	}
+
+	for _, test := range tests {
+		_, subnet, err := net.ParseCIDR(test.subnet)
+		require.Nil(t, err)
+		assert.Equal(t, test.router, RouterIP(*subnet).String(), test.subnet)
+	}
+
+	_, subnet, err := net.ParseCIDR("fd00::/64")
+	require.Nil(t, err)
+	assert.Nil(t, RouterIP(*subnet))
+}
+
+//Tests the lifecycle of a tenant router
+//
+//Test creates a VNIC on one of the two subnets of a tenant and connects
+//the router of the tenant to both subnets. It checks that the router
+//creates the bridge of the second subnet and owns the router addresses,
+//and that it is torn down once the VNIC is destroyed
+//
+//Test is expected to pass
+func TestCN_TenantRouter(t *testing.T) {
+	assert := assert.New(t)
+	cn, err := cnTestInit()
+	require.Nil(t, err)
+
+	_, net1, _ := net.ParseCIDR("192.168.1.0/24")
+	_, net2, _ := net.ParseCIDR("192.168.2.0/24")
+
+	mac, _ := net.ParseMAC("CA:FE:00:01:02:03")
+	vnicCfg := &VnicConfig{
+		VnicIP:     net.IPv4(192, 168, 1, 100),
+		ConcIP:     net.IPv4(192, 168, 111, 1),
+		VnicMAC:    mac,
+		Subnet:     *net1,
+		SubnetKey:  0xF,
+		VnicID:     "vuuid",
+		InstanceID: "iuuid",
+		TenantID:   "tuuid",
+		SubnetID:   "suuid",
+		ConcID:     "cnciuuid",
+	}
+
+	subnets := []RouterSubnet{
+		{
+			Subnet:    *net1,
+			SubnetKey: 0xF,
+			SubnetID:  "suuid",
+			ConcID:    "cnciuuid",
+			ConcIP:    net.IPv4(192, 168, 111, 1),
+		},
+		{
+			Subnet:    *net2,
+			SubnetKey: 0x10,
+			SubnetID:  "suuid2",
+			ConcID:    "cnciuuid2",
+			ConcIP:    net.IPv4(192, 168, 111, 2),
+		},
+	}
+
+	//No router without instances
+	events, err := cn.SetTenantRouter("tuuid", subnets)
+	require.Nil(t, err)
+	assert.Empty(events)
+	_, err = netlink.LinkByAlias(vrfPrefix + "tuuid")
+	assert.NotNil(err)
+
+	_, _, _, err = cn.CreateVnic(vnicCfg)
+	require.Nil(t, err)
+
+	events, err = cn.SetTenantRouter("tuuid", subnets)
+	require.Nil(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(SsntpTunAdd, events[0].Event)
+	assert.Equal(net2.String(), events[0].Subnet)
+
+	vrf, err := netlink.LinkByAlias(vrfPrefix + "tuuid")
+	require.Nil(t, err)
+	for _, s := range subnets {
+		port, err := newRouterVnic(genCnVnicAliases(routerPortCfg("tuuid", s)).vnic)
+		require.Nil(t, err)
+		require.Nil(t, port.GetDevice())
+
+		peer, err := netlink.LinkByName(port.PeerName())
+		require.Nil(t, err)
+		assert.Equal(vrf.Attrs().Index, peer.Attrs().MasterIndex)
+
+		addrs, err := netlink.AddrList(peer, netlink.FAMILY_V4)
+		require.Nil(t, err)
+		require.Len(t, addrs, 1)
+		assert.Equal(RouterIP(s.Subnet).String(), addrs[0].IP.String())
+
+		//Only the local VNIC is reached directly, the rest of the
+		//subnet is reached through the CNCI
+		filter := &netlink.Route{
+			LinkIndex: peer.Attrs().Index,
+			Table:     int(vrf.(*netlink.Vrf).Table),
+			Type:      syscall.RTN_UNICAST,
+		}
+		routes, err := netlink.RouteListFiltered(netlink.FAMILY_V4, filter,
+			netlink.RT_FILTER_OIF|netlink.RT_FILTER_TABLE|netlink.RT_FILTER_TYPE)
+		require.Nil(t, err)
+		var dsts []string
+		for _, r := range routes {
+			dsts = append(dsts, fmt.Sprintf("%v via %v", r.Dst, r.Gw))
+		}
+		expected := []string{
+			fmt.Sprintf("%v via %v", &s.Subnet, routerGateway(s.Subnet)),
+		}
+		if s.SubnetID == vnicCfg.SubnetID {
+			expected = append(expected, "192.168.1.100/32 via <nil>")
+		}
+		sort.Strings(dsts)
+		assert.Equal(expected, dsts)
+	}
+
+	//Setting the same subnets again does nothing
+	events, err = cn.SetTenantRouter("tuuid", subnets)
+	assert.Nil(err)
+	assert.Empty(events)
+
+	//The router keeps the bridge of the VNIC
+	event, _, err := cn.DestroyVnic(vnicCfg)
+	assert.Nil(err)
+	assert.Nil(event)
+
+	events, err = cn.ReleaseTenantRouter("tuuid")
+	require.Nil(t, err)
+	assert.Len(events, 2)
+	for _, e := range events {
+		assert.Equal(SsntpTunDel, e.Event)
+	}
+
+	_, err = netlink.LinkByAlias(vrfPrefix + "tuuid")
+	assert.NotNil(err)
+}
@@ -35,6 +35,9 @@ const (
 	prefixVnicHost = "svn"
 	prefixCnciVnic = "svc"
 	prefixGretap   = "sgt"
+	prefixVxlan    = "svx"
+	prefixIfb      = "svi"
+	prefixVrf      = "svr"
 )
 
 const ifaceRetryLimit = 10
@@ -76,6 +79,8 @@ func validSnPrefix(s string) bool {
 	case strings.HasPrefix(s, prefixVnicHost):
 	case strings.HasPrefix(s, prefixCnciVnic):
 	case strings.HasPrefix(s, prefixGretap):
+	case strings.HasPrefix(s, prefixVxlan):
+	case strings.HasPrefix(s, prefixVrf):
 	default:
 		return false
 	}
@@ -94,13 +99,17 @@ func getPrefix(device interface{}) (string, error) {
 		switch d.Role {
 		case TenantVM:
 			prefix = prefixVnic
-		case TenantContainer:
+		case TenantContainer, TenantRouter:
 			prefix = prefixVnicHost
 		}
 	case *GreTunEP:
 		prefix = prefixGretap
+	case *VxlanTunEP:
+		prefix = prefixVxlan
 	case *CnciVnic:
 		prefix = prefixCnciVnic
+	case *netlink.Vrf:
+		prefix = prefixVrf
 	}
 
 	if prefix == "" {
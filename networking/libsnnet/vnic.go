@@ -52,7 +52,7 @@ func (v *Vnic) InterfaceName() string {
 	switch v.Role {
 	case TenantVM:
 		return v.LinkName
-	case TenantContainer:
+	case TenantContainer, TenantRouter:
 		return v.PeerName()
 	default:
 		return ""
@@ -63,7 +63,7 @@ func (v *Vnic) InterfaceName() string {
 //Returns "" if the link is not setup or if the link
 //has no peer
 func (v *Vnic) PeerName() string {
-	if v.Role != TenantContainer {
+	if v.Role != TenantContainer && v.Role != TenantRouter {
 		return v.LinkName
 	}
 
@@ -108,7 +108,7 @@ func (v *Vnic) GetDevice() error {
 		}
 		v.LinkName = vl.Name
 		v.Link = vl
-	case TenantContainer:
+	case TenantContainer, TenantRouter:
 		vl, ok := link.(*netlink.Veth)
 		if !ok {
 			return netError(v, "get device incorrect interface type %v %v", v.GlobalID, link.Type())
@@ -148,7 +148,7 @@ func (v *Vnic) GetDeviceByName(linkName string) error {
 		}
 		v.LinkName = vl.Name
 		v.Link = vl
-	case TenantContainer:
+	case TenantContainer, TenantRouter:
 		vl, ok := link.(*netlink.Veth)
 		if !ok {
 			return netError(v, "get device incorrect interface type %v %v", linkName, link.Type())
@@ -237,7 +237,7 @@ func (v *Vnic) Create() error {
 		}
 
 		v.Link = link
-	case TenantContainer:
+	case TenantContainer, TenantRouter:
 		//We create only the host side veth, the container side is setup by the kernel
 		link, err := createContainerVnic(v)
 		if err != nil {
@@ -268,6 +268,10 @@ func (v *Vnic) Destroy() error {
 		return netError(v, "destroy link [%v] del [%v]", v.LinkName, err)
 	}
 
+	if err := v.destroyIfb(); err != nil {
+		return netError(v, "destroy ifb [%v] del [%v]", v.LinkName, err)
+	}
+
 	return nil
 
 }
@@ -359,7 +363,7 @@ func (v *Vnic) SetMTU(mtu int) error {
 	switch v.Role {
 	case TenantVM:
 		/* Set by DHCP. */
-	case TenantContainer:
+	case TenantContainer, TenantRouter:
 		/* Need to set the MTU of both ends */
 		if err := netlink.LinkSetMTU(v.Link, mtu); err != nil {
 			return netError(v, "link set mtu %v", err)
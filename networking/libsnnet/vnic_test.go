@@ -20,6 +20,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
 )
 
 func performVnicOps(shouldPass bool, assert *assert.Assertions, vnic *Vnic) {
@@ -275,3 +276,44 @@ func TestVnicContainer_Bridge(t *testing.T) {
 	assert.Nil(bridge.Enable())
 	assert.Nil(vnic.Detach(bridge))
 }
+
+//Tests the bandwidth limits of a VNIC
+//
+//Tests that the traffic received by the instance is shaped
+//on the VNIC and that the traffic it sends is redirected to
+//an IFB device, which is destroyed along with the VNIC
+//
+//Test is expected to pass
+func TestVnic_Bandwidth(t *testing.T) {
+	assert := assert.New(t)
+
+	vnic, _ := NewVnic("testvnic")
+	vnic.Bandwidth = BandwidthLimits{
+		IngressKbps: 10000,
+		EgressKbps:  1000,
+	}
+
+	assert.Nil(vnic.Create())
+	assert.Nil(vnic.Enable())
+	assert.Nil(vnic.setBandwidth())
+
+	classes, err := netlink.ClassList(vnic.Link, htbRootHandle)
+	assert.Nil(err)
+	assert.Equal(1, len(classes))
+
+	filters, err := netlink.FilterList(vnic.Link, ingressHandle)
+	assert.Nil(err)
+	assert.Equal(1, len(filters))
+
+	ifb, err := netlink.LinkByName(ifbName(vnic.LinkName))
+	if assert.Nil(err) {
+		classes, err = netlink.ClassList(ifb, htbRootHandle)
+		assert.Nil(err)
+		assert.Equal(1, len(classes))
+	}
+
+	assert.Nil(vnic.Destroy())
+
+	_, err = netlink.LinkByName(ifbName(vnic.LinkName))
+	assert.NotNil(err)
+}
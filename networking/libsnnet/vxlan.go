@@ -0,0 +1,288 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+)
+
+// VxlanPort is the IANA assigned UDP port used by VXLAN tunnels
+const VxlanPort = 4789
+
+// vxlanMaxVNI is the largest 24 bit VXLAN network identifier
+const vxlanMaxVNI = 1<<24 - 1
+
+// The MAC address of the FDB entries used to flood broadcast and
+// unknown unicast traffic to all the remote end points of a tunnel
+var vxlanFloodMAC = net.HardwareAddr{0, 0, 0, 0, 0, 0}
+
+// newVxlanTunEP is used to initialize the VXLAN tunnel properties
+// This has to be called prior to create() or getDevice()
+// A nil remoteIP creates a tunnel with no default destination, whose
+// remote end points are added with addRemote()
+func newVxlanTunEP(id string, localIP net.IP, remoteIP net.IP, vni uint32) (*VxlanTunEP, error) {
+	if vni > vxlanMaxVNI {
+		return nil, netError(&VxlanTunEP{}, "invalid vni %v %x", id, vni)
+	}
+
+	vxlan := &VxlanTunEP{}
+	vxlan.Link = &netlink.Vxlan{}
+	vxlan.GlobalID = id
+	vxlan.LocalIP = localIP
+	vxlan.RemoteIP = remoteIP
+	vxlan.VNI = vni
+	vxlan.Port = VxlanPort
+	return vxlan, nil
+}
+
+// getDevice associates the tunnel with an existing VXLAN tunnel end point
+func (v *VxlanTunEP) getDevice() error {
+
+	if v.GlobalID == "" {
+		return netError(v, "get device unnamed vxlan device")
+	}
+
+	link, err := netlink.LinkByAlias(v.GlobalID)
+	if err != nil {
+		return netError(v, "get device interface does not exist: %v %v", v.GlobalID, err)
+	}
+
+	vl, ok := link.(*netlink.Vxlan)
+	if !ok {
+		return netError(v, "get device incorrect interface type %v %v", v.GlobalID, link.Type())
+	}
+	v.Link = vl
+	v.LinkName = vl.Name
+	v.LocalIP = vl.SrcAddr
+	v.RemoteIP = vl.Group
+	v.VNI = uint32(vl.VxlanId)
+	v.Port = vl.Port
+
+	return nil
+}
+
+// create instantiates a tunnel
+func (v *VxlanTunEP) create() error {
+	var err error
+
+	if v.GlobalID == "" || v.VNI == 0 {
+		return netError(v, "create cannot create an unnamed vxlan device")
+	}
+
+	if v.LinkName == "" {
+		if v.LinkName, err = genIface(v, false); err != nil {
+			return netError(v, "create geniface %v, %v", v.GlobalID, err)
+		}
+
+		if lerr, err := netlink.LinkByAlias(v.GlobalID); err == nil {
+			return netError(v, "create interface exists %v, %v", v.GlobalID, lerr)
+		}
+	}
+
+	attrs := netlink.NewLinkAttrs()
+	attrs.Name = v.LinkName
+
+	vxlan := &netlink.Vxlan{LinkAttrs: attrs,
+		VxlanId: int(v.VNI),
+		SrcAddr: v.LocalIP,
+		Group:   v.RemoteIP,
+		Port:    v.Port,
+	}
+
+	if err := netlink.LinkAdd(vxlan); err != nil {
+		return netError(v, "create link add %v %v", v.GlobalID, err)
+	}
+
+	link, err := netlink.LinkByName(v.LinkName)
+	if err != nil {
+		return netError(v, "create link by name %v %v", v.GlobalID, err)
+	}
+
+	vl, ok := link.(*netlink.Vxlan)
+	if !ok {
+		return netError(v, "create incorrect interface type %v, %v", v.GlobalID, link.Type())
+	}
+	v.Link = vl
+
+	if err := v.setAlias(v.GlobalID); err != nil {
+		_ = v.destroy()
+		return netError(v, "create link set alias %v %v", v.GlobalID, err)
+	}
+
+	return nil
+}
+
+// destroy an existing tunnel
+func (v *VxlanTunEP) destroy() error {
+
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "destroy invalid vxlan link: %v", v)
+	}
+
+	if err := netlink.LinkDel(v.Link); err != nil {
+		return netError(v, "destroy link del %v", err)
+	}
+
+	return nil
+}
+
+// enable the tunnel
+func (v *VxlanTunEP) enable() error {
+
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "enable invalid vxlan link: %v", v)
+	}
+
+	if err := netlink.LinkSetUp(v.Link); err != nil {
+		return netError(v, "enable link enable %v", err)
+	}
+
+	return nil
+}
+
+// disable the tunnel
+func (v *VxlanTunEP) disable() error {
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "disable invalid vxlan link: %v", v)
+	}
+
+	if err := netlink.LinkSetDown(v.Link); err != nil {
+		return netError(v, "disable link disable %v", err)
+	}
+	return nil
+}
+
+func (v *VxlanTunEP) setAlias(alias string) error {
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "set alias invalid vxlan link: %v", v)
+	}
+
+	if err := netlink.LinkSetAlias(v.Link, alias); err != nil {
+		return netError(v, "set alias link set alias %v %v", alias, err)
+	}
+
+	return nil
+}
+
+// attach the VXLAN tunnel to a device/bridge/switch
+func (v *VxlanTunEP) attach(dev interface{}) error {
+
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "attach vxlan tunnel unnitialized")
+	}
+
+	br, ok := dev.(*Bridge)
+	if !ok {
+		return netError(v, "attach unknown device %v, %T", dev, dev)
+	}
+
+	if br.Link == nil || br.Link.Index == 0 {
+		return netError(v, "attach bridge unnitialized")
+	}
+
+	err := netlink.LinkSetMaster(v.Link, br.Link)
+	if err != nil {
+		return netError(v, "attach link set master %v", err)
+	}
+
+	return nil
+}
+
+// detach the VXLAN tunnel from the device/bridge it is attached to
+func (v *VxlanTunEP) detach(dev interface{}) error {
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "detach invalid vxlan link: %v", v)
+	}
+
+	br, ok := dev.(*Bridge)
+	if !ok {
+		return netError(v, "detach incorrect device type %v, %T", dev, dev)
+	}
+
+	if br.Link == nil || br.Link.Index == 0 {
+		return netError(v, "detach bridge unnitialized")
+	}
+
+	if err := netlink.LinkSetNoMaster(v.Link); err != nil {
+		return netError(v, "detach link set no master %v", err)
+	}
+
+	return nil
+}
+
+func (v *VxlanTunEP) floodEntry(remoteIP net.IP) *netlink.Neigh {
+	return &netlink.Neigh{
+		LinkIndex:    v.Link.Index,
+		Family:       syscall.AF_BRIDGE,
+		State:        netlink.NUD_PERMANENT,
+		Flags:        netlink.NTF_SELF,
+		IP:           remoteIP,
+		HardwareAddr: vxlanFloodMAC,
+	}
+}
+
+// addRemote adds a remote end point to a tunnel with no default destination
+// Broadcast and unknown unicast traffic is replicated to all the remote end
+// points of the tunnel
+func (v *VxlanTunEP) addRemote(remoteIP net.IP) error {
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "add remote invalid vxlan link: %v", v)
+	}
+
+	if err := netlink.NeighAppend(v.floodEntry(remoteIP)); err != nil {
+		return netError(v, "add remote %v %v %v", v.GlobalID, remoteIP, err)
+	}
+
+	return nil
+}
+
+// delRemote removes a remote end point from a tunnel
+func (v *VxlanTunEP) delRemote(remoteIP net.IP) error {
+	if v.Link == nil || v.Link.Index == 0 {
+		return netError(v, "del remote invalid vxlan link: %v", v)
+	}
+
+	if err := netlink.NeighDel(v.floodEntry(remoteIP)); err != nil {
+		return netError(v, "del remote %v %v %v", v.GlobalID, remoteIP, err)
+	}
+
+	return nil
+}
+
+// remotes returns the remote end points added to a tunnel
+func (v *VxlanTunEP) remotes() ([]net.IP, error) {
+	if v.Link == nil || v.Link.Index == 0 {
+		return nil, netError(v, "remotes invalid vxlan link: %v", v)
+	}
+
+	neighs, err := netlink.NeighList(v.Link.Index, syscall.AF_BRIDGE)
+	if err != nil {
+		return nil, netError(v, "remotes neigh list %v %v", v.GlobalID, err)
+	}
+
+	var remotes []net.IP
+	for _, n := range neighs {
+		if n.IP != nil && n.HardwareAddr.String() == vxlanFloodMAC.String() {
+			remotes = append(remotes, n.IP)
+		}
+	}
+
+	return remotes, nil
+}
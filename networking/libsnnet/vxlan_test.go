@@ -0,0 +1,157 @@
+//
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+
+package libsnnet
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func performVxlanOps(shouldPass bool, assert *assert.Assertions, vxlan *VxlanTunEP) {
+	a := assert.Nil
+	if !shouldPass {
+		a = assert.NotNil
+	}
+	a(vxlan.enable())
+	a(vxlan.disable())
+	a(vxlan.destroy())
+}
+
+//Test all VXLAN tunnel primitives
+//
+//Tests create, enable, disable and destroy of VXLAN tunnels
+//Failure indicates changes in netlink or kernel and in some
+//case pre-existing tunnels on the test node. Ensure that
+//there are no existing conflicting tunnels before running
+//this test
+//
+//Test is expected to pass
+func TestVxlan_Basic(t *testing.T) {
+	assert := assert.New(t)
+	id := "testvxlan"
+	local := net.ParseIP("127.0.0.1")
+	remote := local
+	vni := uint32(0xF)
+
+	vxlan, err := newVxlanTunEP(id, local, remote, vni)
+	assert.Nil(err)
+
+	assert.Nil(vxlan.create())
+	assert.Nil(vxlan.getDevice())
+	assert.Equal(vni, vxlan.VNI)
+	performVxlanOps(true, assert, vxlan)
+	assert.NotNil(vxlan.destroy())
+}
+
+//Test VXLAN remote end points
+//
+//Tests that the remote end points of a VXLAN tunnel with no
+//default destination can be added, listed and removed
+//
+//Test is expected to pass
+func TestVxlan_Remotes(t *testing.T) {
+	assert := assert.New(t)
+	id := "testvxlan"
+	local := net.ParseIP("127.0.0.1")
+	remote1 := net.ParseIP("192.168.0.101")
+	remote2 := net.ParseIP("192.168.0.102")
+	vni := uint32(0xF)
+
+	vxlan, err := newVxlanTunEP(id, local, nil, vni)
+	assert.Nil(err)
+
+	assert.Nil(vxlan.create())
+	defer func() { _ = vxlan.destroy() }()
+
+	assert.Nil(vxlan.addRemote(remote1))
+	assert.Nil(vxlan.addRemote(remote2))
+
+	remotes, err := vxlan.remotes()
+	assert.Nil(err)
+	assert.Equal(2, len(remotes))
+
+	assert.Nil(vxlan.delRemote(remote1))
+	assert.NotNil(vxlan.delRemote(remote1))
+
+	remotes, err = vxlan.remotes()
+	assert.Nil(err)
+	assert.Equal(1, len(remotes))
+}
+
+//Test VXLAN tunnel bridge interactions
+//
+//Test all bridge, vxlan tunnel interactions including
+//attach, detach, enable, disable, destroy
+//
+//Test is expected to pass
+func TestVxlan_Bridge(t *testing.T) {
+	assert := assert.New(t)
+	id := "testvxlan"
+	local := net.ParseIP("127.0.0.1")
+	remote := local
+	vni := uint32(0xF)
+
+	vxlan, err := newVxlanTunEP(id, local, remote, vni)
+	assert.Nil(err)
+	bridge, err := NewBridge("testbridge")
+	assert.Nil(err)
+
+	assert.Nil(vxlan.create())
+	defer func() { _ = vxlan.destroy() }()
+
+	assert.Nil(bridge.Create())
+	defer func() { _ = bridge.Destroy() }()
+
+	assert.Nil(vxlan.attach(bridge))
+	//Duplicate
+	assert.Nil(vxlan.attach(bridge))
+	assert.Nil(vxlan.enable())
+	assert.Nil(bridge.Enable())
+	assert.Nil(vxlan.detach(bridge))
+	//Duplicate
+	assert.Nil(vxlan.detach(bridge))
+}
+
+//Tests failure paths in the VXLAN tunnel
+//
+//Tests that VNIs larger than 24 bits are rejected and that
+//duplicate tunnels cannot be created
+//
+//Test is expected to pass
+func TestVxlan_Negative(t *testing.T) {
+	assert := assert.New(t)
+	id := "testvxlan"
+	local := net.ParseIP("127.0.0.1")
+	remote := local
+	vni := uint32(0xF)
+
+	_, err := newVxlanTunEP(id, local, remote, 1<<24)
+	assert.NotNil(err)
+
+	vxlan, err := newVxlanTunEP(id, local, remote, vni)
+	assert.Nil(err)
+	vxlanDupl, err := newVxlanTunEP(id, local, remote, vni)
+	assert.Nil(err)
+
+	assert.Nil(vxlan.create())
+	assert.NotNil(vxlanDupl.create())
+
+	performVxlanOps(false, assert, vxlanDupl)
+	performVxlanOps(true, assert, vxlan)
+}
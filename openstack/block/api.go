@@ -286,6 +286,52 @@ type ShowVolumeDetails struct {
 	Volume VolumeDetail `json:"volume"`
 }
 
+// RequestedSnapshot contains information about a snapshot to be created.
+// http://developer.openstack.org/api-ref-blockstorage-v2.html#createSnapshot
+type RequestedSnapshot struct {
+	VolumeID    string   `json:"volume_id"`
+	Force       bool     `json:"force"`
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	MetaData    MetaData `json:"metadata,omitempty"`
+}
+
+// SnapshotCreateRequest is the json request for the createSnapshot endpoint.
+// http://developer.openstack.org/api-ref-blockstorage-v2.html#createSnapshot
+type SnapshotCreateRequest struct {
+	Snapshot RequestedSnapshot `json:"snapshot"`
+}
+
+// Snapshot contains information about a snapshot of a volume.
+// http://developer.openstack.org/api-ref-blockstorage-v2.html#showSnapshot
+type Snapshot struct {
+	Status         VolumeStatus `json:"status"`
+	Description    string       `json:"description,omitempty"`
+	CreatedAt      *time.Time   `json:"created_at"`
+	Name           string       `json:"name,omitempty"`
+	VolumeID       string       `json:"volume_id"`
+	Size           int          `json:"size"`
+	ID             string       `json:"id"`
+	MetaData       MetaData     `json:"metadata"`
+	OSSnapTenantID string       `json:"os-extended-snapshot-attributes:project_id,omitempty"`
+	OSSnapProgress string       `json:"os-extended-snapshot-attributes:progress,omitempty"`
+}
+
+// SnapshotResponse is the json response for the createSnapshot and
+// showSnapshot endpoints.
+// http://developer.openstack.org/api-ref-blockstorage-v2.html#createSnapshot
+// http://developer.openstack.org/api-ref-blockstorage-v2.html#showSnapshot
+type SnapshotResponse struct {
+	Snapshot Snapshot `json:"snapshot"`
+}
+
+// ListSnapshots is the json response for the listSnapshots and
+// listSnapshotsDetail endpoints.
+// http://developer.openstack.org/api-ref-blockstorage-v2.html#listSnapshots
+type ListSnapshots struct {
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
 // These errors can be returned by the Service interface
 var (
 	ErrQuota                = errors.New("Tenant over quota")
@@ -297,6 +343,11 @@ var (
 	ErrInstanceOwner        = errors.New("You are not instance owner")
 	ErrInstanceNotAvailable = errors.New("Instance not available")
 	ErrVolumeNotAttached    = errors.New("Volume not attached")
+	ErrVolumeHasSnapshots   = errors.New("Volume has snapshots")
+	ErrSnapshotNotFound     = errors.New("Snapshot not found")
+	ErrSnapshotOwner        = errors.New("You are not snapshot owner")
+	ErrVolumeSize           = errors.New("Volume can only be extended")
+	ErrVolumeType           = errors.New("Invalid volume type")
 )
 
 // errorResponse maps service error responses to http responses.
@@ -312,12 +363,19 @@ func errorResponse(err error) APIResponse {
 		return APIResponse{http.StatusNotFound, nil}
 	case ErrInstanceNotFound:
 		return APIResponse{http.StatusNotFound, nil}
+	case ErrSnapshotNotFound:
+		return APIResponse{http.StatusNotFound, nil}
+	case ErrVolumeSize,
+		ErrVolumeType:
+		return APIResponse{http.StatusBadRequest, nil}
 	case ErrVolumeNotAvailable,
 		ErrVolumeNotAvailable,
 		ErrVolumeOwner,
 		ErrInstanceOwner,
 		ErrInstanceNotAvailable,
-		ErrVolumeNotAttached:
+		ErrVolumeNotAttached,
+		ErrVolumeHasSnapshots,
+		ErrSnapshotOwner:
 		return APIResponse{http.StatusForbidden, nil}
 	default:
 		return APIResponse{http.StatusInternalServerError, nil}
@@ -337,9 +395,14 @@ type Service interface {
 	DeleteVolume(tenant string, volume string) error
 	AttachVolume(tenant string, volume string, instance string, mountpoint string) error
 	DetachVolume(tenant string, volume string, attachment string) error
+	ExtendVolume(tenant string, volume string, size int) error
 	ListVolumes(tenant string) ([]ListVolume, error)
 	ListVolumesDetail(tenant string) ([]VolumeDetail, error)
 	ShowVolumeDetails(tenant string, volume string) (VolumeDetail, error)
+	CreateSnapshot(tenant string, req RequestedSnapshot) (Snapshot, error)
+	DeleteSnapshot(tenant string, snapshot string) error
+	ListSnapshots(tenant string) ([]Snapshot, error)
+	ShowSnapshot(tenant string, snapshot string) (Snapshot, error)
 }
 
 // Context contains data and interfaces that the block api will need.
@@ -452,8 +515,9 @@ func showVolumeDetails(bc *Context, w http.ResponseWriter, r *http.Request) (API
 		return errorResponse(err), err
 	}
 	vol.MetaData = map[string]string{}
-	s := "None"
-	vol.VolumeType = s
+	if vol.VolumeType == "" {
+		vol.VolumeType = "None"
+	}
 
 	resp := ShowVolumeDetails{Volume: vol}
 
@@ -521,6 +585,29 @@ func volumeActionDetach(bc *Context, m map[string]interface{}, tenant string, vo
 	return APIResponse{http.StatusAccepted, nil}, nil
 }
 
+func volumeActionExtend(bc *Context, m map[string]interface{}, tenant string, volume string) (APIResponse, error) {
+	val := m["os-extend"]
+
+	m, ok := val.(map[string]interface{})
+	if !ok {
+		return APIResponse{http.StatusBadRequest, nil}, nil
+	}
+
+	// json numbers are decoded as float64
+	size, ok := m["new_size"].(float64)
+	if !ok {
+		// we have to have the new size
+		return APIResponse{http.StatusBadRequest, nil}, nil
+	}
+
+	err := bc.ExtendVolume(tenant, volume, int(size))
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return APIResponse{http.StatusAccepted, nil}, nil
+}
+
 func volumeAction(bc *Context, w http.ResponseWriter, r *http.Request) (APIResponse, error) {
 	vars := mux.Vars(r)
 	tenant := vars["tenant"]
@@ -542,7 +629,7 @@ func volumeAction(bc *Context, w http.ResponseWriter, r *http.Request) (APIRespo
 
 	m := req.(map[string]interface{})
 
-	// for now, we will support only attach and detach
+	// for now, we will support only attach, detach and extend
 
 	if m["os-attach"] != nil {
 		return volumeActionAttach(bc, m, tenant, volume)
@@ -552,9 +639,110 @@ func volumeAction(bc *Context, w http.ResponseWriter, r *http.Request) (APIRespo
 		return volumeActionDetach(bc, m, tenant, volume)
 	}
 
+	if m["os-extend"] != nil {
+		return volumeActionExtend(bc, m, tenant, volume)
+	}
+
 	return APIResponse{http.StatusBadRequest, nil}, err
 }
 
+func createSnapshot(bc *Context, w http.ResponseWriter, r *http.Request) (APIResponse, error) {
+	vars := mux.Vars(r)
+	tenant := vars["tenant"]
+
+	defer r.Body.Close()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return APIResponse{http.StatusBadRequest, nil}, err
+	}
+
+	var req SnapshotCreateRequest
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		return APIResponse{http.StatusBadRequest, nil}, err
+	}
+
+	if req.Snapshot.VolumeID == "" {
+		return APIResponse{http.StatusBadRequest, nil}, errors.New("Missing volume_id")
+	}
+
+	snap, err := bc.CreateSnapshot(tenant, req.Snapshot)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := SnapshotResponse{Snapshot: snap}
+
+	return APIResponse{http.StatusAccepted, resp}, nil
+}
+
+func listSnapshots(bc *Context, w http.ResponseWriter, r *http.Request) (APIResponse, error) {
+	vars := mux.Vars(r)
+	tenant := vars["tenant"]
+
+	// TBD: support sorting and paging
+
+	snaps, err := bc.ListSnapshots(tenant)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	// the extended attributes are only listed in details
+	for i := range snaps {
+		snaps[i].OSSnapTenantID = ""
+		snaps[i].OSSnapProgress = ""
+	}
+
+	resp := ListSnapshots{Snapshots: snaps}
+
+	return APIResponse{http.StatusOK, resp}, nil
+}
+
+func listSnapshotsDetail(bc *Context, w http.ResponseWriter, r *http.Request) (APIResponse, error) {
+	vars := mux.Vars(r)
+	tenant := vars["tenant"]
+
+	// TBD: support sorting and paging
+
+	snaps, err := bc.ListSnapshots(tenant)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := ListSnapshots{Snapshots: snaps}
+
+	return APIResponse{http.StatusOK, resp}, nil
+}
+
+func showSnapshot(bc *Context, w http.ResponseWriter, r *http.Request) (APIResponse, error) {
+	vars := mux.Vars(r)
+	tenant := vars["tenant"]
+	snapshot := vars["snapshot_id"]
+
+	snap, err := bc.ShowSnapshot(tenant, snapshot)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	resp := SnapshotResponse{Snapshot: snap}
+
+	return APIResponse{http.StatusOK, resp}, nil
+}
+
+func deleteSnapshot(bc *Context, w http.ResponseWriter, r *http.Request) (APIResponse, error) {
+	vars := mux.Vars(r)
+	tenant := vars["tenant"]
+	snapshot := vars["snapshot_id"]
+
+	err := bc.DeleteSnapshot(tenant, snapshot)
+	if err != nil {
+		return errorResponse(err), err
+	}
+
+	return APIResponse{http.StatusAccepted, nil}, nil
+}
+
 // Routes provides gorilla mux routes for the supported endpoints.
 func Routes(config APIConfig, r *mux.Router) *mux.Router {
 	// make new Context
@@ -580,5 +768,17 @@ func Routes(config APIConfig, r *mux.Router) *mux.Router {
 	r.Handle("/v2/{tenant}/volumes/{volume_id}/action",
 		APIHandler{context, volumeAction}).Methods("POST")
 
+	// Snapshots
+	r.Handle("/v2/{tenant}/snapshots",
+		APIHandler{context, createSnapshot}).Methods("POST")
+	r.Handle("/v2/{tenant}/snapshots",
+		APIHandler{context, listSnapshots}).Methods("GET")
+	r.Handle("/v2/{tenant}/snapshots/detail",
+		APIHandler{context, listSnapshotsDetail}).Methods("GET")
+	r.Handle("/v2/{tenant}/snapshots/{snapshot_id}",
+		APIHandler{context, showSnapshot}).Methods("GET")
+	r.Handle("/v2/{tenant}/snapshots/{snapshot_id}",
+		APIHandler{context, deleteSnapshot}).Methods("DELETE")
+
 	return r
 }
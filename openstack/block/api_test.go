@@ -40,6 +40,22 @@ var tests = []test{
 		http.StatusAccepted,
 		`{"volume":{"status":"creating","user_id":"validuserid","attachments":[],"links":[],"bootable":"false","encrypted":false,"created_at":null,"updated_at":null,"replication_status":"disabled","multiattach":false,"metadata":{},"id":"validvolumeid","size":10}}`,
 	},
+	{
+		"POST",
+		"/v2/validtenantid/volumes",
+		createVolume,
+		`{"volume":{"size": 10,"volume_type":"fast-ssd","metadata":{}}}`,
+		http.StatusAccepted,
+		`{"volume":{"status":"creating","user_id":"validuserid","attachments":[],"links":[],"bootable":"false","encrypted":false,"created_at":null,"updated_at":null,"volume_type":"fast-ssd","replication_status":"disabled","multiattach":false,"metadata":{},"id":"validvolumeid","size":10}}`,
+	},
+	{
+		"POST",
+		"/v2/validtenantid/volumes",
+		createVolume,
+		`{"volume":{"size": 10,"volume_type":"unknown","metadata":{}}}`,
+		http.StatusBadRequest,
+		"Invalid volume type\nnull",
+	},
 	{
 		"GET",
 		"/v2/validtenantid/volumes",
@@ -88,6 +104,78 @@ var tests = []test{
 		http.StatusAccepted,
 		"null",
 	},
+	{
+		"POST",
+		"/v2/validtenantid/volumes/validvolumeid/action",
+		volumeAction,
+		`{"os-extend":{"new_size":20}}`,
+		http.StatusAccepted,
+		"null",
+	},
+	{
+		"POST",
+		"/v2/validtenantid/volumes/validvolumeid/action",
+		volumeAction,
+		`{"os-extend":{"new_size":5}}`,
+		http.StatusBadRequest,
+		"Volume can only be extended\nnull",
+	},
+	{
+		"POST",
+		"/v2/validtenantid/volumes/validvolumeid/action",
+		volumeAction,
+		`{"os-extend":{}}`,
+		http.StatusBadRequest,
+		"null",
+	},
+	{
+		"POST",
+		"/v2/validtenantid/snapshots",
+		createSnapshot,
+		`{"snapshot":{"volume_id":"validvolumeid","force":false,"name":"snap-001","description":"Daily backup"}}`,
+		http.StatusAccepted,
+		`{"snapshot":{"status":"available","description":"Daily backup","created_at":null,"name":"snap-001","volume_id":"validvolumeid","size":10,"id":"validsnapshotid","metadata":{}}}`,
+	},
+	{
+		"POST",
+		"/v2/validtenantid/snapshots",
+		createSnapshot,
+		`{"snapshot":{"force":false}}`,
+		http.StatusBadRequest,
+		"Missing volume_id\nnull",
+	},
+	{
+		"GET",
+		"/v2/validtenantid/snapshots",
+		listSnapshots,
+		"",
+		http.StatusOK,
+		`{"snapshots":[{"status":"available","created_at":null,"name":"snap-001","volume_id":"validvolumeid","size":10,"id":"validsnapshotid","metadata":{}}]}`,
+	},
+	{
+		"GET",
+		"/v2/validtenantid/snapshots/detail",
+		listSnapshotsDetail,
+		"",
+		http.StatusOK,
+		`{"snapshots":[{"status":"available","created_at":null,"name":"snap-001","volume_id":"validvolumeid","size":10,"id":"validsnapshotid","metadata":{},"os-extended-snapshot-attributes:project_id":"validtenantid","os-extended-snapshot-attributes:progress":"100%"}]}`,
+	},
+	{
+		"GET",
+		"/v2/validtenantid/snapshots/validsnapshotid",
+		showSnapshot,
+		"",
+		http.StatusOK,
+		`{"snapshot":{"status":"available","created_at":null,"name":"snap-001","volume_id":"validvolumeid","size":10,"id":"validsnapshotid","metadata":{},"os-extended-snapshot-attributes:project_id":"validtenantid","os-extended-snapshot-attributes:progress":"100%"}}`,
+	},
+	{
+		"DELETE",
+		"/v2/validtenantid/snapshots/validsnapshotid",
+		deleteSnapshot,
+		"",
+		http.StatusAccepted,
+		"null",
+	},
 }
 
 type testVolumeService struct{}
@@ -134,6 +222,10 @@ func (vs testVolumeService) ShowVolumeDetails(tenant string, volume string) (Vol
 }
 
 func (vs testVolumeService) CreateVolume(tenant string, req RequestedVolume) (Volume, error) {
+	if req.VolumeType != "" && req.VolumeType != "fast-ssd" {
+		return Volume{}, ErrVolumeType
+	}
+
 	return Volume{
 		Status:            Creating,
 		UserID:            "validuserid",
@@ -165,6 +257,13 @@ func (vs testVolumeService) DetachVolume(tenant string, volume string, attachmen
 	return nil
 }
 
+func (vs testVolumeService) ExtendVolume(tenant string, volume string, size int) error {
+	if size <= 10 {
+		return ErrVolumeSize
+	}
+	return nil
+}
+
 func (vs testVolumeService) ListVolumes(tenant string) ([]ListVolume, error) {
 	return []ListVolume{
 		{"validvolumeid1", make([]Link, 0), "vol-001"},
@@ -221,6 +320,43 @@ func (vs testVolumeService) ListVolumesDetail(tenant string) ([]VolumeDetail, er
 	}, nil
 }
 
+func testSnapshot() Snapshot {
+	return Snapshot{
+		Status:         Available,
+		Name:           "snap-001",
+		VolumeID:       "validvolumeid",
+		Size:           10,
+		ID:             "validsnapshotid",
+		MetaData:       map[string]string{},
+		OSSnapTenantID: "validtenantid",
+		OSSnapProgress: "100%",
+	}
+}
+
+func (vs testVolumeService) CreateSnapshot(tenant string, req RequestedSnapshot) (Snapshot, error) {
+	return Snapshot{
+		Status:      Available,
+		Description: req.Description,
+		Name:        req.Name,
+		VolumeID:    req.VolumeID,
+		Size:        10,
+		ID:          "validsnapshotid",
+		MetaData:    map[string]string{},
+	}, nil
+}
+
+func (vs testVolumeService) DeleteSnapshot(tenant string, snapshot string) error {
+	return nil
+}
+
+func (vs testVolumeService) ListSnapshots(tenant string) ([]Snapshot, error) {
+	return []Snapshot{testSnapshot()}, nil
+}
+
+func (vs testVolumeService) ShowSnapshot(tenant string, snapshot string) (Snapshot, error) {
+	return testSnapshot(), nil
+}
+
 func TestAPIResponse(t *testing.T) {
 	var vs testVolumeService
 
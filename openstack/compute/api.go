@@ -22,7 +22,6 @@ import (
 	"net/http"
 	"net/http/httputil"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/golang/glog"
@@ -32,8 +31,9 @@ import (
 // PrivateAddresses contains information about a single instance network
 // interface.
 type PrivateAddresses struct {
-	Addr    string `json:"addr"`
-	MacAddr string `json:"mac_addr"`
+	Addr     string `json:"addr"`
+	IPv6Addr string `json:"ipv6_addr,omitempty"`
+	MacAddr  string `json:"mac_addr"`
 }
 
 // These errors can be returned by the Service interface
@@ -43,6 +43,8 @@ var (
 	ErrServerNotFound       = errors.New("Server not found")
 	ErrServerOwner          = errors.New("You are not server owner")
 	ErrInstanceNotAvailable = errors.New("Instance not currently available for this operation")
+	ErrFlavorNotFound       = errors.New("Flavor not found")
+	ErrInvalidFlavor        = errors.New("Flavor cannot be used by this server")
 )
 
 // errorResponse maps service error responses to http responses.
@@ -56,6 +58,9 @@ func errorResponse(err error) APIResponse {
 	case ErrQuota, ErrServerOwner, ErrInstanceNotAvailable:
 		return APIResponse{http.StatusForbidden, nil}
 
+	case ErrFlavorNotFound, ErrInvalidFlavor:
+		return APIResponse{http.StatusBadRequest, nil}
+
 	default:
 		return APIResponse{http.StatusInternalServerError, nil}
 	}
@@ -159,7 +164,53 @@ type CreateServerRequest struct {
 		MinInstances        int                    `json:"min_count"`
 		BlockDeviceMappings []BlockDeviceMappingV2 `json:"block_device_mapping_v2,omitempty"`
 		Metadata            map[string]string      `json:"metadata,omitempty"`
+		SecurityGroups      []SecurityGroup        `json:"security_groups,omitempty"`
+		Networks            []Network              `json:"networks,omitempty"`
 	} `json:"server"`
+	SchedulerHints *SchedulerHints `json:"os:scheduler_hints,omitempty"`
+}
+
+// SecurityGroup identifies, by name or UUID, a security group of which the
+// instances started by a /v2.1/{tenant}/servers request are to be members.
+type SecurityGroup struct {
+	Name string `json:"name"`
+}
+
+// Network identifies, by UUID or name, a tenant network to which the
+// instances started by a /v2.1/{tenant}/servers request are to be attached.
+// The instances get one VNIC per network, in the order of the request.
+type Network struct {
+	UUID string `json:"uuid"`
+}
+
+// ResizeServerRequest represents the unmarshalled version of the contents
+// of a resize /v2.1/{tenant}/servers/{server}/action request.  The flavor
+// is the workload whose VCPU and memory defaults the instance is to use.
+type ResizeServerRequest struct {
+	Resize struct {
+		FlavorRef string `json:"flavorRef"`
+	} `json:"resize"`
+}
+
+// CreateImageRequest represents the unmarshalled version of the contents
+// of a createImage /v2.1/{tenant}/servers/{server}/action request.  The
+// image is created from the boot volume of the server.
+type CreateImageRequest struct {
+	CreateImage struct {
+		Name string `json:"name"`
+	} `json:"createImage"`
+}
+
+// CreateImageResponse represents the marshalled version of the response
+// to a createImage /v2.1/{tenant}/servers/{server}/action request.
+type CreateImageResponse struct {
+	ImageID string `json:"image_id"`
+}
+
+// SchedulerHints represents the optional placement hints of a
+// /v2.1/{tenant}/servers request.
+type SchedulerHints struct {
+	Group string `json:"group,omitempty"`
 }
 
 // APIConfig contains information needed to start the compute api service.
@@ -176,6 +227,8 @@ type Service interface {
 	DeleteServer(tenant string, server string) error
 	StartServer(tenant string, server string) error
 	StopServer(tenant string, server string) error
+	ResizeServer(tenant string, server string, flavor string) error
+	CreateServerImage(tenant string, server string, name string) (string, error)
 }
 
 type pagerFilterType uint8
@@ -296,6 +349,8 @@ type action uint8
 const (
 	computeActionStart action = iota
 	computeActionStop
+	computeActionResize
+	computeActionCreateImage
 )
 
 func dumpRequestBody(r *http.Request, body bool) {
@@ -506,15 +561,31 @@ func serverAction(c *Context, w http.ResponseWriter, r *http.Request) (APIRespon
 		return APIResponse{http.StatusBadRequest, nil}, err
 	}
 
-	bodyString := string(body)
+	// The action is the only key of the request body
+	var actions map[string]json.RawMessage
+
+	err = json.Unmarshal(body, &actions)
+	if err != nil || len(actions) != 1 {
+		return APIResponse{http.StatusBadRequest, nil},
+			errors.New("Invalid Action")
+	}
+
+	var name string
+	for name = range actions {
+	}
 
 	var action action
 
-	if strings.Contains(bodyString, "os-start") {
+	switch name {
+	case "os-start":
 		action = computeActionStart
-	} else if strings.Contains(bodyString, "os-stop") {
+	case "os-stop":
 		action = computeActionStop
-	} else {
+	case "createImage":
+		action = computeActionCreateImage
+	case "resize":
+		action = computeActionResize
+	default:
 		return APIResponse{http.StatusServiceUnavailable, nil},
 			errors.New("Unsupported Action")
 	}
@@ -524,6 +595,28 @@ func serverAction(c *Context, w http.ResponseWriter, r *http.Request) (APIRespon
 		err = c.StartServer(tenant, server)
 	case computeActionStop:
 		err = c.StopServer(tenant, server)
+	case computeActionResize:
+		var req ResizeServerRequest
+
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			return APIResponse{http.StatusBadRequest, nil}, err
+		}
+
+		err = c.ResizeServer(tenant, server, req.Resize.FlavorRef)
+	case computeActionCreateImage:
+		var req CreateImageRequest
+
+		err = json.Unmarshal(body, &req)
+		if err != nil {
+			return APIResponse{http.StatusBadRequest, nil}, err
+		}
+
+		var imageID string
+		imageID, err = c.CreateServerImage(tenant, server, req.CreateImage.Name)
+		if err == nil {
+			return APIResponse{http.StatusAccepted, CreateImageResponse{ImageID: imageID}}, nil
+		}
 	}
 
 	if err != nil {
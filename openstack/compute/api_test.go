@@ -86,6 +86,46 @@ var tests = []test{
 		http.StatusAccepted,
 		"null",
 	},
+	{
+		"POST",
+		"/v2.1/{tenant}/servers/{server}/action",
+		serverAction,
+		`{"resize":{"flavorRef":"validFlavorID"}}`,
+		http.StatusAccepted,
+		"null",
+	},
+	{
+		"POST",
+		"/v2.1/{tenant}/servers/{server}/action",
+		serverAction,
+		`{"resize":{"flavorRef":"unknownFlavorID"}}`,
+		http.StatusBadRequest,
+		"{\"error\":{\"code\":400,\"name\":\"Bad Request\",\"message\":\"Flavor not found\"}}\n",
+	},
+	{
+		"POST",
+		"/v2.1/{tenant}/servers/{server}/action",
+		serverAction,
+		`{"resize":{"flavorRef":"os-stop"}}`,
+		http.StatusBadRequest,
+		"{\"error\":{\"code\":400,\"name\":\"Bad Request\",\"message\":\"Flavor not found\"}}\n",
+	},
+	{
+		"POST",
+		"/v2.1/{tenant}/servers/{server}/action",
+		serverAction,
+		`{"createImage":{"name":"snapshot"}}`,
+		http.StatusAccepted,
+		`{"image_id":"validImageID"}`,
+	},
+	{
+		"POST",
+		"/v2.1/{tenant}/servers/{server}/action",
+		serverAction,
+		`{"createImage":{"name":"running"}}`,
+		http.StatusForbidden,
+		"{\"error\":{\"code\":403,\"name\":\"Forbidden\",\"message\":\"Instance not currently available for this operation\"}}\n",
+	},
 }
 
 type testComputeService struct{}
@@ -148,6 +188,22 @@ func (cs testComputeService) StopServer(tenant string, server string) error {
 	return nil
 }
 
+func (cs testComputeService) ResizeServer(tenant string, server string, flavor string) error {
+	if flavor != "validFlavorID" {
+		return ErrFlavorNotFound
+	}
+
+	return nil
+}
+
+func (cs testComputeService) CreateServerImage(tenant string, server string, name string) (string, error) {
+	if name == "running" {
+		return "", ErrInstanceNotAvailable
+	}
+
+	return "validImageID", nil
+}
+
 func TestAPIResponse(t *testing.T) {
 	var cs testComputeService
 
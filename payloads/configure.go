@@ -34,6 +34,63 @@ func (s StorageType) String() string {
 	return ""
 }
 
+// TunnelType is used to define the type of the tunnels carrying tenant
+// traffic between compute nodes and CNCIs.
+type TunnelType string
+
+const (
+	// GRETunnel defines point to point GRE tunnels between each compute
+	// node and CNCI pair.
+	GRETunnel TunnelType = "gre"
+
+	// VXLANTunnel defines VXLAN tunnels using the subnet key allocated
+	// by the controller to the tenant subnet as the VXLAN network
+	// identifier.
+	VXLANTunnel TunnelType = "vxlan"
+)
+
+func (t TunnelType) String() string {
+	switch t {
+	case GRETunnel:
+		return "gre"
+	case VXLANTunnel:
+		return "vxlan"
+	}
+
+	return ""
+}
+
+// BlockDriverType is used to define the driver managing the volumes of
+// the cluster.
+type BlockDriverType string
+
+const (
+	// CephBlockDriver defines volumes stored as Ceph RBD images.  It is
+	// the driver used when none is configured.
+	CephBlockDriver BlockDriverType = "ceph"
+
+	// LVMBlockDriver defines volumes stored as thin logical volumes of an
+	// LVM thin pool.
+	LVMBlockDriver BlockDriverType = "lvm"
+
+	// FileBlockDriver defines volumes stored as sparse qcow2 files in a
+	// local or NFS directory.
+	FileBlockDriver BlockDriverType = "file"
+)
+
+func (b BlockDriverType) String() string {
+	switch b {
+	case CephBlockDriver:
+		return "ceph"
+	case LVMBlockDriver:
+		return "lvm"
+	case FileBlockDriver:
+		return "file"
+	}
+
+	return ""
+}
+
 // ConfigureScheduler contains the unmarshalled configurations for the
 // scheduler service.
 type ConfigureScheduler struct {
@@ -52,21 +109,44 @@ type ConfigureController struct {
 	AdminSSHKey          string `yaml:"admin_ssh_key"`
 	AdminPassword        string `yaml:"admin_password"`
 	ClientAuthCACertPath string `yaml:"client_auth_ca_cert_path"`
+	DistributedRouting   bool   `yaml:"distributed_routing"`
 }
 
 // ConfigureLauncher contains the unmarshalled configurations for the
 // launcher service.
 type ConfigureLauncher struct {
-	ComputeNetwork    []string `yaml:"compute_net"`
-	ManagementNetwork []string `yaml:"mgmt_net"`
-	DiskLimit         bool     `yaml:"disk_limit"`
-	MemoryLimit       bool     `yaml:"mem_limit"`
+	ComputeNetwork    []string   `yaml:"compute_net"`
+	ManagementNetwork []string   `yaml:"mgmt_net"`
+	DiskLimit         bool       `yaml:"disk_limit"`
+	MemoryLimit       bool       `yaml:"mem_limit"`
+	TunnelType        TunnelType `yaml:"tunnel_type"`
+}
+
+// ConfigureVolumeType contains the unmarshalled configuration of a named
+// volume type, i.e., the block driver storing the volumes of that type.
+// The Ceph ID of the storage section is used when CephID is not set and
+// the rbd pool when CephPool is not set.
+type ConfigureVolumeType struct {
+	Name           string          `yaml:"name"`
+	BlockDriver    BlockDriverType `yaml:"block_driver,omitempty"`
+	CephID         string          `yaml:"ceph_id,omitempty"`
+	CephPool       string          `yaml:"ceph_pool,omitempty"`
+	LVMVolumeGroup string          `yaml:"lvm_volume_group,omitempty"`
+	LVMThinPool    string          `yaml:"lvm_thin_pool,omitempty"`
+	VolumePath     string          `yaml:"volume_path,omitempty"`
 }
 
 // ConfigureStorage contains the unmarshalled configurations for the
-// Ceph storage driver.
+// storage drivers.  The block driver of the storage section stores the
+// volumes of the default volume type, VolumeTypes the volumes of the
+// named volume types.
 type ConfigureStorage struct {
-	CephID string `yaml:"ceph_id"`
+	CephID         string                `yaml:"ceph_id"`
+	BlockDriver    BlockDriverType       `yaml:"block_driver,omitempty"`
+	LVMVolumeGroup string                `yaml:"lvm_volume_group,omitempty"`
+	LVMThinPool    string                `yaml:"lvm_thin_pool,omitempty"`
+	VolumePath     string                `yaml:"volume_path,omitempty"`
+	VolumeTypes    []ConfigureVolumeType `yaml:"volume_types,omitempty"`
 }
 
 // ConfigurePayload is a wrapper to read and unmarshall all posible
@@ -89,6 +169,7 @@ func (conf *Configure) InitDefaults() {
 	conf.Configure.Controller.CiaoPort = 8889
 	conf.Configure.Launcher.DiskLimit = true
 	conf.Configure.Launcher.MemoryLimit = true
+	conf.Configure.Launcher.TunnelType = GRETunnel
 	conf.Configure.Controller.CNCIDisk = 2048
 	conf.Configure.Controller.CNCIMem = 2048
 	conf.Configure.Controller.CNCIVcpus = 4
@@ -45,6 +45,17 @@ func TestConfigureUnmarshal(t *testing.T) {
 	if cfg.Configure.Storage.CephID != testutil.ManagementID {
 		t.Errorf("Wrong launcher ceph id %v", cfg.Configure.Storage.CephID)
 	}
+
+	if len(cfg.Configure.Storage.VolumeTypes) != 1 ||
+		cfg.Configure.Storage.VolumeTypes[0].Name != testutil.VolumeType ||
+		cfg.Configure.Storage.VolumeTypes[0].BlockDriver != CephBlockDriver ||
+		cfg.Configure.Storage.VolumeTypes[0].CephPool != testutil.CephPool {
+		t.Errorf("Wrong volume types %v", cfg.Configure.Storage.VolumeTypes)
+	}
+
+	if cfg.Configure.Launcher.TunnelType != VXLANTunnel {
+		t.Errorf("Wrong launcher tunnel type %v", cfg.Configure.Launcher.TunnelType)
+	}
 }
 
 func TestConfigureMarshal(t *testing.T) {
@@ -54,6 +65,7 @@ func TestConfigureMarshal(t *testing.T) {
 	cfg.Configure.Launcher.ManagementNetwork = []string{testutil.MgmtNet}
 	cfg.Configure.Launcher.DiskLimit = false
 	cfg.Configure.Launcher.MemoryLimit = false
+	cfg.Configure.Launcher.TunnelType = VXLANTunnel
 
 	p, _ := strconv.Atoi(testutil.CiaoPort)
 	cfg.Configure.Controller.CiaoPort = p
@@ -61,6 +73,13 @@ func TestConfigureMarshal(t *testing.T) {
 	cfg.Configure.Controller.HTTPSKey = testutil.HTTPSKey
 
 	cfg.Configure.Storage.CephID = testutil.ManagementID
+	cfg.Configure.Storage.VolumeTypes = []ConfigureVolumeType{
+		{
+			Name:        testutil.VolumeType,
+			BlockDriver: CephBlockDriver,
+			CephPool:    testutil.CephPool,
+		},
+	}
 
 	cfg.Configure.Scheduler.ConfigStorageURI = testutil.StorageURI
 
@@ -89,3 +108,38 @@ func TestConfigureStorageTypeString(t *testing.T) {
 		}
 	}
 }
+
+func TestConfigureTunnelTypeString(t *testing.T) {
+	var stringTests = []struct {
+		t        TunnelType
+		expected string
+	}{
+		{GRETunnel, "gre"},
+		{VXLANTunnel, "vxlan"},
+		{TunnelType("ipip"), ""},
+	}
+	for _, test := range stringTests {
+		out := test.t.String()
+		if out != test.expected {
+			t.Errorf("expected \"%s\", got \"%s\"", test.expected, out)
+		}
+	}
+}
+
+func TestConfigureBlockDriverTypeString(t *testing.T) {
+	var stringTests = []struct {
+		b        BlockDriverType
+		expected string
+	}{
+		{CephBlockDriver, "ceph"},
+		{LVMBlockDriver, "lvm"},
+		{FileBlockDriver, "file"},
+		{BlockDriverType("nfs"), ""},
+	}
+	for _, test := range stringTests {
+		out := test.b.String()
+		if out != test.expected {
+			t.Errorf("expected \"%s\", got \"%s\"", test.expected, out)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// UpdateGeneration orders the updates replacing some state of a tenant on
+// its CNCIs or compute nodes.  The controller gives each update a higher
+// generation than the previous one and an update older than the last one
+// received is ignored.
+type UpdateGeneration struct {
+	Generation int64 `yaml:"generation,omitempty"`
+}
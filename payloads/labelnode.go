@@ -0,0 +1,35 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// LabelNodeCmd contains the labels to be set on a node.
+type LabelNodeCmd struct {
+	// WorkloadAgentUUID identifies the node to be labelled.  This
+	// information is needed by the scheduler to route the command to
+	// the correct CN.
+	WorkloadAgentUUID string `yaml:"workload_agent_uuid"`
+
+	// Labels are the key/value pairs to be attached to the node, e.g.,
+	// ssd: "true".  They replace any labels previously set on the node.
+	Labels map[string]string `yaml:"labels"`
+}
+
+// LabelNode represents the unmarshalled version of the contents of a SSNTP
+// LabelNode payload.
+type LabelNode struct {
+	LabelNode LabelNodeCmd `yaml:"label_node"`
+}
@@ -0,0 +1,57 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLabelNodeMarshal(t *testing.T) {
+	var cmd LabelNode
+	cmd.LabelNode.WorkloadAgentUUID = testutil.AgentUUID
+	cmd.LabelNode.Labels = testutil.NodeLabels
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.LabelNodeYaml {
+		t.Errorf("LabelNode marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.LabelNodeYaml)
+	}
+}
+
+func TestLabelNodeUnmarshal(t *testing.T) {
+	var cmd LabelNode
+	err := yaml.Unmarshal([]byte(testutil.LabelNodeYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.LabelNode.WorkloadAgentUUID != testutil.AgentUUID {
+		t.Errorf("Wrong Agent UUID field [%s]", cmd.LabelNode.WorkloadAgentUUID)
+	}
+
+	if !reflect.DeepEqual(cmd.LabelNode.Labels, testutil.NodeLabels) {
+		t.Errorf("Wrong Labels field %v", cmd.LabelNode.Labels)
+	}
+}
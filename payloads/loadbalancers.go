@@ -0,0 +1,56 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// LoadBalancer describes a load balancer run by a CNCI.  Connections to
+// the public IP and port of the load balancer are proxied to the member
+// port of one of its healthy members.
+type LoadBalancer struct {
+	LoadBalancerUUID string `yaml:"load_balancer_uuid"`
+	PublicIP         string `yaml:"public_ip"`
+
+	// Protocol is the protocol of the connections proxied, only tcp is
+	// supported.
+	Protocol   string   `yaml:"protocol"`
+	Port       int      `yaml:"port"`
+	MemberPort int      `yaml:"member_port"`
+	MemberIPs  []string `yaml:"member_ips"`
+
+	// HealthInterval is the number of seconds between two health checks
+	// of the members and HealthTimeout the number of seconds after which
+	// a member that does not accept a connection is considered unhealthy.
+	// The CNCI picks its own defaults when they are 0.
+	HealthInterval int `yaml:"health_interval,omitempty"`
+	HealthTimeout  int `yaml:"health_timeout,omitempty"`
+}
+
+// LoadBalancersCmd contains all the load balancers of a tenant that are
+// run by a CNCI.
+type LoadBalancersCmd struct {
+	ConcentratorUUID string         `yaml:"concentrator_uuid"`
+	TenantUUID       string         `yaml:"tenant_uuid"`
+	LoadBalancers    []LoadBalancer `yaml:"load_balancers"`
+
+	UpdateGeneration `yaml:",inline"`
+}
+
+// CommandLoadBalancers represents the unmarshalled version of the contents
+// of a SSNTP LoadBalancers payload.  It replaces any load balancers
+// previously sent to the CNCI.
+type CommandLoadBalancers struct {
+	LoadBalancers LoadBalancersCmd `yaml:"load_balancers"`
+}
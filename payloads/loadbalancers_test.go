@@ -0,0 +1,77 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+var testLoadBalancer = LoadBalancer{
+	LoadBalancerUUID: testutil.LoadBalancerUUID,
+	PublicIP:         testutil.InstancePublicIP,
+	Protocol:         "tcp",
+	Port:             80,
+	MemberPort:       8080,
+	MemberIPs:        []string{testutil.InstancePrivateIP},
+	HealthInterval:   5,
+	HealthTimeout:    2,
+}
+
+func TestLoadBalancersMarshal(t *testing.T) {
+	var cmd CommandLoadBalancers
+	cmd.LoadBalancers.ConcentratorUUID = testutil.CNCIUUID
+	cmd.LoadBalancers.TenantUUID = testutil.TenantUUID
+	cmd.LoadBalancers.LoadBalancers = []LoadBalancer{testLoadBalancer}
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.LoadBalancersYaml {
+		t.Errorf("LoadBalancers marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.LoadBalancersYaml)
+	}
+}
+
+func TestLoadBalancersUnmarshal(t *testing.T) {
+	var cmd CommandLoadBalancers
+	err := yaml.Unmarshal([]byte(testutil.LoadBalancersYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.LoadBalancers.ConcentratorUUID != testutil.CNCIUUID {
+		t.Errorf("Wrong concentrator UUID field [%s]", cmd.LoadBalancers.ConcentratorUUID)
+	}
+
+	if cmd.LoadBalancers.TenantUUID != testutil.TenantUUID {
+		t.Errorf("Wrong tenant UUID field [%s]", cmd.LoadBalancers.TenantUUID)
+	}
+
+	if len(cmd.LoadBalancers.LoadBalancers) != 1 {
+		t.Fatalf("Expected 1 load balancer, got %d", len(cmd.LoadBalancers.LoadBalancers))
+	}
+
+	if !reflect.DeepEqual(cmd.LoadBalancers.LoadBalancers[0], testLoadBalancer) {
+		t.Errorf("Wrong load balancer field %v", cmd.LoadBalancers.LoadBalancers[0])
+	}
+}
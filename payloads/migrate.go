@@ -0,0 +1,44 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// MigrateCmd contains all the information needed by a launcher to live
+// migrate one of its instances to another node.
+type MigrateCmd struct {
+	// InstanceUUID is the UUID of the instance to migrate.
+	InstanceUUID string `yaml:"instance_uuid"`
+
+	// WorkloadAgentUUID identifies the node on which the instance is
+	// currently running.  This information is needed by the scheduler to
+	// route the command to the correct CN.
+	WorkloadAgentUUID string `yaml:"workload_agent_uuid"`
+
+	// DestinationAgentUUID identifies the node to which the instance
+	// is being migrated.
+	DestinationAgentUUID string `yaml:"destination_agent_uuid"`
+
+	// DestinationURI is the URI on which the destination instance is
+	// waiting for the incoming migration, e.g., tcp:192.168.0.2:49152.
+	DestinationURI string `yaml:"destination_uri"`
+}
+
+// Migrate represents the unmarshalled version of the contents of a SSNTP
+// MIGRATE payload.  The structure contains enough information for the
+// source node to start migrating an instance to the destination node.
+type Migrate struct {
+	Migrate MigrateCmd `yaml:"migrate"`
+}
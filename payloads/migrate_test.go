@@ -0,0 +1,66 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMigrateMarshal(t *testing.T) {
+	var cmd Migrate
+	cmd.Migrate.InstanceUUID = testutil.InstanceUUID
+	cmd.Migrate.WorkloadAgentUUID = testutil.AgentUUID
+	cmd.Migrate.DestinationAgentUUID = testutil.DestAgentUUID
+	cmd.Migrate.DestinationURI = testutil.MigrationURI
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.LiveMigrateYaml {
+		t.Errorf("MIGRATE marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.LiveMigrateYaml)
+	}
+}
+
+func TestMigrateUnmarshal(t *testing.T) {
+	var cmd Migrate
+	err := yaml.Unmarshal([]byte(testutil.LiveMigrateYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.Migrate.InstanceUUID != testutil.InstanceUUID {
+		t.Errorf("Wrong Instance UUID field [%s]", cmd.Migrate.InstanceUUID)
+	}
+
+	if cmd.Migrate.WorkloadAgentUUID != testutil.AgentUUID {
+		t.Errorf("Wrong Agent UUID field [%s]", cmd.Migrate.WorkloadAgentUUID)
+	}
+
+	if cmd.Migrate.DestinationAgentUUID != testutil.DestAgentUUID {
+		t.Errorf("Wrong Destination Agent UUID field [%s]", cmd.Migrate.DestinationAgentUUID)
+	}
+
+	if cmd.Migrate.DestinationURI != testutil.MigrationURI {
+		t.Errorf("Wrong Destination URI field [%s]", cmd.Migrate.DestinationURI)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// MigrateFailureReason denotes the underlying error that prevented
+// an SSNTP MIGRATE command from live migrating an instance.
+type MigrateFailureReason string
+
+const (
+	// MigrateNoInstance indicates that an instance could not be migrated
+	// as it does not exist on the node to which the MIGRATE command was
+	// sent.
+	MigrateNoInstance MigrateFailureReason = "no_instance"
+
+	// MigrateInvalidPayload indicates that the payload of the SSNTP
+	// MIGRATE command was corrupt and could not be unmarshalled.
+	MigrateInvalidPayload = "invalid_payload"
+
+	// MigrateInvalidData is returned by ciao-launcher if the contents
+	// of the MIGRATE payload are incorrect, e.g., the destination_uri
+	// is missing.
+	MigrateInvalidData = "invalid_data"
+
+	// MigrateNotSupported indicates that the instance cannot be live
+	// migrated, e.g., it is a container.
+	MigrateNotSupported = "not_supported"
+
+	// MigrateNotRunning indicates that the instance is not running and
+	// so cannot be live migrated.
+	MigrateNotRunning = "not_running"
+
+	// MigrateTransferFailure indicates that the migration was started
+	// but failed before it could complete.
+	MigrateTransferFailure = "transfer_failure"
+)
+
+// ErrorMigrateFailure represents the unmarshalled version of the contents of a
+// SSNTP ERROR frame whose type is set to ssntp.MigrateFailure.
+type ErrorMigrateFailure struct {
+	// NodeUUID is the UUID of the node that generated this error.
+	NodeUUID string `yaml:"node_uuid"`
+
+	// InstanceUUID is the UUID of the instance that could not be migrated.
+	InstanceUUID string `yaml:"instance_uuid"`
+
+	// DestinationAgentUUID is the UUID of the node to which the instance
+	// was being migrated.
+	DestinationAgentUUID string `yaml:"destination_agent_uuid"`
+
+	// Reason provides the reason for the migrate failure, e.g.,
+	// MigrateNoInstance.
+	Reason MigrateFailureReason `yaml:"reason"`
+}
+
+func (r MigrateFailureReason) String() string {
+	switch r {
+	case MigrateNoInstance:
+		return "Instance does not exist"
+	case MigrateInvalidPayload:
+		return "YAML payload is corrupt"
+	case MigrateInvalidData:
+		return "Command section of YAML payload is corrupt or missing required information"
+	case MigrateNotSupported:
+		return "Not Supported"
+	case MigrateNotRunning:
+		return "Instance is not running"
+	case MigrateTransferFailure:
+		return "Failed to transfer instance state"
+	}
+
+	return ""
+}
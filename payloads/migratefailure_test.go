@@ -0,0 +1,91 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestMigrateFailureUnmarshal(t *testing.T) {
+	var error ErrorMigrateFailure
+	err := yaml.Unmarshal([]byte(testutil.MigrateFailureYaml), &error)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if error.NodeUUID != testutil.AgentUUID {
+		t.Error("Wrong Node UUID field")
+	}
+
+	if error.InstanceUUID != testutil.InstanceUUID {
+		t.Error("Wrong Instance UUID field")
+	}
+
+	if error.DestinationAgentUUID != testutil.DestAgentUUID {
+		t.Error("Wrong Destination Agent UUID field")
+	}
+
+	if error.Reason != MigrateTransferFailure {
+		t.Error("Wrong Error field")
+	}
+}
+
+func TestMigrateFailureMarshal(t *testing.T) {
+	error := ErrorMigrateFailure{
+		NodeUUID:             testutil.AgentUUID,
+		InstanceUUID:         testutil.InstanceUUID,
+		DestinationAgentUUID: testutil.DestAgentUUID,
+		Reason:               MigrateTransferFailure,
+	}
+
+	y, err := yaml.Marshal(&error)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.MigrateFailureYaml {
+		t.Errorf("MigrateFailure marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.MigrateFailureYaml)
+	}
+}
+
+func TestMigrateFailureString(t *testing.T) {
+	var stringTests = []struct {
+		r        MigrateFailureReason
+		expected string
+	}{
+		{MigrateNoInstance, "Instance does not exist"},
+		{MigrateInvalidPayload, "YAML payload is corrupt"},
+		{MigrateInvalidData, "Command section of YAML payload is corrupt or missing required information"},
+		{MigrateNotSupported, "Not Supported"},
+		{MigrateNotRunning, "Instance is not running"},
+		{MigrateTransferFailure, "Failed to transfer instance state"},
+	}
+	error := ErrorMigrateFailure{
+		InstanceUUID: testutil.InstanceUUID,
+	}
+	for _, test := range stringTests {
+		error.Reason = test.r
+		s := error.Reason.String()
+		if s != test.expected {
+			t.Errorf("expected \"%s\", got \"%s\"", test.expected, s)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// NetworkInspectCmd asks a node to inspect its network topology.
+type NetworkInspectCmd struct {
+	// NodeUUID is the SSNTP UUID of the agent running on the compute
+	// node, network node or CNCI to inspect.  This information is needed
+	// by the scheduler to route the command to the correct node.
+	NodeUUID string `yaml:"node_uuid"`
+
+	// Repair asks the node to fix the problems it finds before reporting.
+	Repair bool `yaml:"repair"`
+}
+
+// NetworkInspect represents the unmarshalled version of the contents of a
+// SSNTP NetworkInspect payload.
+type NetworkInspect struct {
+	Inspect NetworkInspectCmd `yaml:"network_inspect"`
+}
+
+// NetworkLink describes a network device created by ciao on a node.
+type NetworkLink struct {
+	// Alias identifies the device, e.g., its tenant, subnet and CNCI.
+	Alias string `yaml:"alias"`
+
+	// Name is the name of the device on the node.
+	Name string `yaml:"name"`
+
+	// Master is the alias of the bridge the device is attached to.
+	Master string `yaml:"master,omitempty"`
+
+	// Up is set for the devices that are up.  It is only meaningful for
+	// the actual topology of the node.
+	Up bool `yaml:"up,omitempty"`
+}
+
+// DnsmasqProcess describes the dnsmasq serving the subnet of a CNCI bridge.
+type DnsmasqProcess struct {
+	// Bridge is the alias of the bridge of the subnet.
+	Bridge string `yaml:"bridge"`
+
+	// PID is the process ID of the dnsmasq, 0 if it is not running.
+	PID int `yaml:"pid"`
+
+	// Running is set when the dnsmasq is running.
+	Running bool `yaml:"running"`
+}
+
+// NetworkProblem is a difference between the expected and the actual network
+// topology of a node.
+type NetworkProblem struct {
+	// Alias identifies the device or the bridge with the problem.
+	Alias string `yaml:"alias"`
+
+	// Problem describes the problem.
+	Problem string `yaml:"problem"`
+
+	// Repaired is set when the problem was fixed.
+	Repaired bool `yaml:"repaired"`
+}
+
+// NetworkReportEvent contains the result of the inspection of the network
+// topology of a node.
+type NetworkReportEvent struct {
+	// NodeUUID is the SSNTP UUID of the agent running on the node.
+	NodeUUID string `yaml:"node_uuid"`
+
+	// Repair is set when the node was asked to fix the problems it found.
+	Repair bool `yaml:"repair"`
+
+	// Expected is the topology recorded in the network database of the
+	// node.
+	Expected []NetworkLink `yaml:"expected"`
+
+	// Actual is the topology found on the node, before any repair.
+	Actual []NetworkLink `yaml:"actual"`
+
+	// Dnsmasq lists the dnsmasq processes of a CNCI.
+	Dnsmasq []DnsmasqProcess `yaml:"dnsmasq,omitempty"`
+
+	// Problems lists the problems found.
+	Problems []NetworkProblem `yaml:"problems"`
+
+	// Error is set when the inspection could not complete.
+	Error string `yaml:"error,omitempty"`
+}
+
+// NetworkReport represents the unmarshalled version of the contents of an
+// SSNTP ssntp.NetworkReport event payload.  This event is sent by a node in
+// reply to a NetworkInspect command.
+type NetworkReport struct {
+	Report NetworkReportEvent `yaml:"network_report"`
+}
@@ -0,0 +1,115 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	testReportBridge = "br_192.168.0.0+24"
+	testReportGre    = "gre_192.168.0.0+24##" + testutil.AgentIP
+)
+
+var testNetworkReport = NetworkReportEvent{
+	NodeUUID: testutil.CNCIUUID,
+	Repair:   true,
+	Expected: []NetworkLink{
+		{Alias: testReportBridge, Name: "sbr_8a3c1f2e"},
+		{Alias: testReportGre, Name: "sgre_52b7d6a1", Master: testReportBridge},
+	},
+	Actual: []NetworkLink{
+		{Alias: testReportBridge, Name: "sbr_8a3c1f2e", Up: true},
+		{Alias: testReportGre, Name: "sgre_52b7d6a1"},
+	},
+	Dnsmasq: []DnsmasqProcess{
+		{Bridge: testReportBridge},
+	},
+	Problems: []NetworkProblem{
+		{
+			Alias:    testReportGre,
+			Problem:  "device is not attached to bridge " + testReportBridge,
+			Repaired: true,
+		},
+		{
+			Alias:    testReportBridge,
+			Problem:  "dnsmasq is not running",
+			Repaired: true,
+		},
+	},
+}
+
+func TestNetworkInspectMarshal(t *testing.T) {
+	var cmd NetworkInspect
+	cmd.Inspect.NodeUUID = testutil.CNCIUUID
+	cmd.Inspect.Repair = true
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.NetworkInspectYaml {
+		t.Errorf("NetworkInspect marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.NetworkInspectYaml)
+	}
+}
+
+func TestNetworkInspectUnmarshal(t *testing.T) {
+	var cmd NetworkInspect
+	err := yaml.Unmarshal([]byte(testutil.NetworkInspectYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.Inspect.NodeUUID != testutil.CNCIUUID {
+		t.Errorf("Wrong node UUID field [%s]", cmd.Inspect.NodeUUID)
+	}
+
+	if !cmd.Inspect.Repair {
+		t.Errorf("Wrong repair field")
+	}
+}
+
+func TestNetworkReportMarshal(t *testing.T) {
+	evt := NetworkReport{Report: testNetworkReport}
+
+	y, err := yaml.Marshal(&evt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.NetworkReportYaml {
+		t.Errorf("NetworkReport marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.NetworkReportYaml)
+	}
+}
+
+func TestNetworkReportUnmarshal(t *testing.T) {
+	var evt NetworkReport
+	err := yaml.Unmarshal([]byte(testutil.NetworkReportYaml), &evt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(evt.Report, testNetworkReport) {
+		t.Errorf("Wrong network report %+v", evt.Report)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// PortForward describes a port of a public IP forwarded by a CNCI to a
+// port of an instance.
+type PortForward struct {
+	PortForwardUUID string `yaml:"port_forward_uuid"`
+	PublicIP        string `yaml:"public_ip"`
+
+	// Protocol is either tcp or udp.
+	Protocol    string `yaml:"protocol"`
+	PublicPort  int    `yaml:"public_port"`
+	PrivateIP   string `yaml:"private_ip"`
+	PrivatePort int    `yaml:"private_port"`
+}
+
+// PortForwardsCmd contains all the port forwards of a tenant that are
+// rendered by a CNCI.
+type PortForwardsCmd struct {
+	ConcentratorUUID string        `yaml:"concentrator_uuid"`
+	TenantUUID       string        `yaml:"tenant_uuid"`
+	PortForwards     []PortForward `yaml:"port_forwards"`
+
+	UpdateGeneration `yaml:",inline"`
+}
+
+// CommandPortForwards represents the unmarshalled version of the contents
+// of a SSNTP PortForwards payload.  It replaces any port forwards
+// previously sent to the CNCI.
+type CommandPortForwards struct {
+	PortForwards PortForwardsCmd `yaml:"port_forwards"`
+}
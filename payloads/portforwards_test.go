@@ -0,0 +1,75 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+var testPortForward = PortForward{
+	PortForwardUUID: testutil.PortForwardUUID,
+	PublicIP:        testutil.InstancePublicIP,
+	Protocol:        "tcp",
+	PublicPort:      2222,
+	PrivateIP:       testutil.InstancePrivateIP,
+	PrivatePort:     22,
+}
+
+func TestPortForwardsMarshal(t *testing.T) {
+	var cmd CommandPortForwards
+	cmd.PortForwards.ConcentratorUUID = testutil.CNCIUUID
+	cmd.PortForwards.TenantUUID = testutil.TenantUUID
+	cmd.PortForwards.PortForwards = []PortForward{testPortForward}
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.PortForwardsYaml {
+		t.Errorf("PortForwards marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.PortForwardsYaml)
+	}
+}
+
+func TestPortForwardsUnmarshal(t *testing.T) {
+	var cmd CommandPortForwards
+	err := yaml.Unmarshal([]byte(testutil.PortForwardsYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.PortForwards.ConcentratorUUID != testutil.CNCIUUID {
+		t.Errorf("Wrong concentrator UUID field [%s]", cmd.PortForwards.ConcentratorUUID)
+	}
+
+	if cmd.PortForwards.TenantUUID != testutil.TenantUUID {
+		t.Errorf("Wrong tenant UUID field [%s]", cmd.PortForwards.TenantUUID)
+	}
+
+	if len(cmd.PortForwards.PortForwards) != 1 {
+		t.Fatalf("Expected 1 port forward, got %d", len(cmd.PortForwards.PortForwards))
+	}
+
+	if !reflect.DeepEqual(cmd.PortForwards.PortForwards[0], testPortForward) {
+		t.Errorf("Wrong port forward field %v", cmd.PortForwards.PortForwards[0])
+	}
+}
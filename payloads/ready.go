@@ -45,6 +45,17 @@ type Ready struct {
 	// cpu[0-9]+ entries in /proc/stat.
 	CpusOnline int `yaml:"cpus_online"`
 
+	// Number of VCPUs allocated to the instances running on the CN/NN.
+	VCPUsAllocated int `yaml:"vcpus_allocated"`
+
+	// Amount of memory, in MB, allocated to the instances running on the
+	// CN/NN.
+	MemAllocatedMB int `yaml:"mem_allocated_mb"`
+
+	// Labels set on the CN/NN by the operator, matched by the scheduler
+	// against the node selectors of workloads.
+	Labels map[string]string `yaml:"labels,omitempty"`
+
 	// Array containing one entry for each network interface present on the
 	// CN/NN
 	Networks []NetworkStat
@@ -62,4 +73,6 @@ func (s *Ready) Init() {
 	s.DiskAvailableMB = -1
 	s.Load = -1
 	s.CpusOnline = -1
+	s.VCPUsAllocated = -1
+	s.MemAllocatedMB = -1
 }
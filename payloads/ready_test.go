@@ -41,6 +41,9 @@ func TestReadyMarshal(t *testing.T) {
 		DiskAvailableMB: 256000,
 		Load:            0,
 		CpusOnline:      4,
+		VCPUsAllocated:  6,
+		MemAllocatedMB:  1024,
+		Labels:          testutil.NodeLabels,
 		Networks: []NetworkStat{
 			{NodeIP: "192.168.1.1", NodeMAC: "02:00:15:03:6f:49"},
 			{NodeIP: "10.168.1.1", NodeMAC: "02:00:8c:ba:f9:45"},
@@ -76,6 +79,8 @@ func TestReadyNodeNotAllStats(t *testing.T) {
 		DiskAvailableMB: -1,
 		Load:            1,
 		CpusOnline:      -1,
+		VCPUsAllocated:  -1,
+		MemAllocatedMB:  -1,
 	}
 	if cmd.NodeUUID != expectedCmd.NodeUUID ||
 		cmd.MemTotalMB != expectedCmd.MemTotalMB ||
@@ -84,6 +89,9 @@ func TestReadyNodeNotAllStats(t *testing.T) {
 		cmd.DiskAvailableMB != expectedCmd.DiskAvailableMB ||
 		cmd.Load != expectedCmd.Load ||
 		cmd.CpusOnline != expectedCmd.CpusOnline ||
+		cmd.VCPUsAllocated != expectedCmd.VCPUsAllocated ||
+		cmd.MemAllocatedMB != expectedCmd.MemAllocatedMB ||
+		len(cmd.Labels) != 0 ||
 		len(cmd.Networks) != 0 {
 		t.Error("Unexpected values in Ready")
 	}
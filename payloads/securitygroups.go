@@ -0,0 +1,79 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// SecurityGroupDirection is the direction of the traffic a security group
+// rule applies to, as seen from the instance.
+type SecurityGroupDirection string
+
+const (
+	// Ingress rules match traffic sent to the instance.
+	Ingress SecurityGroupDirection = "ingress"
+
+	// Egress rules match traffic sent by the instance.
+	Egress SecurityGroupDirection = "egress"
+)
+
+// SecurityGroupRule allows a class of traffic to or from an instance.
+type SecurityGroupRule struct {
+	Direction SecurityGroupDirection `yaml:"direction"`
+
+	// Protocol is tcp, udp or icmp.  An empty protocol matches all
+	// traffic.
+	Protocol string `yaml:"protocol,omitempty"`
+
+	// PortMin and PortMax bound the destination ports of tcp and udp
+	// traffic.  A PortMin of 0 matches all ports.
+	PortMin int `yaml:"port_min,omitempty"`
+	PortMax int `yaml:"port_max,omitempty"`
+
+	// RemoteCIDR is the address range of the other end of the traffic.
+	// Rules referring to a remote security group are expanded by the
+	// controller into one rule per member of that group.
+	RemoteCIDR string `yaml:"remote_cidr"`
+}
+
+// InstanceSecurityRules contains the rules applying to a single instance.
+// Rules whose remote CIDR is an IPv6 one apply to the IPv6 address of the
+// instance, rules without a remote CIDR apply to both of its addresses.
+type InstanceSecurityRules struct {
+	InstanceUUID string              `yaml:"instance_uuid"`
+	PrivateIP    string              `yaml:"private_ip"`
+	PrivateIPv6  string              `yaml:"private_ipv6,omitempty"`
+	Rules        []SecurityGroupRule `yaml:"rules"`
+}
+
+// SecurityGroupsCmd contains the security group rules of all the instances
+// of a tenant that are served by a CNCI.  Traffic to and from these
+// instances that no rule allows is dropped.  The command is also sent to
+// the compute nodes, with all the instances of the tenant, in which case
+// NodeUUID is set instead of ConcentratorUUID.  Each node enforces the
+// rules of the instances it runs on the bridge ports of their VNICs.
+type SecurityGroupsCmd struct {
+	ConcentratorUUID string                  `yaml:"concentrator_uuid"`
+	NodeUUID         string                  `yaml:"node_uuid,omitempty"`
+	TenantUUID       string                  `yaml:"tenant_uuid"`
+	Instances        []InstanceSecurityRules `yaml:"instances"`
+	UpdateGeneration `yaml:",inline"`
+}
+
+// CommandSecurityGroups represents the unmarshalled version of the contents
+// of a SSNTP SecurityGroups payload.  It replaces any rules previously sent
+// to the CNCI.
+type CommandSecurityGroups struct {
+	SecurityGroups SecurityGroupsCmd `yaml:"security_groups"`
+}
@@ -0,0 +1,116 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+var testSecurityGroupRules = []SecurityGroupRule{
+	{
+		Direction:  Ingress,
+		Protocol:   "tcp",
+		PortMin:    22,
+		PortMax:    22,
+		RemoteCIDR: "0.0.0.0/0",
+	},
+	{
+		Direction:  Egress,
+		RemoteCIDR: "0.0.0.0/0",
+	},
+}
+
+func TestSecurityGroupsMarshal(t *testing.T) {
+	var cmd CommandSecurityGroups
+	cmd.SecurityGroups.ConcentratorUUID = testutil.CNCIUUID
+	cmd.SecurityGroups.TenantUUID = testutil.TenantUUID
+	cmd.SecurityGroups.Instances = []InstanceSecurityRules{
+		{
+			InstanceUUID: testutil.InstanceUUID,
+			PrivateIP:    testutil.InstancePrivateIP,
+			Rules:        testSecurityGroupRules,
+		},
+	}
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.SecurityGroupsYaml {
+		t.Errorf("SecurityGroups marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.SecurityGroupsYaml)
+	}
+}
+
+func TestSecurityGroupsUnmarshal(t *testing.T) {
+	var cmd CommandSecurityGroups
+	err := yaml.Unmarshal([]byte(testutil.SecurityGroupsYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.SecurityGroups.ConcentratorUUID != testutil.CNCIUUID {
+		t.Errorf("Wrong concentrator UUID field [%s]", cmd.SecurityGroups.ConcentratorUUID)
+	}
+
+	if cmd.SecurityGroups.TenantUUID != testutil.TenantUUID {
+		t.Errorf("Wrong tenant UUID field [%s]", cmd.SecurityGroups.TenantUUID)
+	}
+
+	if len(cmd.SecurityGroups.Instances) != 1 {
+		t.Fatalf("Expected 1 instance, got %d", len(cmd.SecurityGroups.Instances))
+	}
+
+	i := cmd.SecurityGroups.Instances[0]
+	if i.InstanceUUID != testutil.InstanceUUID {
+		t.Errorf("Wrong instance UUID field [%s]", i.InstanceUUID)
+	}
+
+	if i.PrivateIP != testutil.InstancePrivateIP {
+		t.Errorf("Wrong private IP field [%s]", i.PrivateIP)
+	}
+
+	if !reflect.DeepEqual(i.Rules, testSecurityGroupRules) {
+		t.Errorf("Wrong rules field %v", i.Rules)
+	}
+}
+
+func TestSecurityGroupsGeneration(t *testing.T) {
+	var cmd CommandSecurityGroups
+	cmd.SecurityGroups.TenantUUID = testutil.TenantUUID
+	cmd.SecurityGroups.Generation = 42
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed CommandSecurityGroups
+	err = yaml.Unmarshal(y, &parsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed.SecurityGroups.Generation != 42 {
+		t.Errorf("Generation not kept: %s", string(y))
+	}
+}
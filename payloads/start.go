@@ -93,6 +93,24 @@ const (
 	// SharedDiskGiB is used for shared storage across the cluster used for
 	// storing volume and images. (Measured in GiB)
 	SharedDiskGiB = "shared_disk_gib"
+
+	// Snapshot is used to indicate that the requested resource is a
+	// volume snapshot.
+	Snapshot = "snapshot"
+
+	// SnapshotGiB is used for the shared storage used by volume
+	// snapshots. (Measured in GiB)
+	SnapshotGiB = "snapshot_gib"
+
+	// NetworkIngressKbps indicates that a resource struct specifies the
+	// rate, in kbit/s, at which each VNIC of an instance may receive
+	// traffic.  Instances without this resource are not limited.
+	NetworkIngressKbps = "network_ingress_kbps"
+
+	// NetworkEgressKbps indicates that a resource struct specifies the
+	// rate, in kbit/s, at which each VNIC of an instance may send traffic.
+	// Instances without this resource are not limited.
+	NetworkEgressKbps = "network_egress_kbps"
 )
 
 const (
@@ -133,6 +151,11 @@ type StorageResource struct {
 
 	// Size is the requested size for an auto-created storage resource
 	Size int `yaml:"size,omitempty"`
+
+	// VolumeType is the name of the volume type of the storage resource,
+	// which selects the block driver of the volume.  It is empty for
+	// volumes of the default volume type.
+	VolumeType string `yaml:"volume_type,omitempty"`
 }
 
 // RequestedResource is used to specify an individual resource contained within
@@ -183,7 +206,8 @@ type NetworkResources struct {
 	Subnet string `yaml:"subnet"`
 
 	// SubnetKey is the subnet identifier to which the instance
-	// is assigned.
+	// is assigned.  It is allocated by the controller, unique across
+	// the cluster, and used as the key of the tunnels of the subnet.
 	SubnetKey string `yaml:"subnet_key"`
 
 	// SubnetUUID is the subnet ID of the subnet to which the instance is
@@ -199,6 +223,34 @@ type NetworkResources struct {
 	PublicIP bool `yaml:"public_ip"`
 }
 
+// ServerGroupPolicy is the placement policy applied to the members of a
+// server group.
+type ServerGroupPolicy string
+
+const (
+	// Affinity requires all members of a server group to run on the
+	// same node.
+	Affinity ServerGroupPolicy = "affinity"
+
+	// AntiAffinity requires all members of a server group to run on
+	// different nodes.
+	AntiAffinity ServerGroupPolicy = "anti-affinity"
+)
+
+// ServerGroupHint is passed to the scheduler when starting a member of a
+// server group so that the group's placement policy can be enforced.
+type ServerGroupHint struct {
+	// GroupUUID is the UUID of the server group.
+	GroupUUID string `yaml:"group_uuid"`
+
+	// Policy is the placement policy of the server group.
+	Policy ServerGroupPolicy `yaml:"policy"`
+
+	// MemberNodes contains the UUIDs of the nodes on which the other
+	// members of the group are known to be running.
+	MemberNodes []string `yaml:"member_nodes,omitempty"`
+}
+
 // StartCmd contains the information needed to start a new instance.
 type StartCmd struct {
 	// TenantUUID is the UUID of the tenant to which the new instance will
@@ -235,6 +287,18 @@ type StartCmd struct {
 	// for the new instance.
 	Networking NetworkResources `yaml:"networking"`
 
+	// ExtraNetworking contains the networking information of the
+	// additional VNICs of an instance attached to more than one tenant
+	// network, one entry per VNIC.  Only specified when creating CN VM
+	// instances.
+	ExtraNetworking []NetworkResources `yaml:"extra_networking,omitempty"`
+
+	// RouterSubnets contains the subnets of the tenant to which the
+	// distributed router of the tenant on the compute node is connected.
+	// Only specified when creating CN instances of tenants with more than
+	// one subnet when distributed routing is enabled.
+	RouterSubnets []RouterSubnet `yaml:"router_subnets,omitempty"`
+
 	// Storage contains all the information required to attach or boot
 	// from storage for the new instance.
 	Storage []StorageResource `yaml:"storage,omitempty"`
@@ -242,6 +306,19 @@ type StartCmd struct {
 	// Restart is set to true if the payload represents a request to
 	// restart an existing instance on a new node.
 	Restart bool
+
+	// MigrateFrom is set to the UUID of the node currently running the
+	// instance if the payload represents a request to prepare a node to
+	// receive a live migrated instance.  The instance will not be started
+	// on that node.
+	MigrateFrom string `yaml:"migrate_from,omitempty"`
+
+	// ServerGroup is set if the instance is a member of a server group.
+	ServerGroup *ServerGroupHint `yaml:"server_group,omitempty"`
+
+	// NodeSelector restricts the nodes on which the instance can be
+	// scheduled to those carrying all of the given labels.
+	NodeSelector map[string]string `yaml:"node_selector,omitempty"`
 }
 
 // Start represents the unmarshalled version of the contents of a SSNTP START
@@ -109,3 +109,61 @@ func TestStartUnmarshalPartial(t *testing.T) {
 		t.Error("Unexpected values in Start")
 	}
 }
+
+func TestStartUnmarshalServerGroup(t *testing.T) {
+	var cmd Start
+	err := yaml.Unmarshal([]byte(testutil.ServerGroupStartYaml), &cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group := cmd.Start.ServerGroup
+	if group == nil {
+		t.Fatal("Server group hint missing from Start")
+	}
+
+	if group.GroupUUID != testutil.ServerGroupUUID ||
+		group.Policy != AntiAffinity ||
+		len(group.MemberNodes) != 1 ||
+		group.MemberNodes[0] != testutil.AgentUUID {
+		t.Errorf("Unexpected server group hint %+v", *group)
+	}
+}
+
+func TestStartUnmarshalNodeSelector(t *testing.T) {
+	var cmd Start
+	err := yaml.Unmarshal([]byte(testutil.NodeSelectorStartYaml), &cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	selector := cmd.Start.NodeSelector
+	if len(selector) != 1 || selector["ssd"] != "true" {
+		t.Errorf("Unexpected node selector %v", selector)
+	}
+}
+
+func TestStartUnmarshalExtraNetworking(t *testing.T) {
+	var cmd Start
+	err := yaml.Unmarshal([]byte(testutil.MultiNetworkStartYaml), &cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cmd.Start.Networking.VnicUUID != testutil.VNICUUID ||
+		cmd.Start.Networking.PrivateIP != testutil.InstancePrivateIP {
+		t.Errorf("Unexpected networking %+v", cmd.Start.Networking)
+	}
+
+	if len(cmd.Start.ExtraNetworking) != 1 {
+		t.Fatalf("Expected 1 extra VNIC, got %d", len(cmd.Start.ExtraNetworking))
+	}
+
+	extra := cmd.Start.ExtraNetworking[0]
+	if extra.VnicUUID != testutil.ExtraVNICUUID ||
+		extra.Subnet != testutil.ExtraTenantSubnet ||
+		extra.PrivateIP != testutil.ExtraPrivateIP ||
+		extra.ConcentratorUUID != testutil.CNCIUUID {
+		t.Errorf("Unexpected extra networking %+v", extra)
+	}
+}
@@ -82,6 +82,11 @@ const (
 	// NetworkFailure indicates that it was not possible to initialise
 	// networking for the instance.
 	NetworkFailure = "network_failure"
+
+	// ServerGroupConflict is returned by the scheduler if the only nodes
+	// with the resources needed to start the instance would violate the
+	// policy of the instance's server group.
+	ServerGroupConflict = "server_group_conflict"
 )
 
 // ErrorStartFailure represents the unmarshalled version of the contents of a
@@ -128,6 +133,8 @@ func (r StartFailureReason) String() string {
 		return "Failed to launch instance"
 	case NetworkFailure:
 		return "Failed to create VNIC for instance"
+	case ServerGroupConflict:
+		return "No node satisfies the server group policy"
 	}
 
 	return ""
@@ -146,7 +153,8 @@ func (r StartFailureReason) IsFatal() bool {
 		InvalidData,
 		ImageFailure,
 		LaunchFailure,
-		NetworkFailure:
+		NetworkFailure,
+		ServerGroupConflict:
 		return true
 
 	case AlreadyRunning,
@@ -78,6 +78,7 @@ func TestStartFailureString(t *testing.T) {
 		{ImageFailure, "Failed to create instance image"},
 		{LaunchFailure, "Failed to launch instance"},
 		{NetworkFailure, "Failed to create VNIC for instance"},
+		{ServerGroupConflict, "No node satisfies the server group policy"},
 	}
 	error := ErrorStartFailure{
 		InstanceUUID: testutil.InstanceUUID,
@@ -16,6 +16,16 @@
 
 package payloads
 
+const (
+	// SerialConsole is the console type of an instance whose serial port
+	// is exposed as a raw TCP socket.
+	SerialConsole = "serial"
+
+	// SpiceConsole is the console type of an instance exposed through
+	// a SPICE server.
+	SpiceConsole = "spice"
+)
+
 // InstanceStat contains information about the state of an indiviual
 // instance in a ciao cluster.
 type InstanceStat struct {
@@ -36,6 +46,19 @@ type InstanceStat struct {
 	// Will be 0 if the instance is itself a CNCI VM.
 	SSHPort int `yaml:"ssh_port"`
 
+	// Type of the console exposed by the instance, e.g., serial or
+	// spice.  Will be "" if the instance has no console.
+	ConsoleType string `yaml:"console_type,omitempty"`
+
+	// IP address and port number of the console proxy of the compute
+	// node, through which the console of the instance can be reached.
+	ConsoleIP   string `yaml:"console_ip,omitempty"`
+	ConsolePort int    `yaml:"console_port,omitempty"`
+
+	// Token authenticating the connections to the console of the
+	// instance through the console proxy.
+	ConsoleToken string `yaml:"console_token,omitempty"`
+
 	// Memory usage in MB.  May be -1 if State != Running.
 	MemoryUsageMB int `yaml:"memory_usage_mb"`
 
@@ -95,6 +118,9 @@ type Stat struct {
 	// Hostname of the CN/NN
 	NodeHostName string `yaml:"hostname"`
 
+	// Labels set on the CN/NN by the operator
+	Labels map[string]string `yaml:"labels,omitempty"`
+
 	// Array containing one entry for each network interface present on the
 	// CN/NN
 	Networks []NetworkStat
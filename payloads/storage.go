@@ -30,6 +30,11 @@ type VolumeCmd struct {
 	// running.  This information is needed by the scheduler to route
 	// the command to the correct CN/NN.
 	WorkloadAgentUUID string `yaml:"workload_agent_uuid"`
+
+	// VolumeType is the name of the volume type of the volume, which
+	// selects the block driver mapping the volume to the node.  It is
+	// empty for volumes of the default volume type.
+	VolumeType string `yaml:"volume_type,omitempty"`
 }
 
 // AttachVolume represents the unmarshalled version of the contents of a SSNTP
@@ -38,3 +43,36 @@ type VolumeCmd struct {
 type AttachVolume struct {
 	Attach VolumeCmd `yaml:"attach_volume"`
 }
+
+// DetachVolume represents the unmarshalled version of the contents of a SSNTP
+// DetachVolume payload.  The structure contains enough information to detach a
+// volume from an existing instance.
+type DetachVolume struct {
+	Detach VolumeCmd `yaml:"detach_volume"`
+}
+
+// ResizeVolumeCmd contains all the information needed to notify an
+// existing instance that a volume attached to it has been extended.
+type ResizeVolumeCmd struct {
+	// InstanceUUID is the UUID of the instance to which the volume is
+	// attached.
+	InstanceUUID string `yaml:"instance_uuid"`
+
+	// VolumeUUID is the UUID of the extended volume.
+	VolumeUUID string `yaml:"volume_uuid"`
+
+	// WorkloadAgentUUID identifies the node on which the instance is
+	// running.  This information is needed by the scheduler to route
+	// the command to the correct CN.
+	WorkloadAgentUUID string `yaml:"workload_agent_uuid"`
+
+	// SizeGiB is the new size of the volume in GiB.
+	SizeGiB int `yaml:"size_gib"`
+}
+
+// ResizeVolume represents the unmarshalled version of the contents of a SSNTP
+// ResizeVolume payload.  The structure contains enough information to notify
+// an existing instance that a volume attached to it has been extended.
+type ResizeVolume struct {
+	Resize ResizeVolumeCmd `yaml:"resize_volume"`
+}
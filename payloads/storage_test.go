@@ -60,3 +60,82 @@ func TestAttachVolmeMarshal(t *testing.T) {
 			string(y), testutil.AttachVolumeYaml)
 	}
 }
+
+func TestResizeVolumeUnmarshal(t *testing.T) {
+	var resize ResizeVolume
+	err := yaml.Unmarshal([]byte(testutil.ResizeVolumeYaml), &resize)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if resize.Resize.InstanceUUID != testutil.InstanceUUID {
+		t.Errorf("Wrong instance UUID field [%s]", resize.Resize.InstanceUUID)
+	}
+
+	if resize.Resize.VolumeUUID != testutil.VolumeUUID {
+		t.Errorf("Wrong Volume UUID field [%s]", resize.Resize.VolumeUUID)
+	}
+
+	if resize.Resize.WorkloadAgentUUID != testutil.AgentUUID {
+		t.Errorf("Wrong WorkloadAgentUUID field [%s]", resize.Resize.WorkloadAgentUUID)
+	}
+
+	if resize.Resize.SizeGiB != 20 {
+		t.Errorf("Wrong SizeGiB field [%d]", resize.Resize.SizeGiB)
+	}
+}
+
+func TestResizeVolumeMarshal(t *testing.T) {
+	var resize ResizeVolume
+	resize.Resize.InstanceUUID = testutil.InstanceUUID
+	resize.Resize.VolumeUUID = testutil.VolumeUUID
+	resize.Resize.WorkloadAgentUUID = testutil.AgentUUID
+	resize.Resize.SizeGiB = 20
+
+	y, err := yaml.Marshal(&resize)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.ResizeVolumeYaml {
+		t.Errorf("ResizeVolume marshalling failed\n[%s]\n vs\n[%s]",
+			string(y), testutil.ResizeVolumeYaml)
+	}
+}
+
+func TestDetachVolumeUnmarshal(t *testing.T) {
+	var detach DetachVolume
+	err := yaml.Unmarshal([]byte(testutil.DetachVolumeYaml), &detach)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if detach.Detach.InstanceUUID != testutil.InstanceUUID {
+		t.Errorf("Wrong instance UUID field [%s]", detach.Detach.InstanceUUID)
+	}
+
+	if detach.Detach.VolumeUUID != testutil.VolumeUUID {
+		t.Errorf("Wrong Volume UUID field [%s]", detach.Detach.VolumeUUID)
+	}
+
+	if detach.Detach.WorkloadAgentUUID != testutil.AgentUUID {
+		t.Errorf("Wrong WorkloadAgentUUID field [%s]", detach.Detach.WorkloadAgentUUID)
+	}
+}
+
+func TestDetachVolumeMarshal(t *testing.T) {
+	var detach DetachVolume
+	detach.Detach.InstanceUUID = testutil.InstanceUUID
+	detach.Detach.VolumeUUID = testutil.VolumeUUID
+	detach.Detach.WorkloadAgentUUID = testutil.AgentUUID
+
+	y, err := yaml.Marshal(&detach)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.DetachVolumeYaml {
+		t.Errorf("DetachVolume marshalling failed\n[%s]\n vs\n[%s]",
+			string(y), testutil.DetachVolumeYaml)
+	}
+}
@@ -41,6 +41,9 @@ type TenantAddedEvent struct {
 
 	// The UUID of the subnet.
 	SubnetKey int `yaml:"subnet_key"`
+
+	// The type of the tunnel created by the CN, GRE if not specified.
+	TunnelType TunnelType `yaml:"tunnel_type,omitempty"`
 }
 
 // EventTenantAdded represents the unmarshalled version of the contents of an
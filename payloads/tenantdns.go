@@ -0,0 +1,45 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// DNSRecord describes the host name of an instance in the DNS zone of its
+// tenant.
+type DNSRecord struct {
+	Name string `yaml:"name"`
+	IP   string `yaml:"ip"`
+
+	// IPv6 is empty when the subnet of the instance has no IPv6 subnet.
+	IPv6 string `yaml:"ipv6,omitempty"`
+}
+
+// TenantDNSCmd contains the DNS zone of a tenant and the records of its
+// instances, which are served by a CNCI.
+type TenantDNSCmd struct {
+	ConcentratorUUID string      `yaml:"concentrator_uuid"`
+	TenantUUID       string      `yaml:"tenant_uuid"`
+	Zone             string      `yaml:"zone"`
+	Records          []DNSRecord `yaml:"records"`
+
+	UpdateGeneration `yaml:",inline"`
+}
+
+// CommandTenantDNS represents the unmarshalled version of the contents of a
+// SSNTP TenantDNS payload.  It replaces any records previously sent to the
+// CNCI.
+type CommandTenantDNS struct {
+	TenantDNS TenantDNSCmd `yaml:"tenant_dns"`
+}
@@ -0,0 +1,73 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"reflect"
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+var testDNSRecord = DNSRecord{
+	Name: "web-0",
+	IP:   testutil.InstancePrivateIP,
+	IPv6: "fd00:c1a0:0:a801::102",
+}
+
+func TestTenantDNSMarshal(t *testing.T) {
+	var cmd CommandTenantDNS
+	cmd.TenantDNS.ConcentratorUUID = testutil.CNCIUUID
+	cmd.TenantDNS.TenantUUID = testutil.TenantUUID
+	cmd.TenantDNS.Zone = testutil.TenantUUID + ".ciao"
+	cmd.TenantDNS.Records = []DNSRecord{testDNSRecord}
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.TenantDNSYaml {
+		t.Errorf("TenantDNS marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.TenantDNSYaml)
+	}
+}
+
+func TestTenantDNSUnmarshal(t *testing.T) {
+	var cmd CommandTenantDNS
+	err := yaml.Unmarshal([]byte(testutil.TenantDNSYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.TenantDNS.ConcentratorUUID != testutil.CNCIUUID {
+		t.Errorf("Wrong concentrator UUID field [%s]", cmd.TenantDNS.ConcentratorUUID)
+	}
+
+	if cmd.TenantDNS.Zone != testutil.TenantUUID+".ciao" {
+		t.Errorf("Wrong zone field [%s]", cmd.TenantDNS.Zone)
+	}
+
+	if len(cmd.TenantDNS.Records) != 1 {
+		t.Fatalf("Expected 1 record, got %d", len(cmd.TenantDNS.Records))
+	}
+
+	if !reflect.DeepEqual(cmd.TenantDNS.Records[0], testDNSRecord) {
+		t.Errorf("Wrong record field %v", cmd.TenantDNS.Records[0])
+	}
+}
@@ -0,0 +1,56 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads
+
+// RouterSubnet describes a subnet of a tenant to which the distributed
+// router of the tenant on a compute node is connected.
+type RouterSubnet struct {
+	// Subnet is the subnet, as in the networking of the instances.
+	Subnet string `yaml:"subnet"`
+
+	// ConcentratorUUID is the UUID of the CNCI of the subnet.
+	ConcentratorUUID string `yaml:"concentrator_uuid"`
+
+	// ConcentratorIP is the IP address of the CNCI of the subnet.
+	ConcentratorIP string `yaml:"concentrator_ip"`
+
+	// SubnetKey is the cluster unique key of the tunnels of the subnet.
+	SubnetKey int `yaml:"subnet_key"`
+}
+
+// TenantRoutesCmd contains the subnets of a tenant that are routed by the
+// distributed routers of the compute nodes.  It is sent to the compute
+// nodes running instances of the tenant, which connect their router of the
+// tenant to the subnets, and to the CNCIs of the tenant, which route the
+// traffic of their subnet to the other subnets through the router of the
+// compute nodes.
+type TenantRoutesCmd struct {
+	// NodeUUID is the UUID of the compute node or of the CNCI to which
+	// the command is sent.
+	NodeUUID   string         `yaml:"node_uuid"`
+	TenantUUID string         `yaml:"tenant_uuid"`
+	Subnets    []RouterSubnet `yaml:"subnets"`
+
+	UpdateGeneration `yaml:",inline"`
+}
+
+// CommandTenantRoutes represents the unmarshalled version of the contents
+// of a SSNTP TenantRoutes payload.  It replaces any subnets previously sent
+// for the tenant.
+type CommandTenantRoutes struct {
+	TenantRoutes TenantRoutesCmd `yaml:"tenant_routes"`
+}
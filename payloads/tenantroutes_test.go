@@ -0,0 +1,83 @@
+/*
+// Copyright (c) 2017 Intel Corporation
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+*/
+
+package payloads_test
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	. "github.com/ciao-project/ciao/payloads"
+	"github.com/ciao-project/ciao/testutil"
+	"gopkg.in/yaml.v2"
+)
+
+func testSubnetKey(key string) int {
+	k, _ := strconv.Atoi(key)
+	return k
+}
+
+var testRouterSubnets = []RouterSubnet{
+	{
+		Subnet:           testutil.TenantSubnet,
+		ConcentratorUUID: testutil.CNCIUUID,
+		ConcentratorIP:   testutil.CNCIIP,
+		SubnetKey:        testSubnetKey(testutil.SubnetKey),
+	},
+	{
+		Subnet:           testutil.ExtraTenantSubnet,
+		ConcentratorUUID: testutil.CNCIUUID,
+		ConcentratorIP:   testutil.CNCIIP,
+		SubnetKey:        testSubnetKey(testutil.ExtraSubnetKey),
+	},
+}
+
+func TestTenantRoutesMarshal(t *testing.T) {
+	var cmd CommandTenantRoutes
+	cmd.TenantRoutes.NodeUUID = testutil.AgentUUID
+	cmd.TenantRoutes.TenantUUID = testutil.TenantUUID
+	cmd.TenantRoutes.Subnets = testRouterSubnets
+
+	y, err := yaml.Marshal(&cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(y) != testutil.TenantRoutesYaml {
+		t.Errorf("TenantRoutes marshalling failed\n[%s]\n vs\n[%s]", string(y), testutil.TenantRoutesYaml)
+	}
+}
+
+func TestTenantRoutesUnmarshal(t *testing.T) {
+	var cmd CommandTenantRoutes
+	err := yaml.Unmarshal([]byte(testutil.TenantRoutesYaml), &cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if cmd.TenantRoutes.NodeUUID != testutil.AgentUUID {
+		t.Errorf("Wrong node UUID field [%s]", cmd.TenantRoutes.NodeUUID)
+	}
+
+	if cmd.TenantRoutes.TenantUUID != testutil.TenantUUID {
+		t.Errorf("Wrong tenant UUID field [%s]", cmd.TenantRoutes.TenantUUID)
+	}
+
+	if !reflect.DeepEqual(cmd.TenantRoutes.Subnets, testRouterSubnets) {
+		t.Errorf("Wrong subnets field %v", cmd.TenantRoutes.Subnets)
+	}
+}
@@ -107,7 +107,8 @@ type QMPEvent struct {
 }
 
 type qmpResult struct {
-	err error
+	response interface{}
+	err      error
 }
 
 type qmpCommand struct {
@@ -117,6 +118,7 @@ type qmpCommand struct {
 	args           map[string]interface{}
 	filter         *qmpEventFilter
 	resultReceived bool
+	response       interface{}
 }
 
 // QMP is a structure that contains the internal state used by startQMPLoop and
@@ -139,6 +141,33 @@ type QMPVersion struct {
 	Capabilities []string
 }
 
+// MigrationStatus contains the status of an outgoing migration, as reported
+// by the query-migrate command.
+type MigrationStatus struct {
+	// Status is the state of the migration, e.g., active, completed or
+	// failed.  It is empty if no migration has been started.
+	Status string
+
+	// TotalTime is the total amount of time, in milliseconds, that the
+	// migration has been running.
+	TotalTime int64
+
+	// TransferredRAM is the number of bytes of guest RAM that have been
+	// transferred to the destination.
+	TransferredRAM int64
+
+	// RemainingRAM is the number of bytes of guest RAM that still need to
+	// be transferred to the destination.
+	RemainingRAM int64
+
+	// TotalRAM is the total amount of guest RAM in bytes.
+	TotalRAM int64
+
+	// ErrorDesc contains a description of the error if the migration
+	// has failed.
+	ErrorDesc string
+}
+
 func (q *QMP) readLoop(fromVMCh chan<- []byte) {
 	scanner := bufio.NewScanner(q.conn)
 	for scanner.Scan() {
@@ -210,7 +239,7 @@ func (q *QMP) finaliseCommand(cmdEl *list.Element, cmdQueue *list.List, succeede
 	case <-cmd.ctx.Done():
 	default:
 		if succeeded {
-			cmd.res <- qmpResult{}
+			cmd.res <- qmpResult{response: cmd.response}
 		} else {
 			cmd.res <- qmpResult{err: fmt.Errorf("QMP command failed")}
 		}
@@ -233,7 +262,7 @@ func (q *QMP) processQMPInput(line []byte, cmdQueue *list.List) {
 		return
 	}
 
-	_, succeeded := vmData["return"]
+	response, succeeded := vmData["return"]
 	_, failed := vmData["error"]
 
 	if !succeeded && !failed {
@@ -247,6 +276,7 @@ func (q *QMP) processQMPInput(line []byte, cmdQueue *list.List) {
 		return
 	}
 	cmd := cmdEl.Value.(*qmpCommand)
+	cmd.response = response
 	if failed || cmd.filter == nil {
 		q.finaliseCommand(cmdEl, cmdQueue, succeeded)
 	} else {
@@ -465,7 +495,14 @@ func startQMPLoop(conn io.ReadWriteCloser, cfg QMPConfig,
 
 func (q *QMP) executeCommand(ctx context.Context, name string, args map[string]interface{},
 	filter *qmpEventFilter) error {
+	_, err := q.executeCommandWithResponse(ctx, name, args, filter)
+	return err
+}
+
+func (q *QMP) executeCommandWithResponse(ctx context.Context, name string,
+	args map[string]interface{}, filter *qmpEventFilter) (interface{}, error) {
 	var err error
+	var response interface{}
 	resCh := make(chan qmpResult)
 	select {
 	case <-q.disconnectedCh:
@@ -480,17 +517,18 @@ func (q *QMP) executeCommand(ctx context.Context, name string, args map[string]i
 	}
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	select {
 	case res := <-resCh:
 		err = res.err
+		response = res.response
 	case <-ctx.Done():
 		err = ctx.Err()
 	}
 
-	return err
+	return response, err
 }
 
 // QMPStart connects to a unix domain socket maintained by a QMP instance.  It
@@ -675,3 +713,74 @@ func (q *QMP) ExecuteDeviceDel(ctx context.Context, devID string) error {
 	}
 	return q.executeCommand(ctx, "device_del", args, filter)
 }
+
+// ExecuteBlockResize sends a block_resize command to the QEMU instance,
+// informing the guest that the size of a block device has changed.
+// blockdevID is the id of the drive, either the id of a drive given on the
+// QEMU command line or the blockdevID passed to ExecuteBlockdevAdd.  size is
+// the new size of the device in bytes, which must not exceed the size of
+// the underlying device or file.
+func (q *QMP) ExecuteBlockResize(ctx context.Context, blockdevID string, size uint64) error {
+	args := map[string]interface{}{
+		"device": blockdevID,
+		"size":   size,
+	}
+
+	// qemu >= 2.9 names the devices added by ExecuteBlockdevAdd with a
+	// node-name.  QEMU looks up the device first and then the node.
+	if q.version.Major > 2 || (q.version.Major == 2 && q.version.Minor >= 9) {
+		args["node-name"] = blockdevID
+	}
+
+	return q.executeCommand(ctx, "block_resize", args, nil)
+}
+
+// ExecuteMigrate starts a live migration of the instance to the destination
+// specified by uri, e.g., tcp:192.168.0.2:49152.  The destination QEMU
+// instance must have been launched with a matching -incoming parameter.
+// The migrate command returns as soon as the migration has been started.
+// ExecuteQueryMigrate can be used to determine when the migration has
+// completed.
+func (q *QMP) ExecuteMigrate(ctx context.Context, uri string) error {
+	args := map[string]interface{}{
+		"uri": uri,
+	}
+	return q.executeCommand(ctx, "migrate", args, nil)
+}
+
+// ExecuteMigrateCancel cancels a migration that is currently in progress.
+func (q *QMP) ExecuteMigrateCancel(ctx context.Context) error {
+	return q.executeCommand(ctx, "migrate_cancel", nil, nil)
+}
+
+// ExecuteQueryMigrate sends the query-migrate command to the instance and
+// returns the status of the current, or most recent, outgoing migration.
+func (q *QMP) ExecuteQueryMigrate(ctx context.Context) (MigrationStatus, error) {
+	var status MigrationStatus
+
+	response, err := q.executeCommandWithResponse(ctx, "query-migrate", nil, nil)
+	if err != nil {
+		return status, err
+	}
+
+	info, ok := response.(map[string]interface{})
+	if !ok {
+		return status, fmt.Errorf("Invalid query-migrate response")
+	}
+
+	status.Status, _ = info["status"].(string)
+	status.ErrorDesc, _ = info["error-desc"].(string)
+	totalTime, _ := info["total-time"].(float64)
+	status.TotalTime = int64(totalTime)
+
+	if ram, ok := info["ram"].(map[string]interface{}); ok {
+		transferred, _ := ram["transferred"].(float64)
+		remaining, _ := ram["remaining"].(float64)
+		total, _ := ram["total"].(float64)
+		status.TransferredRAM = int64(transferred)
+		status.RemainingRAM = int64(remaining)
+		status.TotalRAM = int64(total)
+	}
+
+	return status, nil
+}
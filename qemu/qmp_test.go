@@ -416,6 +416,29 @@ func TestQMPXBlockdevDel(t *testing.T) {
 	<-disconnectedCh
 }
 
+// Checks that the block_resize command is correctly sent.
+//
+// We start a QMPLoop, send the block_resize command and stop the loop.
+//
+// The block_resize command should be correctly sent and the QMP loop should
+// exit gracefully.
+func TestQMPBlockResize(t *testing.T) {
+	connectedCh := make(chan *QMPVersion)
+	disconnectedCh := make(chan struct{})
+	buf := newQMPTestCommandBuffer(t)
+	buf.AddCommand("block_resize", nil, "return", nil)
+	cfg := QMPConfig{Logger: qmpTestLogger{}}
+	q := startQMPLoop(buf, cfg, connectedCh, disconnectedCh)
+	q.version = checkVersion(t, connectedCh)
+	err := q.ExecuteBlockResize(context.Background(),
+		fmt.Sprintf("drive_%s", testutil.VolumeUUID), 20<<30)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	q.Shutdown()
+	<-disconnectedCh
+}
+
 // Checks that the device_del command is correctly sent.
 //
 // We start a QMPLoop, send the device_del command and wait for it to complete.
@@ -785,3 +808,63 @@ func TestQMPLostLoop(t *testing.T) {
 		t.Error("Expected executeQMPCapabilities to fail")
 	}
 }
+
+// Checks that the migrate command is correctly sent.
+//
+// We start a QMPLoop, send the migrate command and stop the loop.
+//
+// The migrate command should be correctly sent and the QMP loop should
+// exit gracefully.
+func TestQMPMigrate(t *testing.T) {
+	connectedCh := make(chan *QMPVersion)
+	disconnectedCh := make(chan struct{})
+	buf := newQMPTestCommandBuffer(t)
+	buf.AddCommand("migrate", nil, "return", nil)
+	cfg := QMPConfig{Logger: qmpTestLogger{}}
+	q := startQMPLoop(buf, cfg, connectedCh, disconnectedCh)
+	checkVersion(t, connectedCh)
+	err := q.ExecuteMigrate(context.Background(), "tcp:192.168.0.2:49152")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	q.Shutdown()
+	<-disconnectedCh
+}
+
+// Checks that the query-migrate command is correctly sent and that its
+// response is correctly parsed.
+//
+// We start a QMPLoop, send the query-migrate command and stop the loop.
+//
+// The query-migrate command should be correctly sent, the returned
+// MigrationStatus should match the data returned by QMP and the QMP loop
+// should exit gracefully.
+func TestQMPQueryMigrate(t *testing.T) {
+	connectedCh := make(chan *QMPVersion)
+	disconnectedCh := make(chan struct{})
+	buf := newQMPTestCommandBuffer(t)
+	buf.AddCommand("query-migrate", nil, "return",
+		map[string]interface{}{
+			"status":     "completed",
+			"total-time": 12565,
+			"ram": map[string]interface{}{
+				"transferred": 2000,
+				"remaining":   0,
+				"total":       4000,
+			},
+		})
+	cfg := QMPConfig{Logger: qmpTestLogger{}}
+	q := startQMPLoop(buf, cfg, connectedCh, disconnectedCh)
+	checkVersion(t, connectedCh)
+	status, err := q.ExecuteQueryMigrate(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	if status.Status != "completed" || status.TotalTime != 12565 ||
+		status.TransferredRAM != 2000 || status.RemainingRAM != 0 ||
+		status.TotalRAM != 4000 {
+		t.Errorf("Unexpected migration status %+v", status)
+	}
+	q.Shutdown()
+	<-disconnectedCh
+}
@@ -20,6 +20,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"math/rand"
+	"net"
 	"sync"
 	"time"
 
@@ -28,6 +29,9 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
+// dialTimeout bounds each attempt to connect to one of the server URIs.
+const dialTimeout = 5 * time.Second
+
 // ClientNotifier is the SSNTP client notification interface.
 // Any SSNTP client must implement this interface.
 // IMPORTANT: All ClientNotifier implementations must be thread
@@ -223,7 +227,10 @@ func (client *Client) attemptDial() error {
 		for d := 0; ; d++ {
 			for _, uri := range client.uris {
 				client.log.Infof("%s connecting to %s\n", client.uuid, uri)
-				conn, err := tls.Dial(client.transport, uri, client.tls)
+				// Do not let an unreachable server hold up the
+				// fail over to the next one.
+				dialer := &net.Dialer{Timeout: dialTimeout}
+				conn, err := tls.DialWithDialer(dialer, client.transport, uri, client.tls)
 
 				client.status.Lock()
 				if client.status.status == ssntpClosed {
@@ -26,6 +26,7 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
 	"strings"
@@ -42,7 +43,9 @@ type Type uint8
 
 // Command is the SSNTP Command operand.
 // It can be CONNECT, START, STOP, STATS, EVACUATE, DELETE, RESTART,
-// AssignPublicIP, ReleasePublicIP, CONFIGURE or AttachVolume.
+// AssignPublicIP, ReleasePublicIP, CONFIGURE, AttachVolume, Restore, MIGRATE,
+// LabelNode, SecurityGroups, LoadBalancers, PortForwards, TenantDNS,
+// NetworkInspect, TenantRoutes, ResizeVolume or DetachVolume.
 type Command uint8
 
 // Status is the SSNTP Status operand.
@@ -62,7 +65,7 @@ type Error uint8
 // Event is the SSNTP Event operand.
 // It can be TenantAdded, TenantRemoval, InstanceDeleted, InstanceStopped,
 // ConcentratorInstanceAdded, PublicIPAssigned, PublicIPUnassigned, TraceReport,
-// NodeConnected or NodeDisconnected
+// NodeConnected, NodeDisconnected or NetworkReport
 type Event uint8
 
 const (
@@ -229,6 +232,158 @@ const (
 	//	|       |       | (0x0) |  (0x4)  |                 |                             |
 	//	+---------------------------------------------------------------------------------+
 	Restore
+
+	// MIGRATE is sent by the CIAO agent that is the destination of a live
+	// migration to the CIAO agent currently running the instance to be migrated.
+	// It is sent once the destination instance is ready to receive the migration
+	// stream and is routed to the source agent by the Scheduler.
+	//
+	// The MIGRATE command payload includes the instance UUID, the UUIDs of the
+	// source and destination agents and the URI on which the destination
+	// instance is listening.
+	//
+	//                                       SSNTP MIGRATE Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0xb)  |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	MIGRATE
+
+	// LabelNode is sent by the Controller to set the labels of a specific
+	// CIAO agent.  The agent stores the labels and reports them in its READY
+	// and STATS payloads, from which the Scheduler matches them against
+	// workload node selectors.
+	//
+	// The LabelNode command payload includes the agent UUID and the complete
+	// set of labels, which replaces any labels previously set.
+	//
+	//                                       SSNTP LabelNode Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0xc)  |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	LabelNode
+
+	// SecurityGroups is sent by the Controller to a CNCI to set the
+	// security group rules of the tenant instances it serves.  The CNCI
+	// only forwards traffic to and from these instances when one of their
+	// rules allows it.
+	//
+	// The SecurityGroups command payload includes the CNCI UUID and, for
+	// each instance, its private IP and the complete set of its rules,
+	// which replaces any rules previously set.
+	//
+	//                                       SSNTP SecurityGroups Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0xd)  |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	SecurityGroups
+
+	// LoadBalancers is sent by the Controller to a CNCI to set the load
+	// balancers it runs for the tenant.  Each load balancer listens on a
+	// public IP and port and proxies connections to the healthy
+	// members of its pool of tenant instances.
+	//
+	// The LoadBalancers command payload includes the CNCI UUID and the
+	// complete set of load balancers of the CNCI, which replaces any load
+	// balancers previously set.
+	//
+	//                                       SSNTP LoadBalancers Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0xe)  |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	LoadBalancers
+
+	// PortForwards is sent by the Controller to a CNCI to set the ports
+	// of public IPs it forwards to ports of tenant instances.  Several
+	// instances can thus share a single public IP.
+	//
+	// The PortForwards command payload includes the CNCI UUID and the
+	// complete set of port forwards of the CNCI, which replaces any port
+	// forwards previously set.
+	//
+	//                                       SSNTP PortForwards Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0xf)  |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	PortForwards
+
+	// TenantDNS is sent by the Controller to a CNCI to set the host
+	// names of the instances of its tenant.  The CNCI serves them in
+	// the DNS zone of the tenant to the instances of its subnet.
+	//
+	// The TenantDNS command payload includes the CNCI UUID, the zone of
+	// the tenant and the complete set of its records, which replaces any
+	// records previously set.
+	//
+	//                                       SSNTP TenantDNS Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0x10) |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	TenantDNS
+
+	// NetworkInspect is sent by the Controller to a compute node, a
+	// network node or a CNCI to inspect its network topology.  The node
+	// compares the topology recorded in its network database to the
+	// devices actually present, optionally repairs the problems it finds,
+	// and replies with a NetworkReport event.
+	//
+	// The NetworkInspect command payload includes the node UUID and
+	// whether the problems found should be repaired.
+	//
+	//                                       SSNTP NetworkInspect Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0x11) |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	NetworkInspect
+
+	// TenantRoutes is sent by the Controller to the compute nodes running
+	// instances of a tenant and to the CNCIs of the tenant when distributed
+	// routing is enabled.  The compute nodes connect their router of the
+	// tenant to the subnets of the tenant and the CNCIs route the traffic
+	// of their subnet to the other subnets through the router of the
+	// compute nodes.
+	//
+	// The TenantRoutes command payload includes the node UUID, the tenant
+	// UUID and the complete set of its subnets, which replaces any subnets
+	// previously set.
+	//
+	//                                       SSNTP TenantRoutes Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0x12) |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	TenantRoutes
+
+	// ResizeVolume is a command sent to ciao-launcher to notify an instance
+	// that a storage volume attached to it has been extended.
+	//
+	// The ResizeVolume command payload includes a volume UUID, an instance
+	// UUID and the new size of the volume.
+	//
+	//                                       SSNTP ResizeVolume Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0x13) |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	ResizeVolume
+
+	// DetachVolume is a command sent to ciao-launcher for detaching a storage volume
+	// from a specific instance.  Running instances have the volume hot-unplugged.
+	//
+	// The DetachVolume command payload includes a volume UUID and an instance UUID.
+	//
+	//                                       SSNTP DetachVolume Command frame
+	//	+-----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload  |
+	//	|       |       | (0x0) |  (0x14) |                 |                         |
+	//	+-----------------------------------------------------------------------------+
+	DetachVolume
 )
 
 const (
@@ -443,6 +598,22 @@ const (
 	//	|       |       | (0x3) |  (0x2)  |                 | instance information  |
 	//	+---------------------------------------------------------------------------+
 	InstanceStopped
+
+	// NetworkReport events are sent by compute nodes, network nodes and
+	// CNCIs in reply to a NetworkInspect command.  The Scheduler must
+	// forward them to the Controller.
+	//
+	// The NetworkReport event payload contains the node UUID, the
+	// expected and the actual network topology of the node, the dnsmasq
+	// processes of a CNCI and the problems found and repaired.
+	//
+	//					 SSNTP NetworkReport Event frame
+	//
+	//	+----------------------------------------------------------------------------+
+	//	| Major | Minor | Type  | Operand |  Payload Length | YAML formatted payload |
+	//	|       |       | (0x3) |  (0x9)  |                 |                        |
+	//	+----------------------------------------------------------------------------+
+	NetworkReport
 )
 
 // SSNTP clients and servers can have one or several roles and are expected to declare their
@@ -534,6 +705,14 @@ const (
 	// UnassignPublicIPFailure is sent by the CNCI when a an external IP
 	// cannot be unassigned.
 	UnassignPublicIPFailure
+
+	// MigrateFailure is sent by launcher agents to report a failure to
+	// live migrate an instance to another node.
+	MigrateFailure
+
+	// DetachVolumeFailure is sent by launcher agents to report a failure to detach
+	// a volume from an instance.
+	DetachVolumeFailure
 )
 
 // Major is the SSNTP protocol major version
@@ -585,6 +764,26 @@ func (command Command) String() string {
 		return "Attach storage volume"
 	case Restore:
 		return "Restore"
+	case MIGRATE:
+		return "MIGRATE"
+	case LabelNode:
+		return "Label node"
+	case SecurityGroups:
+		return "Security groups"
+	case LoadBalancers:
+		return "Load balancers"
+	case PortForwards:
+		return "Port forwards"
+	case TenantDNS:
+		return "Tenant DNS"
+	case NetworkInspect:
+		return "Network inspect"
+	case TenantRoutes:
+		return "Tenant routes"
+	case ResizeVolume:
+		return "Resize storage volume"
+	case DetachVolume:
+		return "Detach storage volume"
 	}
 
 	return ""
@@ -629,6 +828,8 @@ func (status Event) String() string {
 		return "Node Connected"
 	case NodeDisconnected:
 		return "Node Disconnected"
+	case NetworkReport:
+		return "Network Report"
 	}
 
 	return ""
@@ -652,6 +853,10 @@ func (error Error) String() string {
 		return "SSNTP Connection aborted"
 	case InvalidConfiguration:
 		return "Cluster configuration is invalid"
+	case MigrateFailure:
+		return "Could not migrate instance"
+	case DetachVolumeFailure:
+		return "Could not detach volume"
 	}
 
 	return ""
@@ -823,6 +1028,12 @@ type Config struct {
 	// and IPs on the running host.
 	URI string
 
+	// URIs is an optional list of further SSNTP server URIs for
+	// clients to fail over to, in order of preference, when the
+	// server at URI is unreachable or goes away. URIs without a port
+	// use Port. Servers ignore it.
+	URIs []string
+
 	// CACert is the Certification Authority certificate path
 	// to use when verifiying the peer identity.
 	// If set to "", /etc/pki/ciao/ciao_ca_cert.crt will be used.
@@ -1168,6 +1379,15 @@ func (config *Config) ConfigURIs(uris []string, port uint32) []string {
 		uris = append(uris, fmt.Sprintf("%s:%d", config.URI, port))
 	}
 
+	/* Then the servers to fail over to */
+	for _, uri := range config.URIs {
+		if _, _, err := net.SplitHostPort(uri); err == nil {
+			uris = append(uris, uri)
+		} else {
+			uris = append(uris, fmt.Sprintf("%s:%d", uri, port))
+		}
+	}
+
 	/* Then we parse the CA certificate to find FQDNs and/or IPs to connect to */
 	ips, fqdns, err := config.parseCertificateAuthority()
 	if err == nil {
@@ -1059,6 +1059,108 @@ func TestURILocalhost(t *testing.T) {
 	testMultiURIs(t, testutil.TestCACert, []string{"localhost"}, "", 8888)
 }
 
+// Test the URI list for a configuration with fail over servers
+//
+// Test that the fail over URIs come right after the configured URI,
+// in order, and that they get the configured port unless they have
+// their own.
+//
+// Test is expected to pass
+func TestURIFailover(t *testing.T) {
+	clientConfig, err := buildTestConfig(AGENT)
+	if err != nil {
+		t.Fatalf("Could not build a test config")
+	}
+
+	clientConfig.URI = "scheduler1"
+	clientConfig.URIs = []string{"scheduler2", "scheduler3:9999"}
+
+	parsedURIs := clientConfig.ConfigURIs(nil, 8888)
+	expectedURIs := []string{"scheduler1:8888", "scheduler2:8888", "scheduler3:9999"}
+
+	if len(parsedURIs) < len(expectedURIs) {
+		t.Fatalf("Wrong parsed URI slice length %d", len(parsedURIs))
+	}
+
+	for i, uri := range expectedURIs {
+		if uri != parsedURIs[i] {
+			t.Fatalf("Index %d: Mismatch URI %s vs %s", i, uri, parsedURIs[i])
+		}
+	}
+}
+
+// Test SSNTP client fail over
+//
+// Test that a client connected to the first of its server URIs
+// reconnects to the second one when the first server goes away.
+//
+// Test is expected to pass
+func TestClientFailover(t *testing.T) {
+	var active, standby ssntpEchoServer
+	var client ssntpClient
+	var role Role = AGENT
+
+	active.t = t
+	active.roleConnectChannel = make(chan string)
+	activeConfig, err := buildTestConfig(SCHEDULER)
+	if err != nil {
+		t.Fatalf("Could not build a test config")
+	}
+	activeConfig.Port = 9997
+
+	standby.t = t
+	standby.roleConnectChannel = make(chan string)
+	standbyConfig, err := buildTestConfig(SCHEDULER)
+	if err != nil {
+		t.Fatalf("Could not build a test config")
+	}
+	standbyConfig.Port = 9998
+
+	client.t = t
+	clientConfig, err := buildTestConfig(role)
+	if err != nil {
+		t.Fatalf("Could not build a test config")
+	}
+	clientConfig.URI = "localhost"
+	clientConfig.URIs = []string{"localhost:9998"}
+	clientConfig.Port = 9997
+
+	err = active.ssntp.ServeThreadSync(activeConfig, &active)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	err = standby.ssntp.ServeThreadSync(standbyConfig, &standby)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	err = client.ssntp.Dial(clientConfig, &client)
+	if err != nil {
+		t.Fatalf("Failed to connect")
+	}
+
+	select {
+	case <-active.roleConnectChannel:
+	case <-time.After(time.Second):
+		t.Fatalf("Did not connect to the active server")
+	}
+
+	active.ssntp.Stop()
+
+	select {
+	case clientRole := <-standby.roleConnectChannel:
+		if clientRole != role.String() {
+			t.Fatalf("Wrong role %s", clientRole)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Did not fail over to the standby server")
+	}
+
+	client.ssntp.Close()
+	standby.ssntp.Stop()
+}
+
 // Test SSNTP client connection closure before Dial.
 //
 // Test that an SSNTP client can close itself before Dialing
@@ -2477,6 +2579,15 @@ func TestCommandStringer(t *testing.T) {
 		{ReleasePublicIP, "Release public IP"},
 		{CONFIGURE, "CONFIGURE"},
 		{AttachVolume, "Attach storage volume"},
+		{LabelNode, "Label node"},
+		{SecurityGroups, "Security groups"},
+		{LoadBalancers, "Load balancers"},
+		{PortForwards, "Port forwards"},
+		{TenantDNS, "Tenant DNS"},
+		{NetworkInspect, "Network inspect"},
+		{TenantRoutes, "Tenant routes"},
+		{ResizeVolume, "Resize storage volume"},
+		{DetachVolume, "Detach storage volume"},
 	}
 
 	for _, test := range stringTests {
@@ -2520,6 +2631,7 @@ func TestEventStringer(t *testing.T) {
 		{TraceReport, "Trace Report"},
 		{NodeConnected, "Node Connected"},
 		{NodeDisconnected, "Node Disconnected"},
+		{NetworkReport, "Network Report"},
 	}
 
 	for _, test := range stringTests {
@@ -2543,6 +2655,8 @@ func TestErrorStringer(t *testing.T) {
 		{DeleteFailure, "Could not delete instance"},
 		{ConnectionAborted, "SSNTP Connection aborted"},
 		{InvalidConfiguration, "Cluster configuration is invalid"},
+		{MigrateFailure, "Could not migrate instance"},
+		{DetachVolumeFailure, "Could not detach volume"},
 	}
 
 	for _, test := range stringTests {
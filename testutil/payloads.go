@@ -63,6 +63,12 @@ const MgmtNet = "192.168.1.111"
 // ManagementID is a test identifier for a Ceph ID
 const ManagementID = "ciao"
 
+// VolumeType is a test volume type name
+const VolumeType = "fast-ssd"
+
+// CephPool is a test Ceph pool
+const CephPool = "ssd"
+
 // StorageURI is a test storage URI
 const StorageURI = "/etc/ciao/ciao.json"
 
@@ -96,9 +102,40 @@ const NetAgentUUID = "6be56328-92e2-4ecd-b426-8fe529c04e0c"
 // AgentUUID is a node UUID for coordinated stop/restart/delete tests
 const AgentUUID = "4cb19522-1e18-439a-883a-f9b2a3a95f5e"
 
+// DestAgentUUID is a node UUID for live migration tests
+const DestAgentUUID = "b5b1c3a0-8b52-4d8e-9e2c-6f1d0f3b7a41"
+
+// ServerGroupUUID is a server group UUID for use in tests
+const ServerGroupUUID = "4cb19522-1e18-439a-883a-f9b2a3a95f5e"
+
+// MigrationURI is a destination URI for live migration tests
+const MigrationURI = "tcp:192.168.1.110:49152"
+
 // VolumeUUID is a node UUID for storage tests
 const VolumeUUID = "67d86208-b46c-4465-9018-e14187d4010"
 
+// ExtraVNICUUID is the UUID of the second VNIC of a test instance attached to
+// two tenant networks
+const ExtraVNICUUID = "0d5a4b2e-3f2c-4d1b-9a8e-6c7f1e2d3b4a"
+
+// ExtraTenantSubnet is the subnet of the second VNIC of a test instance
+// attached to two tenant networks
+const ExtraTenantSubnet = "172.17.1.0/24"
+
+// ExtraSubnetKey is the subnet key of the second VNIC of a test instance
+// attached to two tenant networks
+const ExtraSubnetKey = "9"
+
+// ExtraPrivateIP is the IP address of the second VNIC of a test instance
+// attached to two tenant networks
+const ExtraPrivateIP = "172.17.1.2"
+
+// LoadBalancerUUID is a load balancer UUID for use in tests
+const LoadBalancerUUID = "9d2c1b6e-4a7f-4e3d-8b5a-2f6e1c0d7a93"
+
+// PortForwardUUID is a port forward UUID for use in tests
+const PortForwardUUID = "3b9e2f7a-1c4d-4e8f-a5b6-7c8d9e0f1a2b"
+
 var computeNetwork001 = payloads.NetworkStat{
 	NodeIP:  "198.51.100.1",
 	NodeMAC: "02:00:aa:cb:84:41",
@@ -161,6 +198,80 @@ const StartYaml = `start:
   restart: false
 `
 
+// ServerGroupStartYaml is a sample workload START ssntp.Command payload for
+// a member of an anti-affinity server group
+const ServerGroupStartYaml = `start:
+  tenant_uuid: ` + TenantUUID + `
+  instance_uuid: ` + InstanceUUID + `
+  fw_type: efi
+  persistence: host
+  vm_type: qemu
+  requested_resources:
+  - type: vcpus
+    value: 2
+    mandatory: true
+  - type: mem_mb
+    value: 4096
+    mandatory: true
+  server_group:
+    group_uuid: ` + ServerGroupUUID + `
+    policy: anti-affinity
+    member_nodes:
+    - ` + AgentUUID + `
+`
+
+// NodeSelectorStartYaml is a sample workload START ssntp.Command payload for
+// an instance restricted to nodes with a given label
+const NodeSelectorStartYaml = `start:
+  tenant_uuid: ` + TenantUUID + `
+  instance_uuid: ` + InstanceUUID + `
+  fw_type: efi
+  persistence: host
+  vm_type: qemu
+  requested_resources:
+  - type: vcpus
+    value: 2
+    mandatory: true
+  - type: mem_mb
+    value: 4096
+    mandatory: true
+  node_selector:
+    ssd: "true"
+`
+
+// MultiNetworkStartYaml is a sample workload START ssntp.Command payload for
+// an instance attached to two tenant networks
+const MultiNetworkStartYaml = `start:
+  tenant_uuid: ` + TenantUUID + `
+  instance_uuid: ` + InstanceUUID + `
+  fw_type: efi
+  persistence: host
+  vm_type: qemu
+  requested_resources:
+  - type: vcpus
+    value: 2
+    mandatory: true
+  - type: mem_mb
+    value: 4096
+    mandatory: true
+  networking:
+    vnic_mac: ` + VNICMAC + `
+    vnic_uuid: ` + VNICUUID + `
+    concentrator_uuid: ` + CNCIUUID + `
+    concentrator_ip: ` + CNCIIP + `
+    subnet: ` + TenantSubnet + `
+    subnet_key: ` + SubnetKey + `
+    private_ip: ` + InstancePrivateIP + `
+  extra_networking:
+  - vnic_mac: 02:00:ac:11:01:02
+    vnic_uuid: ` + ExtraVNICUUID + `
+    concentrator_uuid: ` + CNCIUUID + `
+    concentrator_ip: ` + CNCIIP + `
+    subnet: ` + ExtraTenantSubnet + `
+    subnet_key: ` + ExtraSubnetKey + `
+    private_ip: ` + ExtraPrivateIP + `
+`
+
 // CNCIStartYaml is a sample CNCI workload START ssntp.Command payload for test cases
 const CNCIStartYaml = `start:
   instance_uuid: ` + CNCIInstanceUUID + `
@@ -286,6 +397,28 @@ const MigrateYaml = `delete:
   stop: true
 `
 
+// LiveMigrateYaml is a sample MIGRATE ssntp.Command payload for test cases
+const LiveMigrateYaml = `migrate:
+  instance_uuid: ` + InstanceUUID + `
+  workload_agent_uuid: ` + AgentUUID + `
+  destination_agent_uuid: ` + DestAgentUUID + `
+  destination_uri: ` + MigrationURI + `
+`
+
+// NodeLabels is a sample set of node labels for test cases
+var NodeLabels = map[string]string{
+	"rack": "r12",
+	"ssd":  "true",
+}
+
+// LabelNodeYaml is a sample node LabelNode ssntp.Command payload for test cases
+const LabelNodeYaml = `label_node:
+  workload_agent_uuid: ` + AgentUUID + `
+  labels:
+    rack: r12
+    ssd: "true"
+`
+
 // EvacuateYaml is a sample node EVACUATE ssntp.Command payload for test cases
 const EvacuateYaml = `evacuate:
   workload_agent_uuid: ` + AgentUUID + `
@@ -314,6 +447,130 @@ const AssignIPYaml = `assign_public_ip:
   vnic_mac: ` + VNICMAC + `
 `
 
+// SecurityGroupsYaml is a sample SecurityGroups ssntp.Command payload for test cases
+const SecurityGroupsYaml = `security_groups:
+  concentrator_uuid: ` + CNCIUUID + `
+  tenant_uuid: ` + TenantUUID + `
+  instances:
+  - instance_uuid: ` + InstanceUUID + `
+    private_ip: ` + InstancePrivateIP + `
+    rules:
+    - direction: ingress
+      protocol: tcp
+      port_min: 22
+      port_max: 22
+      remote_cidr: 0.0.0.0/0
+    - direction: egress
+      remote_cidr: 0.0.0.0/0
+`
+
+// NodeSecurityGroupsYaml is a sample SecurityGroups ssntp.Command payload
+// sent to a compute node for test cases
+const NodeSecurityGroupsYaml = `security_groups:
+  concentrator_uuid: ""
+  node_uuid: ` + AgentUUID + `
+  tenant_uuid: ` + TenantUUID + `
+  instances:
+  - instance_uuid: ` + InstanceUUID + `
+    private_ip: ` + InstancePrivateIP + `
+    rules:
+    - direction: ingress
+      protocol: tcp
+      port_min: 22
+      port_max: 22
+      remote_cidr: 0.0.0.0/0
+`
+
+// LoadBalancersYaml is a sample LoadBalancers ssntp.Command payload for test cases
+const LoadBalancersYaml = `load_balancers:
+  concentrator_uuid: ` + CNCIUUID + `
+  tenant_uuid: ` + TenantUUID + `
+  load_balancers:
+  - load_balancer_uuid: ` + LoadBalancerUUID + `
+    public_ip: ` + InstancePublicIP + `
+    protocol: tcp
+    port: 80
+    member_port: 8080
+    member_ips:
+    - ` + InstancePrivateIP + `
+    health_interval: 5
+    health_timeout: 2
+`
+
+// PortForwardsYaml is a sample PortForwards ssntp.Command payload for test cases
+const PortForwardsYaml = `port_forwards:
+  concentrator_uuid: ` + CNCIUUID + `
+  tenant_uuid: ` + TenantUUID + `
+  port_forwards:
+  - port_forward_uuid: ` + PortForwardUUID + `
+    public_ip: ` + InstancePublicIP + `
+    protocol: tcp
+    public_port: 2222
+    private_ip: ` + InstancePrivateIP + `
+    private_port: 22
+`
+
+// TenantDNSYaml is a sample TenantDNS ssntp.Command payload for test cases
+const TenantDNSYaml = `tenant_dns:
+  concentrator_uuid: ` + CNCIUUID + `
+  tenant_uuid: ` + TenantUUID + `
+  zone: ` + TenantUUID + `.ciao
+  records:
+  - name: web-0
+    ip: ` + InstancePrivateIP + `
+    ipv6: fd00:c1a0:0:a801::102
+`
+
+// NetworkInspectYaml is a sample NetworkInspect ssntp.Command payload for test cases
+const NetworkInspectYaml = `network_inspect:
+  node_uuid: ` + CNCIUUID + `
+  repair: true
+`
+
+// NetworkReportYaml is a sample NetworkReport ssntp.Event payload for test cases
+const NetworkReportYaml = `network_report:
+  node_uuid: ` + CNCIUUID + `
+  repair: true
+  expected:
+  - alias: br_192.168.0.0+24
+    name: sbr_8a3c1f2e
+  - alias: gre_192.168.0.0+24##` + AgentIP + `
+    name: sgre_52b7d6a1
+    master: br_192.168.0.0+24
+  actual:
+  - alias: br_192.168.0.0+24
+    name: sbr_8a3c1f2e
+    up: true
+  - alias: gre_192.168.0.0+24##` + AgentIP + `
+    name: sgre_52b7d6a1
+  dnsmasq:
+  - bridge: br_192.168.0.0+24
+    pid: 0
+    running: false
+  problems:
+  - alias: gre_192.168.0.0+24##` + AgentIP + `
+    problem: device is not attached to bridge br_192.168.0.0+24
+    repaired: true
+  - alias: br_192.168.0.0+24
+    problem: dnsmasq is not running
+    repaired: true
+`
+
+// TenantRoutesYaml is a sample TenantRoutes ssntp.Command payload for test cases
+const TenantRoutesYaml = `tenant_routes:
+  node_uuid: ` + AgentUUID + `
+  tenant_uuid: ` + TenantUUID + `
+  subnets:
+  - subnet: ` + TenantSubnet + `
+    concentrator_uuid: ` + CNCIUUID + `
+    concentrator_ip: ` + CNCIIP + `
+    subnet_key: ` + SubnetKey + `
+  - subnet: ` + ExtraTenantSubnet + `
+    concentrator_uuid: ` + CNCIUUID + `
+    concentrator_ip: ` + CNCIIP + `
+    subnet_key: ` + ExtraSubnetKey + `
+`
+
 // ReleaseIPYaml is a sample ReleasePublicIP ssntp.Command payload for test cases
 const ReleaseIPYaml = `release_public_ip:
   concentrator_uuid: ` + CNCIUUID + `
@@ -372,6 +629,10 @@ const ConfigureYaml = `configure:
     storage_uri: ` + StorageURI + `
   storage:
     ceph_id: ` + ManagementID + `
+    volume_types:
+    - name: ` + VolumeType + `
+      block_driver: ceph
+      ceph_pool: ` + CephPool + `
   controller:
     ciao_port: ` + CiaoPort + `
     compute_ca: ` + HTTPSCACert + `
@@ -382,6 +643,7 @@ const ConfigureYaml = `configure:
     admin_ssh_key: ""
     admin_password: ""
     client_auth_ca_cert_path: ""
+    distributed_routing: false
   launcher:
     compute_net:
     - ` + ComputeNet + `
@@ -389,6 +651,7 @@ const ConfigureYaml = `configure:
     - ` + MgmtNet + `
     disk_limit: false
     mem_limit: false
+    tunnel_type: vxlan
 `
 
 // DeleteFailureYaml is a sample workload DeleteFailure ssntp.Error payload for test cases
@@ -397,6 +660,13 @@ instance_uuid: ` + InstanceUUID + `
 reason: no_instance
 `
 
+// MigrateFailureYaml is a sample MigrateFailure ssntp.Error payload for test cases
+const MigrateFailureYaml = `node_uuid: ` + AgentUUID + `
+instance_uuid: ` + InstanceUUID + `
+destination_agent_uuid: ` + DestAgentUUID + `
+reason: transfer_failure
+`
+
 // InsDelYaml is a sample workload InstanceDeleted ssntp.Event payload for test cases
 const InsDelYaml = `instance_deleted:
   instance_uuid: ` + InstanceUUID + `
@@ -437,6 +707,11 @@ disk_total_mb: 500000
 disk_available_mb: 256000
 load: 0
 cpus_online: 4
+vcpus_allocated: 6
+mem_allocated_mb: 1024
+labels:
+  rack: r12
+  ssd: "true"
 networks:
 - ip: 192.168.1.1
   mac: 02:00:15:03:6f:49
@@ -587,6 +862,26 @@ const AttachVolumeYaml = `attach_volume:
   workload_agent_uuid: ` + AgentUUID + `
 `
 
+// DetachVolumeYaml is a sample yaml payload for the ssntp Detach Volume command.
+const DetachVolumeYaml = `detach_volume:
+  instance_uuid: ` + InstanceUUID + `
+  volume_uuid: ` + VolumeUUID + `
+  workload_agent_uuid: ` + AgentUUID + `
+`
+
+// BadDetachVolumeYaml is a corrupt yaml payload for the ssntp Detach Volume command.
+const BadDetachVolumeYaml = `detach_volume:
+  volume_uuid: ` + VolumeUUID + `
+`
+
+// ResizeVolumeYaml is a sample yaml payload for the ssntp Resize Volume command.
+const ResizeVolumeYaml = `resize_volume:
+  instance_uuid: ` + InstanceUUID + `
+  volume_uuid: ` + VolumeUUID + `
+  workload_agent_uuid: ` + AgentUUID + `
+  size_gib: 20
+`
+
 // BadAttachVolumeYaml is a corrupt yaml payload for the ssntp Attach Volume command.
 const BadAttachVolumeYaml = `attach_volume:
   volume_uuid: ` + VolumeUUID + `
@@ -598,3 +893,10 @@ instance_uuid: ` + InstanceUUID + `
 volume_uuid: ` + VolumeUUID + `
 reason: attach_failure
 `
+
+// DetachVolumeFailureYaml is a sample DetachVolumeFailure ssntp.Error payload for test cases
+const DetachVolumeFailureYaml = `node_uuid: ` + AgentUUID + `
+instance_uuid: ` + InstanceUUID + `
+volume_uuid: ` + VolumeUUID + `
+reason: detach_failure
+`
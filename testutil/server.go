@@ -325,6 +325,30 @@ func getAttachVolumeResult(payload []byte, result *Result) {
 	}
 }
 
+func getDetachVolumeResult(payload []byte, result *Result) {
+	var volCmd payloads.DetachVolume
+
+	err := yaml.Unmarshal(payload, &volCmd)
+	result.Err = err
+	if err == nil {
+		result.NodeUUID = volCmd.Detach.WorkloadAgentUUID
+		result.InstanceUUID = volCmd.Detach.InstanceUUID
+		result.VolumeUUID = volCmd.Detach.VolumeUUID
+	}
+}
+
+func getResizeVolumeResult(payload []byte, result *Result) {
+	var volCmd payloads.ResizeVolume
+
+	err := yaml.Unmarshal(payload, &volCmd)
+	result.Err = err
+	if err == nil {
+		result.NodeUUID = volCmd.Resize.WorkloadAgentUUID
+		result.InstanceUUID = volCmd.Resize.InstanceUUID
+		result.VolumeUUID = volCmd.Resize.VolumeUUID
+	}
+}
+
 func getStartResults(payload []byte, result *Result) {
 	var startCmd payloads.Start
 	var nn bool
@@ -366,6 +390,36 @@ func getRestoreResults(payload []byte, result *Result) {
 	}
 }
 
+func getLabelNodeResults(payload []byte, result *Result) {
+	var labelCmd payloads.LabelNode
+
+	err := yaml.Unmarshal(payload, &labelCmd)
+	result.Err = err
+	if err == nil {
+		result.NodeUUID = labelCmd.LabelNode.WorkloadAgentUUID
+	}
+}
+
+func getNetworkInspectResults(payload []byte, result *Result) {
+	var inspectCmd payloads.NetworkInspect
+
+	err := yaml.Unmarshal(payload, &inspectCmd)
+	result.Err = err
+	if err == nil {
+		result.NodeUUID = inspectCmd.Inspect.NodeUUID
+	}
+}
+
+func getTenantRoutesResults(payload []byte, result *Result) {
+	var routesCmd payloads.CommandTenantRoutes
+
+	err := yaml.Unmarshal(payload, &routesCmd)
+	result.Err = err
+	if err == nil {
+		result.NodeUUID = routesCmd.TenantRoutes.NodeUUID
+	}
+}
+
 // CommandNotify implements an SSNTP CommandNotify callback for SsntpTestServer
 func (server *SsntpTestServer) CommandNotify(uuid string, command ssntp.Command, frame *ssntp.Frame) {
 	var result Result
@@ -398,6 +452,18 @@ func (server *SsntpTestServer) CommandNotify(uuid string, command ssntp.Command,
 	case ssntp.Restore:
 		getRestoreResults(payload, &result)
 
+	case ssntp.LabelNode:
+		getLabelNodeResults(payload, &result)
+
+	case ssntp.NetworkInspect:
+		getNetworkInspectResults(payload, &result)
+		if result.Err == nil {
+			server.Ssntp.SendCommand(result.NodeUUID, command, frame.Payload)
+		}
+
+	case ssntp.TenantRoutes:
+		getTenantRoutesResults(payload, &result)
+
 	case ssntp.STATS:
 		var statsCmd payloads.Stat
 
@@ -407,6 +473,12 @@ func (server *SsntpTestServer) CommandNotify(uuid string, command ssntp.Command,
 	case ssntp.AttachVolume:
 		getAttachVolumeResult(payload, &result)
 
+	case ssntp.DetachVolume:
+		getDetachVolumeResult(payload, &result)
+
+	case ssntp.ResizeVolume:
+		getResizeVolumeResult(payload, &result)
+
 	default:
 		fmt.Fprintf(os.Stderr, "server unhandled command %s\n", command.String())
 	}
@@ -447,6 +519,8 @@ func (server *SsntpTestServer) EventNotify(uuid string, event ssntp.Event, frame
 		// forwards to CNCI via server.EventForward()
 	case ssntp.PublicIPAssigned:
 		// forwards from CNCI Controller(s) via server.EventForward()
+	case ssntp.NetworkReport:
+		// forward rule auto-sends to controllers
 	default:
 		fmt.Fprintf(os.Stderr, "server unhandled event %s\n", event.String())
 	}
@@ -601,6 +675,27 @@ func (server *SsntpTestServer) handleAttachVolume(payload []byte) ssntp.ForwardD
 	return dest
 }
 
+func (server *SsntpTestServer) handleDetachVolume(payload []byte) ssntp.ForwardDestination {
+	var cmd payloads.DetachVolume
+	var dest ssntp.ForwardDestination
+
+	err := yaml.Unmarshal(payload, &cmd)
+	if err != nil {
+		return dest
+	}
+
+	server.clientsLock.Lock()
+	defer server.clientsLock.Unlock()
+
+	for _, c := range server.clients {
+		if c == cmd.Detach.WorkloadAgentUUID {
+			dest.AddRecipient(c)
+		}
+	}
+
+	return dest
+}
+
 // CommandForward implements an SSNTP CommandForward callback for SsntpTestServer
 func (server *SsntpTestServer) CommandForward(uuid string, command ssntp.Command, frame *ssntp.Frame) (dest ssntp.ForwardDestination) {
 	payload := frame.Payload
@@ -610,6 +705,8 @@ func (server *SsntpTestServer) CommandForward(uuid string, command ssntp.Command
 		dest = server.handleStart(payload)
 	case ssntp.AttachVolume:
 		dest = server.handleAttachVolume(payload)
+	case ssntp.DetachVolume:
+		dest = server.handleDetachVolume(payload)
 	case ssntp.EVACUATE:
 		fallthrough
 	case ssntp.DELETE:
@@ -685,10 +782,18 @@ func StartTestServer() *SsntpTestServer {
 				Operand: ssntp.AttachVolumeFailure,
 				Dest:    ssntp.Controller,
 			},
+			{ // all DetachVolumeFailure errors go to all Controllers
+				Operand: ssntp.DetachVolumeFailure,
+				Dest:    ssntp.Controller,
+			},
 			{ // all PublicIPAssigned events go to all Controllers
 				Operand: ssntp.PublicIPAssigned,
 				Dest:    ssntp.Controller,
 			},
+			{ // all NetworkReport events go to all Controllers
+				Operand: ssntp.NetworkReport,
+				Dest:    ssntp.Controller,
+			},
 			{ // all START command are processed by the Command forwarder
 				Operand:        ssntp.START,
 				CommandForward: server,
@@ -713,6 +818,10 @@ func StartTestServer() *SsntpTestServer {
 				Operand:        ssntp.AttachVolume,
 				CommandForward: server,
 			},
+			{ // all DetachVolume commands are processed by the Command forwarder
+				Operand:        ssntp.DetachVolume,
+				CommandForward: server,
+			},
 		},
 	}
 